@@ -81,6 +81,8 @@ func ChannelType2APIType(channelType int) (int, bool) {
 		apiType = constant.APITypeSub2API
 	case constant.ChannelTypeNewAPI:
 		apiType = constant.APITypeNewAPI
+	case constant.ChannelTypeAzureSpeech:
+		apiType = constant.APITypeAzureSpeech
 	}
 	if apiType == -1 {
 		return constant.APITypeOpenAI, false
@@ -1,10 +1,12 @@
 package common
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 
 	"github.com/abema/go-mp4"
 	"github.com/go-audio/aiff"
@@ -48,6 +50,53 @@ func GetAudioDuration(ctx context.Context, f io.ReadSeeker, ext string) (duratio
 	return duration, err
 }
 
+// AudioDurationTokens 按每分钟 1000 token 将音频时长换算为 token，与 $price / minute 的定价对齐。
+// 时长可能来自用户上传或上游返回的元数据，负值钳到 0，过大的值饱和转换防止 int 回绕。
+func AudioDurationTokens(duration float64) int {
+	if duration <= 0 {
+		return 0
+	}
+	return QuotaRound(math.Ceil(duration) / 60.0 * 1000)
+}
+
+// PCMDuration 计算 16-bit 小端 PCM 数据的时长（秒）
+func PCMDuration(size int, sampleRate int, channels int) float64 {
+	if sampleRate <= 0 || channels <= 0 {
+		return 0
+	}
+	return float64(size) / float64(sampleRate*channels*2)
+}
+
+// GetAudioBytesDuration 计算内存中音频数据的时长，format 为不带点的格式名（mp3、wav、pcm 等）。
+// PCM 没有文件头，需由调用方给出采样率，按单声道 16-bit 计算
+func GetAudioBytesDuration(ctx context.Context, data []byte, format string, pcmSampleRate int) (float64, error) {
+	if format == "pcm" {
+		return PCMDuration(len(data), pcmSampleRate, 1), nil
+	}
+	return GetAudioDuration(ctx, bytes.NewReader(data), "."+format)
+}
+
+// PCMToWAV 为 16-bit 小端 PCM 数据添加 RIFF/WAVE 文件头
+func PCMToWAV(pcm []byte, sampleRate int, channels int) []byte {
+	const bitsPerSample = 16
+	blockAlign := channels * bitsPerSample / 8
+	buf := bytes.NewBuffer(make([]byte, 0, 44+len(pcm)))
+	buf.WriteString("RIFF")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(1))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(channels))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(sampleRate))
+	_ = binary.Write(buf, binary.LittleEndian, uint32(sampleRate*blockAlign))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(blockAlign))
+	_ = binary.Write(buf, binary.LittleEndian, uint16(bitsPerSample))
+	buf.WriteString("data")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+	return buf.Bytes()
+}
+
 // getMP3Duration 解析 MP3 文件以获取时长。
 // 注意：对于 VBR (Variable Bitrate) MP3，这个估算可能不完全精确，但通常足够好。
 // FFmpeg 在这种情况下会扫描整个文件来获得精确值，但这里的库提供了快速估算。
@@ -20,6 +20,11 @@ const (
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
 	ContextKeyTokenCrossGroupRetry   ContextKey = "token_cross_group_retry"
 	ContextKeyTokenAutoGroups        ContextKey = "token_auto_groups"
+	ContextKeyTokenOrgId             ContextKey = "token_org_id"
+	ContextKeyTokenBudgeted          ContextKey = "token_budgeted"
+	ContextKeyTokenAllowedProjects   ContextKey = "token_allowed_projects"
+	ContextKeyTokenCallbackUrl       ContextKey = "token_callback_url"
+	ContextKeyProject                ContextKey = "project"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -53,6 +58,12 @@ const (
 	ContextKeyUsingGroup  ContextKey = "group"
 	ContextKeyUserName    ContextKey = "username"
 
+	// ContextKeyUserDunningStage 后付费催缴阶段，限流中间件据此对催缴中的用户降速
+	ContextKeyUserDunningStage ContextKey = "user_dunning_stage"
+
+	// ContextKeyUserResellerId 下游用户所属分销商的用户 ID，计费据此叠加分销商加价
+	ContextKeyUserResellerId ContextKey = "user_reseller_id"
+
 	ContextKeyLocalCountTokens ContextKey = "local_count_tokens"
 
 	ContextKeySystemPromptOverride ContextKey = "system_prompt_override"
@@ -5,6 +5,7 @@ type TaskPlatform string
 const (
 	TaskPlatformSuno       TaskPlatform = "suno"
 	TaskPlatformMidjourney              = "mj"
+	TaskPlatformImage                   = "image"
 )
 
 const (
@@ -16,6 +17,9 @@ const (
 	TaskActionFirstTailGenerate = "firstTailGenerate"
 	TaskActionReferenceGenerate = "referenceGenerate"
 	TaskActionRemix             = "remixGenerate"
+	TaskActionImageGenerate     = "imageGenerate"
+	TaskActionImageEdit         = "imageEdit"
+	TaskActionImageVariation    = "imageVariation"
 )
 
 var SunoModel2Action = map[string]string{
@@ -45,10 +45,21 @@ var auditContentTemplates = map[string]string{
 	"channel.upstream_apply":     "Applied upstream model changes to channel (ID: ${id})",
 	"channel.upstream_apply_all": "Applied upstream model changes to ${count} channels",
 
+	"authz.role_create":       "Created authorization role ${name} (${key})",
+	"authz.role_update":       "Updated authorization role ${name} (${key})",
+	"authz.role_delete":       "Deleted authorization role ${key}",
+	"authz.user_roles_update": "Set authorization roles of user ${username} to [${roles}]",
+
 	"redemption.create": "Created ${count} redemption codes named ${name} (${quota} each)",
 
 	"subscription.plan_reset":      "Reset active subscriptions for plan ${plan_id}",
 	"subscription.user_plan_reset": "Reset active plan ${plan_id} subscriptions for user ${target_user_id}",
+
+	"organization.update":       "Updated organization (ID: ${id})",
+	"organization.quota_adjust": "Adjusted organization (ID: ${id}) quota by ${quota}",
+	"organization.delete":       "Deleted organization (ID: ${id})",
+
+	"credit_ledger.repair": "Reconciled credit ledger of user ${id} by ${delta}",
 }
 
 // auditContentEN 按 action 模板渲染英文兜底文本；未登记的 action 退回 action 本身。
@@ -2,7 +2,12 @@ package controller
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/service/authz"
 
 	"github.com/gin-gonic/gin"
@@ -22,3 +27,128 @@ func GetPermissionCatalog(c *gin.Context) {
 		},
 	})
 }
+
+// GetAuthzRoles lists the built-in and custom roles with their grant matrices.
+func GetAuthzRoles(c *gin.Context) {
+	common.ApiSuccess(c, authz.Roles())
+}
+
+// CreateAuthzRole creates a custom role such as a billing operator or a
+// read-only support role.
+func CreateAuthzRole(c *gin.Context) {
+	var input authz.RoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := authz.CheckGrantable(c.GetInt("id"), c.GetInt("role"), input.Grants); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := authz.CreateRole(input); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "authz.role_create", map[string]interface{}{
+		"key":  input.Key,
+		"name": input.Name,
+	})
+	common.ApiSuccess(c, nil)
+}
+
+// UpdateAuthzRole replaces the name, description and grants of a custom role.
+func UpdateAuthzRole(c *gin.Context) {
+	var input authz.RoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	key := c.Param("key")
+	if err := authz.CheckGrantable(c.GetInt("id"), c.GetInt("role"), input.Grants); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := authz.UpdateRole(key, input); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "authz.role_update", map[string]interface{}{
+		"key":  key,
+		"name": input.Name,
+	})
+	common.ApiSuccess(c, nil)
+}
+
+// DeleteAuthzRole deletes a custom role that is no longer assigned to anyone.
+func DeleteAuthzRole(c *gin.Context) {
+	key := c.Param("key")
+	if err := authz.DeleteRole(key); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "authz.role_delete", map[string]interface{}{
+		"key": key,
+	})
+	common.ApiSuccess(c, nil)
+}
+
+// GetUserAuthzRoles returns the custom roles assigned to a user.
+func GetUserAuthzRoles(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidId)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"roles": authz.UserRoles(id),
+	})
+}
+
+type updateUserAuthzRolesRequest struct {
+	Roles []string `json:"roles"`
+}
+
+// UpdateUserAuthzRoles replaces the custom roles of an administrator. Root is
+// a superuser and common users never pass AdminAuth, so only admins can be
+// assigned custom roles. Callers cannot change their own roles, and may only
+// assign roles whose grants they already hold.
+func UpdateUserAuthzRoles(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidId)
+		return
+	}
+	if id == c.GetInt("id") {
+		common.ApiErrorMsg(c, "cannot change your own authorization roles")
+		return
+	}
+	var req updateUserAuthzRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if user.Role != common.RoleAdminUser {
+		common.ApiErrorMsg(c, "custom roles can only be assigned to admin users")
+		return
+	}
+	if err := authz.CheckRolesGrantable(c.GetInt("id"), c.GetInt("role"), req.Roles); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := authz.SetUserRoles(id, req.Roles); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAuditFor(c, id, "authz.user_roles_update", map[string]interface{}{
+		"username": user.Username,
+		"roles":    strings.Join(req.Roles, ","),
+	})
+	common.ApiSuccess(c, gin.H{
+		"roles": authz.UserRoles(id),
+	})
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SimulateBilling replays consume logs through a candidate pricing
+// configuration. The window defaults to the previous statement month; windows
+// longer than service.BillingSimulationSyncWindow (or async=true) run as a
+// system task whose result is read back through the system task API.
+func SimulateBilling(c *gin.Context) {
+	var req service.BillingSimulationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.StartTimestamp == 0 && req.EndTimestamp == 0 {
+		req.StartTimestamp, req.EndTimestamp = service.PreviousStatementPeriod(time.Now())
+	}
+	if err := req.Validate(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	window := time.Duration(req.EndTimestamp-req.StartTimestamp) * time.Second
+	if c.Query("async") == "true" || window > service.BillingSimulationSyncWindow {
+		task, created, err := service.EnqueueSystemTask(model.SystemTaskTypeBillingSim, req)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		common.ApiSuccess(c, gin.H{
+			"task":    task.ToResponse(),
+			"created": created,
+		})
+		return
+	}
+	result, err := service.RunBillingSimulation(c.Request.Context(), &req, nil)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"result": result,
+	})
+}
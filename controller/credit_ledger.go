@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSelfCreditGrants 返回当前用户仍可使用的额度授予，按消费顺序排列，
+// 用于展示哪些额度将在何时过期。
+func GetSelfCreditGrants(c *gin.Context) {
+	grants, err := model.GetUserCreditGrants(c.GetInt("id"), true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, grants)
+}
+
+func GetUserCreditGrants(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	grants, err := model.GetUserCreditGrants(userId, c.Query("active") == "true")
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, grants)
+}
+
+func GetUserCreditLedgerEntries(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	entries, total, err := model.GetUserCreditLedgerEntries(userId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(entries)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetUserCreditLedgerBalance 返回用户在 at 时间戳（秒，缺省为当前）的账本余额。
+func GetUserCreditLedgerBalance(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	at, _ := strconv.ParseInt(c.Query("at"), 10, 64)
+	balance, err := model.GetCreditLedgerBalance(userId, at)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"user_id": userId,
+		"at":      at,
+		"balance": balance,
+	})
+}
+
+// ReconcileCreditLedger 比对 User.Quota 与账本余额，可用 user_id 只检查一个用户。
+func ReconcileCreditLedger(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	mismatches, checked, err := model.ReconcileCreditLedger(userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"checked":    checked,
+		"mismatches": mismatches,
+	})
+}
+
+// RepairCreditLedger 以 User.Quota 为准补记一笔调整分录，抹平账本差额。
+func RepairCreditLedger(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	delta, err := model.RepairCreditLedger(userId)
+	if errors.Is(err, model.ErrCreditLedgerNotOpened) {
+		common.ApiErrorMsg(c, err.Error())
+		return
+	}
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAuditFor(c, userId, "credit_ledger.repair", map[string]interface{}{
+		"id":    userId,
+		"delta": delta,
+	})
+	common.ApiSuccess(c, gin.H{"delta": delta})
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/oauth"
+	"github.com/QuantumNous/new-api/setting/system_setting"
 	"github.com/gin-gonic/gin"
 )
 
@@ -449,6 +450,15 @@ func buildUserOAuthBindingsResponse(userId int) ([]UserOAuthBindingResponse, err
 
 	response := make([]UserOAuthBindingResponse, 0, len(bindings))
 	for _, binding := range bindings {
+		if binding.ProviderId == model.SAMLOAuthProviderId {
+			response = append(response, UserOAuthBindingResponse{
+				ProviderId:     binding.ProviderId,
+				ProviderName:   system_setting.GetSAMLSettings().GetEffectiveDisplayName(),
+				ProviderSlug:   "saml",
+				ProviderUserId: binding.ProviderUserId,
+			})
+			continue
+		}
 		provider, err := model.GetCustomOAuthProviderById(binding.ProviderId)
 		if err != nil {
 			continue
@@ -0,0 +1,249 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	taskdto "github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relaykit/types"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+const preferRespondAsync = "respond-async"
+
+// isAsyncImageRequest 客户端通过 Prefer: respond-async 显式选择异步图片生成（生成、编辑与变体），流式请求不支持异步
+func isAsyncImageRequest(c *gin.Context, relayInfo *relaycommon.RelayInfo) bool {
+	switch relayInfo.RelayMode {
+	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits, relayconstant.RelayModeImagesVariations:
+	default:
+		return false
+	}
+	if relayInfo.IsStream {
+		return false
+	}
+	for _, header := range c.Request.Header.Values("Prefer") {
+		for _, preference := range strings.Split(header, ",") {
+			token, _, _ := strings.Cut(preference, ";")
+			if strings.EqualFold(strings.TrimSpace(token), preferRespondAsync) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// asyncImageResponse 在内存中收集后台图片请求的响应
+type asyncImageResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *asyncImageResponse) Header() http.Header {
+	return w.header
+}
+
+func (w *asyncImageResponse) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(data)
+}
+
+func (w *asyncImageResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// Flush 满足 gin 对 http.Flusher 的要求，内存响应无需刷新
+func (w *asyncImageResponse) Flush() {}
+
+// asyncImageRequest 脱离原请求生命周期的后台图片请求：请求体复制到独立存储，上下文键值原样保留
+type asyncImageRequest struct {
+	request *http.Request
+	keys    map[string]any
+	body    common.BodyStorage
+}
+
+func newAsyncImageRequest(c *gin.Context) (*asyncImageRequest, error) {
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return nil, err
+	}
+	body, err := storage.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	bodyStorage, err := common.CreateBodyStorage(body)
+	if err != nil {
+		return nil, err
+	}
+	request := c.Request.Clone(context.WithoutCancel(c.Request.Context()))
+	// 原请求结束时会清理 multipart 临时文件，后台请求需从请求体重新解析
+	request.MultipartForm = nil
+	request.Form = nil
+	request.PostForm = nil
+	keys := make(map[string]any, len(c.Keys))
+	for key, value := range c.Keys {
+		keys[key] = value
+	}
+	return &asyncImageRequest{request: request, keys: keys, body: bodyStorage}, nil
+}
+
+// run 以一次内部请求的方式在独立的 gin 引擎中执行 handler，handler 获得完整的 gin 上下文，
+// 响应写入内存；handler 返回后释放请求体存储
+func (r *asyncImageRequest) run(handler func(c *gin.Context, response *asyncImageResponse)) {
+	response := &asyncImageResponse{header: http.Header{}}
+	engine := gin.New()
+	engine.Handle(r.request.Method, "/*path", func(c *gin.Context) {
+		for key, value := range r.keys {
+			c.Set(key, value)
+		}
+		c.Set(common.KeyBodyStorage, r.body)
+		defer common.CleanupBodyStorage(c)
+		handler(c, response)
+	})
+	engine.ServeHTTP(response, r.request)
+}
+
+// release 任务未能提交时释放请求体存储
+func (r *asyncImageRequest) release() {
+	_ = r.body.Close()
+}
+
+// submitAsyncImageTask 将图片请求登记为任务并立即返回任务 ID，上游调用在后台完成。
+// 预扣费在提交时完成，结算或退款在后台任务结束时进行
+func submitAsyncImageTask(c *gin.Context, relayInfo *relaycommon.RelayInfo, relayFormat types.RelayFormat) *types.NewAPIError {
+	callbackURL, err := service.ResolveTaskCallbackURL(c, "")
+	if err != nil {
+		return types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	asyncRequest, err := newAsyncImageRequest(c)
+	if err != nil {
+		return types.NewErrorWithStatusCode(err, types.ErrorCodeReadRequestBodyFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+
+	task := model.InitTask(constant.TaskPlatformImage, relayInfo)
+	task.Status = model.TaskStatusSubmitted
+	task.Action = constant.TaskActionImageGenerate
+	switch relayInfo.RelayMode {
+	case relayconstant.RelayModeImagesEdits:
+		task.Action = constant.TaskActionImageEdit
+	case relayconstant.RelayModeImagesVariations:
+		task.Action = constant.TaskActionImageVariation
+	}
+	task.Properties.OriginModelName = relayInfo.OriginModelName
+	task.PrivateData.BillingSource = relayInfo.BillingSource
+	task.PrivateData.SubscriptionId = relayInfo.SubscriptionId
+	task.PrivateData.OrganizationId = relayInfo.OrganizationId
+	task.PrivateData.TokenId = relayInfo.TokenId
+	task.PrivateData.NodeName = common.NodeName
+	task.PrivateData.Project = common.GetContextKeyString(c, constant.ContextKeyProject)
+	task.PrivateData.CallbackURL = callbackURL
+	task.PrivateData.BillingContext = &model.TaskBillingContext{
+		ModelPrice:      relayInfo.PriceData.ModelPrice,
+		GroupRatio:      relayInfo.PriceData.GroupRatioInfo.GroupRatio,
+		ModelRatio:      relayInfo.PriceData.ModelRatio,
+		OtherRatios:     relayInfo.PriceData.OtherRatios(),
+		OriginModelName: relayInfo.OriginModelName,
+		PerCallBilling:  relayInfo.PriceData.UsePrice,
+	}
+	// 任务额度记录预扣额度：进程中断导致任务超时时，由超时清理按该额度退款
+	task.Quota = relayInfo.FinalPreConsumedQuota
+	if err := task.Insert(); err != nil {
+		asyncRequest.release()
+		return types.NewError(err, types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
+	}
+
+	gopool.Go(func() {
+		asyncRequest.run(func(asyncCtx *gin.Context, response *asyncImageResponse) {
+			runAsyncImageTask(asyncCtx, response, relayInfo, relayFormat, task)
+		})
+	})
+
+	c.Header("Preference-Applied", preferRespondAsync)
+	c.JSON(http.StatusAccepted, taskdto.TaskResponse[any]{
+		Code: "success",
+		Data: relay.TaskModel2Dto(task),
+	})
+	return nil
+}
+
+// runAsyncImageTask 在后台执行图片请求并推进任务状态。
+// 图片处理流程中的结算被推迟，由 finishAsyncImageTask 在任务 CAS 推进到终态后结算或退款
+func runAsyncImageTask(c *gin.Context, response *asyncImageResponse, relayInfo *relaycommon.RelayInfo, relayFormat types.RelayFormat, task *model.Task) {
+	ctx := c.Request.Context()
+
+	task.Status = model.TaskStatusInProgress
+	task.StartTime = time.Now().Unix()
+	task.Progress = "50%"
+	won, err := task.UpdateWithStatus(model.TaskStatusSubmitted)
+	if err != nil || !won {
+		// 未能开始的任务保持预扣状态，由超时清理统一退款
+		logger.LogError(ctx, fmt.Sprintf("async image task %s failed to start: won=%t, err=%v", task.TaskID, won, err))
+		return
+	}
+
+	relayInfo.DeferSettle = true
+	apiErr := relayWithRetry(c, relayInfo, relayFormat)
+	finishAsyncImageTask(c, relayInfo, task, apiErr, response.body.Bytes())
+}
+
+// finishAsyncImageTask 以 CAS 把任务从进行中推进到终态，只有赢得 CAS 的一方处理计费：
+// 成功时按实际用量结算，失败时退还预扣费。超时清理先一步结束任务时它已按预扣额度退款，
+// 这里既不结算也不退款
+func finishAsyncImageTask(c *gin.Context, relayInfo *relaycommon.RelayInfo, task *model.Task, apiErr *types.NewAPIError, data []byte) {
+	ctx := c.Request.Context()
+	relayInfo.DeferSettle = false
+
+	task.Status = model.TaskStatusSuccess
+	task.Progress = "100%"
+	task.FinishTime = time.Now().Unix()
+	task.ChannelId = relayInfo.ChannelId
+	if apiErr != nil {
+		apiErr = service.NormalizeViolationFeeError(apiErr)
+		task.Status = model.TaskStatusFailure
+		task.FailReason = apiErr.Error()
+		task.Quota = 0
+	} else {
+		task.Data = data
+		task.PrivateData.ResultURL = gjson.GetBytes(task.Data, "data.0.url").String()
+		task.Quota = relayInfo.SettledQuota
+	}
+
+	won, err := task.UpdateWithStatus(model.TaskStatusInProgress)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("async image task %s update error: %v", task.TaskID, err))
+		return
+	}
+	if !won {
+		logger.LogWarn(ctx, fmt.Sprintf("async image task %s already finished by another worker, billing left to it", task.TaskID))
+		return
+	}
+	if apiErr != nil {
+		logger.LogError(ctx, fmt.Sprintf("async image task %s failed: %s", task.TaskID, common.LocalLogPreview(apiErr.Error())))
+		if relayInfo.Billing != nil {
+			relayInfo.Billing.Refund(c)
+		}
+		service.ChargeViolationFeeIfNeeded(c, relayInfo, apiErr)
+	} else if err := service.SettleBilling(c, relayInfo, relayInfo.SettledQuota); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("async image task %s settle error: %v", task.TaskID, err))
+	}
+	service.OnTaskFinished(ctx, task)
+}
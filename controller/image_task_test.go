@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relaykit/types"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newImageTaskTestContext(prefer ...string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/generations", strings.NewReader(`{"model":"gpt-image-1","prompt":"cat"}`))
+	for _, value := range prefer {
+		c.Request.Header.Add("Prefer", value)
+	}
+	return c
+}
+
+func TestIsAsyncImageRequestHonoursPreferHeader(t *testing.T) {
+	imageInfo := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeImagesGenerations}
+
+	assert.True(t, isAsyncImageRequest(newImageTaskTestContext("respond-async"), imageInfo))
+	assert.True(t, isAsyncImageRequest(newImageTaskTestContext("return=minimal", "Respond-Async; wait=10"), imageInfo))
+	assert.False(t, isAsyncImageRequest(newImageTaskTestContext("return=minimal"), imageInfo))
+	assert.False(t, isAsyncImageRequest(newImageTaskTestContext(), imageInfo))
+
+	variationInfo := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeImagesVariations}
+	assert.True(t, isAsyncImageRequest(newImageTaskTestContext("respond-async"), variationInfo))
+
+	chatInfo := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeChatCompletions}
+	assert.False(t, isAsyncImageRequest(newImageTaskTestContext("respond-async"), chatInfo))
+	streamInfo := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeImagesGenerations, IsStream: true}
+	assert.False(t, isAsyncImageRequest(newImageTaskTestContext("respond-async"), streamInfo))
+}
+
+func TestAsyncImageRequestOutlivesOriginalRequest(t *testing.T) {
+	c := newImageTaskTestContext("respond-async")
+	c.Set("id", 7)
+	_, err := common.GetBodyStorage(c)
+	require.NoError(t, err)
+
+	asyncRequest, err := newAsyncImageRequest(c)
+	require.NoError(t, err)
+	common.CleanupBodyStorage(c)
+
+	var response *asyncImageResponse
+	asyncRequest.run(func(asyncCtx *gin.Context, r *asyncImageResponse) {
+		response = r
+		assert.Equal(t, 7, asyncCtx.GetInt("id"))
+		storage, err := common.GetBodyStorage(asyncCtx)
+		require.NoError(t, err)
+		body, err := io.ReadAll(storage)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"model":"gpt-image-1","prompt":"cat"}`, string(body))
+		asyncCtx.JSON(http.StatusOK, gin.H{"data": []any{}})
+	})
+	assert.Equal(t, http.StatusOK, response.status)
+	assert.JSONEq(t, `{"data":[]}`, response.body.String())
+}
+
+func setupAsyncImageBillingTest(t *testing.T) (*gin.Context, *relaycommon.RelayInfo, *model.Task) {
+	t.Helper()
+	previousDB, previousLogDB := model.DB, model.LOG_DB
+	previousRedis, previousBatch := common.RedisEnabled, common.BatchUpdateEnabled
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, db.AutoMigrate(&model.User{}, &model.Token{}, &model.Task{}, &model.Log{}, &model.Channel{}))
+	model.DB, model.LOG_DB = db, db
+	common.RedisEnabled, common.BatchUpdateEnabled = false, false
+	t.Cleanup(func() {
+		model.DB, model.LOG_DB = previousDB, previousLogDB
+		common.RedisEnabled, common.BatchUpdateEnabled = previousRedis, previousBatch
+	})
+
+	require.NoError(t, db.Create(&model.User{Id: 1, Username: "image_user", Quota: 1000, Status: common.UserStatusEnabled}).Error)
+	require.NoError(t, db.Create(&model.Token{Id: 1, UserId: 1, Key: "image-token", Name: "image", Status: common.TokenStatusEnabled, RemainQuota: 1000}).Error)
+
+	c := newImageTaskTestContext("respond-async")
+	relayInfo := &relaycommon.RelayInfo{
+		UserId:          1,
+		TokenId:         1,
+		TokenKey:        "image-token",
+		RelayMode:       relayconstant.RelayModeImagesGenerations,
+		OriginModelName: "gpt-image-1",
+		ForcePreConsume: true,
+		StartTime:       time.Now(),
+		ChannelMeta:     &relaycommon.ChannelMeta{},
+	}
+	relayInfo.UserSetting.BillingPreference = "wallet_only"
+	require.Nil(t, service.PreConsumeBilling(c, 300, relayInfo))
+	assert.Equal(t, 700, getAsyncImageUserQuota(t))
+
+	task := model.InitTask(constant.TaskPlatformImage, relayInfo)
+	task.Status = model.TaskStatusInProgress
+	task.Quota = relayInfo.FinalPreConsumedQuota
+	task.PrivateData.TokenId = relayInfo.TokenId
+	require.NoError(t, task.Insert())
+	return c, relayInfo, task
+}
+
+func getAsyncImageUserQuota(t *testing.T) int {
+	t.Helper()
+	var user model.User
+	require.NoError(t, model.DB.Select("quota").Where("id = ?", 1).First(&user).Error)
+	return user.Quota
+}
+
+func TestAsyncImageTaskSettlesDeferredBillingOnSuccess(t *testing.T) {
+	c, relayInfo, task := setupAsyncImageBillingTest(t)
+
+	// 图片处理流程中的结算被推迟，只记录实际额度
+	relayInfo.DeferSettle = true
+	require.NoError(t, service.SettleBilling(c, relayInfo, 200))
+	assert.Equal(t, 700, getAsyncImageUserQuota(t))
+
+	finishAsyncImageTask(c, relayInfo, task, nil, []byte(`{"data":[{"url":"https://example.com/a.png"}]}`))
+	assert.Equal(t, 800, getAsyncImageUserQuota(t))
+	reloaded, exist, err := model.GetByTaskId(1, task.TaskID)
+	require.NoError(t, err)
+	require.True(t, exist)
+	assert.Equal(t, model.TaskStatus(model.TaskStatusSuccess), reloaded.Status)
+	assert.Equal(t, 200, reloaded.Quota)
+}
+
+func TestAsyncImageTaskRefundsPreChargeOnFailure(t *testing.T) {
+	c, relayInfo, task := setupAsyncImageBillingTest(t)
+
+	finishAsyncImageTask(c, relayInfo, task, types.NewError(errors.New("upstream failed"), types.ErrorCodeBadResponse), nil)
+	require.Eventually(t, func() bool { return getAsyncImageUserQuota(t) == 1000 }, time.Second, 10*time.Millisecond)
+	reloaded, _, err := model.GetByTaskId(1, task.TaskID)
+	require.NoError(t, err)
+	assert.Equal(t, model.TaskStatus(model.TaskStatusFailure), reloaded.Status)
+}
+
+func TestAsyncImageTaskSkipsBillingAfterTimeoutRefund(t *testing.T) {
+	c, relayInfo, task := setupAsyncImageBillingTest(t)
+
+	// 超时清理先赢得 CAS 并按预扣额度退款
+	swept := *task
+	swept.Status = model.TaskStatusFailure
+	swept.Progress = "100%"
+	won, err := swept.UpdateWithStatus(model.TaskStatusInProgress)
+	require.NoError(t, err)
+	require.True(t, won)
+	require.True(t, service.RefundTaskQuota(context.Background(), &swept, "timeout"))
+	assert.Equal(t, 1000, getAsyncImageUserQuota(t))
+
+	relayInfo.DeferSettle = true
+	require.NoError(t, service.SettleBilling(c, relayInfo, 200))
+	finishAsyncImageTask(c, relayInfo, task, nil, []byte(`{"data":[]}`))
+	assert.Equal(t, 1000, getAsyncImageUserQuota(t), "the timed-out task must be neither charged nor refunded again")
+	reloaded, _, err := model.GetByTaskId(1, task.TaskID)
+	require.NoError(t, err)
+	assert.Equal(t, model.TaskStatus(model.TaskStatusFailure), reloaded.Status)
+}
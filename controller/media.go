@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServeMediaFile 通过网关签名地址下载转存文件，无需登录
+func ServeMediaFile(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	expires, _ := strconv.ParseInt(c.Query("expires"), 10, 64)
+	retain, _ := strconv.ParseInt(c.Query("retain"), 10, 64)
+	if err := service.VerifyMediaSignature(id, expires, retain, c.Query("signature")); err != nil {
+		c.Status(http.StatusForbidden)
+		return
+	}
+	asset, err := model.GetMediaAssetById(id, 0)
+	if err != nil {
+		if errors.Is(err, model.ErrMediaAssetNotFound) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	// 转存完成前或转存失败时跳转回上游地址
+	if asset.Status != "" && asset.Status != model.MediaAssetStatusReady {
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, asset.OriginalUrl)
+		return
+	}
+	writeMediaAsset(c, asset)
+}
+
+// writeMediaAsset 将转存文件内容流式写入响应；缓存时长不超过文件剩余保留期。
+// 只按原类型下发图片、视频、音频，其余类型作为附件下载，并禁止浏览器嗅探类型
+func writeMediaAsset(c *gin.Context, asset *model.MediaAsset) {
+	reader, err := service.OpenMediaAsset(c.Request.Context(), asset)
+	if err != nil {
+		if errors.Is(err, service.ErrMediaObjectNotFound) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		logger.LogError(c.Request.Context(), fmt.Sprintf("open media asset #%d failed: %v", asset.Id, err))
+		c.Status(http.StatusBadGateway)
+		return
+	}
+	defer reader.Close()
+
+	maxAge := int64(86400)
+	if asset.ExpiresAt > 0 {
+		maxAge = min(maxAge, max(asset.ExpiresAt-common.GetTimestamp(), 0))
+	}
+	contentType, disposition := asset.ContentType, "inline"
+	if !service.IsServableMediaType(contentType) {
+		contentType, disposition = "application/octet-stream", "attachment"
+	}
+	c.Writer.Header().Set("Content-Type", contentType)
+	c.Writer.Header().Set("X-Content-Type-Options", "nosniff")
+	c.Writer.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, path.Base(asset.StorageKey)))
+	c.Writer.Header().Set("Content-Length", strconv.FormatInt(asset.Size, 10))
+	c.Writer.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", maxAge))
+	c.Writer.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(c.Writer, reader); err != nil {
+		logger.LogError(c.Request.Context(), fmt.Sprintf("stream media asset #%d failed: %v", asset.Id, err))
+	}
+}
+
+type mediaAssetItem struct {
+	*model.MediaAsset
+	Url string `json:"url"`
+}
+
+func listMediaAssets(c *gin.Context, userId int) {
+	pageInfo := common.GetPageQuery(c)
+	assets, total, err := model.GetMediaAssets(userId, c.Query("kind"), c.Query("source_id"),
+		pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	items := make([]mediaAssetItem, 0, len(assets))
+	for _, asset := range assets {
+		items = append(items, mediaAssetItem{MediaAsset: asset, Url: service.SignedMediaURL(asset)})
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(items)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetUserMediaAssets 当前用户的转存文件
+func GetUserMediaAssets(c *gin.Context) {
+	listMediaAssets(c, c.GetInt("id"))
+}
+
+// GetAllMediaAssets 所有用户的转存文件，可按 user_id 过滤
+func GetAllMediaAssets(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	listMediaAssets(c, userId)
+}
+
+func deleteMediaAsset(c *gin.Context, userId int) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	asset, err := model.GetMediaAssetById(id, userId)
+	if err != nil {
+		if errors.Is(err, model.ErrMediaAssetNotFound) {
+			common.ApiErrorI18n(c, i18n.MsgMediaAssetNotFound)
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	if err := service.DeleteMediaAssetFile(c.Request.Context(), asset); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// DeleteUserMediaAsset 删除当前用户的一个转存文件，释放存储空间
+func DeleteUserMediaAsset(c *gin.Context) {
+	deleteMediaAsset(c, c.GetInt("id"))
+}
+
+// DeleteMediaAsset 管理员删除任意用户的转存文件
+func DeleteMediaAsset(c *gin.Context) {
+	deleteMediaAsset(c, 0)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
@@ -90,7 +91,11 @@ func runMidjourneyTaskUpdateOnce(ctx context.Context, report func(processed, tot
 		midjourneyChannel, err := model.CacheGetChannel(channelId)
 		if err != nil {
 			logger.LogError(ctx, fmt.Sprintf("CacheGetChannel: %v", err))
-			err := model.MjBulkUpdate(taskIds, map[string]any{
+			orphanedTaskIds := recoverMidjourneyChannelTasks(ctx, channelId, taskIds, taskM)
+			if len(orphanedTaskIds) == 0 {
+				continue
+			}
+			err := model.MjBulkUpdate(orphanedTaskIds, map[string]any{
 				"fail_reason": fmt.Sprintf("获取渠道信息失败，请联系管理员，渠道ID：%d", channelId),
 				"status":      "FAILURE",
 				"progress":    "100%",
@@ -100,127 +105,176 @@ func runMidjourneyTaskUpdateOnce(ctx context.Context, report func(processed, tot
 			}
 			continue
 		}
-		requestUrl := fmt.Sprintf("%s/mj/task/list-by-condition", *midjourneyChannel.BaseURL)
+		pollMidjourneyChannelTasks(ctx, midjourneyChannel, taskIds, taskM)
+	}
+	if report != nil && (ctx == nil || ctx.Err() == nil) {
+		report(totalChannels, totalChannels)
+	}
+	return summary
+}
 
-		body, err := common.Marshal(map[string]any{
-			"ids": taskIds,
-		})
-		if err != nil {
-			logger.LogError(ctx, fmt.Sprintf("Get Task marshal body error: %v", err))
+// recoverMidjourneyChannelTasks 在原渠道已被删除时，按任务记录的上游实例标识改由其它渠道轮询，返回无法找回渠道的任务
+func recoverMidjourneyChannelTasks(ctx context.Context, channelId int, taskIds []string, taskM map[string]*model.Midjourney) []string {
+	identityTaskIds := make(map[string][]string)
+	orphanedTaskIds := make([]string, 0)
+	for _, mjId := range taskIds {
+		task := taskM[mjId]
+		if task == nil || task.ChannelIdentity == "" {
+			orphanedTaskIds = append(orphanedTaskIds, mjId)
 			continue
 		}
-		timeout := time.Second * 15
-		requestCtx, cancel := context.WithTimeout(ctx, timeout)
-		req, err := http.NewRequestWithContext(requestCtx, "POST", requestUrl, bytes.NewBuffer(body))
-		if err != nil {
-			cancel()
-			logger.LogError(ctx, fmt.Sprintf("Get Task error: %v", err))
+		identityTaskIds[task.ChannelIdentity] = append(identityTaskIds[task.ChannelIdentity], mjId)
+	}
+	for identity, mjIds := range identityTaskIds {
+		channel := service.FindMidjourneyChannelByIdentity(identity, channelId)
+		if channel == nil {
+			orphanedTaskIds = append(orphanedTaskIds, mjIds...)
 			continue
 		}
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("mj-api-secret", midjourneyChannel.Key)
-		resp, err := service.GetHttpClient().Do(req)
-		if err != nil {
-			logger.LogError(ctx, fmt.Sprintf("Get Task Do req error: %v", err))
-			cancel()
-			continue
+		ids := make([]int, 0, len(mjIds))
+		for _, mjId := range mjIds {
+			ids = append(ids, taskM[mjId].Id)
 		}
-		if resp.StatusCode != http.StatusOK {
-			logger.LogError(ctx, fmt.Sprintf("Get Task status code: %d", resp.StatusCode))
-			resp.Body.Close()
-			cancel()
+		if err := model.RebindMidjourneyTasks(ids, channel.Id, identity); err != nil {
+			logger.LogError(ctx, fmt.Sprintf("rebind midjourney tasks to channel #%d failed: %v", channel.Id, err))
+			orphanedTaskIds = append(orphanedTaskIds, mjIds...)
 			continue
 		}
-		responseBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			logger.LogError(ctx, fmt.Sprintf("Get Mjp Task parse body error: %v", err))
-			resp.Body.Close()
-			cancel()
-			continue
+		for _, mjId := range mjIds {
+			taskM[mjId].ChannelId = channel.Id
 		}
-		var responseItems []dto.MidjourneyDto
-		err = common.Unmarshal(responseBody, &responseItems)
-		if err != nil {
-			logger.LogError(ctx, fmt.Sprintf("Get Mjp Task parse body error2: %v, body: %s", err, string(responseBody)))
-			resp.Body.Close()
-			cancel()
+		logger.LogInfo(ctx, fmt.Sprintf("渠道 #%d 已不存在，%d 个任务迁移到渠道 #%d", channelId, len(mjIds), channel.Id))
+		pollMidjourneyChannelTasks(ctx, channel, mjIds, taskM)
+	}
+	return orphanedTaskIds
+}
+
+// pollMidjourneyChannelTasks 从渠道拉取未完成任务的最新状态，超过一小时仍未完成的任务标记为失败
+func pollMidjourneyChannelTasks(ctx context.Context, channel *model.Channel, taskIds []string, taskM map[string]*model.Midjourney) {
+	responseItems, err := fetchMidjourneyTasksFromChannel(ctx, channel, taskIds)
+	if err != nil {
+		logger.LogError(ctx, err.Error())
+		return
+	}
+	for _, responseItem := range responseItems {
+		task := taskM[responseItem.MjId]
+		if task == nil {
+			logger.LogWarn(ctx, fmt.Sprintf("Midjourney task response ignored: unknown mj_id=%s", responseItem.MjId))
 			continue
 		}
-		resp.Body.Close()
-		req.Body.Close()
-		cancel()
-
-		for _, responseItem := range responseItems {
-			task := taskM[responseItem.MjId]
-			if task == nil {
-				logger.LogWarn(ctx, fmt.Sprintf("Midjourney task response ignored: unknown mj_id=%s", responseItem.MjId))
-				continue
-			}
 
-			useTime := (time.Now().UnixNano() / int64(time.Millisecond)) - task.SubmitTime
-			// 如果时间超过一小时，且进度不是100%，则认为任务失败
-			if useTime > 3600000 && task.Progress != "100%" {
-				responseItem.FailReason = "上游任务超时（超过1小时）"
-				responseItem.Status = "FAILURE"
-			}
-			if !checkMjTaskNeedUpdate(task, responseItem) {
-				continue
-			}
-			preStatus := task.Status
-			task.Code = 1
-			task.Progress = responseItem.Progress
-			task.PromptEn = responseItem.PromptEn
-			task.State = responseItem.State
-			task.SubmitTime = responseItem.SubmitTime
-			task.StartTime = responseItem.StartTime
-			task.FinishTime = responseItem.FinishTime
-			task.ImageUrl = responseItem.ImageUrl
-			task.Status = responseItem.Status
-			task.FailReason = responseItem.FailReason
-			if responseItem.Properties != nil {
-				propertiesStr, _ := common.Marshal(responseItem.Properties)
-				task.Properties = string(propertiesStr)
-			}
-			if responseItem.Buttons != nil {
-				buttonStr, _ := common.Marshal(responseItem.Buttons)
-				task.Buttons = string(buttonStr)
-			}
-			// 映射 VideoUrl
-			task.VideoUrl = responseItem.VideoUrl
-
-			// 映射 VideoUrls - 将数组序列化为 JSON 字符串
-			if responseItem.VideoUrls != nil && len(responseItem.VideoUrls) > 0 {
-				videoUrlsStr, err := common.Marshal(responseItem.VideoUrls)
-				if err != nil {
-					logger.LogError(ctx, fmt.Sprintf("序列化 VideoUrls 失败: %v", err))
-					task.VideoUrls = "[]" // 失败时设置为空数组
-				} else {
-					task.VideoUrls = string(videoUrlsStr)
-				}
-			} else {
-				task.VideoUrls = "" // 空值时清空字段
-			}
+		useTime := (time.Now().UnixNano() / int64(time.Millisecond)) - task.SubmitTime
+		// 如果时间超过一小时，且进度不是100%，则认为任务失败
+		if useTime > 3600000 && task.Progress != "100%" {
+			responseItem.FailReason = "上游任务超时（超过1小时）"
+			responseItem.Status = "FAILURE"
+		}
+		applyMidjourneyTaskResponse(ctx, task, responseItem)
+	}
+}
 
-			shouldReturnQuota := false
-			if (task.Progress != "100%" && responseItem.FailReason != "") || (task.Progress == "100%" && task.Status == "FAILURE") {
-				logger.LogInfo(ctx, task.MjId+" 构建失败，"+task.FailReason)
-				task.Progress = "100%"
-				if task.Quota != 0 {
-					shouldReturnQuota = true
-				}
-			}
-			won, err := task.UpdateWithStatus(preStatus)
-			if err != nil {
-				logger.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
-			} else if won && shouldReturnQuota {
-				service.RefundMidjourneyQuota(ctx, task, "构图失败")
-			}
+// fetchMidjourneyTasksFromChannel 通过上游 list-by-condition 接口批量查询任务
+func fetchMidjourneyTasksFromChannel(ctx context.Context, channel *model.Channel, taskIds []string) ([]dto.MidjourneyDto, error) {
+	requestUrl := fmt.Sprintf("%s/mj/task/list-by-condition", channel.GetBaseURL())
+
+	body, err := common.Marshal(map[string]any{
+		"ids": taskIds,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Get Task marshal body error: %v", err)
+	}
+	timeout := time.Second * 15
+	requestCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(requestCtx, "POST", requestUrl, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("Get Task error: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("mj-api-secret", channel.Key)
+	resp, err := service.GetHttpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Get Task Do req error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Get Task status code: %d", resp.StatusCode)
+	}
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Get Mjp Task parse body error: %v", err)
+	}
+	var responseItems []dto.MidjourneyDto
+	err = common.Unmarshal(responseBody, &responseItems)
+	if err != nil {
+		return nil, fmt.Errorf("Get Mjp Task parse body error2: %v, body: %s", err, string(responseBody))
+	}
+	return responseItems, nil
+}
+
+// applyMidjourneyTaskResponse 将上游返回的任务状态写回数据库，失败时退还额度并触发回调，返回任务是否被更新
+func applyMidjourneyTaskResponse(ctx context.Context, task *model.Midjourney, responseItem dto.MidjourneyDto) bool {
+	if !checkMjTaskNeedUpdate(task, responseItem) {
+		return false
+	}
+	preStatus := task.Status
+	task.Code = 1
+	task.Progress = responseItem.Progress
+	task.PromptEn = responseItem.PromptEn
+	task.State = responseItem.State
+	task.SubmitTime = responseItem.SubmitTime
+	task.StartTime = responseItem.StartTime
+	task.FinishTime = responseItem.FinishTime
+	task.ImageUrl = responseItem.ImageUrl
+	task.Status = responseItem.Status
+	task.FailReason = responseItem.FailReason
+	if responseItem.Properties != nil {
+		propertiesStr, _ := common.Marshal(responseItem.Properties)
+		task.Properties = string(propertiesStr)
+	}
+	if responseItem.Buttons != nil {
+		buttonStr, _ := common.Marshal(responseItem.Buttons)
+		task.Buttons = string(buttonStr)
+	}
+	// 映射 VideoUrl
+	task.VideoUrl = responseItem.VideoUrl
+
+	// 映射 VideoUrls - 将数组序列化为 JSON 字符串
+	if responseItem.VideoUrls != nil && len(responseItem.VideoUrls) > 0 {
+		videoUrlsStr, err := common.Marshal(responseItem.VideoUrls)
+		if err != nil {
+			logger.LogError(ctx, fmt.Sprintf("序列化 VideoUrls 失败: %v", err))
+			task.VideoUrls = "[]" // 失败时设置为空数组
+		} else {
+			task.VideoUrls = string(videoUrlsStr)
 		}
+	} else {
+		task.VideoUrls = "" // 空值时清空字段
 	}
-	if report != nil && (ctx == nil || ctx.Err() == nil) {
-		report(totalChannels, totalChannels)
+
+	shouldReturnQuota := false
+	if (task.Progress != "100%" && responseItem.FailReason != "") || (task.Progress == "100%" && task.Status == "FAILURE") {
+		logger.LogInfo(ctx, task.MjId+" 构建失败，"+task.FailReason)
+		task.Progress = "100%"
+		// 已失败的任务额度已退还过，重新拉取时不再重复退还
+		if task.Quota != 0 && preStatus != "FAILURE" {
+			shouldReturnQuota = true
+		}
 	}
-	return summary
+	won, err := task.UpdateWithStatus(preStatus)
+	if err != nil {
+		logger.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
+		return false
+	}
+	if won {
+		if shouldReturnQuota {
+			service.RefundMidjourneyQuota(ctx, task, "构图失败")
+		}
+		if task.Status != preStatus {
+			service.EnqueueMidjourneyWebhook(ctx, task)
+		}
+	}
+	return won
 }
 
 func checkMjTaskNeedUpdate(oldTask *model.Midjourney, newTask dto.MidjourneyDto) bool {
@@ -285,6 +339,9 @@ func GetAllMidjourney(c *gin.Context) {
 	queryParams := model.TaskQueryParams{
 		ChannelID:      c.Query("channel_id"),
 		MjID:           c.Query("mj_id"),
+		Action:         c.Query("action"),
+		Status:         c.Query("status"),
+		Prompt:         c.Query("prompt"),
 		StartTimestamp: c.Query("start_timestamp"),
 		EndTimestamp:   c.Query("end_timestamp"),
 	}
@@ -310,6 +367,9 @@ func GetUserMidjourney(c *gin.Context) {
 
 	queryParams := model.TaskQueryParams{
 		MjID:           c.Query("mj_id"),
+		Action:         c.Query("action"),
+		Status:         c.Query("status"),
+		Prompt:         c.Query("prompt"),
 		StartTimestamp: c.Query("start_timestamp"),
 		EndTimestamp:   c.Query("end_timestamp"),
 	}
@@ -327,3 +387,168 @@ func GetUserMidjourney(c *gin.Context) {
 	pageInfo.SetItems(items)
 	common.ApiSuccess(c, pageInfo)
 }
+
+const (
+	midjourneyRefetchDefaultLimit = 1000
+	midjourneyRefetchMaxLimit     = 10000
+	midjourneyRefetchBatchSize    = 100
+)
+
+// midjourneyRefetchPayload 描述一次任务重新拉取的范围，未指定 mj_ids 时按条件筛选
+type midjourneyRefetchPayload struct {
+	MjIds          []string `json:"mj_ids,omitempty"`
+	ChannelId      int      `json:"channel_id,omitempty"`
+	Status         string   `json:"status,omitempty"`
+	StartTimestamp int64    `json:"start_timestamp,omitempty"`
+	EndTimestamp   int64    `json:"end_timestamp,omitempty"`
+	Limit          int      `json:"limit,omitempty"`
+}
+
+// midjourneyRefetchSummary 是 midjourney_refetch 系统任务的执行结果
+type midjourneyRefetchSummary struct {
+	Tasks           int `json:"tasks"`
+	ChannelsScanned int `json:"channels_scanned"`
+	Rebound         int `json:"rebound"`
+	Updated         int `json:"updated"`
+	NotFound        int `json:"not_found"`
+	Unresolved      int `json:"unresolved"`
+	Refunded        int `json:"refunded"`
+}
+
+// RefetchMidjourneyTasks 提交任务重新拉取作业，从上游同步任务的最新状态以修复状态停滞的任务
+func RefetchMidjourneyTasks(c *gin.Context) {
+	var req midjourneyRefetchPayload
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if len(req.MjIds) == 0 && req.ChannelId == 0 && req.Status == "" && req.StartTimestamp == 0 && req.EndTimestamp == 0 {
+		common.ApiErrorMsg(c, "请至少指定一个筛选条件")
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = midjourneyRefetchDefaultLimit
+	} else if req.Limit > midjourneyRefetchMaxLimit {
+		req.Limit = midjourneyRefetchMaxLimit
+	}
+	task, created, err := service.EnqueueSystemTask(model.SystemTaskTypeMidjourneyRefetch, req)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"task":    task.ToResponse(),
+		"created": created,
+	})
+}
+
+// runMidjourneyRefetchTask 按条件重新拉取任务状态。原渠道已删除或地址变更的任务会迁移到指向同一上游实例的渠道；
+// 与轮询不同，这里不做一小时超时判定，也不会对已退款后恢复成功的任务重新扣费
+func runMidjourneyRefetchTask(ctx context.Context, payload midjourneyRefetchPayload, report func(processed, total int)) midjourneyRefetchSummary {
+	summary := midjourneyRefetchSummary{}
+	queryParams := model.TaskQueryParams{
+		MjIDs:  payload.MjIds,
+		Status: payload.Status,
+	}
+	if payload.ChannelId > 0 {
+		queryParams.ChannelID = strconv.Itoa(payload.ChannelId)
+	}
+	if payload.StartTimestamp > 0 {
+		queryParams.StartTimestamp = strconv.FormatInt(payload.StartTimestamp, 10)
+	}
+	if payload.EndTimestamp > 0 {
+		queryParams.EndTimestamp = strconv.FormatInt(payload.EndTimestamp, 10)
+	}
+	limit := payload.Limit
+	if limit <= 0 {
+		limit = midjourneyRefetchDefaultLimit
+	}
+	tasks := model.GetAllTasks(0, limit, queryParams)
+	summary.Tasks = len(tasks)
+
+	type channelKey struct {
+		channelId int
+		identity  string
+	}
+	resolved := make(map[channelKey]*model.Channel)
+	channelTasks := make(map[int][]*model.Midjourney)
+	channels := make(map[int]*model.Channel)
+	for _, task := range tasks {
+		if task.MjId == "" {
+			continue
+		}
+		key := channelKey{channelId: task.ChannelId, identity: task.ChannelIdentity}
+		channel, ok := resolved[key]
+		if !ok {
+			channel = service.FindMidjourneyTaskChannel(task, false)
+			resolved[key] = channel
+		}
+		if channel == nil {
+			summary.Unresolved++
+			continue
+		}
+		identity := model.MidjourneyChannelIdentity(channel.GetBaseURL())
+		if task.ChannelId != channel.Id || task.ChannelIdentity != identity {
+			if err := model.RebindMidjourneyTasks([]int{task.Id}, channel.Id, identity); err != nil {
+				logger.LogError(ctx, fmt.Sprintf("rebind midjourney task %s failed: %v", task.MjId, err))
+				summary.Unresolved++
+				continue
+			}
+			task.ChannelId = channel.Id
+			task.ChannelIdentity = identity
+			summary.Rebound++
+		}
+		channels[channel.Id] = channel
+		channelTasks[channel.Id] = append(channelTasks[channel.Id], task)
+	}
+
+	totalChannels := len(channelTasks)
+	processedChannels := 0
+	for channelId, group := range channelTasks {
+		if ctx.Err() != nil {
+			break
+		}
+		if report != nil {
+			report(processedChannels, totalChannels)
+		}
+		processedChannels++
+		summary.ChannelsScanned++
+		for start := 0; start < len(group); start += midjourneyRefetchBatchSize {
+			batch := group[start:min(start+midjourneyRefetchBatchSize, len(group))]
+			taskM := make(map[string]*model.Midjourney, len(batch))
+			taskIds := make([]string, 0, len(batch))
+			for _, task := range batch {
+				taskM[task.MjId] = task
+				taskIds = append(taskIds, task.MjId)
+			}
+			responseItems, err := fetchMidjourneyTasksFromChannel(ctx, channels[channelId], taskIds)
+			if err != nil {
+				logger.LogError(ctx, err.Error())
+				summary.NotFound += len(batch)
+				continue
+			}
+			found := make(map[string]bool, len(responseItems))
+			for _, responseItem := range responseItems {
+				task := taskM[responseItem.MjId]
+				if task == nil {
+					continue
+				}
+				found[responseItem.MjId] = true
+				// 失败时已退还额度的任务不再改为其它状态，否则用户无需付费即可拿到结果
+				if task.Status == "FAILURE" && task.Quota != 0 && responseItem.Status != "FAILURE" {
+					logger.LogInfo(ctx, fmt.Sprintf("midjourney task %s was refunded as failed, upstream status %s ignored", task.MjId, responseItem.Status))
+					summary.Refunded++
+					continue
+				}
+				if applyMidjourneyTaskResponse(ctx, task, responseItem) {
+					summary.Updated++
+				}
+			}
+			summary.NotFound += len(batch) - len(found)
+		}
+	}
+	if report != nil && ctx.Err() == nil {
+		report(totalChannels, totalChannels)
+	}
+	return summary
+}
@@ -297,6 +297,19 @@ func ListModels(c *gin.Context, modelType int) {
 			"models":        userGeminiModels,
 			"nextPageToken": nil,
 		})
+	case constant.ChannelTypeOllama:
+		// Ollama /api/tags 格式，尺寸与摘要等本地信息不适用，保持为空
+		userOllamaModels := make([]dto.OllamaModel, len(userOpenAiModels))
+		for i, model := range userOpenAiModels {
+			userOllamaModels[i] = dto.OllamaModel{
+				Name:       model.Id,
+				Model:      model.Id,
+				ModifiedAt: time.Unix(int64(model.Created), 0).UTC().Format(time.RFC3339),
+			}
+		}
+		c.JSON(200, dto.OllamaTagsResponse{
+			Models: userOllamaModels,
+		})
 	default:
 		c.JSON(200, gin.H{
 			"success": true,
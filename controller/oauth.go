@@ -13,6 +13,7 @@ import (
 	"github.com/QuantumNous/new-api/middleware"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/oauth"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -218,10 +219,41 @@ func HandleOAuth(c *gin.Context) {
 		return
 	}
 
-	// 9. Setup login
+	// 9. Apply the group asserted by the identity provider (SAML group mapping)
+	if group, ok := oauthUser.Extra["group"].(string); ok && group != "" {
+		if err := syncOAuthUserGroup(user.Id, group); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+
+	// 10. Setup login
 	setupLogin(user, c)
 }
 
+// syncOAuthUserGroup moves a user into the group mapped from the identity
+// provider. Unknown groups are ignored, as are administrators and root users
+// whose group is managed locally; group changes bump AuthVersion like an
+// administrator edit does.
+func syncOAuthUserGroup(userId int, group string) error {
+	if !ratio_setting.ContainsGroupRatio(group) {
+		common.SysLog(fmt.Sprintf("[OAuth] ignoring mapped group %q for user %d: group does not exist", group, userId))
+		return nil
+	}
+	user, err := model.GetUserById(userId, true)
+	if err != nil {
+		return err
+	}
+	if user.Role >= common.RoleAdminUser {
+		return nil
+	}
+	if user.Group == group {
+		return nil
+	}
+	user.Group = group
+	return user.Update(false)
+}
+
 // handleOAuthBind handles binding OAuth account to existing user
 func handleOAuthBind(c *gin.Context, provider oauth.Provider, pendingFlow *model.AuthFlow, flowToken string) {
 	// Exchange code for token
@@ -266,9 +298,9 @@ func handleOAuthBind(c *gin.Context, provider oauth.Provider, pendingFlow *model
 	userId := pendingFlow.UserId
 
 	// Handle binding based on provider type
-	if genericProvider, ok := provider.(*oauth.GenericOAuthProvider); ok {
-		// Custom provider: use user_oauth_bindings table
-		err = model.UpdateUserOAuthBinding(userId, genericProvider.GetProviderId(), oauthUser.ProviderUserID)
+	if bindingProvider, ok := provider.(oauth.BindingTableProvider); ok {
+		// Custom provider or SAML: use user_oauth_bindings table
+		err = model.UpdateUserOAuthBinding(userId, bindingProvider.GetProviderId(), oauthUser.ProviderUserID)
 		if err != nil {
 			common.ApiError(c, err)
 			return
@@ -368,8 +400,8 @@ func findOrCreateOAuthUser(c *gin.Context, provider oauth.Provider, oauthUser *o
 	}
 
 	// Use transaction to ensure user creation and OAuth binding are atomic
-	if genericProvider, ok := provider.(*oauth.GenericOAuthProvider); ok {
-		// Custom provider: create user and binding in a transaction
+	if bindingProvider, ok := provider.(oauth.BindingTableProvider); ok {
+		// Custom provider or SAML: create user and binding in a transaction
 		err := model.DB.Transaction(func(tx *gorm.DB) error {
 			// Create user
 			if err := user.InsertWithTx(tx, inviterId); err != nil {
@@ -379,7 +411,7 @@ func findOrCreateOAuthUser(c *gin.Context, provider oauth.Provider, oauthUser *o
 			// Create OAuth binding
 			binding := &model.UserOAuthBinding{
 				UserId:         user.Id,
-				ProviderId:     genericProvider.GetProviderId(),
+				ProviderId:     bindingProvider.GetProviderId(),
 				ProviderUserId: oauthUser.ProviderUserID,
 			}
 			if err := model.CreateUserOAuthBindingWithTx(tx, binding); err != nil {
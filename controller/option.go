@@ -89,7 +89,11 @@ func GetOptions(c *gin.Context) {
 			strings.HasSuffix(k, "Secret") ||
 			strings.HasSuffix(k, "Key") ||
 			strings.HasSuffix(k, "secret") ||
-			strings.HasSuffix(k, "api_key")
+			strings.HasSuffix(k, "_token") ||
+			strings.HasSuffix(k, "private_key") ||
+			strings.HasSuffix(k, "api_key") ||
+			strings.HasSuffix(k, "access_key") ||
+			strings.HasSuffix(k, "secret_key")
 		if isSensitiveKey {
 			continue
 		}
@@ -362,12 +366,25 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case model.PriceBookVersionOptionKey:
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "价格版本由价格表维护，不能直接修改",
+		})
+		return
 	}
 	err = model.UpdateOption(option.Key, option.Value.(string))
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	// Direct price edits still take effect immediately; record them as a
+	// price book version so the previous prices stay in the history.
+	if model.IsPriceBookOptionKey(option.Key) {
+		if _, err := model.RecordPriceBookSnapshot("option:"+option.Key, c.GetInt("id")); err != nil {
+			common.SysError("failed to record price book snapshot: " + err.Error())
+		}
+	}
 	// 出于安全考虑只记录被修改的配置项名称，不记录配置值（可能含密钥等敏感信息）。
 	recordManageAudit(c, "option.update", map[string]interface{}{
 		"key": option.Key,
@@ -0,0 +1,475 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type organizationRequest struct {
+	Name   string `json:"name"`
+	Status int    `json:"status"`
+}
+
+type organizationMemberRequest struct {
+	UserId     int    `json:"user_id"`
+	Username   string `json:"username"`
+	Role       string `json:"role"`
+	SpendLimit int    `json:"spend_limit"`
+	ResetUsed  bool   `json:"reset_used"`
+}
+
+type organizationQuotaRequest struct {
+	Quota int `json:"quota"`
+}
+
+// organizationRoleRank 用于比较组织内角色高低：owner > admin > member。
+func organizationRoleRank(role string) int {
+	switch role {
+	case model.OrganizationRoleOwner:
+		return 3
+	case model.OrganizationRoleAdmin:
+		return 2
+	case model.OrganizationRoleMember:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// loadOrganizationMembership 解析路由中的组织 ID 并校验当前用户至少拥有 minRole 角色。
+func loadOrganizationMembership(c *gin.Context, minRole string) (*model.Organization, *model.OrganizationMember, bool) {
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return nil, nil, false
+	}
+	org, err := model.GetOrganizationById(orgId)
+	if err != nil {
+		if errors.Is(err, model.ErrOrganizationNotFound) {
+			common.ApiErrorI18n(c, i18n.MsgOrganizationNotFound)
+			return nil, nil, false
+		}
+		common.ApiError(c, err)
+		return nil, nil, false
+	}
+	member, err := model.GetOrganizationMember(orgId, c.GetInt("id"))
+	if err != nil {
+		if errors.Is(err, model.ErrOrganizationMemberNotFound) {
+			common.ApiErrorI18n(c, i18n.MsgOrganizationNotMember)
+			return nil, nil, false
+		}
+		common.ApiError(c, err)
+		return nil, nil, false
+	}
+	if organizationRoleRank(member.Role) < organizationRoleRank(minRole) {
+		common.ApiErrorI18n(c, i18n.MsgOrganizationPermissionDenied)
+		return nil, nil, false
+	}
+	return org, member, true
+}
+
+func respondOrganizationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrOrganizationNotFound):
+		common.ApiErrorI18n(c, i18n.MsgOrganizationNotFound)
+	case errors.Is(err, model.ErrOrganizationMemberNotFound):
+		common.ApiErrorI18n(c, i18n.MsgOrganizationMemberNotFound)
+	case errors.Is(err, model.ErrOrganizationMemberExists):
+		common.ApiErrorI18n(c, i18n.MsgOrganizationMemberExists)
+	case errors.Is(err, model.ErrOrganizationOwnerImmutable):
+		common.ApiErrorI18n(c, i18n.MsgOrganizationOwnerImmutable)
+	case errors.Is(err, model.ErrOrganizationBalanceNotEmpty):
+		common.ApiErrorI18n(c, i18n.MsgOrganizationBalanceNotEmpty)
+	case errors.Is(err, model.ErrOrganizationInviteNotFound):
+		common.ApiErrorI18n(c, i18n.MsgOrganizationInviteNotFound)
+	default:
+		common.ApiError(c, err)
+	}
+}
+
+// ---- User APIs ----
+
+func GetSelfOrganizations(c *gin.Context) {
+	orgs, err := model.GetUserOrganizations(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, orgs)
+}
+
+func CreateOrganization(c *gin.Context) {
+	var req organizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if req.Name == "" {
+		common.ApiErrorI18n(c, i18n.MsgOrganizationNameEmpty)
+		return
+	}
+	org, err := model.CreateOrganization(req.Name, c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, org)
+}
+
+func GetOrganization(c *gin.Context) {
+	org, member, ok := loadOrganizationMembership(c, model.OrganizationRoleMember)
+	if !ok {
+		return
+	}
+	common.ApiSuccess(c, model.OrganizationWithRole{
+		Organization:    *org,
+		Role:            member.Role,
+		SpendLimit:      member.SpendLimit,
+		MemberUsedQuota: member.UsedQuota,
+	})
+}
+
+func UpdateOrganization(c *gin.Context) {
+	org, _, ok := loadOrganizationMembership(c, model.OrganizationRoleOwner)
+	if !ok {
+		return
+	}
+	var req organizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	// 所有者只能修改名称，启用/禁用由管理员控制
+	if err := model.UpdateOrganization(org.Id, req.Name, 0); err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func DeleteOrganization(c *gin.Context) {
+	org, _, ok := loadOrganizationMembership(c, model.OrganizationRoleOwner)
+	if !ok {
+		return
+	}
+	if err := model.DeleteOrganization(org.Id); err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func GetOrganizationMembers(c *gin.Context) {
+	org, _, ok := loadOrganizationMembership(c, model.OrganizationRoleAdmin)
+	if !ok {
+		return
+	}
+	members, err := model.GetOrganizationMembers(org.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, members)
+}
+
+// InviteOrganizationMember 邀请用户加入组织，用户接受后才成为成员。
+// 用户不存在时同样返回成功，避免通过该接口枚举用户。
+func InviteOrganizationMember(c *gin.Context) {
+	org, operator, ok := loadOrganizationMembership(c, model.OrganizationRoleAdmin)
+	if !ok {
+		return
+	}
+	var req organizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.SpendLimit < 0 {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if req.Role == "" {
+		req.Role = model.OrganizationRoleMember
+	}
+	if req.Role == model.OrganizationRoleOwner || !model.IsValidOrganizationRole(req.Role) {
+		common.ApiErrorI18n(c, i18n.MsgOrganizationInvalidRole)
+		return
+	}
+	// 只有所有者可以任命管理员
+	if req.Role == model.OrganizationRoleAdmin && operator.Role != model.OrganizationRoleOwner {
+		common.ApiErrorI18n(c, i18n.MsgOrganizationPermissionDenied)
+		return
+	}
+	userId := req.UserId
+	if userId == 0 && req.Username != "" {
+		if id, err := model.GetUserIdByUsername(req.Username); err == nil {
+			userId = id
+		}
+	}
+	if userId > 0 {
+		if _, err := model.GetUserById(userId, false); err != nil {
+			userId = 0
+		}
+	}
+	if userId > 0 {
+		if err := model.InviteOrganizationMember(org.Id, operator.UserId, userId, req.Role, req.SpendLimit); err != nil {
+			respondOrganizationError(c, err)
+			return
+		}
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// GetSelfOrganizationInvites 返回当前用户待处理的组织邀请。
+func GetSelfOrganizationInvites(c *gin.Context) {
+	invites, err := model.GetUserOrganizationInvites(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, invites)
+}
+
+func AcceptOrganizationInvite(c *gin.Context) {
+	inviteId, err := strconv.Atoi(c.Param("invite_id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	member, err := model.AcceptOrganizationInvite(inviteId, c.GetInt("id"))
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+	common.ApiSuccess(c, member)
+}
+
+func DeclineOrganizationInvite(c *gin.Context) {
+	inviteId, err := strconv.Atoi(c.Param("invite_id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := model.DeclineOrganizationInvite(inviteId, c.GetInt("id")); err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func UpdateOrganizationMember(c *gin.Context) {
+	org, operator, ok := loadOrganizationMembership(c, model.OrganizationRoleAdmin)
+	if !ok {
+		return
+	}
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	var req organizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.SpendLimit < 0 {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if !model.IsValidOrganizationRole(req.Role) {
+		common.ApiErrorI18n(c, i18n.MsgOrganizationInvalidRole)
+		return
+	}
+	target, err := model.GetOrganizationMember(org.Id, userId)
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+	if target.Role != model.OrganizationRoleOwner && req.Role == model.OrganizationRoleOwner {
+		common.ApiErrorI18n(c, i18n.MsgOrganizationInvalidRole)
+		return
+	}
+	// 管理员只能管理普通成员，管理员角色的变更仅限所有者
+	if operator.Role != model.OrganizationRoleOwner &&
+		(target.Role != model.OrganizationRoleMember || req.Role != model.OrganizationRoleMember) {
+		common.ApiErrorI18n(c, i18n.MsgOrganizationPermissionDenied)
+		return
+	}
+	if err := model.UpdateOrganizationMember(org.Id, userId, req.Role, req.SpendLimit, req.ResetUsed); err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func RemoveOrganizationMember(c *gin.Context) {
+	org, operator, ok := loadOrganizationMembership(c, model.OrganizationRoleMember)
+	if !ok {
+		return
+	}
+	userId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	// 成员可以主动退出；移除他人需要比对方更高的角色
+	if userId != operator.UserId {
+		target, err := model.GetOrganizationMember(org.Id, userId)
+		if err != nil {
+			respondOrganizationError(c, err)
+			return
+		}
+		if organizationRoleRank(operator.Role) < organizationRoleRank(model.OrganizationRoleAdmin) ||
+			organizationRoleRank(operator.Role) <= organizationRoleRank(target.Role) {
+			common.ApiErrorI18n(c, i18n.MsgOrganizationPermissionDenied)
+			return
+		}
+	}
+	if err := model.RemoveOrganizationMember(org.Id, userId); err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// FundOrganization 从当前用户的个人钱包向组织共享钱包转入额度，任何成员均可充值。
+func FundOrganization(c *gin.Context) {
+	org, _, ok := loadOrganizationMembership(c, model.OrganizationRoleMember)
+	if !ok {
+		return
+	}
+	var req organizationQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if req.Quota <= 0 {
+		common.ApiErrorI18n(c, i18n.MsgOrganizationQuotaInvalid)
+		return
+	}
+	userId := c.GetInt("id")
+	if err := model.TransferUserQuotaToOrganization(userId, org.Id, req.Quota); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgOrganizationFundFailed, map[string]any{"Error": err.Error()})
+		return
+	}
+	model.RecordLog(userId, model.LogTypeManage, fmt.Sprintf("向组织 %s 转入额度 %s", org.Name, logger.LogQuota(req.Quota)))
+	common.ApiSuccess(c, nil)
+}
+
+// GetOrganizationLogs 返回组织钱包的消费日志；普通成员只能看到自己的记录。
+func GetOrganizationLogs(c *gin.Context) {
+	org, member, ok := loadOrganizationMembership(c, model.OrganizationRoleMember)
+	if !ok {
+		return
+	}
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	if member.Role == model.OrganizationRoleMember {
+		userId = member.UserId
+	}
+	pageInfo := common.GetPageQuery(c)
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	logs, total, err := model.GetOrganizationLogs(org.Id, userId, startTimestamp, endTimestamp, c.Query("model_name"), c.Query("token_name"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(logs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// ---- Admin APIs ----
+
+func AdminListOrganizations(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	orgs, total, err := model.GetAllOrganizations(c.Query("keyword"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(orgs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func AdminGetOrganizationMembers(c *gin.Context) {
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	members, err := model.GetOrganizationMembers(orgId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, members)
+}
+
+func AdminUpdateOrganization(c *gin.Context) {
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	var req organizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := model.UpdateOrganization(orgId, req.Name, req.Status); err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+	recordManageAudit(c, "organization.update", map[string]interface{}{
+		"id":     orgId,
+		"name":   req.Name,
+		"status": req.Status,
+	})
+	common.ApiSuccess(c, nil)
+}
+
+// AdminAdjustOrganizationQuota 增加或扣减组织钱包额度，quota 为负数时表示扣减。
+func AdminAdjustOrganizationQuota(c *gin.Context) {
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	var req organizationQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Quota == 0 {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if req.Quota > 0 {
+		err = model.IncreaseOrganizationQuota(orgId, req.Quota)
+	} else {
+		err = model.DecreaseOrganizationQuota(orgId, -req.Quota)
+	}
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+	recordManageAudit(c, "organization.quota_adjust", map[string]interface{}{
+		"id":    orgId,
+		"quota": logger.LogQuota(req.Quota),
+	})
+	common.ApiSuccess(c, nil)
+}
+
+func AdminDeleteOrganization(c *gin.Context) {
+	orgId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := model.DeleteOrganization(orgId); err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+	recordManageAudit(c, "organization.delete", map[string]interface{}{
+		"id": orgId,
+	})
+	common.ApiSuccess(c, nil)
+}
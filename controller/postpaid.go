@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSelfPostpaidAccount 返回当前用户的后付费账户状态；查询时立即核销已充值的还款。
+// 未开通后付费时 data 为 null。
+func GetSelfPostpaidAccount(c *gin.Context) {
+	account, err := service.RefreshPostpaidAccount(c.GetInt("id"))
+	if errors.Is(err, model.ErrPostpaidAccountNotFound) {
+		common.ApiSuccess(c, nil)
+		return
+	}
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, account)
+}
+
+func GetPostpaidAccounts(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	accounts, total, err := model.GetPostpaidAccounts(pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(accounts)
+	common.ApiSuccess(c, pageInfo)
+}
+
+type postpaidAccountRequest struct {
+	CreditLimit     int `json:"credit_limit"`
+	CycleDays       int `json:"cycle_days"`
+	PaymentTermDays int `json:"payment_term_days"`
+}
+
+// UpdatePostpaidAccount 开通或修改用户的后付费账户
+func UpdatePostpaidAccount(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	req := postpaidAccountRequest{CycleDays: 30, PaymentTermDays: 15}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	account, err := model.SetPostpaidAccount(userId, req.CreditLimit, req.CycleDays, req.PaymentTermDays)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAuditFor(c, userId, "user.postpaid_update", map[string]interface{}{
+		"credit_limit":      logger.LogQuota(req.CreditLimit),
+		"cycle_days":        req.CycleDays,
+		"payment_term_days": req.PaymentTermDays,
+	})
+	common.ApiSuccess(c, account)
+}
+
+// DeletePostpaidAccount 关闭用户的后付费账户，要求余额已结清
+func DeletePostpaidAccount(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.DeletePostpaidAccount(userId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAuditFor(c, userId, "user.postpaid_delete", nil)
+	common.ApiSuccess(c, nil)
+}
+
+// SettlePostpaidAccount 登记线下收款，amount 为 0 时按当前应付金额结清
+func SettlePostpaidAccount(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req struct {
+		Amount int `json:"amount"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+	account, credited, err := service.SettlePostpaidAccount(userId, req.Amount)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAuditFor(c, userId, "user.postpaid_settle", map[string]interface{}{
+		"quota": logger.LogQuota(credited),
+	})
+	common.ApiSuccess(c, account)
+}
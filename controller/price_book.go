@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// priceBookRequest carries a full price book. Omitted sections inherit the
+// prices currently in effect, so a request only touching model_ratio keeps the
+// existing completion ratios, fixed prices and billing expressions.
+type priceBookRequest struct {
+	EffectiveFrom   int64              `json:"effective_from"`
+	Note            string             `json:"note"`
+	ModelRatio      map[string]float64 `json:"model_ratio"`
+	CompletionRatio map[string]float64 `json:"completion_ratio"`
+	ModelPrice      map[string]float64 `json:"model_price"`
+	BillingMode     map[string]string  `json:"billing_mode"`
+	BillingExpr     map[string]string  `json:"billing_expr"`
+}
+
+func (r *priceBookRequest) content() *model.PriceBookContent {
+	content := model.GetCurrentPriceBookContent()
+	if r.ModelRatio != nil {
+		content.ModelRatio = r.ModelRatio
+	}
+	if r.CompletionRatio != nil {
+		content.CompletionRatio = r.CompletionRatio
+	}
+	if r.ModelPrice != nil {
+		content.ModelPrice = r.ModelPrice
+	}
+	if r.BillingMode != nil {
+		content.BillingMode = r.BillingMode
+	}
+	if r.BillingExpr != nil {
+		content.BillingExpr = r.BillingExpr
+	}
+	return content
+}
+
+func priceBookIdParam(c *gin.Context) (*model.PriceBook, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	book, err := model.GetPriceBookById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	return book, true
+}
+
+func GetPriceBooks(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	books, total, err := model.GetPriceBooks(c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(books)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetPriceBook(c *gin.Context) {
+	book, ok := priceBookIdParam(c)
+	if !ok {
+		return
+	}
+	content, err := book.GetContent()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"price_book": book,
+		"content":    content,
+	})
+}
+
+// GetPriceBookDiff compares a price book with the prices currently in effect.
+func GetPriceBookDiff(c *gin.Context) {
+	book, ok := priceBookIdParam(c)
+	if !ok {
+		return
+	}
+	content, err := book.GetContent()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"current_version": model.GetActivePriceBookVersion(),
+		"changes":         model.DiffPriceBookContent(model.GetCurrentPriceBookContent(), content),
+	})
+}
+
+// PreviewPriceBook validates a price book without saving it and returns the
+// changes it would make against the prices currently in effect.
+func PreviewPriceBook(c *gin.Context) {
+	var req priceBookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	content := req.content()
+	if err := content.Validate(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"current_version": model.GetActivePriceBookVersion(),
+		"changes":         model.DiffPriceBookContent(model.GetCurrentPriceBookContent(), content),
+	})
+}
+
+// CreatePriceBook schedules a price book. An effective_from in the past (or
+// omitted) applies it right away.
+func CreatePriceBook(c *gin.Context) {
+	var req priceBookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	now := common.GetTimestamp()
+	if req.EffectiveFrom <= 0 {
+		req.EffectiveFrom = now
+	}
+	book, err := model.CreatePriceBook(req.content(), req.EffectiveFrom, strings.TrimSpace(req.Note), c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if book.EffectiveFrom <= now {
+		if _, err := model.ApplyDuePriceBooks(now); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		if book, err = model.GetPriceBookById(book.Id); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+	recordManageAudit(c, "price_book.create", map[string]interface{}{
+		"version":        book.Id,
+		"effective_from": book.EffectiveFrom,
+	})
+	common.ApiSuccess(c, book)
+}
+
+func CancelPriceBook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.CancelPriceBook(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	recordManageAudit(c, "price_book.cancel", map[string]interface{}{
+		"version": id,
+	})
+	common.ApiSuccess(c, nil)
+}
+
+func GetModelPriceHistory(c *gin.Context) {
+	modelName := strings.TrimSpace(c.Query("model"))
+	if modelName == "" {
+		common.ApiError(c, errors.New("model is required"))
+		return
+	}
+	history, err := model.GetModelPriceHistory(modelName)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, history)
+}
@@ -94,6 +94,9 @@ func ResetModelRatio(c *gin.Context) {
 		})
 		return
 	}
+	if _, err := model.RecordPriceBookSnapshot("reset_model_ratio", c.GetInt("id")); err != nil {
+		common.SysError("failed to record price book snapshot: " + err.Error())
+	}
 	c.JSON(200, gin.H{
 		"success": true,
 		"message": "重置模型倍率成功",
@@ -36,7 +36,7 @@ import (
 func relayHandler(c *gin.Context, info *relaycommon.RelayInfo) *types.NewAPIError {
 	var err *types.NewAPIError
 	switch info.RelayMode {
-	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits:
+	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits, relayconstant.RelayModeImagesVariations:
 		err = relay.ImageHelper(c, info)
 	case relayconstant.RelayModeAudioSpeech:
 		fallthrough
@@ -120,11 +120,21 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		return
 	}
 
+	// 始终提取请求体中的项目标签，以便从 Claude metadata 中移除；请求头已指定时以请求头为准
+	if project := service.ProjectFromRequest(request); common.GetContextKeyString(c, constant.ContextKeyProject) == "" {
+		service.ApplyRequestProjectTag(c, project)
+	}
+
 	relayInfo, err := relaycommon.GenRelayInfo(c, relayFormat, request, ws)
 	if err != nil {
 		newAPIError = types.NewError(err, types.ErrorCodeGenRelayInfoFailed)
 		return
 	}
+	asyncImage := isAsyncImageRequest(c, relayInfo)
+	if asyncImage {
+		// 请求返回后图片仍在生成，需与视频任务一样锁定全额预扣
+		relayInfo.ForcePreConsume = true
+	}
 
 	needSensitiveCheck := setting.ShouldCheckPromptSensitive()
 	needCountToken := constant.CountToken
@@ -181,6 +191,15 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 	}()
 
+	if asyncImage {
+		newAPIError = submitAsyncImageTask(c, relayInfo, relayFormat)
+		return
+	}
+	newAPIError = relayWithRetry(c, relayInfo, relayFormat)
+}
+
+// relayWithRetry 选择渠道并转发请求，按重试策略切换渠道，返回最后一次的错误
+func relayWithRetry(c *gin.Context, relayInfo *relaycommon.RelayInfo, relayFormat types.RelayFormat) (newAPIError *types.NewAPIError) {
 	retryParam := &service.RetryParam{
 		Ctx:         c,
 		TokenGroup:  relayInfo.TokenGroup,
@@ -230,7 +249,7 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 
 		if newAPIError == nil {
 			relayInfo.LastError = nil
-			return
+			return nil
 		}
 
 		newAPIError = service.NormalizeViolationFeeError(newAPIError)
@@ -253,6 +272,7 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 			perfmetrics.RecordRelaySample(relayInfo, false, 0)
 		})
 	}
+	return newAPIError
 }
 
 var upgrader = websocket.Upgrader{
@@ -504,6 +524,11 @@ func RelayTask(c *gin.Context) {
 		respondTaskError(c, taskErr)
 		return
 	}
+	callbackURL, err := service.ResolveTaskCallbackURL(c, "")
+	if err != nil {
+		respondTaskError(c, service.TaskErrorWrapperLocal(err, "invalid_callback_url", http.StatusBadRequest))
+		return
+	}
 
 	var result *relay.TaskSubmitResult
 	var taskErr *taskdto.TaskError
@@ -588,8 +613,12 @@ func RelayTask(c *gin.Context) {
 		task.PrivateData.UpstreamTaskID = result.UpstreamTaskID
 		task.PrivateData.BillingSource = relayInfo.BillingSource
 		task.PrivateData.SubscriptionId = relayInfo.SubscriptionId
+		task.PrivateData.OrganizationId = relayInfo.OrganizationId
 		task.PrivateData.TokenId = relayInfo.TokenId
 		task.PrivateData.NodeName = common.NodeName
+		task.PrivateData.Project = common.GetContextKeyString(c, constant.ContextKeyProject)
+		task.PrivateData.CallbackURL = callbackURL
+		task.PrivateData.UpstreamCallback = relayInfo.UpstreamCallbackURL != ""
 		task.PrivateData.BillingContext = &model.TaskBillingContext{
 			ModelPrice:      relayInfo.PriceData.ModelPrice,
 			GroupRatio:      relayInfo.PriceData.GroupRatioInfo.GroupRatio,
@@ -0,0 +1,432 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type resellerRequest struct {
+	UserId   int     `json:"user_id"`
+	Markup   float64 `json:"markup"`
+	MaxUsers int     `json:"max_users"`
+	Status   int     `json:"status"`
+}
+
+type resellerUserRequest struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	DisplayName string `json:"display_name"`
+	Status      int    `json:"status"`
+}
+
+type resellerQuotaRequest struct {
+	Quota int `json:"quota"`
+}
+
+func respondResellerError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrResellerNotFound):
+		common.ApiErrorI18n(c, i18n.MsgResellerNotFound)
+	case errors.Is(err, model.ErrResellerExists):
+		common.ApiErrorI18n(c, i18n.MsgResellerExists)
+	case errors.Is(err, model.ErrResellerDisabled):
+		common.ApiErrorI18n(c, i18n.MsgResellerDisabled)
+	case errors.Is(err, model.ErrResellerUserLimit):
+		common.ApiErrorI18n(c, i18n.MsgResellerUserLimit)
+	case errors.Is(err, model.ErrResellerHasUsers):
+		common.ApiErrorI18n(c, i18n.MsgResellerHasUsers)
+	case errors.Is(err, model.ErrResellerUserNotFound):
+		common.ApiErrorI18n(c, i18n.MsgResellerUserNotFound)
+	case errors.Is(err, model.ErrResellerInvalidMarkup):
+		common.ApiErrorI18n(c, i18n.MsgResellerInvalidMarkup)
+	case errors.Is(err, model.ErrResellerTooDeep):
+		common.ApiErrorI18n(c, i18n.MsgResellerTooDeep)
+	default:
+		common.ApiError(c, err)
+	}
+}
+
+// loadSelfReseller 校验当前用户是启用状态的分销商。
+func loadSelfReseller(c *gin.Context) (*model.Reseller, bool) {
+	reseller, err := model.GetResellerByUserId(c.GetInt("id"))
+	if err != nil {
+		if errors.Is(err, model.ErrResellerNotFound) {
+			common.ApiErrorI18n(c, i18n.MsgResellerNotReseller)
+			return nil, false
+		}
+		common.ApiError(c, err)
+		return nil, false
+	}
+	if reseller.Status != model.ResellerStatusEnabled {
+		common.ApiErrorI18n(c, i18n.MsgResellerDisabled)
+		return nil, false
+	}
+	return reseller, true
+}
+
+// loadResellerUser 解析路由中的用户 ID 并校验其属于该分销商。
+func loadResellerUser(c *gin.Context, resellerId int) (*model.User, bool) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return nil, false
+	}
+	user, err := model.GetResellerUser(resellerId, userId)
+	if err != nil {
+		respondResellerError(c, err)
+		return nil, false
+	}
+	return user, true
+}
+
+// resellerReportPeriod 解析报表时间范围，默认为最近 30 天。
+func resellerReportPeriod(c *gin.Context) (int64, int64) {
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	if endTimestamp <= 0 {
+		endTimestamp = time.Now().Unix()
+	}
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	if startTimestamp <= 0 || startTimestamp > endTimestamp {
+		startTimestamp = endTimestamp - 30*24*3600
+	}
+	return startTimestamp, endTimestamp
+}
+
+// ---- Reseller APIs ----
+
+func GetSelfReseller(c *gin.Context) {
+	reseller, err := model.GetResellerDetail(c.GetInt("id"))
+	if err != nil {
+		if errors.Is(err, model.ErrResellerNotFound) {
+			common.ApiErrorI18n(c, i18n.MsgResellerNotReseller)
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	markup, err := model.GetResellerMarkup(reseller.UserId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"reseller": reseller,
+		// 下游用户相对站点基础价格的总加价（包含上级分销商的加价）
+		"total_markup": markup,
+	})
+}
+
+func GetResellerUsers(c *gin.Context) {
+	reseller, ok := loadSelfReseller(c)
+	if !ok {
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	users, total, err := model.GetResellerUsers(reseller.UserId, c.Query("keyword"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(users)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func CreateResellerUser(c *gin.Context) {
+	reseller, ok := loadSelfReseller(c)
+	if !ok {
+		return
+	}
+	var req resellerUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" || req.Password == "" {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := common.Validate.Struct(&model.User{Username: req.Username, Password: req.Password, DisplayName: req.DisplayName}); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgUserInputInvalid, map[string]any{"Error": err.Error()})
+		return
+	}
+	user, err := model.CreateResellerUser(reseller.UserId, req.Username, req.Password, req.DisplayName)
+	if err != nil {
+		respondResellerError(c, err)
+		return
+	}
+	model.RecordLog(reseller.UserId, model.LogTypeManage, fmt.Sprintf("创建下游用户 %s", user.Username))
+	common.ApiSuccess(c, gin.H{"id": user.Id, "username": user.Username})
+}
+
+// UpdateResellerUser 修改下游用户的显示名、密码或启用状态。
+func UpdateResellerUser(c *gin.Context) {
+	reseller, ok := loadSelfReseller(c)
+	if !ok {
+		return
+	}
+	target, ok := loadResellerUser(c, reseller.UserId)
+	if !ok {
+		return
+	}
+	var req resellerUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if req.Status != 0 && req.Status != common.UserStatusEnabled && req.Status != common.UserStatusDisabled {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := common.Validate.Var(req.DisplayName, "max=20"); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgUserInputInvalid, map[string]any{"Error": err.Error()})
+		return
+	}
+	if req.Password != "" {
+		if err := common.Validate.Var(req.Password, "min=8,max=20"); err != nil {
+			common.ApiErrorI18n(c, i18n.MsgUserInputInvalid, map[string]any{"Error": err.Error()})
+			return
+		}
+	}
+	user, err := model.GetUserById(target.Id, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.DisplayName != "" {
+		user.DisplayName = req.DisplayName
+	}
+	if req.Status != 0 {
+		user.Status = req.Status
+	}
+	updatePassword := req.Password != ""
+	if updatePassword {
+		user.Password = req.Password
+	}
+	if err := user.Update(updatePassword); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// AdjustResellerUserQuota 为下游用户发放或扣回额度，quota 为负数时表示扣回。
+// 发放额度不从分销商钱包扣除，下游用户消费时才按批发价扣费。
+func AdjustResellerUserQuota(c *gin.Context) {
+	reseller, ok := loadSelfReseller(c)
+	if !ok {
+		return
+	}
+	target, ok := loadResellerUser(c, reseller.UserId)
+	if !ok {
+		return
+	}
+	var req resellerQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Quota == 0 {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := model.AdjustResellerUserQuota(reseller.UserId, target.Id, req.Quota); err != nil {
+		respondResellerError(c, err)
+		return
+	}
+	model.RecordLog(target.Id, model.LogTypeManage, fmt.Sprintf("分销商调整额度 %s", logger.LogQuota(req.Quota)))
+	common.ApiSuccess(c, nil)
+}
+
+// CreateSubReseller 将自己的下游用户设为下级分销商，下级的加价叠加在自己的价格之上。
+func CreateSubReseller(c *gin.Context) {
+	reseller, ok := loadSelfReseller(c)
+	if !ok {
+		return
+	}
+	target, ok := loadResellerUser(c, reseller.UserId)
+	if !ok {
+		return
+	}
+	var req resellerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	sub, err := model.CreateReseller(target.Id, req.Markup, req.MaxUsers)
+	if err != nil {
+		respondResellerError(c, err)
+		return
+	}
+	common.ApiSuccess(c, sub)
+}
+
+// UpdateSubReseller 修改下级分销商的加价、用户上限或启用状态。
+func UpdateSubReseller(c *gin.Context) {
+	reseller, ok := loadSelfReseller(c)
+	if !ok {
+		return
+	}
+	target, ok := loadResellerUser(c, reseller.UserId)
+	if !ok {
+		return
+	}
+	var req resellerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := model.UpdateReseller(target.Id, req.Markup, req.MaxUsers, req.Status); err != nil {
+		respondResellerError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+// GetResellerReport 返回分销商在时间范围内各下游用户的零售额度、批发额度与差价。
+func GetResellerReport(c *gin.Context) {
+	reseller, ok := loadSelfReseller(c)
+	if !ok {
+		return
+	}
+	startTimestamp, endTimestamp := resellerReportPeriod(c)
+	report, err := model.GetResellerUsageReport(reseller.UserId, startTimestamp, endTimestamp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, report)
+}
+
+// GetResellerLogs 返回分销商下游用户的消费日志。
+func GetResellerLogs(c *gin.Context) {
+	reseller, ok := loadSelfReseller(c)
+	if !ok {
+		return
+	}
+	userIds, err := model.GetResellerUserIds(reseller.UserId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	pageInfo := common.GetPageQuery(c)
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	logs, total, err := model.GetResellerLogs(userIds, userId, startTimestamp, endTimestamp, c.Query("model_name"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(logs)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// ---- Admin APIs ----
+
+func AdminListResellers(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	resellers, total, err := model.GetAllResellers(c.Query("keyword"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(resellers)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func AdminCreateReseller(c *gin.Context) {
+	var req resellerRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.UserId <= 0 {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	reseller, err := model.CreateReseller(req.UserId, req.Markup, req.MaxUsers)
+	if err != nil {
+		respondResellerError(c, err)
+		return
+	}
+	recordManageAuditFor(c, req.UserId, "reseller.create", map[string]interface{}{
+		"markup":    req.Markup,
+		"max_users": req.MaxUsers,
+	})
+	common.ApiSuccess(c, reseller)
+}
+
+func AdminUpdateReseller(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	var req resellerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := model.UpdateReseller(userId, req.Markup, req.MaxUsers, req.Status); err != nil {
+		respondResellerError(c, err)
+		return
+	}
+	recordManageAuditFor(c, userId, "reseller.update", map[string]interface{}{
+		"markup":    req.Markup,
+		"max_users": req.MaxUsers,
+		"status":    req.Status,
+	})
+	common.ApiSuccess(c, nil)
+}
+
+func AdminDeleteReseller(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := model.DeleteReseller(userId); err != nil {
+		respondResellerError(c, err)
+		return
+	}
+	recordManageAuditFor(c, userId, "reseller.delete", nil)
+	common.ApiSuccess(c, nil)
+}
+
+func AdminGetResellerUsers(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	users, total, err := model.GetResellerUsers(userId, c.Query("keyword"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(users)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func AdminGetResellerReport(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	startTimestamp, endTimestamp := resellerReportPeriod(c)
+	report, err := model.GetResellerUsageReport(userId, startTimestamp, endTimestamp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, report)
+}
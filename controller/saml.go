@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/oauth"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/gin-gonic/gin"
+)
+
+// samlRequestIDPrefix turns the auth flow token into a valid XML ID (IDs may
+// not start with a digit or '-').
+const samlRequestIDPrefix = "_"
+
+type samlRequestPayload struct {
+	State string `json:"state"`
+}
+
+// SAMLMetadata serves the SP metadata document to register with the IdP.
+func SAMLMetadata(c *gin.Context) {
+	sp, err := oauth.NewSAMLServiceProvider()
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgOAuthSAMLNotConfigured)
+		return
+	}
+	metadata, err := sp.Metadata()
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgOAuthSAMLNotConfigured)
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// SAMLLogin starts SP-initiated SSO. The state is a flow token obtained from
+// POST /api/oauth/state with provider "saml"; it travels through the IdP as
+// RelayState and comes back to /oauth/saml like an OAuth state.
+func SAMLLogin(c *gin.Context) {
+	provider := oauth.GetProvider("saml")
+	if provider == nil || !provider.IsEnabled() {
+		common.ApiErrorI18n(c, i18n.MsgOAuthNotEnabled, providerParams(system_setting.GetSAMLSettings().GetEffectiveDisplayName()))
+		return
+	}
+	state := c.Query("state")
+	if _, err := model.GetAuthFlow(state, model.AuthFlowMatch{
+		Purpose:  model.AuthFlowPurposeOAuth,
+		Provider: "saml",
+	}); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": i18n.T(c, i18n.MsgOAuthStateInvalid)})
+		return
+	}
+	sp, err := oauth.NewSAMLServiceProvider()
+	if err != nil {
+		common.SysError("failed to load SAML settings: " + err.Error())
+		common.ApiErrorI18n(c, i18n.MsgOAuthSAMLNotConfigured)
+		return
+	}
+	payload, err := common.Marshal(samlRequestPayload{State: state})
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	requestToken, _, err := model.CreateAuthFlow(model.AuthFlowCreate{
+		Purpose:   model.AuthFlowPurposeSAMLRequest,
+		Provider:  "saml",
+		Payload:   string(payload),
+		ExpiresAt: time.Now().Add(oauthAuthFlowTTL),
+	})
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	redirect, err := sp.AuthnRequestURL(samlRequestIDPrefix+requestToken, state)
+	if err != nil {
+		common.SysError("failed to build SAML AuthnRequest: " + err.Error())
+		common.ApiErrorI18n(c, i18n.MsgOAuthSAMLNotConfigured)
+		return
+	}
+	c.Redirect(http.StatusFound, redirect)
+}
+
+// SAMLACS is the assertion consumer service. It validates the response
+// posted by the IdP, correlates it with the outstanding AuthnRequest and
+// hands the identity to the OAuth callback flow as a one-time code.
+func SAMLACS(c *gin.Context) {
+	relayState := c.PostForm("RelayState")
+	sp, err := oauth.NewSAMLServiceProvider()
+	if err != nil {
+		common.SysError("failed to load SAML settings: " + err.Error())
+		redirectSAMLError(c, relayState, i18n.MsgOAuthSAMLNotConfigured)
+		return
+	}
+	assertion, err := sp.ParseResponse(c.PostForm("SAMLResponse"))
+	if err != nil {
+		logger.LogWarn(c.Request.Context(), "rejected SAML response: "+err.Error())
+		redirectSAMLError(c, relayState, i18n.MsgOAuthSAMLAssertionInvalid)
+		return
+	}
+	requestToken := strings.TrimPrefix(assertion.InResponseTo, samlRequestIDPrefix)
+	flow, err := model.ConsumeAuthFlow(requestToken, model.AuthFlowMatch{
+		Purpose:  model.AuthFlowPurposeSAMLRequest,
+		Provider: "saml",
+	})
+	if err != nil {
+		logger.LogWarn(c.Request.Context(), fmt.Sprintf("SAML response for unknown request %q: %s", assertion.InResponseTo, err.Error()))
+		redirectSAMLError(c, relayState, i18n.MsgOAuthStateInvalid)
+		return
+	}
+	var payload samlRequestPayload
+	if err := common.UnmarshalJsonStr(flow.Payload, &payload); err != nil || payload.State != relayState {
+		redirectSAMLError(c, relayState, i18n.MsgOAuthStateInvalid)
+		return
+	}
+	if err := model.ClaimExternalAuthAssertion(model.AuthFlowPurposeSAMLAssertion, assertion.Issuer+"\n"+assertion.ID, assertion.ExpiresAt); err != nil {
+		logger.LogWarn(c.Request.Context(), "replayed SAML assertion "+assertion.ID)
+		redirectSAMLError(c, relayState, i18n.MsgOAuthSAMLAssertionInvalid)
+		return
+	}
+	code, err := oauth.CreateSAMLTicket(relayState, assertion)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.Redirect(http.StatusFound, samlCallbackURL(url.Values{
+		"code":  {code},
+		"state": {relayState},
+	}))
+}
+
+func samlCallbackURL(query url.Values) string {
+	return strings.TrimRight(system_setting.ServerAddress, "/") + "/oauth/saml?" + query.Encode()
+}
+
+// redirectSAMLError reports a failure through the frontend OAuth callback,
+// which shows error_description and releases the state. Without a state
+// there is nothing to return to, so the error is rendered directly.
+func redirectSAMLError(c *gin.Context, relayState string, msgKey string) {
+	if relayState == "" {
+		common.ApiErrorI18n(c, msgKey)
+		return
+	}
+	c.Redirect(http.StatusFound, samlCallbackURL(url.Values{
+		"error":             {"saml_error"},
+		"error_description": {i18n.T(c, msgKey)},
+		"state":             {relayState},
+	}))
+}
@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/oauth"
+	"github.com/QuantumNous/new-api/saml/samltest"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupSAMLControllerTest(t *testing.T) (*samltest.IdP, *system_setting.SAMLSettings) {
+	t.Helper()
+	require.NoError(t, i18n.Init())
+	previousDB := model.DB
+	previousType := common.MainDatabaseType()
+	previousAddress := system_setting.ServerAddress
+	settings := system_setting.GetSAMLSettings()
+	previousSettings := *settings
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.AuthFlow{}))
+	model.DB = db
+	common.SetMainDatabaseType(common.DatabaseTypeSQLite)
+	t.Cleanup(func() {
+		model.DB = previousDB
+		common.SetMainDatabaseType(previousType)
+		system_setting.ServerAddress = previousAddress
+		*settings = previousSettings
+	})
+
+	idp, err := samltest.New("https://idp.example.com", "https://idp.example.com/sso")
+	require.NoError(t, err)
+	spKey, spCertificate, err := samltest.NewKeyPair("new-api")
+	require.NoError(t, err)
+	system_setting.ServerAddress = "https://api.example.com"
+	settings.Enabled = true
+	settings.IdPMetadata = string(idp.Metadata())
+	settings.SPCertificate = samltest.CertificatePEM(spCertificate)
+	settings.SPPrivateKey = samltest.PrivateKeyPEM(spKey)
+	settings.UsernameAttribute = "uid"
+	settings.EmailAttribute = "email"
+	settings.GroupAttribute = "groups"
+	settings.GroupMapping = `{"engineering":"vip"}`
+	return idp, settings
+}
+
+func createSAMLLoginState(t *testing.T) string {
+	t.Helper()
+	state, _, err := model.CreateAuthFlow(model.AuthFlowCreate{
+		Purpose:   model.AuthFlowPurposeOAuth,
+		Provider:  "saml",
+		Intent:    model.AuthFlowIntentLogin,
+		Payload:   "{}",
+		ExpiresAt: time.Now().Add(oauthAuthFlowTTL),
+	})
+	require.NoError(t, err)
+	return state
+}
+
+func postSAMLResponse(t *testing.T, samlResponse string, relayState string) *httptest.ResponseRecorder {
+	t.Helper()
+	form := url.Values{"SAMLResponse": {samlResponse}, "RelayState": {relayState}}
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/saml/acs", strings.NewReader(form.Encode()))
+	c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	SAMLACS(c)
+	// The gin engine flushes the status after the handler chain; http.Redirect
+	// writes no body for POST requests.
+	c.Writer.WriteHeaderNow()
+	return recorder
+}
+
+func TestSAMLLoginRoundTripThroughStubIdP(t *testing.T) {
+	idp, _ := setupSAMLControllerTest(t)
+	state := createSAMLLoginState(t)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/saml/login?state="+url.QueryEscape(state), nil)
+	SAMLLogin(c)
+	require.Equal(t, http.StatusFound, recorder.Code)
+
+	sp, err := oauth.NewSAMLServiceProvider()
+	require.NoError(t, err)
+	request, err := samltest.ParseAuthnRequest(recorder.Header().Get("Location"), sp.Certificate)
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.example.com/api/saml/acs", request.AssertionConsumerServiceURL)
+	assert.Equal(t, state, request.RelayState)
+
+	samlResponse, err := idp.Respond(samltest.Response{
+		InResponseTo: request.ID,
+		ACSURL:       request.AssertionConsumerServiceURL,
+		Audience:     request.Issuer,
+		NameID:       "employee-42",
+		Attributes: map[string][]string{
+			"uid":    {"alice"},
+			"email":  {"alice@example.com"},
+			"groups": {"marketing", "engineering"},
+		},
+	})
+	require.NoError(t, err)
+	recorder = postSAMLResponse(t, samlResponse, request.RelayState)
+	require.Equal(t, http.StatusFound, recorder.Code)
+	callback, err := url.Parse(recorder.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/oauth/saml", callback.Path)
+	assert.Equal(t, state, callback.Query().Get("state"))
+	code := callback.Query().Get("code")
+	require.NotEmpty(t, code)
+
+	// The frontend then calls HandleOAuth, which redeems the code.
+	provider := oauth.GetProvider("saml")
+	exchange, _ := gin.CreateTestContext(httptest.NewRecorder())
+	exchange.Request = httptest.NewRequest(http.MethodGet, "/api/oauth/saml?code="+url.QueryEscape(code)+"&state="+url.QueryEscape(state), nil)
+	token, err := provider.ExchangeToken(exchange.Request.Context(), code, exchange)
+	require.NoError(t, err)
+	user, err := provider.GetUserInfo(exchange.Request.Context(), token)
+	require.NoError(t, err)
+	assert.Equal(t, "employee-42", user.ProviderUserID)
+	assert.Equal(t, "alice", user.Username)
+	assert.Equal(t, "alice@example.com", user.Email)
+	assert.Equal(t, "vip", user.Extra["group"])
+
+	// Codes are single use.
+	_, err = provider.ExchangeToken(exchange.Request.Context(), code, exchange)
+	assert.Error(t, err)
+
+	// Replaying the same response fails even though it is still validly signed.
+	recorder = postSAMLResponse(t, samlResponse, request.RelayState)
+	require.Equal(t, http.StatusFound, recorder.Code)
+	callback, err = url.Parse(recorder.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "saml_error", callback.Query().Get("error"))
+	assert.Empty(t, callback.Query().Get("code"))
+}
+
+func TestSAMLACSRejectsUnsolicitedResponse(t *testing.T) {
+	idp, _ := setupSAMLControllerTest(t)
+	state := createSAMLLoginState(t)
+
+	samlResponse, err := idp.Respond(samltest.Response{
+		InResponseTo: "_never-issued",
+		ACSURL:       "https://api.example.com/api/saml/acs",
+		Audience:     "https://api.example.com/api/saml/metadata",
+		NameID:       "employee-42",
+	})
+	require.NoError(t, err)
+	recorder := postSAMLResponse(t, samlResponse, state)
+	require.Equal(t, http.StatusFound, recorder.Code)
+	callback, err := url.Parse(recorder.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "saml_error", callback.Query().Get("error"))
+	assert.Empty(t, callback.Query().Get("code"))
+}
+
+func TestSyncOAuthUserGroupSkipsAdministrators(t *testing.T) {
+	setupSAMLControllerTest(t)
+	require.NoError(t, model.DB.AutoMigrate(&model.User{}, &model.UserSession{}))
+	previousRedis := common.RedisEnabled
+	common.RedisEnabled = false
+	t.Cleanup(func() { common.RedisEnabled = previousRedis })
+	member := &model.User{Username: "saml_member", Role: common.RoleCommonUser, Status: common.UserStatusEnabled, Group: "default", AffCode: "saml-member"}
+	admin := &model.User{Username: "saml_admin", Role: common.RoleAdminUser, Status: common.UserStatusEnabled, Group: "default", AffCode: "saml-admin"}
+	root := &model.User{Username: "saml_root", Role: common.RoleRootUser, Status: common.UserStatusEnabled, Group: "default", AffCode: "saml-root"}
+	require.NoError(t, model.DB.Create(member).Error)
+	require.NoError(t, model.DB.Create(admin).Error)
+	require.NoError(t, model.DB.Create(root).Error)
+
+	for _, user := range []*model.User{member, admin, root} {
+		require.NoError(t, syncOAuthUserGroup(user.Id, "vip"))
+	}
+
+	groupOf := func(id int) string {
+		var user model.User
+		require.NoError(t, model.DB.First(&user, id).Error)
+		return user.Group
+	}
+	assert.Equal(t, "vip", groupOf(member.Id))
+	assert.Equal(t, "default", groupOf(admin.Id))
+	assert.Equal(t, "default", groupOf(root.Id))
+}
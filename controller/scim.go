@@ -0,0 +1,621 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/scim"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SCIM 2.0 provisioning (RFC 7644). Users map onto model.User; groups map
+// onto the configured user groups, so adding a user to an IdP group sets the
+// user's group and removing it falls back to scim.default_group. The routes
+// are authenticated by middleware.SCIMAuth, so every handler here acts on
+// behalf of the identity provider rather than a logged-in user.
+
+const scimBasePath = "/scim/v2"
+
+func scimRespond(c *gin.Context, status int, v any) {
+	body, err := common.Marshal(v)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	c.Data(status, scim.ContentType, body)
+}
+
+func scimError(c *gin.Context, status int, scimType string, detail string) {
+	body, _ := common.Marshal(scim.NewError(status, scimType, detail))
+	c.Data(status, scim.ContentType, body)
+}
+
+func scimTime(unix int64) string {
+	if unix <= 0 {
+		return ""
+	}
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
+
+func toSCIMUser(su *model.ScimUser) scim.User {
+	user := su.User
+	id := strconv.Itoa(user.Id)
+	active := user.Status == common.UserStatusEnabled
+	result := scim.User{
+		Schemas:     []string{scim.SchemaUser},
+		Id:          id,
+		UserName:    user.Username,
+		DisplayName: user.DisplayName,
+		Active:      &active,
+		Meta: &scim.Meta{
+			ResourceType: "User",
+			Created:      scimTime(user.CreatedAt),
+			Location:     scimBasePath + "/Users/" + id,
+		},
+	}
+	if su.Identity != nil {
+		result.UserName = su.Identity.UserName
+		result.ExternalId = su.Identity.ExternalId
+		result.Meta.LastModified = scimTime(su.Identity.UpdatedTime)
+		if su.Identity.GivenName != "" || su.Identity.FamilyName != "" {
+			result.Name = &scim.Name{
+				GivenName:  su.Identity.GivenName,
+				FamilyName: su.Identity.FamilyName,
+				Formatted:  strings.TrimSpace(su.Identity.GivenName + " " + su.Identity.FamilyName),
+			}
+		}
+	}
+	if user.Email != "" {
+		result.Emails = []scim.MultiValue{{Value: user.Email, Type: "work", Primary: true}}
+	}
+	if user.Group != "" {
+		result.Groups = []scim.MultiValue{{Value: user.Group, Display: user.Group, Ref: scimBasePath + "/Groups/" + user.Group}}
+	}
+	return result
+}
+
+func scimIdentityFromUser(userId int, input scim.User) model.ScimIdentity {
+	identity := model.ScimIdentity{
+		UserId:     userId,
+		UserName:   strings.TrimSpace(input.UserName),
+		ExternalId: strings.TrimSpace(input.ExternalId),
+	}
+	if input.Name != nil {
+		identity.GivenName = input.Name.GivenName
+		identity.FamilyName = input.Name.FamilyName
+	}
+	return identity
+}
+
+func scimDisplayName(input scim.User) string {
+	if name := strings.TrimSpace(input.DisplayName); name != "" {
+		return name
+	}
+	if input.Name != nil {
+		if input.Name.Formatted != "" {
+			return strings.TrimSpace(input.Name.Formatted)
+		}
+		if full := strings.TrimSpace(input.Name.GivenName + " " + input.Name.FamilyName); full != "" {
+			return full
+		}
+	}
+	return ""
+}
+
+func truncateDisplayName(name string) string {
+	if runes := []rune(name); len(runes) > 20 {
+		return string(runes[:20])
+	}
+	return name
+}
+
+func scimUserIdParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		scimError(c, http.StatusNotFound, "", "user not found")
+		return 0, false
+	}
+	return id, true
+}
+
+func loadSCIMUser(c *gin.Context) (*model.ScimUser, bool) {
+	id, ok := scimUserIdParam(c)
+	if !ok {
+		return nil, false
+	}
+	su, err := model.GetScimUser(id)
+	if err != nil {
+		if errors.Is(err, model.ErrScimUserNotFound) {
+			scimError(c, http.StatusNotFound, "", "user not found")
+		} else {
+			scimError(c, http.StatusInternalServerError, "", err.Error())
+		}
+		return nil, false
+	}
+	if su.User.Role >= common.RoleAdminUser {
+		scimError(c, http.StatusForbidden, "", "administrators cannot be managed through SCIM")
+		return nil, false
+	}
+	if su.Identity == nil {
+		scimError(c, http.StatusNotFound, "", "user not found")
+		return nil, false
+	}
+	return su, true
+}
+
+// deprovisionSCIMUser disables every token of a user the IdP deactivated or
+// deleted, so API access ends together with the sessions.
+func deprovisionSCIMUser(userId int) {
+	if _, err := model.DisableUserTokens(userId); err != nil {
+		common.SysError(fmt.Sprintf("scim: failed to disable tokens of user %d: %s", userId, err.Error()))
+	}
+	if err := model.InvalidateUserTokensCache(userId); err != nil {
+		common.SysError(fmt.Sprintf("scim: failed to invalidate token cache of user %d: %s", userId, err.Error()))
+	}
+}
+
+// applySCIMUser writes a full SCIM user representation onto an existing user.
+func applySCIMUser(c *gin.Context, su *model.ScimUser, input scim.User) bool {
+	if strings.TrimSpace(input.UserName) == "" {
+		scimError(c, http.StatusBadRequest, "invalidValue", "userName is required")
+		return false
+	}
+	user := su.User
+	wasActive := user.Status == common.UserStatusEnabled
+	if name := scimDisplayName(input); name != "" {
+		user.DisplayName = truncateDisplayName(name)
+	}
+	if email := model.NormalizeEmail(input.PrimaryEmail()); email != "" && email != user.Email {
+		if err := model.EnsureEmailAvailable(email, user.Id); err != nil {
+			scimError(c, http.StatusConflict, "uniqueness", err.Error())
+			return false
+		}
+		user.Email = email
+	}
+	if input.IsActive() {
+		user.Status = common.UserStatusEnabled
+	} else {
+		user.Status = common.UserStatusDisabled
+	}
+	// Update bumps AuthVersion and revokes sessions when the status changes.
+	if err := user.Update(false); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return false
+	}
+	if err := model.SaveScimIdentity(scimIdentityFromUser(user.Id, input)); err != nil {
+		if errors.Is(err, model.ErrScimUserNameTaken) {
+			scimError(c, http.StatusConflict, "uniqueness", err.Error())
+		} else {
+			scimError(c, http.StatusInternalServerError, "", err.Error())
+		}
+		return false
+	}
+	if wasActive && user.Status == common.UserStatusDisabled {
+		deprovisionSCIMUser(user.Id)
+		model.RecordLog(user.Id, model.LogTypeManage, "SCIM：身份提供方停用了该用户，已吊销会话并禁用全部令牌")
+	} else if !wasActive && user.Status == common.UserStatusEnabled {
+		model.RecordLog(user.Id, model.LogTypeManage, "SCIM：身份提供方重新启用了该用户")
+	}
+	return true
+}
+
+func respondSCIMUser(c *gin.Context, status int, userId int) {
+	su, err := model.GetScimUser(userId)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	scimRespond(c, status, toSCIMUser(su))
+}
+
+// ---- Discovery ----
+
+func SCIMServiceProviderConfig(c *gin.Context) {
+	scimRespond(c, http.StatusOK, scim.ServiceProviderConfig())
+}
+
+func SCIMResourceTypes(c *gin.Context) {
+	resources := scim.ResourceTypes()
+	scimRespond(c, http.StatusOK, scim.NewListResponse(resources, len(resources), 1))
+}
+
+// ---- Users ----
+
+func SCIMListUsers(c *gin.Context) {
+	filter, err := scim.ParseFilter(c.Query("filter"))
+	if err != nil {
+		scimError(c, http.StatusBadRequest, "invalidFilter", err.Error())
+		return
+	}
+	startIndex, offset, count := scim.ParsePagination(c.Query("startIndex"), c.Query("count"))
+	attribute, value := "", ""
+	if filter != nil {
+		attribute, value = filter.Attribute, filter.Value
+	}
+	users, total, err := model.FindScimUsers(attribute, value, offset, count)
+	if err != nil {
+		scimError(c, http.StatusBadRequest, "invalidFilter", err.Error())
+		return
+	}
+	resources := make([]any, 0, len(users))
+	for _, su := range users {
+		resources = append(resources, toSCIMUser(su))
+	}
+	scimRespond(c, http.StatusOK, scim.NewListResponse(resources, int(total), startIndex))
+}
+
+func SCIMGetUser(c *gin.Context) {
+	su, ok := loadSCIMUser(c)
+	if !ok {
+		return
+	}
+	scimRespond(c, http.StatusOK, toSCIMUser(su))
+}
+
+func SCIMCreateUser(c *gin.Context) {
+	var input scim.User
+	if err := common.DecodeJson(c.Request.Body, &input); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	if strings.TrimSpace(input.UserName) == "" {
+		scimError(c, http.StatusBadRequest, "invalidValue", "userName is required")
+		return
+	}
+	user := &model.User{
+		DisplayName: truncateDisplayName(scimDisplayName(input)),
+		Email:       model.NormalizeEmail(input.PrimaryEmail()),
+		Group:       system_setting.GetSCIMSettings().GetEffectiveDefaultGroup(),
+		Status:      common.UserStatusEnabled,
+	}
+	if !input.IsActive() {
+		user.Status = common.UserStatusDisabled
+	}
+	if user.Email != "" {
+		if err := model.EnsureEmailAvailable(user.Email, 0); err != nil {
+			scimError(c, http.StatusConflict, "uniqueness", err.Error())
+			return
+		}
+	}
+	if err := model.CreateScimUser(scimIdentityFromUser(0, input), user); err != nil {
+		if errors.Is(err, model.ErrScimUserNameTaken) {
+			scimError(c, http.StatusConflict, "uniqueness", err.Error())
+		} else {
+			scimError(c, http.StatusInternalServerError, "", err.Error())
+		}
+		return
+	}
+	model.RecordLog(user.Id, model.LogTypeManage, "SCIM：用户由身份提供方创建")
+	respondSCIMUser(c, http.StatusCreated, user.Id)
+}
+
+func SCIMReplaceUser(c *gin.Context) {
+	su, ok := loadSCIMUser(c)
+	if !ok {
+		return
+	}
+	var input scim.User
+	if err := common.DecodeJson(c.Request.Body, &input); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	if !applySCIMUser(c, su, input) {
+		return
+	}
+	respondSCIMUser(c, http.StatusOK, su.User.Id)
+}
+
+func SCIMPatchUser(c *gin.Context) {
+	su, ok := loadSCIMUser(c)
+	if !ok {
+		return
+	}
+	var req scim.PatchRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	current := toSCIMUser(su)
+	if err := scim.ApplyUserPatch(&current, req.Operations); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+	if !applySCIMUser(c, su, current) {
+		return
+	}
+	respondSCIMUser(c, http.StatusOK, su.User.Id)
+}
+
+func SCIMDeleteUser(c *gin.Context) {
+	su, ok := loadSCIMUser(c)
+	if !ok {
+		return
+	}
+	user := su.User
+	// Tokens are disabled before the soft delete so they stay unusable even if
+	// the account is later restored.
+	deprovisionSCIMUser(user.Id)
+	if err := user.Delete(); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	if err := model.DeleteScimIdentity(user.Id); err != nil {
+		common.SysError(fmt.Sprintf("scim: failed to delete identity of user %d: %s", user.Id, err.Error()))
+	}
+	model.RecordLog(user.Id, model.LogTypeManage, "SCIM：用户已被身份提供方删除")
+	c.Status(http.StatusNoContent)
+}
+
+// ---- Groups ----
+
+func toSCIMGroup(group string, withMembers bool) (scim.Group, error) {
+	result := scim.Group{
+		Schemas:     []string{scim.SchemaGroup},
+		Id:          group,
+		DisplayName: group,
+		Meta: &scim.Meta{
+			ResourceType: "Group",
+			Location:     scimBasePath + "/Groups/" + group,
+		},
+	}
+	if !withMembers {
+		return result, nil
+	}
+	users, err := model.GetScimUsersByGroup(group)
+	if err != nil {
+		return result, err
+	}
+	for _, user := range users {
+		id := strconv.Itoa(user.Id)
+		result.Members = append(result.Members, scim.MultiValue{
+			Value:   id,
+			Display: user.Username,
+			Ref:     scimBasePath + "/Users/" + id,
+		})
+	}
+	return result, nil
+}
+
+func loadSCIMGroup(c *gin.Context) (string, bool) {
+	group := c.Param("id")
+	if !ratio_setting.ContainsGroupRatio(group) {
+		scimError(c, http.StatusNotFound, "", "group not found")
+		return "", false
+	}
+	return group, true
+}
+
+// scimManagedUser loads a group member for a membership change. Only users
+// provisioned through SCIM can be moved, and administrators never are.
+func scimManagedUser(userIdValue string) (*model.User, error) {
+	userId, err := strconv.Atoi(userIdValue)
+	if err != nil {
+		return nil, fmt.Errorf("invalid member value %q", userIdValue)
+	}
+	su, err := model.GetScimUser(userId)
+	if err != nil {
+		return nil, fmt.Errorf("member %q not found", userIdValue)
+	}
+	if su.User.Role >= common.RoleAdminUser {
+		return nil, fmt.Errorf("member %q is an administrator and cannot be managed through SCIM", userIdValue)
+	}
+	if su.Identity == nil {
+		return nil, fmt.Errorf("member %q was not provisioned through SCIM", userIdValue)
+	}
+	return &su.User, nil
+}
+
+// setSCIMUserGroup moves a user into a group. Group changes bump AuthVersion
+// like an administrator edit does.
+func setSCIMUserGroup(user *model.User, group string) error {
+	if user.Group == group {
+		return nil
+	}
+	user.Group = group
+	return user.Update(false)
+}
+
+// applySCIMGroupMembers applies membership changes to a group. Removed users
+// return to the default group rather than losing their group altogether. A
+// replace only resets SCIM-provisioned members; local users who happen to be
+// in the same group are left alone.
+func applySCIMGroupMembers(group string, patch scim.GroupPatch) error {
+	defaultGroup := system_setting.GetSCIMSettings().GetEffectiveDefaultGroup()
+	remove := patch.Remove
+	add := patch.Add
+	if patch.ReplaceMembers {
+		keep := make(map[string]struct{}, len(patch.Members))
+		for _, member := range patch.Members {
+			keep[member] = struct{}{}
+		}
+		users, err := model.GetScimUsersByGroup(group)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			id := strconv.Itoa(user.Id)
+			if _, ok := keep[id]; !ok {
+				remove = append(remove, id)
+			}
+		}
+		add = append(add, patch.Members...)
+	}
+	for _, member := range remove {
+		if group == defaultGroup {
+			break
+		}
+		user, err := scimManagedUser(member)
+		if err != nil || user.Group != group {
+			continue
+		}
+		if err := setSCIMUserGroup(user, defaultGroup); err != nil {
+			return err
+		}
+	}
+	for _, member := range add {
+		user, err := scimManagedUser(member)
+		if err != nil {
+			return err
+		}
+		if err := setSCIMUserGroup(user, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func respondSCIMGroup(c *gin.Context, status int, group string) {
+	result, err := toSCIMGroup(group, true)
+	if err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	scimRespond(c, status, result)
+}
+
+func SCIMListGroups(c *gin.Context) {
+	filter, err := scim.ParseFilter(c.Query("filter"))
+	if err != nil {
+		scimError(c, http.StatusBadRequest, "invalidFilter", err.Error())
+		return
+	}
+	startIndex, offset, count := scim.ParsePagination(c.Query("startIndex"), c.Query("count"))
+	var groups []string
+	if filter != nil {
+		if filter.Attribute != "displayname" && filter.Attribute != "id" {
+			scimError(c, http.StatusBadRequest, "invalidFilter", "only displayName and id filters are supported for groups")
+			return
+		}
+		group := filter.Value
+		if filter.Attribute == "displayname" {
+			group = system_setting.GetSCIMSettings().MapGroup(group)
+		}
+		if ratio_setting.ContainsGroupRatio(group) {
+			groups = append(groups, group)
+		}
+	} else {
+		for group := range ratio_setting.GetGroupRatioCopy() {
+			groups = append(groups, group)
+		}
+		sort.Strings(groups)
+	}
+	total := len(groups)
+	if offset > len(groups) {
+		offset = len(groups)
+	}
+	groups = groups[offset:]
+	if len(groups) > count {
+		groups = groups[:count]
+	}
+	withMembers := c.Query("excludedAttributes") != "members"
+	resources := make([]any, 0, len(groups))
+	for _, group := range groups {
+		result, err := toSCIMGroup(group, withMembers)
+		if err != nil {
+			scimError(c, http.StatusInternalServerError, "", err.Error())
+			return
+		}
+		resources = append(resources, result)
+	}
+	scimRespond(c, http.StatusOK, scim.NewListResponse(resources, total, startIndex))
+}
+
+func SCIMGetGroup(c *gin.Context) {
+	group, ok := loadSCIMGroup(c)
+	if !ok {
+		return
+	}
+	respondSCIMGroup(c, http.StatusOK, group)
+}
+
+// SCIMCreateGroup binds an IdP group to an existing user group. User groups
+// carry pricing ratios and are configured by administrators, so SCIM cannot
+// create new ones; unknown names are rejected.
+func SCIMCreateGroup(c *gin.Context) {
+	var input scim.Group
+	if err := common.DecodeJson(c.Request.Body, &input); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	group := system_setting.GetSCIMSettings().MapGroup(input.DisplayName)
+	if !ratio_setting.ContainsGroupRatio(group) {
+		scimError(c, http.StatusBadRequest, "invalidValue", fmt.Sprintf("group %q is not configured; add it to the group ratios or scim.group_mapping", input.DisplayName))
+		return
+	}
+	patch := scim.GroupPatch{Add: make([]string, 0, len(input.Members))}
+	for _, member := range input.Members {
+		patch.Add = append(patch.Add, member.Value)
+	}
+	if err := applySCIMGroupMembers(group, patch); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+	respondSCIMGroup(c, http.StatusCreated, group)
+}
+
+func SCIMReplaceGroup(c *gin.Context) {
+	group, ok := loadSCIMGroup(c)
+	if !ok {
+		return
+	}
+	var input scim.Group
+	if err := common.DecodeJson(c.Request.Body, &input); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	patch := scim.GroupPatch{ReplaceMembers: true, Members: make([]string, 0, len(input.Members))}
+	for _, member := range input.Members {
+		patch.Members = append(patch.Members, member.Value)
+	}
+	if err := applySCIMGroupMembers(group, patch); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+	respondSCIMGroup(c, http.StatusOK, group)
+}
+
+func SCIMPatchGroup(c *gin.Context) {
+	group, ok := loadSCIMGroup(c)
+	if !ok {
+		return
+	}
+	var req scim.PatchRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	patch, err := scim.ParseGroupPatch(req.Operations)
+	if err != nil {
+		scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+	if err := applySCIMGroupMembers(group, patch); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+	respondSCIMGroup(c, http.StatusOK, group)
+}
+
+// SCIMDeleteGroup unbinds an IdP group: its members move back to the default
+// group while the configured group itself is kept.
+func SCIMDeleteGroup(c *gin.Context) {
+	group, ok := loadSCIMGroup(c)
+	if !ok {
+		return
+	}
+	if err := applySCIMGroupMembers(group, scim.GroupPatch{ReplaceMembers: true}); err != nil {
+		scimError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSelfStatements 列出当前用户的月度账单，以及其担任所有者/管理员的组织账单
+func GetSelfStatements(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	statements, total, err := model.GetVisibleStatements(c.GetInt("id"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(statements)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// DownloadSelfStatement 下载账单，format=pdf（默认）或 html
+func DownloadSelfStatement(c *gin.Context) {
+	statement, ok := loadStatementParam(c)
+	if !ok {
+		return
+	}
+	allowed, err := model.CanUserViewStatement(c.GetInt("id"), statement)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !allowed {
+		common.ApiErrorMsg(c, "statement not found")
+		return
+	}
+	writeStatementFile(c, statement)
+}
+
+// GetAllStatements 管理员按用户、组织或账单周期筛选账单
+func GetAllStatements(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	orgId, _ := strconv.Atoi(c.Query("org_id"))
+	periodStart, _ := strconv.ParseInt(c.Query("period_start"), 10, 64)
+	statements, total, err := model.GetAllStatements(userId, orgId, periodStart, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(statements)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// DownloadStatement 管理员下载任意账单
+func DownloadStatement(c *gin.Context) {
+	statement, ok := loadStatementParam(c)
+	if !ok {
+		return
+	}
+	writeStatementFile(c, statement)
+}
+
+// GenerateStatements 提交账单生成任务，未指定周期时生成上一个自然月
+func GenerateStatements(c *gin.Context) {
+	var req statementTaskPayload
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+	if req.PeriodStart == 0 && req.PeriodEnd == 0 {
+		req.PeriodStart, req.PeriodEnd = service.PreviousStatementPeriod(time.Now())
+	}
+	if req.PeriodStart >= req.PeriodEnd {
+		common.ApiErrorMsg(c, "invalid statement period")
+		return
+	}
+	task, created, err := service.EnqueueSystemTask(model.SystemTaskTypeStatement, req)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"task":    task.ToResponse(),
+		"created": created,
+	})
+}
+
+func loadStatementParam(c *gin.Context) (*model.Statement, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	statement, err := model.GetStatementById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	return statement, true
+}
+
+func writeStatementFile(c *gin.Context, statement *model.Statement) {
+	var (
+		data        []byte
+		contentType string
+		ext         string
+		err         error
+	)
+	if c.Query("format") == "html" {
+		data, err = service.RenderStatementHTML(statement)
+		contentType, ext = "text/html; charset=utf-8", "html"
+	} else {
+		data, err = service.RenderStatementPDF(statement)
+		contentType, ext = "application/pdf", "pdf"
+	}
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename="+statement.InvoiceNo+"."+ext)
+	c.Data(http.StatusOK, contentType, data)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/gin-gonic/gin"
@@ -16,7 +17,8 @@ import (
 // ---- Shared types ----
 
 type SubscriptionPlanDTO struct {
-	Plan model.SubscriptionPlan `json:"plan"`
+	Plan         model.SubscriptionPlan  `json:"plan"`
+	DisplayPrice *service.CurrencyAmount `json:"display_price,omitempty"`
 }
 
 type BillingPreferenceRequest struct {
@@ -40,11 +42,15 @@ func GetSubscriptionPlans(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	settingMap, _ := model.GetUserSetting(c.GetInt("id"), false)
+	currency := service.ResolveUserCurrency(settingMap.Currency)
 	result := make([]SubscriptionPlanDTO, 0, len(plans))
 	for _, p := range plans {
 		p.NormalizeDefaults()
+		displayPrice, _ := service.SubscriptionPlanPrice(&p, currency)
 		result = append(result, SubscriptionPlanDTO{
-			Plan: p,
+			Plan:         p,
+			DisplayPrice: displayPrice,
 		})
 	}
 	common.ApiSuccess(c, result)
@@ -165,6 +171,9 @@ func AdminCreateSubscriptionPlan(c *gin.Context) {
 		req.Plan.Currency = "USD"
 	}
 	req.Plan.Currency = "USD"
+	if !normalizePlanCurrencyPrices(c, &req.Plan) {
+		return
+	}
 	if req.Plan.AllowBalancePay == nil {
 		req.Plan.AllowBalancePay = common.GetPointer(true)
 	}
@@ -245,6 +254,9 @@ func AdminUpdateSubscriptionPlan(c *gin.Context) {
 		req.Plan.Currency = "USD"
 	}
 	req.Plan.Currency = "USD"
+	if !normalizePlanCurrencyPrices(c, &req.Plan) {
+		return
+	}
 	if req.Plan.DurationUnit == "" {
 		req.Plan.DurationUnit = model.SubscriptionDurationMonth
 	}
@@ -286,6 +298,7 @@ func AdminUpdateSubscriptionPlan(c *gin.Context) {
 			"subtitle":                   req.Plan.Subtitle,
 			"price_amount":               req.Plan.PriceAmount,
 			"currency":                   req.Plan.Currency,
+			"currency_prices":            req.Plan.CurrencyPrices,
 			"duration_unit":              req.Plan.DurationUnit,
 			"duration_value":             req.Plan.DurationValue,
 			"custom_seconds":             req.Plan.CustomSeconds,
@@ -321,6 +334,46 @@ func AdminUpdateSubscriptionPlan(c *gin.Context) {
 	common.ApiSuccess(c, nil)
 }
 
+// subscriptionOrderMoney returns the amount charged for a plan in the given
+// currency. Plans without a price for that currency convert PriceAmount from
+// the plan currency, so the order amount always matches its currency.
+func subscriptionOrderMoney(plan *model.SubscriptionPlan, currency string) (float64, error) {
+	price, err := service.SubscriptionPlanPrice(plan, currency)
+	if err != nil {
+		return 0, err
+	}
+	return price.Amount, nil
+}
+
+// normalizePlanCurrencyPrices validates the per-currency price list and
+// rewrites it with upper-case currency codes.
+func normalizePlanCurrencyPrices(c *gin.Context, plan *model.SubscriptionPlan) bool {
+	if strings.TrimSpace(plan.CurrencyPrices) == "" {
+		plan.CurrencyPrices = ""
+		return true
+	}
+	raw := map[string]float64{}
+	if err := common.UnmarshalJsonStr(plan.CurrencyPrices, &raw); err != nil {
+		common.ApiErrorMsg(c, "币种价格格式错误")
+		return false
+	}
+	prices := make(map[string]float64, len(raw))
+	for code, price := range raw {
+		code = operation_setting.NormalizeCurrency(code)
+		if !service.IsSupportedCurrency(code) {
+			common.ApiErrorMsg(c, fmt.Sprintf("不支持的币种: %s", code))
+			return false
+		}
+		if price < 0 || price > 999999 {
+			common.ApiErrorMsg(c, fmt.Sprintf("币种 %s 的价格无效", code))
+			return false
+		}
+		prices[code] = price
+	}
+	plan.CurrencyPrices = common.GetJsonString(prices)
+	return true
+}
+
 type AdminUpdateSubscriptionPlanStatusRequest struct {
 	Enabled *bool `json:"enabled"`
 }
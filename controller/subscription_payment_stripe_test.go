@@ -0,0 +1,14 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripeAmountFromMinorHandlesZeroDecimalCurrencies(t *testing.T) {
+	assert.Equal(t, 19.99, stripeAmountFromMinor(1999, "USD"))
+	assert.Equal(t, 9.5, stripeAmountFromMinor(950, "EUR"))
+	assert.Equal(t, 1500.0, stripeAmountFromMinor(1500, "JPY"))
+	assert.Equal(t, 0.0, stripeAmountFromMinor(0, "USD"))
+}
@@ -23,7 +23,11 @@ func RegisterScheduledSystemTasks() {
 	service.RegisterSystemTaskHandler(channelHealthProbeHandler{})
 	service.RegisterSystemTaskHandler(modelUpdateHandler{})
 	service.RegisterSystemTaskHandler(midjourneyPollHandler{})
+	service.RegisterSystemTaskHandler(midjourneyRefetchHandler{})
 	service.RegisterSystemTaskHandler(asyncTaskPollHandler{})
+	service.RegisterSystemTaskHandler(statementHandler{})
+	service.RegisterSystemTaskHandler(postpaidDunningHandler{})
+	service.RegisterSystemTaskHandler(priceBookHandler{})
 }
 
 type channelHealthProbeHandler struct{}
@@ -168,6 +172,22 @@ func (midjourneyPollHandler) Run(ctx context.Context, task *model.SystemTask, ru
 	finishSystemTaskHandler(task, runnerID, model.SystemTaskStatusSucceeded, summary, nil)
 }
 
+// midjourneyRefetchHandler runs an admin-requested Midjourney re-fetch. It is
+// not scheduled: rows are only created through RefetchMidjourneyTasks.
+type midjourneyRefetchHandler struct{}
+
+func (midjourneyRefetchHandler) Type() string { return model.SystemTaskTypeMidjourneyRefetch }
+
+func (midjourneyRefetchHandler) Run(ctx context.Context, task *model.SystemTask, runnerID string) {
+	payload := midjourneyRefetchPayload{}
+	if err := task.DecodePayload(&payload); err != nil {
+		finishSystemTaskHandler(task, runnerID, model.SystemTaskStatusFailed, nil, err)
+		return
+	}
+	summary := runMidjourneyRefetchTask(ctx, payload, service.NewSystemTaskProgressReporter(task, runnerID))
+	finishSystemTaskHandler(task, runnerID, model.SystemTaskStatusSucceeded, summary, nil)
+}
+
 // asyncTaskPollHandler runs one async-task (Suno/video) polling pass per
 // scheduled run. Like midjourneyPollHandler, Enabled() folds in the unfinished
 // task existence check so an idle system schedules no rows.
@@ -188,6 +208,106 @@ func (asyncTaskPollHandler) Run(ctx context.Context, task *model.SystemTask, run
 	finishSystemTaskHandler(task, runnerID, model.SystemTaskStatusSucceeded, summary, nil)
 }
 
+// statementHandler generates last month's statements. Enabled() only reports
+// true until the previous month has been generated by this instance, so the
+// hourly check stays cheap; a restart triggers one extra pass, which skips
+// statements that already exist. Admins can enqueue a run for an explicit
+// period through statementTaskPayload.
+type statementHandler struct{}
+
+var statementGeneratedPeriod atomic.Int64
+
+type statementTaskPayload struct {
+	PeriodStart int64 `json:"period_start,omitempty"`
+	PeriodEnd   int64 `json:"period_end,omitempty"`
+}
+
+func (statementHandler) Type() string { return model.SystemTaskTypeStatement }
+
+func (statementHandler) Enabled() bool {
+	if !operation_setting.GetStatementSetting().Enabled {
+		return false
+	}
+	start, _ := service.PreviousStatementPeriod(time.Now())
+	return statementGeneratedPeriod.Load() != start
+}
+
+func (statementHandler) Interval() time.Duration { return time.Hour }
+
+func (statementHandler) NewPayload() any { return nil }
+
+func (statementHandler) Run(ctx context.Context, task *model.SystemTask, runnerID string) {
+	payload := statementTaskPayload{}
+	if err := task.DecodePayload(&payload); err != nil {
+		finishSystemTaskHandler(task, runnerID, model.SystemTaskStatusFailed, nil, err)
+		return
+	}
+	scheduled := payload.PeriodStart == 0 && payload.PeriodEnd == 0
+	if scheduled {
+		payload.PeriodStart, payload.PeriodEnd = service.PreviousStatementPeriod(time.Now())
+	}
+	summary, err := service.GenerateStatementsForPeriod(ctx, payload.PeriodStart, payload.PeriodEnd, service.NewSystemTaskProgressReporter(task, runnerID))
+	if err != nil {
+		finishSystemTaskHandler(task, runnerID, model.SystemTaskStatusFailed, summary, err)
+		return
+	}
+	if scheduled && summary.Failed == 0 {
+		statementGeneratedPeriod.Store(payload.PeriodStart)
+	}
+	finishSystemTaskHandler(task, runnerID, model.SystemTaskStatusSucceeded, summary, nil)
+}
+
+// postpaidDunningHandler closes due postpaid billing cycles, clears settled
+// balances and escalates dunning. Like the polling handlers, Enabled() folds
+// in an existence check so installations without postpaid accounts schedule
+// no rows.
+type postpaidDunningHandler struct{}
+
+func (postpaidDunningHandler) Type() string { return model.SystemTaskTypePostpaidDunning }
+
+func (postpaidDunningHandler) Enabled() bool {
+	return operation_setting.GetPostpaidSetting().Enabled && model.HasPostpaidAccounts()
+}
+
+func (postpaidDunningHandler) Interval() time.Duration { return 10 * time.Minute }
+
+func (postpaidDunningHandler) NewPayload() any { return nil }
+
+func (postpaidDunningHandler) Run(ctx context.Context, task *model.SystemTask, runnerID string) {
+	summary, err := service.RunPostpaidDunningOnce(ctx, service.NewSystemTaskProgressReporter(task, runnerID))
+	if err != nil {
+		finishSystemTaskHandler(task, runnerID, model.SystemTaskStatusFailed, summary, err)
+		return
+	}
+	finishSystemTaskHandler(task, runnerID, model.SystemTaskStatusSucceeded, summary, nil)
+}
+
+// priceBookHandler applies scheduled price books once their effective time
+// has passed. It only schedules rows while a pending price book exists.
+type priceBookHandler struct{}
+
+func (priceBookHandler) Type() string { return model.SystemTaskTypePriceBook }
+
+func (priceBookHandler) Enabled() bool { return model.HasPendingPriceBooks() }
+
+func (priceBookHandler) Interval() time.Duration { return time.Minute }
+
+func (priceBookHandler) NewPayload() any { return nil }
+
+func (priceBookHandler) Run(ctx context.Context, task *model.SystemTask, runnerID string) {
+	book, err := model.ApplyDuePriceBooks(common.GetTimestamp())
+	if err != nil {
+		finishSystemTaskHandler(task, runnerID, model.SystemTaskStatusFailed, nil, err)
+		return
+	}
+	result := map[string]int{"applied_version": 0}
+	if book != nil {
+		result["applied_version"] = book.Id
+		common.SysLog(fmt.Sprintf("price book %d applied", book.Id))
+	}
+	finishSystemTaskHandler(task, runnerID, model.SystemTaskStatusSucceeded, result, nil)
+}
+
 func finishSystemTaskHandler(task *model.SystemTask, runnerID string, status model.SystemTaskStatus, result any, runErr error) {
 	errorMessage := ""
 	if runErr != nil {
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// taskCallbackMaxBodyBytes 回调请求体上限，任务状态通知远小于此值
+const taskCallbackMaxBodyBytes = 4 << 20
+
+// TaskUpstreamCallback 接收上游推送的异步任务状态，地址中的签名由网关在提交时生成
+func TaskUpstreamCallback(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !operation_setting.GetTaskCallbackSetting().Enabled {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+	channelId, err := strconv.Atoi(c.Param("channel_id"))
+	taskId := c.Param("task_id")
+	if err != nil || !service.VerifyTaskUpstreamCallback(channelId, taskId, c.Param("sig")) {
+		logger.LogWarn(ctx, fmt.Sprintf("task callback 验签失败 path=%q client_ip=%s", c.Request.URL.Path, c.ClientIP()))
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, taskCallbackMaxBodyBytes))
+	if err != nil {
+		if common.IsRequestBodyTooLargeError(err) {
+			c.AbortWithStatus(http.StatusRequestEntityTooLarge)
+			return
+		}
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	reply, err := service.HandleTaskUpstreamCallback(ctx, channelId, taskId, body)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("task callback 处理失败 task_id=%s channel_id=%d error=%q", taskId, channelId, err.Error()))
+		switch {
+		case errors.Is(err, service.ErrTaskCallbackNotFound):
+			c.AbortWithStatus(http.StatusNotFound)
+		case errors.Is(err, service.ErrTaskCallbackUnsupported), errors.Is(err, service.ErrTaskCallbackMismatch):
+			c.AbortWithStatus(http.StatusBadRequest)
+		default:
+			// 返回 5xx 让上游按自身策略重试，兜底轮询也会继续
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}
+		return
+	}
+	if len(reply) > 0 {
+		c.Data(http.StatusOK, "application/json", reply)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
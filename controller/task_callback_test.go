@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskUpstreamCallbackRejectsOversizedBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setting := operation_setting.GetTaskCallbackSetting()
+	previousEnabled := setting.Enabled
+	previousAddress := system_setting.ServerAddress
+	setting.Enabled = true
+	system_setting.ServerAddress = "https://gateway.example.com"
+	t.Cleanup(func() {
+		setting.Enabled = previousEnabled
+		system_setting.ServerAddress = previousAddress
+	})
+
+	callbackURL, err := url.Parse(service.BuildTaskUpstreamCallbackURL(3, "task_large"))
+	require.NoError(t, err)
+	router := gin.New()
+	router.POST("/api/task/callback/:channel_id/:task_id/:sig", TaskUpstreamCallback)
+
+	body := bytes.Repeat([]byte("a"), taskCallbackMaxBodyBytes+1)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, callbackURL.Path, bytes.NewReader(body)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+}
@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func listTaskWebhookDeliveries(c *gin.Context, userId int) {
+	pageInfo := common.GetPageQuery(c)
+	deliveries, total, err := model.GetTaskWebhookDeliveries(userId, c.Query("task_id"), c.Query("status"),
+		pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(deliveries)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetUserTaskWebhookDeliveries 当前用户的任务回调投递记录
+func GetUserTaskWebhookDeliveries(c *gin.Context) {
+	listTaskWebhookDeliveries(c, c.GetInt("id"))
+}
+
+// GetAllTaskWebhookDeliveries 所有用户的任务回调投递记录，可按 user_id 过滤
+func GetAllTaskWebhookDeliveries(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	listTaskWebhookDeliveries(c, userId)
+}
+
+// RetryUserTaskWebhookDelivery 重新投递当前用户一条已结束的回调
+func RetryUserTaskWebhookDelivery(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err := model.RetryTaskWebhookDelivery(id, c.GetInt("id")); err != nil {
+		if errors.Is(err, model.ErrTaskWebhookDeliveryNotFound) {
+			common.ApiErrorI18n(c, i18n.MsgTaskWebhookNotFound)
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
@@ -307,6 +307,35 @@ func AddToken(c *gin.Context) {
 		token.CrossGroupRetry = false
 		_ = token.SetAutoGroups(nil)
 	}
+	// 组织令牌：仅组织成员可创建，且组织必须处于启用状态
+	if token.OrgId > 0 {
+		if !checkTokenOrganization(c, token.OrgId) {
+			return
+		}
+	}
+	allowedProjects, err := model.NormalizeAllowedProjects(token.AllowedProjects)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	callbackUrl, err := service.ValidateTaskCallbackURL(token.CallbackUrl)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// 配置回调地址时确保用户已有签名密钥，回调从第一次起就带签名
+	if callbackUrl != "" {
+		if _, err := model.EnsureUserTaskCallbackSecret(c.GetInt("id")); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+	budgetToken := model.Token{}
+	if err := budgetToken.SetBudget(token.BudgetAmount, token.BudgetPeriod, token.BudgetTimezone,
+		token.BudgetSoftPercent, token.BudgetHardPercent); err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	key, err := common.GenerateKey()
 	if err != nil {
 		common.ApiErrorI18n(c, i18n.MsgTokenGenerateFailed)
@@ -328,6 +357,16 @@ func AddToken(c *gin.Context) {
 		Group:              token.Group,
 		CrossGroupRetry:    token.CrossGroupRetry,
 		AutoGroups:         token.AutoGroups,
+		OrgId:              token.OrgId,
+		BudgetAmount:       budgetToken.BudgetAmount,
+		BudgetPeriod:       budgetToken.BudgetPeriod,
+		BudgetTimezone:     budgetToken.BudgetTimezone,
+		BudgetSoftPercent:  budgetToken.BudgetSoftPercent,
+		BudgetHardPercent:  budgetToken.BudgetHardPercent,
+		BudgetPeriodStart:  budgetToken.BudgetPeriodStart,
+		BudgetResetTime:    budgetToken.BudgetResetTime,
+		AllowedProjects:    allowedProjects,
+		CallbackUrl:        callbackUrl,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -340,6 +379,23 @@ func AddToken(c *gin.Context) {
 	})
 }
 
+func checkTokenOrganization(c *gin.Context, orgId int) bool {
+	if _, err := model.GetOrganizationMember(orgId, c.GetInt("id")); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgOrganizationNotMember)
+		return false
+	}
+	org, err := model.GetOrganizationById(orgId)
+	if err != nil {
+		common.ApiError(c, err)
+		return false
+	}
+	if org.Status != model.OrganizationStatusEnabled {
+		common.ApiErrorI18n(c, i18n.MsgOrganizationDisabled)
+		return false
+	}
+	return true
+}
+
 func DeleteToken(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	userId := c.GetInt("id")
@@ -407,6 +463,29 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
 		cleanToken.CrossGroupRetry = token.CrossGroupRetry
+		allowedProjects, err := model.NormalizeAllowedProjects(token.AllowedProjects)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		cleanToken.AllowedProjects = allowedProjects
+		callbackUrl, err := service.ValidateTaskCallbackURL(token.CallbackUrl)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		if callbackUrl != "" {
+			if _, err := model.EnsureUserTaskCallbackSecret(cleanToken.UserId); err != nil {
+				common.ApiError(c, err)
+				return
+			}
+		}
+		cleanToken.CallbackUrl = callbackUrl
+		if err := cleanToken.SetBudget(token.BudgetAmount, token.BudgetPeriod, token.BudgetTimezone,
+			token.BudgetSoftPercent, token.BudgetHardPercent); err != nil {
+			common.ApiError(c, err)
+			return
+		}
 		if token.Group != "auto" {
 			cleanToken.CrossGroupRetry = false
 			_ = cleanToken.SetAutoGroups(nil)
@@ -120,6 +120,9 @@ func GetTopUpInfo(c *gin.Context) {
 		"amount_options":          operation_setting.GetPaymentSetting().AmountOptions,
 		"discount":                operation_setting.GetPaymentSetting().AmountDiscount,
 		"topup_link":              common.TopUpLink,
+		"currencies":              service.ListCurrencyOptions(),
+		"epay_currency":           getEpayCurrency(),
+		"stripe_currency":         getStripeCurrency(),
 	}
 	common.ApiSuccess(c, data)
 }
@@ -162,7 +165,7 @@ func getPayMoney(amount int64, group string) float64 {
 	}
 
 	dTopupGroupRatio := decimal.NewFromFloat(topupGroupRatio)
-	dPrice := decimal.NewFromFloat(operation_setting.Price)
+	dPrice := decimal.NewFromFloat(service.GetTopUpUnitPrice(getEpayCurrency(), operation_setting.Price))
 	// apply optional preset discount by the original request amount (if configured), default 1.0
 	discount := 1.0
 	if ds, ok := operation_setting.GetPaymentSetting().AmountDiscount[int(amount)]; ok {
@@ -177,6 +180,11 @@ func getPayMoney(amount int64, group string) float64 {
 	return payMoney.InexactFloat64()
 }
 
+// getEpayCurrency 易支付的结算币种，默认人民币
+func getEpayCurrency() string {
+	return operation_setting.GetCurrencySetting().GetProviderCurrency(model.PaymentProviderEpay, "CNY")
+}
+
 func getMinTopup() int64 {
 	minTopup := operation_setting.MinTopUp
 	if operation_setting.GetQuotaDisplayType() == operation_setting.QuotaDisplayTypeTokens {
@@ -325,6 +333,7 @@ func RequestEpay(c *gin.Context) {
 		dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
 		amount = dAmount.Div(dQuotaPerUnit).IntPart()
 	}
+	currency, exchangeRate := service.OrderCurrency(getEpayCurrency())
 	topUp := &model.TopUp{
 		UserId:          id,
 		Amount:          amount,
@@ -334,6 +343,8 @@ func RequestEpay(c *gin.Context) {
 		PaymentProvider: model.PaymentProviderEpay,
 		CreateTime:      time.Now().Unix(),
 		Status:          common.TopUpStatusPending,
+		Currency:        currency,
+		ExchangeRate:    exchangeRate,
 	}
 	err = topUp.Insert()
 	if err != nil {
@@ -13,6 +13,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 
@@ -116,6 +117,7 @@ func (*StripeAdaptor) RequestPay(c *gin.Context, req *StripePayRequest) {
 		return
 	}
 
+	currency, exchangeRate := service.OrderCurrency(getStripeCurrency())
 	topUp := &model.TopUp{
 		UserId:          id,
 		Amount:          req.Amount,
@@ -125,6 +127,8 @@ func (*StripeAdaptor) RequestPay(c *gin.Context, req *StripePayRequest) {
 		PaymentProvider: model.PaymentProviderStripe,
 		CreateTime:      time.Now().Unix(),
 		Status:          common.TopUpStatusPending,
+		Currency:        currency,
+		ExchangeRate:    exchangeRate,
 	}
 	err = topUp.Insert()
 	if err != nil {
@@ -438,10 +442,15 @@ func getStripePayMoney(amount float64, group string) float64 {
 			discount = ds
 		}
 	}
-	payMoney := amount * setting.StripeUnitPrice * topupGroupRatio * discount
+	payMoney := amount * service.GetTopUpUnitPrice(getStripeCurrency(), setting.StripeUnitPrice) * topupGroupRatio * discount
 	return payMoney
 }
 
+// getStripeCurrency 返回 Stripe 价格对应的币种，需与 StripePriceId 的币种一致
+func getStripeCurrency() string {
+	return operation_setting.GetCurrencySetting().GetProviderCurrency(model.PaymentProviderStripe, "USD")
+}
+
 func getStripeMinTopup() int64 {
 	minTopup := setting.StripeMinTopUp
 	if operation_setting.GetQuotaDisplayType() == operation_setting.QuotaDisplayTypeTokens {
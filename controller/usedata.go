@@ -1,8 +1,11 @@
 package controller
 
 import (
+	"encoding/csv"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
@@ -129,3 +132,126 @@ func GetUserFlowQuotaDates(c *gin.Context) {
 	})
 	return
 }
+
+var projectUsageCSVHeader = []string{"date", "project", "model_name", "token_id", "token_name", "count", "token_used", "quota", "amount"}
+
+func projectUsageFilterFromQuery(c *gin.Context) (model.ProjectUsageFilter, bool) {
+	startTimestamp, endTimestamp, ok := parseFlowQuotaTimeRange(c)
+	if !ok {
+		return model.ProjectUsageFilter{}, false
+	}
+	tokenId, _ := strconv.Atoi(c.Query("token_id"))
+	return model.ProjectUsageFilter{
+		StartTime: startTimestamp,
+		EndTime:   endTimestamp,
+		Project:   c.Query("project"),
+		ModelName: c.Query("model_name"),
+		TokenID:   tokenId,
+	}, true
+}
+
+// GetProjectUsageReport 按项目、模型、令牌、天汇总用量，format=csv 时导出 CSV。
+func GetProjectUsageReport(c *gin.Context) {
+	filter, ok := projectUsageFilterFromQuery(c)
+	if !ok {
+		return
+	}
+	filter.Username = c.Query("username")
+	respondProjectUsageReport(c, filter)
+}
+
+func GetSelfProjectUsageReport(c *gin.Context) {
+	filter, ok := projectUsageFilterFromQuery(c)
+	if !ok {
+		return
+	}
+	filter.UserID = c.GetInt("id")
+	respondProjectUsageReport(c, filter)
+}
+
+func respondProjectUsageReport(c *gin.Context, filter model.ProjectUsageFilter) {
+	rows, err := model.GetProjectUsageReport(filter)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if c.Query("format") != "csv" {
+		common.ApiSuccess(c, rows)
+		return
+	}
+	writeCSVReport(c, "project-usage", filter.StartTime, filter.EndTime, projectUsageCSVHeader, rows, func(row *model.ProjectUsage) []string {
+		return []string{
+			time.Unix(row.Day, 0).UTC().Format("2006-01-02"),
+			row.Project,
+			row.ModelName,
+			strconv.Itoa(row.TokenID),
+			row.TokenName,
+			strconv.Itoa(row.Count),
+			strconv.Itoa(row.TokenUsed),
+			strconv.Itoa(row.Quota),
+			quotaAmountString(row.Quota),
+		}
+	})
+}
+
+// writeCSVReport 以附件形式导出按天汇总的 CSV 报表，文件名带报表的起止日期
+func writeCSVReport[T any](c *gin.Context, name string, startTime int64, endTime int64, header []string, rows []T, record func(T) []string) {
+	filename := fmt.Sprintf("%s-%s-%s.csv", name,
+		time.Unix(startTime, 0).UTC().Format("20060102"), time.Unix(endTime, 0).UTC().Format("20060102"))
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	writer := csv.NewWriter(c.Writer)
+	_ = writer.Write(header)
+	for _, row := range rows {
+		_ = writer.Write(record(row))
+	}
+	writer.Flush()
+}
+
+// quotaAmountString 把额度换算为金额
+func quotaAmountString(quota int) string {
+	return strconv.FormatFloat(float64(quota)/common.QuotaPerUnit, 'f', 6, 64)
+}
+
+var channelMarginCSVHeader = []string{"date", "channel_id", "channel_name", "model_name", "count", "quota", "costed_quota", "upstream_cost", "margin", "revenue_amount", "cost_amount", "margin_amount"}
+
+// GetChannelMarginReport 按渠道、模型、天汇总收入、上游成本与毛利，format=csv 时导出 CSV。
+func GetChannelMarginReport(c *gin.Context) {
+	startTimestamp, endTimestamp, ok := parseFlowQuotaTimeRange(c)
+	if !ok {
+		return
+	}
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	filter := model.ChannelMarginFilter{
+		StartTime: startTimestamp,
+		EndTime:   endTimestamp,
+		ChannelID: channelId,
+		ModelName: c.Query("model_name"),
+	}
+	rows, err := model.GetChannelMarginReport(filter)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if c.Query("format") != "csv" {
+		common.ApiSuccess(c, rows)
+		return
+	}
+	writeCSVReport(c, "channel-margin", filter.StartTime, filter.EndTime, channelMarginCSVHeader, rows, func(row *model.ChannelMargin) []string {
+		return []string{
+			time.Unix(row.Day, 0).UTC().Format("2006-01-02"),
+			strconv.Itoa(row.ChannelID),
+			row.ChannelName,
+			row.ModelName,
+			strconv.Itoa(row.Count),
+			strconv.Itoa(row.Quota),
+			strconv.Itoa(row.CostedQuota),
+			strconv.Itoa(row.UpstreamCost),
+			strconv.Itoa(row.Margin),
+			quotaAmountString(row.Quota),
+			quotaAmountString(row.UpstreamCost),
+			quotaAmountString(row.Margin),
+		}
+	})
+}
@@ -509,6 +509,7 @@ func buildSelfUserData(user *model.User) map[string]interface{} {
 	userSetting := user.GetSetting()
 	permissions := calculateUserPermissions(user.Role)
 	permissions["admin_permissions"] = authz.Capabilities(user.Id, user.Role)
+	balance, _ := service.QuotaInCurrency(user.Quota, service.ResolveUserCurrency(userSetting.Currency))
 	return map[string]interface{}{
 		"id":                user.Id,
 		"username":          user.Username,
@@ -534,6 +535,7 @@ func buildSelfUserData(user *model.User) map[string]interface{} {
 		"setting":           user.Setting,
 		"stripe_customer":   user.StripeCustomer,
 		"sidebar_modules":   userSetting.SidebarModules, // 正确提取sidebar_modules字段
+		"balance":           balance,
 		"permissions":       permissions,
 	}
 }
@@ -839,6 +841,32 @@ func UpdateSelf(c *gin.Context) {
 		return
 	}
 
+	// 检查是否是展示币种更新请求
+	if currency, currencyExists := requestData["currency"]; currencyExists {
+		currencyStr, _ := currency.(string)
+		currencyStr = operation_setting.NormalizeCurrency(currencyStr)
+		if currencyStr != "" && !service.IsSupportedCurrency(currencyStr) {
+			common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+			return
+		}
+		userId := c.GetInt("id")
+		user, err := model.GetUserById(userId, false)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+
+		currentSetting := user.GetSetting()
+		currentSetting.Currency = currencyStr
+		if err := model.UpdateUserSetting(user.Id, currentSetting); err != nil {
+			common.ApiErrorI18n(c, i18n.MsgUpdateFailed)
+			return
+		}
+
+		common.ApiSuccessI18n(c, i18n.MsgUpdateSuccess, nil)
+		return
+	}
+
 	// 原有的用户信息更新逻辑
 	var user model.User
 	requestDataBytes, err := common.Marshal(requestData)
@@ -1167,7 +1195,7 @@ func ManageUser(c *gin.Context) {
 				common.ApiErrorI18n(c, i18n.MsgUserQuotaChangeZero)
 				return
 			}
-			if err := model.IncreaseUserQuota(user.Id, req.Value, true); err != nil {
+			if err := model.GrantUserQuota(user.Id, req.Value, model.CreditSourceAdjustment, ""); err != nil {
 				common.ApiError(c, err)
 				return
 			}
@@ -1179,7 +1207,7 @@ func ManageUser(c *gin.Context) {
 				common.ApiErrorI18n(c, i18n.MsgUserQuotaChangeZero)
 				return
 			}
-			if err := model.DecreaseUserQuota(user.Id, req.Value, true); err != nil {
+			if err := model.DecreaseUserQuota(user.Id, req.Value, true, model.CreditRef{Source: model.CreditSourceAdjustment}); err != nil {
 				common.ApiError(c, err)
 				return
 			}
@@ -1188,7 +1216,7 @@ func ManageUser(c *gin.Context) {
 			})
 		case "override":
 			oldQuota := user.Quota
-			if err := model.DB.Model(&model.User{}).Where("id = ?", user.Id).Update("quota", req.Value).Error; err != nil {
+			if err := model.OverrideUserQuota(user.Id, req.Value); err != nil {
 				common.ApiError(c, err)
 				return
 			}
@@ -1509,6 +1537,8 @@ func UpdateUserSetting(c *gin.Context) {
 			settings.WebhookSecret = req.WebhookSecret
 		}
 	}
+	// 任务回调密钥不在通知设置中编辑，保存时保留
+	settings.TaskCallbackSecret = existingSettings.TaskCallbackSecret
 
 	// 如果提供了通知邮箱，添加到设置中
 	if req.QuotaWarningType == dto.NotifyTypeEmail && req.NotificationEmail != "" {
@@ -3,6 +3,7 @@ package controller
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -55,6 +56,18 @@ func VideoProxy(c *gin.Context) {
 		return
 	}
 
+	// 结果已转存时直接读取网关存储，不依赖上游地址是否仍然有效
+	if task.PrivateData.MediaAssetId > 0 {
+		asset, err := model.GetMediaAssetById(task.PrivateData.MediaAssetId, task.UserId)
+		if err == nil {
+			writeMediaAsset(c, asset)
+			return
+		}
+		if !errors.Is(err, model.ErrMediaAssetNotFound) {
+			logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to query media asset for task %s: %s", taskID, err.Error()))
+		}
+	}
+
 	channel, err := model.CacheGetChannel(task.ChannelId)
 	if err != nil {
 		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to get channel for task %s: %s", taskID, err.Error()))
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	videoListDefaultLimit = 20
+	videoListMaxLimit     = 100
+)
+
+// getUserVideoTask 查询当前用户的任务，不存在时已写入 404 响应
+func getUserVideoTask(c *gin.Context, taskID string) (*model.Task, bool) {
+	task, exists, err := model.GetByTaskId(c.GetInt("id"), taskID)
+	if err != nil {
+		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to query task %s: %s", taskID, err.Error()))
+		videoProxyError(c, http.StatusInternalServerError, "server_error", "Failed to query task")
+		return nil, false
+	}
+	if !exists || task == nil {
+		videoProxyError(c, http.StatusNotFound, "invalid_request_error", "Video not found")
+		return nil, false
+	}
+	return task, true
+}
+
+// openAIVideoJSON 转换为 OpenAI Video 对象；平台未实现转换时使用通用字段
+func openAIVideoJSON(task *model.Task) []byte {
+	if data, taskErr := relay.TaskToOpenAIVideo(task); taskErr == nil {
+		return data
+	}
+	data, _ := common.Marshal(task.ToOpenAIVideo())
+	return data
+}
+
+// VideoList GET /v1/videos，按 after 游标分页列出当前用户的视频任务
+func VideoList(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = videoListDefaultLimit
+	}
+	limit = min(limit, videoListMaxLimit)
+	ascending := c.Query("order") == "asc"
+
+	userID := c.GetInt("id")
+	var afterID int64
+	if after := c.Query("after"); after != "" {
+		task, ok := getUserVideoTask(c, after)
+		if !ok {
+			return
+		}
+		afterID = task.ID
+	}
+
+	tasks, err := model.GetUserVideoTasks(userID, afterID, limit+1, ascending)
+	if err != nil {
+		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to list videos for user %d: %s", userID, err.Error()))
+		videoProxyError(c, http.StatusInternalServerError, "server_error", "Failed to list videos")
+		return
+	}
+	list := dto.OpenAIVideoList{Object: "list", Data: []json.RawMessage{}}
+	if len(tasks) > limit {
+		tasks = tasks[:limit]
+		list.HasMore = true
+	}
+	for _, task := range tasks {
+		list.Data = append(list.Data, openAIVideoJSON(task))
+	}
+	if len(tasks) > 0 {
+		list.FirstID = tasks[0].TaskID
+		list.LastID = tasks[len(tasks)-1].TaskID
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// VideoCancel POST /v1/videos/:video_id/cancel，取消未完成的任务并退还预扣额度
+func VideoCancel(c *gin.Context) {
+	task, ok := getUserVideoTask(c, c.Param("video_id"))
+	if !ok {
+		return
+	}
+	if err := service.CancelTask(c.Request.Context(), task); err != nil {
+		switch {
+		case errors.Is(err, service.ErrTaskAlreadyFinished):
+			videoProxyError(c, http.StatusConflict, "invalid_request_error", "Video has already finished")
+		case errors.Is(err, service.ErrTaskCancelUnsupported):
+			videoProxyError(c, http.StatusBadRequest, "invalid_request_error", "Cancellation is not supported for this video")
+		case errors.Is(err, service.ErrTaskCancelRejected):
+			videoProxyError(c, http.StatusConflict, "invalid_request_error", "Upstream rejected the cancellation")
+		default:
+			logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to cancel task %s: %s", task.TaskID, err.Error()))
+			videoProxyError(c, http.StatusBadGateway, "server_error", "Failed to cancel video")
+		}
+		return
+	}
+	c.Data(http.StatusOK, "application/json", openAIVideoJSON(task))
+}
+
+// VideoDelete DELETE /v1/videos/:task_id，删除已结束的任务及其转存文件
+func VideoDelete(c *gin.Context) {
+	task, ok := getUserVideoTask(c, c.Param("task_id"))
+	if !ok {
+		return
+	}
+	if err := service.DeleteTask(c.Request.Context(), task); err != nil {
+		if errors.Is(err, service.ErrTaskNotFinished) {
+			videoProxyError(c, http.StatusConflict, "invalid_request_error", "Video is still in progress, cancel it first")
+			return
+		}
+		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to delete task %s: %s", task.TaskID, err.Error()))
+		videoProxyError(c, http.StatusInternalServerError, "server_error", "Failed to delete video")
+		return
+	}
+	c.JSON(http.StatusOK, dto.OpenAIVideoDeleted{ID: task.TaskID, Object: "video.deleted", Deleted: true})
+}
@@ -285,20 +285,22 @@ const (
 
 // OAuth related messages
 const (
-	MsgOAuthInvalidCode     = "oauth.invalid_code"
-	MsgOAuthGetUserErr      = "oauth.get_user_error"
-	MsgOAuthAccountUsed     = "oauth.account_used"
-	MsgOAuthUnknownProvider = "oauth.unknown_provider"
-	MsgOAuthStateInvalid    = "oauth.state_invalid"
-	MsgOAuthNotEnabled      = "oauth.not_enabled"
-	MsgOAuthUserDeleted     = "oauth.user_deleted"
-	MsgOAuthUserBanned      = "oauth.user_banned"
-	MsgOAuthBindSuccess     = "oauth.bind_success"
-	MsgOAuthAlreadyBound    = "oauth.already_bound"
-	MsgOAuthConnectFailed   = "oauth.connect_failed"
-	MsgOAuthTokenFailed     = "oauth.token_failed"
-	MsgOAuthUserInfoEmpty   = "oauth.user_info_empty"
-	MsgOAuthTrustLevelLow   = "oauth.trust_level_low"
+	MsgOAuthInvalidCode          = "oauth.invalid_code"
+	MsgOAuthGetUserErr           = "oauth.get_user_error"
+	MsgOAuthAccountUsed          = "oauth.account_used"
+	MsgOAuthUnknownProvider      = "oauth.unknown_provider"
+	MsgOAuthStateInvalid         = "oauth.state_invalid"
+	MsgOAuthNotEnabled           = "oauth.not_enabled"
+	MsgOAuthUserDeleted          = "oauth.user_deleted"
+	MsgOAuthUserBanned           = "oauth.user_banned"
+	MsgOAuthBindSuccess          = "oauth.bind_success"
+	MsgOAuthAlreadyBound         = "oauth.already_bound"
+	MsgOAuthConnectFailed        = "oauth.connect_failed"
+	MsgOAuthTokenFailed          = "oauth.token_failed"
+	MsgOAuthUserInfoEmpty        = "oauth.user_info_empty"
+	MsgOAuthTrustLevelLow        = "oauth.trust_level_low"
+	MsgOAuthSAMLNotConfigured    = "oauth.saml_not_configured"
+	MsgOAuthSAMLAssertionInvalid = "oauth.saml_assertion_invalid"
 )
 
 // Model layer error messages (for translation in controller)
@@ -336,3 +338,44 @@ const (
 	MsgCustomOAuthBindingNotFound   = "custom_oauth.binding_not_found"
 	MsgCustomOAuthProviderIdInvalid = "custom_oauth.provider_id_field_invalid"
 )
+
+// Organization related messages
+const (
+	MsgOrganizationNotFound         = "organization.not_found"
+	MsgOrganizationNotMember        = "organization.not_member"
+	MsgOrganizationDisabled         = "organization.disabled"
+	MsgOrganizationPermissionDenied = "organization.permission_denied"
+	MsgOrganizationNameEmpty        = "organization.name_empty"
+	MsgOrganizationMemberExists     = "organization.member_exists"
+	MsgOrganizationMemberNotFound   = "organization.member_not_found"
+	MsgOrganizationOwnerImmutable   = "organization.owner_immutable"
+	MsgOrganizationInvalidRole      = "organization.invalid_role"
+	MsgOrganizationQuotaInvalid     = "organization.quota_invalid"
+	MsgOrganizationFundFailed       = "organization.fund_failed"
+	MsgOrganizationBalanceNotEmpty  = "organization.balance_not_empty"
+	MsgOrganizationInviteNotFound   = "organization.invite_not_found"
+)
+
+// Reseller related messages
+const (
+	MsgResellerNotFound        = "reseller.not_found"
+	MsgResellerNotReseller     = "reseller.not_reseller"
+	MsgResellerExists          = "reseller.exists"
+	MsgResellerDisabled        = "reseller.disabled"
+	MsgResellerUserLimit       = "reseller.user_limit"
+	MsgResellerHasUsers        = "reseller.has_users"
+	MsgResellerUserNotFound    = "reseller.user_not_found"
+	MsgResellerInvalidMarkup   = "reseller.invalid_markup"
+	MsgResellerTooDeep         = "reseller.too_deep"
+	MsgResellerPaymentDisabled = "reseller.payment_disabled"
+)
+
+// Task webhook related messages
+const (
+	MsgTaskWebhookNotFound = "task_webhook.not_found"
+)
+
+// Media archive related messages
+const (
+	MsgMediaAssetNotFound = "media.not_found"
+)
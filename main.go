@@ -128,6 +128,21 @@ func main() {
 	// Subscription quota reset task (daily/weekly/monthly/custom)
 	service.StartSubscriptionQuotaResetTask()
 
+	// Token periodic budget reset task (daily/weekly/monthly)
+	service.StartTokenBudgetResetTask()
+
+	// Credit ledger: expire promotional grants
+	service.StartCreditGrantExpiryTask()
+
+	// Client completion webhooks for async tasks (retry queue)
+	service.StartTaskWebhookDeliveryTask()
+
+	// Media archive retention (expired generated videos, images and audio)
+	service.StartMediaRetentionTask()
+
+	// Audit log retention (independent of the log cleanup task)
+	service.StartAuditLogRetentionTask()
+
 	// Report this process as a system instance so the System Info page can show
 	// all currently alive nodes in multi-instance deployments.
 	service.StartSystemInstanceReporter()
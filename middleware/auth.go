@@ -479,6 +479,12 @@ func TokenAuth() func(c *gin.Context) {
 		if err != nil {
 			return
 		}
+		if project := c.Request.Header.Get(service.ProjectHeader); project != "" {
+			if err := service.ApplyProjectTag(c, project); err != nil {
+				abortWithOpenAiMessage(c, http.StatusForbidden, err.Error(), types.ErrorCodeAccessDenied)
+				return
+			}
+		}
 		c.Next()
 	}
 }
@@ -503,6 +509,10 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 	}
 	common.SetContextKey(c, constant.ContextKeyTokenGroup, token.Group)
 	common.SetContextKey(c, constant.ContextKeyTokenCrossGroupRetry, token.CrossGroupRetry)
+	common.SetContextKey(c, constant.ContextKeyTokenOrgId, token.OrgId)
+	common.SetContextKey(c, constant.ContextKeyTokenBudgeted, token.HasBudget())
+	common.SetContextKey(c, constant.ContextKeyTokenAllowedProjects, token.AllowedProjects)
+	common.SetContextKey(c, constant.ContextKeyTokenCallbackUrl, token.CallbackUrl)
 	if token.AutoGroups != "" {
 		autoGroups, err := token.GetAutoGroups()
 		if err != nil {
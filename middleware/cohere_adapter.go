@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/relaykit/relayconvert"
+	"github.com/QuantumNous/new-api/relaykit/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	cohereEndpointChat   = "/v2/chat"
+	cohereEndpointEmbed  = "/v2/embed"
+	cohereEndpointRerank = "/v2/rerank"
+)
+
+var cohereCodec = &nativeAPICodec{
+	name:              "cohere",
+	format:            types.RelayFormatCohere,
+	streamContentType: "text/event-stream",
+	// Cohere v2 流以事件类型作为 SSE event 名，且没有 [DONE] 结束标记
+	encodeStreamValue: func(value any) ([]byte, error) {
+		data, err := common.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if event, ok := value.(*dto.CohereStreamEvent); ok {
+			return []byte("event: " + event.Type + "\ndata: " + string(data) + "\n\n"), nil
+		}
+		return []byte("data: " + string(data) + "\n\n"), nil
+	},
+	errorBody: func(_ int, message string) any {
+		return dto.CohereErrorResponse{Message: message}
+	},
+}
+
+var cohereEndpoints = map[string]*nativeAPIEndpoint{
+	cohereEndpointChat: {
+		targetPath: "/v1/chat/completions",
+		convertRequest: func(c *gin.Context) (any, string, bool, error) {
+			chatRequest := &dto.CohereChatRequest{}
+			if err := bindNativeAPIRequest(c, chatRequest); err != nil {
+				return nil, "", false, err
+			}
+			return convertNativeAPITextRequest(c, chatRequest.Model, chatRequest)
+		},
+		convertResponse: func(c *gin.Context, body []byte) (any, error) {
+			return convertNativeAPIChatResponse(c, body, types.RelayFormatCohere)
+		},
+	},
+	cohereEndpointEmbed: {
+		targetPath: "/v1/embeddings",
+		convertRequest: func(c *gin.Context) (any, string, bool, error) {
+			embedRequest := &dto.CohereEmbedRequest{}
+			if err := bindNativeAPIRequest(c, embedRequest); err != nil {
+				return nil, "", false, err
+			}
+			embeddingRequest, err := relayconvert.CohereEmbedRequestToOpenAI(embedRequest)
+			if err != nil {
+				return nil, "", false, err
+			}
+			return embeddingRequest, embedRequest.Model, false, nil
+		},
+		convertResponse: func(_ *gin.Context, body []byte) (any, error) {
+			var response dto.OpenAIEmbeddingResponse
+			if err := common.Unmarshal(body, &response); err != nil {
+				return nil, err
+			}
+			return relayconvert.OpenAIEmbeddingResponseToCohere(&response), nil
+		},
+	},
+	cohereEndpointRerank: {
+		targetPath: "/v1/rerank",
+		convertRequest: func(c *gin.Context) (any, string, bool, error) {
+			rerankRequest := &dto.CohereRerankRequest{}
+			if err := bindNativeAPIRequest(c, rerankRequest); err != nil {
+				return nil, "", false, err
+			}
+			return relayconvert.CohereRerankRequestToRerank(rerankRequest), rerankRequest.Model, false, nil
+		},
+		convertResponse: func(_ *gin.Context, body []byte) (any, error) {
+			var response dto.RerankResponse
+			if err := common.Unmarshal(body, &response); err != nil {
+				return nil, err
+			}
+			return relayconvert.RerankResponseToCohere(&response), nil
+		},
+	},
+}
+
+// CohereRequestConvert 让网关以 Cohere v2 原生 API 对外提供服务：
+// 将 /v2/chat、/v2/embed、/v2/rerank 请求转换为 OpenAI 格式并改写路径，
+// 响应（含 SSE 流）转换回 Cohere v2 格式。须挂载在 TokenAuth 与 Distribute 之前。
+func CohereRequestConvert() func(c *gin.Context) {
+	return nativeAPIRequestConvert(cohereCodec, cohereEndpoints)
+}
@@ -376,6 +376,12 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 				modelRequest.Model = req.Model
 			}
 		}
+	} else if strings.HasPrefix(c.Request.URL.Path, "/v1/images/variations") {
+		// 变体请求为 multipart 表单，model 可省略（与 OpenAI 一致默认 dall-e-2）
+		if req, err := getModelFromRequest(c); err == nil && req.Model != "" {
+			modelRequest.Model = req.Model
+		}
+		modelRequest.Model = common.GetStringIfEmpty(modelRequest.Model, "dall-e-2")
 	}
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/audio") {
 		relayMode := relayconstant.RelayModeAudioSpeech
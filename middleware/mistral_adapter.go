@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/relaykit/relayconvert"
+	"github.com/QuantumNous/new-api/relaykit/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	mistralEndpointChat       = "/mistral/v1/chat/completions"
+	mistralEndpointEmbeddings = "/mistral/v1/embeddings"
+)
+
+var mistralCodec = &nativeAPICodec{
+	name:              "mistral",
+	format:            types.RelayFormatMistral,
+	streamContentType: "text/event-stream",
+	encodeStreamValue: func(value any) ([]byte, error) {
+		data, err := common.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return []byte("data: " + string(data) + "\n\n"), nil
+	},
+	streamTerminator: []byte("data: [DONE]\n\n"),
+	errorBody: func(status int, message string) any {
+		errorType := "invalid_request_error"
+		if status >= http.StatusInternalServerError {
+			errorType = "api_error"
+		}
+		return dto.MistralErrorResponse{Object: "error", Message: message, Type: errorType}
+	},
+}
+
+var mistralEndpoints = map[string]*nativeAPIEndpoint{
+	mistralEndpointChat: {
+		targetPath: "/v1/chat/completions",
+		convertRequest: func(c *gin.Context) (any, string, bool, error) {
+			chatRequest := &dto.MistralChatRequest{}
+			if err := bindNativeAPIRequest(c, chatRequest); err != nil {
+				return nil, "", false, err
+			}
+			return convertNativeAPITextRequest(c, chatRequest.Model, chatRequest)
+		},
+		convertResponse: func(c *gin.Context, body []byte) (any, error) {
+			return convertNativeAPIChatResponse(c, body, types.RelayFormatMistral)
+		},
+	},
+	mistralEndpointEmbeddings: {
+		targetPath: "/v1/embeddings",
+		convertRequest: func(c *gin.Context) (any, string, bool, error) {
+			embeddingRequest := &dto.MistralEmbeddingRequest{}
+			if err := bindNativeAPIRequest(c, embeddingRequest); err != nil {
+				return nil, "", false, err
+			}
+			openAIRequest, err := relayconvert.MistralEmbeddingRequestToOpenAI(embeddingRequest)
+			if err != nil {
+				return nil, "", false, err
+			}
+			return openAIRequest, embeddingRequest.Model, false, nil
+		},
+		// Mistral 的 embeddings 响应与 OpenAI 一致，原样返回
+		convertResponse: func(_ *gin.Context, body []byte) (any, error) {
+			return json.RawMessage(body), nil
+		},
+	},
+}
+
+// MistralRequestConvert 让网关以 Mistral 原生 API 对外提供服务：
+// 将 /mistral/v1/chat/completions、/mistral/v1/embeddings 请求转换为 OpenAI 格式并改写路径，
+// 响应（含 SSE 流）转换回 Mistral 格式。须挂载在 TokenAuth 与 Distribute 之前。
+func MistralRequestConvert() func(c *gin.Context) {
+	return nativeAPIRequestConvert(mistralCodec, mistralEndpoints)
+}
@@ -167,6 +167,9 @@ func memoryRateLimitHandler(duration int64, totalMaxCount, successMaxCount int)
 // ModelRequestRateLimit 模型请求限流中间件
 func ModelRequestRateLimit() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		if !postpaidThrottle(c) {
+			return
+		}
 		// 在每个请求时检查是否启用限流
 		if !setting.ModelRequestRateLimitEnabled {
 			c.Next()
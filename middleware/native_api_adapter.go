@@ -0,0 +1,354 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/relaykit/relayconvert"
+	"github.com/QuantumNous/new-api/relaykit/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// nativeAPICodec 描述一种原生 API（Ollama、Cohere、Mistral 等）的响应编码方式。
+type nativeAPICodec struct {
+	// name 用于日志前缀
+	name string
+	// format 为文本响应转换的目标格式
+	format types.RelayFormat
+	// streamContentType 为流式响应的 Content-Type
+	streamContentType string
+	// encodeStreamValue 将一条流式转换结果编码为待写出的字节（NDJSON 行或 SSE 事件）
+	encodeStreamValue func(value any) ([]byte, error)
+	// streamTerminator 在流结束时追加写出，可为空
+	streamTerminator []byte
+	// errorBody 构造原生格式的错误响应体
+	errorBody func(status int, message string) any
+}
+
+// nativeAPIEndpoint 描述一个原生端点如何映射到 OpenAI 端点。
+type nativeAPIEndpoint struct {
+	// targetPath 为改写后的 OpenAI 路径
+	targetPath string
+	// convertRequest 解析原生请求体，返回 OpenAI 请求、模型名以及是否流式
+	convertRequest func(c *gin.Context) (request any, model string, stream bool, err error)
+	// convertResponse 将缓存的 OpenAI 非流式响应体转换为原生响应
+	convertResponse func(c *gin.Context, body []byte) (any, error)
+	// adaptStreamValue 可选，对流式转换结果做端点级调整
+	adaptStreamValue func(value any) any
+}
+
+// nativeAPIRequestConvert 让网关以原生 API 对外提供服务：
+// 按路径选取端点，将请求转换为 OpenAI 格式并改写路径，后续鉴权、分发、计费与 /v1 完全一致；
+// 同时包装响应写入器，把 OpenAI 响应（含 SSE 流）转换回原生格式。
+// 须挂载在 TokenAuth 与 Distribute 之前。
+func nativeAPIRequestConvert(codec *nativeAPICodec, endpoints map[string]*nativeAPIEndpoint) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		endpoint, ok := endpoints[c.Request.URL.Path]
+		if !ok {
+			abortWithNativeAPIMessage(c, codec, http.StatusNotFound, fmt.Sprintf("unsupported %s endpoint %s", codec.name, c.Request.URL.Path))
+			return
+		}
+		openAIRequest, model, stream, err := endpoint.convertRequest(c)
+		if err != nil {
+			abortWithNativeAPIMessage(c, codec, http.StatusBadRequest, err.Error())
+			return
+		}
+		if model == "" {
+			abortWithNativeAPIMessage(c, codec, http.StatusBadRequest, "model is required")
+			return
+		}
+
+		jsonData, err := common.Marshal(openAIRequest)
+		if err != nil {
+			abortWithNativeAPIMessage(c, codec, http.StatusInternalServerError, "failed to marshal request body")
+			return
+		}
+
+		// 丢弃原请求体缓存，后续读取将使用转换后的 OpenAI 请求体
+		common.CleanupBodyStorage(c)
+		c.Set(common.KeyRequestBody, jsonData)
+		c.Request.Body = io.NopCloser(bytes.NewReader(jsonData))
+		c.Request.ContentLength = int64(len(jsonData))
+		c.Request.URL.Path = endpoint.targetPath
+
+		writer := &nativeAPIResponseWriter{
+			ResponseWriter: c.Writer,
+			ctx:            c,
+			codec:          codec,
+			endpoint:       endpoint,
+			status:         http.StatusOK,
+		}
+		if stream {
+			writer.state, err = relayconvert.NewResponseStreamState(types.RelayFormatOpenAI, codec.format, relayconvert.ResponseStreamOptions{
+				Model:   model,
+				Created: common.GetTimestamp(),
+			})
+			if err != nil {
+				abortWithNativeAPIMessage(c, codec, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		c.Writer = writer
+		c.Next()
+		writer.finish()
+	}
+}
+
+func abortWithNativeAPIMessage(c *gin.Context, codec *nativeAPICodec, statusCode int, message string) {
+	c.JSON(statusCode, codec.errorBody(statusCode, common.MessageWithRequestId(message, c.GetString(common.RequestIdKey))))
+	c.Abort()
+}
+
+// bindNativeAPIRequest 解析原生请求体，保留请求体缓存以便出错时复用。
+func bindNativeAPIRequest(c *gin.Context, request any) error {
+	if err := common.UnmarshalBodyReusable(c, request); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+// convertNativeAPIChatRequest 通过 relayconvert 将原生对话请求转换为 OpenAI chat completions 请求。
+func convertNativeAPIChatRequest(c *gin.Context, request any) (*dto.GeneralOpenAIRequest, error) {
+	result, err := relayconvert.ConvertRequest(c, nil, types.RelayFormatOpenAI, request)
+	if err != nil {
+		return nil, err
+	}
+	chatRequest, ok := result.Value.(*dto.GeneralOpenAIRequest)
+	if !ok {
+		return nil, fmt.Errorf("unexpected converted request %T", result.Value)
+	}
+	return chatRequest, nil
+}
+
+// convertNativeAPITextRequest 转换原生对话请求并返回 OpenAI 请求、模型名与是否流式。
+// 缺少模型时不做转换，由 nativeAPIRequestConvert 统一返回 model is required。
+func convertNativeAPITextRequest(c *gin.Context, model string, request any) (any, string, bool, error) {
+	if model == "" {
+		return nil, "", false, nil
+	}
+	chatRequest, err := convertNativeAPIChatRequest(c, request)
+	if err != nil {
+		return nil, "", false, err
+	}
+	return chatRequest, model, chatRequest.IsStream(c.Request), nil
+}
+
+// convertNativeAPIChatResponse 将 OpenAI chat completions 响应体转换为目标原生格式。
+func convertNativeAPIChatResponse(c *gin.Context, body []byte, format types.RelayFormat) (any, error) {
+	var response dto.OpenAITextResponse
+	if err := common.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	result, err := relayconvert.ConvertResponse(c, nil, format, &response)
+	if err != nil {
+		return nil, err
+	}
+	return result.Value, nil
+}
+
+// nativeAPIResponseWriter 截获下游写出的 OpenAI 响应并转换为原生格式。
+// SSE 流按行解析后逐条转换并立即写出；非流式响应与错误先缓存，
+// 在请求结束时整体转换。状态码与响应头延迟到首次真正写出时才下发。
+type nativeAPIResponseWriter struct {
+	gin.ResponseWriter
+	ctx      *gin.Context
+	codec    *nativeAPICodec
+	endpoint *nativeAPIEndpoint
+	state    *relayconvert.ResponseStreamState
+
+	status    int
+	body      bytes.Buffer
+	decided   bool
+	streaming bool
+	started   bool
+	done      bool
+}
+
+func (w *nativeAPIResponseWriter) WriteHeader(code int) {
+	if !w.started {
+		w.status = code
+	}
+}
+
+func (w *nativeAPIResponseWriter) WriteHeaderNow() {}
+
+func (w *nativeAPIResponseWriter) Status() int {
+	return w.status
+}
+
+func (w *nativeAPIResponseWriter) Written() bool {
+	return w.decided
+}
+
+func (w *nativeAPIResponseWriter) Flush() {
+	if w.started {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *nativeAPIResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *nativeAPIResponseWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		w.streaming = w.state != nil && w.status < http.StatusBadRequest &&
+			strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream")
+	}
+	w.body.Write(b)
+	if !w.streaming {
+		return len(b), nil
+	}
+	for {
+		index := bytes.IndexByte(w.body.Bytes(), '\n')
+		if index < 0 {
+			break
+		}
+		w.handleStreamLine(string(w.body.Next(index + 1)))
+	}
+	return len(b), nil
+}
+
+func (w *nativeAPIResponseWriter) handleStreamLine(line string) {
+	if w.done {
+		return
+	}
+	line = strings.TrimSpace(line)
+	data, isData := strings.CutPrefix(line, "data:")
+	// 中途出错时下游会在流后直接追加 JSON 错误体，其余非 data 行（如 PING 注释）忽略
+	if !isData && !strings.HasPrefix(line, "{") {
+		return
+	}
+	data = strings.TrimSpace(data)
+	if data == "[DONE]" {
+		w.finishStream()
+		return
+	}
+	if message, ok := nativeAPIErrorMessage([]byte(data)); ok {
+		w.done = true
+		w.writeStreamValue(w.codec.errorBody(w.status, message))
+		return
+	}
+	var chunk dto.ChatCompletionsStreamResponse
+	if err := common.UnmarshalJsonStr(data, &chunk); err != nil {
+		common.SysError(w.codec.name + " adapter: failed to decode stream chunk: " + err.Error())
+		return
+	}
+	results, err := relayconvert.ConvertStreamResponseChunk(w.ctx, nil, w.state, &chunk)
+	if err != nil {
+		common.SysError(w.codec.name + " adapter: failed to convert stream chunk: " + err.Error())
+		return
+	}
+	w.writeResults(results)
+}
+
+func (w *nativeAPIResponseWriter) finishStream() {
+	if w.done {
+		return
+	}
+	w.done = true
+	results, err := relayconvert.FinalizeStreamResponse(w.ctx, nil, w.state)
+	if err != nil {
+		common.SysError(w.codec.name + " adapter: failed to finalize stream: " + err.Error())
+		return
+	}
+	w.writeResults(results)
+	if len(w.codec.streamTerminator) > 0 {
+		w.writeBody(w.codec.streamContentType, w.codec.streamTerminator)
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *nativeAPIResponseWriter) writeResults(results []relayconvert.ResponseResult) {
+	for _, result := range results {
+		value := result.Value
+		if w.endpoint.adaptStreamValue != nil {
+			value = w.endpoint.adaptStreamValue(value)
+		}
+		w.writeStreamValue(value)
+	}
+}
+
+func (w *nativeAPIResponseWriter) writeStreamValue(value any) {
+	data, err := w.codec.encodeStreamValue(value)
+	if err != nil {
+		return
+	}
+	w.writeBody(w.codec.streamContentType, data)
+	w.ResponseWriter.Flush()
+}
+
+func (w *nativeAPIResponseWriter) writeBody(contentType string, data []byte) {
+	if !w.started {
+		w.started = true
+		header := w.Header()
+		header.Set("Content-Type", contentType)
+		header.Del("Content-Length")
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	_, _ = w.ResponseWriter.Write(data)
+}
+
+// finish 在请求处理结束后调用：补齐未收到 [DONE] 的流，或整体转换缓存的响应。
+func (w *nativeAPIResponseWriter) finish() {
+	if w.streaming {
+		if rest := w.body.String(); rest != "" {
+			w.body.Reset()
+			w.handleStreamLine(rest)
+		}
+		w.finishStream()
+		return
+	}
+	if !w.decided {
+		return
+	}
+
+	body := w.body.Bytes()
+	message, isError := nativeAPIErrorMessage(body)
+	if !isError && w.status >= http.StatusBadRequest {
+		message, isError = strings.TrimSpace(string(body)), true
+		if message == "" {
+			message = http.StatusText(w.status)
+		}
+	}
+	if !isError {
+		value, err := w.endpoint.convertResponse(w.ctx, body)
+		if err == nil {
+			var data []byte
+			if data, err = common.Marshal(value); err == nil {
+				w.writeBody("application/json", data)
+				return
+			}
+		}
+		w.status = http.StatusInternalServerError
+		message = "failed to convert response: " + err.Error()
+	}
+	if w.status < http.StatusBadRequest {
+		w.status = http.StatusInternalServerError
+	}
+	data, _ := common.Marshal(w.codec.errorBody(w.status, message))
+	w.writeBody("application/json", data)
+}
+
+// nativeAPIErrorMessage 提取 OpenAI 风格（error 为对象）或 Ollama 风格（error 为字符串）的错误信息。
+func nativeAPIErrorMessage(body []byte) (string, bool) {
+	result := gjson.GetBytes(body, "error")
+	if !result.Exists() || result.Type == gjson.Null {
+		return "", false
+	}
+	if result.IsObject() {
+		if message := result.Get("message"); message.Exists() {
+			return message.String(), true
+		}
+		return result.Raw, true
+	}
+	return result.String(), true
+}
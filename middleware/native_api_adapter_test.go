@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeOpenAIStream(c *gin.Context, chunks ...string) {
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Status(http.StatusOK)
+	for _, data := range append(chunks, `[DONE]`) {
+		_, _ = c.Writer.WriteString("data: " + data + "\n\n")
+		c.Writer.Flush()
+	}
+}
+
+func TestCohereRequestConvertStreamsChatAsCohereEvents(t *testing.T) {
+	body := `{"model":"command-a","stream":true,"p":0.5,"stop_sequences":["END"],"documents":["the sky is blue"],"messages":[{"role":"user","content":[{"type":"text","text":"colour?"}]}]}`
+	recorder := performNativeAPIRequest(t, CohereRequestConvert(), "/v2/chat", body, func(c *gin.Context) {
+		assert.Equal(t, "/v1/chat/completions", c.Request.URL.Path)
+		var openAIRequest dto.GeneralOpenAIRequest
+		require.NoError(t, common.UnmarshalBodyReusable(c, &openAIRequest))
+		assert.True(t, openAIRequest.IsStream(c.Request))
+		require.NotNil(t, openAIRequest.TopP)
+		assert.Equal(t, 0.5, *openAIRequest.TopP)
+		require.Len(t, openAIRequest.Messages, 2)
+		assert.Equal(t, "system", openAIRequest.Messages[0].Role)
+		assert.Contains(t, openAIRequest.Messages[0].StringContent(), "the sky is blue")
+		assert.Equal(t, "colour?", openAIRequest.Messages[1].StringContent())
+
+		writeOpenAIStream(c,
+			`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"command-a","choices":[{"index":0,"delta":{"role":"assistant","content":"Bl"}}]}`,
+			`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"command-a","choices":[{"index":0,"delta":{"content":"ue"},"finish_reason":"stop"}]}`,
+			`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"command-a","choices":[],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`,
+		)
+	})
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/event-stream", recorder.Header().Get("Content-Type"))
+	assert.NotContains(t, recorder.Body.String(), "[DONE]")
+
+	var events []dto.CohereStreamEvent
+	for _, block := range strings.Split(strings.TrimSpace(recorder.Body.String()), "\n\n") {
+		lines := strings.Split(block, "\n")
+		require.Len(t, lines, 2)
+		var event dto.CohereStreamEvent
+		require.NoError(t, common.UnmarshalJsonStr(strings.TrimPrefix(lines[1], "data: "), &event))
+		assert.Equal(t, "event: "+event.Type, lines[0])
+		events = append(events, event)
+	}
+	require.Len(t, events, 6)
+	assert.Equal(t, dto.CohereStreamEventMessageStart, events[0].Type)
+	assert.Equal(t, dto.CohereStreamEventContentStart, events[1].Type)
+	assert.Equal(t, "Bl", events[2].Delta.Message.Content.Text)
+	assert.Equal(t, "ue", events[3].Delta.Message.Content.Text)
+	assert.Equal(t, dto.CohereStreamEventContentEnd, events[4].Type)
+	assert.Equal(t, dto.CohereStreamEventMessageEnd, events[5].Type)
+	assert.Equal(t, dto.CohereFinishReasonComplete, events[5].Delta.FinishReason)
+	assert.Equal(t, 5, events[5].Delta.Usage.BilledUnits.InputTokens)
+	assert.Equal(t, 2, events[5].Delta.Usage.Tokens.OutputTokens)
+}
+
+func TestCohereRequestConvertConvertsRerankAndErrors(t *testing.T) {
+	recorder := performNativeAPIRequest(t, CohereRequestConvert(), "/v2/rerank", `{"model":"rerank-v3.5","query":"q","documents":["a","b"],"top_n":1}`, func(c *gin.Context) {
+		assert.Equal(t, "/v1/rerank", c.Request.URL.Path)
+		var rerankRequest dto.RerankRequest
+		require.NoError(t, common.UnmarshalBodyReusable(c, &rerankRequest))
+		assert.Equal(t, []any{"a", "b"}, rerankRequest.Documents)
+		c.JSON(http.StatusOK, dto.RerankResponse{
+			Results: []dto.RerankResponseResult{{Index: 1, RelevanceScore: 0.9}},
+			Usage:   dto.Usage{PromptTokens: 3, TotalTokens: 3},
+		})
+	})
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var rerank dto.CohereRerankResponse
+	require.NoError(t, common.Unmarshal(recorder.Body.Bytes(), &rerank))
+	require.Len(t, rerank.Results, 1)
+	assert.Equal(t, 1, rerank.Results[0].Index)
+	assert.Equal(t, 1, rerank.Meta.BilledUnits.SearchUnits)
+
+	recorder = performNativeAPIRequest(t, CohereRequestConvert(), "/v2/embed", `{"model":"embed-v4.0","texts":["a"],"embedding_types":["int8"]}`, func(c *gin.Context) {
+		t.Fatal("handler should not run for unsupported embedding types")
+	})
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+	var errorResponse dto.CohereErrorResponse
+	require.NoError(t, common.Unmarshal(recorder.Body.Bytes(), &errorResponse))
+	assert.True(t, strings.HasPrefix(errorResponse.Message, `unsupported cohere embedding type "int8"`))
+}
+
+func TestMistralRequestConvertConvertsChat(t *testing.T) {
+	body := `{"model":"mistral-large-latest","random_seed":7,"tool_choice":"any","tools":[{"type":"function","function":{"name":"lookup","parameters":{"type":"object"}}}],"messages":[{"role":"user","content":"hi"},{"role":"assistant","content":"","tool_calls":[{"id":"abc123def","function":{"name":"lookup","arguments":{"q":"x"}}}]},{"role":"tool","tool_call_id":"abc123def","name":"lookup","content":"42"}]}`
+	recorder := performNativeAPIRequest(t, MistralRequestConvert(), "/mistral/v1/chat/completions", body, func(c *gin.Context) {
+		assert.Equal(t, "/v1/chat/completions", c.Request.URL.Path)
+		var openAIRequest dto.GeneralOpenAIRequest
+		require.NoError(t, common.UnmarshalBodyReusable(c, &openAIRequest))
+		assert.False(t, openAIRequest.IsStream(c.Request))
+		assert.Equal(t, "required", openAIRequest.ToolChoice)
+		require.NotNil(t, openAIRequest.Seed)
+		assert.Equal(t, float64(7), *openAIRequest.Seed)
+		require.Len(t, openAIRequest.Messages, 3)
+		toolCalls := openAIRequest.Messages[1].ParseToolCalls()
+		require.Len(t, toolCalls, 1)
+		assert.JSONEq(t, `{"q":"x"}`, toolCalls[0].Function.Arguments)
+		assert.Equal(t, "abc123def", openAIRequest.Messages[2].ToolCallId)
+
+		reasoning := "hmm"
+		c.JSON(http.StatusOK, dto.OpenAITextResponse{
+			Id:      "chatcmpl-1",
+			Model:   "mistral-large-latest",
+			Object:  "chat.completion",
+			Created: int64(1700000000),
+			Choices: []dto.OpenAITextResponseChoice{{
+				Message:      dto.Message{Role: "assistant", Content: "The answer is 42", ReasoningContent: &reasoning},
+				FinishReason: "stop",
+			}},
+			Usage: dto.Usage{PromptTokens: 20, CompletionTokens: 5, TotalTokens: 25},
+		})
+	})
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var response dto.MistralChatResponse
+	require.NoError(t, common.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, "chatcmpl-1", response.ID)
+	assert.EqualValues(t, 1700000000, response.Created)
+	assert.Equal(t, 25, response.Usage.TotalTokens)
+	require.Len(t, response.Choices, 1)
+	chunks := response.Choices[0].Message.ParseContent()
+	require.Len(t, chunks, 2)
+	assert.Equal(t, dto.MistralContentTypeThinking, chunks[0].Type)
+	assert.Equal(t, "hmm", chunks[0].Thinking[0].Text)
+	assert.Equal(t, "The answer is 42", chunks[1].Text)
+}
+
+func TestMistralRequestConvertStreamsChatWithDone(t *testing.T) {
+	recorder := performNativeAPIRequest(t, MistralRequestConvert(), "/mistral/v1/chat/completions", `{"model":"mistral-small-latest","stream":true,"messages":[{"role":"user","content":"hi"}]}`, func(c *gin.Context) {
+		writeOpenAIStream(c,
+			`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"mistral-small-latest","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}]}`,
+			`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"mistral-small-latest","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`,
+		)
+	})
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	blocks := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n\n")
+	require.Len(t, blocks, 3)
+	assert.Equal(t, "data: [DONE]", blocks[2])
+
+	var first dto.MistralStreamResponse
+	require.NoError(t, common.UnmarshalJsonStr(strings.TrimPrefix(blocks[0], "data: "), &first))
+	require.Len(t, first.Choices, 1)
+	assert.Equal(t, "Hello", first.Choices[0].Delta.Content)
+	require.NotNil(t, first.Choices[0].FinishReason)
+	assert.Equal(t, "stop", *first.Choices[0].FinishReason)
+
+	var usage dto.MistralStreamResponse
+	require.NoError(t, common.UnmarshalJsonStr(strings.TrimPrefix(blocks[1], "data: "), &usage))
+	require.NotNil(t, usage.Usage)
+	assert.Equal(t, 4, usage.Usage.TotalTokens)
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/relaykit/relayconvert"
+	"github.com/QuantumNous/new-api/relaykit/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ollamaEndpointChat     = "/api/chat"
+	ollamaEndpointGenerate = "/api/generate"
+	ollamaEndpointEmbed    = "/api/embed"
+)
+
+var ollamaCodec = &nativeAPICodec{
+	name:              "ollama",
+	format:            types.RelayFormatOllama,
+	streamContentType: "application/x-ndjson",
+	encodeStreamValue: func(value any) ([]byte, error) {
+		data, err := common.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	},
+	errorBody: func(_ int, message string) any {
+		return dto.OllamaErrorResponse{Error: message}
+	},
+}
+
+var ollamaEndpoints = map[string]*nativeAPIEndpoint{
+	ollamaEndpointChat: {
+		targetPath: "/v1/chat/completions",
+		convertRequest: func(c *gin.Context) (any, string, bool, error) {
+			chatRequest := &dto.OllamaChatRequest{}
+			if err := bindNativeAPIRequest(c, chatRequest); err != nil {
+				return nil, "", false, err
+			}
+			return convertNativeAPITextRequest(c, chatRequest.Model, chatRequest)
+		},
+		convertResponse: func(c *gin.Context, body []byte) (any, error) {
+			return convertNativeAPIChatResponse(c, body, types.RelayFormatOllama)
+		},
+	},
+	ollamaEndpointGenerate: {
+		targetPath: "/v1/chat/completions",
+		convertRequest: func(c *gin.Context) (any, string, bool, error) {
+			generateRequest := &dto.OllamaGenerateRequest{}
+			if err := bindNativeAPIRequest(c, generateRequest); err != nil {
+				return nil, "", false, err
+			}
+			return convertNativeAPITextRequest(c, generateRequest.Model, generateRequest)
+		},
+		convertResponse: func(c *gin.Context, body []byte) (any, error) {
+			value, err := convertNativeAPIChatResponse(c, body, types.RelayFormatOllama)
+			if err != nil {
+				return nil, err
+			}
+			return ollamaGenerateValue(value), nil
+		},
+		adaptStreamValue: ollamaGenerateValue,
+	},
+	ollamaEndpointEmbed: {
+		targetPath: "/v1/embeddings",
+		convertRequest: func(c *gin.Context) (any, string, bool, error) {
+			embedRequest := &dto.OllamaEmbeddingRequest{}
+			if err := bindNativeAPIRequest(c, embedRequest); err != nil {
+				return nil, "", false, err
+			}
+			return relayconvert.OllamaEmbeddingRequestToOpenAI(embedRequest), embedRequest.Model, false, nil
+		},
+		convertResponse: func(_ *gin.Context, body []byte) (any, error) {
+			var response dto.OpenAIEmbeddingResponse
+			if err := common.Unmarshal(body, &response); err != nil {
+				return nil, err
+			}
+			return relayconvert.OpenAIEmbeddingResponseToOllama(&response), nil
+		},
+	},
+}
+
+// OllamaRequestConvert 让网关以 Ollama 原生 API 对外提供服务：
+// 将 /api/chat、/api/generate、/api/embed 请求转换为 OpenAI 格式并改写路径，
+// 响应（含 SSE 流）转换回 Ollama JSON / NDJSON。须挂载在 TokenAuth 与 Distribute 之前。
+func OllamaRequestConvert() func(c *gin.Context) {
+	return nativeAPIRequestConvert(ollamaCodec, ollamaEndpoints)
+}
+
+// ollamaGenerateValue 将 chat 形态的响应转换为 /api/generate 所需的形态。
+func ollamaGenerateValue(value any) any {
+	if chat, ok := value.(*dto.OllamaChatResponse); ok {
+		return chat.ToGenerateResponse()
+	}
+	return value
+}
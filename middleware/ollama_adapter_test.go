@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func performOllamaRequest(t *testing.T, path string, body string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	return performNativeAPIRequest(t, OllamaRequestConvert(), path, body, handler)
+}
+
+func performNativeAPIRequest(t *testing.T, convert gin.HandlerFunc, path string, body string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST(path, convert, handler)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestOllamaRequestConvertStreamsGenerateAsNDJSON(t *testing.T) {
+	recorder := performOllamaRequest(t, "/api/generate", `{"model":"llama3","system":"be brief","prompt":"hi","options":{"num_predict":16}}`, func(c *gin.Context) {
+		assert.Equal(t, "/v1/chat/completions", c.Request.URL.Path)
+		var openAIRequest dto.GeneralOpenAIRequest
+		require.NoError(t, common.UnmarshalBodyReusable(c, &openAIRequest))
+		assert.Equal(t, "llama3", openAIRequest.Model)
+		assert.True(t, openAIRequest.IsStream(c.Request))
+		require.NotNil(t, openAIRequest.StreamOptions)
+		assert.True(t, openAIRequest.StreamOptions.IncludeUsage)
+		require.Len(t, openAIRequest.Messages, 2)
+		assert.Equal(t, "system", openAIRequest.Messages[0].Role)
+		assert.Equal(t, "hi", openAIRequest.Messages[1].StringContent())
+		assert.EqualValues(t, 16, openAIRequest.GetMaxTokens())
+
+		c.Writer.Header().Set("Content-Type", "text/event-stream")
+		c.Status(http.StatusOK)
+		for _, data := range []string{
+			`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"llama3","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`,
+			`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"llama3","choices":[{"index":0,"delta":{"content":"lo"}}]}`,
+			`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"llama3","choices":[{"index":0,"delta":{},"finish_reason":"length"}]}`,
+			`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"llama3","choices":[],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`,
+			`[DONE]`,
+		} {
+			_, _ = c.Writer.WriteString(": PING\n\ndata: " + data + "\n\n")
+			c.Writer.Flush()
+		}
+	})
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/x-ndjson", recorder.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n")
+	require.Len(t, lines, 3)
+
+	var chunks []dto.OllamaGenerateResponse
+	for _, line := range lines {
+		var chunk dto.OllamaGenerateResponse
+		require.NoError(t, common.UnmarshalJsonStr(line, &chunk))
+		chunks = append(chunks, chunk)
+	}
+	assert.Equal(t, "Hel", chunks[0].Response)
+	assert.Equal(t, "lo", chunks[1].Response)
+	assert.False(t, chunks[1].Done)
+	assert.True(t, chunks[2].Done)
+	assert.Equal(t, "length", chunks[2].DoneReason)
+	assert.Equal(t, 5, chunks[2].PromptEvalCount)
+	assert.Equal(t, 2, chunks[2].EvalCount)
+}
+
+func TestOllamaRequestConvertConvertsNonStreamChat(t *testing.T) {
+	recorder := performOllamaRequest(t, "/api/chat", `{"model":"llama3","stream":false,"messages":[{"role":"user","content":"hi"}]}`, func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `"stream":false`)
+
+		c.Writer.Header().Set("Content-Length", "999")
+		c.JSON(http.StatusOK, dto.OpenAITextResponse{
+			Id:      "chatcmpl-1",
+			Model:   "llama3",
+			Object:  "chat.completion",
+			Created: int64(1700000000),
+			Choices: []dto.OpenAITextResponseChoice{{
+				Message:      dto.Message{Role: "assistant", Content: "hello"},
+				FinishReason: "stop",
+			}},
+			Usage: dto.Usage{PromptTokens: 3, CompletionTokens: 1, TotalTokens: 4},
+		})
+	})
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Empty(t, recorder.Header().Get("Content-Length"))
+	var response dto.OllamaChatResponse
+	require.NoError(t, common.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, "llama3", response.Model)
+	assert.Equal(t, "hello", response.Message.Content)
+	assert.True(t, response.Done)
+	assert.Equal(t, 3, response.PromptEvalCount)
+	assert.Equal(t, 1, response.EvalCount)
+}
+
+func TestOllamaRequestConvertConvertsEmbeddingAndErrors(t *testing.T) {
+	recorder := performOllamaRequest(t, "/api/embed", `{"model":"nomic-embed-text","input":["a","b"]}`, func(c *gin.Context) {
+		assert.Equal(t, "/v1/embeddings", c.Request.URL.Path)
+		c.JSON(http.StatusOK, dto.OpenAIEmbeddingResponse{
+			Object: "list",
+			Model:  "nomic-embed-text",
+			Data: []dto.OpenAIEmbeddingResponseItem{
+				{Object: "embedding", Index: 1, Embedding: []float64{0.2}},
+				{Object: "embedding", Index: 0, Embedding: []float64{0.1}},
+			},
+			Usage: dto.Usage{PromptTokens: 2, TotalTokens: 2},
+		})
+	})
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var embedding dto.OllamaEmbeddingResponse
+	require.NoError(t, common.Unmarshal(recorder.Body.Bytes(), &embedding))
+	assert.Equal(t, [][]float64{{0.1}, {0.2}}, embedding.Embeddings)
+	assert.Equal(t, 2, embedding.PromptEvalCount)
+
+	recorder = performOllamaRequest(t, "/api/chat", `{"model":"llama3","messages":[{"role":"user","content":"hi"}]}`, func(c *gin.Context) {
+		abortWithOpenAiMessage(c, http.StatusUnauthorized, "invalid token")
+	})
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	var errorResponse dto.OllamaErrorResponse
+	require.NoError(t, common.Unmarshal(recorder.Body.Bytes(), &errorResponse))
+	assert.True(t, strings.HasPrefix(errorResponse.Error, "invalid token"))
+
+	recorder = performOllamaRequest(t, "/api/chat", `{"messages":[]}`, func(c *gin.Context) {
+		t.Fatal("handler should not run without a model")
+	})
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+	require.NoError(t, common.Unmarshal(recorder.Body.Bytes(), &errorResponse))
+	assert.True(t, strings.HasPrefix(errorResponse.Error, "model is required"))
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+const postpaidThrottleMark = "PPT"
+
+// postpaidThrottle 对催缴进入限速阶段的后付费用户按分钟限流。返回 false 时请求已被中止。
+// Redis 异常时放行，限速只是催缴手段，不应影响可用性。
+func postpaidThrottle(c *gin.Context) bool {
+	if common.GetContextKeyInt(c, constant.ContextKeyUserDunningStage) < model.PostpaidStageThrottled {
+		return true
+	}
+	limit := operation_setting.GetPostpaidSetting().ThrottleRequestsPerMinute
+	if limit <= 0 {
+		return true
+	}
+	userId := c.GetInt("id")
+	message := fmt.Sprintf("账单已逾期，请求已限速：每分钟最多 %d 次，请结清账单后恢复", limit)
+	if common.RedisEnabled {
+		allowed, _, ttlSeconds, err := redisFixedWindowTake(c.Request.Context(), redisUserRateLimitKey(postpaidThrottleMark, userId), limit, 60)
+		if err != nil {
+			logger.LogError(c.Request.Context(), fmt.Sprintf("postpaid throttle check failed: %v", err))
+			return true
+		}
+		if !allowed {
+			c.Header("Retry-After", fmt.Sprintf("%d", ttlSeconds))
+			abortWithOpenAiMessage(c, http.StatusTooManyRequests, message)
+			return false
+		}
+		return true
+	}
+	inMemoryRateLimiter.Init(common.RateLimitKeyExpirationDuration)
+	if !inMemoryRateLimiter.Request(fmt.Sprintf("%s:user:%d", postpaidThrottleMark, userId), limit, 60) {
+		c.Header("Retry-After", "60")
+		abortWithOpenAiMessage(c, http.StatusTooManyRequests, message)
+		return false
+	}
+	return true
+}
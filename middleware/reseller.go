@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DenyResellerUsers 拒绝分销商下游用户自行充值或购买订阅：其余额由分销商发放，
+// 自行购买的额度在消费时仍会从分销商钱包扣除批发价。
+func DenyResellerUsers() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		user, err := model.GetUserCache(c.GetInt("id"))
+		if err != nil {
+			common.ApiError(c, err)
+			c.Abort()
+			return
+		}
+		if user.ResellerId > 0 {
+			common.ApiErrorI18n(c, i18n.MsgResellerPaymentDisabled)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
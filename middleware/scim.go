@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/scim"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SCIMAuth authenticates identity provider requests to /scim/v2 with the
+// dedicated SCIM bearer token. It is independent of user sessions and API
+// tokens, and every failure is answered in the SCIM error format.
+func SCIMAuth() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		settings := system_setting.GetSCIMSettings()
+		expected := strings.TrimSpace(settings.BearerToken)
+		if !settings.Enabled || expected == "" {
+			abortSCIM(c, http.StatusNotFound, "SCIM provisioning is not enabled")
+			return
+		}
+		auth := c.GetHeader("Authorization")
+		provided, ok := strings.CutPrefix(auth, "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(provided)), []byte(expected)) != 1 {
+			abortSCIM(c, http.StatusUnauthorized, "invalid SCIM bearer token")
+			return
+		}
+		c.Next()
+	}
+}
+
+func abortSCIM(c *gin.Context, status int, detail string) {
+	body, _ := common.Marshal(scim.NewError(status, "", detail))
+	c.Data(status, scim.ContentType, body)
+	c.Abort()
+}
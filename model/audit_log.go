@@ -0,0 +1,425 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// AuditLog 是独立于 logs 表的审计日志存储。每条记录的 Hash 覆盖自身字段与上一条
+// 记录的 Hash（PrevHash），构成哈希链：任何一条被修改、删除或插入都会在校验时
+// 断链。Seq 为连续递增且唯一的链序号，多节点并发追加时由唯一索引保证不分叉。
+//
+// 审计日志固定写入主库（DB），不跟随 LOG_DB（可能是 ClickHouse），以便在事务中
+// 读取链尾并追加；其保留期由 AuditLogSetting 单独控制，不受日志清理任务影响。
+type AuditLog struct {
+	Id         int    `json:"id"`
+	Seq        int64  `json:"seq" gorm:"uniqueIndex"`
+	CreatedAt  int64  `json:"created_at" gorm:"bigint;index"`
+	ActorId    int    `json:"actor_id" gorm:"index"`
+	ActorName  string `json:"actor_name" gorm:"type:varchar(64);index;default:''"`
+	ActorRole  int    `json:"actor_role" gorm:"default:0"`
+	Action     string `json:"action" gorm:"type:varchar(64);index"`
+	TargetType string `json:"target_type" gorm:"type:varchar(32);index:idx_audit_logs_target,priority:1;default:''"`
+	TargetId   string `json:"target_id" gorm:"type:varchar(64);index:idx_audit_logs_target,priority:2;default:''"`
+	Ip         string `json:"ip" gorm:"type:varchar(64);default:''"`
+	Content    string `json:"content" gorm:"type:text"`
+	Details    string `json:"details" gorm:"type:text"`
+	PrevHash   string `json:"prev_hash" gorm:"type:char(64);default:''"`
+	Hash       string `json:"hash" gorm:"type:char(64);default:''"`
+}
+
+// AuditLogActionPrune 是保留期清理追加的链上记录，记录被删除区间的最后一条
+// seq/hash，使剩余链的起点可被校验。
+const AuditLogActionPrune = "audit.prune"
+
+const auditLogAppendAttempts = 3
+
+// auditLogAppendLock 串行化本进程内的追加，减少与链尾唯一索引的冲突重试；
+// 跨节点的并发由事务内锁定链尾 + Seq 唯一索引兜底。
+var auditLogAppendLock sync.Mutex
+
+// AuditLogEntry 为追加审计日志的入参，Seq/Hash 等链字段由 AppendAuditLog 填充。
+type AuditLogEntry struct {
+	ActorId    int
+	ActorName  string
+	ActorRole  int
+	Action     string
+	TargetType string
+	TargetId   string
+	Ip         string
+	Content    string
+	Details    map[string]interface{}
+}
+
+// auditLogHashInput 固定参与哈希的字段及其顺序；新增字段必须追加在末尾，
+// 否则历史记录将无法校验。
+type auditLogHashInput struct {
+	Seq        int64  `json:"seq"`
+	PrevHash   string `json:"prev_hash"`
+	CreatedAt  int64  `json:"created_at"`
+	ActorId    int    `json:"actor_id"`
+	ActorName  string `json:"actor_name"`
+	ActorRole  int    `json:"actor_role"`
+	Action     string `json:"action"`
+	TargetType string `json:"target_type"`
+	TargetId   string `json:"target_id"`
+	Ip         string `json:"ip"`
+	Content    string `json:"content"`
+	Details    string `json:"details"`
+}
+
+func computeAuditLogHash(entry *AuditLog) (string, error) {
+	data, err := common.Marshal(auditLogHashInput{
+		Seq:        entry.Seq,
+		PrevHash:   entry.PrevHash,
+		CreatedAt:  entry.CreatedAt,
+		ActorId:    entry.ActorId,
+		ActorName:  entry.ActorName,
+		ActorRole:  entry.ActorRole,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetId:   entry.TargetId,
+		Ip:         entry.Ip,
+		Content:    entry.Content,
+		Details:    entry.Details,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AppendAuditLog 在链尾追加一条审计日志。
+func AppendAuditLog(entry AuditLogEntry) (*AuditLog, error) {
+	record := newAuditLogRecord(entry)
+
+	auditLogAppendLock.Lock()
+	defer auditLogAppendLock.Unlock()
+
+	var err error
+	for attempt := 0; attempt < auditLogAppendAttempts; attempt++ {
+		record.Id = 0
+		err = DB.Transaction(func(tx *gorm.DB) error {
+			return appendAuditLogTx(tx, record)
+		})
+		if err == nil {
+			return record, nil
+		}
+	}
+	return nil, err
+}
+
+func newAuditLogRecord(entry AuditLogEntry) *AuditLog {
+	details := ""
+	if len(entry.Details) > 0 {
+		details = common.MapToJsonStr(entry.Details)
+	}
+	return &AuditLog{
+		ActorId:    entry.ActorId,
+		ActorName:  entry.ActorName,
+		ActorRole:  entry.ActorRole,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetId:   entry.TargetId,
+		Ip:         entry.Ip,
+		Content:    entry.Content,
+		Details:    details,
+	}
+}
+
+// appendAuditLogTx 在事务内把 record 链接到当前链尾，调用方需持有 auditLogAppendLock。
+func appendAuditLogTx(tx *gorm.DB, record *AuditLog) error {
+	var last AuditLog
+	if err := lockForUpdate(tx).Order("seq desc").Limit(1).Find(&last).Error; err != nil {
+		return err
+	}
+	record.Seq = last.Seq + 1
+	record.PrevHash = last.Hash
+	record.CreatedAt = common.GetTimestamp()
+	if record.CreatedAt < last.CreatedAt {
+		record.CreatedAt = last.CreatedAt
+	}
+	hash, err := computeAuditLogHash(record)
+	if err != nil {
+		return err
+	}
+	record.Hash = hash
+	return tx.Create(record).Error
+}
+
+// recordAuditLogEntry 追加审计日志；失败只记录系统日志，不影响业务流程。
+func recordAuditLogEntry(entry AuditLogEntry) {
+	if _, err := AppendAuditLog(entry); err != nil {
+		common.SysError("failed to append audit log: " + err.Error())
+	}
+}
+
+// auditLogTarget 从操作参数推断被操作对象：优先使用目标用户，其次使用
+// action 前缀（资源类型）+ params.id，中间件兜底记录则取路由参数 :id。
+func auditLogTarget(action string, params map[string]interface{}, auditInfo map[string]interface{}) (string, string) {
+	if v, ok := params["target_user_id"]; ok {
+		return "user", fmt.Sprintf("%v", v)
+	}
+	targetType := action
+	if idx := strings.Index(action, "."); idx > 0 {
+		targetType = action[:idx]
+	}
+	if targetType == "generic" {
+		targetType = ""
+	}
+	if v, ok := params["id"]; ok {
+		return targetType, fmt.Sprintf("%v", v)
+	}
+	if routeParams, ok := auditInfo["params"].(map[string]string); ok && routeParams["id"] != "" {
+		return targetType, routeParams["id"]
+	}
+	return targetType, ""
+}
+
+// AuditLogFilter 为审计日志查询与导出共用的过滤条件，零值字段不参与过滤。
+type AuditLogFilter struct {
+	ActorId        int
+	ActorName      string
+	Action         string
+	TargetType     string
+	TargetId       string
+	StartTimestamp int64
+	EndTimestamp   int64
+}
+
+func (f AuditLogFilter) apply(tx *gorm.DB) (*gorm.DB, error) {
+	if f.ActorId != 0 {
+		tx = tx.Where("actor_id = ?", f.ActorId)
+	}
+	if f.ActorName != "" {
+		tx = tx.Where("actor_name = ?", f.ActorName)
+	}
+	if f.Action != "" {
+		if strings.HasSuffix(f.Action, ".*") {
+			pattern, err := sanitizeLikePattern(strings.TrimSuffix(f.Action, "*") + "%")
+			if err != nil {
+				return nil, err
+			}
+			tx = tx.Where("action LIKE ? ESCAPE '!'", pattern)
+		} else {
+			tx = tx.Where("action = ?", f.Action)
+		}
+	}
+	if f.TargetType != "" {
+		tx = tx.Where("target_type = ?", f.TargetType)
+	}
+	if f.TargetId != "" {
+		tx = tx.Where("target_id = ?", f.TargetId)
+	}
+	if f.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", f.StartTimestamp)
+	}
+	if f.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", f.EndTimestamp)
+	}
+	return tx, nil
+}
+
+// GetAuditLogs 按过滤条件分页查询审计日志，最新的在前。
+func GetAuditLogs(filter AuditLogFilter, startIdx int, num int) ([]*AuditLog, int64, error) {
+	tx, err := filter.apply(DB.Model(&AuditLog{}))
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var logs []*AuditLog
+	if err := tx.Order("seq desc").Limit(num).Offset(startIdx).Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
+
+// EachAuditLog 按 seq 升序分批遍历满足过滤条件的审计日志，供导出流式输出。
+// fn 返回错误时停止遍历并返回该错误。
+func EachAuditLog(filter AuditLogFilter, batchSize int, fn func(*AuditLog) error) error {
+	afterSeq := int64(0)
+	for {
+		tx, err := filter.apply(DB.Model(&AuditLog{}))
+		if err != nil {
+			return err
+		}
+		var batch []*AuditLog
+		if err := tx.Where("seq > ?", afterSeq).Order("seq asc").Limit(batchSize).Find(&batch).Error; err != nil {
+			return err
+		}
+		for _, entry := range batch {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		afterSeq = batch[len(batch)-1].Seq
+	}
+}
+
+// AuditLogVerifyResult 描述一次链校验的结果。Valid 为 false 时，BrokenSeq 为第一条
+// 校验失败的记录，Reason 说明原因。LastHash 可由管理员在外部留存，用于发现链尾
+// 被整体截断的情况（哈希链本身无法感知尾部删除）。
+type AuditLogVerifyResult struct {
+	Valid     bool   `json:"valid"`
+	Checked   int64  `json:"checked"`
+	FirstSeq  int64  `json:"first_seq"`
+	LastSeq   int64  `json:"last_seq"`
+	LastHash  string `json:"last_hash"`
+	BrokenSeq int64  `json:"broken_seq,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+type auditLogPruneDetails struct {
+	ThroughSeq  int64  `json:"through_seq"`
+	ThroughHash string `json:"through_hash"`
+	Deleted     int64  `json:"deleted"`
+}
+
+// VerifyAuditLogChain 按 seq 顺序校验整条哈希链：序号连续、PrevHash 与上一条
+// Hash 一致、Hash 与记录内容一致。链头若因保留期清理不再从 1 开始，则要求其
+// PrevHash 与最近一次清理记录中的 through_hash 一致。
+func VerifyAuditLogChain(batchSize int) (*AuditLogVerifyResult, error) {
+	result := &AuditLogVerifyResult{Valid: true}
+	var prev *AuditLog
+	fail := func(entry *AuditLog, reason string) error {
+		result.Valid = false
+		result.BrokenSeq = entry.Seq
+		result.Reason = reason
+		return errAuditLogVerifyStop
+	}
+	err := EachAuditLog(AuditLogFilter{}, batchSize, func(entry *AuditLog) error {
+		if prev == nil {
+			result.FirstSeq = entry.Seq
+			if err := verifyAuditLogChainStart(entry); err != nil {
+				return fail(entry, err.Error())
+			}
+		} else {
+			if entry.Seq != prev.Seq+1 {
+				return fail(entry, fmt.Sprintf("sequence gap after seq %d", prev.Seq))
+			}
+			if entry.PrevHash != prev.Hash {
+				return fail(entry, "prev_hash does not match the previous entry")
+			}
+		}
+		hash, err := computeAuditLogHash(entry)
+		if err != nil {
+			return err
+		}
+		if hash != entry.Hash {
+			return fail(entry, "hash does not match the entry content")
+		}
+		result.Checked++
+		result.LastSeq = entry.Seq
+		result.LastHash = entry.Hash
+		prev = entry
+		return nil
+	})
+	if err != nil && !errors.Is(err, errAuditLogVerifyStop) {
+		return nil, err
+	}
+	return result, nil
+}
+
+var errAuditLogVerifyStop = errors.New("audit log verification stopped")
+
+func verifyAuditLogChainStart(first *AuditLog) error {
+	if first.Seq == 1 {
+		if first.PrevHash != "" {
+			return errors.New("first entry has a prev_hash")
+		}
+		return nil
+	}
+	var prune AuditLog
+	err := DB.Where("action = ?", AuditLogActionPrune).Order("seq desc").Limit(1).Find(&prune).Error
+	if err != nil {
+		return err
+	}
+	if prune.Id == 0 {
+		return errors.New("chain does not start at seq 1 and no prune record exists")
+	}
+	var details auditLogPruneDetails
+	if err := common.UnmarshalJsonStr(prune.Details, &details); err != nil {
+		return errors.New("prune record details are unreadable")
+	}
+	if details.ThroughSeq != first.Seq-1 || details.ThroughHash != first.PrevHash {
+		return errors.New("chain start does not match the latest prune record")
+	}
+	return nil
+}
+
+// PruneAuditLogs 删除 created_at 早于 cutoff 的审计日志，并在链尾追加一条
+// audit.prune 记录保存被删区间最后一条的 seq/hash，作为剩余链的校验锚点。
+// 删除与锚点在同一事务内提交，中途失败不会留下无锚点的断链。
+// 最近一条 audit.prune 记录本身永远不会被删除。
+func PruneAuditLogs(cutoff int64, batchSize int) (int64, error) {
+	auditLogAppendLock.Lock()
+	defer auditLogAppendLock.Unlock()
+
+	var deleted int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		deleted = 0
+		var latestPrune AuditLog
+		if err := tx.Where("action = ?", AuditLogActionPrune).Order("seq desc").Limit(1).Find(&latestPrune).Error; err != nil {
+			return err
+		}
+		var last AuditLog
+		query := tx.Where("created_at < ?", cutoff)
+		if latestPrune.Id != 0 {
+			query = query.Where("seq < ?", latestPrune.Seq)
+		}
+		if err := query.Order("seq desc").Limit(1).Find(&last).Error; err != nil {
+			return err
+		}
+		if last.Id == 0 {
+			return nil
+		}
+		// Deleting by seq keeps the remaining chain contiguous even when clock skew
+		// left an older created_at after a newer one.
+		for {
+			var ids []int
+			if err := tx.Model(&AuditLog{}).Where("seq <= ?", last.Seq).Order("seq asc").Limit(batchSize).Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			if len(ids) == 0 {
+				break
+			}
+			res := tx.Where("id IN ?", ids).Delete(&AuditLog{})
+			if res.Error != nil {
+				return res.Error
+			}
+			deleted += res.RowsAffected
+			if len(ids) < batchSize {
+				break
+			}
+		}
+		return appendAuditLogTx(tx, newAuditLogRecord(AuditLogEntry{
+			Action:  AuditLogActionPrune,
+			Content: "Pruned " + strconv.FormatInt(deleted, 10) + " audit log entries through seq " + strconv.FormatInt(last.Seq, 10),
+			Details: map[string]interface{}{
+				"through_seq":  last.Seq,
+				"through_hash": last.Hash,
+				"deleted":      deleted,
+			},
+		}))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
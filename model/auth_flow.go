@@ -22,6 +22,9 @@ const (
 	AuthFlowPurposePasskeyStepUp     = "passkey_step_up"
 	AuthFlowPurposeTelegramBind      = "telegram_bind"
 	AuthFlowPurposeTelegramAssertion = "telegram_assertion"
+	AuthFlowPurposeSAMLRequest       = "saml_request"
+	AuthFlowPurposeSAMLAssertion     = "saml_assertion"
+	AuthFlowPurposeSAMLTicket        = "saml_ticket"
 	AuthFlowIntentLogin              = "login"
 	AuthFlowIntentBind               = "bind"
 	AuthFlowTokenBytes               = 32
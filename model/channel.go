@@ -12,6 +12,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/pkg/billingexpr"
 	"github.com/QuantumNous/new-api/relaykit/dto"
 	"github.com/QuantumNous/new-api/relaykit/types"
 
@@ -992,6 +993,17 @@ func (channel *Channel) ValidateSettings() error {
 			return fmt.Errorf("advanced custom channels require a %s route when upstream model update checks are enabled", dto.AdvancedCustomModelListPath)
 		}
 	}
+	if channelOtherSettings.UpstreamCostRatio < 0 {
+		return fmt.Errorf("upstream_cost_ratio must not be negative")
+	}
+	for modelName, expr := range channelOtherSettings.UpstreamCostExprs {
+		if strings.TrimSpace(expr) == "" {
+			continue
+		}
+		if _, err := billingexpr.CompileFromCache(expr); err != nil {
+			return fmt.Errorf("invalid upstream cost expression for %s: %w", modelName, err)
+		}
+	}
 	return nil
 }
 
@@ -13,6 +13,7 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 )
 
@@ -21,6 +22,10 @@ var channelsIDM map[int]*Channel                     // all channels include dis
 // channel2advancedCustomConfig caches parsed Advanced Custom (type 58) configs so
 // path-aware selection avoids re-parsing JSON per request. Refreshed on full sync.
 var channel2advancedCustomConfig map[int]*dto.AdvancedCustomConfig
+
+// channel2upstreamCostRatio caches each channel's upstream cost ratio for
+// cheapest-channel preference. Channels without a ratio are absent.
+var channel2upstreamCostRatio map[int]float64
 var channelSyncLock sync.RWMutex
 
 func InitChannelCache() {
@@ -30,15 +35,23 @@ func InitChannelCache() {
 	}
 	newChannelId2channel := make(map[int]*Channel)
 	newChannel2advancedCustomConfig := make(map[int]*dto.AdvancedCustomConfig)
+	newChannel2upstreamCostRatio := make(map[int]float64)
 	var channels []*Channel
 	DB.Find(&channels)
 	for _, channel := range channels {
 		newChannelId2channel[channel.Id] = channel
+		if channel.OtherSettings == "" {
+			continue
+		}
+		otherSettings := channel.GetOtherSettings()
 		if channel.Type == constant.ChannelTypeAdvancedCustom {
-			if config := channel.GetOtherSettings().AdvancedCustom; config != nil {
+			if config := otherSettings.AdvancedCustom; config != nil {
 				newChannel2advancedCustomConfig[channel.Id] = config
 			}
 		}
+		if otherSettings.UpstreamCostRatio > 0 {
+			newChannel2upstreamCostRatio[channel.Id] = otherSettings.UpstreamCostRatio
+		}
 	}
 	var abilities []*Ability
 	DB.Find(&abilities)
@@ -94,6 +107,7 @@ func InitChannelCache() {
 	}
 	channelsIDM = newChannelId2channel
 	channel2advancedCustomConfig = newChannel2advancedCustomConfig
+	channel2upstreamCostRatio = newChannel2upstreamCostRatio
 	channelSyncLock.Unlock()
 	// Lock ordering: InvalidatePricingCache acquires updatePricingLock, and
 	// GetPricing (holding updatePricingLock) nests channelSyncLock.RLock via
@@ -191,6 +205,14 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, requestPat
 		return nil, errors.New(fmt.Sprintf("no channel found, group: %s, model: %s, priority: %d", group, model, targetPriority))
 	}
 
+	if operation_setting.GetUpstreamCostSetting().PreferCheapestChannel {
+		targetChannels = filterCheapestChannels(targetChannels)
+		sumWeight = 0
+		for _, channel := range targetChannels {
+			sumWeight += channel.GetWeight()
+		}
+	}
+
 	// smoothing factor and adjustment
 	smoothingFactor := 1
 	smoothingAdjustment := 0
@@ -222,6 +244,28 @@ func GetRandomSatisfiedChannel(group string, model string, retry int, requestPat
 	return nil, errors.New("channel not found")
 }
 
+// filterCheapestChannels keeps the channels sharing the lowest upstream cost
+// ratio. Channels without a configured ratio are only kept when none of the
+// candidates has one. Caller must hold channelSyncLock (read lock).
+func filterCheapestChannels(channels []*Channel) []*Channel {
+	lowest := 0.0
+	for _, channel := range channels {
+		if ratio, ok := channel2upstreamCostRatio[channel.Id]; ok && (lowest == 0 || ratio < lowest) {
+			lowest = ratio
+		}
+	}
+	if lowest == 0 {
+		return channels
+	}
+	cheapest := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		if ratio, ok := channel2upstreamCostRatio[channel.Id]; ok && ratio == lowest {
+			cheapest = append(cheapest, channel)
+		}
+	}
+	return cheapest
+}
+
 // filterChannelsByRequestPathAndModel restricts candidates by request path and
 // model. Only Advanced Custom (type 58) channels are path-checked: they are kept
 // only when one of their configured routes matches requestPath and model. All
@@ -327,12 +371,20 @@ func CacheUpdateChannel(channel *Channel) {
 	if channel2advancedCustomConfig == nil {
 		channel2advancedCustomConfig = make(map[int]*dto.AdvancedCustomConfig)
 	}
+	if channel2upstreamCostRatio == nil {
+		channel2upstreamCostRatio = make(map[int]float64)
+	}
 	delete(channel2advancedCustomConfig, channel.Id)
+	delete(channel2upstreamCostRatio, channel.Id)
+	otherSettings := channel.GetOtherSettings()
 	if channel.Type == constant.ChannelTypeAdvancedCustom {
-		if config := channel.GetOtherSettings().AdvancedCustom; config != nil {
+		if config := otherSettings.AdvancedCustom; config != nil {
 			channel2advancedCustomConfig[channel.Id] = config
 		}
 	}
+	if otherSettings.UpstreamCostRatio > 0 {
+		channel2upstreamCostRatio[channel.Id] = otherSettings.UpstreamCostRatio
+	}
 	logger.LogDebug(nil, "CacheUpdateChannel after: id=%d, name=%s, status=%d, polling_index=%d", channel.Id, channel.Name, channel.Status, channel.ChannelInfo.MultiKeyPollingIndex)
 	// Lock ordering: do NOT hold channelSyncLock while calling
 	// InvalidatePricingCache. GetPricing acquires updatePricingLock first and then
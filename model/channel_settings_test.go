@@ -98,3 +98,17 @@ func TestAdvancedCustomChannelRequiresModelListRouteOnlyWhenUpdateChecksEnabled(
 		})
 	}
 }
+
+func TestChannelValidateSettingsChecksUpstreamCost(t *testing.T) {
+	channel := &Channel{}
+	channel.SetOtherSettings(dto.ChannelOtherSettings{UpstreamCostExprs: map[string]string{"gpt-a": "p * 1 + c * 2"}})
+	require.NoError(t, channel.ValidateSettings())
+
+	channel.SetOtherSettings(dto.ChannelOtherSettings{UpstreamCostExprs: map[string]string{"gpt-a": "p * ("}})
+	err := channel.ValidateSettings()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "gpt-a")
+
+	channel.SetOtherSettings(dto.ChannelOtherSettings{UpstreamCostRatio: -1})
+	assert.Error(t, channel.ValidateSettings())
+}
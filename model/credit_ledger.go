@@ -0,0 +1,752 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"gorm.io/gorm"
+)
+
+// 额度账本（复式记账）
+//
+// User.Quota 仍是扣费热路径使用的余额；账本在其旁边以只追加的方式记录每一笔
+// 变动，用于回答「哪些额度何时过期」以及重建任意历史时点的余额。
+//
+// 每笔业务对应一个 TxnId，其下各分录金额之和恒为 0：
+//   - wallet  用户钱包（按 GrantId 记到具体授予上，GrantId=0 表示未分摊或透支）
+//   - funding 授予的资金来源（充值、兑换码、签到等）
+//   - usage / adjustment 等 扣费对手方，即 PostCreditDebit 的 source
+//   - expired 过期注销
+//
+// 用户某时点的账本余额 = 该用户 created_at <= 该时点的全部 wallet 分录之和，
+// 对账时与 User.Quota 比较。授予（CreditGrant）记录来源、优先级与过期时间，
+// 扣费按优先级从小到大、先到期先用的顺序分摊到各授予上。
+//
+// 请求热路径的扣费与退还（PostCreditUsage）只写入 GrantId=0 的未分摊分录，
+// 不锁定授予行；新授予入账、授予过期和查询授予时再按上述顺序把未分摊额度
+// 分摊到具体授予（settleCreditUsage）。分摊进度记在 CreditSettlement 中，每次
+// 只汇总上次分摊之后新增的未分摊分录。
+//
+// 用户的第一笔账本记录前会自动写入一笔 open 分录，把当时的 User.Quota 作为期初
+// 余额，因此 Post* 必须与 User.Quota 的变动在同一事务内、且在其之前调用。
+// 批量更新（BATCH_UPDATE_ENABLED）模式下的额度变动在落库时按来源汇总记账。
+
+const (
+	CreditSourceTopUp          = "topup"
+	CreditSourceRedemption     = "redemption"
+	CreditSourceCheckin        = "checkin"
+	CreditSourceAffiliate      = "affiliate"
+	CreditSourceAdjustment     = "adjustment"
+	CreditSourceRefund         = "refund"
+	CreditSourceOpeningBalance = "opening_balance"
+	CreditSourceUsage          = "usage"
+	CreditSourceOrganization   = "organization"
+	CreditSourceSubscription   = "subscription"
+	CreditSourcePostpaid       = "postpaid"
+	CreditSourceReseller       = "reseller"
+)
+
+const (
+	CreditAccountWallet  = "wallet"
+	CreditAccountFunding = "funding"
+	CreditAccountExpired = "expired"
+)
+
+const (
+	CreditEntryKindOpen     = "open"
+	CreditEntryKindGrant    = "grant"
+	CreditEntryKindDebit    = "debit"
+	CreditEntryKindReversal = "reversal"
+	CreditEntryKindCover    = "cover"
+	CreditEntryKindExpire   = "expire"
+)
+
+const (
+	CreditGrantStatusActive    = "active"
+	CreditGrantStatusExhausted = "exhausted"
+	CreditGrantStatusExpired   = "expired"
+)
+
+// CreditGrant 一笔额度授予及其剩余可用额度
+type CreditGrant struct {
+	Id        int    `json:"id"`
+	UserId    int    `json:"user_id" gorm:"index:idx_credit_grants_user_remaining,priority:1"`
+	Source    string `json:"source" gorm:"type:varchar(32);index"`
+	Reference string `json:"reference" gorm:"type:varchar(128);default:''"`
+	Priority  int    `json:"priority" gorm:"default:100"`
+	Amount    int64  `json:"amount"`
+	Remaining int64  `json:"remaining" gorm:"index:idx_credit_grants_user_remaining,priority:2"`
+	ExpiresAt int64  `json:"expires_at" gorm:"bigint;index;default:0"`
+	Status    string `json:"status" gorm:"type:varchar(16);default:'active'"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+}
+
+// CreditLedgerEntry 账本分录，只追加、不修改
+type CreditLedgerEntry struct {
+	Id        int    `json:"id" gorm:"index:idx_credit_entries_unassigned,priority:4"`
+	TxnId     string `json:"txn_id" gorm:"type:varchar(32);index"`
+	UserId    int    `json:"user_id" gorm:"index:idx_credit_entries_user_created,priority:1;index:idx_credit_entries_unassigned,priority:1"`
+	Account   string `json:"account" gorm:"type:varchar(32);index:idx_credit_entries_unassigned,priority:2"`
+	GrantId   int    `json:"grant_id" gorm:"index;index:idx_credit_entries_unassigned,priority:3;default:0"`
+	Amount    int64  `json:"amount"`
+	Kind      string `json:"kind" gorm:"type:varchar(16)"`
+	Source    string `json:"source" gorm:"type:varchar(32)"`
+	Reference string `json:"reference" gorm:"type:varchar(128);index;default:''"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint;index:idx_credit_entries_user_created,priority:2"`
+}
+
+// CreditSettlement 用户未分摊钱包额度的分摊进度：Id 不超过 EntryId 的未分摊分录
+// 已计入 Unassigned，即分摊后仍未归属任何授予的额度（透支为负）。
+type CreditSettlement struct {
+	UserId     int   `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	EntryId    int   `json:"entry_id"`
+	Unassigned int64 `json:"unassigned"`
+}
+
+// CreditRef 标识一笔钱包额度变动在账本中的对手方与业务引用。
+type CreditRef struct {
+	Source    string
+	Reference string
+}
+
+// creditPosting 一笔账本业务，负责生成同一 TxnId 下的分录
+type creditPosting struct {
+	tx        *gorm.DB
+	txnId     string
+	userId    int
+	source    string
+	reference string
+	now       int64
+}
+
+func newCreditPosting(tx *gorm.DB, userId int, source string, reference string) *creditPosting {
+	return &creditPosting{
+		tx:        tx,
+		txnId:     common.GetUUID(),
+		userId:    userId,
+		source:    source,
+		reference: reference,
+		now:       common.GetTimestamp(),
+	}
+}
+
+func (p *creditPosting) entry(account string, grantId int, amount int64, kind string) error {
+	return p.tx.Create(&CreditLedgerEntry{
+		TxnId:     p.txnId,
+		UserId:    p.userId,
+		Account:   account,
+		GrantId:   grantId,
+		Amount:    amount,
+		Kind:      kind,
+		Source:    p.source,
+		Reference: p.reference,
+		CreatedAt: p.now,
+	}).Error
+}
+
+// runCreditPosting 在 tx（为 nil 时新开事务）中执行账本记账；账本未启用时不做任何事。
+func runCreditPosting(tx *gorm.DB, fn func(tx *gorm.DB) error) error {
+	if !operation_setting.IsCreditLedgerEnabled() {
+		return nil
+	}
+	if tx != nil {
+		return fn(tx)
+	}
+	return DB.Transaction(fn)
+}
+
+// PostCreditGrant 记录一笔授予：按来源策略确定优先级与过期时间。
+// 必须在增加 User.Quota 之前调用（可与其在同一事务内）。
+func PostCreditGrant(tx *gorm.DB, userId int, source string, reference string, amount int) error {
+	if amount <= 0 {
+		return nil
+	}
+	return runCreditPosting(tx, func(tx *gorm.DB) error {
+		if err := ensureCreditLedgerOpened(tx, userId); err != nil {
+			return err
+		}
+		p := newCreditPosting(tx, userId, source, reference)
+		_, err := p.grant(CreditAccountFunding, CreditEntryKindGrant, int64(amount))
+		return err
+	})
+}
+
+// PostCreditDebit 记录一笔扣减，source 为对手方（如 usage、adjustment）。
+// 必须在减少 User.Quota 之前调用。
+func PostCreditDebit(tx *gorm.DB, userId int, source string, reference string, amount int) error {
+	if amount <= 0 {
+		return nil
+	}
+	return runCreditPosting(tx, func(tx *gorm.DB) error {
+		if err := ensureCreditLedgerOpened(tx, userId); err != nil {
+			return err
+		}
+		return newCreditPosting(tx, userId, source, reference).debit(int64(amount))
+	})
+}
+
+// PostCreditReversal 冲回同一 reference 此前的扣减（退款、预扣回滚、差额退还），
+// 额度按扣减的相反顺序退回原授予；找不到可冲回的扣减时记为一笔 refund 授予。
+// 必须在增加 User.Quota 之前调用。
+func PostCreditReversal(tx *gorm.DB, userId int, source string, reference string, amount int) error {
+	if amount <= 0 {
+		return nil
+	}
+	return runCreditPosting(tx, func(tx *gorm.DB) error {
+		if err := ensureCreditLedgerOpened(tx, userId); err != nil {
+			return err
+		}
+		return newCreditPosting(tx, userId, source, reference).reversal(int64(amount))
+	})
+}
+
+// PostCreditUsage 记录一笔计量扣费（quotaDelta < 0）或退还（quotaDelta > 0）。
+// 只写入未分摊的钱包分录，不锁定授予行也不做汇总查询，供请求热路径使用；
+// 分摊到具体授予由 settleCreditUsage 完成。必须与 User.Quota 的变动在同一事务内、
+// 且在其之前调用。
+func PostCreditUsage(tx *gorm.DB, userId int, ref CreditRef, quotaDelta int) error {
+	if quotaDelta == 0 {
+		return nil
+	}
+	return runCreditPosting(tx, func(tx *gorm.DB) error {
+		if err := ensureCreditLedgerOpened(tx, userId); err != nil {
+			return err
+		}
+		p := newCreditPosting(tx, userId, ref.Source, ref.Reference)
+		kind := CreditEntryKindDebit
+		if quotaDelta > 0 {
+			kind = CreditEntryKindReversal
+		}
+		if err := p.entry(CreditAccountWallet, 0, int64(quotaDelta), kind); err != nil {
+			return err
+		}
+		return p.entry(p.source, 0, -int64(quotaDelta), kind)
+	})
+}
+
+// ensureCreditLedgerOpened 锁定用户行，并在用户第一笔账本记录前写入期初余额。
+// 记账随后都会更新 User.Quota，提前持有同一把锁不增加锁冲突；写入未分摊分录的
+// 事务因此都与 settleCreditUsage 互斥，分摊进度不会越过尚未提交的分录。
+func ensureCreditLedgerOpened(tx *gorm.DB, userId int) error {
+	var user User
+	if err := lockForUpdate(tx).Select("id", "quota").Where("id = ?", userId).First(&user).Error; err != nil {
+		return err
+	}
+	opened, err := creditLedgerOpened(tx, userId)
+	if err != nil || opened {
+		return err
+	}
+	p := newCreditPosting(tx, userId, CreditSourceOpeningBalance, "")
+	quota := int64(user.Quota)
+	switch {
+	case quota > 0:
+		_, err = p.grant(CreditAccountFunding, CreditEntryKindOpen, quota)
+		return err
+	case quota < 0:
+		if err := p.entry(CreditAccountWallet, 0, quota, CreditEntryKindOpen); err != nil {
+			return err
+		}
+		return p.entry(CreditAccountFunding, 0, -quota, CreditEntryKindOpen)
+	default:
+		return p.entry(CreditAccountWallet, 0, 0, CreditEntryKindOpen)
+	}
+}
+
+func creditLedgerOpened(tx *gorm.DB, userId int) (bool, error) {
+	var ids []int
+	if err := tx.Model(&CreditLedgerEntry{}).Where("user_id = ?", userId).Limit(1).Pluck("id", &ids).Error; err != nil {
+		return false, err
+	}
+	return len(ids) > 0, nil
+}
+
+// grant 新建授予并记入钱包，再分摊未分摊的扣费与透支。
+func (p *creditPosting) grant(counterAccount string, kind string, amount int64) (*CreditGrant, error) {
+	policy := operation_setting.GetCreditGrantPolicy(p.source)
+	grant := &CreditGrant{
+		UserId:    p.userId,
+		Source:    p.source,
+		Reference: p.reference,
+		Priority:  policy.Priority,
+		Amount:    amount,
+		Remaining: amount,
+		ExpiresAt: policy.ExpiresAt(time.Unix(p.now, 0)),
+		Status:    CreditGrantStatusActive,
+		CreatedAt: p.now,
+	}
+	if err := p.tx.Create(grant).Error; err != nil {
+		return nil, err
+	}
+	if err := p.entry(CreditAccountWallet, grant.Id, amount, kind); err != nil {
+		return nil, err
+	}
+	if err := p.entry(counterAccount, 0, -amount, kind); err != nil {
+		return nil, err
+	}
+	return grant, settleCreditUsage(p.tx, p.userId, p.now)
+}
+
+// creditGrantConsumeOrder 授予的消费顺序：优先级小的先用，同优先级先到期的先用，
+// 永不过期的最后用。
+const creditGrantConsumeOrder = "priority asc, CASE WHEN expires_at = 0 THEN 1 ELSE 0 END asc, expires_at asc, id asc"
+
+func (p *creditPosting) debit(amount int64) error {
+	var grants []*CreditGrant
+	err := lockForUpdate(p.tx).
+		Where("user_id = ? AND remaining > 0 AND (expires_at = 0 OR expires_at > ?)", p.userId, p.now).
+		Order(creditGrantConsumeOrder).
+		Find(&grants).Error
+	if err != nil {
+		return err
+	}
+	left := amount
+	for _, grant := range grants {
+		if left == 0 {
+			break
+		}
+		take := min(left, grant.Remaining)
+		grant.Remaining -= take
+		if grant.Remaining == 0 {
+			grant.Status = CreditGrantStatusExhausted
+		}
+		if err := p.tx.Model(grant).Select("remaining", "status").Updates(grant).Error; err != nil {
+			return err
+		}
+		if err := p.entry(CreditAccountWallet, grant.Id, -take, CreditEntryKindDebit); err != nil {
+			return err
+		}
+		left -= take
+	}
+	if left > 0 {
+		// 授予不足的部分记为透支，由后续授予抵补。
+		if err := p.entry(CreditAccountWallet, 0, -left, CreditEntryKindDebit); err != nil {
+			return err
+		}
+	}
+	return p.entry(p.source, 0, amount, CreditEntryKindDebit)
+}
+
+// settleCreditUsage 把未分摊的钱包额度（GrantId=0 分录之和）分摊到授予上：
+// 为负时按消费顺序从仍有效的授予中扣除，余下部分保留为透支；为正时（热路径
+// 退还了已分摊的扣费）按消费倒序补回仍有效授予已消费的部分，补不回的部分
+// 留作不归属任何授予的余额。保证各授予剩余额度之和 + 未分摊额度 = 钱包余额。
+//
+// 未分摊额度由 CreditSettlement 记录的上次结果加上其后新增的分录得出。写入未分摊
+// 分录的记账都持有用户行锁，这里同样先锁用户行，确保推进进度时没有未提交的分录。
+func settleCreditUsage(tx *gorm.DB, userId int, now int64) error {
+	if err := lockForUpdate(tx).Select("id").Where("id = ?", userId).First(&User{}).Error; err != nil {
+		return err
+	}
+	settlement := CreditSettlement{UserId: userId}
+	if err := tx.Where("user_id = ?", userId).Limit(1).Find(&settlement).Error; err != nil {
+		return err
+	}
+	var pending struct {
+		Amount  int64
+		EntryId int
+	}
+	if err := tx.Model(&CreditLedgerEntry{}).
+		Where("user_id = ? AND account = ? AND grant_id = 0 AND id > ?", userId, CreditAccountWallet, settlement.EntryId).
+		Select("COALESCE(SUM(amount), 0) AS amount, COALESCE(MAX(id), 0) AS entry_id").Scan(&pending).Error; err != nil {
+		return err
+	}
+	if pending.EntryId == 0 && settlement.Unassigned == 0 {
+		return nil
+	}
+	if pending.EntryId > 0 {
+		settlement.EntryId = pending.EntryId
+		settlement.Unassigned += pending.Amount
+	}
+	previous := settlement
+	p := &creditPosting{tx: tx, txnId: common.GetUUID(), userId: userId, source: CreditSourceUsage, now: now}
+	if err := p.assignUnassigned(&settlement); err != nil {
+		return err
+	}
+	if pending.EntryId == 0 && settlement == previous {
+		return nil
+	}
+	return tx.Save(&settlement).Error
+}
+
+// assignUnassigned 执行 settleCreditUsage 的分摊，新写入的未分摊分录同步计入 settlement。
+func (p *creditPosting) assignUnassigned(settlement *CreditSettlement) error {
+	tx, userId, now := p.tx, p.userId, p.now
+	unassigned := settlement.Unassigned
+	if unassigned == 0 {
+		return nil
+	}
+	var grants []*CreditGrant
+	if unassigned < 0 {
+		err := lockForUpdate(tx).
+			Where("user_id = ? AND remaining > 0 AND (expires_at = 0 OR expires_at > ?)", userId, now).
+			Order(creditGrantConsumeOrder).
+			Find(&grants).Error
+		if err != nil {
+			return err
+		}
+		left := -unassigned
+		for _, grant := range grants {
+			if left == 0 {
+				break
+			}
+			take := min(left, grant.Remaining)
+			grant.Remaining -= take
+			if grant.Remaining == 0 {
+				grant.Status = CreditGrantStatusExhausted
+			}
+			if err := p.moveUnassigned(settlement, grant, -take); err != nil {
+				return err
+			}
+			left -= take
+		}
+		return nil
+	}
+	err := lockForUpdate(tx).
+		Where("user_id = ? AND remaining < amount AND status <> ? AND (expires_at = 0 OR expires_at > ?)", userId, CreditGrantStatusExpired, now).
+		Order(creditGrantConsumeOrder).
+		Find(&grants).Error
+	if err != nil {
+		return err
+	}
+	left := unassigned
+	for i := len(grants) - 1; i >= 0 && left > 0; i-- {
+		grant := grants[i]
+		back := min(left, grant.Amount-grant.Remaining)
+		grant.Remaining += back
+		grant.Status = CreditGrantStatusActive
+		if err := p.moveUnassigned(settlement, grant, back); err != nil {
+			return err
+		}
+		left -= back
+	}
+	return nil
+}
+
+// moveUnassigned 在授予与未分摊额度之间转移 amount（正数转入授予），grant 已更新剩余额度。
+func (p *creditPosting) moveUnassigned(settlement *CreditSettlement, grant *CreditGrant, amount int64) error {
+	if err := p.tx.Model(grant).Select("remaining", "status").Updates(grant).Error; err != nil {
+		return err
+	}
+	if err := p.entry(CreditAccountWallet, grant.Id, amount, CreditEntryKindCover); err != nil {
+		return err
+	}
+	cover := &CreditLedgerEntry{
+		TxnId:     p.txnId,
+		UserId:    p.userId,
+		Account:   CreditAccountWallet,
+		Amount:    -amount,
+		Kind:      CreditEntryKindCover,
+		Source:    p.source,
+		Reference: p.reference,
+		CreatedAt: p.now,
+	}
+	if err := p.tx.Create(cover).Error; err != nil {
+		return err
+	}
+	settlement.EntryId = cover.Id
+	settlement.Unassigned -= amount
+	return nil
+}
+
+type creditGrantNet struct {
+	GrantId int
+	Net     int64
+}
+
+func (p *creditPosting) reversal(amount int64) error {
+	left := amount
+	if p.reference != "" {
+		var nets []creditGrantNet
+		err := p.tx.Model(&CreditLedgerEntry{}).
+			Select("grant_id, SUM(amount) AS net").
+			Where("user_id = ? AND account = ? AND reference = ? AND kind IN ?", p.userId, CreditAccountWallet, p.reference,
+				[]string{CreditEntryKindDebit, CreditEntryKindReversal}).
+			Group("grant_id").
+			Scan(&nets).Error
+		if err != nil {
+			return err
+		}
+		outstanding := make(map[int]int64, len(nets))
+		grantIds := make([]int, 0, len(nets))
+		for _, n := range nets {
+			if n.Net < 0 {
+				outstanding[n.GrantId] = -n.Net
+				if n.GrantId != 0 {
+					grantIds = append(grantIds, n.GrantId)
+				}
+			}
+		}
+		// 透支是最后扣的，最先冲回。
+		if owed := outstanding[0]; owed > 0 {
+			back := min(left, owed)
+			if err := p.entry(CreditAccountWallet, 0, back, CreditEntryKindReversal); err != nil {
+				return err
+			}
+			left -= back
+		}
+		if left > 0 && len(grantIds) > 0 {
+			var grants []*CreditGrant
+			if err := lockForUpdate(p.tx).Where("id IN ?", grantIds).Order(creditGrantConsumeOrder).Find(&grants).Error; err != nil {
+				return err
+			}
+			for i := len(grants) - 1; i >= 0 && left > 0; i-- {
+				grant := grants[i]
+				back := min(left, outstanding[grant.Id])
+				grant.Remaining += back
+				// 已到期的授予先恢复为 active，由过期任务再次注销。
+				grant.Status = CreditGrantStatusActive
+				if err := p.tx.Model(grant).Select("remaining", "status").Updates(grant).Error; err != nil {
+					return err
+				}
+				if err := p.entry(CreditAccountWallet, grant.Id, back, CreditEntryKindReversal); err != nil {
+					return err
+				}
+				left -= back
+			}
+		}
+		if restored := amount - left; restored > 0 {
+			if err := p.entry(p.source, 0, -restored, CreditEntryKindReversal); err != nil {
+				return err
+			}
+		}
+	}
+	if left > 0 {
+		refund := &creditPosting{tx: p.tx, txnId: p.txnId, userId: p.userId, source: CreditSourceRefund, reference: p.reference, now: p.now}
+		if _, err := refund.grant(p.source, CreditEntryKindReversal, left); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpireDueCreditGrants 注销已到期授予的剩余额度，并同步扣减 User.Quota。
+// 返回本批处理的授予数量。
+func ExpireDueCreditGrants(limit int) (int, error) {
+	now := common.GetTimestamp()
+	var ids []int
+	err := DB.Model(&CreditGrant{}).
+		Where("expires_at > 0 AND expires_at <= ? AND remaining > 0", now).
+		Order("expires_at asc").Limit(limit).Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	for _, id := range ids {
+		if err := expireCreditGrant(id, now); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+// expireCreditGrant 注销一笔到期授予。先分摊未分摊的扣费，再以用户当前余额
+// 扣除其他有效授予后的部分为上限扣减 User.Quota，账本与余额存在差异时不会
+// 误扣用户已付费的额度；超出上限的部分记入 adjustment 以抹平差异。
+func expireCreditGrant(grantId int, now int64) error {
+	var userId int
+	var expired int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var grant CreditGrant
+		if err := tx.Select("id", "user_id").Where("id = ?", grantId).First(&grant).Error; err != nil {
+			return err
+		}
+		// 与扣费路径一致，先锁用户行再锁授予行。
+		var user User
+		if err := lockForUpdate(tx).Select("id", "quota").Where("id = ?", grant.UserId).First(&user).Error; err != nil {
+			return err
+		}
+		if err := settleCreditUsage(tx, grant.UserId, now); err != nil {
+			return err
+		}
+		if err := lockForUpdate(tx).Where("id = ?", grantId).First(&grant).Error; err != nil {
+			return err
+		}
+		if grant.Remaining <= 0 || grant.ExpiresAt == 0 || grant.ExpiresAt > now {
+			return nil
+		}
+		var others int64
+		if err := tx.Model(&CreditGrant{}).
+			Where("user_id = ? AND id <> ? AND remaining > 0 AND (expires_at = 0 OR expires_at > ?)", grant.UserId, grant.Id, now).
+			Select("COALESCE(SUM(remaining), 0)").Scan(&others).Error; err != nil {
+			return err
+		}
+		writtenOff := grant.Remaining
+		userId = grant.UserId
+		expired = max(0, min(writtenOff, int64(user.Quota)-others))
+		grant.Remaining = 0
+		grant.Status = CreditGrantStatusExpired
+		if err := tx.Model(&grant).Select("remaining", "status").Updates(&grant).Error; err != nil {
+			return err
+		}
+		p := &creditPosting{tx: tx, txnId: common.GetUUID(), userId: grant.UserId, source: grant.Source, reference: grant.Reference, now: now}
+		if err := p.entry(CreditAccountWallet, grant.Id, -writtenOff, CreditEntryKindExpire); err != nil {
+			return err
+		}
+		if err := p.entry(CreditAccountExpired, 0, expired, CreditEntryKindExpire); err != nil {
+			return err
+		}
+		if drift := writtenOff - expired; drift > 0 {
+			if err := p.entry(CreditSourceAdjustment, 0, drift, CreditEntryKindExpire); err != nil {
+				return err
+			}
+		}
+		if expired == 0 {
+			return nil
+		}
+		return tx.Model(&User{}).Where("id = ?", grant.UserId).Update("quota", gorm.Expr("quota - ?", expired)).Error
+	})
+	if err != nil || expired == 0 {
+		return err
+	}
+	if cacheErr := cacheDecrUserQuota(userId, expired); cacheErr != nil {
+		common.SysLog(fmt.Sprintf("failed to sync expired credit to user quota cache: %s", cacheErr.Error()))
+	}
+	RecordLog(userId, LogTypeSystem, fmt.Sprintf("额度授予 #%d 已过期，注销剩余额度 %d", grantId, expired))
+	return nil
+}
+
+// GetCreditLedgerBalance 返回用户在 at（含）时点的账本余额；at 为 0 表示当前。
+func GetCreditLedgerBalance(userId int, at int64) (int64, error) {
+	tx := DB.Model(&CreditLedgerEntry{}).Where("user_id = ? AND account = ?", userId, CreditAccountWallet)
+	if at > 0 {
+		tx = tx.Where("created_at <= ?", at)
+	}
+	var balance int64
+	err := tx.Select("COALESCE(SUM(amount), 0)").Scan(&balance).Error
+	return balance, err
+}
+
+// GetUserCreditGrants 列出用户的授予，可只看仍有剩余的。列出前先分摊未分摊的扣费，
+// 使剩余额度反映最新的消费。
+func GetUserCreditGrants(userId int, activeOnly bool) ([]*CreditGrant, error) {
+	if operation_setting.IsCreditLedgerEnabled() {
+		if err := DB.Transaction(func(tx *gorm.DB) error {
+			return settleCreditUsage(tx, userId, common.GetTimestamp())
+		}); err != nil {
+			return nil, err
+		}
+	}
+	tx := DB.Where("user_id = ?", userId)
+	if activeOnly {
+		tx = tx.Where("remaining > 0 AND (expires_at = 0 OR expires_at > ?)", common.GetTimestamp())
+	}
+	var grants []*CreditGrant
+	err := tx.Order(creditGrantConsumeOrder).Find(&grants).Error
+	return grants, err
+}
+
+// GetUserCreditLedgerEntries 分页列出用户的钱包分录，最新的在前。
+func GetUserCreditLedgerEntries(userId int, startIdx int, num int) ([]*CreditLedgerEntry, int64, error) {
+	tx := DB.Model(&CreditLedgerEntry{}).Where("user_id = ? AND account = ?", userId, CreditAccountWallet)
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var entries []*CreditLedgerEntry
+	err := tx.Order("id desc").Limit(num).Offset(startIdx).Find(&entries).Error
+	return entries, total, err
+}
+
+// CreditLedgerMismatch 对账差异：Quota 为 User.Quota，Balance 为账本钱包余额，
+// GrantBalance 为各授予剩余之和加透支，三者应相等。
+type CreditLedgerMismatch struct {
+	UserId       int   `json:"user_id"`
+	Quota        int64 `json:"quota"`
+	Balance      int64 `json:"balance"`
+	GrantBalance int64 `json:"grant_balance"`
+}
+
+var ErrCreditLedgerNotOpened = errors.New("credit ledger has no entries for this user")
+
+// ReconcileCreditLedger 逐个比对已开户用户的 User.Quota 与账本余额。userId 为 0
+// 时检查全部用户，只返回存在差异的用户。
+func ReconcileCreditLedger(userId int) ([]CreditLedgerMismatch, int, error) {
+	type walletSum struct {
+		UserId  int
+		Balance int64
+	}
+	query := DB.Model(&CreditLedgerEntry{}).
+		Select("user_id, SUM(amount) AS balance").
+		Where("account = ?", CreditAccountWallet)
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	var sums []walletSum
+	if err := query.Group("user_id").Scan(&sums).Error; err != nil {
+		return nil, 0, err
+	}
+	mismatches := make([]CreditLedgerMismatch, 0)
+	for _, sum := range sums {
+		var user User
+		if err := DB.Select("id", "quota").Where("id = ?", sum.UserId).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return nil, 0, err
+		}
+		grantBalance, err := creditGrantBalance(sum.UserId)
+		if err != nil {
+			return nil, 0, err
+		}
+		if int64(user.Quota) != sum.Balance || grantBalance != sum.Balance {
+			mismatches = append(mismatches, CreditLedgerMismatch{
+				UserId:       sum.UserId,
+				Quota:        int64(user.Quota),
+				Balance:      sum.Balance,
+				GrantBalance: grantBalance,
+			})
+		}
+	}
+	return mismatches, len(sums), nil
+}
+
+func creditGrantBalance(userId int) (int64, error) {
+	var remaining, overdraft int64
+	if err := DB.Model(&CreditGrant{}).Where("user_id = ?", userId).
+		Select("COALESCE(SUM(remaining), 0)").Scan(&remaining).Error; err != nil {
+		return 0, err
+	}
+	if err := DB.Model(&CreditLedgerEntry{}).
+		Where("user_id = ? AND account = ? AND grant_id = 0", userId, CreditAccountWallet).
+		Select("COALESCE(SUM(amount), 0)").Scan(&overdraft).Error; err != nil {
+		return 0, err
+	}
+	return remaining + overdraft, nil
+}
+
+// RepairCreditLedger 以 User.Quota 为准，用一笔 adjustment 授予或扣减抹平账本差额，
+// 返回调整金额（正数为补记授予）。
+func RepairCreditLedger(userId int) (int64, error) {
+	var delta int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		opened, err := creditLedgerOpened(tx, userId)
+		if err != nil {
+			return err
+		}
+		if !opened {
+			return ErrCreditLedgerNotOpened
+		}
+		var user User
+		if err := lockForUpdate(tx).Select("id", "quota").Where("id = ?", userId).First(&user).Error; err != nil {
+			return err
+		}
+		var balance int64
+		if err := tx.Model(&CreditLedgerEntry{}).Where("user_id = ? AND account = ?", userId, CreditAccountWallet).
+			Select("COALESCE(SUM(amount), 0)").Scan(&balance).Error; err != nil {
+			return err
+		}
+		delta = int64(user.Quota) - balance
+		p := newCreditPosting(tx, userId, CreditSourceAdjustment, "reconcile")
+		switch {
+		case delta > 0:
+			_, err = p.grant(CreditAccountFunding, CreditEntryKindGrant, delta)
+		case delta < 0:
+			err = p.debit(-delta)
+		}
+		return err
+	})
+	return delta, err
+}
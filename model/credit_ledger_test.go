@@ -0,0 +1,228 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func enableCreditLedgerForTest(t *testing.T) {
+	t.Helper()
+	setting := operation_setting.GetCreditLedgerSetting()
+	prev := setting.Enabled
+	setting.Enabled = true
+	t.Cleanup(func() { setting.Enabled = prev })
+}
+
+func creditTestQuota(t *testing.T, userId int) int64 {
+	t.Helper()
+	var user User
+	require.NoError(t, DB.Select("quota").Where("id = ?", userId).First(&user).Error)
+	return int64(user.Quota)
+}
+
+func TestCreditLedgerOpensWithExistingBalance(t *testing.T) {
+	truncateTables(t)
+	enableCreditLedgerForTest(t)
+	user := createReserveTestUser(t, 1000)
+
+	require.NoError(t, PostCreditGrant(nil, user.Id, CreditSourceRedemption, "redemption:1", 500))
+	require.NoError(t, DB.Model(&User{}).Where("id = ?", user.Id).Update("quota", 1500).Error)
+
+	balance, err := GetCreditLedgerBalance(user.Id, 0)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1500, balance)
+
+	grants, err := GetUserCreditGrants(user.Id, true)
+	require.NoError(t, err)
+	require.Len(t, grants, 2)
+	assert.Equal(t, CreditSourceOpeningBalance, grants[0].Source)
+	assert.EqualValues(t, 1000, grants[0].Remaining)
+
+	mismatches, checked, err := ReconcileCreditLedger(user.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 1, checked)
+	assert.Empty(t, mismatches)
+}
+
+func TestCreditLedgerConsumesPriorityGrantFirstAndReverses(t *testing.T) {
+	truncateTables(t)
+	enableCreditLedgerForTest(t)
+	user := createReserveTestUser(t, 0)
+
+	require.NoError(t, PostCreditGrant(nil, user.Id, CreditSourceTopUp, "topup:a", 1000))
+	require.NoError(t, PostCreditGrant(nil, user.Id, CreditSourceCheckin, "checkin:1", 200))
+	require.NoError(t, DB.Model(&User{}).Where("id = ?", user.Id).Update("quota", 1200).Error)
+
+	require.NoError(t, PostCreditDebit(nil, user.Id, CreditSourceUsage, "req-1", 300))
+	grants, err := GetUserCreditGrants(user.Id, false)
+	require.NoError(t, err)
+	require.Len(t, grants, 2)
+	assert.Equal(t, CreditSourceCheckin, grants[0].Source)
+	assert.EqualValues(t, 0, grants[0].Remaining)
+	assert.Equal(t, CreditGrantStatusExhausted, grants[0].Status)
+	assert.EqualValues(t, 900, grants[1].Remaining)
+
+	require.NoError(t, PostCreditReversal(nil, user.Id, CreditSourceUsage, "req-1", 150))
+	grants, err = GetUserCreditGrants(user.Id, false)
+	require.NoError(t, err)
+	// 按消费倒序退回：先补满最后被消费的充值授予，余下退回签到授予。
+	assert.EqualValues(t, 50, grants[0].Remaining)
+	assert.EqualValues(t, 1000, grants[1].Remaining)
+
+	balance, err := GetCreditLedgerBalance(user.Id, 0)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1050, balance)
+}
+
+func TestCreditLedgerOverdraftIsCoveredByNextGrant(t *testing.T) {
+	truncateTables(t)
+	enableCreditLedgerForTest(t)
+	user := createReserveTestUser(t, 100)
+
+	require.NoError(t, PostCreditDebit(nil, user.Id, CreditSourceUsage, "req-1", 150))
+	require.NoError(t, PostCreditGrant(nil, user.Id, CreditSourceTopUp, "topup:b", 500))
+
+	grantBalance, err := creditGrantBalance(user.Id)
+	require.NoError(t, err)
+	assert.EqualValues(t, 450, grantBalance)
+	balance, err := GetCreditLedgerBalance(user.Id, 0)
+	require.NoError(t, err)
+	assert.EqualValues(t, 450, balance)
+}
+
+func TestCreditLedgerExpiresDueGrants(t *testing.T) {
+	truncateTables(t)
+	enableCreditLedgerForTest(t)
+	user := createReserveTestUser(t, 0)
+
+	require.NoError(t, PostCreditGrant(nil, user.Id, CreditSourceCheckin, "checkin:1", 200))
+	require.NoError(t, PostCreditGrant(nil, user.Id, CreditSourceTopUp, "topup:c", 800))
+	require.NoError(t, DB.Model(&User{}).Where("id = ?", user.Id).Update("quota", 1000).Error)
+	require.NoError(t, PostCreditDebit(nil, user.Id, CreditSourceUsage, "req-1", 50))
+	require.NoError(t, DB.Model(&User{}).Where("id = ?", user.Id).Update("quota", 950).Error)
+
+	require.NoError(t, DB.Model(&CreditGrant{}).Where("source = ?", CreditSourceCheckin).
+		Update("expires_at", common.GetTimestamp()-1).Error)
+	expired, err := ExpireDueCreditGrants(10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+
+	assert.EqualValues(t, 800, creditTestQuota(t, user.Id))
+	balance, err := GetCreditLedgerBalance(user.Id, 0)
+	require.NoError(t, err)
+	assert.EqualValues(t, 800, balance)
+
+	mismatches, _, err := ReconcileCreditLedger(user.Id)
+	require.NoError(t, err)
+	assert.Empty(t, mismatches)
+}
+
+func TestCreditLedgerRepairMatchesQuota(t *testing.T) {
+	truncateTables(t)
+	enableCreditLedgerForTest(t)
+	user := createReserveTestUser(t, 0)
+
+	_, err := RepairCreditLedger(user.Id)
+	assert.ErrorIs(t, err, ErrCreditLedgerNotOpened)
+
+	require.NoError(t, PostCreditGrant(nil, user.Id, CreditSourceTopUp, "topup:d", 500))
+	require.NoError(t, DB.Model(&User{}).Where("id = ?", user.Id).Update("quota", 420).Error)
+
+	mismatches, _, err := ReconcileCreditLedger(user.Id)
+	require.NoError(t, err)
+	require.Len(t, mismatches, 1)
+	assert.EqualValues(t, 420, mismatches[0].Quota)
+	assert.EqualValues(t, 500, mismatches[0].Balance)
+
+	delta, err := RepairCreditLedger(user.Id)
+	require.NoError(t, err)
+	assert.EqualValues(t, -80, delta)
+
+	mismatches, _, err = ReconcileCreditLedger(user.Id)
+	require.NoError(t, err)
+	assert.Empty(t, mismatches)
+}
+
+func TestCreditLedgerUsagePostsWithQuotaAndSettlesOnListing(t *testing.T) {
+	truncateTables(t)
+	enableCreditLedgerForTest(t)
+	user := createReserveTestUser(t, 0)
+
+	require.NoError(t, GrantUserQuota(user.Id, 200, CreditSourceCheckin, "checkin:1"))
+	require.NoError(t, GrantUserQuota(user.Id, 1000, CreditSourceTopUp, "topup:e"))
+	ref := CreditRef{Source: CreditSourceUsage, Reference: "req-1"}
+	reserved, err := TryReserveUserQuota(user.Id, 300, ref)
+	require.NoError(t, err)
+	require.True(t, reserved)
+	require.NoError(t, IncreaseUserQuota(user.Id, 50, true, ref))
+
+	assert.EqualValues(t, 950, creditTestQuota(t, user.Id))
+	mismatches, _, err := ReconcileCreditLedger(user.Id)
+	require.NoError(t, err)
+	assert.Empty(t, mismatches)
+
+	grants, err := GetUserCreditGrants(user.Id, false)
+	require.NoError(t, err)
+	require.Len(t, grants, 2)
+	assert.Equal(t, CreditSourceCheckin, grants[0].Source)
+	assert.EqualValues(t, 0, grants[0].Remaining)
+	assert.EqualValues(t, 950, grants[1].Remaining)
+}
+
+func TestCreditLedgerSettlementOnlyReadsNewUnassignedEntries(t *testing.T) {
+	truncateTables(t)
+	enableCreditLedgerForTest(t)
+	user := createReserveTestUser(t, 0)
+
+	require.NoError(t, GrantUserQuota(user.Id, 100, CreditSourceTopUp, "topup:f"))
+	require.NoError(t, PostCreditUsage(nil, user.Id, CreditRef{Source: CreditSourceUsage, Reference: "req-1"}, -150))
+	_, err := GetUserCreditGrants(user.Id, false)
+	require.NoError(t, err)
+
+	var settlement CreditSettlement
+	require.NoError(t, DB.Where("user_id = ?", user.Id).First(&settlement).Error)
+	assert.EqualValues(t, -50, settlement.Unassigned)
+	var lastId int
+	require.NoError(t, DB.Model(&CreditLedgerEntry{}).
+		Where("user_id = ? AND account = ? AND grant_id = 0", user.Id, CreditAccountWallet).
+		Select("MAX(id)").Scan(&lastId).Error)
+	assert.Equal(t, lastId, settlement.EntryId)
+
+	// 已分摊的分录不再参与汇总，进度之前的历史被改动也不影响结果
+	require.NoError(t, DB.Model(&CreditLedgerEntry{}).
+		Where("user_id = ? AND account = ? AND grant_id = 0 AND id <= ?", user.Id, CreditAccountWallet, settlement.EntryId).
+		Update("amount", 0).Error)
+	require.NoError(t, GrantUserQuota(user.Id, 80, CreditSourceTopUp, "topup:g"))
+	grants, err := GetUserCreditGrants(user.Id, false)
+	require.NoError(t, err)
+	require.Len(t, grants, 2)
+	assert.EqualValues(t, 0, grants[0].Remaining)
+	assert.EqualValues(t, 30, grants[1].Remaining)
+	require.NoError(t, DB.Where("user_id = ?", user.Id).First(&settlement).Error)
+	assert.EqualValues(t, 0, settlement.Unassigned)
+}
+
+func TestCreditLedgerExpiryNeverTakesPaidBalance(t *testing.T) {
+	truncateTables(t)
+	enableCreditLedgerForTest(t)
+	user := createReserveTestUser(t, 0)
+
+	require.NoError(t, GrantUserQuota(user.Id, 200, CreditSourceCheckin, "checkin:1"))
+	require.NoError(t, GrantUserQuota(user.Id, 800, CreditSourceTopUp, "topup:f"))
+	// 账本外的扣减使 User.Quota 低于授予剩余之和。
+	require.NoError(t, DB.Model(&User{}).Where("id = ?", user.Id).Update("quota", 900).Error)
+
+	require.NoError(t, DB.Model(&CreditGrant{}).Where("source = ?", CreditSourceCheckin).
+		Update("expires_at", common.GetTimestamp()-1).Error)
+	expired, err := ExpireDueCreditGrants(10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+
+	// 只注销用户当前余额中超出付费授予的 100，付费的 800 不受影响。
+	assert.EqualValues(t, 800, creditTestQuota(t, user.Id))
+}
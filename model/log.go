@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/types"
 
@@ -77,6 +78,10 @@ type Log struct {
 	Ip                string `json:"ip" gorm:"index;default:''"`
 	RequestId         string `json:"request_id,omitempty" gorm:"type:varchar(64);index:idx_logs_request_id;default:''"`
 	UpstreamRequestId string `json:"upstream_request_id,omitempty" gorm:"type:varchar(128);index:idx_logs_upstream_request_id;default:''"`
+	OrgId             int    `json:"org_id,omitempty" gorm:"index;default:0"`
+	Project           string `json:"project,omitempty" gorm:"type:varchar(64);index;default:''"`
+	UpstreamCost      int    `json:"upstream_cost" gorm:"default:0"`
+	PriceBookVersion  int    `json:"price_book_version,omitempty" gorm:"default:0"`
 	Other             string `json:"other"`
 }
 
@@ -226,6 +231,7 @@ func RecordLoginLog(userId int, username string, content string, ip string, acti
 // action+params 写入 Other.op，供前端本地化渲染（普通用户可见，不含敏感信息）。
 // adminInfo 存放操作者身份（写入 Other.admin_info，普通用户查询时剥离）；
 // auditInfo 存放路由/方法/结果等中间件兜底信息（写入 Other.audit_info，普通用户查询时剥离）。
+// 同一条记录还会追加到哈希链审计存储（AuditLog），见 AppendAuditLog。
 func RecordOperationAuditLog(logUserId int, content string, ip string, action string, params map[string]interface{}, adminInfo map[string]interface{}, auditInfo map[string]interface{}) {
 	username, _ := GetUsernameById(logUserId, false)
 	other := map[string]interface{}{
@@ -249,6 +255,31 @@ func RecordOperationAuditLog(logUserId int, content string, ip string, action st
 	if err := createLog(log); err != nil {
 		common.SysLog("failed to record operation audit log: " + err.Error())
 	}
+
+	// 同步写入独立的哈希链审计存储（不受日志清理任务影响）。
+	actorRole, _ := adminInfo["admin_role"].(int)
+	targetType, targetId := auditLogTarget(action, params, auditInfo)
+	details := map[string]interface{}{}
+	if len(params) > 0 {
+		details["params"] = params
+	}
+	if len(adminInfo) > 0 {
+		details["admin_info"] = adminInfo
+	}
+	if len(auditInfo) > 0 {
+		details["audit_info"] = auditInfo
+	}
+	recordAuditLogEntry(AuditLogEntry{
+		ActorId:    logUserId,
+		ActorName:  username,
+		ActorRole:  actorRole,
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   targetId,
+		Ip:         ip,
+		Content:    content,
+		Details:    details,
+	})
 }
 
 func RecordTopupLog(userId int, content string, callerIp string, paymentMethod string, callbackPaymentMethod string) {
@@ -338,6 +369,9 @@ type RecordConsumeLogParams struct {
 	IsStream         bool                   `json:"is_stream"`
 	Group            string                 `json:"group"`
 	Other            map[string]interface{} `json:"other"`
+	// UpstreamCost 上游成本（额度单位），仅 CostTracked 为 true 时有意义
+	UpstreamCost int  `json:"upstream_cost"`
+	CostTracked  bool `json:"cost_tracked"`
 }
 
 func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams) {
@@ -381,6 +415,10 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 		}(),
 		RequestId:         requestId,
 		UpstreamRequestId: upstreamRequestId,
+		OrgId:             common.GetContextKeyInt(c, constant.ContextKeyTokenOrgId),
+		Project:           common.GetContextKeyString(c, constant.ContextKeyProject),
+		UpstreamCost:      params.UpstreamCost,
+		PriceBookVersion:  GetActivePriceBookVersion(),
 		Other:             otherStr,
 	}
 	err := createLog(log)
@@ -389,16 +427,19 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 	}
 	if common.DataExportEnabled {
 		LogQuotaData(QuotaDataLogParams{
-			UserID:    userId,
-			Username:  username,
-			ModelName: params.ModelName,
-			Quota:     params.Quota,
-			CreatedAt: createdAt,
-			TokenUsed: params.PromptTokens + params.CompletionTokens,
-			UseGroup:  params.Group,
-			TokenID:   params.TokenId,
-			ChannelID: params.ChannelId,
-			NodeName:  common.NodeName,
+			UserID:       userId,
+			Username:     username,
+			ModelName:    params.ModelName,
+			Quota:        params.Quota,
+			CreatedAt:    createdAt,
+			TokenUsed:    params.PromptTokens + params.CompletionTokens,
+			UseGroup:     params.Group,
+			TokenID:      params.TokenId,
+			ChannelID:    params.ChannelId,
+			NodeName:     common.NodeName,
+			Project:      log.Project,
+			UpstreamCost: params.UpstreamCost,
+			CostTracked:  params.CostTracked,
 		})
 	}
 }
@@ -414,6 +455,10 @@ type RecordTaskBillingLogParams struct {
 	Group     string
 	Other     map[string]interface{}
 	NodeName  string // 任务发起节点；为空时回退当前节点
+	Project   string // 任务提交时的成本归属项目
+
+	UpstreamCost int // 上游成本，仅 CostTracked 为 true 时有意义
+	CostTracked  bool
 }
 
 func RecordTaskBillingLog(params RecordTaskBillingLogParams) {
@@ -422,25 +467,31 @@ func RecordTaskBillingLog(params RecordTaskBillingLogParams) {
 	}
 	username, _ := GetUsernameById(params.UserId, false)
 	tokenName := ""
+	orgId := 0
 	if params.TokenId > 0 {
 		if token, err := GetTokenById(params.TokenId); err == nil {
 			tokenName = token.Name
+			orgId = token.OrgId
 		}
 	}
 	createdAt := common.GetTimestamp()
 	log := &Log{
-		UserId:    params.UserId,
-		Username:  username,
-		CreatedAt: createdAt,
-		Type:      params.LogType,
-		Content:   params.Content,
-		TokenName: tokenName,
-		ModelName: params.ModelName,
-		Quota:     params.Quota,
-		ChannelId: params.ChannelId,
-		TokenId:   params.TokenId,
-		Group:     params.Group,
-		Other:     common.MapToJsonStr(params.Other),
+		UserId:           params.UserId,
+		Username:         username,
+		CreatedAt:        createdAt,
+		Type:             params.LogType,
+		Content:          params.Content,
+		TokenName:        tokenName,
+		ModelName:        params.ModelName,
+		Quota:            params.Quota,
+		ChannelId:        params.ChannelId,
+		TokenId:          params.TokenId,
+		Group:            params.Group,
+		OrgId:            orgId,
+		Project:          params.Project,
+		Other:            common.MapToJsonStr(params.Other),
+		UpstreamCost:     params.UpstreamCost,
+		PriceBookVersion: GetActivePriceBookVersion(),
 	}
 	err := createLog(log)
 	if err != nil {
@@ -452,15 +503,18 @@ func RecordTaskBillingLog(params RecordTaskBillingLogParams) {
 			nodeName = common.NodeName
 		}
 		LogQuotaData(QuotaDataLogParams{
-			UserID:    params.UserId,
-			Username:  username,
-			ModelName: params.ModelName,
-			Quota:     params.Quota,
-			CreatedAt: createdAt,
-			UseGroup:  params.Group,
-			TokenID:   params.TokenId,
-			ChannelID: params.ChannelId,
-			NodeName:  nodeName,
+			UserID:       params.UserId,
+			Username:     username,
+			ModelName:    params.ModelName,
+			Quota:        params.Quota,
+			CreatedAt:    createdAt,
+			UseGroup:     params.Group,
+			TokenID:      params.TokenId,
+			ChannelID:    params.ChannelId,
+			NodeName:     nodeName,
+			Project:      params.Project,
+			UpstreamCost: params.UpstreamCost,
+			CostTracked:  params.CostTracked,
 		})
 	}
 }
@@ -609,13 +663,88 @@ func GetUserLogs(userId int, logType int, startTimestamp int64, endTimestamp int
 	return logs, total, err
 }
 
+// GetOrganizationLogs returns consume logs charged to an organization wallet.
+// userId > 0 narrows the result to a single member.
+func GetOrganizationLogs(orgId int, userId int, startTimestamp int64, endTimestamp int64, modelName string, tokenName string, startIdx int, num int) (logs []*Log, total int64, err error) {
+	tx := LOG_DB.Where("logs.org_id = ? and logs.type = ?", orgId, LogTypeConsume)
+	if userId > 0 {
+		tx = tx.Where("logs.user_id = ?", userId)
+	}
+	if tx, err = applyExplicitLogTextFilter(tx, "logs.model_name", modelName); err != nil {
+		return nil, 0, err
+	}
+	if tokenName != "" {
+		tx = tx.Where("logs.token_name = ?", tokenName)
+	}
+	if startTimestamp != 0 {
+		tx = tx.Where("logs.created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("logs.created_at <= ?", endTimestamp)
+	}
+	err = tx.Model(&Log{}).Limit(logSearchCountLimit).Count(&total).Error
+	if err != nil {
+		common.SysError("failed to count organization logs: " + err.Error())
+		return nil, 0, errors.New("查询日志失败")
+	}
+	order := "logs.id desc"
+	if common.UsingLogDatabase(common.DatabaseTypeClickHouse) {
+		order = clickHouseLogOrder("logs.")
+	}
+	err = tx.Order(order).Limit(num).Offset(startIdx).Find(&logs).Error
+	if err != nil {
+		common.SysError("failed to search organization logs: " + err.Error())
+		return nil, 0, errors.New("查询日志失败")
+	}
+
+	formatUserLogs(logs, startIdx)
+	return logs, total, err
+}
+
+// GetResellerLogs 返回分销商下游用户的消费日志，userIds 为该分销商的下游用户
+func GetResellerLogs(userIds []int, userId int, startTimestamp int64, endTimestamp int64, modelName string, startIdx int, num int) (logs []*Log, total int64, err error) {
+	if len(userIds) == 0 {
+		return []*Log{}, 0, nil
+	}
+	tx := LOG_DB.Where("logs.user_id IN ? and logs.type = ?", userIds, LogTypeConsume)
+	if userId > 0 {
+		tx = tx.Where("logs.user_id = ?", userId)
+	}
+	if tx, err = applyExplicitLogTextFilter(tx, "logs.model_name", modelName); err != nil {
+		return nil, 0, err
+	}
+	if startTimestamp != 0 {
+		tx = tx.Where("logs.created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("logs.created_at <= ?", endTimestamp)
+	}
+	err = tx.Model(&Log{}).Limit(logSearchCountLimit).Count(&total).Error
+	if err != nil {
+		common.SysError("failed to count reseller logs: " + err.Error())
+		return nil, 0, errors.New("查询日志失败")
+	}
+	order := "logs.id desc"
+	if common.UsingLogDatabase(common.DatabaseTypeClickHouse) {
+		order = clickHouseLogOrder("logs.")
+	}
+	err = tx.Order(order).Limit(num).Offset(startIdx).Find(&logs).Error
+	if err != nil {
+		common.SysError("failed to search reseller logs: " + err.Error())
+		return nil, 0, errors.New("查询日志失败")
+	}
+
+	formatUserLogs(logs, startIdx)
+	return logs, total, err
+}
+
 type Stat struct {
 	Quota int `json:"quota"`
 	Rpm   int `json:"rpm"`
 	Tpm   int `json:"tpm"`
 }
 
-func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, channel int, group string) (stat Stat, err error) {
+func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, channel int, group string, project string) (stat Stat, err error) {
 	tx := LOG_DB.Table("logs").Select("COALESCE(sum(quota), 0) quota")
 
 	// 为rpm和tpm创建单独的查询
@@ -651,6 +780,10 @@ func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelNa
 		tx = tx.Where(logGroupCol+" = ?", group)
 		rpmTpmQuery = rpmTpmQuery.Where(logGroupCol+" = ?", group)
 	}
+	if project != "" {
+		tx = tx.Where("project = ?", project)
+		rpmTpmQuery = rpmTpmQuery.Where("project = ?", project)
+	}
 
 	tx = tx.Where("type = ?", LogTypeConsume)
 	rpmTpmQuery = rpmTpmQuery.Where("type = ?", LogTypeConsume)
@@ -735,3 +868,62 @@ func DeleteOldLogBatch(ctx context.Context, targetTimestamp int64, limit int) (i
 	}
 	return result.RowsAffected, nil
 }
+
+// ConsumeLogFilter 离线重放消费日志（如计费模拟）时的筛选条件，时间窗口为 [Start, End)
+type ConsumeLogFilter struct {
+	StartTimestamp int64
+	EndTimestamp   int64
+	ModelName      string
+	UserId         int
+	Group          string
+}
+
+func (f ConsumeLogFilter) query(ctx context.Context) *gorm.DB {
+	tx := LOG_DB.WithContext(ctx).Model(&Log{}).
+		Where("logs.type = ? AND logs.created_at >= ? AND logs.created_at < ?", LogTypeConsume, f.StartTimestamp, f.EndTimestamp)
+	if f.ModelName != "" {
+		tx = tx.Where("logs.model_name = ?", f.ModelName)
+	}
+	if f.UserId != 0 {
+		tx = tx.Where("logs.user_id = ?", f.UserId)
+	}
+	if f.Group != "" {
+		tx = tx.Where("logs."+logGroupCol+" = ?", f.Group)
+	}
+	return tx
+}
+
+func CountConsumeLogs(ctx context.Context, filter ConsumeLogFilter) (int64, error) {
+	var total int64
+	err := filter.query(ctx).Count(&total).Error
+	return total, err
+}
+
+// ScanConsumeLogs 按时间顺序分批读取消费日志并交给 fn 处理，fn 返回错误时终止
+func ScanConsumeLogs(ctx context.Context, filter ConsumeLogFilter, batchSize int, fn func(logs []*Log) error) error {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	order := "logs.created_at asc, logs.id asc"
+	if common.UsingLogDatabase(common.DatabaseTypeClickHouse) {
+		order = "logs.created_at asc, logs.request_id asc"
+	}
+	for offset := 0; ; offset += batchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var logs []*Log
+		if err := filter.query(ctx).Order(order).Limit(batchSize).Offset(offset).Find(&logs).Error; err != nil {
+			return err
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+		if err := fn(logs); err != nil {
+			return err
+		}
+		if len(logs) < batchSize {
+			return nil
+		}
+	}
+}
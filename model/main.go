@@ -293,6 +293,21 @@ func migrateDB() error {
 		&ChannelHealthProbeState{},
 		&CasbinRule{},
 		&AuthzRole{},
+		&Organization{},
+		&OrganizationMember{},
+		&OrganizationInvite{},
+		&ScimIdentity{},
+		&AuditLog{},
+		&CreditGrant{},
+		&CreditLedgerEntry{},
+		&CreditSettlement{},
+		&Statement{},
+		&PostpaidAccount{},
+		&PriceBook{},
+		&Reseller{},
+		&ResellerUsage{},
+		&TaskWebhookDelivery{},
+		&MediaAsset{},
 	)
 	if err != nil {
 		return err
@@ -354,6 +369,21 @@ func migrateDBFast() error {
 		{&SystemInstance{}, "SystemInstance"},
 		{&SystemTask{}, "SystemTask"},
 		{&SystemTaskLock{}, "SystemTaskLock"},
+		{&Organization{}, "Organization"},
+		{&OrganizationMember{}, "OrganizationMember"},
+		{&OrganizationInvite{}, "OrganizationInvite"},
+		{&ScimIdentity{}, "ScimIdentity"},
+		{&AuditLog{}, "AuditLog"},
+		{&CreditGrant{}, "CreditGrant"},
+		{&CreditLedgerEntry{}, "CreditLedgerEntry"},
+		{&CreditSettlement{}, "CreditSettlement"},
+		{&Statement{}, "Statement"},
+		{&PostpaidAccount{}, "PostpaidAccount"},
+		{&PriceBook{}, "PriceBook"},
+		{&Reseller{}, "Reseller"},
+		{&ResellerUsage{}, "ResellerUsage"},
+		{&TaskWebhookDelivery{}, "TaskWebhookDelivery"},
+		{&MediaAsset{}, "MediaAsset"},
 	}
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
@@ -404,11 +434,26 @@ func migrateLOGDB() error {
 	return LOG_DB.AutoMigrate(&Log{})
 }
 
+// clickHouseLogAddedColumns lists log columns introduced after the initial
+// ClickHouse schema; CREATE TABLE IF NOT EXISTS does not add them to
+// existing tables.
+var clickHouseLogAddedColumns = []string{
+	"org_id Int32 DEFAULT 0",
+	"project String DEFAULT ''",
+	"upstream_cost Int32 DEFAULT 0",
+	"price_book_version Int32 DEFAULT 0",
+}
+
 func migrateClickHouseLogDB() error {
 	ttlDays := clickHouseLogTTLDays()
 	if err := LOG_DB.Exec(clickHouseLogCreateTableSQL(ttlDays)).Error; err != nil {
 		return err
 	}
+	for _, column := range clickHouseLogAddedColumns {
+		if err := LOG_DB.Exec("ALTER TABLE logs ADD COLUMN IF NOT EXISTS " + column).Error; err != nil {
+			return err
+		}
+	}
 	return syncClickHouseLogTTL(ttlDays)
 }
 
@@ -457,6 +502,10 @@ CREATE TABLE IF NOT EXISTS logs (
 	ip String DEFAULT '',
 	request_id String DEFAULT '',
 	upstream_request_id String DEFAULT '',
+	org_id Int32 DEFAULT 0,
+	project String DEFAULT '',
+	upstream_cost Int32 DEFAULT 0,
+	price_book_version Int32 DEFAULT 0,
 	other String DEFAULT ''
 )
 ENGINE = MergeTree()
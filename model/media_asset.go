@@ -0,0 +1,208 @@
+package model
+
+import (
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+// 转存文件类型
+const (
+	MediaKindVideo = "video"
+	MediaKindImage = "image"
+	MediaKindAudio = "audio"
+)
+
+// 转存文件来源：异步任务结果或同步图片接口响应
+const (
+	MediaSourceTask  = "task"
+	MediaSourceImage = "image"
+)
+
+// 转存状态：pending 后台转存中，ready 已写入存储，failed 转存失败。
+// 未就绪的文件不占用存储空间，签名地址跳转回上游地址
+const (
+	MediaAssetStatusPending = "pending"
+	MediaAssetStatusReady   = "ready"
+	MediaAssetStatusFailed  = "failed"
+)
+
+var ErrMediaAssetNotFound = errors.New("media asset not found")
+
+// mediaURLPattern 匹配网关文件签名地址；JSON 中的 & 可能被转义为 \u0026。
+// 旧版地址没有 retain 参数，其 expires 即文件保留期
+var mediaURLPattern = regexp.MustCompile(`(?:https?://[^\s"'?]*?)?/api/media/file/(\d+)\?expires=(\d+)(?:&|\\u0026)(?:retain=(\d+)(?:&|\\u0026))?signature=([0-9a-f]{64})`)
+
+// MediaSignature 计算文件地址签名，retain 为文件保留截止时间
+func MediaSignature(id int, expires int64, retain int64) string {
+	return common.GenerateHMAC(fmt.Sprintf("media:%d:%d:%d", id, expires, retain))
+}
+
+// SignMediaURL 签发文件的网关地址，retain 为文件保留截止时间（0 表示永久保留）。
+// 地址在签名有效期或保留期结束时失效，以先到者为准
+func SignMediaURL(id int, retain int64) string {
+	expires := common.GetTimestamp() + operation_setting.GetMediaArchiveSetting().SignedURLSeconds()
+	if retain > 0 {
+		expires = min(expires, retain)
+	}
+	return fmt.Sprintf("%s/api/media/file/%d?expires=%d&retain=%d&signature=%s",
+		strings.TrimRight(system_setting.ServerAddress, "/"), id, expires, retain, MediaSignature(id, expires, retain))
+}
+
+// RefreshMediaURLs 为保存在任务结果中的网关文件地址重新签发有效期，读取结果时调用；
+// 签名无效的地址原样保留
+func RefreshMediaURLs(text string) string {
+	if !strings.Contains(text, "/api/media/file/") {
+		return text
+	}
+	return mediaURLPattern.ReplaceAllStringFunc(text, func(match string) string {
+		parts := mediaURLPattern.FindStringSubmatch(match)
+		id, _ := strconv.Atoi(parts[1])
+		expires, _ := strconv.ParseInt(parts[2], 10, 64)
+		retain, expected := expires, common.GenerateHMAC(fmt.Sprintf("media:%d:%d", id, expires))
+		if parts[3] != "" {
+			retain, _ = strconv.ParseInt(parts[3], 10, 64)
+			expected = MediaSignature(id, expires, retain)
+		}
+		if !hmac.Equal([]byte(expected), []byte(parts[4])) {
+			return match
+		}
+		return SignMediaURL(id, retain)
+	})
+}
+
+// MediaAsset 网关转存的生成结果文件，文件内容保存在 Backend 对应的存储中
+type MediaAsset struct {
+	Id          int    `json:"id"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	Source      string `json:"source" gorm:"type:varchar(20)"`
+	SourceId    string `json:"source_id" gorm:"type:varchar(191);index"` // 任务 ID 或请求 ID
+	Kind        string `json:"kind" gorm:"type:varchar(16)"`
+	Backend     string `json:"backend" gorm:"type:varchar(16)"`
+	StorageKey  string `json:"-" gorm:"type:varchar(255)"`
+	ContentType string `json:"content_type" gorm:"type:varchar(128)"`
+	Size        int64  `json:"size" gorm:"bigint"`
+	OriginalUrl string `json:"original_url" gorm:"type:text"`
+	Quota       int    `json:"quota" gorm:"default:0"` // 转存时单独收取的额度
+	Status      string `json:"status" gorm:"type:varchar(16);default:'ready'"`
+	ExpiresAt   int64  `json:"expires_at" gorm:"bigint;index"` // 0 表示永久保留
+	CreatedAt   int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+// CreateMediaAsset 记录一个文件，Status 为 pending 时由 FinishMediaAsset 写入存储信息
+func CreateMediaAsset(asset *MediaAsset) error {
+	if asset.CreatedAt == 0 {
+		asset.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(asset).Error
+}
+
+// FinishMediaAsset 把转存中的文件标记为已写入存储；记录已被删除时返回 ErrMediaAssetNotFound
+func FinishMediaAsset(asset *MediaAsset) error {
+	result := DB.Model(&MediaAsset{}).Where("id = ? AND status = ?", asset.Id, MediaAssetStatusPending).
+		Updates(map[string]any{
+			"backend":      asset.Backend,
+			"storage_key":  asset.StorageKey,
+			"content_type": asset.ContentType,
+			"size":         asset.Size,
+			"quota":        asset.Quota,
+			"status":       MediaAssetStatusReady,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMediaAssetNotFound
+	}
+	asset.Status = MediaAssetStatusReady
+	return nil
+}
+
+// FailMediaAsset 标记转存失败，签名地址此后跳转回上游地址
+func FailMediaAsset(id int) error {
+	return DB.Model(&MediaAsset{}).Where("id = ? AND status = ?", id, MediaAssetStatusPending).
+		Update("status", MediaAssetStatusFailed).Error
+}
+
+// GetMediaAssetById 按 ID 查询文件，userId 为 0 时不校验归属
+func GetMediaAssetById(id int, userId int) (*MediaAsset, error) {
+	query := DB.Where("id = ?", id)
+	if userId > 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	var asset MediaAsset
+	err := query.First(&asset).Error
+	exist, err := RecordExist(err)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, ErrMediaAssetNotFound
+	}
+	return &asset, nil
+}
+
+// SumUserMediaAssetBytes 统计用户当前占用的存储空间
+func SumUserMediaAssetBytes(userId int) (int64, error) {
+	var total int64
+	err := DB.Model(&MediaAsset{}).Where("user_id = ?", userId).
+		Select("COALESCE(SUM(size), 0)").Scan(&total).Error
+	return total, err
+}
+
+// GetExpiredMediaAssets 查询保留期已结束的文件
+func GetExpiredMediaAssets(now int64, limit int) ([]*MediaAsset, error) {
+	var assets []*MediaAsset
+	err := DB.Where("expires_at > 0 AND expires_at <= ?", now).
+		Order("expires_at asc").Limit(limit).Find(&assets).Error
+	return assets, err
+}
+
+// DeleteMediaAsset 删除文件记录，存储中的内容由调用方先行删除
+func DeleteMediaAsset(id int) error {
+	return DB.Delete(&MediaAsset{}, id).Error
+}
+
+// GetMediaAssets 分页查询转存文件，userId 为 0 时查询全部用户
+func GetMediaAssets(userId int, kind string, sourceId string, startIdx int, num int) ([]*MediaAsset, int64, error) {
+	query := DB.Model(&MediaAsset{})
+	if userId > 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if sourceId != "" {
+		query = query.Where("source_id = ?", sourceId)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var assets []*MediaAsset
+	err := query.Order("id desc").Limit(num).Offset(startIdx).Find(&assets).Error
+	return assets, total, err
+}
+
+// GetMediaAssetsBySourceId 查询某个任务或请求转存的全部文件
+func GetMediaAssetsBySourceId(userId int, sourceId string) ([]*MediaAsset, error) {
+	var assets []*MediaAsset
+	err := DB.Where("user_id = ? AND source_id = ?", userId, sourceId).Find(&assets).Error
+	return assets, err
+}
+
+// UpdateTaskArchivedResult 转存完成后写回任务的结果地址与数据，仅更新已成功的任务
+func UpdateTaskArchivedResult(task *Task) error {
+	return DB.Model(&Task{}).Where("id = ? AND status = ?", task.ID, TaskStatusSuccess).
+		Updates(map[string]any{
+			"private_data": task.PrivateData,
+			"data":         task.Data,
+		}).Error
+}
@@ -1,5 +1,16 @@
 package model
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+
+	"gorm.io/gorm"
+)
+
 type Midjourney struct {
 	Id          int    `json:"id"`
 	Code        int    `json:"code"`
@@ -20,32 +31,62 @@ type Midjourney struct {
 	Progress    string `json:"progress" gorm:"type:varchar(30);index"`
 	FailReason  string `json:"fail_reason"`
 	ChannelId   int    `json:"channel_id"`
-	Quota       int    `json:"quota"`
-	Buttons     string `json:"buttons"`
-	Properties  string `json:"properties"`
+	// ChannelIdentity 标识任务所在的上游 MJ 实例，渠道删除重建或更换密钥后仍可据此找回可处理后续操作的渠道
+	ChannelIdentity string `json:"-" gorm:"type:varchar(64);index;default:''"`
+	Quota           int    `json:"quota"`
+	Buttons         string `json:"buttons"`
+	Properties      string `json:"properties"`
 
-	TokenId          int `json:"-" gorm:"default:0"`
-	BillingChannelId int `json:"-" gorm:"default:0"`
+	TokenId          int    `json:"-" gorm:"default:0"`
+	BillingChannelId int    `json:"-" gorm:"default:0"`
+	CallbackUrl      string `json:"-" gorm:"type:varchar(512);default:''"` // 任务结束后通知客户端的回调地址
 }
 
 // TaskQueryParams 用于包含所有搜索条件的结构体，可以根据需求添加更多字段
 type TaskQueryParams struct {
 	ChannelID      string
 	MjID           string
+	MjIDs          []string
+	Action         string
+	Status         string
+	Prompt         string
 	StartTimestamp string
 	EndTimestamp   string
 }
 
-func GetAllUserTask(userId int, startIdx int, num int, queryParams TaskQueryParams) []*Midjourney {
-	var tasks []*Midjourney
-	var err error
+// MidjourneyChannelIdentity 根据渠道地址生成上游 MJ 实例标识，任务 ID 只在提交它的实例上有效
+func MidjourneyChannelIdentity(baseURL string) string {
+	normalized := strings.ToLower(strings.TrimRight(strings.TrimSpace(baseURL), "/"))
+	if normalized == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:16])
+}
 
-	// 初始化查询构建器
-	query := DB.Where("user_id = ?", userId)
+func escapeMidjourneyLike(value string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(value)
+}
 
+func applyTaskQueryParams(query *gorm.DB, queryParams TaskQueryParams) *gorm.DB {
+	if queryParams.ChannelID != "" {
+		query = query.Where("channel_id = ?", queryParams.ChannelID)
+	}
 	if queryParams.MjID != "" {
 		query = query.Where("mj_id = ?", queryParams.MjID)
 	}
+	if len(queryParams.MjIDs) > 0 {
+		query = query.Where("mj_id in (?)", queryParams.MjIDs)
+	}
+	if queryParams.Action != "" {
+		query = query.Where("action = ?", queryParams.Action)
+	}
+	if queryParams.Status != "" {
+		query = query.Where("status = ?", queryParams.Status)
+	}
+	if queryParams.Prompt != "" {
+		query = query.Where("prompt LIKE ? ESCAPE '!'", "%"+escapeMidjourneyLike(queryParams.Prompt)+"%")
+	}
 	if queryParams.StartTimestamp != "" {
 		// 假设您已将前端传来的时间戳转换为数据库所需的时间格式，并处理了时间戳的验证和解析
 		query = query.Where("submit_time >= ?", queryParams.StartTimestamp)
@@ -53,6 +94,15 @@ func GetAllUserTask(userId int, startIdx int, num int, queryParams TaskQueryPara
 	if queryParams.EndTimestamp != "" {
 		query = query.Where("submit_time <= ?", queryParams.EndTimestamp)
 	}
+	return query
+}
+
+func GetAllUserTask(userId int, startIdx int, num int, queryParams TaskQueryParams) []*Midjourney {
+	var tasks []*Midjourney
+	var err error
+
+	// 初始化查询构建器
+	query := applyTaskQueryParams(DB.Where("user_id = ?", userId), queryParams)
 
 	// 获取数据
 	err = query.Order("id desc").Limit(num).Offset(startIdx).Find(&tasks).Error
@@ -67,22 +117,8 @@ func GetAllTasks(startIdx int, num int, queryParams TaskQueryParams) []*Midjourn
 	var tasks []*Midjourney
 	var err error
 
-	// 初始化查询构建器
-	query := DB
-
-	// 添加过滤条件
-	if queryParams.ChannelID != "" {
-		query = query.Where("channel_id = ?", queryParams.ChannelID)
-	}
-	if queryParams.MjID != "" {
-		query = query.Where("mj_id = ?", queryParams.MjID)
-	}
-	if queryParams.StartTimestamp != "" {
-		query = query.Where("submit_time >= ?", queryParams.StartTimestamp)
-	}
-	if queryParams.EndTimestamp != "" {
-		query = query.Where("submit_time <= ?", queryParams.EndTimestamp)
-	}
+	// 初始化查询构建器并添加过滤条件
+	query := applyTaskQueryParams(DB, queryParams)
 
 	// 获取数据
 	err = query.Order("id desc").Limit(num).Offset(startIdx).Find(&tasks).Error
@@ -214,20 +250,7 @@ func MjBulkUpdateByTaskIds(taskIDs []int, params map[string]any) error {
 // CountAllTasks returns total midjourney tasks for admin query
 func CountAllTasks(queryParams TaskQueryParams) int64 {
 	var total int64
-	query := DB.Model(&Midjourney{})
-	if queryParams.ChannelID != "" {
-		query = query.Where("channel_id = ?", queryParams.ChannelID)
-	}
-	if queryParams.MjID != "" {
-		query = query.Where("mj_id = ?", queryParams.MjID)
-	}
-	if queryParams.StartTimestamp != "" {
-		query = query.Where("submit_time >= ?", queryParams.StartTimestamp)
-	}
-	if queryParams.EndTimestamp != "" {
-		query = query.Where("submit_time <= ?", queryParams.EndTimestamp)
-	}
-	_ = query.Count(&total).Error
+	_ = applyTaskQueryParams(DB.Model(&Midjourney{}), queryParams).Count(&total).Error
 	return total
 }
 
@@ -235,15 +258,23 @@ func CountAllTasks(queryParams TaskQueryParams) int64 {
 func CountAllUserTask(userId int, queryParams TaskQueryParams) int64 {
 	var total int64
 	query := DB.Model(&Midjourney{}).Where("user_id = ?", userId)
-	if queryParams.MjID != "" {
-		query = query.Where("mj_id = ?", queryParams.MjID)
-	}
-	if queryParams.StartTimestamp != "" {
-		query = query.Where("submit_time >= ?", queryParams.StartTimestamp)
-	}
-	if queryParams.EndTimestamp != "" {
-		query = query.Where("submit_time <= ?", queryParams.EndTimestamp)
-	}
-	_ = query.Count(&total).Error
+	_ = applyTaskQueryParams(query, queryParams).Count(&total).Error
 	return total
 }
+
+// RebindMidjourneyTasks 将任务迁移到接管原上游实例的渠道，后续轮询与操作均使用新渠道
+func RebindMidjourneyTasks(taskIDs []int, channelId int, channelIdentity string) error {
+	return MjBulkUpdateByTaskIds(taskIDs, map[string]any{
+		"channel_id":       channelId,
+		"channel_identity": channelIdentity,
+	})
+}
+
+// GetEnabledMidjourneyChannels 返回所有启用的 Midjourney 与 Midjourney Plus 渠道
+func GetEnabledMidjourneyChannels() ([]*Channel, error) {
+	var channels []*Channel
+	err := DB.Where("type in (?) AND status = ?",
+		[]int{constant.ChannelTypeMidjourney, constant.ChannelTypeMidjourneyPlus}, common.ChannelStatusEnabled).
+		Order("priority desc, id").Find(&channels).Error
+	return channels, err
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMidjourneyChannelIdentityNormalizesBaseURL(t *testing.T) {
+	identity := MidjourneyChannelIdentity("https://mj.example.com")
+	assert.Len(t, identity, 32)
+	assert.Equal(t, identity, MidjourneyChannelIdentity(" HTTPS://MJ.example.com/ "))
+	assert.NotEqual(t, identity, MidjourneyChannelIdentity("https://mj2.example.com"))
+	assert.Empty(t, MidjourneyChannelIdentity(" "))
+}
+
+func TestGetAllUserTaskFiltersByPromptStatusAndAction(t *testing.T) {
+	truncateTables(t)
+
+	tasks := []*Midjourney{
+		{UserId: 1, MjId: "1", Action: "IMAGINE", Status: "SUCCESS", Prompt: "a red fox", SubmitTime: 1000},
+		{UserId: 1, MjId: "2", Action: "IMAGINE", Status: "FAILURE", Prompt: "a red car", SubmitTime: 2000},
+		{UserId: 1, MjId: "3", Action: "UPSCALE", Status: "SUCCESS", Prompt: "100%_red", SubmitTime: 3000},
+		{UserId: 2, MjId: "4", Action: "IMAGINE", Status: "SUCCESS", Prompt: "a red fox", SubmitTime: 4000},
+	}
+	for _, task := range tasks {
+		require.NoError(t, task.Insert())
+	}
+
+	mjIds := func(items []*Midjourney) []string {
+		ids := make([]string, 0, len(items))
+		for _, item := range items {
+			ids = append(ids, item.MjId)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"3", "2", "1"}, mjIds(GetAllUserTask(1, 0, 10, TaskQueryParams{Prompt: "red"})))
+	assert.Equal(t, []string{"1"}, mjIds(GetAllUserTask(1, 0, 10, TaskQueryParams{Prompt: "red", Status: "SUCCESS", Action: "IMAGINE"})))
+	assert.Equal(t, []string{"3"}, mjIds(GetAllUserTask(1, 0, 10, TaskQueryParams{Prompt: "0%_"})))
+	assert.Equal(t, []string{"2"}, mjIds(GetAllUserTask(1, 0, 10, TaskQueryParams{StartTimestamp: "1500", EndTimestamp: "2500"})))
+	assert.Equal(t, int64(2), CountAllUserTask(1, TaskQueryParams{Prompt: "a red"}))
+}
+
+func TestRebindMidjourneyTasksAndEnabledChannels(t *testing.T) {
+	truncateTables(t)
+
+	baseURL := "https://mj.example.com"
+	channels := []*Channel{
+		{Id: 1, Type: constant.ChannelTypeMidjourney, Status: common.ChannelStatusEnabled, BaseURL: &baseURL},
+		{Id: 2, Type: constant.ChannelTypeMidjourneyPlus, Status: common.ChannelStatusManuallyDisabled, BaseURL: &baseURL},
+		{Id: 3, Type: constant.ChannelTypeOpenAI, Status: common.ChannelStatusEnabled, BaseURL: &baseURL},
+	}
+	for _, channel := range channels {
+		require.NoError(t, DB.Create(channel).Error)
+	}
+	enabled, err := GetEnabledMidjourneyChannels()
+	require.NoError(t, err)
+	require.Len(t, enabled, 1)
+	assert.Equal(t, 1, enabled[0].Id)
+
+	task := &Midjourney{UserId: 1, MjId: "1", ChannelId: 9}
+	require.NoError(t, task.Insert())
+	require.NoError(t, RebindMidjourneyTasks([]int{task.Id}, 1, MidjourneyChannelIdentity(baseURL)))
+	rebound := GetByOnlyMJId("1")
+	require.NotNil(t, rebound)
+	assert.Equal(t, 1, rebound.ChannelId)
+	assert.Equal(t, MidjourneyChannelIdentity(baseURL), rebound.ChannelIdentity)
+}
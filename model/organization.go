@@ -0,0 +1,576 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+)
+
+// Organization member roles
+const (
+	OrganizationRoleOwner  = "owner"
+	OrganizationRoleAdmin  = "admin"
+	OrganizationRoleMember = "member"
+)
+
+// Organization status
+const (
+	OrganizationStatusEnabled  = 1
+	OrganizationStatusDisabled = 2
+)
+
+var (
+	ErrOrganizationNotFound           = errors.New("organization not found")
+	ErrOrganizationMemberNotFound     = errors.New("organization member not found")
+	ErrOrganizationMemberExists       = errors.New("user is already a member of this organization")
+	ErrOrganizationQuotaInsufficient  = errors.New("organization quota insufficient")
+	ErrOrganizationMemberLimitReached = errors.New("organization member spend limit reached")
+	ErrOrganizationOwnerImmutable     = errors.New("the organization owner cannot be removed or demoted")
+	ErrOrganizationBalanceNotEmpty    = errors.New("organization balance must be zero before deletion")
+	ErrOrganizationInviteNotFound     = errors.New("organization invitation not found")
+)
+
+// Organization is a team that shares one quota wallet. Members keep their own
+// tokens; tokens issued under the organization (Token.OrgId) are charged to
+// the organization wallet instead of the member's personal quota.
+type Organization struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(128);index"`
+	OwnerId     int    `json:"owner_id" gorm:"index"`
+	Quota       int    `json:"quota" gorm:"default:0"`
+	UsedQuota   int    `json:"used_quota" gorm:"default:0"`
+	Status      int    `json:"status" gorm:"default:1"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// OrganizationMember links a user to an organization. SpendLimit caps the
+// member's lifetime spend from the shared wallet; 0 means unlimited.
+type OrganizationMember struct {
+	Id          int    `json:"id"`
+	OrgId       int    `json:"org_id" gorm:"uniqueIndex:idx_org_member,priority:1"`
+	UserId      int    `json:"user_id" gorm:"uniqueIndex:idx_org_member,priority:2;index"`
+	Role        string `json:"role" gorm:"type:varchar(16);default:'member'"`
+	SpendLimit  int    `json:"spend_limit" gorm:"default:0"`
+	UsedQuota   int    `json:"used_quota" gorm:"default:0"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	Username    string `json:"username" gorm:"-:all"`
+}
+
+// OrganizationInvite is a pending invitation. A user only becomes a member
+// after accepting it, so organization admins cannot enrol anyone unilaterally.
+type OrganizationInvite struct {
+	Id          int    `json:"id"`
+	OrgId       int    `json:"org_id" gorm:"uniqueIndex:idx_org_invite,priority:1"`
+	UserId      int    `json:"user_id" gorm:"uniqueIndex:idx_org_invite,priority:2;index"`
+	InviterId   int    `json:"inviter_id"`
+	Role        string `json:"role" gorm:"type:varchar(16);default:'member'"`
+	SpendLimit  int    `json:"spend_limit" gorm:"default:0"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	OrgName     string `json:"org_name" gorm:"-:all"`
+}
+
+// OrganizationWithRole is an organization as seen by one of its members.
+type OrganizationWithRole struct {
+	Organization
+	Role            string `json:"role"`
+	SpendLimit      int    `json:"spend_limit"`
+	MemberUsedQuota int    `json:"member_used_quota"`
+}
+
+func IsValidOrganizationRole(role string) bool {
+	switch role {
+	case OrganizationRoleOwner, OrganizationRoleAdmin, OrganizationRoleMember:
+		return true
+	default:
+		return false
+	}
+}
+
+// CreateOrganization creates an organization with the given user as its owner.
+func CreateOrganization(name string, ownerId int) (*Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("organization name is required")
+	}
+	now := common.GetTimestamp()
+	org := &Organization{
+		Name:        name,
+		OwnerId:     ownerId,
+		Status:      OrganizationStatusEnabled,
+		CreatedTime: now,
+		UpdatedTime: now,
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrgId:       org.Id,
+			UserId:      ownerId,
+			Role:        OrganizationRoleOwner,
+			CreatedTime: now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+func GetOrganizationById(id int) (*Organization, error) {
+	var org Organization
+	if err := DB.First(&org, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, err
+	}
+	return &org, nil
+}
+
+func GetAllOrganizations(keyword string, startIdx int, num int) (orgs []*Organization, total int64, err error) {
+	tx := DB.Model(&Organization{})
+	if keyword != "" {
+		tx = tx.Where("name LIKE ?", "%"+keyword+"%")
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&orgs).Error
+	return orgs, total, err
+}
+
+// GetUserOrganizations returns the organizations a user belongs to together
+// with the user's role and spend in each.
+func GetUserOrganizations(userId int) ([]OrganizationWithRole, error) {
+	var members []OrganizationMember
+	if err := DB.Where("user_id = ?", userId).Order("id asc").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return []OrganizationWithRole{}, nil
+	}
+	orgIds := make([]int, 0, len(members))
+	for _, member := range members {
+		orgIds = append(orgIds, member.OrgId)
+	}
+	var orgs []Organization
+	if err := DB.Where("id IN ?", orgIds).Find(&orgs).Error; err != nil {
+		return nil, err
+	}
+	orgById := make(map[int]Organization, len(orgs))
+	for _, org := range orgs {
+		orgById[org.Id] = org
+	}
+	result := make([]OrganizationWithRole, 0, len(members))
+	for _, member := range members {
+		org, ok := orgById[member.OrgId]
+		if !ok {
+			continue
+		}
+		result = append(result, OrganizationWithRole{
+			Organization:    org,
+			Role:            member.Role,
+			SpendLimit:      member.SpendLimit,
+			MemberUsedQuota: member.UsedQuota,
+		})
+	}
+	return result, nil
+}
+
+func GetOrganizationMember(orgId int, userId int) (*OrganizationMember, error) {
+	var member OrganizationMember
+	if err := DB.Where("org_id = ? AND user_id = ?", orgId, userId).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrganizationMemberNotFound
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+func GetOrganizationMembers(orgId int) ([]*OrganizationMember, error) {
+	var members []*OrganizationMember
+	if err := DB.Where("org_id = ?", orgId).Order("id asc").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return members, nil
+	}
+	userIds := make([]int, 0, len(members))
+	for _, member := range members {
+		userIds = append(userIds, member.UserId)
+	}
+	var users []User
+	if err := DB.Select("id", "username").Where("id IN ?", userIds).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	usernames := make(map[int]string, len(users))
+	for _, user := range users {
+		usernames[user.Id] = user.Username
+	}
+	for _, member := range members {
+		member.Username = usernames[member.UserId]
+	}
+	return members, nil
+}
+
+func AddOrganizationMember(orgId int, userId int, role string, spendLimit int) (*OrganizationMember, error) {
+	member := &OrganizationMember{
+		OrgId:       orgId,
+		UserId:      userId,
+		Role:        role,
+		SpendLimit:  spendLimit,
+		CreatedTime: common.GetTimestamp(),
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		return addOrganizationMemberTx(tx, member)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+func addOrganizationMemberTx(tx *gorm.DB, member *OrganizationMember) error {
+	var count int64
+	if err := tx.Model(&OrganizationMember{}).Where("org_id = ? AND user_id = ?", member.OrgId, member.UserId).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrOrganizationMemberExists
+	}
+	return tx.Create(member).Error
+}
+
+// InviteOrganizationMember creates or refreshes a pending invitation for a
+// user. Inviting an existing member fails; everything else succeeds silently
+// so the caller learns nothing about which accounts exist.
+func InviteOrganizationMember(orgId int, inviterId int, userId int, role string, spendLimit int) error {
+	if _, err := GetOrganizationMember(orgId, userId); err == nil {
+		return ErrOrganizationMemberExists
+	} else if !errors.Is(err, ErrOrganizationMemberNotFound) {
+		return err
+	}
+	var invite OrganizationInvite
+	err := DB.Where("org_id = ? AND user_id = ?", orgId, userId).First(&invite).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	invite.OrgId = orgId
+	invite.UserId = userId
+	invite.InviterId = inviterId
+	invite.Role = role
+	invite.SpendLimit = spendLimit
+	invite.CreatedTime = common.GetTimestamp()
+	return DB.Save(&invite).Error
+}
+
+// GetUserOrganizationInvites returns the pending invitations of a user.
+func GetUserOrganizationInvites(userId int) ([]*OrganizationInvite, error) {
+	var invites []*OrganizationInvite
+	if err := DB.Where("user_id = ?", userId).Order("id desc").Find(&invites).Error; err != nil {
+		return nil, err
+	}
+	for _, invite := range invites {
+		if org, err := GetOrganizationById(invite.OrgId); err == nil {
+			invite.OrgName = org.Name
+		}
+	}
+	return invites, nil
+}
+
+// AcceptOrganizationInvite turns a pending invitation into a membership.
+func AcceptOrganizationInvite(inviteId int, userId int) (*OrganizationMember, error) {
+	var member *OrganizationMember
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var invite OrganizationInvite
+		if err := lockForUpdate(tx).Where("id = ? AND user_id = ?", inviteId, userId).First(&invite).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrganizationInviteNotFound
+			}
+			return err
+		}
+		if err := tx.Delete(&OrganizationInvite{}, "id = ?", invite.Id).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&Organization{}).Where("id = ?", invite.OrgId).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrOrganizationNotFound
+		}
+		member = &OrganizationMember{
+			OrgId:       invite.OrgId,
+			UserId:      invite.UserId,
+			Role:        invite.Role,
+			SpendLimit:  invite.SpendLimit,
+			CreatedTime: common.GetTimestamp(),
+		}
+		return addOrganizationMemberTx(tx, member)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// DeclineOrganizationInvite discards a pending invitation.
+func DeclineOrganizationInvite(inviteId int, userId int) error {
+	result := DB.Delete(&OrganizationInvite{}, "id = ? AND user_id = ?", inviteId, userId)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOrganizationInviteNotFound
+	}
+	return nil
+}
+
+// UpdateOrganizationMember changes a member's role and spend limit and can
+// reset the spend counted against that limit.
+func UpdateOrganizationMember(orgId int, userId int, role string, spendLimit int, resetUsed bool) error {
+	member, err := GetOrganizationMember(orgId, userId)
+	if err != nil {
+		return err
+	}
+	if member.Role == OrganizationRoleOwner && role != OrganizationRoleOwner {
+		return ErrOrganizationOwnerImmutable
+	}
+	updates := map[string]interface{}{
+		"role":        role,
+		"spend_limit": spendLimit,
+	}
+	if resetUsed {
+		updates["used_quota"] = 0
+	}
+	return DB.Model(&OrganizationMember{}).Where("id = ?", member.Id).Updates(updates).Error
+}
+
+// RemoveOrganizationMember removes a member and disables the tokens they
+// issued under the organization, so a leaver can no longer spend the shared
+// wallet.
+func RemoveOrganizationMember(orgId int, userId int) error {
+	var disabled []Token
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var member OrganizationMember
+		if err := lockForUpdate(tx).Where("org_id = ? AND user_id = ?", orgId, userId).First(&member).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrganizationMemberNotFound
+			}
+			return err
+		}
+		if member.Role == OrganizationRoleOwner {
+			return ErrOrganizationOwnerImmutable
+		}
+		if err := tx.Delete(&OrganizationMember{}, "id = ?", member.Id).Error; err != nil {
+			return err
+		}
+		var err error
+		disabled, err = disableOrganizationTokensTx(tx, orgId, userId)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return invalidateTokensCache(disabled)
+}
+
+// DeleteOrganization removes an organization, its memberships and pending
+// invitations, and disables every token issued under it. The shared wallet
+// holds quota funded by several members, so deletion is refused until the
+// balance has been spent or cleared by an administrator rather than handing
+// it all to the owner.
+func DeleteOrganization(orgId int) error {
+	var disabled []Token
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var org Organization
+		if err := lockForUpdate(tx).First(&org, "id = ?", orgId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrganizationNotFound
+			}
+			return err
+		}
+		if org.Quota != 0 {
+			return ErrOrganizationBalanceNotEmpty
+		}
+		if err := tx.Delete(&OrganizationMember{}, "org_id = ?", orgId).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&OrganizationInvite{}, "org_id = ?", orgId).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&Organization{}, "id = ?", orgId).Error; err != nil {
+			return err
+		}
+		var err error
+		disabled, err = disableOrganizationTokensTx(tx, orgId, 0)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return invalidateTokensCache(disabled)
+}
+
+func UpdateOrganization(orgId int, name string, status int) error {
+	updates := map[string]interface{}{
+		"updated_time": common.GetTimestamp(),
+	}
+	if name = strings.TrimSpace(name); name != "" {
+		updates["name"] = name
+	}
+	if status == OrganizationStatusEnabled || status == OrganizationStatusDisabled {
+		updates["status"] = status
+	}
+	result := DB.Model(&Organization{}).Where("id = ?", orgId).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOrganizationNotFound
+	}
+	return nil
+}
+
+// disableOrganizationTokensTx disables the enabled tokens issued under an
+// organization, optionally only those of one member, and returns them so the
+// caller can drop their cache entries after commit.
+func disableOrganizationTokensTx(tx *gorm.DB, orgId int, userId int) ([]Token, error) {
+	var tokens []Token
+	query := tx.Select("id", commonKeyCol).Where("org_id = ? AND status = ?", orgId, common.TokenStatusEnabled)
+	if userId > 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if err := query.Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	ids := make([]int, 0, len(tokens))
+	for _, token := range tokens {
+		ids = append(ids, token.Id)
+	}
+	if err := tx.Model(&Token{}).Where("id IN ?", ids).Update("status", common.TokenStatusDisabled).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// TryReserveOrganizationQuota atomically deducts amount from the shared wallet
+// and charges it to the member's spend, failing without side effects when the
+// wallet is short or the member would exceed their spend limit. Organization
+// balances are not cached in Redis, so the database row is authoritative.
+func TryReserveOrganizationQuota(orgId int, userId int, amount int) error {
+	if amount < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	if amount == 0 {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Organization{}).
+			Where("id = ? AND status = ? AND quota >= ?", orgId, OrganizationStatusEnabled, amount).
+			Updates(map[string]interface{}{
+				"quota":      gorm.Expr("quota - ?", amount),
+				"used_quota": gorm.Expr("used_quota + ?", amount),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return ErrOrganizationQuotaInsufficient
+		}
+		result = tx.Model(&OrganizationMember{}).
+			Where("org_id = ? AND user_id = ? AND (spend_limit = 0 OR used_quota + ? <= spend_limit)", orgId, userId, amount).
+			Update("used_quota", gorm.Expr("used_quota + ?", amount))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return ErrOrganizationMemberLimitReached
+		}
+		return nil
+	})
+}
+
+// AdjustOrganizationQuota applies a settlement delta to the shared wallet and
+// the member's spend without limit checks: delta > 0 charges, delta < 0
+// refunds. Like the wallet settlement path, a positive delta may drive the
+// balance negative rather than interrupt an already-served request.
+func AdjustOrganizationQuota(orgId int, userId int, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Organization{}).Where("id = ?", orgId).Updates(map[string]interface{}{
+			"quota":      gorm.Expr("quota - ?", delta),
+			"used_quota": gorm.Expr("used_quota + ?", delta),
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&OrganizationMember{}).
+			Where("org_id = ? AND user_id = ?", orgId, userId).
+			Update("used_quota", gorm.Expr("used_quota + ?", delta)).Error
+	})
+}
+
+// IncreaseOrganizationQuota tops up the shared wallet.
+func IncreaseOrganizationQuota(orgId int, quota int) error {
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	result := DB.Model(&Organization{}).Where("id = ?", orgId).Update("quota", gorm.Expr("quota + ?", quota))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOrganizationNotFound
+	}
+	return nil
+}
+
+// DecreaseOrganizationQuota removes quota from the shared wallet, e.g. when
+// an administrator corrects a balance.
+func DecreaseOrganizationQuota(orgId int, quota int) error {
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	result := DB.Model(&Organization{}).Where("id = ?", orgId).Update("quota", gorm.Expr("quota - ?", quota))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOrganizationNotFound
+	}
+	return nil
+}
+
+// TransferUserQuotaToOrganization moves quota from a member's personal wallet
+// into the shared organization wallet.
+func TransferUserQuotaToOrganization(userId int, orgId int, quota int) error {
+	if quota <= 0 {
+		return errors.New("quota must be positive")
+	}
+	ref := CreditRef{Source: CreditSourceOrganization, Reference: fmt.Sprintf("organization:%d", orgId)}
+	reserved, err := TryReserveUserQuota(userId, quota, ref)
+	if err != nil {
+		return err
+	}
+	if !reserved {
+		return errors.New("user quota insufficient")
+	}
+	if err := IncreaseOrganizationQuota(orgId, quota); err != nil {
+		if refundErr := IncreaseUserQuota(userId, quota, true, ref); refundErr != nil {
+			common.SysError("failed to refund user quota after organization transfer failure: " + refundErr.Error())
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestOrganization(t *testing.T, quota int) (*Organization, User) {
+	t.Helper()
+	owner := createReserveTestUser(t, 0)
+	org, err := CreateOrganization("org-"+common.GetRandomString(6), owner.Id)
+	require.NoError(t, err)
+	require.NoError(t, IncreaseOrganizationQuota(org.Id, quota))
+	return org, owner
+}
+
+func getOrganizationFromDB(t *testing.T, id int) Organization {
+	t.Helper()
+	var org Organization
+	require.NoError(t, DB.First(&org, id).Error)
+	return org
+}
+
+func TestTryReserveOrganizationQuota_ChargesWalletAndMember(t *testing.T) {
+	truncateTables(t)
+	org, owner := createTestOrganization(t, 1000)
+
+	require.NoError(t, TryReserveOrganizationQuota(org.Id, owner.Id, 300))
+
+	stored := getOrganizationFromDB(t, org.Id)
+	assert.Equal(t, 700, stored.Quota)
+	assert.Equal(t, 300, stored.UsedQuota)
+	member, err := GetOrganizationMember(org.Id, owner.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 300, member.UsedQuota)
+}
+
+func TestTryReserveOrganizationQuota_InsufficientWalletHasNoSideEffects(t *testing.T) {
+	truncateTables(t)
+	org, owner := createTestOrganization(t, 100)
+
+	err := TryReserveOrganizationQuota(org.Id, owner.Id, 101)
+	assert.ErrorIs(t, err, ErrOrganizationQuotaInsufficient)
+
+	stored := getOrganizationFromDB(t, org.Id)
+	assert.Equal(t, 100, stored.Quota)
+	member, err := GetOrganizationMember(org.Id, owner.Id)
+	require.NoError(t, err)
+	assert.Zero(t, member.UsedQuota)
+}
+
+func TestTryReserveOrganizationQuota_MemberSpendLimit(t *testing.T) {
+	truncateTables(t)
+	org, _ := createTestOrganization(t, 1000)
+	user := createReserveTestUser(t, 0)
+	_, err := AddOrganizationMember(org.Id, user.Id, OrganizationRoleMember, 150)
+	require.NoError(t, err)
+
+	require.NoError(t, TryReserveOrganizationQuota(org.Id, user.Id, 100))
+	err = TryReserveOrganizationQuota(org.Id, user.Id, 60)
+	assert.ErrorIs(t, err, ErrOrganizationMemberLimitReached)
+
+	// The failed reservation must roll back the wallet deduction too.
+	stored := getOrganizationFromDB(t, org.Id)
+	assert.Equal(t, 900, stored.Quota)
+	member, err := GetOrganizationMember(org.Id, user.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 100, member.UsedQuota)
+}
+
+func TestTryReserveOrganizationQuota_DisabledOrganization(t *testing.T) {
+	truncateTables(t)
+	org, owner := createTestOrganization(t, 1000)
+	require.NoError(t, UpdateOrganization(org.Id, "", OrganizationStatusDisabled))
+
+	err := TryReserveOrganizationQuota(org.Id, owner.Id, 10)
+	assert.ErrorIs(t, err, ErrOrganizationQuotaInsufficient)
+}
+
+func TestAdjustOrganizationQuota_RefundsWalletAndMember(t *testing.T) {
+	truncateTables(t)
+	org, owner := createTestOrganization(t, 1000)
+	require.NoError(t, TryReserveOrganizationQuota(org.Id, owner.Id, 400))
+
+	require.NoError(t, AdjustOrganizationQuota(org.Id, owner.Id, -150))
+
+	stored := getOrganizationFromDB(t, org.Id)
+	assert.Equal(t, 750, stored.Quota)
+	assert.Equal(t, 250, stored.UsedQuota)
+	member, err := GetOrganizationMember(org.Id, owner.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 250, member.UsedQuota)
+}
+
+func TestRemoveOrganizationMember_DisablesOrganizationTokens(t *testing.T) {
+	truncateTables(t)
+	org, owner := createTestOrganization(t, 1000)
+	user := createReserveTestUser(t, 0)
+	_, err := AddOrganizationMember(org.Id, user.Id, OrganizationRoleMember, 0)
+	require.NoError(t, err)
+	orgToken := Token{
+		UserId:      user.Id,
+		Key:         "org-token-" + common.GetRandomString(8),
+		Name:        "org",
+		Status:      common.TokenStatusEnabled,
+		ExpiredTime: -1,
+		OrgId:       org.Id,
+	}
+	require.NoError(t, orgToken.Insert())
+	personalToken := Token{
+		UserId:      user.Id,
+		Key:         "personal-token-" + common.GetRandomString(8),
+		Name:        "personal",
+		Status:      common.TokenStatusEnabled,
+		ExpiredTime: -1,
+	}
+	require.NoError(t, personalToken.Insert())
+
+	assert.ErrorIs(t, RemoveOrganizationMember(org.Id, owner.Id), ErrOrganizationOwnerImmutable)
+	require.NoError(t, RemoveOrganizationMember(org.Id, user.Id))
+
+	assert.Equal(t, common.TokenStatusDisabled, getTokenFromDB(t, orgToken.Id).Status)
+	assert.Equal(t, common.TokenStatusEnabled, getTokenFromDB(t, personalToken.Id).Status)
+	_, err = GetOrganizationMember(org.Id, user.Id)
+	assert.ErrorIs(t, err, ErrOrganizationMemberNotFound)
+}
+
+func TestDeleteOrganization_RequiresEmptyBalance(t *testing.T) {
+	truncateTables(t)
+	org, owner := createTestOrganization(t, 500)
+	orgToken := Token{
+		UserId:      owner.Id,
+		Key:         "org-token-" + common.GetRandomString(8),
+		Name:        "org",
+		Status:      common.TokenStatusEnabled,
+		ExpiredTime: -1,
+		OrgId:       org.Id,
+	}
+	require.NoError(t, orgToken.Insert())
+
+	assert.ErrorIs(t, DeleteOrganization(org.Id), ErrOrganizationBalanceNotEmpty)
+	assert.Equal(t, 500, getOrganizationFromDB(t, org.Id).Quota)
+	assert.Equal(t, common.TokenStatusEnabled, getTokenFromDB(t, orgToken.Id).Status)
+
+	require.NoError(t, DecreaseOrganizationQuota(org.Id, 500))
+	require.NoError(t, DeleteOrganization(org.Id))
+
+	assert.Zero(t, getUserQuotaFromDB(t, owner.Id))
+	assert.Equal(t, common.TokenStatusDisabled, getTokenFromDB(t, orgToken.Id).Status)
+	_, err := GetOrganizationById(org.Id)
+	assert.ErrorIs(t, err, ErrOrganizationNotFound)
+}
+
+func TestOrganizationInvite_RequiresAcceptance(t *testing.T) {
+	truncateTables(t)
+	org, owner := createTestOrganization(t, 0)
+	user := createReserveTestUser(t, 0)
+
+	require.NoError(t, InviteOrganizationMember(org.Id, owner.Id, user.Id, OrganizationRoleMember, 100))
+	_, err := GetOrganizationMember(org.Id, user.Id)
+	assert.ErrorIs(t, err, ErrOrganizationMemberNotFound)
+	assert.ErrorIs(t, InviteOrganizationMember(org.Id, owner.Id, owner.Id, OrganizationRoleMember, 0), ErrOrganizationMemberExists)
+
+	invites, err := GetUserOrganizationInvites(user.Id)
+	require.NoError(t, err)
+	require.Len(t, invites, 1)
+	assert.Equal(t, org.Name, invites[0].OrgName)
+
+	_, err = AcceptOrganizationInvite(invites[0].Id, owner.Id)
+	assert.ErrorIs(t, err, ErrOrganizationInviteNotFound)
+	member, err := AcceptOrganizationInvite(invites[0].Id, user.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 100, member.SpendLimit)
+
+	invites, err = GetUserOrganizationInvites(user.Id)
+	require.NoError(t, err)
+	assert.Empty(t, invites)
+}
@@ -0,0 +1,334 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"gorm.io/gorm"
+)
+
+// 后付费账户：用户余额可以透支到 -CreditLimit，按账期（CycleDays）结算。账期结束时
+// 的欠款（负余额）记为应付金额，到期日为结算日后 PaymentTermDays 天；逾期后按催缴
+// 策略逐级进入提醒、限速、停用阶段。用户通过任意支付渠道充值或管理员手动登记收款
+// 使余额回到非负后即视为结清，催缴阶段随之复位。
+//
+// User.CreditLimit / User.DunningStage 是本表的镜像，供预扣费热路径（含 Redis
+// 缓存中的 Lua 预扣脚本）直接读取，由本文件中的函数统一维护。
+const (
+	PostpaidStageCurrent   = 0
+	PostpaidStageWarned    = 1
+	PostpaidStageThrottled = 2
+	PostpaidStageSuspended = 3
+)
+
+var (
+	ErrPostpaidAccountNotFound = errors.New("postpaid account not found")
+	ErrPostpaidAccountHasDebt  = errors.New("postpaid account has outstanding balance")
+	ErrPostpaidInvalidSettings = errors.New("invalid postpaid account settings")
+)
+
+type PostpaidAccount struct {
+	UserId           int    `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	CreditLimit      int    `json:"credit_limit"`
+	CycleDays        int    `json:"cycle_days"`
+	PaymentTermDays  int    `json:"payment_term_days"`
+	CycleStart       int64  `json:"cycle_start" gorm:"bigint"`
+	CycleEnd         int64  `json:"cycle_end" gorm:"bigint;index"`
+	OutstandingQuota int64  `json:"outstanding_quota"`
+	DueAt            int64  `json:"due_at" gorm:"bigint"`
+	DunningStage     int    `json:"dunning_stage"`
+	StageChangedAt   int64  `json:"stage_changed_at" gorm:"bigint"`
+	LastSettledAt    int64  `json:"last_settled_at" gorm:"bigint"`
+	CreatedAt        int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt        int64  `json:"updated_at" gorm:"bigint"`
+	Username         string `json:"username,omitempty" gorm:"-"`
+	Balance          int    `json:"balance" gorm:"-"`
+}
+
+// PostpaidDunningPolicy 逾期天数阈值，负数表示不启用该阶段
+type PostpaidDunningPolicy struct {
+	WarnDays     int
+	ThrottleDays int
+	SuspendDays  int
+}
+
+// StageFor 按逾期秒数计算应处的催缴阶段
+func (p PostpaidDunningPolicy) StageFor(overdueSeconds int64) int {
+	days := int(overdueSeconds / 86400)
+	switch {
+	case p.SuspendDays >= 0 && days >= p.SuspendDays:
+		return PostpaidStageSuspended
+	case p.ThrottleDays >= 0 && days >= p.ThrottleDays:
+		return PostpaidStageThrottled
+	case p.WarnDays >= 0 && days >= p.WarnDays:
+		return PostpaidStageWarned
+	default:
+		return PostpaidStageCurrent
+	}
+}
+
+// PostpaidTransition 一次账期处理的结果，服务层据此发送通知
+type PostpaidTransition struct {
+	UserId        int   `json:"user_id"`
+	CycleClosed   bool  `json:"cycle_closed"`
+	Settled       bool  `json:"settled"`
+	PreviousStage int   `json:"previous_stage"`
+	Stage         int   `json:"stage"`
+	Outstanding   int64 `json:"outstanding"`
+	DueAt         int64 `json:"due_at"`
+}
+
+// effectiveCreditLimit 后付费未启用或催缴停用后信用额度不可用
+func effectiveCreditLimit(creditLimit int, dunningStage int) int {
+	if !operation_setting.IsPostpaidEnabled() || creditLimit <= 0 || dunningStage >= PostpaidStageSuspended {
+		return 0
+	}
+	return creditLimit
+}
+
+// GetUserAvailableQuota 返回可用于预扣的额度（余额 + 有效信用额度），优先读缓存
+func GetUserAvailableQuota(id int) (int, error) {
+	if common.RedisEnabled {
+		cache, err := GetUserCache(id)
+		if err != nil {
+			return 0, err
+		}
+		return cache.AvailableQuota(), nil
+	}
+	var user User
+	if err := DB.Select("id", "quota", "credit_limit", "dunning_stage").Where("id = ?", id).First(&user).Error; err != nil {
+		return 0, err
+	}
+	return user.Quota + effectiveCreditLimit(user.CreditLimit, user.DunningStage), nil
+}
+
+func GetPostpaidAccount(userId int) (*PostpaidAccount, error) {
+	var account PostpaidAccount
+	if err := DB.Where("user_id = ?", userId).First(&account).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPostpaidAccountNotFound
+		}
+		return nil, err
+	}
+	return &account, nil
+}
+
+// SetPostpaidAccount 开通或修改后付费账户。修改账期长度从下一个账期开始生效。
+func SetPostpaidAccount(userId int, creditLimit int, cycleDays int, paymentTermDays int) (*PostpaidAccount, error) {
+	if creditLimit < 0 || cycleDays <= 0 || paymentTermDays < 0 {
+		return nil, ErrPostpaidInvalidSettings
+	}
+	var account PostpaidAccount
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&User{}).Where("id = ?", userId).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		now := common.GetTimestamp()
+		err := tx.Where("user_id = ?", userId).First(&account).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			account = PostpaidAccount{
+				UserId:     userId,
+				CycleStart: now,
+				CycleEnd:   now + int64(cycleDays)*86400,
+				CreatedAt:  now,
+			}
+		case err != nil:
+			return err
+		}
+		account.CreditLimit = creditLimit
+		account.CycleDays = cycleDays
+		account.PaymentTermDays = paymentTermDays
+		account.UpdatedAt = now
+		if err := tx.Save(&account).Error; err != nil {
+			return err
+		}
+		return tx.Model(&User{}).Where("id = ?", userId).Update("credit_limit", creditLimit).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	syncPostpaidUserCache(userId, account.CreditLimit, account.DunningStage)
+	return &account, nil
+}
+
+// DeletePostpaidAccount 关闭后付费账户，仍有欠款（余额为负）时拒绝
+func DeletePostpaidAccount(userId int) error {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := tx.Select("id", "quota").Where("id = ?", userId).First(&user).Error; err != nil {
+			return err
+		}
+		if user.Quota < 0 {
+			return ErrPostpaidAccountHasDebt
+		}
+		result := tx.Where("user_id = ?", userId).Delete(&PostpaidAccount{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPostpaidAccountNotFound
+		}
+		return tx.Model(&User{}).Where("id = ?", userId).Updates(map[string]interface{}{
+			"credit_limit":  0,
+			"dunning_stage": PostpaidStageCurrent,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	syncPostpaidUserCache(userId, 0, PostpaidStageCurrent)
+	return nil
+}
+
+func syncPostpaidUserCache(userId int, creditLimit int, dunningStage int) {
+	if err := updateUserCacheField(userId, "CreditLimit", creditLimit); err != nil {
+		common.SysLog("failed to sync postpaid credit limit to user cache: " + err.Error())
+	}
+	if err := updateUserCacheField(userId, "DunningStage", dunningStage); err != nil {
+		common.SysLog("failed to sync postpaid dunning stage to user cache: " + err.Error())
+	}
+}
+
+// ProcessPostpaidAccount 处理一个后付费账户：先按当前余额核销应付金额，再结算已到期
+// 的账期，最后按逾期天数升级催缴阶段。可重复调用。
+func ProcessPostpaidAccount(userId int, now int64, policy PostpaidDunningPolicy) (*PostpaidTransition, error) {
+	var transition *PostpaidTransition
+	var account PostpaidAccount
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userId).First(&account).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPostpaidAccountNotFound
+			}
+			return err
+		}
+		var quota int
+		if err := tx.Model(&User{}).Where("id = ?", userId).Select("quota").Find(&quota).Error; err != nil {
+			return err
+		}
+		owed := int64(0)
+		if quota < 0 {
+			owed = int64(-quota)
+		}
+		transition = applyPostpaidCycle(&account, owed, now, policy)
+		account.UpdatedAt = now
+		if err := tx.Save(&account).Error; err != nil {
+			return err
+		}
+		if transition.Stage != transition.PreviousStage {
+			return tx.Model(&User{}).Where("id = ?", userId).Update("dunning_stage", transition.Stage).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if transition.Stage != transition.PreviousStage {
+		syncPostpaidUserCache(userId, account.CreditLimit, account.DunningStage)
+	}
+	return transition, nil
+}
+
+// applyPostpaidCycle 是 ProcessPostpaidAccount 的纯计算部分，owed 为当前欠款（负余额的绝对值）
+func applyPostpaidCycle(account *PostpaidAccount, owed int64, now int64, policy PostpaidDunningPolicy) *PostpaidTransition {
+	transition := &PostpaidTransition{UserId: account.UserId, PreviousStage: account.DunningStage}
+
+	// 还款核销：应付金额不会超过当前欠款
+	if account.OutstandingQuota > owed {
+		account.OutstandingQuota = owed
+	}
+	if account.OutstandingQuota == 0 && account.DueAt != 0 {
+		account.DueAt = 0
+		account.LastSettledAt = now
+		account.DunningStage = PostpaidStageCurrent
+		account.StageChangedAt = now
+		transition.Settled = true
+	}
+
+	// 账期结算：未结清的旧应付保留原到期日，新欠款并入应付金额
+	if account.CycleEnd > 0 && now >= account.CycleEnd {
+		closedAt := account.CycleEnd
+		cycleSeconds := int64(account.CycleDays) * 86400
+		if cycleSeconds <= 0 {
+			cycleSeconds = 30 * 86400
+		}
+		for account.CycleEnd <= now {
+			account.CycleStart = account.CycleEnd
+			account.CycleEnd += cycleSeconds
+		}
+		transition.CycleClosed = true
+		if owed > account.OutstandingQuota {
+			account.OutstandingQuota = owed
+			if account.DueAt == 0 {
+				account.DueAt = closedAt + int64(account.PaymentTermDays)*86400
+			}
+		}
+	}
+
+	// 催缴：只升级不降级，结清后才复位
+	if account.OutstandingQuota > 0 && account.DueAt > 0 && now >= account.DueAt {
+		if stage := policy.StageFor(now - account.DueAt); stage > account.DunningStage {
+			account.DunningStage = stage
+			account.StageChangedAt = now
+		}
+	}
+
+	transition.Stage = account.DunningStage
+	transition.Outstanding = account.OutstandingQuota
+	transition.DueAt = account.DueAt
+	return transition
+}
+
+// GetPostpaidAccountUserIds 返回全部后付费账户的用户 ID
+func GetPostpaidAccountUserIds() ([]int, error) {
+	var ids []int
+	err := DB.Model(&PostpaidAccount{}).Order("user_id asc").Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// HasPostpaidAccounts 是否存在后付费账户，用于调度器判断是否需要运行催缴任务
+func HasPostpaidAccounts() bool {
+	var count int64
+	if err := DB.Model(&PostpaidAccount{}).Limit(1).Count(&count).Error; err != nil {
+		return false
+	}
+	return count > 0
+}
+
+// GetPostpaidAccounts 管理端分页列出后付费账户，附带用户名与当前余额
+func GetPostpaidAccounts(startIdx int, num int) ([]*PostpaidAccount, int64, error) {
+	var total int64
+	if err := DB.Model(&PostpaidAccount{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var accounts []*PostpaidAccount
+	if err := DB.Order("user_id asc").Limit(num).Offset(startIdx).Find(&accounts).Error; err != nil {
+		return nil, 0, err
+	}
+	if len(accounts) == 0 {
+		return accounts, total, nil
+	}
+	userIds := make([]int, 0, len(accounts))
+	for _, account := range accounts {
+		userIds = append(userIds, account.UserId)
+	}
+	var users []User
+	if err := DB.Select("id", "username", "quota").Where("id IN ?", userIds).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	byId := make(map[int]User, len(users))
+	for _, user := range users {
+		byId[user.Id] = user
+	}
+	for _, account := range accounts {
+		account.Username = byId[account.UserId].Username
+		account.Balance = byId[account.UserId].Quota
+	}
+	return accounts, total, nil
+}
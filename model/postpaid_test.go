@@ -0,0 +1,159 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testPostpaidPolicy = PostpaidDunningPolicy{WarnDays: 0, ThrottleDays: 7, SuspendDays: 14}
+
+func enablePostpaidForTest(t *testing.T, enabled bool) {
+	t.Helper()
+	setting := operation_setting.GetPostpaidSetting()
+	previous := setting.Enabled
+	setting.Enabled = enabled
+	t.Cleanup(func() { setting.Enabled = previous })
+}
+
+func TestTryReserveUserQuotaAllowsOverdraftUpToCreditLimit(t *testing.T) {
+	truncateTables(t)
+	enablePostpaidForTest(t, true)
+	user := createReserveTestUser(t, 100)
+	_, err := SetPostpaidAccount(user.Id, 500, 30, 15)
+	require.NoError(t, err)
+
+	reserved, err := TryReserveUserQuota(user.Id, 550, CreditRef{Source: CreditSourceUsage})
+	require.NoError(t, err)
+	assert.True(t, reserved)
+	assert.Equal(t, -450, getUserQuotaFromDB(t, user.Id))
+
+	reserved, err = TryReserveUserQuota(user.Id, 100, CreditRef{Source: CreditSourceUsage})
+	require.NoError(t, err)
+	assert.False(t, reserved, "reserve must not exceed the credit limit")
+
+	available, err := GetUserAvailableQuota(user.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 50, available)
+
+	require.NoError(t, DB.Model(&User{}).Where("id = ?", user.Id).Update("dunning_stage", PostpaidStageSuspended).Error)
+	reserved, err = TryReserveUserQuota(user.Id, 10, CreditRef{Source: CreditSourceUsage})
+	require.NoError(t, err)
+	assert.False(t, reserved, "suspended accounts lose their credit line")
+}
+
+func TestApplyPostpaidCycleClosesAndEscalates(t *testing.T) {
+	const day = int64(86400)
+	account := &PostpaidAccount{UserId: 1, CreditLimit: 1000, CycleDays: 30, PaymentTermDays: 10, CycleStart: 0, CycleEnd: 30 * day}
+
+	transition := applyPostpaidCycle(account, 400, 29*day, testPostpaidPolicy)
+	assert.False(t, transition.CycleClosed)
+	assert.EqualValues(t, 0, account.OutstandingQuota)
+
+	transition = applyPostpaidCycle(account, 400, 30*day+5, testPostpaidPolicy)
+	assert.True(t, transition.CycleClosed)
+	assert.EqualValues(t, 400, transition.Outstanding)
+	assert.Equal(t, 40*day, account.DueAt)
+	assert.Equal(t, 60*day, account.CycleEnd)
+	assert.Equal(t, PostpaidStageCurrent, transition.Stage)
+
+	transition = applyPostpaidCycle(account, 450, 40*day, testPostpaidPolicy)
+	assert.Equal(t, PostpaidStageWarned, transition.Stage)
+	assert.EqualValues(t, 400, transition.Outstanding, "usage in the open cycle is not yet due")
+
+	transition = applyPostpaidCycle(account, 450, 47*day, testPostpaidPolicy)
+	assert.Equal(t, PostpaidStageThrottled, transition.Stage)
+
+	// 部分还款只减少应付金额，不复位催缴
+	transition = applyPostpaidCycle(account, 100, 55*day, testPostpaidPolicy)
+	assert.EqualValues(t, 100, transition.Outstanding)
+	assert.Equal(t, PostpaidStageSuspended, transition.Stage)
+
+	transition = applyPostpaidCycle(account, 0, 56*day, testPostpaidPolicy)
+	assert.True(t, transition.Settled)
+	assert.Equal(t, PostpaidStageCurrent, transition.Stage)
+	assert.EqualValues(t, 0, account.DueAt)
+}
+
+func TestProcessPostpaidAccountMirrorsStageOnUser(t *testing.T) {
+	truncateTables(t)
+	user := createReserveTestUser(t, -300)
+	account, err := SetPostpaidAccount(user.Id, 1000, 30, 0)
+	require.NoError(t, err)
+
+	closedAt := account.CycleEnd
+	transition, err := ProcessPostpaidAccount(user.Id, closedAt+14*86400, testPostpaidPolicy)
+	require.NoError(t, err)
+	assert.True(t, transition.CycleClosed)
+	assert.Equal(t, PostpaidStageSuspended, transition.Stage)
+
+	var stored User
+	require.NoError(t, DB.Select("id", "credit_limit", "dunning_stage").First(&stored, user.Id).Error)
+	assert.Equal(t, 1000, stored.CreditLimit)
+	assert.Equal(t, PostpaidStageSuspended, stored.DunningStage)
+
+	require.ErrorIs(t, DeletePostpaidAccount(user.Id), ErrPostpaidAccountHasDebt)
+
+	require.NoError(t, IncreaseUserQuota(user.Id, 300, true, CreditRef{Source: CreditSourceUsage}))
+	transition, err = ProcessPostpaidAccount(user.Id, closedAt+15*86400, testPostpaidPolicy)
+	require.NoError(t, err)
+	assert.True(t, transition.Settled)
+	require.NoError(t, DB.Select("id", "dunning_stage").First(&stored, user.Id).Error)
+	assert.Equal(t, PostpaidStageCurrent, stored.DunningStage)
+
+	require.NoError(t, DeletePostpaidAccount(user.Id))
+	require.NoError(t, DB.Select("id", "credit_limit").First(&stored, user.Id).Error)
+	assert.Equal(t, 0, stored.CreditLimit)
+}
+
+func TestCachedReserveHonoursCreditLimitAndSuspension(t *testing.T) {
+	truncateTables(t)
+	enablePostpaidForTest(t, true)
+	useUserCacheMiniRedis(t)
+	user := createReserveTestUser(t, 0)
+	_, err := SetPostpaidAccount(user.Id, 200, 30, 15)
+	require.NoError(t, err)
+
+	cache, err := GetUserCache(user.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 200, cache.AvailableQuota())
+
+	reserved, err := TryReserveUserQuota(user.Id, 150, CreditRef{Source: CreditSourceUsage})
+	require.NoError(t, err)
+	assert.True(t, reserved)
+	reserved, err = TryReserveUserQuota(user.Id, 100, CreditRef{Source: CreditSourceUsage})
+	require.NoError(t, err)
+	assert.False(t, reserved)
+
+	require.NoError(t, DB.Model(&User{}).Where("id = ?", user.Id).Update("dunning_stage", PostpaidStageSuspended).Error)
+	syncPostpaidUserCache(user.Id, 200, PostpaidStageSuspended)
+	reserved, err = TryReserveUserQuota(user.Id, 10, CreditRef{Source: CreditSourceUsage})
+	require.NoError(t, err)
+	assert.False(t, reserved)
+}
+
+func TestCreditLimitIgnoredWhenPostpaidDisabled(t *testing.T) {
+	truncateTables(t)
+	enablePostpaidForTest(t, false)
+	user := createReserveTestUser(t, 100)
+	_, err := SetPostpaidAccount(user.Id, 500, 30, 15)
+	require.NoError(t, err)
+
+	reserved, err := TryReserveUserQuota(user.Id, 150, CreditRef{Source: CreditSourceUsage})
+	require.NoError(t, err)
+	assert.False(t, reserved, "credit limit must not apply while postpaid is disabled")
+
+	available, err := GetUserAvailableQuota(user.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 100, available)
+
+	useUserCacheMiniRedis(t)
+	cache, err := GetUserCache(user.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 100, cache.AvailableQuota())
+	reserved, err = TryReserveUserQuota(user.Id, 150, CreditRef{Source: CreditSourceUsage})
+	require.NoError(t, err)
+	assert.False(t, reserved)
+}
@@ -0,0 +1,541 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/billing_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+
+	"gorm.io/gorm"
+)
+
+// 价格表（price book）是模型倍率、补全倍率、固定价格与分段计费表达式的完整快照。
+// 每个价格表的 Id 即价格版本号：预约的价格表在 EffectiveFrom 到达后由系统任务写入
+// options 表生效；通过设置页直接修改价格时也会记录一个立即生效的快照，因此历史价格
+// 不会丢失。当前生效的版本号保存在 PriceBookVersion 选项中，随选项同步到所有节点，
+// 消费日志据此记录所用的价格版本。
+const (
+	PriceBookStatusPending    = "pending"
+	PriceBookStatusActive     = "active"
+	PriceBookStatusSuperseded = "superseded"
+	PriceBookStatusCancelled  = "cancelled"
+
+	PriceBookVersionOptionKey = "PriceBookVersion"
+)
+
+var (
+	ErrPriceBookNotFound   = errors.New("price book not found")
+	ErrPriceBookNotPending = errors.New("price book is not pending")
+)
+
+// PriceBookOptionKeys 价格表覆盖的选项，修改其中任意一项都会产生新的价格版本
+var PriceBookOptionKeys = []string{
+	"ModelRatio",
+	"CompletionRatio",
+	"ModelPrice",
+	"billing_setting." + billing_setting.BillingModeField,
+	"billing_setting." + billing_setting.BillingExprField,
+}
+
+var activePriceBookVersion atomic.Int64
+
+// GetActivePriceBookVersion 返回当前生效的价格版本号，尚未建立价格表时为 0
+func GetActivePriceBookVersion() int {
+	return int(activePriceBookVersion.Load())
+}
+
+func IsPriceBookOptionKey(key string) bool {
+	for _, k := range PriceBookOptionKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+type PriceBook struct {
+	Id            int    `json:"id"`
+	Status        string `json:"status" gorm:"type:varchar(16);index"`
+	EffectiveFrom int64  `json:"effective_from" gorm:"bigint;index"`
+	AppliedAt     int64  `json:"applied_at" gorm:"bigint"`
+	Content       string `json:"-" gorm:"type:text"`
+	Note          string `json:"note" gorm:"type:varchar(255)"`
+	CreatedBy     int    `json:"created_by"`
+	CreatedAt     int64  `json:"created_at" gorm:"bigint"`
+}
+
+// PriceBookContent 价格表内容，字段与对应选项的 JSON 结构一致
+type PriceBookContent struct {
+	ModelRatio      map[string]float64 `json:"model_ratio"`
+	CompletionRatio map[string]float64 `json:"completion_ratio"`
+	ModelPrice      map[string]float64 `json:"model_price"`
+	BillingMode     map[string]string  `json:"billing_mode"`
+	BillingExpr     map[string]string  `json:"billing_expr"`
+}
+
+// GetCurrentPriceBookContent 读取内存中正在使用的价格
+func GetCurrentPriceBookContent() *PriceBookContent {
+	return &PriceBookContent{
+		ModelRatio:      ratio_setting.GetModelRatioCopy(),
+		CompletionRatio: ratio_setting.GetCompletionRatioCopy(),
+		ModelPrice:      ratio_setting.GetModelPriceCopy(),
+		BillingMode:     billing_setting.GetBillingModeCopy(),
+		BillingExpr:     billing_setting.GetBillingExprCopy(),
+	}
+}
+
+func (c *PriceBookContent) normalize() {
+	if c.ModelRatio == nil {
+		c.ModelRatio = map[string]float64{}
+	}
+	if c.CompletionRatio == nil {
+		c.CompletionRatio = map[string]float64{}
+	}
+	if c.ModelPrice == nil {
+		c.ModelPrice = map[string]float64{}
+	}
+	if c.BillingMode == nil {
+		c.BillingMode = map[string]string{}
+	}
+	if c.BillingExpr == nil {
+		c.BillingExpr = map[string]string{}
+	}
+}
+
+// Validate 校验倍率与价格非负、计费模式合法，并对分段计费表达式做冒烟测试
+func (c *PriceBookContent) Validate() error {
+	for _, m := range []map[string]float64{c.ModelRatio, c.CompletionRatio, c.ModelPrice} {
+		for name, value := range m {
+			if value < 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+				return fmt.Errorf("invalid price for model %s", name)
+			}
+		}
+	}
+	for name, mode := range c.BillingMode {
+		switch mode {
+		case billing_setting.BillingModeRatio:
+		case billing_setting.BillingModeTieredExpr:
+			if c.BillingExpr[name] == "" {
+				return fmt.Errorf("model %s uses %s billing but has no expression", name, mode)
+			}
+		default:
+			return fmt.Errorf("unknown billing mode %q for model %s", mode, name)
+		}
+	}
+	for name, expr := range c.BillingExpr {
+		if err := billing_setting.SmokeTestExpr(expr); err != nil {
+			return fmt.Errorf("invalid billing expression for model %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// optionValues 转换为生效时写入 options 表的键值
+func (c *PriceBookContent) optionValues() (map[string]string, error) {
+	values := make(map[string]string, len(PriceBookOptionKeys))
+	fields := []any{c.ModelRatio, c.CompletionRatio, c.ModelPrice, c.BillingMode, c.BillingExpr}
+	for i, key := range PriceBookOptionKeys {
+		data, err := common.Marshal(fields[i])
+		if err != nil {
+			return nil, err
+		}
+		values[key] = string(data)
+	}
+	return values, nil
+}
+
+func (b *PriceBook) GetContent() (*PriceBookContent, error) {
+	content := &PriceBookContent{}
+	if b.Content != "" {
+		if err := common.UnmarshalJsonStr(b.Content, content); err != nil {
+			return nil, err
+		}
+	}
+	content.normalize()
+	return content, nil
+}
+
+func (b *PriceBook) setContent(content *PriceBookContent) error {
+	content.normalize()
+	data, err := common.Marshal(content)
+	if err != nil {
+		return err
+	}
+	b.Content = string(data)
+	return nil
+}
+
+// PriceBookModelPricing 单个模型在某个价格表中的定价，未配置的项为 null
+type PriceBookModelPricing struct {
+	ModelRatio      *float64 `json:"model_ratio"`
+	CompletionRatio *float64 `json:"completion_ratio"`
+	ModelPrice      *float64 `json:"model_price"`
+	BillingMode     string   `json:"billing_mode,omitempty"`
+	BillingExpr     string   `json:"billing_expr,omitempty"`
+}
+
+func lookupPrice(m map[string]float64, name string) *float64 {
+	if value, ok := m[name]; ok {
+		return &value
+	}
+	return nil
+}
+
+func samePrice(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func (c *PriceBookContent) ModelPricing(modelName string) PriceBookModelPricing {
+	return PriceBookModelPricing{
+		ModelRatio:      lookupPrice(c.ModelRatio, modelName),
+		CompletionRatio: lookupPrice(c.CompletionRatio, modelName),
+		ModelPrice:      lookupPrice(c.ModelPrice, modelName),
+		BillingMode:     c.BillingMode[modelName],
+		BillingExpr:     c.BillingExpr[modelName],
+	}
+}
+
+func (p PriceBookModelPricing) configured() bool {
+	return p.ModelRatio != nil || p.CompletionRatio != nil || p.ModelPrice != nil || p.BillingMode != "" || p.BillingExpr != ""
+}
+
+func (p PriceBookModelPricing) equal(other PriceBookModelPricing) bool {
+	return samePrice(p.ModelRatio, other.ModelRatio) &&
+		samePrice(p.CompletionRatio, other.CompletionRatio) &&
+		samePrice(p.ModelPrice, other.ModelPrice) &&
+		p.BillingMode == other.BillingMode &&
+		p.BillingExpr == other.BillingExpr
+}
+
+// PriceBookChange 两个价格表之间单个模型单项价格的差异，Old/New 为 null 表示新增或删除
+type PriceBookChange struct {
+	ModelName string `json:"model_name"`
+	Field     string `json:"field"`
+	Old       any    `json:"old"`
+	New       any    `json:"new"`
+}
+
+func diffPriceField[V comparable](changes []PriceBookChange, field string, from, to map[string]V) []PriceBookChange {
+	for name, oldValue := range from {
+		newValue, ok := to[name]
+		switch {
+		case !ok:
+			changes = append(changes, PriceBookChange{ModelName: name, Field: field, Old: oldValue})
+		case newValue != oldValue:
+			changes = append(changes, PriceBookChange{ModelName: name, Field: field, Old: oldValue, New: newValue})
+		}
+	}
+	for name, newValue := range to {
+		if _, ok := from[name]; !ok {
+			changes = append(changes, PriceBookChange{ModelName: name, Field: field, New: newValue})
+		}
+	}
+	return changes
+}
+
+// DiffPriceBookContent 按模型名、字段排序列出 from 到 to 的全部价格变化
+func DiffPriceBookContent(from, to *PriceBookContent) []PriceBookChange {
+	changes := make([]PriceBookChange, 0)
+	changes = diffPriceField(changes, "model_ratio", from.ModelRatio, to.ModelRatio)
+	changes = diffPriceField(changes, "completion_ratio", from.CompletionRatio, to.CompletionRatio)
+	changes = diffPriceField(changes, "model_price", from.ModelPrice, to.ModelPrice)
+	changes = diffPriceField(changes, "billing_mode", from.BillingMode, to.BillingMode)
+	changes = diffPriceField(changes, "billing_expr", from.BillingExpr, to.BillingExpr)
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].ModelName != changes[j].ModelName {
+			return changes[i].ModelName < changes[j].ModelName
+		}
+		return changes[i].Field < changes[j].Field
+	})
+	return changes
+}
+
+func getActivePriceBook(tx *gorm.DB) (*PriceBook, error) {
+	var books []*PriceBook
+	if err := tx.Where("status = ?", PriceBookStatusActive).Order("id desc").Limit(1).Find(&books).Error; err != nil {
+		return nil, err
+	}
+	if len(books) == 0 {
+		return nil, nil
+	}
+	return books[0], nil
+}
+
+// activatePriceBook 在事务中将 book 置为唯一生效的价格表，并把其内容写入 options 表。
+// 返回需要在事务提交后应用到内存的选项值。
+func activatePriceBook(tx *gorm.DB, book *PriceBook, content *PriceBookContent, now int64) (map[string]string, error) {
+	values, err := content.optionValues()
+	if err != nil {
+		return nil, err
+	}
+	values[PriceBookVersionOptionKey] = strconv.Itoa(book.Id)
+	if err := tx.Model(&PriceBook{}).
+		Where("status = ? AND id <> ?", PriceBookStatusActive, book.Id).
+		Update("status", PriceBookStatusSuperseded).Error; err != nil {
+		return nil, err
+	}
+	if err := saveOptionsTx(tx, values); err != nil {
+		return nil, err
+	}
+	book.Status = PriceBookStatusActive
+	book.AppliedAt = now
+	return values, nil
+}
+
+// RecordPriceBookSnapshot 将当前内存中的价格记录为一个立即生效的版本。
+// 与当前生效版本内容相同时不产生新版本。用于设置页直接修改价格之后，以及首次
+// 预约价格表时为现有价格建立基线。
+func RecordPriceBookSnapshot(note string, createdBy int) (*PriceBook, error) {
+	content := GetCurrentPriceBookContent()
+	var values map[string]string
+	var book *PriceBook
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		active, err := getActivePriceBook(tx)
+		if err != nil {
+			return err
+		}
+		if active != nil {
+			activeContent, err := active.GetContent()
+			if err == nil && len(DiffPriceBookContent(activeContent, content)) == 0 {
+				book = active
+				return nil
+			}
+		}
+		now := common.GetTimestamp()
+		book = &PriceBook{
+			Status:        PriceBookStatusActive,
+			EffectiveFrom: now,
+			AppliedAt:     now,
+			Note:          note,
+			CreatedBy:     createdBy,
+			CreatedAt:     now,
+		}
+		if err := book.setContent(content); err != nil {
+			return err
+		}
+		if err := tx.Create(book).Error; err != nil {
+			return err
+		}
+		// 价格本身已在内存与 options 表中，只需记录版本号
+		values = map[string]string{PriceBookVersionOptionKey: strconv.Itoa(book.Id)}
+		if err := tx.Model(&PriceBook{}).
+			Where("status = ? AND id <> ?", PriceBookStatusActive, book.Id).
+			Update("status", PriceBookStatusSuperseded).Error; err != nil {
+			return err
+		}
+		return saveOptionsTx(tx, values)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if values != nil {
+		if err := applyOptionValues(values); err != nil {
+			return nil, err
+		}
+	}
+	return book, nil
+}
+
+// CreatePriceBook 预约一个在 effectiveFrom 生效的价格表。首次使用时先为当前价格
+// 建立基线版本，保证生效前的价格也可追溯。
+func CreatePriceBook(content *PriceBookContent, effectiveFrom int64, note string, createdBy int) (*PriceBook, error) {
+	content.normalize()
+	if err := content.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := RecordPriceBookSnapshot("baseline", createdBy); err != nil {
+		return nil, err
+	}
+	book := &PriceBook{
+		Status:        PriceBookStatusPending,
+		EffectiveFrom: effectiveFrom,
+		Note:          note,
+		CreatedBy:     createdBy,
+		CreatedAt:     common.GetTimestamp(),
+	}
+	if err := book.setContent(content); err != nil {
+		return nil, err
+	}
+	if err := DB.Create(book).Error; err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// ApplyDuePriceBooks 应用已到生效时间的预约价格表。多个价格表同时到期时只应用
+// 生效时间最晚的一个，其余直接标记为已被取代。没有到期价格表时返回 nil。
+func ApplyDuePriceBooks(now int64) (*PriceBook, error) {
+	var due []*PriceBook
+	if err := DB.Where("status = ? AND effective_from <= ?", PriceBookStatusPending, now).
+		Order("effective_from desc, id desc").Find(&due).Error; err != nil {
+		return nil, err
+	}
+	if len(due) == 0 {
+		return nil, nil
+	}
+	book := due[0]
+	content, err := book.GetContent()
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]string
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		// 条件更新防止多个节点重复应用同一价格表
+		result := tx.Model(&PriceBook{}).
+			Where("id = ? AND status = ?", book.Id, PriceBookStatusPending).
+			Updates(map[string]interface{}{"status": PriceBookStatusActive, "applied_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPriceBookNotPending
+		}
+		values, err = activatePriceBook(tx, book, content, now)
+		if err != nil {
+			return err
+		}
+		for _, skipped := range due[1:] {
+			if err := tx.Model(&PriceBook{}).
+				Where("id = ? AND status = ?", skipped.Id, PriceBookStatusPending).
+				Update("status", PriceBookStatusSuperseded).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, ErrPriceBookNotPending) {
+		// 已被其他节点应用
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := applyOptionValues(values); err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// HasPendingPriceBooks 是否存在待生效的价格表，用于调度器判断是否需要运行
+func HasPendingPriceBooks() bool {
+	var count int64
+	if err := DB.Model(&PriceBook{}).Where("status = ?", PriceBookStatusPending).Limit(1).Count(&count).Error; err != nil {
+		return false
+	}
+	return count > 0
+}
+
+func GetPriceBookById(id int) (*PriceBook, error) {
+	var book PriceBook
+	err := DB.First(&book, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPriceBookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+func GetPriceBooks(status string, startIdx int, num int) ([]*PriceBook, int64, error) {
+	var books []*PriceBook
+	var total int64
+	query := DB.Model(&PriceBook{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("id desc").Limit(num).Offset(startIdx).Find(&books).Error; err != nil {
+		return nil, 0, err
+	}
+	return books, total, nil
+}
+
+// CancelPriceBook 取消尚未生效的价格表
+func CancelPriceBook(id int) error {
+	result := DB.Model(&PriceBook{}).
+		Where("id = ? AND status = ?", id, PriceBookStatusPending).
+		Update("status", PriceBookStatusCancelled)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPriceBookNotPending
+	}
+	return nil
+}
+
+// PriceBookModelHistory 模型价格历史中的一条记录
+type PriceBookModelHistory struct {
+	Version       int    `json:"version"`
+	Status        string `json:"status"`
+	EffectiveFrom int64  `json:"effective_from"`
+	AppliedAt     int64  `json:"applied_at"`
+	Note          string `json:"note"`
+	PriceBookModelPricing
+}
+
+// GetModelPriceHistory 按生效顺序列出模型的价格变化，只包含该模型定价发生变化的版本；
+// 待生效的价格表排在最后。
+func GetModelPriceHistory(modelName string) ([]PriceBookModelHistory, error) {
+	var books []*PriceBook
+	if err := DB.Where("status <> ?", PriceBookStatusCancelled).Find(&books).Error; err != nil {
+		return nil, err
+	}
+	sortKey := func(b *PriceBook) int64 {
+		if b.AppliedAt > 0 {
+			return b.AppliedAt
+		}
+		return b.EffectiveFrom
+	}
+	sort.SliceStable(books, func(i, j int) bool {
+		pi, pj := books[i].Status == PriceBookStatusPending, books[j].Status == PriceBookStatusPending
+		if pi != pj {
+			return pj
+		}
+		if ki, kj := sortKey(books[i]), sortKey(books[j]); ki != kj {
+			return ki < kj
+		}
+		return books[i].Id < books[j].Id
+	})
+	history := make([]PriceBookModelHistory, 0)
+	var last *PriceBookModelPricing
+	for _, book := range books {
+		// 同时到期而被跳过的价格表从未生效
+		if book.Status == PriceBookStatusSuperseded && book.AppliedAt == 0 {
+			continue
+		}
+		content, err := book.GetContent()
+		if err != nil {
+			return nil, err
+		}
+		pricing := content.ModelPricing(modelName)
+		if last == nil && !pricing.configured() {
+			continue
+		}
+		if last != nil && last.equal(pricing) {
+			continue
+		}
+		history = append(history, PriceBookModelHistory{
+			Version:               book.Id,
+			Status:                book.Status,
+			EffectiveFrom:         book.EffectiveFrom,
+			AppliedAt:             book.AppliedAt,
+			Note:                  book.Note,
+			PriceBookModelPricing: pricing,
+		})
+		last = &pricing
+	}
+	return history, nil
+}
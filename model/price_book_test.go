@@ -0,0 +1,138 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// usePriceBookTestDB 使用独立的内存库，并在测试结束后恢复全局价格与选项
+func usePriceBookTestDB(t *testing.T) {
+	t.Helper()
+	previousDB := DB
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&Option{}, &PriceBook{}))
+	DB = db
+
+	previousMap := common.OptionMap
+	common.OptionMap = map[string]string{}
+	previousValues, err := GetCurrentPriceBookContent().optionValues()
+	require.NoError(t, err)
+	previousVersion := activePriceBookVersion.Load()
+	t.Cleanup(func() {
+		DB = previousDB
+		require.NoError(t, applyOptionValues(previousValues))
+		common.OptionMap = previousMap
+		activePriceBookVersion.Store(previousVersion)
+	})
+	require.NoError(t, UpdateOption("ModelRatio", `{"model-a":1,"model-b":2}`))
+	require.NoError(t, UpdateOption("CompletionRatio", `{"model-a":3}`))
+	require.NoError(t, UpdateOption("ModelPrice", `{}`))
+	require.NoError(t, UpdateOption("billing_setting.billing_mode", `{}`))
+	require.NoError(t, UpdateOption("billing_setting.billing_expr", `{}`))
+}
+
+func TestDiffPriceBookContent(t *testing.T) {
+	from := &PriceBookContent{
+		ModelRatio:  map[string]float64{"a": 1, "b": 2},
+		BillingMode: map[string]string{"a": "ratio"},
+	}
+	to := &PriceBookContent{
+		ModelRatio:  map[string]float64{"a": 1.5, "c": 3},
+		BillingMode: map[string]string{"a": "ratio"},
+	}
+	changes := DiffPriceBookContent(from, to)
+	require.Len(t, changes, 3)
+	assert.Equal(t, PriceBookChange{ModelName: "a", Field: "model_ratio", Old: 1.0, New: 1.5}, changes[0])
+	assert.Equal(t, PriceBookChange{ModelName: "b", Field: "model_ratio", Old: 2.0}, changes[1])
+	assert.Equal(t, PriceBookChange{ModelName: "c", Field: "model_ratio", New: 3.0}, changes[2])
+}
+
+func TestScheduledPriceBookAppliesAtEffectiveTime(t *testing.T) {
+	usePriceBookTestDB(t)
+	content := GetCurrentPriceBookContent()
+	content.ModelRatio["model-a"] = 4
+	delete(content.ModelRatio, "model-b")
+
+	book, err := CreatePriceBook(content, 2000, "raise model-a", 1)
+	require.NoError(t, err)
+	assert.Equal(t, PriceBookStatusPending, book.Status)
+	baseline := GetActivePriceBookVersion()
+	require.NotZero(t, baseline, "scheduling a price book records the current prices as a baseline")
+	assert.True(t, HasPendingPriceBooks())
+
+	applied, err := ApplyDuePriceBooks(1999)
+	require.NoError(t, err)
+	assert.Nil(t, applied)
+	ratio, _, _ := ratio_setting.GetModelRatio("model-a")
+	assert.Equal(t, 1.0, ratio)
+
+	applied, err = ApplyDuePriceBooks(2000)
+	require.NoError(t, err)
+	require.NotNil(t, applied)
+	assert.Equal(t, book.Id, GetActivePriceBookVersion())
+	ratio, _, _ = ratio_setting.GetModelRatio("model-a")
+	assert.Equal(t, 4.0, ratio)
+	_, ok := ratio_setting.GetModelRatioCopy()["model-b"]
+	assert.False(t, ok)
+	assert.Equal(t, `{"model-a":4}`, requireOptionValue(t, DB, "ModelRatio"))
+	assert.False(t, HasPendingPriceBooks())
+
+	previous, err := GetPriceBookById(baseline)
+	require.NoError(t, err)
+	assert.Equal(t, PriceBookStatusSuperseded, previous.Status)
+}
+
+func TestModelPriceHistoryListsChanges(t *testing.T) {
+	usePriceBookTestDB(t)
+	_, err := RecordPriceBookSnapshot("baseline", 1)
+	require.NoError(t, err)
+
+	// 只修改 model-b 的版本不应出现在 model-a 的历史中
+	require.NoError(t, UpdateOption("ModelRatio", `{"model-a":1,"model-b":5}`))
+	_, err = RecordPriceBookSnapshot("option:ModelRatio", 1)
+	require.NoError(t, err)
+
+	content := GetCurrentPriceBookContent()
+	content.ModelRatio["model-a"] = 2
+	now := common.GetTimestamp()
+	first, err := CreatePriceBook(content, now, "", 1)
+	require.NoError(t, err)
+	_, err = ApplyDuePriceBooks(now)
+	require.NoError(t, err)
+
+	content.ModelRatio["model-a"] = 3
+	pending, err := CreatePriceBook(content, now+3600, "", 1)
+	require.NoError(t, err)
+
+	history, err := GetModelPriceHistory("model-a")
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, 1.0, *history[0].ModelRatio)
+	assert.Equal(t, 3.0, *history[0].CompletionRatio)
+	assert.Equal(t, first.Id, history[1].Version)
+	assert.Equal(t, 2.0, *history[1].ModelRatio)
+	assert.Equal(t, pending.Id, history[2].Version)
+	assert.Equal(t, PriceBookStatusPending, history[2].Status)
+
+	require.NoError(t, CancelPriceBook(pending.Id))
+	assert.ErrorIs(t, CancelPriceBook(pending.Id), ErrPriceBookNotPending)
+	history, err = GetModelPriceHistory("model-a")
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+}
+
+func TestPriceBookContentValidate(t *testing.T) {
+	content := &PriceBookContent{ModelRatio: map[string]float64{"a": -1}}
+	assert.Error(t, content.Validate())
+	content = &PriceBookContent{BillingMode: map[string]string{"a": "tiered_expr"}}
+	assert.Error(t, content.Validate())
+	content = &PriceBookContent{BillingMode: map[string]string{"a": "unknown"}}
+	assert.Error(t, content.Validate())
+}
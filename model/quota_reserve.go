@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"gorm.io/gorm"
 )
 
@@ -24,7 +25,11 @@ if tonumber(redis.call('HGET', KEYS[1], 'Id') or '0') ~= tonumber(ARGV[2])
   return -1
 end
 local quota = tonumber(redis.call('HGET', KEYS[1], 'Quota'))
-if quota == nil or quota < tonumber(ARGV[1]) then
+local credit = tonumber(redis.call('HGET', KEYS[1], 'CreditLimit') or '0') or 0
+if ARGV[5] ~= '1' or (tonumber(redis.call('HGET', KEYS[1], 'DunningStage') or '0') or 0) >= tonumber(ARGV[4]) then
+  credit = 0
+end
+if quota == nil or quota + credit < tonumber(ARGV[1]) then
   return 0
 end
 redis.call('HINCRBY', KEYS[1], 'Quota', -tonumber(ARGV[1]))
@@ -80,8 +85,12 @@ func quotaResultFromLua(result int, err error) (cacheQuotaResult, error) {
 }
 
 func cacheTryReserveUserQuota(userID int, amount int64) (cacheQuotaResult, error) {
+	postpaid := 0
+	if operation_setting.IsPostpaidEnabled() {
+		postpaid = 1
+	}
 	result, err := common.RDB.Eval(context.Background(), userQuotaReserveScript,
-		[]string{getUserCacheKey(userID)}, amount, userID, userCacheSchemaVersion).Int()
+		[]string{getUserCacheKey(userID)}, amount, userID, userCacheSchemaVersion, PostpaidStageSuspended, postpaid).Int()
 	return quotaResultFromLua(result, err)
 }
 
@@ -105,21 +114,42 @@ func cacheApplyTokenQuotaDelta(id int, key string, delta int64) (cacheQuotaResul
 
 // persistUserQuotaDelta 把已在缓存侧预扣成功的增量落库；批量模式下入队，
 // 直写模式下要求行存在（用户已删除时报错，交由调用方补偿缓存）。
-func persistUserQuotaDelta(id int, delta int) error {
+func persistUserQuotaDelta(id int, delta int, ref CreditRef) error {
 	if common.BatchUpdateEnabled {
-		addNewRecord(BatchUpdateTypeUserQuota, id, delta)
+		addUserQuotaRecord(id, delta, ref)
 		return nil
 	}
-	result := DB.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", delta))
-	if result.Error != nil {
-		return result.Error
+	rows, err := applyUserQuotaDelta(id, delta, ref)
+	if err != nil {
+		return err
 	}
-	if result.RowsAffected != 1 {
+	if rows != 1 {
 		return gorm.ErrRecordNotFound
 	}
 	return nil
 }
 
+// applyUserQuotaDelta 直接落库一笔钱包额度变动；账本启用时与记账在同一事务内。
+func applyUserQuotaDelta(id int, delta int, ref CreditRef) (int64, error) {
+	update := func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", delta))
+	}
+	if !operation_setting.IsCreditLedgerEnabled() {
+		result := update(DB)
+		return result.RowsAffected, result.Error
+	}
+	var rows int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := PostCreditUsage(tx, id, ref, delta); err != nil {
+			return err
+		}
+		result := update(tx)
+		rows = result.RowsAffected
+		return result.Error
+	})
+	return rows, err
+}
+
 func persistTokenQuotaDelta(id int, delta int) error {
 	if common.BatchUpdateEnabled {
 		addNewRecord(BatchUpdateTypeTokenQuota, id, delta)
@@ -141,11 +171,41 @@ func persistTokenQuotaDelta(id int, delta int) error {
 	return nil
 }
 
-func reserveUserQuotaDB(id int, quota int) (bool, error) {
-	result := DB.Model(&User{}).
-		Where("id = ? AND quota >= ?", id, quota).
-		Update("quota", gorm.Expr("quota - ?", quota))
-	return result.RowsAffected == 1, result.Error
+var errUserQuotaNotReserved = errors.New("user quota not reserved")
+
+// reserveUserQuotaDB 条件扣减；启用后付费时用户允许透支到信用额度，催缴停用后信用额度不再可用
+func reserveUserQuotaDB(id int, quota int, ref CreditRef) (bool, error) {
+	postpaid := operation_setting.IsPostpaidEnabled()
+	reserve := func(tx *gorm.DB) *gorm.DB {
+		query := tx.Model(&User{})
+		if postpaid {
+			query = query.Where("id = ? AND quota + (CASE WHEN dunning_stage >= ? THEN 0 ELSE credit_limit END) >= ?", id, PostpaidStageSuspended, quota)
+		} else {
+			query = query.Where("id = ? AND quota >= ?", id, quota)
+		}
+		return query.Update("quota", gorm.Expr("quota - ?", quota))
+	}
+	if !operation_setting.IsCreditLedgerEnabled() {
+		result := reserve(DB)
+		return result.RowsAffected == 1, result.Error
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := PostCreditUsage(tx, id, ref, -quota); err != nil {
+			return err
+		}
+		result := reserve(tx)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errUserQuotaNotReserved
+		}
+		return nil
+	})
+	if errors.Is(err, errUserQuotaNotReserved) || errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
 }
 
 func reserveTokenQuotaDB(id int, quota int) (bool, error) {
@@ -160,9 +220,11 @@ func reserveTokenQuotaDB(id int, quota int) (bool, error) {
 }
 
 // TryReserveUserQuota atomically checks and deducts a user's wallet quota.
+// Postpaid users may go negative down to their credit limit. ref names the
+// ledger counterparty, posted in the same transaction as the deduction.
 // 缓存命中时以缓存余额为准（避免批量模式下过期的数据库余额放大并发超扣）；
 // Redis 异常或水合失败时降级为数据库条件更新，保证服务可用。
-func TryReserveUserQuota(id int, quota int) (bool, error) {
+func TryReserveUserQuota(id int, quota int, ref CreditRef) (bool, error) {
 	if quota < 0 {
 		return false, errors.New("quota 不能为负数！")
 	}
@@ -170,7 +232,7 @@ func TryReserveUserQuota(id int, quota int) (bool, error) {
 		return true, nil
 	}
 	if !common.RedisEnabled {
-		return reserveUserQuotaDB(id, quota)
+		return reserveUserQuotaDB(id, quota, ref)
 	}
 
 	result, err := cacheTryReserveUserQuota(id, int64(quota))
@@ -183,12 +245,12 @@ func TryReserveUserQuota(id int, quota int) (bool, error) {
 		if err != nil {
 			common.SysLog("user quota cache reserve unavailable, falling back to database: " + err.Error())
 		}
-		return reserveUserQuotaDB(id, quota)
+		return reserveUserQuotaDB(id, quota, ref)
 	}
 	if result == cacheQuotaInsufficient {
 		return false, nil
 	}
-	if err = persistUserQuotaDelta(id, -quota); err != nil {
+	if err = persistUserQuotaDelta(id, -quota, ref); err != nil {
 		compensated, compensateErr := cacheApplyUserQuotaDelta(id, int64(quota))
 		if compensateErr != nil || compensated != cacheQuotaOK {
 			common.SysError(fmt.Sprintf("failed to compensate reserved user quota: result=%d error=%v", compensated, compensateErr))
@@ -200,13 +262,18 @@ func TryReserveUserQuota(id int, quota int) (bool, error) {
 
 // TryReserveTokenQuota atomically checks and deducts a token quota. Unlimited
 // tokens skip the balance check but still update remain/used accounting.
-func TryReserveTokenQuota(id int, key string, quota int, unlimited bool) (bool, error) {
+// Budgeted tokens additionally check the current period budget in the same
+// database update, regardless of unlimited.
+func TryReserveTokenQuota(id int, key string, quota int, unlimited bool, budgeted bool) (bool, error) {
 	if quota < 0 {
 		return false, errors.New("quota 不能为负数！")
 	}
 	if quota == 0 {
 		return true, nil
 	}
+	if budgeted {
+		return tryReserveBudgetedTokenQuota(id, key, quota, unlimited)
+	}
 	if unlimited {
 		return true, DecreaseTokenQuota(id, key, quota)
 	}
@@ -175,6 +175,9 @@ func Redeem(key string, userId int) (quota int, err error) {
 		if result.RowsAffected == 0 {
 			return errors.New("该兑换码已被使用")
 		}
+		if err := PostCreditGrant(tx, userId, CreditSourceRedemption, fmt.Sprintf("redemption:%d", redemption.Id), redemption.Quota); err != nil {
+			return err
+		}
 		return tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", redemption.Quota)).Error
 	})
 	if err != nil {
@@ -0,0 +1,536 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Reseller status
+const (
+	ResellerStatusEnabled  = 1
+	ResellerStatusDisabled = 2
+)
+
+// ResellerMaxDepth limits how many resellers may be stacked above one user.
+const ResellerMaxDepth = 3
+
+// resellerCacheTTL bounds how long a node keeps using a reseller's markup and
+// status after they were changed on another node.
+const resellerCacheTTL = 60 * time.Second
+
+var (
+	ErrResellerNotFound      = errors.New("reseller not found")
+	ErrResellerExists        = errors.New("user is already a reseller")
+	ErrResellerDisabled      = errors.New("reseller is disabled")
+	ErrResellerUserLimit     = errors.New("reseller user limit reached")
+	ErrResellerHasUsers      = errors.New("reseller still has downstream users")
+	ErrResellerUserNotFound  = errors.New("user does not belong to this reseller")
+	ErrResellerInvalidMarkup = errors.New("reseller markup must be at least 1")
+	ErrResellerTooDeep       = errors.New("reseller nesting is too deep")
+)
+
+// Reseller marks a user as a sub-distributor. The reseller creates and manages
+// downstream users (User.ResellerId) whose prices are the parent price times
+// Markup. Downstream consumption is charged to the downstream user's balance at
+// the marked-up price and to the reseller's own wallet at the parent price, so
+// the reseller keeps the difference. A reseller may itself be the downstream
+// user of another reseller, in which case the markups multiply.
+type Reseller struct {
+	UserId      int     `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	Markup      float64 `json:"markup" gorm:"default:1"`
+	MaxUsers    int     `json:"max_users" gorm:"default:0"` // 0 means unlimited
+	Status      int     `json:"status" gorm:"default:1"`
+	CreatedTime int64   `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64   `json:"updated_time" gorm:"bigint"`
+	Username    string  `json:"username" gorm:"-:all"`
+	ParentId    int     `json:"parent_id" gorm:"-:all"`
+	UserCount   int64   `json:"user_count" gorm:"-:all"`
+}
+
+// ResellerUsage aggregates, per UTC day, what a reseller's downstream user
+// was charged (RetailQuota) and what the reseller paid for it (WholesaleQuota).
+// For nested resellers RetailQuota is what the reseller one level below paid.
+type ResellerUsage struct {
+	Id             int   `json:"id"`
+	ResellerId     int   `json:"reseller_id" gorm:"uniqueIndex:idx_reseller_usage,priority:1"`
+	UserId         int   `json:"user_id" gorm:"uniqueIndex:idx_reseller_usage,priority:2"`
+	Day            int64 `json:"day" gorm:"bigint;uniqueIndex:idx_reseller_usage,priority:3"`
+	RetailQuota    int64 `json:"retail_quota" gorm:"bigint;default:0"`
+	WholesaleQuota int64 `json:"wholesale_quota" gorm:"bigint;default:0"`
+}
+
+// ResellerLevel is one reseller above a downstream user. The reseller pays
+// the user's retail charge divided by Divisor, the product of the markups from
+// this reseller down to the user.
+type ResellerLevel struct {
+	ResellerId int
+	Divisor    float64
+	Disabled   bool
+}
+
+// ResellerUsageItem is one downstream user's line in a reseller report.
+type ResellerUsageItem struct {
+	UserId         int    `json:"user_id"`
+	Username       string `json:"username"`
+	RetailQuota    int64  `json:"retail_quota"`
+	WholesaleQuota int64  `json:"wholesale_quota"`
+	Margin         int64  `json:"margin"`
+}
+
+// ResellerUsageReport summarizes a reseller's downstream usage over a period.
+type ResellerUsageReport struct {
+	ResellerId     int                 `json:"reseller_id"`
+	StartTime      int64               `json:"start_time"`
+	EndTime        int64               `json:"end_time"`
+	RetailQuota    int64               `json:"retail_quota"`
+	WholesaleQuota int64               `json:"wholesale_quota"`
+	Margin         int64               `json:"margin"`
+	Items          []ResellerUsageItem `json:"items"`
+}
+
+type resellerCacheEntry struct {
+	reseller Reseller
+	parentId int
+	expireAt time.Time
+}
+
+var (
+	resellerCacheMu sync.RWMutex
+	resellerCache   = map[int]resellerCacheEntry{}
+)
+
+func invalidateResellerCache(userId int) {
+	resellerCacheMu.Lock()
+	delete(resellerCache, userId)
+	resellerCacheMu.Unlock()
+}
+
+// getCachedReseller returns the reseller row together with the reseller's own
+// parent reseller, served from a short-lived in-process cache.
+func getCachedReseller(userId int) (*Reseller, int, error) {
+	resellerCacheMu.RLock()
+	entry, ok := resellerCache[userId]
+	resellerCacheMu.RUnlock()
+	if ok && time.Now().Before(entry.expireAt) {
+		reseller := entry.reseller
+		return &reseller, entry.parentId, nil
+	}
+	reseller, err := GetResellerByUserId(userId)
+	if err != nil {
+		return nil, 0, err
+	}
+	var parentId int
+	if err := DB.Model(&User{}).Where("id = ?", userId).Select("reseller_id").Scan(&parentId).Error; err != nil {
+		return nil, 0, err
+	}
+	resellerCacheMu.Lock()
+	resellerCache[userId] = resellerCacheEntry{
+		reseller: *reseller,
+		parentId: parentId,
+		expireAt: time.Now().Add(resellerCacheTTL),
+	}
+	resellerCacheMu.Unlock()
+	return reseller, parentId, nil
+}
+
+// GetResellerChain returns the resellers above a downstream user of
+// resellerId, nearest first, and the total markup applied to the user's prices.
+// Disabled resellers are included and flagged so that new requests can be
+// refused while requests already served are still settled.
+func GetResellerChain(resellerId int) ([]ResellerLevel, float64, error) {
+	levels := make([]ResellerLevel, 0, 1)
+	divisor := 1.0
+	for id := resellerId; id > 0; {
+		if len(levels) >= ResellerMaxDepth {
+			return nil, 0, ErrResellerTooDeep
+		}
+		reseller, parentId, err := getCachedReseller(id)
+		if err != nil {
+			return nil, 0, err
+		}
+		divisor *= reseller.Markup
+		levels = append(levels, ResellerLevel{
+			ResellerId: id,
+			Divisor:    divisor,
+			Disabled:   reseller.Status != ResellerStatusEnabled,
+		})
+		id = parentId
+	}
+	return levels, divisor, nil
+}
+
+// GetResellerMarkup returns the total markup applied to the prices of a
+// downstream user of resellerId, or 1 when resellerId is 0.
+func GetResellerMarkup(resellerId int) (float64, error) {
+	if resellerId <= 0 {
+		return 1, nil
+	}
+	_, markup, err := GetResellerChain(resellerId)
+	return markup, err
+}
+
+func validateResellerMarkup(markup float64) error {
+	if markup < 1 {
+		return ErrResellerInvalidMarkup
+	}
+	return nil
+}
+
+func GetResellerByUserId(userId int) (*Reseller, error) {
+	var reseller Reseller
+	if err := DB.First(&reseller, "user_id = ?", userId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrResellerNotFound
+		}
+		return nil, err
+	}
+	return &reseller, nil
+}
+
+// CreateReseller turns an existing user into a reseller. When the user is
+// itself a downstream user, the new reseller is nested under its reseller.
+func CreateReseller(userId int, markup float64, maxUsers int) (*Reseller, error) {
+	if err := validateResellerMarkup(markup); err != nil {
+		return nil, err
+	}
+	user, err := GetUserById(userId, false)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := GetResellerByUserId(userId); err == nil {
+		return nil, ErrResellerExists
+	} else if !errors.Is(err, ErrResellerNotFound) {
+		return nil, err
+	}
+	if user.ResellerId > 0 {
+		levels, _, err := GetResellerChain(user.ResellerId)
+		if err != nil {
+			return nil, err
+		}
+		if len(levels) >= ResellerMaxDepth {
+			return nil, ErrResellerTooDeep
+		}
+	}
+	now := common.GetTimestamp()
+	reseller := &Reseller{
+		UserId:      userId,
+		Markup:      markup,
+		MaxUsers:    maxUsers,
+		Status:      ResellerStatusEnabled,
+		CreatedTime: now,
+		UpdatedTime: now,
+	}
+	if err := DB.Create(reseller).Error; err != nil {
+		return nil, err
+	}
+	invalidateResellerCache(userId)
+	return reseller, nil
+}
+
+func UpdateReseller(userId int, markup float64, maxUsers int, status int) error {
+	if err := validateResellerMarkup(markup); err != nil {
+		return err
+	}
+	updates := map[string]interface{}{
+		"markup":       markup,
+		"max_users":    maxUsers,
+		"updated_time": common.GetTimestamp(),
+	}
+	if status == ResellerStatusEnabled || status == ResellerStatusDisabled {
+		updates["status"] = status
+	}
+	result := DB.Model(&Reseller{}).Where("user_id = ?", userId).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrResellerNotFound
+	}
+	invalidateResellerCache(userId)
+	return nil
+}
+
+// DeleteReseller removes reseller status. Resellers that still own
+// downstream users must move or delete them first.
+func DeleteReseller(userId int) error {
+	var count int64
+	if err := DB.Model(&User{}).Where("reseller_id = ?", userId).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrResellerHasUsers
+	}
+	result := DB.Delete(&Reseller{}, "user_id = ?", userId)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrResellerNotFound
+	}
+	invalidateResellerCache(userId)
+	return nil
+}
+
+func GetAllResellers(keyword string, startIdx int, num int) (resellers []*Reseller, total int64, err error) {
+	tx := DB.Model(&Reseller{})
+	if keyword != "" {
+		tx = tx.Where("user_id IN (?)", DB.Model(&User{}).Select("id").Where("username LIKE ?", "%"+keyword+"%"))
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err = tx.Order("user_id desc").Limit(num).Offset(startIdx).Find(&resellers).Error; err != nil {
+		return nil, 0, err
+	}
+	if err = fillResellerDetails(resellers); err != nil {
+		return nil, 0, err
+	}
+	return resellers, total, nil
+}
+
+// GetResellerDetail returns a reseller with its username, parent and number
+// of downstream users filled in.
+func GetResellerDetail(userId int) (*Reseller, error) {
+	reseller, err := GetResellerByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	if err := fillResellerDetails([]*Reseller{reseller}); err != nil {
+		return nil, err
+	}
+	return reseller, nil
+}
+
+func fillResellerDetails(resellers []*Reseller) error {
+	if len(resellers) == 0 {
+		return nil
+	}
+	userIds := make([]int, 0, len(resellers))
+	for _, reseller := range resellers {
+		userIds = append(userIds, reseller.UserId)
+	}
+	var users []User
+	if err := DB.Select("id", "username", "reseller_id").Where("id IN ?", userIds).Find(&users).Error; err != nil {
+		return err
+	}
+	userById := make(map[int]User, len(users))
+	for _, user := range users {
+		userById[user.Id] = user
+	}
+	var counts []struct {
+		ResellerId int
+		Count      int64
+	}
+	if err := DB.Model(&User{}).Select("reseller_id, count(*) as count").
+		Where("reseller_id IN ?", userIds).Group("reseller_id").Scan(&counts).Error; err != nil {
+		return err
+	}
+	countById := make(map[int]int64, len(counts))
+	for _, count := range counts {
+		countById[count.ResellerId] = count.Count
+	}
+	for _, reseller := range resellers {
+		reseller.Username = userById[reseller.UserId].Username
+		reseller.ParentId = userById[reseller.UserId].ResellerId
+		reseller.UserCount = countById[reseller.UserId]
+	}
+	return nil
+}
+
+// CreateResellerUser registers a downstream user for an enabled reseller. The
+// user starts with an empty balance in the reseller's group; the reseller funds
+// it through AdjustResellerUserQuota.
+func CreateResellerUser(resellerId int, username string, password string, displayName string) (*User, error) {
+	reseller, err := GetResellerByUserId(resellerId)
+	if err != nil {
+		return nil, err
+	}
+	if reseller.Status != ResellerStatusEnabled {
+		return nil, ErrResellerDisabled
+	}
+	owner, err := GetUserById(resellerId, false)
+	if err != nil {
+		return nil, err
+	}
+	username = strings.TrimSpace(username)
+	if displayName == "" {
+		displayName = username
+	}
+	user := &User{
+		Username:    username,
+		Password:    password,
+		DisplayName: displayName,
+		Role:        common.RoleCommonUser,
+		Group:       owner.Group,
+		ResellerId:  resellerId,
+	}
+	user.SetSetting(dto.UserSetting{SidebarModules: generateDefaultSidebarConfigForRole(common.RoleCommonUser)})
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if reseller.MaxUsers > 0 {
+			var count int64
+			if err := tx.Model(&User{}).Where("reseller_id = ?", resellerId).Count(&count).Error; err != nil {
+				return err
+			}
+			if count >= int64(reseller.MaxUsers) {
+				return ErrResellerUserLimit
+			}
+		}
+		if err := user.InsertWithTx(tx, 0); err != nil {
+			return err
+		}
+		// Downstream balances are funded by the reseller, not by the sign-up bonus.
+		user.Quota = 0
+		return tx.Model(&User{}).Where("id = ?", user.Id).Update("quota", 0).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func resellerUserQuery(resellerId int) *gorm.DB {
+	return DB.Model(&User{}).Where("reseller_id = ?", resellerId)
+}
+
+func GetResellerUsers(resellerId int, keyword string, startIdx int, num int) (users []*User, total int64, err error) {
+	tx := resellerUserQuery(resellerId)
+	if keyword != "" {
+		tx = tx.Where("username LIKE ? OR display_name LIKE ?", "%"+keyword+"%", "%"+keyword+"%")
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Omit("password", "access_token").Find(&users).Error
+	return users, total, err
+}
+
+// GetResellerUser returns a downstream user, failing when the user is not
+// managed by resellerId.
+func GetResellerUser(resellerId int, userId int) (*User, error) {
+	var user User
+	if err := resellerUserQuery(resellerId).Where("id = ?", userId).Omit("password", "access_token").First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrResellerUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// AdjustResellerUserQuota credits (delta > 0) or debits (delta < 0) the
+// balance of a downstream user. The reseller's own wallet is not touched; it
+// is charged only when the downstream user consumes.
+func AdjustResellerUserQuota(resellerId int, userId int, delta int) error {
+	if _, err := GetResellerUser(resellerId, userId); err != nil {
+		return err
+	}
+	reference := fmt.Sprintf("reseller:%d", resellerId)
+	if delta > 0 {
+		return GrantUserQuota(userId, delta, CreditSourceReseller, reference)
+	}
+	if delta < 0 {
+		reserved, err := TryReserveUserQuota(userId, -delta, CreditRef{Source: CreditSourceReseller, Reference: reference})
+		if err != nil {
+			return err
+		}
+		if !reserved {
+			return errors.New("user quota insufficient")
+		}
+	}
+	return nil
+}
+
+// RecordResellerUsage adds a charge (or, with negative values, a refund) to
+// the reseller's daily usage for one downstream user. Usage is reporting only;
+// a failure here never affects billing.
+func RecordResellerUsage(resellerId int, userId int, retail int, wholesale int) error {
+	if retail == 0 && wholesale == 0 {
+		return nil
+	}
+	now := time.Now().Unix()
+	usage := &ResellerUsage{
+		ResellerId:     resellerId,
+		UserId:         userId,
+		Day:            now - now%86400,
+		RetailQuota:    int64(retail),
+		WholesaleQuota: int64(wholesale),
+	}
+	return DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{
+			{Name: "reseller_id"},
+			{Name: "user_id"},
+			{Name: "day"},
+		},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"retail_quota":    gorm.Expr("reseller_usages.retail_quota + ?", usage.RetailQuota),
+			"wholesale_quota": gorm.Expr("reseller_usages.wholesale_quota + ?", usage.WholesaleQuota),
+		}),
+	}).Create(usage).Error
+}
+
+// GetResellerUsageReport sums a reseller's usage per downstream user for the
+// UTC days touching [startTime, endTime].
+func GetResellerUsageReport(resellerId int, startTime int64, endTime int64) (*ResellerUsageReport, error) {
+	var rows []struct {
+		UserId         int
+		RetailQuota    int64
+		WholesaleQuota int64
+	}
+	if err := DB.Model(&ResellerUsage{}).
+		Select("user_id, sum(retail_quota) as retail_quota, sum(wholesale_quota) as wholesale_quota").
+		Where("reseller_id = ? AND day >= ? AND day <= ?", resellerId, startTime-startTime%86400, endTime).
+		Group("user_id").Order("user_id asc").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	report := &ResellerUsageReport{
+		ResellerId: resellerId,
+		StartTime:  startTime,
+		EndTime:    endTime,
+		Items:      make([]ResellerUsageItem, 0, len(rows)),
+	}
+	if len(rows) == 0 {
+		return report, nil
+	}
+	userIds := make([]int, 0, len(rows))
+	for _, row := range rows {
+		userIds = append(userIds, row.UserId)
+	}
+	var users []User
+	if err := DB.Unscoped().Select("id", "username").Where("id IN ?", userIds).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	usernames := make(map[int]string, len(users))
+	for _, user := range users {
+		usernames[user.Id] = user.Username
+	}
+	for _, row := range rows {
+		report.Items = append(report.Items, ResellerUsageItem{
+			UserId:         row.UserId,
+			Username:       usernames[row.UserId],
+			RetailQuota:    row.RetailQuota,
+			WholesaleQuota: row.WholesaleQuota,
+			Margin:         row.RetailQuota - row.WholesaleQuota,
+		})
+		report.RetailQuota += row.RetailQuota
+		report.WholesaleQuota += row.WholesaleQuota
+	}
+	report.Margin = report.RetailQuota - report.WholesaleQuota
+	return report, nil
+}
+
+// GetResellerUserIds lists the ids of a reseller's downstream users, used to
+// scope log queries to the reseller.
+func GetResellerUserIds(resellerId int) ([]int, error) {
+	var ids []int
+	err := resellerUserQuery(resellerId).Pluck("id", &ids).Error
+	return ids, err
+}
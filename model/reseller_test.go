@@ -0,0 +1,115 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetResellerTables(t *testing.T) {
+	t.Helper()
+	truncateTables(t)
+	t.Cleanup(func() {
+		resellerCacheMu.Lock()
+		resellerCache = map[int]resellerCacheEntry{}
+		resellerCacheMu.Unlock()
+	})
+}
+
+func TestCreateResellerUser_StartsEmptyAndEnforcesLimit(t *testing.T) {
+	resetResellerTables(t)
+	originalQuota := common.QuotaForNewUser
+	common.QuotaForNewUser = 500
+	t.Cleanup(func() { common.QuotaForNewUser = originalQuota })
+
+	owner := createReserveTestUser(t, 1000)
+	_, err := CreateReseller(owner.Id, 1.5, 1)
+	require.NoError(t, err)
+
+	user, err := CreateResellerUser(owner.Id, "downstream-"+common.GetRandomString(4), "password123", "")
+	require.NoError(t, err)
+
+	stored, err := GetUserById(user.Id, false)
+	require.NoError(t, err)
+	assert.Equal(t, owner.Id, stored.ResellerId)
+	assert.Equal(t, owner.Group, stored.Group)
+	assert.Zero(t, stored.Quota)
+
+	_, err = CreateResellerUser(owner.Id, "downstream-"+common.GetRandomString(4), "password123", "")
+	assert.ErrorIs(t, err, ErrResellerUserLimit)
+
+	assert.ErrorIs(t, DeleteReseller(owner.Id), ErrResellerHasUsers)
+}
+
+func TestGetResellerChain_MultipliesNestedMarkups(t *testing.T) {
+	resetResellerTables(t)
+	top := createReserveTestUser(t, 0)
+	_, err := CreateReseller(top.Id, 1.5, 0)
+	require.NoError(t, err)
+	sub, err := CreateResellerUser(top.Id, "sub-"+common.GetRandomString(4), "password123", "")
+	require.NoError(t, err)
+	_, err = CreateReseller(sub.Id, 2, 0)
+	require.NoError(t, err)
+
+	levels, markup, err := GetResellerChain(sub.Id)
+	require.NoError(t, err)
+	assert.InDelta(t, 3.0, markup, 1e-9)
+	require.Len(t, levels, 2)
+	assert.Equal(t, sub.Id, levels[0].ResellerId)
+	assert.InDelta(t, 2.0, levels[0].Divisor, 1e-9)
+	assert.Equal(t, top.Id, levels[1].ResellerId)
+	assert.InDelta(t, 3.0, levels[1].Divisor, 1e-9)
+
+	require.NoError(t, UpdateReseller(top.Id, 1.5, 0, ResellerStatusDisabled))
+	levels, _, err = GetResellerChain(sub.Id)
+	require.NoError(t, err)
+	assert.False(t, levels[0].Disabled)
+	assert.True(t, levels[1].Disabled)
+}
+
+func TestAdjustResellerUserQuota_OnlyOwnUsers(t *testing.T) {
+	resetResellerTables(t)
+	owner := createReserveTestUser(t, 1000)
+	other := createReserveTestUser(t, 0)
+	_, err := CreateReseller(owner.Id, 1.2, 0)
+	require.NoError(t, err)
+	user, err := CreateResellerUser(owner.Id, "downstream-"+common.GetRandomString(4), "password123", "")
+	require.NoError(t, err)
+
+	require.NoError(t, AdjustResellerUserQuota(owner.Id, user.Id, 300))
+	require.NoError(t, AdjustResellerUserQuota(owner.Id, user.Id, -100))
+	assert.Error(t, AdjustResellerUserQuota(owner.Id, user.Id, -500))
+	assert.ErrorIs(t, AdjustResellerUserQuota(owner.Id, other.Id, 100), ErrResellerUserNotFound)
+
+	stored, err := GetUserById(user.Id, false)
+	require.NoError(t, err)
+	assert.Equal(t, 200, stored.Quota)
+	ownerStored, err := GetUserById(owner.Id, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, ownerStored.Quota)
+}
+
+func TestGetResellerUsageReport_AggregatesPerUser(t *testing.T) {
+	resetResellerTables(t)
+	owner := createReserveTestUser(t, 0)
+	_, err := CreateReseller(owner.Id, 1.25, 0)
+	require.NoError(t, err)
+	user, err := CreateResellerUser(owner.Id, "downstream-"+common.GetRandomString(4), "password123", "")
+	require.NoError(t, err)
+
+	require.NoError(t, RecordResellerUsage(owner.Id, user.Id, 500, 400))
+	require.NoError(t, RecordResellerUsage(owner.Id, user.Id, 250, 200))
+	require.NoError(t, RecordResellerUsage(owner.Id, user.Id, -125, -100))
+
+	now := time.Now().Unix()
+	report, err := GetResellerUsageReport(owner.Id, now-3600, now)
+	require.NoError(t, err)
+	require.Len(t, report.Items, 1)
+	assert.Equal(t, user.Username, report.Items[0].Username)
+	assert.Equal(t, int64(625), report.RetailQuota)
+	assert.Equal(t, int64(500), report.WholesaleQuota)
+	assert.Equal(t, int64(125), report.Margin)
+}
@@ -0,0 +1,268 @@
+package model
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrScimUserNotFound  = errors.New("scim user not found")
+	ErrScimUserNameTaken = errors.New("scim userName is already provisioned")
+)
+
+// ScimIdentity marks a user as provisioned by the identity provider through
+// SCIM and keeps the IdP-side identifiers, which do not have to fit the local
+// username rules (IdPs commonly use email addresses as userName).
+type ScimIdentity struct {
+	Id          int    `json:"id"`
+	UserId      int    `json:"user_id" gorm:"uniqueIndex"`
+	UserName    string `json:"user_name" gorm:"type:varchar(255);uniqueIndex"`
+	ExternalId  string `json:"external_id" gorm:"type:varchar(255);index"`
+	GivenName   string `json:"given_name" gorm:"type:varchar(128)"`
+	FamilyName  string `json:"family_name" gorm:"type:varchar(128)"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// ScimUser is a local user together with its SCIM identity. Identity is nil
+// for users that exist locally but were never written through SCIM.
+type ScimUser struct {
+	User     User
+	Identity *ScimIdentity
+}
+
+func GetScimUser(userId int) (*ScimUser, error) {
+	var user User
+	if err := DB.First(&user, "id = ?", userId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrScimUserNotFound
+		}
+		return nil, err
+	}
+	result := &ScimUser{User: user}
+	var identity ScimIdentity
+	err := DB.Where("user_id = ?", userId).First(&identity).Error
+	if err == nil {
+		result.Identity = &identity
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindScimUsers lists SCIM-provisioned users. attribute/value implement an
+// equality filter on userName, externalId or emails. Users created locally are
+// never returned: SCIM only manages accounts it provisioned itself.
+func FindScimUsers(attribute string, value string, offset int, limit int) ([]*ScimUser, int64, error) {
+	var identities []ScimIdentity
+	var total int64
+	switch attribute {
+	case "":
+		tx := DB.Model(&ScimIdentity{})
+		if err := tx.Count(&total).Error; err != nil {
+			return nil, 0, err
+		}
+		if err := tx.Order("id asc").Limit(limit).Offset(offset).Find(&identities).Error; err != nil {
+			return nil, 0, err
+		}
+		return loadScimUsers(identities, total)
+	case "username":
+		if err := DB.Where("user_name = ?", value).Find(&identities).Error; err != nil {
+			return nil, 0, err
+		}
+	case "externalid":
+		if err := DB.Where("external_id = ?", value).Find(&identities).Error; err != nil {
+			return nil, 0, err
+		}
+	case "emails", "emails.value":
+		var users []User
+		if err := DB.Where("email = ?", NormalizeEmail(value)).Find(&users).Error; err != nil {
+			return nil, 0, err
+		}
+		result, err := attachScimIdentities(users)
+		provisioned := result[:0]
+		for _, user := range result {
+			if user.Identity != nil {
+				provisioned = append(provisioned, user)
+			}
+		}
+		return pageScimUsers(provisioned, int64(len(provisioned)), offset, limit), int64(len(provisioned)), err
+	default:
+		return nil, 0, errors.New("unsupported filter attribute: " + attribute)
+	}
+	users, total, err := loadScimUsers(identities, int64(len(identities)))
+	return pageScimUsers(users, total, offset, limit), total, err
+}
+
+func pageScimUsers(users []*ScimUser, total int64, offset int, limit int) []*ScimUser {
+	if offset >= len(users) {
+		return []*ScimUser{}
+	}
+	end := offset + limit
+	if end > len(users) {
+		end = len(users)
+	}
+	return users[offset:end]
+}
+
+func loadScimUsers(identities []ScimIdentity, total int64) ([]*ScimUser, int64, error) {
+	if len(identities) == 0 {
+		return []*ScimUser{}, total, nil
+	}
+	userIds := make([]int, 0, len(identities))
+	for _, identity := range identities {
+		userIds = append(userIds, identity.UserId)
+	}
+	var users []User
+	if err := DB.Where("id IN ?", userIds).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	userById := make(map[int]User, len(users))
+	for _, user := range users {
+		userById[user.Id] = user
+	}
+	result := make([]*ScimUser, 0, len(identities))
+	for i := range identities {
+		user, ok := userById[identities[i].UserId]
+		if !ok {
+			continue
+		}
+		result = append(result, &ScimUser{User: user, Identity: &identities[i]})
+	}
+	return result, total, nil
+}
+
+func attachScimIdentities(users []User) ([]*ScimUser, error) {
+	result := make([]*ScimUser, 0, len(users))
+	if len(users) == 0 {
+		return result, nil
+	}
+	userIds := make([]int, 0, len(users))
+	for _, user := range users {
+		userIds = append(userIds, user.Id)
+	}
+	var identities []ScimIdentity
+	if err := DB.Where("user_id IN ?", userIds).Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	identityByUser := make(map[int]*ScimIdentity, len(identities))
+	for i := range identities {
+		identityByUser[identities[i].UserId] = &identities[i]
+	}
+	for _, user := range users {
+		result = append(result, &ScimUser{User: user, Identity: identityByUser[user.Id]})
+	}
+	return result, nil
+}
+
+// scimLocalUsername picks the local username for a provisioned user: the SCIM
+// userName when it fits the local rules and is free, otherwise a generated one.
+func scimLocalUsername(userName string) string {
+	if userName != "" && len(userName) <= UserNameMaxLength {
+		if exists, err := CheckUserExistOrDeleted(userName, ""); err == nil && !exists {
+			return userName
+		}
+	}
+	// A random suffix instead of the next user id, which concurrent
+	// provisioning requests would both pick.
+	for i := 0; i < 5; i++ {
+		candidate := "scim_" + strings.ToLower(common.GetRandomString(12))
+		if exists, err := CheckUserExistOrDeleted(candidate, ""); err == nil && !exists {
+			return candidate
+		}
+	}
+	return "scim_" + strings.ToLower(common.GetRandomString(15))
+}
+
+// CreateScimUser creates a local user for a SCIM userName and links it to
+// the identity. The user has no password and signs in through SSO.
+func CreateScimUser(identity ScimIdentity, user *User) error {
+	identity.UserName = strings.TrimSpace(identity.UserName)
+	if identity.UserName == "" {
+		return errors.New("userName is required")
+	}
+	var count int64
+	if err := DB.Model(&ScimIdentity{}).Where("user_name = ?", identity.UserName).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrScimUserNameTaken
+	}
+	user.Username = scimLocalUsername(identity.UserName)
+	if user.DisplayName == "" {
+		user.DisplayName = identity.UserName
+	}
+	if runes := []rune(user.DisplayName); len(runes) > 20 {
+		user.DisplayName = string(runes[:20])
+	}
+	user.Role = common.RoleCommonUser
+	now := common.GetTimestamp()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := user.InsertWithTx(tx, 0); err != nil {
+			return err
+		}
+		identity.UserId = user.Id
+		identity.CreatedTime = now
+		identity.UpdatedTime = now
+		return tx.Create(&identity).Error
+	})
+	if err != nil {
+		return err
+	}
+	user.FinalizeOAuthUserCreation(0)
+	return nil
+}
+
+// SaveScimIdentity creates or updates the SCIM identity of a user.
+func SaveScimIdentity(identity ScimIdentity) error {
+	identity.UserName = strings.TrimSpace(identity.UserName)
+	if identity.UserName == "" {
+		return errors.New("userName is required")
+	}
+	var conflict int64
+	if err := DB.Model(&ScimIdentity{}).
+		Where("user_name = ? AND user_id <> ?", identity.UserName, identity.UserId).
+		Count(&conflict).Error; err != nil {
+		return err
+	}
+	if conflict > 0 {
+		return ErrScimUserNameTaken
+	}
+	now := common.GetTimestamp()
+	var existing ScimIdentity
+	err := DB.Where("user_id = ?", identity.UserId).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		identity.CreatedTime = now
+		identity.UpdatedTime = now
+		return DB.Create(&identity).Error
+	}
+	if err != nil {
+		return err
+	}
+	return DB.Model(&existing).Updates(map[string]interface{}{
+		"user_name":    identity.UserName,
+		"external_id":  identity.ExternalId,
+		"given_name":   identity.GivenName,
+		"family_name":  identity.FamilyName,
+		"updated_time": now,
+	}).Error
+}
+
+// DeleteScimIdentity removes the SCIM link of a user.
+func DeleteScimIdentity(userId int) error {
+	return DB.Where("user_id = ?", userId).Delete(&ScimIdentity{}).Error
+}
+
+// GetScimUsersByGroup returns the SCIM-provisioned users in a local user
+// group. Users created locally are never group members from the IdP's view.
+func GetScimUsersByGroup(group string) ([]User, error) {
+	var users []User
+	err := DB.Where(commonGroupCol+" = ?", group).
+		Where("id IN (?)", DB.Model(&ScimIdentity{}).Select("user_id")).
+		Order("id asc").
+		Find(&users).Error
+	return users, err
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateScimUser_LongUserNameGetsGeneratedUsername(t *testing.T) {
+	truncateTables(t)
+	user := &User{Email: "very.long.name@example.com", Status: common.UserStatusEnabled}
+
+	require.NoError(t, CreateScimUser(ScimIdentity{UserName: "very.long.name@example.com", ExternalId: "ext-1"}, user))
+
+	assert.NotEqual(t, "very.long.name@example.com", user.Username)
+	assert.LessOrEqual(t, len(user.Username), UserNameMaxLength)
+	users, total, err := FindScimUsers("username", "very.long.name@example.com", 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	assert.Equal(t, user.Id, users[0].User.Id)
+	assert.Equal(t, "ext-1", users[0].Identity.ExternalId)
+
+	err = CreateScimUser(ScimIdentity{UserName: "very.long.name@example.com"}, &User{})
+	assert.ErrorIs(t, err, ErrScimUserNameTaken)
+}
+
+func TestFindScimUsers_IgnoresUnlinkedLocalUser(t *testing.T) {
+	truncateTables(t)
+	local := createReserveTestUser(t, 0)
+	createReserveTestUser(t, 0)
+
+	_, total, err := FindScimUsers("username", local.Username, 0, 10)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	require.NoError(t, SaveScimIdentity(ScimIdentity{UserId: local.Id, UserName: "idp-name"}))
+	users, total, err := FindScimUsers("username", "idp-name", 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	assert.Equal(t, local.Id, users[0].User.Id)
+
+	members, err := GetScimUsersByGroup(local.Group)
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	assert.Equal(t, local.Id, members[0].Id)
+}
+
+func TestDisableUserTokens(t *testing.T) {
+	truncateTables(t)
+	user := createReserveTestUser(t, 0)
+	enabled := Token{UserId: user.Id, Key: "scim-" + common.GetRandomString(8), Status: common.TokenStatusEnabled, ExpiredTime: -1}
+	require.NoError(t, enabled.Insert())
+	exhausted := Token{UserId: user.Id, Key: "scim-" + common.GetRandomString(8), Status: common.TokenStatusExhausted, ExpiredTime: -1}
+	require.NoError(t, exhausted.Insert())
+
+	count, err := DisableUserTokens(user.Id)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+	assert.Equal(t, common.TokenStatusDisabled, getTokenFromDB(t, enabled.Id).Status)
+	assert.Equal(t, common.TokenStatusExhausted, getTokenFromDB(t, exhausted.Id).Status)
+}
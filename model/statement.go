@@ -0,0 +1,457 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// 月度账单：按用户（以及组织钱包）汇总一个账单周期内的充值、订阅、按模型用量、
+// 退款以及期初/期末余额，并分配连续的发票编号。账单生成后即冻结，明细以 JSON
+// 快照保存，渲染 HTML/PDF 时不再回查原始数据。
+//
+// 余额口径：用户已开启额度账本且账本覆盖整个周期时取账本余额，期初与期末之间
+// 无法归入用量/退款的差额记为“充值及其他调整”（充值、兑换码、签到、订阅抵扣、
+// 管理员调整等）。账本未覆盖整个周期的用户以及组织钱包无法还原历史时点的余额，
+// 账单不列出余额（BalanceSource 为 unavailable），只列出本期收支。
+const (
+	StatementBalanceSourceLedger      = "ledger"
+	StatementBalanceSourceUnavailable = "unavailable"
+	// 早期版本按上一期账单或本期收支推算余额，仅存在于历史账单中
+	StatementBalanceSourcePrevious = "previous_statement"
+	StatementBalanceSourceDerived  = "derived"
+)
+
+var ErrStatementExists = errors.New("statement already exists for this period")
+
+type Statement struct {
+	Id                int     `json:"id"`
+	InvoiceSeq        int64   `json:"invoice_seq" gorm:"uniqueIndex"`
+	InvoiceNo         string  `json:"invoice_no" gorm:"type:varchar(64);uniqueIndex"`
+	UserId            int     `json:"user_id" gorm:"uniqueIndex:idx_statement_owner_period,priority:1"`
+	OrgId             int     `json:"org_id" gorm:"uniqueIndex:idx_statement_owner_period,priority:2;index"`
+	PeriodStart       int64   `json:"period_start" gorm:"bigint;uniqueIndex:idx_statement_owner_period,priority:3"`
+	PeriodEnd         int64   `json:"period_end" gorm:"bigint"`
+	OpeningBalance    int64   `json:"opening_balance"`
+	TopUpMoney        float64 `json:"top_up_money"`
+	SubscriptionMoney float64 `json:"subscription_money"`
+	UsageQuota        int64   `json:"usage_quota"`
+	RefundQuota       int64   `json:"refund_quota"`
+	AdjustmentQuota   int64   `json:"adjustment_quota"`
+	ClosingBalance    int64   `json:"closing_balance"`
+	BalanceSource     string  `json:"balance_source" gorm:"type:varchar(32)"`
+	Detail            string  `json:"-" gorm:"type:text"`
+	CreatedAt         int64   `json:"created_at" gorm:"bigint;index"`
+}
+
+// StatementParty 账单上的开票方/收票方信息，生成时快照
+type StatementParty struct {
+	Name    string `json:"name"`
+	Address string `json:"address,omitempty"`
+	TaxId   string `json:"tax_id,omitempty"`
+	Email   string `json:"email,omitempty"`
+}
+
+type StatementPayment struct {
+	TradeNo       string  `json:"trade_no"`
+	PaymentMethod string  `json:"payment_method"`
+	Title         string  `json:"title,omitempty"`
+	Money         float64 `json:"money"`
+	CompleteTime  int64   `json:"complete_time"`
+}
+
+type StatementModelUsage struct {
+	ModelName        string `json:"model_name" gorm:"column:model_name"`
+	Count            int64  `json:"count" gorm:"column:count"`
+	PromptTokens     int64  `json:"prompt_tokens" gorm:"column:prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens" gorm:"column:completion_tokens"`
+	Quota            int64  `json:"quota" gorm:"column:quota"`
+}
+
+type StatementDetail struct {
+	Seller        StatementParty        `json:"seller"`
+	Customer      StatementParty        `json:"customer"`
+	Footer        string                `json:"footer,omitempty"`
+	TopUps        []StatementPayment    `json:"top_ups"`
+	Subscriptions []StatementPayment    `json:"subscriptions"`
+	Usage         []StatementModelUsage `json:"usage"`
+}
+
+func (s *Statement) GetDetail() (*StatementDetail, error) {
+	detail := &StatementDetail{}
+	if s.Detail == "" {
+		return detail, nil
+	}
+	if err := common.UnmarshalJsonStr(s.Detail, detail); err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+// IsOrganization 是否为组织钱包账单
+func (s *Statement) IsOrganization() bool {
+	return s.OrgId > 0
+}
+
+// HasBalances 账单是否列出期初/期末余额
+func (s *Statement) HasBalances() bool {
+	return s.BalanceSource != StatementBalanceSourceUnavailable
+}
+
+// StatementPeriod 返回 now 所在月份的上一个自然月（[start, end)）
+func StatementPeriod(now time.Time, loc *time.Location) (int64, int64) {
+	now = now.In(loc)
+	end := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	start := end.AddDate(0, -1, 0)
+	return start.Unix(), end.Unix()
+}
+
+// StatementOwners 周期内有账务活动的用户与组织
+type StatementOwners struct {
+	UserIds []int
+	OrgIds  []int
+}
+
+// GetStatementOwners 找出周期内有用量、退款、充值或订阅订单的用户，以及有用量的组织
+func GetStatementOwners(start int64, end int64) (*StatementOwners, error) {
+	userSet := make(map[int]struct{})
+	orgSet := make(map[int]struct{})
+
+	var logOwners []struct {
+		UserId int `gorm:"column:user_id"`
+		OrgId  int `gorm:"column:org_id"`
+	}
+	err := LOG_DB.Table("logs").Select("DISTINCT user_id, org_id").
+		Where("type IN ? AND created_at >= ? AND created_at < ?", []int{LogTypeConsume, LogTypeRefund}, start, end).
+		Find(&logOwners).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, owner := range logOwners {
+		if owner.OrgId > 0 {
+			orgSet[owner.OrgId] = struct{}{}
+		} else if owner.UserId > 0 {
+			userSet[owner.UserId] = struct{}{}
+		}
+	}
+
+	var paymentUserIds []int
+	if err := DB.Model(&TopUp{}).Distinct("user_id").
+		Where("status = ? AND complete_time >= ? AND complete_time < ?", common.TopUpStatusSuccess, start, end).
+		Pluck("user_id", &paymentUserIds).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range paymentUserIds {
+		userSet[id] = struct{}{}
+	}
+
+	owners := &StatementOwners{}
+	for id := range userSet {
+		owners.UserIds = append(owners.UserIds, id)
+	}
+	for id := range orgSet {
+		owners.OrgIds = append(owners.OrgIds, id)
+	}
+	sort.Ints(owners.UserIds)
+	sort.Ints(owners.OrgIds)
+	return owners, nil
+}
+
+func statementExists(userId int, orgId int, start int64) (bool, error) {
+	var count int64
+	err := DB.Model(&Statement{}).
+		Where("user_id = ? AND org_id = ? AND period_start = ?", userId, orgId, start).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// BuildUserStatement 汇总用户个人钱包在 [start, end) 的账单（不含组织令牌产生的用量）
+func BuildUserStatement(userId int, start int64, end int64, seller StatementParty, footer string) (*Statement, error) {
+	user, err := GetUserById(userId, false)
+	if err != nil {
+		return nil, err
+	}
+	detail := &StatementDetail{
+		Seller:   seller,
+		Customer: StatementParty{Name: user.Username, Email: user.Email},
+		Footer:   footer,
+	}
+	if detail.Customer.Name == "" {
+		detail.Customer.Name = fmt.Sprintf("user-%d", userId)
+	}
+
+	if detail.TopUps, err = getStatementTopUps(userId, start, end); err != nil {
+		return nil, err
+	}
+	if detail.Subscriptions, err = getStatementSubscriptions(userId, start, end); err != nil {
+		return nil, err
+	}
+	if detail.Usage, err = getStatementUsage("user_id = ? AND org_id = 0", userId, start, end); err != nil {
+		return nil, err
+	}
+	refund, err := sumStatementRefund("user_id = ? AND org_id = 0", userId, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &Statement{UserId: userId, PeriodStart: start, PeriodEnd: end, RefundQuota: refund}
+	for _, topUp := range detail.TopUps {
+		statement.TopUpMoney += topUp.Money
+	}
+	for _, order := range detail.Subscriptions {
+		statement.SubscriptionMoney += order.Money
+	}
+	for _, usage := range detail.Usage {
+		statement.UsageQuota += usage.Quota
+	}
+
+	opened, err := creditLedgerOpenedBefore(userId, start)
+	if err != nil {
+		return nil, err
+	}
+	if opened {
+		if statement.OpeningBalance, err = GetCreditLedgerBalance(userId, start-1); err != nil {
+			return nil, err
+		}
+		if statement.ClosingBalance, err = GetCreditLedgerBalance(userId, end-1); err != nil {
+			return nil, err
+		}
+		statement.BalanceSource = StatementBalanceSourceLedger
+		statement.AdjustmentQuota = statement.ClosingBalance - statement.OpeningBalance + statement.UsageQuota - statement.RefundQuota
+	} else {
+		statement.BalanceSource = StatementBalanceSourceUnavailable
+	}
+	return statement, statement.setDetail(detail)
+}
+
+// BuildOrganizationStatement 汇总组织共享钱包在 [start, end) 的账单
+func BuildOrganizationStatement(orgId int, start int64, end int64, seller StatementParty, footer string) (*Statement, error) {
+	org, err := GetOrganizationById(orgId)
+	if err != nil {
+		return nil, err
+	}
+	detail := &StatementDetail{
+		Seller:        seller,
+		Customer:      StatementParty{Name: org.Name},
+		Footer:        footer,
+		TopUps:        []StatementPayment{},
+		Subscriptions: []StatementPayment{},
+	}
+	if owner, err := GetUserById(org.OwnerId, false); err == nil {
+		detail.Customer.Email = owner.Email
+	}
+	if detail.Usage, err = getStatementUsage("org_id = ?", orgId, start, end); err != nil {
+		return nil, err
+	}
+	refund, err := sumStatementRefund("org_id = ?", orgId, start, end)
+	if err != nil {
+		return nil, err
+	}
+	statement := &Statement{OrgId: orgId, PeriodStart: start, PeriodEnd: end, RefundQuota: refund,
+		BalanceSource: StatementBalanceSourceUnavailable}
+	for _, usage := range detail.Usage {
+		statement.UsageQuota += usage.Quota
+	}
+	return statement, statement.setDetail(detail)
+}
+
+func (s *Statement) setDetail(detail *StatementDetail) error {
+	data, err := common.Marshal(detail)
+	if err != nil {
+		return err
+	}
+	s.Detail = string(data)
+	return nil
+}
+
+func creditLedgerOpenedBefore(userId int, start int64) (bool, error) {
+	var count int64
+	err := DB.Model(&CreditLedgerEntry{}).
+		Where("user_id = ? AND account = ? AND created_at < ?", userId, CreditAccountWallet, start).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func getStatementTopUps(userId int, start int64, end int64) ([]StatementPayment, error) {
+	// 订阅订单完成时也会写入同单号的 TopUp 记录，这里排除以免重复计入
+	var topUps []TopUp
+	err := DB.Where("user_id = ? AND status = ? AND complete_time >= ? AND complete_time < ?", userId, common.TopUpStatusSuccess, start, end).
+		Where("trade_no NOT IN (?)", DB.Model(&SubscriptionOrder{}).Select("trade_no").Where("user_id = ?", userId)).
+		Order("complete_time asc").
+		Find(&topUps).Error
+	if err != nil {
+		return nil, err
+	}
+	payments := make([]StatementPayment, 0, len(topUps))
+	for _, topUp := range topUps {
+		payments = append(payments, StatementPayment{
+			TradeNo:       topUp.TradeNo,
+			PaymentMethod: topUp.PaymentMethod,
+			Money:         topUp.Money,
+			CompleteTime:  topUp.CompleteTime,
+		})
+	}
+	return payments, nil
+}
+
+func getStatementSubscriptions(userId int, start int64, end int64) ([]StatementPayment, error) {
+	var orders []SubscriptionOrder
+	err := DB.Where("user_id = ? AND status = ? AND complete_time >= ? AND complete_time < ?", userId, common.TopUpStatusSuccess, start, end).
+		Order("complete_time asc").
+		Find(&orders).Error
+	if err != nil {
+		return nil, err
+	}
+	planIds := make([]int, 0, len(orders))
+	for _, order := range orders {
+		planIds = append(planIds, order.PlanId)
+	}
+	titles := make(map[int]string, len(planIds))
+	if len(planIds) > 0 {
+		var plans []SubscriptionPlan
+		if err := DB.Select("id", "title").Where("id IN ?", planIds).Find(&plans).Error; err != nil {
+			return nil, err
+		}
+		for _, plan := range plans {
+			titles[plan.Id] = plan.Title
+		}
+	}
+	payments := make([]StatementPayment, 0, len(orders))
+	for _, order := range orders {
+		payments = append(payments, StatementPayment{
+			TradeNo:       order.TradeNo,
+			PaymentMethod: order.PaymentMethod,
+			Title:         titles[order.PlanId],
+			Money:         order.Money,
+			CompleteTime:  order.CompleteTime,
+		})
+	}
+	return payments, nil
+}
+
+func getStatementUsage(ownerCond string, ownerId int, start int64, end int64) ([]StatementModelUsage, error) {
+	usage := make([]StatementModelUsage, 0)
+	err := LOG_DB.Table("logs").
+		Select("model_name, count(*) as count, COALESCE(sum(prompt_tokens), 0) as prompt_tokens, COALESCE(sum(completion_tokens), 0) as completion_tokens, COALESCE(sum(quota), 0) as quota").
+		Where(ownerCond, ownerId).
+		Where("type = ? AND created_at >= ? AND created_at < ?", LogTypeConsume, start, end).
+		Group("model_name").
+		Order("model_name asc").
+		Find(&usage).Error
+	return usage, err
+}
+
+func sumStatementRefund(ownerCond string, ownerId int, start int64, end int64) (int64, error) {
+	var refund int64
+	err := LOG_DB.Table("logs").Select("COALESCE(sum(quota), 0)").
+		Where(ownerCond, ownerId).
+		Where("type = ? AND created_at >= ? AND created_at < ?", LogTypeRefund, start, end).
+		Scan(&refund).Error
+	return refund, err
+}
+
+// CreateStatement 为账单分配下一个连续的发票编号并保存。同一周期已有账单时返回
+// ErrStatementExists；编号冲突（并发生成）时重试。
+func CreateStatement(statement *Statement, invoicePrefix string) error {
+	exists, err := statementExists(statement.UserId, statement.OrgId, statement.PeriodStart)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrStatementExists
+	}
+	if statement.CreatedAt == 0 {
+		statement.CreatedAt = common.GetTimestamp()
+	}
+	const maxAttempts = 5
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		err = DB.Transaction(func(tx *gorm.DB) error {
+			var lastSeq int64
+			if err := tx.Model(&Statement{}).Select("COALESCE(MAX(invoice_seq), 0)").Scan(&lastSeq).Error; err != nil {
+				return err
+			}
+			statement.Id = 0
+			statement.InvoiceSeq = lastSeq + 1
+			statement.InvoiceNo = FormatInvoiceNo(invoicePrefix, statement.InvoiceSeq)
+			return tx.Create(statement).Error
+		})
+		if err == nil {
+			return nil
+		}
+		exists, existsErr := statementExists(statement.UserId, statement.OrgId, statement.PeriodStart)
+		if existsErr == nil && exists {
+			return ErrStatementExists
+		}
+	}
+	return err
+}
+
+// FormatInvoiceNo 生成形如 INV-000123 的发票编号
+func FormatInvoiceNo(prefix string, seq int64) string {
+	return fmt.Sprintf("%s-%06d", strings.TrimSpace(prefix), seq)
+}
+
+// GetStatementById 获取账单
+func GetStatementById(id int) (*Statement, error) {
+	var statement Statement
+	if err := DB.First(&statement, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &statement, nil
+}
+
+// GetVisibleStatements 列出用户本人的账单，以及其担任所有者/管理员的组织的账单
+func GetVisibleStatements(userId int, startIdx int, num int) ([]*Statement, int64, error) {
+	tx := DB.Model(&Statement{}).Where("(user_id = ? AND org_id = 0) OR org_id IN (?)", userId,
+		DB.Model(&OrganizationMember{}).Select("org_id").
+			Where("user_id = ? AND role IN ?", userId, []string{OrganizationRoleOwner, OrganizationRoleAdmin}))
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var statements []*Statement
+	err := tx.Order("period_start desc, id desc").Limit(num).Offset(startIdx).Find(&statements).Error
+	return statements, total, err
+}
+
+// CanUserViewStatement 用户是否可以查看该账单
+func CanUserViewStatement(userId int, statement *Statement) (bool, error) {
+	if !statement.IsOrganization() {
+		return statement.UserId == userId, nil
+	}
+	member, err := GetOrganizationMember(statement.OrgId, userId)
+	if err != nil {
+		if errors.Is(err, ErrOrganizationMemberNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return member.Role == OrganizationRoleOwner || member.Role == OrganizationRoleAdmin, nil
+}
+
+// GetAllStatements 管理端分页列出账单，userId/orgId 为 0 时不过滤
+func GetAllStatements(userId int, orgId int, periodStart int64, startIdx int, num int) ([]*Statement, int64, error) {
+	tx := DB.Model(&Statement{})
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if orgId != 0 {
+		tx = tx.Where("org_id = ?", orgId)
+	}
+	if periodStart != 0 {
+		tx = tx.Where("period_start = ?", periodStart)
+	}
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var statements []*Statement
+	err := tx.Order("id desc").Limit(num).Offset(startIdx).Find(&statements).Error
+	return statements, total, err
+}
@@ -0,0 +1,152 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	statementTestStart = int64(1_700_000_000)
+	statementTestEnd   = statementTestStart + 30*86400
+)
+
+func insertStatementTestLog(t *testing.T, userId int, orgId int, logType int, modelName string, quota int, createdAt int64) {
+	t.Helper()
+	require.NoError(t, LOG_DB.Create(&Log{
+		UserId:           userId,
+		OrgId:            orgId,
+		Type:             logType,
+		ModelName:        modelName,
+		Quota:            quota,
+		PromptTokens:     10,
+		CompletionTokens: 5,
+		CreatedAt:        createdAt,
+	}).Error)
+}
+
+func TestBuildUserStatementSummarisesPeriod(t *testing.T) {
+	truncateTables(t)
+	user := &User{Id: 41, Username: "statement_user", Email: "u@example.com", Status: common.UserStatusEnabled, Quota: 7000}
+	require.NoError(t, DB.Create(user).Error)
+	require.NoError(t, DB.Create(&SubscriptionPlan{Id: 3, Title: "Pro", PriceAmount: 20, Currency: "USD", DurationUnit: SubscriptionDurationMonth, DurationValue: 1, Enabled: true}).Error)
+
+	in := statementTestStart + 100
+	require.NoError(t, DB.Create(&TopUp{UserId: 41, Amount: 10, Money: 10, TradeNo: "top-1", PaymentMethod: "stripe", Status: common.TopUpStatusSuccess, CompleteTime: in}).Error)
+	// 订阅订单同单号的 TopUp 不应计入充值
+	require.NoError(t, DB.Create(&TopUp{UserId: 41, Money: 20, TradeNo: "sub-1", PaymentMethod: "stripe", Status: common.TopUpStatusSuccess, CompleteTime: in}).Error)
+	require.NoError(t, DB.Create(&SubscriptionOrder{UserId: 41, PlanId: 3, Money: 20, TradeNo: "sub-1", PaymentMethod: "stripe", Status: common.TopUpStatusSuccess, CompleteTime: in}).Error)
+	require.NoError(t, DB.Create(&TopUp{UserId: 41, Money: 99, TradeNo: "top-late", Status: common.TopUpStatusSuccess, CompleteTime: statementTestEnd}).Error)
+
+	insertStatementTestLog(t, 41, 0, LogTypeConsume, "gpt-a", 300, in)
+	insertStatementTestLog(t, 41, 0, LogTypeConsume, "gpt-a", 200, in+10)
+	insertStatementTestLog(t, 41, 0, LogTypeConsume, "gpt-b", 100, in+20)
+	insertStatementTestLog(t, 41, 0, LogTypeRefund, "gpt-b", 50, in+30)
+	insertStatementTestLog(t, 41, 9, LogTypeConsume, "gpt-a", 1000, in+40)
+	insertStatementTestLog(t, 41, 0, LogTypeConsume, "gpt-a", 1000, statementTestEnd)
+
+	statement, err := BuildUserStatement(41, statementTestStart, statementTestEnd, StatementParty{Name: "Seller Ltd"}, "Thanks")
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, statement.TopUpMoney)
+	assert.Equal(t, 20.0, statement.SubscriptionMoney)
+	assert.EqualValues(t, 600, statement.UsageQuota)
+	assert.EqualValues(t, 50, statement.RefundQuota)
+	// 未开启账本时无法还原历史余额，不使用生成时的钱包余额
+	assert.Equal(t, StatementBalanceSourceUnavailable, statement.BalanceSource)
+	assert.False(t, statement.HasBalances())
+	assert.Zero(t, statement.ClosingBalance)
+	assert.Zero(t, statement.OpeningBalance)
+	assert.Zero(t, statement.AdjustmentQuota)
+
+	detail, err := statement.GetDetail()
+	require.NoError(t, err)
+	assert.Equal(t, "Seller Ltd", detail.Seller.Name)
+	assert.Equal(t, "statement_user", detail.Customer.Name)
+	require.Len(t, detail.TopUps, 1)
+	assert.Equal(t, "top-1", detail.TopUps[0].TradeNo)
+	require.Len(t, detail.Subscriptions, 1)
+	assert.Equal(t, "Pro", detail.Subscriptions[0].Title)
+	require.Len(t, detail.Usage, 2)
+	assert.Equal(t, "gpt-a", detail.Usage[0].ModelName)
+	assert.EqualValues(t, 2, detail.Usage[0].Count)
+	assert.EqualValues(t, 500, detail.Usage[0].Quota)
+}
+
+func TestBuildUserStatementTakesBalancesFromLedger(t *testing.T) {
+	truncateTables(t)
+	enableCreditLedgerForTest(t)
+	user := createReserveTestUser(t, 0)
+	require.NoError(t, GrantUserQuota(user.Id, 1000, CreditSourceTopUp, "topup:before"))
+	require.NoError(t, DB.Model(&CreditLedgerEntry{}).Where("user_id = ?", user.Id).Update("created_at", statementTestStart-10).Error)
+	require.NoError(t, GrantUserQuota(user.Id, 300, CreditSourceRedemption, "redemption:in"))
+	reserved, err := TryReserveUserQuota(user.Id, 400, CreditRef{Source: CreditSourceUsage, Reference: "req-in"})
+	require.NoError(t, err)
+	require.True(t, reserved)
+	require.NoError(t, DB.Model(&CreditLedgerEntry{}).Where("user_id = ? AND created_at > ?", user.Id, statementTestStart).
+		Update("created_at", statementTestStart+5).Error)
+	insertStatementTestLog(t, user.Id, 0, LogTypeConsume, "gpt-a", 400, statementTestStart+5)
+	// 周期结束后的额度变动不影响本期期末余额
+	require.NoError(t, GrantUserQuota(user.Id, 5000, CreditSourceTopUp, "topup:after"))
+	require.NoError(t, DB.Model(&CreditLedgerEntry{}).Where("user_id = ? AND reference = ?", user.Id, "topup:after").
+		Update("created_at", statementTestEnd+5).Error)
+
+	statement, err := BuildUserStatement(user.Id, statementTestStart, statementTestEnd, StatementParty{}, "")
+	require.NoError(t, err)
+	assert.Equal(t, StatementBalanceSourceLedger, statement.BalanceSource)
+	assert.EqualValues(t, 1000, statement.OpeningBalance)
+	assert.EqualValues(t, 900, statement.ClosingBalance)
+	// 1000 - 400 + 300 = 900：300 为期间的兑换码
+	assert.EqualValues(t, 300, statement.AdjustmentQuota)
+}
+
+func TestCreateStatementAssignsSequentialInvoiceNumbers(t *testing.T) {
+	truncateTables(t)
+	first := &Statement{UserId: 1, PeriodStart: statementTestStart, PeriodEnd: statementTestEnd}
+	second := &Statement{UserId: 2, PeriodStart: statementTestStart, PeriodEnd: statementTestEnd}
+	require.NoError(t, CreateStatement(first, "ACME"))
+	require.NoError(t, CreateStatement(second, "ACME"))
+	assert.Equal(t, "ACME-000001", first.InvoiceNo)
+	assert.Equal(t, "ACME-000002", second.InvoiceNo)
+
+	duplicate := &Statement{UserId: 1, PeriodStart: statementTestStart, PeriodEnd: statementTestEnd}
+	assert.ErrorIs(t, CreateStatement(duplicate, "ACME"), ErrStatementExists)
+}
+
+func TestStatementVisibilityIncludesManagedOrganizations(t *testing.T) {
+	truncateTables(t)
+	org, owner := createTestOrganization(t, 0)
+	member := createReserveTestUser(t, 0)
+	_, err := AddOrganizationMember(org.Id, member.Id, OrganizationRoleMember, 0)
+	require.NoError(t, err)
+
+	own := &Statement{UserId: owner.Id, PeriodStart: statementTestStart, PeriodEnd: statementTestEnd}
+	orgStatement := &Statement{OrgId: org.Id, PeriodStart: statementTestStart, PeriodEnd: statementTestEnd}
+	require.NoError(t, CreateStatement(own, "INV"))
+	require.NoError(t, CreateStatement(orgStatement, "INV"))
+
+	statements, total, err := GetVisibleStatements(owner.Id, 0, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, total)
+	assert.Len(t, statements, 2)
+
+	_, total, err = GetVisibleStatements(member.Id, 0, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, total)
+
+	allowed, err := CanUserViewStatement(member.Id, orgStatement)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	allowed, err = CanUserViewStatement(owner.Id, orgStatement)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
+
+func TestStatementPeriodIsPreviousCalendarMonth(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	start, end := StatementPeriod(time.Date(2026, 3, 15, 10, 0, 0, 0, loc), loc)
+	assert.Equal(t, time.Date(2026, 2, 1, 0, 0, 0, 0, loc).Unix(), start)
+	assert.Equal(t, time.Date(2026, 3, 1, 0, 0, 0, 0, loc).Unix(), end)
+}
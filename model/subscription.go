@@ -152,6 +152,8 @@ type SubscriptionPlan struct {
 	// Display money amount (follow existing code style: float64 for money)
 	PriceAmount float64 `json:"price_amount" gorm:"type:decimal(10,6);not null;default:0"`
 	Currency    string  `json:"currency" gorm:"type:varchar(8);not null;default:'USD'"`
+	// Per-currency prices as JSON, e.g. {"EUR":9.5,"CNY":68}; other currencies convert PriceAmount
+	CurrencyPrices string `json:"currency_prices" gorm:"type:text"`
 
 	DurationUnit  string `json:"duration_unit" gorm:"type:varchar(16);not null;default:'month'"`
 	DurationValue int    `json:"duration_value" gorm:"type:int;not null;default:1"`
@@ -201,6 +203,25 @@ func (p *SubscriptionPlan) BeforeUpdate(tx *gorm.DB) error {
 	return nil
 }
 
+// GetCurrencyPrices 解析套餐的币种价格表，格式错误时视为未配置
+func (p *SubscriptionPlan) GetCurrencyPrices() map[string]float64 {
+	prices := map[string]float64{}
+	if strings.TrimSpace(p.CurrencyPrices) == "" {
+		return prices
+	}
+	raw := map[string]float64{}
+	if err := common.UnmarshalJsonStr(p.CurrencyPrices, &raw); err != nil {
+		return prices
+	}
+	for code, price := range raw {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code != "" && price >= 0 {
+			prices[code] = price
+		}
+	}
+	return prices
+}
+
 func (p *SubscriptionPlan) NormalizeDefaults() {
 	if p.AllowBalancePay == nil {
 		p.AllowBalancePay = common.GetPointer(true)
@@ -225,6 +246,10 @@ type SubscriptionOrder struct {
 	CompleteTime    int64  `json:"complete_time"`
 
 	ProviderPayload string `json:"provider_payload" gorm:"type:text"`
+
+	// Money 的币种与下单时的汇率（1 USD = ExchangeRate Currency），历史订单为空
+	Currency     string  `json:"currency" gorm:"type:varchar(8);default:''"`
+	ExchangeRate float64 `json:"exchange_rate" gorm:"default:0"`
 }
 
 func (o *SubscriptionOrder) Insert() error {
@@ -790,6 +815,9 @@ func PurchaseSubscriptionWithBalance(userId int, planId int) error {
 			return errors.New("余额不足")
 		}
 		if requiredQuota > 0 {
+			if err := PostCreditDebit(tx, userId, CreditSourceSubscription, fmt.Sprintf("subscription_plan:%d", plan.Id), requiredQuota); err != nil {
+				return err
+			}
 			if err := tx.Model(&User{}).Where("id = ?", userId).
 				Update("quota", gorm.Expr("quota - ?", requiredQuota)).Error; err != nil {
 				return err
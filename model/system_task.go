@@ -16,12 +16,17 @@ const (
 	SystemTaskStatusSucceeded SystemTaskStatus = "succeeded"
 	SystemTaskStatusFailed    SystemTaskStatus = "failed"
 
-	SystemTaskTypeLogCleanup     = "log_cleanup"
-	SystemTaskTypeChannelTest    = "channel_test"
-	SystemTaskTypeHealthProbe    = "channel_health_probe"
-	SystemTaskTypeModelUpdate    = "model_update"
-	SystemTaskTypeMidjourneyPoll = "midjourney_poll"
-	SystemTaskTypeAsyncTaskPoll  = "async_task_poll"
+	SystemTaskTypeLogCleanup        = "log_cleanup"
+	SystemTaskTypeChannelTest       = "channel_test"
+	SystemTaskTypeHealthProbe       = "channel_health_probe"
+	SystemTaskTypeModelUpdate       = "model_update"
+	SystemTaskTypeMidjourneyPoll    = "midjourney_poll"
+	SystemTaskTypeAsyncTaskPoll     = "async_task_poll"
+	SystemTaskTypeStatement         = "monthly_statement"
+	SystemTaskTypePostpaidDunning   = "postpaid_dunning"
+	SystemTaskTypePriceBook         = "price_book_apply"
+	SystemTaskTypeBillingSim        = "billing_simulation"
+	SystemTaskTypeMidjourneyRefetch = "midjourney_refetch"
 )
 
 var ErrSystemTaskLockLost = errors.New("system task lock lost")
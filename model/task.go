@@ -105,11 +105,17 @@ type TaskPrivateData struct {
 	UpstreamTaskID string `json:"upstream_task_id,omitempty"` // 上游真实 task ID
 	ResultURL      string `json:"result_url,omitempty"`       // 任务成功后的结果 URL（视频地址等）
 	// 计费上下文：用于异步退款/差额结算（轮询阶段读取）
-	BillingSource  string              `json:"billing_source,omitempty"`  // "wallet" 或 "subscription"
-	SubscriptionId int                 `json:"subscription_id,omitempty"` // 订阅 ID，用于订阅退款
-	TokenId        int                 `json:"token_id,omitempty"`        // 令牌 ID，用于令牌额度退款
-	NodeName       string              `json:"node_name,omitempty"`       // 发起任务的节点名，轮询结算阶段据此归属日志而非最后查询节点
-	BillingContext *TaskBillingContext `json:"billing_context,omitempty"` // 计费参数快照（用于轮询阶段重新计算）
+	BillingSource    string              `json:"billing_source,omitempty"`    // "wallet"、"subscription" 或 "organization"
+	SubscriptionId   int                 `json:"subscription_id,omitempty"`   // 订阅 ID，用于订阅退款
+	OrganizationId   int                 `json:"organization_id,omitempty"`   // 组织 ID，用于组织钱包退款
+	TokenId          int                 `json:"token_id,omitempty"`          // 令牌 ID，用于令牌额度退款
+	NodeName         string              `json:"node_name,omitempty"`         // 发起任务的节点名，轮询结算阶段据此归属日志而非最后查询节点
+	Project          string              `json:"project,omitempty"`           // 成本归属项目，结算日志沿用提交时的项目
+	BillingContext   *TaskBillingContext `json:"billing_context,omitempty"`   // 计费参数快照（用于轮询阶段重新计算）
+	CallbackURL      string              `json:"callback_url,omitempty"`      // 任务结束后通知客户端的回调地址
+	UpstreamCallback bool                `json:"upstream_callback,omitempty"` // 已向上游登记网关回调地址，轮询仅作兜底
+	CallbackAt       int64               `json:"callback_at,omitempty"`       // 最近一次收到上游回调的时间，兜底轮询从此刻重新计时
+	MediaAssetId     int                 `json:"media_asset_id,omitempty"`    // 结果已转存时对应的 MediaAsset ID
 }
 
 // TaskBillingContext 记录任务提交时的计费参数，以便轮询阶段可以重新计算额度。
@@ -131,15 +137,23 @@ func (t *Task) GetUpstreamTaskID() string {
 	return t.TaskID
 }
 
-// GetResultURL 获取任务结果 URL（视频地址等）
+// GetResultURL 获取任务结果 URL（视频地址等），转存文件的签名地址在读取时重新签发
 // 新数据存在 PrivateData.ResultURL 中；旧数据回退到 FailReason（历史兼容）
 func (t *Task) GetResultURL() string {
 	if t.PrivateData.ResultURL != "" {
-		return t.PrivateData.ResultURL
+		return RefreshMediaURLs(t.PrivateData.ResultURL)
 	}
 	return t.FailReason
 }
 
+// GetResultData 返回任务结果数据，其中转存文件的签名地址在读取时重新签发
+func (t *Task) GetResultData() json.RawMessage {
+	if len(t.Data) == 0 {
+		return t.Data
+	}
+	return json.RawMessage(RefreshMediaURLs(string(t.Data)))
+}
+
 // GenerateTaskID 生成对外暴露的 task_xxxx 格式 ID
 func GenerateTaskID() string {
 	key, _ := common.GenerateRandomCharsKey(32)
@@ -349,6 +363,20 @@ func GetByTaskId(userId int, taskId string) (*Task, bool, error) {
 	return task, exist, err
 }
 
+// GetTaskByChannelAndTaskId 按渠道和公开任务 ID 查询任务，供上游回调定位任务
+func GetTaskByChannelAndTaskId(channelId int, taskId string) (*Task, bool, error) {
+	if taskId == "" {
+		return nil, false, nil
+	}
+	var task *Task
+	err := DB.Where("channel_id = ? and task_id = ?", channelId, taskId).First(&task).Error
+	exist, err := RecordExist(err)
+	if err != nil {
+		return nil, false, err
+	}
+	return task, exist, nil
+}
+
 func GetByTaskIds(userId int, taskIds []any) ([]*Task, error) {
 	if len(taskIds) == 0 {
 		return nil, nil
@@ -363,6 +391,28 @@ func GetByTaskIds(userId int, taskIds []any) ([]*Task, error) {
 	return task, nil
 }
 
+// GetUserVideoTasks 按游标分页查询用户的视频任务（不含 Suno），afterId 为上一页最后一条的主键，0 表示从头开始
+func GetUserVideoTasks(userId int, afterId int64, limit int, ascending bool) ([]*Task, error) {
+	query := DB.Where("user_id = ? AND platform NOT IN ?", userId, []constant.TaskPlatform{constant.TaskPlatformSuno, constant.TaskPlatformImage})
+	order := "id desc"
+	if ascending {
+		order = "id asc"
+		if afterId > 0 {
+			query = query.Where("id > ?", afterId)
+		}
+	} else if afterId > 0 {
+		query = query.Where("id < ?", afterId)
+	}
+	var tasks []*Task
+	err := query.Order(order).Limit(limit).Find(&tasks).Error
+	return tasks, err
+}
+
+// DeleteTask 删除任务记录
+func DeleteTask(id int64) error {
+	return DB.Delete(&Task{}, id).Error
+}
+
 func (Task *Task) Insert() error {
 	var err error
 	err = DB.Create(Task).Error
@@ -428,6 +478,14 @@ func (t *Task) UpdateWithStatus(fromStatus TaskStatus) (bool, error) {
 	return result.RowsAffected > 0, nil
 }
 
+// MarkUpstreamCallback 持久化最近一次收到上游回调的时间，仅更新仍处于当前状态的任务，
+// 使兜底轮询在重启或多节点下也能识别回调正常的任务。
+func (t *Task) MarkUpstreamCallback(at int64) error {
+	t.PrivateData.CallbackAt = at
+	return DB.Model(&Task{}).Where("id = ? AND status = ?", t.ID, t.Status).
+		Update("private_data", t.PrivateData).Error
+}
+
 // TaskBulkUpdateByID performs an unconditional bulk UPDATE by primary key IDs.
 // WARNING: This function has NO CAS (Compare-And-Swap) guard — it will overwrite
 // any concurrent status changes. DO NOT use in billing/quota lifecycle flows
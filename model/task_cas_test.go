@@ -58,6 +58,22 @@ func TestMain(m *testing.M) {
 		&SystemTask{},
 		&SystemTaskLock{},
 		&ChannelHealthProbeState{},
+		&Organization{},
+		&OrganizationMember{},
+		&OrganizationInvite{},
+		&ScimIdentity{},
+		&AuditLog{},
+		&CreditGrant{},
+		&CreditLedgerEntry{},
+		&CreditSettlement{},
+		&Statement{},
+		&PostpaidAccount{},
+		&PriceBook{},
+		&Reseller{},
+		&ResellerUsage{},
+		&TaskWebhookDelivery{},
+		&MediaAsset{},
+		&Midjourney{},
 	); err != nil {
 		panic("failed to migrate: " + err.Error())
 	}
@@ -91,6 +107,22 @@ func truncateTables(t *testing.T) {
 		DB.Exec("DELETE FROM system_task_locks")
 		DB.Exec("DELETE FROM system_tasks")
 		DB.Exec("DELETE FROM channel_health_probe_states")
+		DB.Exec("DELETE FROM organizations")
+		DB.Exec("DELETE FROM organization_members")
+		DB.Exec("DELETE FROM organization_invites")
+		DB.Exec("DELETE FROM scim_identities")
+		DB.Exec("DELETE FROM audit_logs")
+		DB.Exec("DELETE FROM credit_grants")
+		DB.Exec("DELETE FROM credit_ledger_entries")
+		DB.Exec("DELETE FROM credit_settlements")
+		DB.Exec("DELETE FROM statements")
+		DB.Exec("DELETE FROM postpaid_accounts")
+		DB.Exec("DELETE FROM price_books")
+		DB.Exec("DELETE FROM resellers")
+		DB.Exec("DELETE FROM reseller_usages")
+		DB.Exec("DELETE FROM task_webhook_deliveries")
+		DB.Exec("DELETE FROM media_assets")
+		DB.Exec("DELETE FROM midjourneys")
 	})
 }
 
@@ -0,0 +1,145 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+)
+
+const (
+	TaskWebhookStatusPending   = "pending"
+	TaskWebhookStatusSucceeded = "succeeded"
+	TaskWebhookStatusFailed    = "failed"
+)
+
+// 回调来源：异步任务表（视频、音乐等）或 Midjourney 任务表
+const (
+	TaskWebhookSourceTask       = "task"
+	TaskWebhookSourceMidjourney = "midjourney"
+)
+
+var ErrTaskWebhookDeliveryNotFound = errors.New("task webhook delivery not found")
+
+// TaskWebhookDelivery 任务完成回调的投递队列，同时作为投递日志保留每次尝试的结果
+type TaskWebhookDelivery struct {
+	Id             int    `json:"id"`
+	UserId         int    `json:"user_id" gorm:"index"`
+	Source         string `json:"source" gorm:"type:varchar(20)"`
+	TaskId         string `json:"task_id" gorm:"type:varchar(191);index"`
+	Event          string `json:"event" gorm:"type:varchar(32)"`
+	Url            string `json:"url" gorm:"type:varchar(512)"`
+	Payload        string `json:"payload" gorm:"type:text"`
+	Status         string `json:"status" gorm:"type:varchar(16);index:idx_task_webhook_due,priority:1"`
+	Attempts       int    `json:"attempts" gorm:"default:0"`
+	NextAttemptAt  int64  `json:"next_attempt_at" gorm:"bigint;index:idx_task_webhook_due,priority:2"`
+	LastStatusCode int    `json:"last_status_code" gorm:"default:0"`
+	LastError      string `json:"last_error" gorm:"type:text"`
+	DeliveredAt    int64  `json:"delivered_at" gorm:"bigint;default:0"`
+	CreatedAt      int64  `json:"created_at" gorm:"bigint;index"`
+	UpdatedAt      int64  `json:"updated_at" gorm:"bigint"`
+}
+
+// CreateTaskWebhookDelivery 写入一条待投递的回调，立即可被投递任务领取
+func CreateTaskWebhookDelivery(delivery *TaskWebhookDelivery) error {
+	now := common.GetTimestamp()
+	delivery.Status = TaskWebhookStatusPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	delivery.CreatedAt = now
+	delivery.UpdatedAt = now
+	return DB.Create(delivery).Error
+}
+
+// ClaimDueTaskWebhookDeliveries 领取到期的待投递回调，领取时把下次尝试时间推后 lease 秒，
+// 投递过程中进程退出时该条会在租约过期后被重新领取
+func ClaimDueTaskWebhookDeliveries(limit int, lease int64) ([]*TaskWebhookDelivery, error) {
+	now := common.GetTimestamp()
+	var candidates []*TaskWebhookDelivery
+	err := DB.Where("status = ? AND next_attempt_at <= ?", TaskWebhookStatusPending, now).
+		Order("next_attempt_at asc").Limit(limit).Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+	claimed := make([]*TaskWebhookDelivery, 0, len(candidates))
+	for _, delivery := range candidates {
+		result := DB.Model(&TaskWebhookDelivery{}).
+			Where("id = ? AND status = ? AND next_attempt_at = ?", delivery.Id, TaskWebhookStatusPending, delivery.NextAttemptAt).
+			Updates(map[string]any{"next_attempt_at": now + lease, "updated_at": now})
+		if result.Error != nil {
+			return claimed, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		delivery.NextAttemptAt = now + lease
+		claimed = append(claimed, delivery)
+	}
+	return claimed, nil
+}
+
+// RecordTaskWebhookAttempt 记录一次投递结果；nextAttemptAt 为 0 表示不再重试
+func RecordTaskWebhookAttempt(id int, statusCode int, deliverErr error, nextAttemptAt int64) error {
+	now := common.GetTimestamp()
+	updates := map[string]any{
+		"attempts":         gorm.Expr("attempts + 1"),
+		"last_status_code": statusCode,
+		"updated_at":       now,
+	}
+	switch {
+	case deliverErr == nil:
+		updates["status"] = TaskWebhookStatusSucceeded
+		updates["last_error"] = ""
+		updates["delivered_at"] = now
+	case nextAttemptAt > 0:
+		updates["last_error"] = deliverErr.Error()
+		updates["next_attempt_at"] = nextAttemptAt
+	default:
+		updates["status"] = TaskWebhookStatusFailed
+		updates["last_error"] = deliverErr.Error()
+	}
+	return DB.Model(&TaskWebhookDelivery{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// RetryTaskWebhookDelivery 将已结束的回调重新放回队列，重置尝试次数
+func RetryTaskWebhookDelivery(id int, userId int) error {
+	query := DB.Model(&TaskWebhookDelivery{}).Where("id = ? AND status <> ?", id, TaskWebhookStatusPending)
+	if userId > 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	now := common.GetTimestamp()
+	result := query.Updates(map[string]any{
+		"status":          TaskWebhookStatusPending,
+		"attempts":        0,
+		"next_attempt_at": now,
+		"updated_at":      now,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTaskWebhookDeliveryNotFound
+	}
+	return nil
+}
+
+// GetTaskWebhookDeliveries 分页查询回调投递记录，userId 为 0 时查询全部用户
+func GetTaskWebhookDeliveries(userId int, taskId string, status string, startIdx int, num int) ([]*TaskWebhookDelivery, int64, error) {
+	query := DB.Model(&TaskWebhookDelivery{})
+	if userId > 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if taskId != "" {
+		query = query.Where("task_id = ?", taskId)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var deliveries []*TaskWebhookDelivery
+	err := query.Order("id desc").Limit(num).Offset(startIdx).Find(&deliveries).Error
+	return deliveries, total, err
+}
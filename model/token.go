@@ -3,6 +3,7 @@ package model
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/QuantumNous/new-api/common"
@@ -29,7 +30,21 @@ type Token struct {
 	Group              string         `json:"group" gorm:"default:''"`
 	CrossGroupRetry    bool           `json:"cross_group_retry"` // 跨分组重试，仅auto分组有效
 	AutoGroups         string         `json:"-" gorm:"type:text"`
+	OrgId              int            `json:"org_id" gorm:"index;default:0"`  // 非 0 时从组织共享钱包扣费
+	BudgetAmount       int            `json:"budget_amount" gorm:"default:0"` // 周期预算，0 表示未启用，见 token_budget.go
+	BudgetPeriod       string         `json:"budget_period" gorm:"type:varchar(16);default:''"`
+	BudgetTimezone     string         `json:"budget_timezone" gorm:"type:varchar(64);default:''"`
+	BudgetSoftPercent  int            `json:"budget_soft_percent" gorm:"default:0"` // 达到该百分比时提醒一次，0 表示不提醒
+	BudgetHardPercent  int            `json:"budget_hard_percent" gorm:"default:0"` // 超过该百分比拒绝请求，0 表示 100
+	BudgetUsed         int            `json:"budget_used" gorm:"default:0"`
+	BudgetPeriodStart  int64          `json:"budget_period_start" gorm:"bigint;default:0"`
+	BudgetResetTime    int64          `json:"budget_reset_time" gorm:"bigint;default:0;index"`
+	BudgetSoftNotified bool           `json:"budget_soft_notified"`
+	AllowedProjects    string         `json:"allowed_projects" gorm:"type:text"`                // 允许的成本归属项目，每行一个，* 表示任意
+	CallbackUrl        string         `json:"callback_url" gorm:"type:varchar(512);default:''"` // 异步任务完成回调的默认地址，请求未指定时使用
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
+
+	budgetRestarted bool // SetBudget 开启了新周期，Update 时需一并写入计数
 }
 
 func (token *Token) GetAutoGroups() ([]string, error) {
@@ -103,6 +118,63 @@ func (token *Token) GetIpLimits() []string {
 	return ipLimits
 }
 
+// GetAllowedProjects 返回令牌允许使用的项目标签列表
+func (token *Token) GetAllowedProjects() []string {
+	return ParseAllowedProjects(token.AllowedProjects)
+}
+
+func ParseAllowedProjects(raw string) []string {
+	projects := make([]string, 0)
+	for _, item := range strings.FieldsFunc(raw, func(r rune) bool {
+		return r == '\n' || r == ','
+	}) {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			projects = append(projects, item)
+		}
+	}
+	return projects
+}
+
+var projectTagPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:/-]{0,63}$`)
+
+// ValidateProjectTag 校验项目标签格式：字母或数字开头，最长 64 个字符
+func ValidateProjectTag(project string) error {
+	if !projectTagPattern.MatchString(project) {
+		return fmt.Errorf("invalid project tag: %q", project)
+	}
+	return nil
+}
+
+// NormalizeAllowedProjects 校验并规范化令牌的项目允许列表（去重、每行一个）
+func NormalizeAllowedProjects(raw string) (string, error) {
+	seen := make(map[string]struct{})
+	projects := make([]string, 0)
+	for _, project := range ParseAllowedProjects(raw) {
+		if project != "*" {
+			if err := ValidateProjectTag(project); err != nil {
+				return "", err
+			}
+		}
+		if _, ok := seen[project]; ok {
+			continue
+		}
+		seen[project] = struct{}{}
+		projects = append(projects, project)
+	}
+	return strings.Join(projects, "\n"), nil
+}
+
+// IsProjectAllowed 判断项目是否在允许列表中；列表为空时不允许携带项目标签
+func IsProjectAllowed(allowedProjects string, project string) bool {
+	for _, allowed := range ParseAllowedProjects(allowedProjects) {
+		if allowed == "*" || allowed == project {
+			return true
+		}
+	}
+	return false
+}
+
 func GetAllUserTokens(userId int, startIdx int, num int) ([]*Token, error) {
 	var tokens []*Token
 	var err error
@@ -312,8 +384,14 @@ func (token *Token) Update() (err error) {
 	if cacheErr := invalidateTokenCacheForMutation(token.Key); cacheErr != nil {
 		common.SysLog("failed to invalidate token cache before update: " + cacheErr.Error())
 	}
-	return DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry", "auto_groups").Updates(token).Error
+	columns := []string{"name", "status", "expired_time", "remain_quota", "unlimited_quota",
+		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry", "auto_groups",
+		"budget_amount", "budget_period", "budget_timezone", "budget_soft_percent", "budget_hard_percent",
+		"allowed_projects", "callback_url"}
+	if token.budgetRestarted {
+		columns = append(columns, "budget_used", "budget_period_start", "budget_reset_time", "budget_soft_notified")
+	}
+	return DB.Model(token).Select(columns).Updates(token).Error
 }
 
 func (token *Token) SelectUpdate() (err error) {
@@ -478,6 +556,32 @@ func GetTokenKeysByIds(ids []int, userId int) ([]Token, error) {
 	return tokens, err
 }
 
+// DisableUserTokens 禁用指定用户的所有启用中令牌（如用户被身份提供方停用时），
+// 返回被禁用的令牌数量。缓存先于数据库失效，保证被禁用的令牌不会继续通过缓存校验。
+func DisableUserTokens(userId int) (int64, error) {
+	if userId <= 0 {
+		return 0, errors.New("userId 无效")
+	}
+	var tokens []Token
+	if err := DB.Select("id", commonKeyCol).
+		Where("user_id = ? AND status = ?", userId, common.TokenStatusEnabled).
+		Find(&tokens).Error; err != nil {
+		return 0, err
+	}
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+	if err := invalidateTokensCache(tokens); err != nil {
+		return 0, err
+	}
+	ids := make([]int, 0, len(tokens))
+	for _, token := range tokens {
+		ids = append(ids, token.Id)
+	}
+	result := DB.Model(&Token{}).Where("id IN ?", ids).Update("status", common.TokenStatusDisabled)
+	return result.RowsAffected, result.Error
+}
+
 // InvalidateUserTokensCache 清理指定用户所有令牌在 Redis 中的缓存，
 // 配合 InvalidateUserCache 使用，可在用户被禁用/删除时立即阻断其令牌的请求。
 // 下一次请求将从数据库重新加载令牌及用户状态，从而立即识别出被禁用的用户。
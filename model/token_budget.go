@@ -0,0 +1,298 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/bytedance/gopkg/util/gopool"
+	"gorm.io/gorm"
+)
+
+// 令牌周期预算：在 RemainQuota（终身额度）之外，为令牌设置按日/周/月自动重置的
+// 消费上限。BudgetAmount 为 0 表示未启用。
+//
+// 预算计数（budget_used 等）只以数据库为准，不进入令牌缓存；缓存中仅保留
+// BudgetAmount 用于在鉴权时判断令牌是否需要走预算预扣路径。
+const (
+	TokenBudgetPeriodDaily   = SubscriptionResetDaily
+	TokenBudgetPeriodWeekly  = SubscriptionResetWeekly
+	TokenBudgetPeriodMonthly = SubscriptionResetMonthly
+)
+
+const tokenBudgetMaxHardPercent = 1000
+
+// tokenBudgetLimitExpr 硬上限：budget_amount * hard% / 100，hard 为 0 时按 100%。
+const tokenBudgetLimitExpr = "budget_amount * (CASE WHEN budget_hard_percent > 0 THEN budget_hard_percent ELSE 100 END) / 100"
+
+func NormalizeTokenBudgetPeriod(period string) string {
+	switch strings.TrimSpace(period) {
+	case TokenBudgetPeriodDaily, TokenBudgetPeriodWeekly, TokenBudgetPeriodMonthly:
+		return strings.TrimSpace(period)
+	default:
+		return ""
+	}
+}
+
+func loadTokenBudgetLocation(timezone string) (*time.Location, error) {
+	timezone = strings.TrimSpace(timezone)
+	if timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(timezone)
+}
+
+// tokenBudgetPeriodBounds 返回 now 所在预算周期的起止时间（按令牌时区对齐到
+// 零点、周一零点或月初零点）。
+func tokenBudgetPeriodBounds(now time.Time, period string, loc *time.Location) (time.Time, time.Time) {
+	now = now.In(loc)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	switch period {
+	case TokenBudgetPeriodWeekly:
+		weekday := int(day.Weekday())
+		if weekday == 0 {
+			weekday = 7
+		}
+		start := day.AddDate(0, 0, 1-weekday)
+		return start, start.AddDate(0, 0, 7)
+	case TokenBudgetPeriodMonthly:
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 1, 0)
+	default:
+		return day, day.AddDate(0, 0, 1)
+	}
+}
+
+// HasBudget 令牌是否启用了周期预算
+func (token *Token) HasBudget() bool {
+	return token.BudgetAmount > 0
+}
+
+// BudgetLimit 返回当前周期的硬上限
+func (token *Token) BudgetLimit() int {
+	hard := token.BudgetHardPercent
+	if hard <= 0 {
+		hard = 100
+	}
+	return token.BudgetAmount * hard / 100
+}
+
+// SetBudget 校验并应用周期预算配置。启用预算、或周期/时区发生变化时开启新周期并清零计数；
+// 仅调整金额或阈值时保留本周期已用额度。
+func (token *Token) SetBudget(amount int, period string, timezone string, softPercent int, hardPercent int) error {
+	if amount < 0 {
+		return errors.New("budget amount must not be negative")
+	}
+	if amount == 0 {
+		if token.BudgetAmount != 0 || token.BudgetResetTime != 0 {
+			token.budgetRestarted = true
+		}
+		token.BudgetAmount = 0
+		token.BudgetPeriod = ""
+		token.BudgetTimezone = ""
+		token.BudgetSoftPercent = 0
+		token.BudgetHardPercent = 0
+		token.BudgetUsed = 0
+		token.BudgetPeriodStart = 0
+		token.BudgetResetTime = 0
+		token.BudgetSoftNotified = false
+		return nil
+	}
+	normalized := NormalizeTokenBudgetPeriod(period)
+	if normalized == "" {
+		return fmt.Errorf("invalid budget period: %s", period)
+	}
+	timezone = strings.TrimSpace(timezone)
+	loc, err := loadTokenBudgetLocation(timezone)
+	if err != nil {
+		return fmt.Errorf("invalid budget timezone: %s", timezone)
+	}
+	if softPercent < 0 || softPercent > 100 {
+		return errors.New("budget soft threshold must be between 0 and 100")
+	}
+	if hardPercent < 0 || hardPercent > tokenBudgetMaxHardPercent {
+		return fmt.Errorf("budget hard threshold must be between 0 and %d", tokenBudgetMaxHardPercent)
+	}
+	restart := token.BudgetAmount == 0 || token.BudgetPeriod != normalized || token.BudgetTimezone != timezone
+	softChanged := token.BudgetSoftPercent != softPercent || token.BudgetAmount != amount
+	token.BudgetAmount = amount
+	token.BudgetPeriod = normalized
+	token.BudgetTimezone = timezone
+	token.BudgetSoftPercent = softPercent
+	token.BudgetHardPercent = hardPercent
+	if restart {
+		start, end := tokenBudgetPeriodBounds(time.Now(), normalized, loc)
+		token.BudgetUsed = 0
+		token.BudgetPeriodStart = start.Unix()
+		token.BudgetResetTime = end.Unix()
+		token.BudgetSoftNotified = false
+		token.budgetRestarted = true
+	} else if softChanged {
+		token.BudgetSoftNotified = false
+		token.budgetRestarted = true
+	}
+	return nil
+}
+
+// reserveBudgetedTokenQuotaDB 在同一条条件更新中同时校验令牌余额与本周期硬上限。
+// 周期已到期但尚未被重置任务处理时更新失败，由调用方重置后重试。
+func reserveBudgetedTokenQuotaDB(id int, quota int, skipBalance bool, now int64) (bool, error) {
+	tx := DB.Model(&Token{}).
+		Where("id = ? AND budget_amount > 0 AND budget_reset_time > ?", id, now).
+		Where("budget_used + ? <= "+tokenBudgetLimitExpr, quota)
+	if !skipBalance {
+		tx = tx.Where("remain_quota >= ?", quota)
+	}
+	result := tx.Updates(map[string]interface{}{
+		"remain_quota":  gorm.Expr("remain_quota - ?", quota),
+		"used_quota":    gorm.Expr("used_quota + ?", quota),
+		"budget_used":   gorm.Expr("budget_used + ?", quota),
+		"accessed_time": now,
+	})
+	return result.RowsAffected == 1, result.Error
+}
+
+// tryReserveBudgetedTokenQuota 预算令牌的预算计数始终直写数据库。批量更新模式下数据库
+// remain_quota 滞后于缓存，余额改由缓存原子预扣校验，数据库只校验预算；
+// 其余情况在同一条更新中校验余额与预算，成功后再异步同步缓存余额。
+func tryReserveBudgetedTokenQuota(id int, key string, quota int, unlimited bool) (bool, error) {
+	if !unlimited && common.RedisEnabled && common.BatchUpdateEnabled {
+		result, err := cacheTryReserveTokenQuota(id, key, int64(quota))
+		if err == nil && result == cacheQuotaMiss {
+			if _, hydrateErr := GetTokenByKey(key, true); hydrateErr == nil {
+				result, err = cacheTryReserveTokenQuota(id, key, int64(quota))
+			}
+		}
+		if err != nil {
+			common.SysLog("budgeted token quota cache reserve unavailable, falling back to database: " + err.Error())
+		}
+		if err == nil && result == cacheQuotaInsufficient {
+			return false, nil
+		}
+		if err == nil && result == cacheQuotaOK {
+			reserved, err := reserveTokenBudgetDB(id, quota, true)
+			if err != nil || !reserved {
+				compensated, compensateErr := cacheApplyTokenQuotaDelta(id, key, int64(quota))
+				if compensateErr != nil || compensated != cacheQuotaOK {
+					common.SysError(fmt.Sprintf("failed to compensate reserved token quota: result=%d error=%v", compensated, compensateErr))
+				}
+			}
+			return reserved, err
+		}
+	}
+	reserved, err := reserveTokenBudgetDB(id, quota, unlimited)
+	if reserved && common.RedisEnabled {
+		gopool.Go(func() {
+			if _, err := cacheApplyTokenQuotaDelta(id, key, int64(-quota)); err != nil {
+				common.SysLog("failed to sync budgeted token quota cache: " + err.Error())
+			}
+		})
+	}
+	return reserved, err
+}
+
+// reserveTokenBudgetDB 在数据库中预扣预算令牌额度，周期到期时先重置再重试一次。
+// skipBalance 为 true 时不校验 remain_quota（无限额度或余额已由缓存校验）。
+func reserveTokenBudgetDB(id int, quota int, skipBalance bool) (bool, error) {
+	now := common.GetTimestamp()
+	reserved, err := reserveBudgetedTokenQuotaDB(id, quota, skipBalance, now)
+	if err != nil || reserved {
+		return reserved, err
+	}
+	resetCount, err := resetTokenBudgetIfDue(id, now)
+	if err != nil || resetCount == 0 {
+		return false, err
+	}
+	return reserveBudgetedTokenQuotaDB(id, quota, skipBalance, now)
+}
+
+// resetTokenBudgetIfDue 若令牌预算周期已到期，则开启新周期并清零计数。
+func resetTokenBudgetIfDue(id int, now int64) (int, error) {
+	var token Token
+	err := DB.Select("id", "budget_amount", "budget_period", "budget_timezone", "budget_reset_time").
+		Where("id = ?", id).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if !token.HasBudget() || token.BudgetResetTime <= 0 || token.BudgetResetTime > now {
+		return 0, nil
+	}
+	return resetTokenBudget(&token, now)
+}
+
+func resetTokenBudget(token *Token, now int64) (int, error) {
+	period := NormalizeTokenBudgetPeriod(token.BudgetPeriod)
+	if period == "" {
+		period = TokenBudgetPeriodMonthly
+	}
+	loc, err := loadTokenBudgetLocation(token.BudgetTimezone)
+	if err != nil {
+		loc = time.Local
+	}
+	start, end := tokenBudgetPeriodBounds(time.Unix(now, 0), period, loc)
+	// 以旧的 reset 时间为条件，避免与并发重置重复清零
+	result := DB.Model(&Token{}).
+		Where("id = ? AND budget_reset_time = ?", token.Id, token.BudgetResetTime).
+		Updates(map[string]interface{}{
+			"budget_used":          0,
+			"budget_period_start":  start.Unix(),
+			"budget_reset_time":    end.Unix(),
+			"budget_soft_notified": false,
+		})
+	return int(result.RowsAffected), result.Error
+}
+
+// ResetDueTokenBudgets 重置已到期的令牌预算周期，返回本批重置数量。
+func ResetDueTokenBudgets(limit int) (int, error) {
+	if limit <= 0 {
+		limit = 200
+	}
+	now := common.GetTimestamp()
+	var tokens []Token
+	err := DB.Select("id", "budget_amount", "budget_period", "budget_timezone", "budget_reset_time").
+		Where("budget_amount > 0 AND budget_reset_time > 0 AND budget_reset_time <= ?", now).
+		Order("budget_reset_time asc").
+		Limit(limit).
+		Find(&tokens).Error
+	if err != nil || len(tokens) == 0 {
+		return 0, err
+	}
+	resetCount := 0
+	for i := range tokens {
+		n, err := resetTokenBudget(&tokens[i], now)
+		if err != nil {
+			return resetCount, err
+		}
+		resetCount += n
+	}
+	return resetCount, nil
+}
+
+// AdjustTokenBudgetUsed 按结算差额调整本周期已用预算（delta < 0 为退还），不走批量更新。
+// 预扣发生在当前周期开始之前时不调整，避免上一周期的退款抵扣新周期的用量。
+func AdjustTokenBudgetUsed(id int, delta int, reservedAt int64) error {
+	if delta == 0 {
+		return nil
+	}
+	return DB.Model(&Token{}).
+		Where("id = ? AND budget_amount > 0 AND budget_period_start <= ?", id, reservedAt).
+		Update("budget_used", gorm.Expr("CASE WHEN budget_used + ? > 0 THEN budget_used + ? ELSE 0 END", delta, delta)).Error
+}
+
+// MarkTokenBudgetSoftThreshold 本周期已用额度首次达到软阈值时返回令牌（仅返回一次），
+// 供调用方发送提醒；未达到或已提醒过时返回 nil。
+func MarkTokenBudgetSoftThreshold(id int) (*Token, error) {
+	result := DB.Model(&Token{}).
+		Where("id = ? AND budget_amount > 0 AND budget_soft_percent > 0 AND budget_soft_notified = ?", id, false).
+		Where("budget_used * 100 >= budget_amount * budget_soft_percent").
+		Update("budget_soft_notified", true)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	return GetTokenById(id)
+}
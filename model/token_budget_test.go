@@ -0,0 +1,192 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createBudgetTestToken(t *testing.T, remainQuota int, unlimited bool, amount int, softPercent int, hardPercent int) Token {
+	t.Helper()
+	token := createReserveTestToken(t, remainQuota)
+	token.UnlimitedQuota = unlimited
+	require.NoError(t, token.SetBudget(amount, TokenBudgetPeriodDaily, "UTC", softPercent, hardPercent))
+	require.NoError(t, token.Update())
+	return token
+}
+
+func TestTokenBudgetPeriodBounds(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+	// 2026-03-04 是周三
+	now := time.Date(2026, 3, 4, 1, 30, 0, 0, loc)
+
+	start, end := tokenBudgetPeriodBounds(now, TokenBudgetPeriodDaily, loc)
+	assert.Equal(t, time.Date(2026, 3, 4, 0, 0, 0, 0, loc), start)
+	assert.Equal(t, time.Date(2026, 3, 5, 0, 0, 0, 0, loc), end)
+
+	start, end = tokenBudgetPeriodBounds(now, TokenBudgetPeriodWeekly, loc)
+	assert.Equal(t, time.Date(2026, 3, 2, 0, 0, 0, 0, loc), start)
+	assert.Equal(t, time.Date(2026, 3, 9, 0, 0, 0, 0, loc), end)
+
+	start, end = tokenBudgetPeriodBounds(now, TokenBudgetPeriodMonthly, loc)
+	assert.Equal(t, time.Date(2026, 3, 1, 0, 0, 0, 0, loc), start)
+	assert.Equal(t, time.Date(2026, 4, 1, 0, 0, 0, 0, loc), end)
+
+	// 同一时刻在 UTC 下仍属于前一天
+	start, _ = tokenBudgetPeriodBounds(now, TokenBudgetPeriodDaily, time.UTC)
+	assert.Equal(t, time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC), start)
+}
+
+func TestTokenBudgetRejectsInvalidConfig(t *testing.T) {
+	token := Token{}
+	assert.Error(t, token.SetBudget(100, "yearly", "", 0, 0))
+	assert.Error(t, token.SetBudget(100, TokenBudgetPeriodDaily, "Mars/Olympus", 0, 0))
+	assert.Error(t, token.SetBudget(100, TokenBudgetPeriodDaily, "", 120, 0))
+	assert.Error(t, token.SetBudget(-1, TokenBudgetPeriodDaily, "", 0, 0))
+	assert.NoError(t, token.SetBudget(0, "", "", 0, 0))
+	assert.False(t, token.HasBudget())
+}
+
+func TestTokenBudgetEnforcesHardLimit(t *testing.T) {
+	truncateTables(t)
+	resetBatchUpdateTestState(t)
+	token := createBudgetTestToken(t, 0, true, 100, 0, 120)
+
+	reserved, err := TryReserveTokenQuota(token.Id, token.Key, 90, true, true)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+	reserved, err = TryReserveTokenQuota(token.Id, token.Key, 30, true, true)
+	require.NoError(t, err)
+	assert.True(t, reserved, "hard threshold allows up to 120%")
+	reserved, err = TryReserveTokenQuota(token.Id, token.Key, 1, true, true)
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, 120, getTokenFromDB(t, token.Id).BudgetUsed)
+
+	// 结算退款释放本周期预算
+	require.NoError(t, IncreaseTokenQuota(token.Id, token.Key, 20))
+	require.NoError(t, AdjustTokenBudgetUsed(token.Id, -20, time.Now().Unix()))
+	assert.Equal(t, 100, getTokenFromDB(t, token.Id).BudgetUsed)
+	reserved, err = TryReserveTokenQuota(token.Id, token.Key, 20, true, true)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+}
+
+func TestTokenBudgetStillChecksRemainQuota(t *testing.T) {
+	truncateTables(t)
+	resetBatchUpdateTestState(t)
+	token := createBudgetTestToken(t, 50, false, 100, 0, 0)
+
+	reserved, err := TryReserveTokenQuota(token.Id, token.Key, 60, false, true)
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	reloaded := getTokenFromDB(t, token.Id)
+	assert.Equal(t, 50, reloaded.RemainQuota)
+	assert.Equal(t, 0, reloaded.BudgetUsed)
+}
+
+func TestTokenBudgetIgnoresRefundsFromPreviousPeriod(t *testing.T) {
+	truncateTables(t)
+	resetBatchUpdateTestState(t)
+	token := createBudgetTestToken(t, 0, true, 100, 0, 0)
+	previous := time.Now().Add(-48 * time.Hour).Unix()
+
+	reserved, err := TryReserveTokenQuota(token.Id, token.Key, 60, true, true)
+	require.NoError(t, err)
+	require.True(t, reserved)
+
+	// 上一周期的预扣退款不抵扣本周期用量
+	require.NoError(t, AdjustTokenBudgetUsed(token.Id, -50, previous))
+	assert.Equal(t, 60, getTokenFromDB(t, token.Id).BudgetUsed)
+	require.NoError(t, AdjustTokenBudgetUsed(token.Id, -50, time.Now().Unix()))
+	assert.Equal(t, 10, getTokenFromDB(t, token.Id).BudgetUsed)
+}
+
+func TestTokenBudgetChecksCachedBalanceInBatchMode(t *testing.T) {
+	truncateTables(t)
+	resetBatchUpdateTestState(t)
+	token := createBudgetTestToken(t, 10, false, 100, 0, 0)
+	useUserCacheMiniRedis(t)
+	common.BatchUpdateEnabled = true
+
+	reserved, err := TryReserveTokenQuota(token.Id, token.Key, 4, false, true)
+	require.NoError(t, err)
+	require.True(t, reserved)
+	// 普通结算已在缓存扣减、批量增量尚未落库
+	result, err := cacheApplyTokenQuotaDelta(token.Id, token.Key, -5)
+	require.NoError(t, err)
+	require.Equal(t, cacheQuotaOK, result)
+	addNewRecord(BatchUpdateTypeTokenQuota, token.Id, -5)
+	assert.Equal(t, 6, getTokenFromDB(t, token.Id).RemainQuota, "batch delta is not flushed yet")
+
+	reserved, err = TryReserveTokenQuota(token.Id, token.Key, 3, false, true)
+	require.NoError(t, err)
+	assert.False(t, reserved, "stale DB balance must not authorize a budgeted spend")
+	reloaded := getTokenFromDB(t, token.Id)
+	assert.Equal(t, 4, reloaded.BudgetUsed)
+
+	reserved, err = TryReserveTokenQuota(token.Id, token.Key, 1, false, true)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+	batchUpdate()
+	reloaded = getTokenFromDB(t, token.Id)
+	assert.Equal(t, 0, reloaded.RemainQuota)
+	assert.Equal(t, 5, reloaded.BudgetUsed)
+}
+
+func TestTokenBudgetResetsWhenPeriodIsDue(t *testing.T) {
+	truncateTables(t)
+	resetBatchUpdateTestState(t)
+	token := createBudgetTestToken(t, 0, true, 100, 0, 0)
+
+	reserved, err := TryReserveTokenQuota(token.Id, token.Key, 100, true, true)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	past := time.Now().Add(-time.Minute).Unix()
+	require.NoError(t, DB.Model(&Token{}).Where("id = ?", token.Id).Update("budget_reset_time", past).Error)
+
+	// 预扣路径按需重置
+	reserved, err = TryReserveTokenQuota(token.Id, token.Key, 40, true, true)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+	reloaded := getTokenFromDB(t, token.Id)
+	assert.Equal(t, 40, reloaded.BudgetUsed)
+	assert.Greater(t, reloaded.BudgetResetTime, time.Now().Unix())
+
+	// 定时任务重置
+	require.NoError(t, DB.Model(&Token{}).Where("id = ?", token.Id).Update("budget_reset_time", past).Error)
+	n, err := ResetDueTokenBudgets(10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 0, getTokenFromDB(t, token.Id).BudgetUsed)
+}
+
+func TestTokenBudgetSoftThresholdNotifiesOncePerPeriod(t *testing.T) {
+	truncateTables(t)
+	resetBatchUpdateTestState(t)
+	token := createBudgetTestToken(t, 0, true, 100, 80, 0)
+
+	reserved, err := TryReserveTokenQuota(token.Id, token.Key, 70, true, true)
+	require.NoError(t, err)
+	require.True(t, reserved)
+	marked, err := MarkTokenBudgetSoftThreshold(token.Id)
+	require.NoError(t, err)
+	assert.Nil(t, marked)
+
+	reserved, err = TryReserveTokenQuota(token.Id, token.Key, 10, true, true)
+	require.NoError(t, err)
+	require.True(t, reserved)
+	marked, err = MarkTokenBudgetSoftThreshold(token.Id)
+	require.NoError(t, err)
+	require.NotNil(t, marked)
+	assert.Equal(t, 80, marked.BudgetUsed)
+
+	marked, err = MarkTokenBudgetSoftThreshold(token.Id)
+	require.NoError(t, err)
+	assert.Nil(t, marked)
+}
@@ -66,7 +66,7 @@ if redis.call('EXISTS', KEYS[2]) == 1 then
   return 0
 end
 if redis.call('EXISTS', KEYS[1]) == 1 then
-  redis.call('EXPIRE', KEYS[1], ARGV[17])
+  redis.call('EXPIRE', KEYS[1], ARGV[21])
   return 2
 end
 redis.call('HSET', KEYS[1],
@@ -74,8 +74,9 @@ redis.call('HSET', KEYS[1],
   'CreatedTime', ARGV[5], 'AccessedTime', ARGV[6], 'ExpiredTime', ARGV[7],
   'UnlimitedQuota', ARGV[8], 'ModelLimitsEnabled', ARGV[9], 'ModelLimits', ARGV[10],
   'AllowIps', ARGV[11], 'Group', ARGV[12], 'CrossGroupRetry', ARGV[13],
-  'AutoGroups', ARGV[14], 'RemainQuota', ARGV[15], 'UsedQuota', ARGV[16])
-redis.call('EXPIRE', KEYS[1], ARGV[17])
+  'AutoGroups', ARGV[14], 'RemainQuota', ARGV[15], 'UsedQuota', ARGV[16],
+  'OrgId', ARGV[17], 'BudgetAmount', ARGV[18], 'AllowedProjects', ARGV[19], 'CallbackUrl', ARGV[20])
+redis.call('EXPIRE', KEYS[1], ARGV[21])
 return 1`
 
 	return common.RDB.Eval(context.Background(), script, []string{
@@ -85,8 +86,8 @@ return 1`
 		token.CreatedTime, token.AccessedTime, token.ExpiredTime,
 		strconv.FormatBool(token.UnlimitedQuota), strconv.FormatBool(token.ModelLimitsEnabled),
 		token.ModelLimits, allowIps, token.Group, strconv.FormatBool(token.CrossGroupRetry),
-		token.AutoGroups, token.RemainQuota, token.UsedQuota,
-		tokenCacheTTLSeconds(),
+		token.AutoGroups, token.RemainQuota, token.UsedQuota, token.OrgId, token.BudgetAmount,
+		token.AllowedProjects, token.CallbackUrl, tokenCacheTTLSeconds(),
 	).Int()
 }
 
@@ -22,6 +22,9 @@ type TopUp struct {
 	CreateTime      int64   `json:"create_time"`
 	CompleteTime    int64   `json:"complete_time"`
 	Status          string  `json:"status"`
+	// Money 的币种与下单时的汇率（1 USD = ExchangeRate Currency），历史订单为空
+	Currency     string  `json:"currency" gorm:"type:varchar(8);default:''"`
+	ExchangeRate float64 `json:"exchange_rate" gorm:"default:0"`
 }
 
 const (
@@ -83,12 +86,17 @@ func ValidateTopUpQuotaCapacity(userId int, creditedQuota int) error {
 
 // creditTopUpQuota atomically enforces the int32 wallet ceiling while adding
 // quota. Keeping the predicate and increment in one UPDATE prevents two
-// concurrent callbacks from both passing a separate read/check.
-func creditTopUpQuota(tx *gorm.DB, userId int, creditedQuota int, updates map[string]interface{}) error {
+// concurrent callbacks from both passing a separate read/check. The credit
+// ledger grant is posted in the same transaction, before the balance moves.
+func creditTopUpQuota(tx *gorm.DB, topUp *TopUp, creditedQuota int, updates map[string]interface{}) error {
+	userId := topUp.UserId
 	maxCurrentQuota, err := topUpQuotaMaxCurrent(creditedQuota)
 	if err != nil {
 		return err
 	}
+	if err := PostCreditGrant(tx, userId, CreditSourceTopUp, "topup:"+topUp.TradeNo, creditedQuota); err != nil {
+		return err
+	}
 
 	updateFields := make(map[string]interface{}, len(updates)+1)
 	for key, value := range updates {
@@ -214,7 +222,7 @@ func RechargeEpay(tradeNo string, actualPaymentMethod string, callerIp string) (
 		if err := tx.Save(topUp).Error; err != nil {
 			return err
 		}
-		return creditTopUpQuota(tx, topUp.UserId, quotaToAdd, nil)
+		return creditTopUpQuota(tx, topUp, quotaToAdd, nil)
 	})
 	if err != nil {
 		if !errors.Is(err, ErrTopUpNotFound) && !errors.Is(err, ErrPaymentMethodMismatch) && !errors.Is(err, ErrTopUpStatusInvalid) {
@@ -272,7 +280,7 @@ func Recharge(referenceId string, customerId string, callerIp string) (err error
 		if err != nil || quota <= 0 {
 			return ErrInvalidTopUpQuota
 		}
-		return creditTopUpQuota(tx, topUp.UserId, quota, map[string]interface{}{
+		return creditTopUpQuota(tx, topUp, quota, map[string]interface{}{
 			"stripe_customer": customerId,
 		})
 	})
@@ -502,7 +510,7 @@ func ManualCompleteTopUp(tradeNo string, callerIp string) error {
 		}
 
 		// 增加用户额度（立即写库，保持一致性）
-		if err := creditTopUpQuota(tx, topUp.UserId, quotaToAdd, nil); err != nil {
+		if err := creditTopUpQuota(tx, topUp, quotaToAdd, nil); err != nil {
 			return err
 		}
 
@@ -579,7 +587,7 @@ func RechargeCreem(referenceId string, customerEmail string, customerName string
 			}
 		}
 
-		return creditTopUpQuota(tx, topUp.UserId, quota, updateFields)
+		return creditTopUpQuota(tx, topUp, quota, updateFields)
 	})
 
 	if err != nil {
@@ -637,7 +645,7 @@ func RechargeWaffo(tradeNo string, callerIp string) (err error) {
 			return err
 		}
 
-		return creditTopUpQuota(tx, topUp.UserId, quotaToAdd, nil)
+		return creditTopUpQuota(tx, topUp, quotaToAdd, nil)
 	})
 
 	if err != nil {
@@ -697,7 +705,7 @@ func RechargeWaffoPancake(tradeNo string) (err error) {
 			return err
 		}
 
-		return creditTopUpQuota(tx, topUp.UserId, quotaToAdd, nil)
+		return creditTopUpQuota(tx, topUp, quotaToAdd, nil)
 	})
 
 	if err != nil {
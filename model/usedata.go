@@ -20,9 +20,13 @@ type QuotaData struct {
 	TokenID   int    `json:"token_id" gorm:"index;default:0"`
 	ChannelID int    `json:"channel_id" gorm:"index;default:0"`
 	NodeName  string `json:"node_name" gorm:"index;size:64;default:''"`
+	Project   string `json:"project" gorm:"index;size:64;default:''"`
 	TokenUsed int    `json:"token_used" gorm:"default:0"`
 	Count     int    `json:"count" gorm:"default:0"`
 	Quota     int    `json:"quota" gorm:"default:0"`
+	// UpstreamCost 上游成本合计；CostedQuota 为其中已统计成本的请求的扣费合计，用于计算毛利
+	UpstreamCost int `json:"upstream_cost" gorm:"default:0"`
+	CostedQuota  int `json:"costed_quota" gorm:"default:0"`
 }
 
 type QuotaDataLogParams struct {
@@ -36,6 +40,10 @@ type QuotaDataLogParams struct {
 	TokenID   int
 	ChannelID int
 	NodeName  string
+	Project   string
+	// UpstreamCost 仅在 CostTracked 为 true 时计入成本汇总
+	UpstreamCost int
+	CostTracked  bool
 }
 
 func UpdateQuotaData() {
@@ -52,7 +60,7 @@ var CacheQuotaData = make(map[string]*QuotaData)
 var CacheQuotaDataLock = sync.Mutex{}
 
 func logQuotaDataCache(quotaData *QuotaData) {
-	key := fmt.Sprintf("%d\x00%s\x00%s\x00%d\x00%s\x00%d\x00%d\x00%s",
+	key := fmt.Sprintf("%d\x00%s\x00%s\x00%d\x00%s\x00%d\x00%d\x00%s\x00%s",
 		quotaData.UserID,
 		quotaData.Username,
 		quotaData.ModelName,
@@ -61,6 +69,7 @@ func logQuotaDataCache(quotaData *QuotaData) {
 		quotaData.TokenID,
 		quotaData.ChannelID,
 		quotaData.NodeName,
+		quotaData.Project,
 	)
 	count := quotaData.Count
 	quota := quotaData.Quota
@@ -70,6 +79,8 @@ func logQuotaDataCache(quotaData *QuotaData) {
 		cachedQuotaData.Count += count
 		cachedQuotaData.Quota += quota
 		cachedQuotaData.TokenUsed += tokenUsed
+		cachedQuotaData.UpstreamCost += quotaData.UpstreamCost
+		cachedQuotaData.CostedQuota += quotaData.CostedQuota
 		quotaData = cachedQuotaData
 	}
 	CacheQuotaData[key] = quotaData
@@ -87,10 +98,15 @@ func LogQuotaData(params QuotaDataLogParams) {
 		TokenID:   params.TokenID,
 		ChannelID: params.ChannelID,
 		NodeName:  params.NodeName,
+		Project:   params.Project,
 		Count:     1,
 		Quota:     params.Quota,
 		TokenUsed: params.TokenUsed,
 	}
+	if params.CostTracked {
+		quotaData.UpstreamCost = params.UpstreamCost
+		quotaData.CostedQuota = params.Quota
+	}
 
 	CacheQuotaDataLock.Lock()
 	defer CacheQuotaDataLock.Unlock()
@@ -108,8 +124,8 @@ func SaveQuotaDataCache() {
 	for _, quotaData := range CacheQuotaData {
 		quotaDataDB := &QuotaData{}
 		DB.Table("quota_data").
-			Where("user_id = ? and username = ? and model_name = ? and created_at = ? and use_group = ? and token_id = ? and channel_id = ? and node_name = ?",
-				quotaData.UserID, quotaData.Username, quotaData.ModelName, quotaData.CreatedAt, quotaData.UseGroup, quotaData.TokenID, quotaData.ChannelID, quotaData.NodeName).
+			Where("user_id = ? and username = ? and model_name = ? and created_at = ? and use_group = ? and token_id = ? and channel_id = ? and node_name = ? and project = ?",
+				quotaData.UserID, quotaData.Username, quotaData.ModelName, quotaData.CreatedAt, quotaData.UseGroup, quotaData.TokenID, quotaData.ChannelID, quotaData.NodeName, quotaData.Project).
 			First(quotaDataDB)
 		if quotaDataDB.Id > 0 {
 			//quotaDataDB.Count += quotaData.Count
@@ -126,12 +142,14 @@ func SaveQuotaDataCache() {
 
 func increaseQuotaData(quotaData *QuotaData) {
 	err := DB.Table("quota_data").
-		Where("user_id = ? and username = ? and model_name = ? and created_at = ? and use_group = ? and token_id = ? and channel_id = ? and node_name = ?",
-			quotaData.UserID, quotaData.Username, quotaData.ModelName, quotaData.CreatedAt, quotaData.UseGroup, quotaData.TokenID, quotaData.ChannelID, quotaData.NodeName).
+		Where("user_id = ? and username = ? and model_name = ? and created_at = ? and use_group = ? and token_id = ? and channel_id = ? and node_name = ? and project = ?",
+			quotaData.UserID, quotaData.Username, quotaData.ModelName, quotaData.CreatedAt, quotaData.UseGroup, quotaData.TokenID, quotaData.ChannelID, quotaData.NodeName, quotaData.Project).
 		Updates(map[string]interface{}{
-			"count":      gorm.Expr("count + ?", quotaData.Count),
-			"quota":      gorm.Expr("quota + ?", quotaData.Quota),
-			"token_used": gorm.Expr("token_used + ?", quotaData.TokenUsed),
+			"count":         gorm.Expr("count + ?", quotaData.Count),
+			"quota":         gorm.Expr("quota + ?", quotaData.Quota),
+			"token_used":    gorm.Expr("token_used + ?", quotaData.TokenUsed),
+			"upstream_cost": gorm.Expr("upstream_cost + ?", quotaData.UpstreamCost),
+			"costed_quota":  gorm.Expr("costed_quota + ?", quotaData.CostedQuota),
 		}).Error
 	if err != nil {
 		common.SysLog(fmt.Sprintf("increaseQuotaData error: %s", err))
@@ -181,3 +199,132 @@ func GetAllQuotaDates(startTime int64, endTime int64, username string) (quotaDat
 	err = DB.Table("quota_data").Select("model_name, sum(count) as count, sum(quota) as quota, sum(token_used) as token_used, created_at").Where("created_at >= ? and created_at <= ?", startTime, endTime).Group("model_name, created_at").Find(&quotaDatas).Error
 	return quotaDatas, err
 }
+
+// ProjectUsageFilter 成本归属报表过滤条件；UserID 非 0 时只统计该用户的数据。
+type ProjectUsageFilter struct {
+	StartTime int64
+	EndTime   int64
+	UserID    int
+	Username  string
+	Project   string
+	ModelName string
+	TokenID   int
+}
+
+// ProjectUsage 按项目、模型、令牌、天聚合的用量，Day 为当天 00:00（UTC）的时间戳。
+type ProjectUsage struct {
+	Day       int64  `json:"day" gorm:"column:day"`
+	Project   string `json:"project" gorm:"column:project"`
+	ModelName string `json:"model_name" gorm:"column:model_name"`
+	TokenID   int    `json:"token_id" gorm:"column:token_id"`
+	TokenName string `json:"token_name" gorm:"-"`
+	Count     int    `json:"count" gorm:"column:count"`
+	TokenUsed int    `json:"token_used" gorm:"column:token_used"`
+	Quota     int    `json:"quota" gorm:"column:quota"`
+}
+
+// GetProjectUsageReport 基于 quota_data 小时汇总生成按天的项目成本报表，
+// 需开启数据看板（DataExportEnabled）才会有数据。
+func GetProjectUsageReport(filter ProjectUsageFilter) ([]*ProjectUsage, error) {
+	rows := make([]*ProjectUsage, 0)
+	query := DB.Table("quota_data").
+		Select("created_at - (created_at % 86400) as day, project, model_name, token_id, sum(count) as count, sum(token_used) as token_used, sum(quota) as quota").
+		Where("created_at >= ? and created_at <= ?", filter.StartTime, filter.EndTime)
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Username != "" {
+		query = query.Where("username = ?", filter.Username)
+	}
+	if filter.Project != "" {
+		query = query.Where("project = ?", filter.Project)
+	}
+	if filter.ModelName != "" {
+		query = query.Where("model_name = ?", filter.ModelName)
+	}
+	if filter.TokenID != 0 {
+		query = query.Where("token_id = ?", filter.TokenID)
+	}
+	err := query.Group("created_at - (created_at % 86400), project, model_name, token_id").
+		Order("day asc, project asc, model_name asc, token_id asc").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, fillProjectUsageTokenNames(rows)
+}
+
+func fillProjectUsageTokenNames(rows []*ProjectUsage) error {
+	flowRows := make([]*FlowQuotaData, len(rows))
+	for i, row := range rows {
+		flowRows[i] = &FlowQuotaData{TokenID: row.TokenID}
+	}
+	if err := fillFlowTokenNames(flowRows); err != nil {
+		return err
+	}
+	for i, row := range rows {
+		row.TokenName = flowRows[i].TokenName
+	}
+	return nil
+}
+
+// ChannelMarginFilter 渠道毛利报表过滤条件
+type ChannelMarginFilter struct {
+	StartTime int64
+	EndTime   int64
+	ChannelID int
+	ModelName string
+}
+
+// ChannelMargin 按渠道、模型、天聚合的收入与上游成本。Quota 为全部请求的扣费（收入），
+// CostedQuota 为已统计成本请求的扣费，Margin = CostedQuota - UpstreamCost。
+type ChannelMargin struct {
+	Day          int64  `json:"day" gorm:"column:day"`
+	ChannelID    int    `json:"channel_id" gorm:"column:channel_id"`
+	ChannelName  string `json:"channel_name" gorm:"-"`
+	ModelName    string `json:"model_name" gorm:"column:model_name"`
+	Count        int    `json:"count" gorm:"column:count"`
+	Quota        int    `json:"quota" gorm:"column:quota"`
+	CostedQuota  int    `json:"costed_quota" gorm:"column:costed_quota"`
+	UpstreamCost int    `json:"upstream_cost" gorm:"column:upstream_cost"`
+	Margin       int    `json:"margin" gorm:"-"`
+}
+
+// GetChannelMarginReport 基于 quota_data 生成按天的渠道收入、成本与毛利报表，
+// 需开启数据看板（DataExportEnabled）才会有数据。
+func GetChannelMarginReport(filter ChannelMarginFilter) ([]*ChannelMargin, error) {
+	rows := make([]*ChannelMargin, 0)
+	query := DB.Table("quota_data").
+		Select("created_at - (created_at % 86400) as day, channel_id, model_name, sum(count) as count, sum(quota) as quota, sum(costed_quota) as costed_quota, sum(upstream_cost) as upstream_cost").
+		Where("created_at >= ? and created_at <= ?", filter.StartTime, filter.EndTime)
+	if filter.ChannelID != 0 {
+		query = query.Where("channel_id = ?", filter.ChannelID)
+	}
+	if filter.ModelName != "" {
+		query = query.Where("model_name = ?", filter.ModelName)
+	}
+	err := query.Group("created_at - (created_at % 86400), channel_id, model_name").
+		Order("day asc, channel_id asc, model_name asc").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		row.Margin = row.CostedQuota - row.UpstreamCost
+	}
+	return rows, fillChannelMarginNames(rows)
+}
+
+func fillChannelMarginNames(rows []*ChannelMargin) error {
+	flowRows := make([]*FlowQuotaData, len(rows))
+	for i, row := range rows {
+		flowRows[i] = &FlowQuotaData{ChannelID: row.ChannelID}
+	}
+	if err := fillFlowChannelNames(flowRows); err != nil {
+		return err
+	}
+	for i, row := range rows {
+		row.ChannelName = flowRows[i].ChannelName
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogQuotaDataAccumulatesUpstreamCostOnlyWhenTracked(t *testing.T) {
+	truncateTables(t)
+	CacheQuotaDataLock.Lock()
+	CacheQuotaData = make(map[string]*QuotaData)
+	CacheQuotaDataLock.Unlock()
+
+	base := QuotaDataLogParams{UserID: 1, Username: "alice", ModelName: "gpt-a", CreatedAt: 3700, ChannelID: 5, Quota: 100}
+	LogQuotaData(base)
+	base.UpstreamCost = 60
+	base.CostTracked = true
+	LogQuotaData(base)
+	SaveQuotaDataCache()
+
+	var rows []QuotaData
+	require.NoError(t, DB.Find(&rows).Error)
+	require.Len(t, rows, 1)
+	assert.Equal(t, 200, rows[0].Quota)
+	assert.Equal(t, 100, rows[0].CostedQuota)
+	assert.Equal(t, 60, rows[0].UpstreamCost)
+
+	LogQuotaData(base)
+	SaveQuotaDataCache()
+	require.NoError(t, DB.Find(&rows).Error)
+	assert.Equal(t, 200, rows[0].CostedQuota)
+	assert.Equal(t, 120, rows[0].UpstreamCost)
+}
+
+func TestGetChannelMarginReportGroupsByChannelModelAndDay(t *testing.T) {
+	truncateTables(t)
+	require.NoError(t, DB.Create(&Channel{Id: 5, Name: "cheap-upstream", Key: "sk-5"}).Error)
+
+	const day1 = 86400 * 20000
+	const day2 = day1 + 86400
+	seedFlowQuotaData(t, QuotaData{UserID: 1, Username: "alice", ModelName: "gpt-a", CreatedAt: day1 + 3600, ChannelID: 5, Count: 2, Quota: 100, CostedQuota: 100, UpstreamCost: 70})
+	seedFlowQuotaData(t, QuotaData{UserID: 2, Username: "bob", ModelName: "gpt-a", CreatedAt: day1 + 7200, ChannelID: 5, Count: 1, Quota: 50})
+	seedFlowQuotaData(t, QuotaData{UserID: 1, Username: "alice", ModelName: "gpt-a", CreatedAt: day2, ChannelID: 5, Count: 1, Quota: 30, CostedQuota: 30, UpstreamCost: 40})
+	seedFlowQuotaData(t, QuotaData{UserID: 1, Username: "alice", ModelName: "gpt-b", CreatedAt: day1, ChannelID: 6, Count: 1, Quota: 9, CostedQuota: 9, UpstreamCost: 3})
+
+	rows, err := GetChannelMarginReport(ChannelMarginFilter{StartTime: day1, EndTime: day2 + 3600, ChannelID: 5})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.EqualValues(t, day1, rows[0].Day)
+	assert.Equal(t, "cheap-upstream", rows[0].ChannelName)
+	assert.Equal(t, 3, rows[0].Count)
+	assert.Equal(t, 150, rows[0].Quota)
+	assert.Equal(t, 100, rows[0].CostedQuota)
+	assert.Equal(t, 30, rows[0].Margin)
+	assert.Equal(t, -10, rows[1].Margin)
+
+	rows, err = GetChannelMarginReport(ChannelMarginFilter{StartTime: day1, EndTime: day2 + 3600})
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+}
+
+func TestFilterCheapestChannelsPrefersLowestUpstreamCostRatio(t *testing.T) {
+	channelSyncLock.Lock()
+	prev := channel2upstreamCostRatio
+	channel2upstreamCostRatio = map[int]float64{1: 0.8, 2: 0.5, 3: 0.5}
+	channelSyncLock.Unlock()
+	t.Cleanup(func() {
+		channelSyncLock.Lock()
+		channel2upstreamCostRatio = prev
+		channelSyncLock.Unlock()
+	})
+
+	channelSyncLock.RLock()
+	defer channelSyncLock.RUnlock()
+	cheapest := filterCheapestChannels([]*Channel{{Id: 1}, {Id: 2}, {Id: 3}, {Id: 4}})
+	require.Len(t, cheapest, 2)
+	assert.Equal(t, 2, cheapest[0].Id)
+	assert.Equal(t, 3, cheapest[1].Id)
+
+	unknown := filterCheapestChannels([]*Channel{{Id: 4}, {Id: 5}})
+	assert.Len(t, unknown, 2)
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetProjectUsageReportGroupsByProjectModelTokenAndDay(t *testing.T) {
+	truncateTables(t)
+	require.NoError(t, DB.Create(&Token{Id: 31, UserId: 1, Key: "sk-ci", Name: "ci-bot"}).Error)
+
+	const day1 = 86400 * 20000
+	const day2 = day1 + 86400
+	seedFlowQuotaData(t, QuotaData{UserID: 1, Username: "alice", ModelName: "gpt-a", CreatedAt: day1 + 3600, TokenID: 31, Project: "search", Count: 2, Quota: 100, TokenUsed: 40})
+	seedFlowQuotaData(t, QuotaData{UserID: 1, Username: "alice", ModelName: "gpt-a", CreatedAt: day1 + 7200, TokenID: 31, Project: "search", NodeName: "node-b", Count: 1, Quota: 50, TokenUsed: 10})
+	seedFlowQuotaData(t, QuotaData{UserID: 1, Username: "alice", ModelName: "gpt-a", CreatedAt: day2, TokenID: 31, Project: "search", Count: 1, Quota: 30, TokenUsed: 5})
+	seedFlowQuotaData(t, QuotaData{UserID: 1, Username: "alice", ModelName: "gpt-a", CreatedAt: day1, TokenID: 31, Project: "ads", Count: 1, Quota: 70, TokenUsed: 7})
+	seedFlowQuotaData(t, QuotaData{UserID: 2, Username: "bob", ModelName: "gpt-b", CreatedAt: day1, TokenID: 32, Project: "search", Count: 1, Quota: 9, TokenUsed: 1})
+
+	rows, err := GetProjectUsageReport(ProjectUsageFilter{StartTime: day1, EndTime: day2 + 3600, UserID: 1})
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, "ads", rows[0].Project)
+	assert.EqualValues(t, day1, rows[1].Day)
+	assert.Equal(t, "search", rows[1].Project)
+	assert.Equal(t, 150, rows[1].Quota)
+	assert.Equal(t, 3, rows[1].Count)
+	assert.Equal(t, "ci-bot", rows[1].TokenName)
+	assert.EqualValues(t, day2, rows[2].Day)
+
+	rows, err = GetProjectUsageReport(ProjectUsageFilter{StartTime: day1, EndTime: day2 + 3600, Project: "search"})
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+}
+
+func TestLogQuotaDataSplitsRowsByProject(t *testing.T) {
+	truncateTables(t)
+	CacheQuotaDataLock.Lock()
+	CacheQuotaData = make(map[string]*QuotaData)
+	CacheQuotaDataLock.Unlock()
+
+	base := QuotaDataLogParams{UserID: 1, Username: "alice", ModelName: "gpt-a", CreatedAt: 3700, TokenID: 11, Quota: 10}
+	base.Project = "search"
+	LogQuotaData(base)
+	LogQuotaData(base)
+	base.Project = "ads"
+	LogQuotaData(base)
+	SaveQuotaDataCache()
+
+	var rows []QuotaData
+	require.NoError(t, DB.Order("quota DESC").Find(&rows).Error)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "search", rows[0].Project)
+	assert.Equal(t, 20, rows[0].Quota)
+	assert.Equal(t, "ads", rows[1].Project)
+}
+
+func TestNormalizeAllowedProjects(t *testing.T) {
+	normalized, err := NormalizeAllowedProjects(" search \nads,search\n\n*")
+	require.NoError(t, err)
+	assert.Equal(t, "search\nads\n*", normalized)
+
+	_, err = NormalizeAllowedProjects("bad project")
+	assert.Error(t, err)
+
+	assert.True(t, IsProjectAllowed("search\nads", "ads"))
+	assert.False(t, IsProjectAllowed("search", "ads"))
+	assert.False(t, IsProjectAllowed("", "ads"))
+	assert.True(t, IsProjectAllowed("*", "ads"))
+}
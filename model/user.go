@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -109,21 +110,27 @@ type User struct {
 	CreatedAt        int64                      `json:"created_at" gorm:"autoCreateTime;column:created_at"`
 	LastLoginAt      int64                      `json:"last_login_at" gorm:"default:0;column:last_login_at"`
 	AuthVersion      int64                      `json:"-" gorm:"type:bigint;not null;default:1;column:auth_version"`
+	CreditLimit      int                        `json:"credit_limit" gorm:"type:int;default:0;column:credit_limit"`     // 后付费信用额度，余额可透支至 -CreditLimit
+	DunningStage     int                        `json:"dunning_stage" gorm:"type:int;default:0;column:dunning_stage"`   // 后付费催缴阶段，见 PostpaidStage*
+	ResellerId       int                        `json:"reseller_id" gorm:"type:int;default:0;index;column:reseller_id"` // 所属分销商的用户 ID，0 表示直属用户
 	AdminPermissions map[string]map[string]bool `json:"admin_permissions,omitempty" gorm:"-:all"`
 }
 
 func (user *User) ToBaseUser() *UserBase {
 	cache := &UserBase{
-		Id:          user.Id,
-		Group:       user.Group,
-		Quota:       user.Quota,
-		Status:      user.Status,
-		Role:        user.Role,
-		Username:    user.Username,
-		Setting:     user.Setting,
-		Email:       user.Email,
-		AuthVersion: user.AuthVersion,
-		CacheSchema: userCacheSchemaVersion,
+		Id:           user.Id,
+		Group:        user.Group,
+		Quota:        user.Quota,
+		Status:       user.Status,
+		Role:         user.Role,
+		Username:     user.Username,
+		Setting:      user.Setting,
+		Email:        user.Email,
+		AuthVersion:  user.AuthVersion,
+		CreditLimit:  user.CreditLimit,
+		DunningStage: user.DunningStage,
+		ResellerId:   user.ResellerId,
+		CacheSchema:  userCacheSchemaVersion,
 	}
 	return cache
 }
@@ -190,6 +197,55 @@ func UpdateUserSetting(userId int, setting dto.UserSetting) error {
 	return updateUserSettingCache(userId, settingValue)
 }
 
+// EnsureUserTaskCallbackSecret 返回用户的任务回调签名密钥，未设置时生成并保存，
+// 保证任务回调始终带签名。该密钥与额度通知的 webhook 密钥分开保存，首次生成时沿用
+// 已配置的 webhook 密钥，以兼容此前以其验签的回调接收方。并发生成时以先写入者为准。
+func EnsureUserTaskCallbackSecret(userId int) (string, error) {
+	if setting, err := GetUserSetting(userId, false); err == nil && setting.TaskCallbackSecret != "" {
+		return setting.TaskCallbackSecret, nil
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		user := &User{}
+		if err := DB.Select("id", "setting").Where("id = ?", userId).First(user).Error; err != nil {
+			return "", err
+		}
+		setting := user.GetSetting()
+		if setting.TaskCallbackSecret != "" {
+			return setting.TaskCallbackSecret, nil
+		}
+		secret := setting.WebhookSecret
+		if secret == "" {
+			generated, err := common.GenerateRandomCharsKey(32)
+			if err != nil {
+				return "", err
+			}
+			secret = generated
+		}
+		setting.TaskCallbackSecret = secret
+		settingBytes, err := common.Marshal(setting)
+		if err != nil {
+			return "", err
+		}
+		query := DB.Model(&User{}).Where("id = ?", userId)
+		if user.Setting == "" {
+			query = query.Where("setting IS NULL OR setting = ''")
+		} else {
+			query = query.Where("setting = ?", user.Setting)
+		}
+		result := query.Update("setting", string(settingBytes))
+		if result.Error != nil {
+			return "", result.Error
+		}
+		if result.RowsAffected == 1 {
+			if err := updateUserSettingCache(userId, string(settingBytes)); err != nil {
+				common.SysLog("failed to update user setting cache: " + err.Error())
+			}
+			return secret, nil
+		}
+	}
+	return "", errors.New("task callback secret changed concurrently")
+}
+
 // userBindColumns 允许通过 UpdateUserBindColumn 更新的第三方账号绑定列白名单。
 // 列名只可能来自代码内部的 provider 实现，白名单是防御纵深，不依赖调用方自律。
 var userBindColumns = map[string]bool{
@@ -513,6 +569,15 @@ func GetUserIdByAffCode(affCode string) (int, error) {
 	return user.Id, err
 }
 
+func GetUserIdByUsername(username string) (int, error) {
+	if username == "" {
+		return 0, errors.New("username 为空！")
+	}
+	var user User
+	err := DB.Select("id").First(&user, "username = ?", username).Error
+	return user.Id, err
+}
+
 func DeleteUserById(id int) (err error) {
 	if id == 0 {
 		return errors.New("id 为空！")
@@ -568,6 +633,10 @@ func (user *User) TransferAffQuotaToQuota(quota int) error {
 		return errors.New("邀请额度不足！")
 	}
 
+	if err := PostCreditGrant(tx, user.Id, CreditSourceAffiliate, "", quota); err != nil {
+		return err
+	}
+
 	// 更新用户额度
 	user.AffQuota -= quota
 	user.Quota += quota
@@ -679,7 +748,7 @@ func (user *User) finishInsert(inviterId int) {
 	}
 	if inviterId != 0 && operation_setting.IsPaymentComplianceConfirmed() {
 		if common.QuotaForInvitee > 0 {
-			_ = IncreaseUserQuota(user.Id, common.QuotaForInvitee, true)
+			_ = GrantUserQuota(user.Id, common.QuotaForInvitee, CreditSourceAffiliate, "invitee")
 			RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("使用邀请码赠送 %s", logger.LogQuota(common.QuotaForInvitee)))
 		}
 		if common.QuotaForInviter > 0 {
@@ -736,7 +805,7 @@ func (user *User) FinalizeOAuthUserCreation(inviterId int) {
 	}
 	if inviterId != 0 && operation_setting.IsPaymentComplianceConfirmed() {
 		if common.QuotaForInvitee > 0 {
-			_ = IncreaseUserQuota(user.Id, common.QuotaForInvitee, true)
+			_ = GrantUserQuota(user.Id, common.QuotaForInvitee, CreditSourceAffiliate, "invitee")
 			RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("使用邀请码赠送 %s", logger.LogQuota(common.QuotaForInvitee)))
 		}
 		if common.QuotaForInviter > 0 {
@@ -1267,7 +1336,9 @@ func GetUserSetting(id int, fromDB bool) (settingMap dto.UserSetting, err error)
 	return userBase.GetSetting(), nil
 }
 
-func IncreaseUserQuota(id int, quota int, db bool) (err error) {
+// IncreaseUserQuota 增加用户钱包额度，ref 为账本对手方（退还记为对该对手方的冲回）。
+// 新增资金（充值、赠送、管理员加额度）应使用 GrantUserQuota。
+func IncreaseUserQuota(id int, quota int, db bool, ref CreditRef) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
@@ -1278,21 +1349,15 @@ func IncreaseUserQuota(id int, quota int, db bool) (err error) {
 		}
 	})
 	if !db && common.BatchUpdateEnabled {
-		addNewRecord(BatchUpdateTypeUserQuota, id, quota)
+		addUserQuotaRecord(id, quota, ref)
 		return nil
 	}
-	return increaseUserQuota(id, quota)
-}
-
-func increaseUserQuota(id int, quota int) (err error) {
-	err = DB.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", quota)).Error
-	if err != nil {
-		return err
-	}
+	_, err = applyUserQuotaDelta(id, quota, ref)
 	return err
 }
 
-func DecreaseUserQuota(id int, quota int, db bool) (err error) {
+// DecreaseUserQuota 扣减用户钱包额度，ref 为账本对手方。
+func DecreaseUserQuota(id int, quota int, db bool, ref CreditRef) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
 	}
@@ -1303,28 +1368,63 @@ func DecreaseUserQuota(id int, quota int, db bool) (err error) {
 		}
 	})
 	if !db && common.BatchUpdateEnabled {
-		addNewRecord(BatchUpdateTypeUserQuota, id, -quota)
+		addUserQuotaRecord(id, -quota, ref)
 		return nil
 	}
-	return decreaseUserQuota(id, quota)
+	_, err = applyUserQuotaDelta(id, -quota, ref)
+	return err
 }
 
-func decreaseUserQuota(id int, quota int) (err error) {
-	err = DB.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota - ?", quota)).Error
+// GrantUserQuota 以一笔新授予增加用户钱包额度，授予与额度变动在同一事务内落库。
+func GrantUserQuota(id int, quota int, source string, reference string) error {
+	if quota < 0 {
+		return errors.New("quota 不能为负数！")
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := PostCreditGrant(tx, id, source, reference, quota); err != nil {
+			return err
+		}
+		return tx.Model(&User{}).Where("id = ?", id).Update("quota", gorm.Expr("quota + ?", quota)).Error
+	})
 	if err != nil {
 		return err
 	}
-	return err
+	gopool.Go(func() {
+		if err := cacheIncrUserQuota(id, int64(quota)); err != nil {
+			common.SysLog("failed to increase user quota: " + err.Error())
+		}
+	})
+	return nil
 }
 
-func DeltaUpdateUserQuota(id int, delta int) (err error) {
+// OverrideUserQuota 将用户额度直接设置为 quota，差额记入额度账本。
+func OverrideUserQuota(id int, quota int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := lockForUpdate(tx).Select("id", "quota").Where("id = ?", id).First(&user).Error; err != nil {
+			return err
+		}
+		if delta := quota - user.Quota; delta > 0 {
+			if err := PostCreditGrant(tx, id, CreditSourceAdjustment, "override", delta); err != nil {
+				return err
+			}
+		} else if delta < 0 {
+			if err := PostCreditDebit(tx, id, CreditSourceAdjustment, "override", -delta); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&User{}).Where("id = ?", id).Update("quota", quota).Error
+	})
+}
+
+func DeltaUpdateUserQuota(id int, delta int, ref CreditRef) (err error) {
 	if delta == 0 {
 		return nil
 	}
 	if delta > 0 {
-		return IncreaseUserQuota(id, delta, false)
+		return IncreaseUserQuota(id, delta, false, ref)
 	} else {
-		return DecreaseUserQuota(id, -delta, false)
+		return DecreaseUserQuota(id, -delta, false, ref)
 	}
 }
 
@@ -1382,18 +1482,32 @@ func updateUserUsedQuotaAndRequestCount(id int, quota int, count int) {
 	//}
 }
 
-func updateUserQuotaUsedQuotaAndRequestCount(id int, quota int, usedQuota int, requestCount int) {
+// updateUserQuotaUsedQuotaAndRequestCount 落库批量累计的用户额度变动；credits 为按
+// 账本来源汇总的额度变动，与额度更新在同一事务内记账。
+func updateUserQuotaUsedQuotaAndRequestCount(id int, quota int, usedQuota int, requestCount int, credits map[string]int) {
 	if quota == 0 && usedQuota == 0 && requestCount == 0 {
 		return
 	}
 
-	err := DB.Model(&User{}).Where("id = ?", id).Updates(
-		map[string]interface{}{
-			"quota":         gorm.Expr("quota + ?", quota),
-			"used_quota":    gorm.Expr("used_quota + ?", usedQuota),
-			"request_count": gorm.Expr("request_count + ?", requestCount),
-		},
-	).Error
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		sources := make([]string, 0, len(credits))
+		for source := range credits {
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+		for _, source := range sources {
+			if err := PostCreditUsage(tx, id, CreditRef{Source: source, Reference: "batch"}, credits[source]); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&User{}).Where("id = ?", id).Updates(
+			map[string]interface{}{
+				"quota":         gorm.Expr("quota + ?", quota),
+				"used_quota":    gorm.Expr("used_quota + ?", usedQuota),
+				"request_count": gorm.Expr("request_count + ?", requestCount),
+			},
+		).Error
+	})
 	if err != nil {
 		common.SysLog("failed to batch update user quota, used quota and request count: " + err.Error())
 	}
@@ -11,19 +11,27 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-const userCacheSchemaVersion = 2
+const userCacheSchemaVersion = 4
 
 type UserBase struct {
-	Id          int    `json:"id"`
-	Group       string `json:"group"`
-	Email       string `json:"email"`
-	Quota       int    `json:"quota"`
-	Status      int    `json:"status"`
-	Role        int    `json:"role"`
-	Username    string `json:"username"`
-	Setting     string `json:"setting"`
-	AuthVersion int64  `json:"-"`
-	CacheSchema int    `json:"-"`
+	Id           int    `json:"id"`
+	Group        string `json:"group"`
+	Email        string `json:"email"`
+	Quota        int    `json:"quota"`
+	Status       int    `json:"status"`
+	Role         int    `json:"role"`
+	Username     string `json:"username"`
+	Setting      string `json:"setting"`
+	AuthVersion  int64  `json:"-"`
+	CreditLimit  int    `json:"-"`
+	DunningStage int    `json:"-"`
+	ResellerId   int    `json:"-"`
+	CacheSchema  int    `json:"-"`
+}
+
+// AvailableQuota 可用于预扣的额度：钱包余额加上未被催缴停用的后付费信用额度
+func (user *UserBase) AvailableQuota() int {
+	return user.Quota + effectiveCreditLimit(user.CreditLimit, user.DunningStage)
 }
 
 func (user *UserBase) WriteContext(c *gin.Context) {
@@ -33,6 +41,8 @@ func (user *UserBase) WriteContext(c *gin.Context) {
 	common.SetContextKey(c, constant.ContextKeyUserEmail, user.Email)
 	common.SetContextKey(c, constant.ContextKeyUserName, user.Username)
 	common.SetContextKey(c, constant.ContextKeyUserSetting, user.GetSetting())
+	common.SetContextKey(c, constant.ContextKeyUserDunningStage, user.DunningStage)
+	common.SetContextKey(c, constant.ContextKeyUserResellerId, user.ResellerId)
 }
 
 func (user *UserBase) GetSetting() dto.UserSetting {
@@ -7,6 +7,11 @@ import (
 	"gorm.io/gorm"
 )
 
+// SAMLOAuthProviderId is the provider_id of bindings created by SAML single
+// sign-on. Custom OAuth providers use auto-increment IDs, so a negative value
+// cannot collide with them.
+const SAMLOAuthProviderId = -1
+
 // UserOAuthBinding stores the binding relationship between users and custom OAuth providers
 type UserOAuthBinding struct {
 	Id             int       `json:"id" gorm:"primaryKey"`
@@ -23,6 +23,11 @@ const (
 var batchUpdateStores []map[int]int
 var batchUpdateLocks []sync.Mutex
 
+// batchCreditStore 按用户与账本来源累计批量模式下的钱包额度变动，落库时与
+// BatchUpdateTypeUserQuota 在同一事务内记账。
+var batchCreditStore = make(map[int]map[string]int)
+var batchCreditLock sync.Mutex
+
 func init() {
 	for i := 0; i < BatchUpdateTypeCount; i++ {
 		batchUpdateStores = append(batchUpdateStores, make(map[int]int))
@@ -49,6 +54,17 @@ func addNewRecord(type_ int, id int, value int) {
 	}
 }
 
+// addUserQuotaRecord 批量模式下累计一笔钱包额度变动及其账本来源。
+func addUserQuotaRecord(id int, delta int, ref CreditRef) {
+	batchCreditLock.Lock()
+	defer batchCreditLock.Unlock()
+	addNewRecord(BatchUpdateTypeUserQuota, id, delta)
+	if batchCreditStore[id] == nil {
+		batchCreditStore[id] = make(map[string]int)
+	}
+	batchCreditStore[id][ref.Source] += delta
+}
+
 func batchUpdate() {
 	// check if there's any data to update
 	hasData := false
@@ -68,12 +84,16 @@ func batchUpdate() {
 
 	common.SysLog("batch update started")
 	stores := make([]map[int]int, BatchUpdateTypeCount)
+	batchCreditLock.Lock()
 	for i := 0; i < BatchUpdateTypeCount; i++ {
 		batchUpdateLocks[i].Lock()
 		stores[i] = batchUpdateStores[i]
 		batchUpdateStores[i] = make(map[int]int)
 		batchUpdateLocks[i].Unlock()
 	}
+	creditStore := batchCreditStore
+	batchCreditStore = make(map[int]map[string]int)
+	batchCreditLock.Unlock()
 
 	for i, store := range stores {
 		if i == BatchUpdateTypeUserQuota || i == BatchUpdateTypeUsedQuota || i == BatchUpdateTypeRequestCount {
@@ -107,7 +127,7 @@ func batchUpdate() {
 		userIDs[key] = struct{}{}
 	}
 	for key := range userIDs {
-		updateUserQuotaUsedQuotaAndRequestCount(key, userQuotaStore[key], usedQuotaStore[key], requestCountStore[key])
+		updateUserQuotaUsedQuotaAndRequestCount(key, userQuotaStore[key], usedQuotaStore[key], requestCountStore[key], creditStore[key])
 	}
 	common.SysLog("batch update finished")
 }
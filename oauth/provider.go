@@ -40,3 +40,11 @@ type Provider interface {
 	// (e.g. the user_oauth_bindings table) return an empty string.
 	ProviderUserIDColumn() string
 }
+
+// BindingTableProvider is implemented by providers whose account bindings are
+// stored in the user_oauth_bindings table under a provider ID rather than in
+// a users-table column (custom OAuth providers and SAML).
+type BindingTableProvider interface {
+	Provider
+	GetProviderId() int
+}
@@ -0,0 +1,204 @@
+package oauth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/saml"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	Register("saml", &SAMLProvider{})
+}
+
+// samlTicketTTL bounds the gap between the ACS redirect and the frontend
+// calling back into HandleOAuth.
+const samlTicketTTL = 5 * time.Minute
+
+// SAMLProvider adapts SAML single sign-on to the OAuth callback flow. The
+// browser is sent to the IdP by /api/saml/login; the ACS endpoint validates
+// the assertion, stores the mapped identity as a one-time ticket and
+// redirects to /oauth/saml?code=<ticket>&state=<state>, so HandleOAuth
+// finishes login and bind exactly as for other providers. Bindings live in
+// user_oauth_bindings under model.SAMLOAuthProviderId.
+type SAMLProvider struct{}
+
+type samlTicket struct {
+	State       string `json:"state"`
+	NameID      string `json:"name_id"`
+	Username    string `json:"username,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	Email       string `json:"email,omitempty"`
+	Group       string `json:"group,omitempty"`
+}
+
+// NewSAMLServiceProvider builds the SP from the current settings. Explicit
+// IdP fields override values read from the pasted IdP metadata.
+func NewSAMLServiceProvider() (*saml.ServiceProvider, error) {
+	settings := system_setting.GetSAMLSettings()
+	sp := &saml.ServiceProvider{
+		EntityID:     settings.GetEffectiveSPEntityId(),
+		ACSURL:       settings.GetACSURL(),
+		NameIDFormat: strings.TrimSpace(settings.NameIDFormat),
+	}
+	if strings.TrimSpace(settings.IdPMetadata) != "" {
+		idp, err := saml.ParseIdPMetadata([]byte(settings.IdPMetadata))
+		if err != nil {
+			return nil, err
+		}
+		sp.IdP = *idp
+	}
+	if entityId := strings.TrimSpace(settings.IdPEntityId); entityId != "" {
+		sp.IdP.EntityID = entityId
+	}
+	if ssoURL := strings.TrimSpace(settings.IdPSSOURL); ssoURL != "" {
+		sp.IdP.SSOURL = ssoURL
+	}
+	if strings.TrimSpace(settings.IdPCertificate) != "" {
+		certificates, err := saml.ParseCertificates(settings.IdPCertificate)
+		if err != nil {
+			return nil, err
+		}
+		sp.IdP.Certificates = certificates
+	}
+	if strings.TrimSpace(settings.SPCertificate) != "" {
+		certificates, err := saml.ParseCertificates(settings.SPCertificate)
+		if err != nil {
+			return nil, err
+		}
+		sp.Certificate = certificates[0]
+	}
+	if strings.TrimSpace(settings.SPPrivateKey) != "" {
+		key, err := saml.ParsePrivateKey(settings.SPPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		sp.PrivateKey = key
+	}
+	return sp, nil
+}
+
+// CreateSAMLTicket maps a validated assertion onto the local user fields and
+// stores it as a one-time code bound to the OAuth state.
+func CreateSAMLTicket(state string, assertion *saml.Assertion) (string, error) {
+	settings := system_setting.GetSAMLSettings()
+	ticket := samlTicket{
+		State:  state,
+		NameID: assertion.NameID,
+	}
+	if name := strings.TrimSpace(settings.UsernameAttribute); name != "" {
+		ticket.Username = assertion.Attribute(name)
+	}
+	if name := strings.TrimSpace(settings.EmailAttribute); name != "" {
+		ticket.Email = assertion.Attribute(name)
+	}
+	if ticket.Email == "" && assertion.NameIDFormat == saml.NameIDFormatEmailAddress {
+		ticket.Email = assertion.NameID
+	}
+	if name := strings.TrimSpace(settings.DisplayNameAttribute); name != "" {
+		ticket.DisplayName = assertion.Attribute(name)
+	}
+	if name := strings.TrimSpace(settings.GroupAttribute); name != "" {
+		ticket.Group = settings.MapGroup(assertion.Attributes[name])
+	}
+	payload, err := common.Marshal(ticket)
+	if err != nil {
+		return "", err
+	}
+	code, _, err := model.CreateAuthFlow(model.AuthFlowCreate{
+		Purpose:   model.AuthFlowPurposeSAMLTicket,
+		Provider:  "saml",
+		Payload:   string(payload),
+		ExpiresAt: time.Now().Add(samlTicketTTL),
+	})
+	return code, err
+}
+
+func (p *SAMLProvider) GetName() string {
+	return system_setting.GetSAMLSettings().GetEffectiveDisplayName()
+}
+
+func (p *SAMLProvider) IsEnabled() bool {
+	return system_setting.GetSAMLSettings().Enabled
+}
+
+// ExchangeToken redeems the ticket created by the ACS endpoint. The ticket
+// only works together with the state it was issued for.
+func (p *SAMLProvider) ExchangeToken(ctx context.Context, code string, c *gin.Context) (*OAuthToken, error) {
+	if code == "" {
+		return nil, NewOAuthError(i18n.MsgOAuthInvalidCode, nil)
+	}
+	flow, err := model.ConsumeAuthFlow(code, model.AuthFlowMatch{
+		Purpose:  model.AuthFlowPurposeSAMLTicket,
+		Provider: "saml",
+	})
+	if err != nil {
+		return nil, NewOAuthErrorWithRaw(i18n.MsgOAuthInvalidCode, nil, err.Error())
+	}
+	var ticket samlTicket
+	if err := common.UnmarshalJsonStr(flow.Payload, &ticket); err != nil {
+		return nil, err
+	}
+	if ticket.State == "" || ticket.State != c.Query("state") {
+		return nil, NewOAuthError(i18n.MsgOAuthStateInvalid, nil)
+	}
+	// The validated identity travels to GetUserInfo in place of an access token.
+	return &OAuthToken{AccessToken: flow.Payload, TokenType: "saml"}, nil
+}
+
+func (p *SAMLProvider) GetUserInfo(ctx context.Context, token *OAuthToken) (*OAuthUser, error) {
+	var ticket samlTicket
+	if err := common.UnmarshalJsonStr(token.AccessToken, &ticket); err != nil {
+		return nil, err
+	}
+	if ticket.NameID == "" {
+		return nil, NewOAuthError(i18n.MsgOAuthUserInfoEmpty, map[string]any{"Provider": p.GetName()})
+	}
+	user := &OAuthUser{
+		ProviderUserID: ticket.NameID,
+		Username:       ticket.Username,
+		DisplayName:    ticket.DisplayName,
+		Email:          ticket.Email,
+		Extra:          map[string]any{},
+	}
+	if ticket.Group != "" {
+		user.Extra["group"] = ticket.Group
+	}
+	return user, nil
+}
+
+func (p *SAMLProvider) IsUserIDTaken(providerUserID string) bool {
+	return model.IsProviderUserIdTaken(model.SAMLOAuthProviderId, providerUserID)
+}
+
+func (p *SAMLProvider) FillUserByProviderID(user *model.User, providerUserID string) error {
+	foundUser, err := model.GetUserByOAuthBinding(model.SAMLOAuthProviderId, providerUserID)
+	if err != nil {
+		return err
+	}
+	*user = *foundUser
+	return nil
+}
+
+func (p *SAMLProvider) SetProviderUserID(user *model.User, providerUserID string) {
+	// Stored in user_oauth_bindings by the OAuth controller.
+}
+
+func (p *SAMLProvider) GetProviderPrefix() string {
+	return "saml_"
+}
+
+func (p *SAMLProvider) ProviderUserIDColumn() string {
+	return ""
+}
+
+// GetProviderId returns the provider ID for binding purposes
+func (p *SAMLProvider) GetProviderId() int {
+	return model.SAMLOAuthProviderId
+}
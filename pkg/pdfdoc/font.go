@@ -0,0 +1,181 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// unicodeFont is an embedded TrueType font drawn with Identity-H encoding, so
+// text is written as glyph ids. The whole font file is embedded; glyph widths
+// and the ToUnicode map only cover the glyphs actually drawn.
+type unicodeFont struct {
+	data   []byte
+	font   *sfnt.Font
+	buf    sfnt.Buffer
+	name   string
+	widths map[sfnt.GlyphIndex]int
+	runes  map[sfnt.GlyphIndex]rune
+}
+
+// SetUnicodeFont embeds a TrueType font used for text that the standard
+// fonts cannot encode. Only TrueType outlines (.ttf) are supported; OpenType
+// CFF fonts and font collections are rejected.
+func (d *Document) SetUnicodeFont(data []byte) error {
+	if len(data) >= 4 && (string(data[:4]) == "OTTO" || string(data[:4]) == "ttcf") {
+		return errors.New("pdfdoc: only single TrueType (.ttf) fonts can be embedded")
+	}
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return fmt.Errorf("pdfdoc: parse font: %w", err)
+	}
+	u := &unicodeFont{
+		data:   data,
+		font:   f,
+		widths: map[sfnt.GlyphIndex]int{},
+		runes:  map[sfnt.GlyphIndex]rune{},
+	}
+	u.name = "UnicodeFont"
+	if name, err := f.Name(&u.buf, sfnt.NameIDPostScript); err == nil {
+		if cleaned := sanitizeFontName(name); cleaned != "" {
+			u.name = cleaned
+		}
+	}
+	d.unicode = u
+	return nil
+}
+
+func sanitizeFontName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// unitsPPEM scales font metrics to 1/1000 em.
+var unitsPPEM = fixed.I(1000)
+
+// glyph returns the glyph id and width (1/1000 em) of r, falling back to '?'
+// for runes the font has no glyph for.
+func (u *unicodeFont) glyph(r rune) (sfnt.GlyphIndex, int) {
+	gid, err := u.font.GlyphIndex(&u.buf, r)
+	if err != nil || gid == 0 {
+		r = '?'
+		gid, _ = u.font.GlyphIndex(&u.buf, r)
+	}
+	if width, ok := u.widths[gid]; ok {
+		return gid, width
+	}
+	advance, err := u.font.GlyphAdvance(&u.buf, gid, unitsPPEM, font.HintingNone)
+	width := 1000
+	if err == nil {
+		width = advance.Round()
+	}
+	u.widths[gid] = width
+	if _, ok := u.runes[gid]; !ok {
+		u.runes[gid] = r
+	}
+	return gid, width
+}
+
+func (u *unicodeFont) width(s string) int {
+	total := 0
+	for _, r := range s {
+		_, width := u.glyph(r)
+		total += width
+	}
+	return total
+}
+
+// encode returns s as a hex string of 2-byte glyph ids.
+func (u *unicodeFont) encode(s string) string {
+	var b strings.Builder
+	b.WriteByte('<')
+	for _, r := range s {
+		if r == '\n' || r == '\r' || r == '\t' {
+			r = ' '
+		}
+		gid, _ := u.glyph(r)
+		fmt.Fprintf(&b, "%04X", uint16(gid))
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+func (u *unicodeFont) usedGlyphs() []sfnt.GlyphIndex {
+	gids := make([]sfnt.GlyphIndex, 0, len(u.widths))
+	for gid := range u.widths {
+		gids = append(gids, gid)
+	}
+	sort.Slice(gids, func(i, j int) bool { return gids[i] < gids[j] })
+	return gids
+}
+
+// objects returns the font dictionaries in order: Type0 font, CIDFont, font
+// descriptor, font file and ToUnicode CMap, numbered from first.
+func (u *unicodeFont) objects(first int) []string {
+	metrics, _ := u.font.Metrics(&u.buf, unitsPPEM, font.HintingNone)
+	bounds, _ := u.font.Bounds(&u.buf, unitsPPEM, font.HintingNone)
+	ascent, descent := metrics.Ascent.Round(), metrics.Descent.Round()
+
+	var widths strings.Builder
+	for _, gid := range u.usedGlyphs() {
+		fmt.Fprintf(&widths, "%d [%d] ", gid, u.widths[gid])
+	}
+
+	var cmap strings.Builder
+	cmap.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	cmap.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	cmap.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	cmap.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	gids := u.usedGlyphs()
+	for start := 0; start < len(gids); start += 100 {
+		end := min(start+100, len(gids))
+		fmt.Fprintf(&cmap, "%d beginbfchar\n", end-start)
+		for _, gid := range gids[start:end] {
+			fmt.Fprintf(&cmap, "<%04X> <%s>\n", uint16(gid), utf16Hex(u.runes[gid]))
+		}
+		cmap.WriteString("endbfchar\n")
+	}
+	cmap.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend")
+
+	return []string{
+		fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+			u.name, first+1, first+4),
+		fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /DW 1000 /W [%s] /CIDToGIDMap /Identity >>",
+			u.name, first+2, strings.TrimSpace(widths.String())),
+		fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 4 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+			u.name, bounds.Min.X.Round(), -bounds.Max.Y.Round(), bounds.Max.X.Round(), -bounds.Min.Y.Round(), ascent, -descent, ascent, first+3),
+		flateStream(u.data, fmt.Sprintf("/Length1 %d", len(u.data))),
+		flateStream([]byte(cmap.String()), ""),
+	}
+}
+
+func utf16Hex(r rune) string {
+	if r > 0xFFFF {
+		r -= 0x10000
+		return fmt.Sprintf("%04X%04X", 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+	}
+	return fmt.Sprintf("%04X", r)
+}
+
+func flateStream(data []byte, extra string) string {
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	_, _ = w.Write(data)
+	_ = w.Close()
+	if extra != "" {
+		extra = " " + extra
+	}
+	return fmt.Sprintf("<< /Length %d /Filter /FlateDecode%s >>\nstream\n%s\nendstream", compressed.Len(), extra, compressed.String())
+}
@@ -0,0 +1,252 @@
+// Package pdfdoc is a minimal pure-Go PDF writer for simple text documents
+// such as statements and invoices. It supports multiple pages, the standard
+// Helvetica / Helvetica-Bold fonts and straight lines. Latin-1 text uses the
+// standard fonts with WinAnsiEncoding; other text (e.g. CJK) needs a TrueType
+// font embedded with SetUnicodeFont, otherwise Bytes returns
+// ErrUnsupportedText instead of rendering it as '?'.
+package pdfdoc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnsupportedText reports text outside Latin-1 drawn without a Unicode font.
+var ErrUnsupportedText = errors.New("pdfdoc: text contains characters outside Latin-1 and no Unicode font is set")
+
+// A4 page size in points.
+const (
+	PageWidthA4  = 595.28
+	PageHeightA4 = 841.89
+)
+
+type page struct {
+	content bytes.Buffer
+}
+
+// Document collects pages and renders them into a PDF file. Coordinates passed
+// to drawing methods use a top-left origin with y growing downwards.
+type Document struct {
+	Width   float64
+	Height  float64
+	pages   []*page
+	unicode *unicodeFont
+	err     error
+}
+
+// New creates an empty A4 document.
+func New() *Document {
+	return &Document{Width: PageWidthA4, Height: PageHeightA4}
+}
+
+// AddPage starts a new page; subsequent drawing goes to it.
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &page{})
+}
+
+// PageCount returns the number of pages added so far.
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+func (d *Document) current() *page {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// Text draws s with its baseline starting at (x, y). Text outside Latin-1 is
+// drawn with the Unicode font, which has no bold variant.
+func (d *Document) Text(x, y, size float64, bold bool, s string) {
+	font, text := "F1", ""
+	if bold {
+		font = "F2"
+	}
+	switch {
+	case isLatin1(s):
+		text = "(" + escapeText(s) + ")"
+	case d.unicode != nil:
+		font, text = "F3", d.unicode.encode(s)
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("%w: %q", ErrUnsupportedText, s)
+		}
+		return
+	}
+	fmt.Fprintf(&d.current().content, "BT /%s %s Tf %s %s Td %s Tj ET\n",
+		font, formatNumber(size), formatNumber(x), formatNumber(d.Height-y), text)
+}
+
+// TextRight draws s so that it ends at xRight.
+func (d *Document) TextRight(xRight, y, size float64, bold bool, s string) {
+	d.Text(xRight-d.TextWidth(s, size, bold), y, size, bold, s)
+}
+
+// TextWidth returns the rendered width of s in points, measured with the font
+// Text would use for it.
+func (d *Document) TextWidth(s string, size float64, bold bool) float64 {
+	if isLatin1(s) || d.unicode == nil {
+		return TextWidth(s, size, bold)
+	}
+	return float64(d.unicode.width(s)) * size / 1000
+}
+
+// Truncate shortens s with a trailing "..." so that it fits in maxWidth.
+func (d *Document) Truncate(s string, maxWidth, size float64, bold bool) string {
+	return truncate(s, maxWidth, func(s string) float64 { return d.TextWidth(s, size, bold) })
+}
+
+// Line draws a straight line with the given stroke width.
+func (d *Document) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&d.current().content, "%s w %s %s m %s %s l S\n",
+		formatNumber(width), formatNumber(x1), formatNumber(d.Height-y1), formatNumber(x2), formatNumber(d.Height-y2))
+}
+
+// TextWidth returns the rendered width of s in points.
+func TextWidth(s string, size float64, bold bool) float64 {
+	widths := helveticaWidths
+	if bold {
+		widths = helveticaBoldWidths
+	}
+	total := 0
+	for _, r := range s {
+		if r >= 32 && r <= 126 {
+			total += widths[r-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// Truncate shortens s with a trailing "..." so that it fits in maxWidth,
+// measured with the standard fonts.
+func Truncate(s string, maxWidth, size float64, bold bool) string {
+	return truncate(s, maxWidth, func(s string) float64 { return TextWidth(s, size, bold) })
+}
+
+func truncate(s string, maxWidth float64, width func(string) float64) string {
+	if width(s) <= maxWidth {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := string(runes) + "..."
+		if width(candidate) <= maxWidth {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// Bytes renders the document. It fails with ErrUnsupportedText if any text
+// could not be encoded.
+func (d *Document) Bytes() ([]byte, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	var buf bytes.Buffer
+	offsets := make([]int, 0, 4+2*len(d.pages))
+	writeObject := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// 1: catalog, 2: pages, 3/4: fonts, 5-9: Unicode font when set,
+	// then one page + content pair per page
+	var fontObjects []string
+	fonts := "/F1 3 0 R /F2 4 0 R"
+	if d.unicode != nil {
+		fontObjects = d.unicode.objects(5)
+		fonts += " /F3 5 0 R"
+	}
+	firstPage := 5 + len(fontObjects)
+	writeObject("<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	writeObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	writeObject("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	writeObject("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for _, object := range fontObjects {
+		writeObject(object)
+	}
+	for i, p := range d.pages {
+		writeObject(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			formatNumber(d.Width), formatNumber(d.Height), fonts, firstPage+1+2*i))
+		writeObject(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", p.content.Len(), p.content.String()))
+	}
+
+	xrefOffset := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xrefOffset)
+	return buf.Bytes(), nil
+}
+
+// isLatin1 reports whether the standard fonts can encode s.
+func isLatin1(s string) bool {
+	for _, r := range s {
+		if r > 0xFF || (r >= 0x7F && r < 0xA0) {
+			return false
+		}
+	}
+	return true
+}
+
+// escapeText encodes Latin-1 text for a literal string; callers check isLatin1 first.
+func escapeText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n' || r == '\r' || r == '\t':
+			b.WriteByte(' ')
+		case r >= 32 && r <= 126:
+			b.WriteRune(r)
+		case r >= 0xA0 && r <= 0xFF:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+func formatNumber(f float64) string {
+	s := fmt.Sprintf("%.2f", f)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// Glyph widths (1/1000 em) for ASCII 32..126 from the standard Type 1 AFM metrics.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
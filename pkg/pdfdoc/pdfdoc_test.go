@@ -0,0 +1,75 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestDocumentBytesHasValidXref(t *testing.T) {
+	doc := New()
+	doc.Text(40, 60, 12, true, "Statement (March)")
+	doc.Line(40, 70, 555, 70, 0.5)
+	doc.AddPage()
+	doc.TextRight(555, 60, 10, false, "Total 12.50")
+
+	out, err := doc.Bytes()
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(out, []byte("%PDF-1.4")))
+	assert.Contains(t, string(out), "/Count 2")
+	assert.Contains(t, string(out), `(Statement \(March\)) Tj`)
+
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(out)
+	require.NotNil(t, m)
+	xref, err := strconv.Atoi(string(m[1]))
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(out[xref:], []byte("xref\n")))
+
+	// 每个对象的偏移量都应指向 "N 0 obj"
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(out[xref:], -1)
+	require.Len(t, entries, 8)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		assert.True(t, bytes.HasPrefix(out[offset:], []byte(fmt.Sprintf("%d 0 obj", i+1))), "object %d", i+1)
+	}
+}
+
+func TestEscapeTextReplacesUnsupportedRunes(t *testing.T) {
+	assert.Equal(t, `a\\b \(c\) caf\351 ??`, escapeText("a\\b (c) café 中文"))
+}
+
+func TestTextOutsideLatin1RequiresUnicodeFont(t *testing.T) {
+	doc := New()
+	doc.Text(40, 60, 12, false, "Bill to: 张三")
+	_, err := doc.Bytes()
+	assert.ErrorIs(t, err, ErrUnsupportedText)
+}
+
+func TestUnicodeFontIsEmbedded(t *testing.T) {
+	doc := New()
+	require.NoError(t, doc.SetUnicodeFont(goregular.TTF))
+	doc.Text(40, 60, 12, false, "Café")
+	doc.TextRight(555, 80, 12, true, "Привет")
+
+	out, err := doc.Bytes()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "(Caf\\351) Tj")
+	assert.Contains(t, string(out), "/Subtype /CIDFontType2")
+	assert.Contains(t, string(out), "/FontFile2 8 0 R")
+	assert.Regexp(t, `/F3 12 Tf [\d.]+ [\d.]+ Td <[0-9A-F]{24}> Tj`, string(out))
+	assert.Greater(t, doc.TextWidth("Привет", 12, false), 0.0)
+	assert.Contains(t, doc.Truncate("Привет мир, длинная строка", 60, 12, false), "...")
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "short", Truncate("short", 100, 10, false))
+	truncated := Truncate("a-very-long-model-name-that-does-not-fit", 60, 10, false)
+	assert.True(t, TextWidth(truncated, 10, false) <= 60)
+	assert.Contains(t, truncated, "...")
+}
@@ -79,6 +79,25 @@ type TaskAdaptor interface {
 	ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, error)
 }
 
+// TaskCallbackAdaptor is implemented by task adaptors whose upstream can push
+// task status to a callback URL. When callbacks are enabled the relay sets
+// info.UpstreamCallbackURL before BuildRequestBody, and the adaptor is expected
+// to pass it upstream. Polling then only runs as a slow safety net.
+type TaskCallbackAdaptor interface {
+	// ParseTaskCallback converts an inbound callback body into a body that
+	// ParseTaskResult understands.
+	ParseTaskCallback(body []byte) (*relaycommon.TaskCallback, error)
+}
+
+// TaskCancelAdaptor is implemented by task adaptors whose upstream can cancel
+// an unfinished task. body carries the same "task_id" and "action" keys as
+// FetchTask; any 2xx response is treated as a successful cancellation. Tasks
+// on platforms without this capability cannot be cancelled, because the
+// upstream would keep generating (and billing) the result.
+type TaskCancelAdaptor interface {
+	CancelTask(baseUrl, key string, body map[string]any, proxy string) (*http.Response, error)
+}
+
 type OpenAIVideoConverter interface {
 	ConvertToOpenAIVideo(originTask *model.Task) ([]byte, error)
 }
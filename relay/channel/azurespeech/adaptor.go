@@ -0,0 +1,156 @@
+package azurespeech
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/relaykit/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	regionalAPIHostSuffix = ".api.cognitive.microsoft.com"
+	transcribeAPIVersion  = "2024-11-15"
+)
+
+type Adaptor struct {
+	// speechFormat is the OpenAI response_format of a speech request.
+	speechFormat string
+	// transcription holds the uploaded audio of a transcription request for the response handler.
+	transcription *helper.TranscriptionInput
+	// contentType is the multipart content type of the converted transcription body.
+	contentType string
+}
+
+func (a *Adaptor) ConvertGeminiRequest(*gin.Context, *relaycommon.RelayInfo, *dto.GeminiChatRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertClaudeRequest(*gin.Context, *relaycommon.RelayInfo, *dto.ClaudeRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.AudioRequest) (io.Reader, error) {
+	switch info.RelayMode {
+	case constant.RelayModeAudioSpeech:
+		format, err := speechFormat(request)
+		if err != nil {
+			return nil, err
+		}
+		a.speechFormat = format
+		return strings.NewReader(buildSSML(request)), nil
+	case constant.RelayModeAudioTranscription:
+		if err := helper.ValidateTranscriptionFormat(request.ResponseFormat); err != nil {
+			return nil, err
+		}
+		input, err := helper.ReadTranscriptionInput(c)
+		if err != nil {
+			return nil, err
+		}
+		body, contentType, err := buildTranscriptionBody(input)
+		if err != nil {
+			return nil, err
+		}
+		a.transcription = input
+		a.contentType = contentType
+		// transcripts are returned in one piece
+		info.IsStream = false
+		return body, nil
+	default:
+		return nil, errors.New("only audio speech and transcription are supported by Azure Speech")
+	}
+}
+
+func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
+}
+
+// speechBaseURL maps regional Cognitive Services endpoints to the TTS host of the same region;
+// custom domains and other base URLs serve both APIs and are used as-is.
+func speechBaseURL(baseURL string) string {
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return baseURL
+	}
+	region, ok := strings.CutSuffix(parsed.Hostname(), regionalAPIHostSuffix)
+	if !ok || region == "" {
+		return baseURL
+	}
+	return fmt.Sprintf("https://%s.tts.speech.microsoft.com", region)
+}
+
+func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	baseURL := strings.TrimSuffix(info.ChannelBaseUrl, "/")
+	switch info.RelayMode {
+	case constant.RelayModeAudioSpeech:
+		return speechBaseURL(baseURL) + "/cognitiveservices/v1", nil
+	case constant.RelayModeAudioTranscription:
+		return fmt.Sprintf("%s/speechtotext/transcriptions:transcribe?api-version=%s", baseURL, transcribeAPIVersion), nil
+	}
+	return "", errors.New("invalid relay mode")
+}
+
+func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Header, info *relaycommon.RelayInfo) error {
+	channel.SetupApiRequestHeader(info, c, req)
+	req.Set("Ocp-Apim-Subscription-Key", info.ApiKey)
+	switch info.RelayMode {
+	case constant.RelayModeAudioSpeech:
+		req.Set("Content-Type", "application/ssml+xml")
+		req.Set("X-Microsoft-OutputFormat", outputFormats[a.speechFormat].name)
+		req.Del("Accept")
+	case constant.RelayModeAudioTranscription:
+		req.Set("Content-Type", a.contentType)
+	}
+	return nil
+}
+
+func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.OpenAIResponsesRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (any, error) {
+	return channel.DoApiRequest(a, c, info, requestBody)
+}
+
+func (a *Adaptor) ConvertRerankRequest(c *gin.Context, relayMode int, request dto.RerankRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertEmbeddingRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.EmbeddingRequest) (any, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
+	switch info.RelayMode {
+	case constant.RelayModeAudioSpeech:
+		return TTSHandler(c, info, resp, a.speechFormat)
+	case constant.RelayModeAudioTranscription:
+		return STTHandler(c, info, resp, a.transcription)
+	}
+	return nil, types.NewError(errors.New("invalid relay mode"), types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
+}
+
+func (a *Adaptor) GetModelList() []string {
+	return ModelList
+}
+
+func (a *Adaptor) GetChannelName() string {
+	return ChannelName
+}
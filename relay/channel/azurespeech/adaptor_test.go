@@ -0,0 +1,186 @@
+package azurespeech
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+// oneSecondOfPCM is one second of 24kHz 16-bit mono silence.
+var oneSecondOfPCM = make([]byte, 48000)
+
+func newAzureSpeechRelayInfo(serverURL string, relayMode int, request *dto.AudioRequest) *relaycommon.RelayInfo {
+	info := &relaycommon.RelayInfo{
+		RelayMode:       relayMode,
+		OriginModelName: request.Model,
+		Request:         request,
+		IsStream:        request.IsStream(nil),
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ChannelBaseUrl:    serverURL,
+			ApiKey:            "speech-key",
+			UpstreamModelName: request.Model,
+		},
+	}
+	info.SetEstimatePromptTokens(3)
+	return info
+}
+
+func relayAzureSpeech(t *testing.T, c *gin.Context, info *relaycommon.RelayInfo) *dto.Usage {
+	t.Helper()
+	adaptor := &Adaptor{}
+	body, err := adaptor.ConvertAudioRequest(c, info, *info.Request.(*dto.AudioRequest))
+	require.NoError(t, err)
+	resp, err := adaptor.DoRequest(c, info, body)
+	require.NoError(t, err)
+	usage, apiErr := adaptor.DoResponse(c, resp.(*http.Response), info)
+	require.Nil(t, apiErr)
+	return usage.(*dto.Usage)
+}
+
+func TestSpeechBaseURL(t *testing.T) {
+	assert.Equal(t, "https://eastus.tts.speech.microsoft.com", speechBaseURL("https://eastus.api.cognitive.microsoft.com"))
+	assert.Equal(t, "https://my-speech.cognitiveservices.azure.com", speechBaseURL("https://my-speech.cognitiveservices.azure.com"))
+	assert.Equal(t, "http://127.0.0.1:8080", speechBaseURL("http://127.0.0.1:8080"))
+}
+
+func TestSpeechSendsSSMLAndBillsByDuration(t *testing.T) {
+	service.InitHttpClient()
+	gin.SetMode(gin.TestMode)
+	wav := common.PCMToWAV(oneSecondOfPCM, 24000, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "/cognitiveservices/v1", r.URL.Path)
+		assert.Equal(t, "speech-key", r.Header.Get("Ocp-Apim-Subscription-Key"))
+		assert.Equal(t, "application/ssml+xml", r.Header.Get("Content-Type"))
+		assert.Equal(t, "riff-24khz-16bit-mono-pcm", r.Header.Get("X-Microsoft-OutputFormat"))
+		assert.Equal(t, `<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xml:lang="en-US">`+
+			`<voice name="en-US-AvaMultilingualNeural"><prosody rate="+25%">Fish &amp; chips</prosody></voice></speak>`, string(body))
+		w.Header().Set("Content-Type", "audio/x-wav")
+		_, _ = w.Write(wav)
+	}))
+	defer server.Close()
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/audio/speech", nil)
+	speed := 1.25
+	info := newAzureSpeechRelayInfo(server.URL, relayconstant.RelayModeAudioSpeech,
+		&dto.AudioRequest{Model: "azure-tts", Input: "Fish & chips", Voice: "alloy", ResponseFormat: "wav", Speed: &speed})
+
+	usage := relayAzureSpeech(t, c, info)
+
+	assert.Equal(t, "audio/wav", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, wav, recorder.Body.Bytes())
+	assert.Equal(t, 3, usage.PromptTokens)
+	assert.Equal(t, common.AudioDurationTokens(1), usage.CompletionTokenDetails.AudioTokens)
+}
+
+func TestSpeechStreamsAudioDeltas(t *testing.T) {
+	service.InitHttpClient()
+	gin.SetMode(gin.TestMode)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "raw-24khz-16bit-mono-pcm", r.Header.Get("X-Microsoft-OutputFormat"))
+		assert.Contains(t, r.Header.Get("Content-Type"), "application/ssml+xml")
+		_, _ = w.Write(oneSecondOfPCM)
+		w.(http.Flusher).Flush()
+		_, _ = w.Write(oneSecondOfPCM)
+	}))
+	defer server.Close()
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/audio/speech", nil)
+	info := newAzureSpeechRelayInfo(server.URL, relayconstant.RelayModeAudioSpeech,
+		&dto.AudioRequest{Model: "azure-tts", Input: "hello", Voice: "de-DE-KatjaNeural", ResponseFormat: "pcm", StreamFormat: "sse"})
+
+	usage := relayAzureSpeech(t, c, info)
+
+	var audio []byte
+	var events []string
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			events = append(events, data)
+		}
+	}
+	require.NotEmpty(t, events)
+	for _, event := range events[:len(events)-1] {
+		assert.Equal(t, "speech.audio.delta", gjson.Get(event, "type").String())
+		chunk, err := base64.StdEncoding.DecodeString(gjson.Get(event, "audio").String())
+		require.NoError(t, err)
+		audio = append(audio, chunk...)
+	}
+	done := events[len(events)-1]
+	assert.Equal(t, "speech.audio.done", gjson.Get(done, "type").String())
+	assert.Len(t, audio, 2*len(oneSecondOfPCM))
+	assert.Equal(t, common.AudioDurationTokens(2), usage.CompletionTokens)
+	assert.Equal(t, int64(usage.TotalTokens), gjson.Get(done, "usage.total_tokens").Int())
+}
+
+func TestTranscriptionUsesFastTranscriptionAPI(t *testing.T) {
+	service.InitHttpClient()
+	gin.SetMode(gin.TestMode)
+	wav := common.PCMToWAV(make([]byte, 48000*3), 24000, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/speechtotext/transcriptions:transcribe", r.URL.Path)
+		assert.Equal(t, transcribeAPIVersion, r.URL.Query().Get("api-version"))
+		assert.Equal(t, "speech-key", r.Header.Get("Ocp-Apim-Subscription-Key"))
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		assert.JSONEq(t, `{"locales":["en-US"]}`, r.FormValue("definition"))
+		file, header, err := r.FormFile("audio")
+		require.NoError(t, err)
+		defer file.Close()
+		audio, _ := io.ReadAll(file)
+		assert.Equal(t, wav, audio)
+		assert.Equal(t, "meeting.wav", header.Filename)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"durationMilliseconds":4200,"combinedPhrases":[{"text":"Hello world."}],"phrases":[{"locale":"en-US","text":"Hello world."}]}`)
+	}))
+	defer server.Close()
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	part, err := writer.CreateFormFile("file", "meeting.wav")
+	require.NoError(t, err)
+	_, _ = part.Write(wav)
+	require.NoError(t, writer.WriteField("model", "azure-stt"))
+	require.NoError(t, writer.WriteField("language", "en"))
+	require.NoError(t, writer.Close())
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", &form)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	info := newAzureSpeechRelayInfo(server.URL, relayconstant.RelayModeAudioTranscription,
+		&dto.AudioRequest{Model: "azure-stt", ResponseFormat: "verbose_json"})
+
+	usage := relayAzureSpeech(t, c, info)
+
+	assert.Equal(t, "Hello world.", gjson.Get(recorder.Body.String(), "text").String())
+	assert.Equal(t, "en", gjson.Get(recorder.Body.String(), "language").String())
+	assert.InDelta(t, 4.2, gjson.Get(recorder.Body.String(), "duration").Float(), 1e-9)
+	assert.Equal(t, common.AudioDurationTokens(4.2), usage.PromptTokens)
+}
+
+func TestTranslationIsRejected(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	info := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeAudioTranslation}
+	_, err := (&Adaptor{}).ConvertAudioRequest(c, info, dto.AudioRequest{Model: "azure-stt"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,8 @@
+package azurespeech
+
+var ModelList = []string{
+	"azure-tts",
+	"azure-stt",
+}
+
+var ChannelName = "azure-speech"
@@ -0,0 +1,131 @@
+package azurespeech
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/relaykit/types"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type transcriptionDefinition struct {
+	Locales []string `json:"locales,omitempty"`
+}
+
+type transcriptionResponse struct {
+	DurationMilliseconds int64 `json:"durationMilliseconds"`
+	CombinedPhrases      []struct {
+		Text string `json:"text"`
+	} `json:"combinedPhrases"`
+	Phrases []struct {
+		Locale string `json:"locale"`
+	} `json:"phrases"`
+}
+
+// transcriptionLocale expands Whisper-style language codes to the locale Azure expects.
+// Without a language Azure identifies the spoken language itself.
+func transcriptionLocale(language string) string {
+	if language == "" || strings.Contains(language, "-") {
+		return language
+	}
+	switch strings.ToLower(language) {
+	case "en":
+		return "en-US"
+	case "zh":
+		return "zh-CN"
+	case "ja":
+		return "ja-JP"
+	case "ko":
+		return "ko-KR"
+	case "pt":
+		return "pt-BR"
+	default:
+		return strings.ToLower(language) + "-" + strings.ToUpper(language)
+	}
+}
+
+// buildTranscriptionBody builds the multipart body of an Azure fast transcription request.
+func buildTranscriptionBody(input *helper.TranscriptionInput) (*bytes.Buffer, string, error) {
+	var definition transcriptionDefinition
+	if locale := transcriptionLocale(input.Language); locale != "" {
+		definition.Locales = []string{locale}
+	}
+	definitionJSON, err := common.Marshal(definition)
+	if err != nil {
+		return nil, "", err
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="audio"; filename="`+escapeQuotes(input.Filename)+`"`)
+	if input.ContentType != "" {
+		header.Set("Content-Type", input.ContentType)
+	} else {
+		header.Set("Content-Type", "application/octet-stream")
+	}
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err = part.Write(input.Audio); err != nil {
+		return nil, "", err
+	}
+	if err = writer.WriteField("definition", string(definitionJSON)); err != nil {
+		return nil, "", err
+	}
+	if err = writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return body, writer.FormDataContentType(), nil
+}
+
+func escapeQuotes(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+// STTHandler converts an Azure fast transcription result into the requested OpenAI format.
+// Billing uses the duration reported by Azure and falls back to the decoded upload duration.
+func STTHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response, input *helper.TranscriptionInput) (*dto.Usage, *types.NewAPIError) {
+	defer service.CloseResponseBodyGracefully(resp)
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	var azureResponse transcriptionResponse
+	if err := common.Unmarshal(responseBody, &azureResponse); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+
+	var text string
+	if len(azureResponse.CombinedPhrases) > 0 {
+		text = azureResponse.CombinedPhrases[0].Text
+	}
+	language := input.Language
+	if language == "" && len(azureResponse.Phrases) > 0 {
+		language = azureResponse.Phrases[0].Locale
+	}
+	duration := input.Duration
+	if azureResponse.DurationMilliseconds > 0 {
+		duration = float64(azureResponse.DurationMilliseconds) / 1000
+	}
+
+	responseFormat := "json"
+	if audioReq, ok := info.Request.(*dto.AudioRequest); ok && audioReq.ResponseFormat != "" {
+		responseFormat = audioReq.ResponseFormat
+	}
+	if err := helper.WriteTranscription(c, responseFormat, "transcribe", strings.TrimSpace(text), language, duration); err != nil {
+		return nil, types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	return helper.TranscriptionUsage(info, duration), nil
+}
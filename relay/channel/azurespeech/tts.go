@@ -0,0 +1,135 @@
+package azurespeech
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/relaykit/types"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultVoice      = "en-US-AvaMultilingualNeural"
+	defaultLocale     = "en-US"
+	speechSampleRate  = 24000
+	streamChunkLength = 32 << 10
+)
+
+type outputFormat struct {
+	name        string
+	contentType string
+}
+
+// outputFormats maps OpenAI response_format values to Azure X-Microsoft-OutputFormat names.
+var outputFormats = map[string]outputFormat{
+	"mp3":  {name: "audio-24khz-48kbitrate-mono-mp3", contentType: "audio/mpeg"},
+	"opus": {name: "ogg-24khz-16bit-mono-opus", contentType: "audio/ogg"},
+	"wav":  {name: "riff-24khz-16bit-mono-pcm", contentType: "audio/wav"},
+	"pcm":  {name: "raw-24khz-16bit-mono-pcm", contentType: "audio/pcm"},
+}
+
+// openAIVoices are OpenAI voice names; they fall back to the default Azure neural voice.
+var openAIVoices = map[string]bool{
+	"alloy": true, "ash": true, "ballad": true, "coral": true, "echo": true, "fable": true,
+	"nova": true, "onyx": true, "sage": true, "shimmer": true, "verse": true,
+}
+
+func speechFormat(request dto.AudioRequest) (string, error) {
+	format := request.ResponseFormat
+	if format == "" {
+		format = "mp3"
+	}
+	if _, ok := outputFormats[format]; !ok {
+		return "", fmt.Errorf("unsupported response_format for Azure Speech: %s", format)
+	}
+	return format, nil
+}
+
+// voiceLocale extracts the locale prefix of Azure voice names such as "zh-CN-XiaoxiaoNeural".
+func voiceLocale(voice string) string {
+	parts := strings.SplitN(voice, "-", 3)
+	if len(parts) < 3 {
+		return defaultLocale
+	}
+	return parts[0] + "-" + parts[1]
+}
+
+func escapeXML(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// buildSSML converts an OpenAI speech request into an SSML document for the Azure TTS REST API.
+func buildSSML(request dto.AudioRequest) string {
+	voice := request.Voice
+	if voice == "" || openAIVoices[strings.ToLower(voice)] {
+		voice = defaultVoice
+	}
+	text := escapeXML(request.Input)
+	if request.Speed != nil && *request.Speed > 0 && *request.Speed != 1 {
+		text = fmt.Sprintf(`<prosody rate="%+.0f%%">%s</prosody>`, (*request.Speed-1)*100, text)
+	}
+	return fmt.Sprintf(`<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xml:lang="%s"><voice name="%s">%s</voice></speak>`,
+		voiceLocale(voice), escapeXML(voice), text)
+}
+
+// TTSHandler relays Azure TTS audio to the client while it is being synthesized and bills by its duration.
+// Streaming requests receive the audio as OpenAI speech SSE events.
+func TTSHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response, format string) (*dto.Usage, *types.NewAPIError) {
+	defer service.CloseResponseBodyGracefully(resp)
+
+	var audio []byte
+	var err error
+	if info.IsStream {
+		audio, err = streamSpeechEvents(c, resp.Body)
+	} else {
+		audio, err = helper.CopyAudioStream(c, outputFormats[format].contentType, resp.Body)
+	}
+	if err != nil {
+		// the audio has already been partially written, so bill what was delivered instead of retrying
+		logger.LogError(c, "error relaying azure speech audio: "+err.Error())
+	}
+
+	duration, err := common.GetAudioBytesDuration(c.Request.Context(), audio, format, speechSampleRate)
+	if err != nil {
+		logger.LogWarn(c, "failed to get azure speech audio duration: "+err.Error())
+	}
+	usage := helper.SpeechUsage(info, duration, len(audio))
+	if info.IsStream {
+		_ = helper.SpeechAudioDone(c, usage)
+	}
+	return usage, nil
+}
+
+func streamSpeechEvents(c *gin.Context, body io.Reader) ([]byte, error) {
+	helper.SetEventStreamHeaders(c)
+	var audio []byte
+	buf := make([]byte, streamChunkLength)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			audio = append(audio, buf[:n]...)
+			if writeErr := helper.SpeechAudioDelta(c, buf[:n]); writeErr != nil {
+				return audio, writeErr
+			}
+		}
+		if err == io.EOF {
+			return audio, nil
+		}
+		if err != nil {
+			return audio, err
+		}
+	}
+}
@@ -1,15 +1,18 @@
 package gemini
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 
+	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/relay/channel"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/relaykit/dto"
 	"github.com/QuantumNous/new-api/relaykit/relayconvert"
 	"github.com/QuantumNous/new-api/relaykit/types"
@@ -21,6 +24,8 @@ import (
 )
 
 type Adaptor struct {
+	// transcription holds the uploaded audio of a transcription request for the response handler.
+	transcription *helper.TranscriptionInput
 }
 
 func (a *Adaptor) ConvertGeminiRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeminiChatRequest) (any, error) {
@@ -56,35 +61,67 @@ func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayIn
 }
 
 func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.AudioRequest) (io.Reader, error) {
-	//TODO implement me
-	return nil, errors.New("not implemented")
+	var geminiRequest *dto.GeminiChatRequest
+	switch info.RelayMode {
+	case constant.RelayModeAudioSpeech:
+		geminiRequest = buildSpeechRequest(request)
+	case constant.RelayModeAudioTranscription, constant.RelayModeAudioTranslation:
+		if err := helper.ValidateTranscriptionFormat(request.ResponseFormat); err != nil {
+			return nil, err
+		}
+		input, err := helper.ReadTranscriptionInput(c)
+		if err != nil {
+			return nil, err
+		}
+		a.transcription = input
+		// transcripts are returned in one piece
+		info.IsStream = false
+		geminiRequest = buildTranscriptionRequest(info, input)
+	default:
+		return nil, errors.New("unsupported audio relay mode")
+	}
+	jsonData, err := common.Marshal(geminiRequest)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(jsonData), nil
 }
 
-func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
-	if !strings.HasPrefix(info.UpstreamModelName, "imagen") {
-		return nil, errors.New("not supported model for image generation, only imagen models are supported")
+// imageAspectRatio converts an OpenAI size to an aspect ratio but allows users to specify the aspect ratio directly.
+func imageAspectRatio(size string) string {
+	size = strings.TrimSpace(size)
+	if strings.Contains(size, ":") {
+		return size
 	}
+	switch size {
+	case "1536x1024":
+		return "3:2"
+	case "1024x1536":
+		return "2:3"
+	case "1024x1792":
+		return "9:16"
+	case "1792x1024":
+		return "16:9"
+	default:
+		return "1:1"
+	}
+}
 
-	// convert size to aspect ratio but allow user to specify aspect ratio
-	aspectRatio := "1:1" // default aspect ratio
-	size := strings.TrimSpace(request.Size)
-	if size != "" {
-		if strings.Contains(size, ":") {
-			aspectRatio = size
-		} else {
-			switch size {
-			case "256x256", "512x512", "1024x1024":
-				aspectRatio = "1:1"
-			case "1536x1024":
-				aspectRatio = "3:2"
-			case "1024x1536":
-				aspectRatio = "2:3"
-			case "1024x1792":
-				aspectRatio = "9:16"
-			case "1792x1024":
-				aspectRatio = "16:9"
-			}
+func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
+	if info.RelayMode == constant.RelayModeImagesEdits || info.RelayMode == constant.RelayModeImagesVariations {
+		if strings.HasPrefix(info.UpstreamModelName, "imagen") {
+			return nil, errors.New("image edits and variations require a Gemini image model, imagen models only support generation")
 		}
+		input, err := helper.ReadImageEditInput(c)
+		if err != nil {
+			return nil, err
+		}
+		// edited images are returned in one piece
+		info.IsStream = false
+		return buildImageEditRequest(info, request, input), nil
+	}
+	if !strings.HasPrefix(info.UpstreamModelName, "imagen") {
+		return nil, errors.New("not supported model for image generation, only imagen models are supported")
 	}
 
 	// build gemini imagen request
@@ -96,7 +133,7 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 		},
 		Parameters: dto.GeminiImageParameters{
 			SampleCount:      int(lo.FromPtrOr(request.N, uint(1))),
-			AspectRatio:      aspectRatio,
+			AspectRatio:      imageAspectRatio(request.Size),
 			PersonGeneration: "allow_adult", // default allow adult
 		},
 	}
@@ -175,6 +212,14 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 
 func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Header, info *relaycommon.RelayInfo) error {
 	channel.SetupApiRequestHeader(info, c, req)
+	if info.RelayMode == constant.RelayModeAudioSpeech ||
+		info.RelayMode == constant.RelayModeAudioTranscription ||
+		info.RelayMode == constant.RelayModeAudioTranslation ||
+		info.RelayMode == constant.RelayModeImagesEdits ||
+		info.RelayMode == constant.RelayModeImagesVariations {
+		// audio and multipart image edit requests are converted to JSON generateContent calls
+		req.Set("Content-Type", "application/json")
+	}
 	req.Set("x-goog-api-key", info.ApiKey)
 	return nil
 }
@@ -255,6 +300,15 @@ func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, request
 }
 
 func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
+	switch info.RelayMode {
+	case constant.RelayModeAudioSpeech:
+		return GeminiTTSHandler(c, info, resp)
+	case constant.RelayModeAudioTranscription, constant.RelayModeAudioTranslation:
+		return GeminiSTTHandler(c, info, resp, a.transcription)
+	case constant.RelayModeImagesEdits, constant.RelayModeImagesVariations:
+		return GeminiImageEditHandler(c, info, resp)
+	}
+
 	if info.RelayMode == constant.RelayModeResponses {
 		if info.IsStream {
 			return GeminiResponsesStreamHandler(c, info, resp)
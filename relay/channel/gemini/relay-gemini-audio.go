@@ -0,0 +1,220 @@
+package gemini
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/relaykit/types"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSpeechVoice      = "Kore"
+	defaultSpeechSampleRate = 24000
+)
+
+// openAISpeechVoices are OpenAI voice names; Gemini has its own prebuilt voices,
+// so requests written for OpenAI fall back to the default Gemini voice.
+var openAISpeechVoices = map[string]bool{
+	"alloy": true, "ash": true, "ballad": true, "coral": true, "echo": true, "fable": true,
+	"nova": true, "onyx": true, "sage": true, "shimmer": true, "verse": true,
+}
+
+// buildSpeechRequest converts an OpenAI speech request into a Gemini TTS generateContent request.
+func buildSpeechRequest(request dto.AudioRequest) *dto.GeminiChatRequest {
+	text := request.Input
+	if request.Instructions != "" {
+		// Gemini TTS is steered by natural-language style prompts placed before the text.
+		text = request.Instructions + ":\n" + request.Input
+	}
+	voice := request.Voice
+	if voice == "" || openAISpeechVoices[strings.ToLower(voice)] {
+		voice = defaultSpeechVoice
+	}
+	speechConfig, _ := common.Marshal(map[string]any{
+		"voiceConfig": map[string]any{
+			"prebuiltVoiceConfig": map[string]any{"voiceName": voice},
+		},
+	})
+	return &dto.GeminiChatRequest{
+		Contents: []dto.GeminiChatContent{{
+			Role:  "user",
+			Parts: []dto.GeminiPart{{Text: text}},
+		}},
+		GenerationConfig: dto.GeminiChatGenerationConfig{
+			ResponseModalities: []string{"AUDIO"},
+			SpeechConfig:       speechConfig,
+		},
+	}
+}
+
+// buildTranscriptionRequest sends the uploaded audio as inline data with a transcription prompt.
+func buildTranscriptionRequest(info *relaycommon.RelayInfo, input *helper.TranscriptionInput) *dto.GeminiChatRequest {
+	var prompt strings.Builder
+	if info.RelayMode == constant.RelayModeAudioTranslation {
+		prompt.WriteString("Translate the speech in this audio into English. Respond with the English translation only.")
+	} else {
+		prompt.WriteString("Generate a verbatim transcript of the speech in this audio. Respond with the transcript only.")
+		if input.Language != "" {
+			prompt.WriteString(" The spoken language is " + input.Language + ".")
+		}
+	}
+	if input.Prompt != "" {
+		prompt.WriteString(" Context for spelling and style: " + input.Prompt)
+	}
+	return &dto.GeminiChatRequest{
+		Contents: []dto.GeminiChatContent{{
+			Role: "user",
+			Parts: []dto.GeminiPart{
+				{Text: prompt.String()},
+				{InlineData: &dto.GeminiInlineData{
+					MimeType: input.ContentType,
+					Data:     base64.StdEncoding.EncodeToString(input.Audio),
+				}},
+			},
+		}},
+	}
+}
+
+// speechSampleRate parses the rate parameter of mime types such as "audio/L16;codec=pcm;rate=24000".
+func speechSampleRate(mimeType string) int {
+	for _, param := range strings.Split(mimeType, ";") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(param), "rate="); ok {
+			if rate, err := strconv.Atoi(value); err == nil && rate > 0 {
+				return rate
+			}
+		}
+	}
+	return defaultSpeechSampleRate
+}
+
+// collectSpeechAudio decodes the PCM audio parts of a Gemini response.
+func collectSpeechAudio(response *dto.GeminiChatResponse) ([]byte, int, error) {
+	var pcm []byte
+	sampleRate := defaultSpeechSampleRate
+	for _, candidate := range response.Candidates {
+		for _, part := range candidate.Content.Parts {
+			if part.InlineData == nil || !strings.HasPrefix(part.InlineData.MimeType, "audio/") {
+				continue
+			}
+			chunk, err := base64.StdEncoding.DecodeString(part.InlineData.Data)
+			if err != nil {
+				return nil, 0, fmt.Errorf("decode gemini audio: %w", err)
+			}
+			pcm = append(pcm, chunk...)
+			sampleRate = speechSampleRate(part.InlineData.MimeType)
+		}
+	}
+	return pcm, sampleRate, nil
+}
+
+// GeminiTTSHandler returns Gemini TTS output as WAV, or raw PCM when response_format is pcm.
+// Gemini only produces 16-bit mono PCM, so other formats are served as WAV with a matching
+// Content-Type. Streaming requests are relayed as OpenAI speech SSE events carrying PCM chunks.
+func GeminiTTSHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	if info.IsStream {
+		return geminiTTSStreamHandler(c, info, resp)
+	}
+	defer service.CloseResponseBodyGracefully(resp)
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	var geminiResponse dto.GeminiChatResponse
+	if err := common.Unmarshal(responseBody, &geminiResponse); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	pcm, sampleRate, err := collectSpeechAudio(&geminiResponse)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	if len(pcm) == 0 {
+		return nil, types.NewOpenAIError(errors.New("no audio generated"), types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+
+	if audioReq, ok := info.Request.(*dto.AudioRequest); ok && audioReq.ResponseFormat == "pcm" {
+		c.Data(http.StatusOK, "audio/pcm", pcm)
+	} else {
+		c.Data(http.StatusOK, "audio/wav", common.PCMToWAV(pcm, sampleRate, 1))
+	}
+	return helper.SpeechUsage(info, common.PCMDuration(len(pcm), sampleRate, 1), len(pcm)), nil
+}
+
+func geminiTTSStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	var duration float64
+	audioSize := 0
+	helper.StreamScannerHandler(c, resp, info, func(data string, sr *helper.StreamResult) {
+		var geminiResponse dto.GeminiChatResponse
+		if err := common.Unmarshal([]byte(data), &geminiResponse); err != nil {
+			logger.LogError(c, "error unmarshalling gemini speech stream: "+err.Error())
+			return
+		}
+		pcm, sampleRate, err := collectSpeechAudio(&geminiResponse)
+		if err != nil {
+			sr.Error(err)
+			return
+		}
+		if len(pcm) == 0 {
+			return
+		}
+		duration += common.PCMDuration(len(pcm), sampleRate, 1)
+		audioSize += len(pcm)
+		if err := helper.SpeechAudioDelta(c, pcm); err != nil {
+			sr.Stop(err)
+		}
+	})
+	if audioSize == 0 {
+		return nil, types.NewOpenAIError(errors.New("no audio generated"), types.ErrorCodeEmptyResponse, http.StatusInternalServerError)
+	}
+	usage := helper.SpeechUsage(info, duration, audioSize)
+	_ = helper.SpeechAudioDone(c, usage)
+	return usage, nil
+}
+
+// GeminiSTTHandler converts the transcript text returned by Gemini into the requested OpenAI format.
+// Billing follows the input audio duration, matching the transcription pre-consume estimate.
+func GeminiSTTHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response, input *helper.TranscriptionInput) (*dto.Usage, *types.NewAPIError) {
+	defer service.CloseResponseBodyGracefully(resp)
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	var geminiResponse dto.GeminiChatResponse
+	if err := common.Unmarshal(responseBody, &geminiResponse); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	var text strings.Builder
+	if len(geminiResponse.Candidates) > 0 {
+		for _, part := range geminiResponse.Candidates[0].Content.Parts {
+			if !part.Thought {
+				text.WriteString(part.Text)
+			}
+		}
+	}
+
+	responseFormat := "json"
+	if audioReq, ok := info.Request.(*dto.AudioRequest); ok && audioReq.ResponseFormat != "" {
+		responseFormat = audioReq.ResponseFormat
+	}
+	task := "transcribe"
+	if info.RelayMode == constant.RelayModeAudioTranslation {
+		task = "translate"
+	}
+	if err := helper.WriteTranscription(c, responseFormat, task, strings.TrimSpace(text.String()), input.Language, input.Duration); err != nil {
+		return nil, types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	return helper.TranscriptionUsage(info, input.Duration), nil
+}
@@ -0,0 +1,189 @@
+package gemini
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+// oneSecondOfPCM is one second of 24kHz 16-bit mono silence, the format Gemini TTS returns.
+var oneSecondOfPCM = make([]byte, 48000)
+
+func geminiAudioPart(pcm []byte) map[string]any {
+	return map[string]any{"inlineData": map[string]any{
+		"mimeType": "audio/L16;codec=pcm;rate=24000",
+		"data":     base64.StdEncoding.EncodeToString(pcm),
+	}}
+}
+
+func newGeminiAudioStandIn(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, body []byte)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "test-key", r.Header.Get("x-goog-api-key"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		handler(w, r, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func writeGeminiJSON(w http.ResponseWriter, v any) {
+	body, _ := common.Marshal(v)
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+func newGeminiAudioRelayInfo(serverURL string, relayMode int, model string, request *dto.AudioRequest) *relaycommon.RelayInfo {
+	info := &relaycommon.RelayInfo{
+		RelayMode:       relayMode,
+		OriginModelName: model,
+		Request:         request,
+		IsStream:        request.IsStream(nil),
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ChannelBaseUrl:    serverURL,
+			ApiKey:            "test-key",
+			UpstreamModelName: model,
+		},
+	}
+	info.SetEstimatePromptTokens(5)
+	return info
+}
+
+func relayGeminiAudio(t *testing.T, c *gin.Context, info *relaycommon.RelayInfo) *dto.Usage {
+	t.Helper()
+	adaptor := &Adaptor{}
+	body, err := adaptor.ConvertAudioRequest(c, info, *info.Request.(*dto.AudioRequest))
+	require.NoError(t, err)
+	resp, err := adaptor.DoRequest(c, info, body)
+	require.NoError(t, err)
+	usage, apiErr := adaptor.DoResponse(c, resp.(*http.Response), info)
+	require.Nil(t, apiErr)
+	return usage.(*dto.Usage)
+}
+
+func TestGeminiSpeechReturnsWAVBilledByDuration(t *testing.T) {
+	service.InitHttpClient()
+	gin.SetMode(gin.TestMode)
+	server := newGeminiAudioStandIn(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		assert.Equal(t, "/v1beta/models/gemini-2.5-flash-preview-tts:generateContent", r.URL.Path)
+		assert.Equal(t, "AUDIO", gjson.GetBytes(body, "generationConfig.responseModalities.0").String())
+		assert.Equal(t, "Kore", gjson.GetBytes(body, "generationConfig.speechConfig.voiceConfig.prebuiltVoiceConfig.voiceName").String())
+		assert.Equal(t, "Say cheerfully:\nhello", gjson.GetBytes(body, "contents.0.parts.0.text").String())
+		writeGeminiJSON(w, map[string]any{"candidates": []any{
+			map[string]any{"content": map[string]any{"role": "model", "parts": []any{geminiAudioPart(oneSecondOfPCM)}}},
+		}})
+	})
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/audio/speech", nil)
+	info := newGeminiAudioRelayInfo(server.URL, relayconstant.RelayModeAudioSpeech, "gemini-2.5-flash-preview-tts",
+		&dto.AudioRequest{Model: "gemini-2.5-flash-preview-tts", Input: "hello", Voice: "alloy", Instructions: "Say cheerfully"})
+
+	usage := relayGeminiAudio(t, c, info)
+
+	assert.Equal(t, "audio/wav", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, common.PCMToWAV(oneSecondOfPCM, 24000, 1), recorder.Body.Bytes())
+	assert.Equal(t, 5, usage.PromptTokens)
+	assert.Equal(t, common.AudioDurationTokens(1), usage.CompletionTokens)
+	assert.Equal(t, usage.CompletionTokens, usage.CompletionTokenDetails.AudioTokens)
+}
+
+func TestGeminiSpeechStreamsAudioDeltas(t *testing.T) {
+	service.InitHttpClient()
+	gin.SetMode(gin.TestMode)
+	oldStreamingTimeout := constant.StreamingTimeout
+	constant.StreamingTimeout = 300
+	t.Cleanup(func() { constant.StreamingTimeout = oldStreamingTimeout })
+	server := newGeminiAudioStandIn(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		assert.Equal(t, "/v1beta/models/gemini-2.5-flash-preview-tts:streamGenerateContent", r.URL.Path)
+		assert.Equal(t, "sse", r.URL.Query().Get("alt"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		for range 2 {
+			chunk, _ := common.Marshal(map[string]any{"candidates": []any{
+				map[string]any{"content": map[string]any{"role": "model", "parts": []any{geminiAudioPart(oneSecondOfPCM)}}},
+			}})
+			_, _ = w.Write([]byte("data: " + string(chunk) + "\n\n"))
+		}
+	})
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/audio/speech", nil)
+	info := newGeminiAudioRelayInfo(server.URL, relayconstant.RelayModeAudioSpeech, "gemini-2.5-flash-preview-tts",
+		&dto.AudioRequest{Model: "gemini-2.5-flash-preview-tts", Input: "hello", StreamFormat: "sse"})
+
+	usage := relayGeminiAudio(t, c, info)
+
+	var events []string
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			events = append(events, data)
+		}
+	}
+	require.Len(t, events, 3)
+	assert.Equal(t, "speech.audio.delta", gjson.Get(events[0], "type").String())
+	assert.Equal(t, base64.StdEncoding.EncodeToString(oneSecondOfPCM), gjson.Get(events[0], "audio").String())
+	assert.Equal(t, "speech.audio.done", gjson.Get(events[2], "type").String())
+	assert.Equal(t, int64(common.AudioDurationTokens(2)), gjson.Get(events[2], "usage.output_tokens").Int())
+	assert.Equal(t, common.AudioDurationTokens(2), usage.CompletionTokens)
+}
+
+func TestGeminiTranscriptionUsesInlineAudio(t *testing.T) {
+	service.InitHttpClient()
+	gin.SetMode(gin.TestMode)
+	wav := common.PCMToWAV(make([]byte, 48000*3), 24000, 1)
+	server := newGeminiAudioStandIn(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		assert.Equal(t, "/v1beta/models/gemini-2.5-flash:generateContent", r.URL.Path)
+		assert.Contains(t, gjson.GetBytes(body, "contents.0.parts.0.text").String(), "The spoken language is de.")
+		assert.Equal(t, "audio/wav", gjson.GetBytes(body, "contents.0.parts.1.inlineData.mimeType").String())
+		assert.Equal(t, base64.StdEncoding.EncodeToString(wav), gjson.GetBytes(body, "contents.0.parts.1.inlineData.data").String())
+		writeGeminiJSON(w, map[string]any{"candidates": []any{
+			map[string]any{"content": map[string]any{"role": "model", "parts": []any{
+				map[string]any{"text": "thinking...", "thought": true},
+				map[string]any{"text": " Guten Tag \n"},
+			}}},
+		}})
+	})
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	part, err := writer.CreateFormFile("file", "greeting.wav")
+	require.NoError(t, err)
+	_, _ = part.Write(wav)
+	require.NoError(t, writer.WriteField("model", "gemini-2.5-flash"))
+	require.NoError(t, writer.WriteField("language", "de"))
+	require.NoError(t, writer.Close())
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", &form)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	info := newGeminiAudioRelayInfo(server.URL, relayconstant.RelayModeAudioTranscription, "gemini-2.5-flash",
+		&dto.AudioRequest{Model: "gemini-2.5-flash", ResponseFormat: "text"})
+
+	usage := relayGeminiAudio(t, c, info)
+
+	assert.Equal(t, "Guten Tag", recorder.Body.String())
+	assert.Equal(t, common.AudioDurationTokens(3), usage.PromptTokens)
+	assert.Equal(t, usage.PromptTokens, usage.TotalTokens)
+}
@@ -0,0 +1,100 @@
+package gemini
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/relaykit/types"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// buildImageEditRequest converts a multipart edit or variation request into a Gemini image model
+// generateContent request. Gemini has no native mask input, so the mask is sent as the last
+// image together with an instruction describing it.
+func buildImageEditRequest(info *relaycommon.RelayInfo, request dto.ImageRequest, input *helper.ImageEditInput) *dto.GeminiChatRequest {
+	prompt := helper.EmulatedImageEditPrompt(info.RelayMode, request.Prompt, input.Mask != nil)
+	parts := []dto.GeminiPart{{Text: prompt}}
+	images := input.Images
+	if input.Mask != nil {
+		images = append(images[:len(images):len(images)], input.Mask)
+	}
+	for _, image := range images {
+		parts = append(parts, dto.GeminiPart{InlineData: &dto.GeminiInlineData{
+			MimeType: image.MimeType,
+			Data:     image.Base64(),
+		}})
+	}
+
+	geminiRequest := &dto.GeminiChatRequest{
+		Contents: []dto.GeminiChatContent{{
+			Role:  "user",
+			Parts: parts,
+		}},
+		GenerationConfig: dto.GeminiChatGenerationConfig{
+			ResponseModalities: []string{"TEXT", "IMAGE"},
+		},
+	}
+	if size := strings.TrimSpace(request.Size); size != "" && size != "auto" {
+		geminiRequest.GenerationConfig.ImageConfig, _ = common.Marshal(map[string]any{
+			"aspectRatio": imageAspectRatio(size),
+		})
+	}
+	return geminiRequest
+}
+
+// GeminiImageEditHandler returns the inline images of a Gemini image model response as an
+// OpenAI image response. Usage follows the Gemini token counts and per-call prices are
+// settled by the number of images actually returned.
+func GeminiImageEditHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	defer service.CloseResponseBodyGracefully(resp)
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	var geminiResponse dto.GeminiChatResponse
+	if err := common.Unmarshal(responseBody, &geminiResponse); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+
+	openAIResponse := dto.ImageResponse{
+		Created: common.GetTimestamp(),
+		Data:    make([]dto.ImageData, 0),
+	}
+	var revisedPrompt strings.Builder
+	for _, candidate := range geminiResponse.Candidates {
+		for _, part := range candidate.Content.Parts {
+			if part.Thought {
+				continue
+			}
+			if part.InlineData != nil && strings.HasPrefix(part.InlineData.MimeType, "image/") {
+				openAIResponse.Data = append(openAIResponse.Data, dto.ImageData{B64Json: part.InlineData.Data})
+			} else if part.Text != "" {
+				revisedPrompt.WriteString(part.Text)
+			}
+		}
+	}
+	if len(openAIResponse.Data) == 0 {
+		return nil, types.NewOpenAIError(errors.New("no images generated"), types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	for i := range openAIResponse.Data {
+		openAIResponse.Data[i].RevisedPrompt = strings.TrimSpace(revisedPrompt.String())
+	}
+
+	jsonResponse, err := common.Marshal(openAIResponse)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	c.Data(http.StatusOK, "application/json", jsonResponse)
+
+	helper.UpdateImageCount(info, int64(len(openAIResponse.Data)))
+	usage := buildUsageFromGeminiResponse(c, info, &geminiResponse)
+	return &usage, nil
+}
@@ -0,0 +1,93 @@
+package gemini
+
+import (
+	"bytes"
+	"encoding/base64"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func TestGeminiImageEditSendsImagesAndMaskInline(t *testing.T) {
+	service.InitHttpClient()
+	gin.SetMode(gin.TestMode)
+	source := []byte("source image")
+	mask := []byte("mask image")
+	edited := base64.StdEncoding.EncodeToString([]byte("edited image"))
+	server := newGeminiAudioStandIn(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		assert.Equal(t, "/v1beta/models/gemini-2.5-flash-image:generateContent", r.URL.Path)
+		assert.Equal(t, "IMAGE", gjson.GetBytes(body, "generationConfig.responseModalities.1").String())
+		assert.Equal(t, "3:2", gjson.GetBytes(body, "generationConfig.imageConfig.aspectRatio").String())
+		assert.Contains(t, gjson.GetBytes(body, "contents.0.parts.0.text").String(), "add a hat\n")
+		assert.Equal(t, base64.StdEncoding.EncodeToString(source), gjson.GetBytes(body, "contents.0.parts.1.inlineData.data").String())
+		assert.Equal(t, base64.StdEncoding.EncodeToString(mask), gjson.GetBytes(body, "contents.0.parts.2.inlineData.data").String())
+		writeGeminiJSON(w, map[string]any{
+			"candidates": []any{map[string]any{"content": map[string]any{"role": "model", "parts": []any{
+				map[string]any{"text": "Here is the cat with a hat."},
+				map[string]any{"inlineData": map[string]any{"mimeType": "image/png", "data": edited}},
+			}}}},
+			"usageMetadata": map[string]any{"promptTokenCount": 600, "candidatesTokenCount": 1290, "totalTokenCount": 1890},
+		})
+	})
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	for field, data := range map[string][]byte{"image": source, "mask": mask} {
+		part, err := writer.CreateFormFile(field, field+".png")
+		require.NoError(t, err)
+		_, _ = part.Write(data)
+	}
+	require.NoError(t, writer.Close())
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/edits", &form)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	request := dto.ImageRequest{Model: "gemini-2.5-flash-image", Prompt: "add a hat", Size: "1536x1024"}
+	info := &relaycommon.RelayInfo{
+		RelayMode:       relayconstant.RelayModeImagesEdits,
+		OriginModelName: request.Model,
+		Request:         &request,
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ChannelBaseUrl:    server.URL,
+			ApiKey:            "test-key",
+			UpstreamModelName: request.Model,
+		},
+	}
+
+	adaptor := &Adaptor{}
+	converted, err := adaptor.ConvertImageRequest(c, info, request)
+	require.NoError(t, err)
+	body, err := common.Marshal(converted)
+	require.NoError(t, err)
+	resp, err := adaptor.DoRequest(c, info, bytes.NewReader(body))
+	require.NoError(t, err)
+	usage, apiErr := adaptor.DoResponse(c, resp.(*http.Response), info)
+	require.Nil(t, apiErr)
+
+	assert.Equal(t, edited, gjson.Get(recorder.Body.String(), "data.0.b64_json").String())
+	assert.Equal(t, "Here is the cat with a hat.", gjson.Get(recorder.Body.String(), "data.0.revised_prompt").String())
+	assert.Equal(t, 1290, usage.(*dto.Usage).CompletionTokens)
+}
+
+func TestGeminiImageEditRejectsImagenModels(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	info := &relaycommon.RelayInfo{
+		RelayMode:   relayconstant.RelayModeImagesVariations,
+		ChannelMeta: &relaycommon.ChannelMeta{UpstreamModelName: "imagen-4.0-generate-001"},
+	}
+	_, err := (&Adaptor{}).ConvertImageRequest(c, info, dto.ImageRequest{})
+	assert.Error(t, err)
+}
@@ -14,6 +14,7 @@ import (
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/relaykit/relayconvert"
 	"github.com/QuantumNous/new-api/relaykit/types"
 	"github.com/QuantumNous/new-api/service"
 
@@ -42,59 +43,6 @@ type ollamaChatStreamChunk struct {
 	EvalDuration       int64  `json:"eval_duration"`
 }
 
-func ollamaToolCallsToOpenAI(toolCalls []OllamaToolCall, startIndex int, includeIndex bool) ([]dto.ToolCallResponse, int) {
-	if len(toolCalls) == 0 {
-		return nil, startIndex
-	}
-	result := make([]dto.ToolCallResponse, 0, len(toolCalls))
-	for _, tc := range toolCalls {
-		var argBytes []byte
-		var err error
-		if tc.Function.Arguments == nil {
-			argBytes = []byte("{}")
-		} else {
-			argBytes, err = common.Marshal(tc.Function.Arguments)
-			if err != nil || len(argBytes) == 0 {
-				argBytes = []byte("{}")
-			}
-		}
-		toolCallID := tc.ID
-		if toolCallID == "" {
-			toolCallID = fmt.Sprintf("call_%d", startIndex)
-		}
-		tr := dto.ToolCallResponse{
-			ID:   toolCallID,
-			Type: "function",
-			Function: dto.FunctionResponse{
-				Name:      tc.Function.Name,
-				Arguments: string(argBytes),
-			},
-		}
-		if includeIndex {
-			tr.SetIndex(startIndex)
-		}
-		startIndex++
-		result = append(result, tr)
-	}
-	return result, startIndex
-}
-
-func toUnix(ts string) int64 {
-	if ts == "" {
-		return time.Now().Unix()
-	}
-	// try time.RFC3339 or with nanoseconds
-	t, err := time.Parse(time.RFC3339Nano, ts)
-	if err != nil {
-		t2, err2 := time.Parse(time.RFC3339, ts)
-		if err2 == nil {
-			return t2.Unix()
-		}
-		return time.Now().Unix()
-	}
-	return t.Unix()
-}
-
 func ollamaStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
 	if resp == nil || resp.Body == nil {
 		return nil, types.NewOpenAIError(fmt.Errorf("empty response"), types.ErrorCodeBadResponse, http.StatusBadRequest)
@@ -127,7 +75,7 @@ func ollamaStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 		if chunk.Model != "" {
 			model = chunk.Model
 		}
-		created = toUnix(chunk.CreatedAt)
+		created = relayconvert.OllamaCreatedAtUnix(chunk.CreatedAt)
 
 		if !chunk.Done {
 			// delta content
@@ -165,7 +113,7 @@ func ollamaStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 			}
 			// tool calls
 			if chunk.Message != nil && len(chunk.Message.ToolCalls) > 0 {
-				delta.Choices[0].Delta.ToolCalls, toolCallIndex = ollamaToolCallsToOpenAI(chunk.Message.ToolCalls, toolCallIndex, true)
+				delta.Choices[0].Delta.ToolCalls, toolCallIndex = relayconvert.OllamaToolCallsToOpenAI(chunk.Message.ToolCalls, toolCallIndex, true)
 			}
 			if data, err := common.Marshal(delta); err == nil {
 				_ = helper.StringData(c, string(data))
@@ -261,7 +209,7 @@ func ollamaChatHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.R
 		}
 		if ck.Message != nil && len(ck.Message.ToolCalls) > 0 {
 			var converted []dto.ToolCallResponse
-			converted, toolCallIndex = ollamaToolCallsToOpenAI(ck.Message.ToolCalls, toolCallIndex, false)
+			converted, toolCallIndex = relayconvert.OllamaToolCallsToOpenAI(ck.Message.ToolCalls, toolCallIndex, false)
 			toolCalls = append(toolCalls, converted...)
 		}
 	}
@@ -289,7 +237,7 @@ func ollamaChatHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.R
 			aggContent.WriteString(single.Message.Content)
 			if len(single.Message.ToolCalls) > 0 {
 				var converted []dto.ToolCallResponse
-				converted, toolCallIndex = ollamaToolCallsToOpenAI(single.Message.ToolCalls, toolCallIndex, false)
+				converted, toolCallIndex = relayconvert.OllamaToolCallsToOpenAI(single.Message.ToolCalls, toolCallIndex, false)
 				toolCalls = append(toolCalls, converted...)
 			}
 		} else {
@@ -301,7 +249,7 @@ func ollamaChatHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.R
 	if model == "" {
 		model = info.UpstreamModelName
 	}
-	created := toUnix(lastChunk.CreatedAt)
+	created := relayconvert.OllamaCreatedAtUnix(lastChunk.CreatedAt)
 	usage := &dto.Usage{PromptTokens: lastChunk.PromptEvalCount, CompletionTokens: lastChunk.EvalCount, TotalTokens: lastChunk.PromptEvalCount + lastChunk.EvalCount}
 	content := aggContent.String()
 	finishReason := lastChunk.DoneReason
@@ -1,10 +1,9 @@
 package openai
 
 import (
-	"bytes"
+	"encoding/base64"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
 
 	"github.com/QuantumNous/new-api/common"
@@ -18,6 +17,8 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+const openAITTSSampleRate = 24000
+
 func OpenaiTTSHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) *dto.Usage {
 	// the status code has been judged before, if there is a body reading failure,
 	// it should be regarded as a non-recoverable error, so it should not return err for external retry.
@@ -26,93 +27,64 @@ func OpenaiTTSHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 	// the subsequent failure of the response body should be regarded as a non-recoverable error,
 	// and can be terminated directly.
 	defer service.CloseResponseBodyGracefully(resp)
-	usage := &dto.Usage{}
-	usage.PromptTokens = info.GetEstimatePromptTokens()
-	usage.TotalTokens = info.GetEstimatePromptTokens()
 	for k, v := range resp.Header {
 		if !service.ShouldCopyUpstreamHeader(c, k, v) {
 			continue
 		}
 		c.Writer.Header().Set(k, v[0])
 	}
-	c.Writer.WriteHeader(resp.StatusCode)
+
+	audioFormat := "mp3"
+	if audioReq, ok := info.Request.(*dto.AudioRequest); ok && audioReq.ResponseFormat != "" {
+		audioFormat = audioReq.ResponseFormat
+	}
 
 	if info.IsStream {
+		usage := &dto.Usage{}
+		var audio []byte
+		c.Writer.WriteHeader(resp.StatusCode)
 		helper.StreamScannerHandler(c, resp, info, func(data string, sr *helper.StreamResult) {
-			if service.SundaySearch(data, "usage") {
-				var simpleResponse dto.SimpleResponse
-				if err := common.Unmarshal([]byte(data), &simpleResponse); err != nil {
-					logger.LogError(c, err.Error())
-					sr.Error(err)
-				} else if simpleResponse.Usage.TotalTokens != 0 {
-					usage.PromptTokens = simpleResponse.Usage.InputTokens
-					usage.CompletionTokens = simpleResponse.OutputTokens
-					usage.TotalTokens = simpleResponse.TotalTokens
+			var event dto.AudioSpeechStreamEvent
+			if err := common.Unmarshal([]byte(data), &event); err != nil {
+				logger.LogError(c, err.Error())
+				sr.Error(err)
+			} else if event.Type == helper.SpeechEventAudioDelta {
+				if chunk, err := base64.StdEncoding.DecodeString(event.Audio); err == nil {
+					audio = append(audio, chunk...)
 				}
+			} else if event.Usage != nil && event.Usage.TotalTokens != 0 {
+				usage.PromptTokens = event.Usage.InputTokens
+				usage.CompletionTokens = event.Usage.OutputTokens
+				usage.TotalTokens = event.Usage.TotalTokens
 			}
 			if err := helper.StringData(c, data); err != nil {
 				sr.Error(err)
 			}
 		})
-	} else {
-		common.SetContextKey(c, constant.ContextKeyLocalCountTokens, true)
-		// 读取响应体到缓冲区
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			logger.LogError(c, fmt.Sprintf("failed to read TTS response body: %v", err))
-			c.Writer.WriteHeaderNow()
+		if usage.TotalTokens != 0 {
 			return usage
 		}
-
-		// 写入响应到客户端
-		c.Writer.WriteHeaderNow()
-		_, err = c.Writer.Write(bodyBytes)
+		// 本地引擎等上游不回传用量时，按已输出音频的时长计费
+		duration, err := common.GetAudioBytesDuration(c.Request.Context(), audio, audioFormat, openAITTSSampleRate)
 		if err != nil {
-			logger.LogError(c, fmt.Sprintf("failed to write TTS response: %v", err))
-		}
-
-		// 计算音频时长并更新 usage
-		audioFormat := "mp3" // 默认格式
-		if audioReq, ok := info.Request.(*dto.AudioRequest); ok && audioReq.ResponseFormat != "" {
-			audioFormat = audioReq.ResponseFormat
+			logger.LogWarn(c, fmt.Sprintf("failed to get audio duration: %v", err))
 		}
+		return helper.SpeechUsage(info, duration, len(audio))
+	}
 
-		var duration float64
-		var durationErr error
-
-		if audioFormat == "pcm" {
-			// PCM 格式没有文件头，根据 OpenAI TTS 的 PCM 参数计算时长
-			// 采样率: 24000 Hz, 位深度: 16-bit (2 bytes), 声道数: 1
-			const sampleRate = 24000
-			const bytesPerSample = 2
-			const channels = 1
-			duration = float64(len(bodyBytes)) / float64(sampleRate*bytesPerSample*channels)
-		} else {
-			ext := "." + audioFormat
-			reader := bytes.NewReader(bodyBytes)
-			duration, durationErr = common.GetAudioDuration(c.Request.Context(), reader, ext)
-		}
-
-		usage.PromptTokensDetails.TextTokens = usage.PromptTokens
-
-		if durationErr != nil {
-			logger.LogWarn(c, fmt.Sprintf("failed to get audio duration: %v", durationErr))
-			// 如果无法获取时长，则设置保底的 CompletionTokens，根据body大小计算
-			sizeInKB := float64(len(bodyBytes)) / 1000.0
-			estimatedTokens := int(math.Ceil(sizeInKB)) // 粗略估算每KB约等于1 token
-			usage.CompletionTokens = estimatedTokens
-			usage.CompletionTokenDetails.AudioTokens = estimatedTokens
-		} else if duration > 0 {
-			// 计算 token: ceil(duration) / 60.0 * 1000，即每分钟 1000 tokens。
-			// duration 解析自上游返回的音频元数据，饱和转换防止 int 回绕。
-			completionTokens := common.QuotaRound(math.Ceil(duration) / 60.0 * 1000)
-			usage.CompletionTokens = completionTokens
-			usage.CompletionTokenDetails.AudioTokens = completionTokens
-		}
-		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	common.SetContextKey(c, constant.ContextKeyLocalCountTokens, true)
+	// 边收边转发，长文本合成时客户端无需等待完整音频
+	bodyBytes, err := helper.CopyAudioStream(c, "", resp.Body)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to relay TTS response: %v", err))
 	}
 
-	return usage
+	// PCM 格式没有文件头，按 OpenAI TTS 的 PCM 参数（24000 Hz、16-bit、单声道）计算时长
+	duration, durationErr := common.GetAudioBytesDuration(c.Request.Context(), bodyBytes, audioFormat, openAITTSSampleRate)
+	if durationErr != nil {
+		logger.LogWarn(c, fmt.Sprintf("failed to get audio duration: %v", durationErr))
+	}
+	return helper.SpeechUsage(info, duration, len(bodyBytes))
 }
 
 func OpenaiSTTHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo, responseFormat string) (*types.NewAPIError, *dto.Usage) {
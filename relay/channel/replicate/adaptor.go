@@ -17,6 +17,7 @@ import (
 	"github.com/QuantumNous/new-api/relay/channel"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/relaykit/dto"
 	"github.com/QuantumNous/new-api/relaykit/types"
 	"github.com/QuantumNous/new-api/service"
@@ -55,9 +56,8 @@ func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Header, info *rel
 	channel.SetupApiRequestHeader(info, c, req)
 	req.Set("Authorization", "Bearer "+info.ApiKey)
 	req.Set("Prefer", "wait")
-	if req.Get("Content-Type") == "" {
-		req.Set("Content-Type", "application/json")
-	}
+	// predictions are always JSON, including those converted from multipart edits
+	req.Set("Content-Type", "application/json")
 	if req.Get("Accept") == "" {
 		req.Set("Accept", "application/json")
 	}
@@ -73,10 +73,20 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 			request.Prompt = v
 		}
 	}
+	request.Prompt = helper.EmulatedImageEditPrompt(info.RelayMode, request.Prompt, false)
 	if strings.TrimSpace(request.Prompt) == "" {
 		return nil, errors.New("replicate adaptor: prompt is required")
 	}
 
+	var editInput *helper.ImageEditInput
+	if info.RelayMode == relayconstant.RelayModeImagesEdits || info.RelayMode == relayconstant.RelayModeImagesVariations {
+		input, err := helper.ReadImageEditInput(c)
+		if err != nil {
+			return nil, fmt.Errorf("replicate adaptor: %w", err)
+		}
+		editInput = input
+	}
+
 	modelName := strings.TrimSpace(info.UpstreamModelName)
 	if modelName == "" {
 		modelName = strings.TrimSpace(request.Model)
@@ -84,6 +94,11 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 	if modelName == "" {
 		modelName = ModelFlux11Pro
 	}
+	if editInput != nil && editInput.Mask != nil && !strings.Contains(modelName, "fill") {
+		// masks are only honoured by inpainting models; switching models here would
+		// bill the requested model for a different upstream run
+		return nil, fmt.Errorf("replicate adaptor: mask requires an inpainting model such as %s", ModelFluxFillPro)
+	}
 	info.UpstreamModelName = modelName
 
 	info.RequestURLPath = fmt.Sprintf("/v1/models/%s/predictions", modelName)
@@ -124,15 +139,26 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 		inputPayload["prompt_upsampling"] = true
 	}
 
-	if info.RelayMode == relayconstant.RelayModeImagesEdits {
-		imageURL, err := uploadFileFromForm(c, info, "image", "image[]", "image_prompt")
+	if editInput != nil {
+		imageURL, err := uploadFile(info, editInput.Images[0])
 		if err != nil {
 			return nil, err
 		}
-		if imageURL == "" {
-			return nil, errors.New("replicate adaptor: image file is required for edits")
+		if editInput.Mask == nil {
+			inputPayload["image_prompt"] = imageURL
+		} else {
+			// Flux Fill expects white for the regions to repaint, OpenAI masks mark them transparent
+			mask, err := helper.InpaintMask(editInput.Mask)
+			if err != nil {
+				return nil, fmt.Errorf("replicate adaptor: %w", err)
+			}
+			maskURL, err := uploadFile(info, mask)
+			if err != nil {
+				return nil, err
+			}
+			inputPayload["image"] = imageURL
+			inputPayload["mask"] = maskURL
 		}
-		inputPayload["image_prompt"] = imageURL
 	}
 
 	if len(request.ExtraFields) > 0 {
@@ -287,6 +313,7 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 	c.Writer.WriteHeader(http.StatusOK)
 	_, _ = c.Writer.Write(responseBytes)
 
+	helper.UpdateImageCount(info, int64(len(imageResponse.Data)))
 	usage := &dto.Usage{}
 	return usage, nil
 }
@@ -397,57 +424,13 @@ func normalizeFluxDimension(value int) int {
 	return value
 }
 
-func uploadFileFromForm(c *gin.Context, info *relaycommon.RelayInfo, fieldCandidates ...string) (string, error) {
-	if info == nil {
-		return "", errors.New("replicate adaptor: relay info is nil")
-	}
-
-	mf := c.Request.MultipartForm
-	if mf == nil {
-		if _, err := c.MultipartForm(); err != nil {
-			return "", fmt.Errorf("replicate adaptor: parse multipart form failed: %w", err)
-		}
-		mf = c.Request.MultipartForm
-	}
-	if mf == nil || len(mf.File) == 0 {
-		return "", nil
-	}
-
-	if len(fieldCandidates) == 0 {
-		fieldCandidates = []string{"image", "image[]", "image_prompt"}
-	}
-
-	var fileHeader *multipart.FileHeader
-	for _, key := range fieldCandidates {
-		if files := mf.File[key]; len(files) > 0 {
-			fileHeader = files[0]
-			break
-		}
-	}
-	if fileHeader == nil {
-		for _, files := range mf.File {
-			if len(files) > 0 {
-				fileHeader = files[0]
-				break
-			}
-		}
-	}
-	if fileHeader == nil {
-		return "", nil
-	}
-
-	file, err := fileHeader.Open()
-	if err != nil {
-		return "", fmt.Errorf("replicate adaptor: failed to open image file: %w", err)
-	}
-	defer file.Close()
-
+func uploadFile(info *relaycommon.RelayInfo, image *helper.ImageFile) (string, error) {
 	var body bytes.Buffer
 	writer := multipart.NewWriter(&body)
 
 	hdr := make(textproto.MIMEHeader)
-	hdr.Set("Content-Disposition", fmt.Sprintf("form-data; name=\"content\"; filename=\"%s\"", fileHeader.Filename))
-	contentType := fileHeader.Header.Get("Content-Type")
+	hdr.Set("Content-Disposition", fmt.Sprintf("form-data; name=\"content\"; filename=\"%s\"", image.Filename))
+	contentType := image.MimeType
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
@@ -458,7 +441,7 @@ func uploadFileFromForm(c *gin.Context, info *relaycommon.RelayInfo, fieldCandid
 		writer.Close()
 		return "", fmt.Errorf("replicate adaptor: create upload form failed: %w", err)
 	}
-	if _, err := io.Copy(part, file); err != nil {
+	if _, err := part.Write(image.Data); err != nil {
 		writer.Close()
 		return "", fmt.Errorf("replicate adaptor: copy image content failed: %w", err)
 	}
@@ -5,8 +5,11 @@ const (
 	ChannelName = "replicate"
 	// ModelFlux11Pro is the default image generation model supported by this channel.
 	ModelFlux11Pro = "black-forest-labs/flux-1.1-pro"
+	// ModelFluxFillPro is the inpainting model used for mask-based edits.
+	ModelFluxFillPro = "black-forest-labs/flux-fill-pro"
 )
 
 var ModelList = []string{
 	ModelFlux11Pro,
+	ModelFluxFillPro,
 }
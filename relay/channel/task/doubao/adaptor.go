@@ -195,6 +195,9 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayIn
 	} else {
 		info.UpstreamModelName = body.Model
 	}
+	if info.UpstreamCallbackURL != "" {
+		body.CallbackURL = info.UpstreamCallbackURL
+	}
 	data, err := common.Marshal(body)
 	if err != nil {
 		return nil, err
@@ -263,6 +266,30 @@ func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any, proxy
 	return client.Do(req)
 }
 
+// CancelTask cancels a queued task; Ark rejects cancellation once generation has started.
+func (a *TaskAdaptor) CancelTask(baseUrl, key string, body map[string]any, proxy string) (*http.Response, error) {
+	taskID, ok := body["task_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid task_id")
+	}
+
+	uri := fmt.Sprintf("%s/api/v3/contents/generations/tasks/%s", baseUrl, taskID)
+
+	req, err := http.NewRequest(http.MethodDelete, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+
+	client, err := service.GetHttpClientWithProxy(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("new proxy http client failed: %w", err)
+	}
+	return client.Do(req)
+}
+
 func (a *TaskAdaptor) GetModelList() []string {
 	return ModelList
 }
@@ -345,6 +372,16 @@ func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, e
 	return &taskResult, nil
 }
 
+// ParseTaskCallback handles Doubao's push notification, whose body is the same task object
+// returned by the query API.
+func (a *TaskAdaptor) ParseTaskCallback(body []byte) (*relaycommon.TaskCallback, error) {
+	var callback responsePayload
+	if err := common.Unmarshal(body, &callback); err != nil {
+		return nil, errors.Wrap(err, "unmarshal callback body failed")
+	}
+	return &relaycommon.TaskCallback{UpstreamTaskID: callback.ID, ResultBody: body}, nil
+}
+
 func (a *TaskAdaptor) ConvertToOpenAIVideo(originTask *model.Task) ([]byte, error) {
 	var dResp responseTask
 	if err := common.Unmarshal(originTask.Data, &dResp); err != nil {
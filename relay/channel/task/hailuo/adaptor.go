@@ -163,6 +163,9 @@ func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq, in
 	if err := req.UnmarshalMetadata(&videoRequest); err != nil {
 		return nil, errors.Wrap(err, "unmarshal metadata to video request failed")
 	}
+	if info.UpstreamCallbackURL != "" {
+		videoRequest.CallbackURL = info.UpstreamCallbackURL
+	}
 
 	return videoRequest, nil
 }
@@ -224,6 +227,43 @@ func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, e
 	return &taskResult, nil
 }
 
+// callbackStatuses maps the lowercase states MiniMax pushes to the query API's values.
+var callbackStatuses = map[string]string{
+	"preparing":  TaskStatusPreparing,
+	"queueing":   TaskStatusQueueing,
+	"processing": TaskStatusProcessing,
+	"success":    TaskStatusSuccess,
+	"failed":     TaskStatusFailed,
+	"fail":       TaskStatusFailed,
+}
+
+// ParseTaskCallback handles MiniMax's push notification. The first request after submission
+// is a URL verification carrying only "challenge", which must be echoed back.
+func (a *TaskAdaptor) ParseTaskCallback(body []byte) (*relaycommon.TaskCallback, error) {
+	var callback struct {
+		Challenge string `json:"challenge"`
+		QueryTaskResponse
+	}
+	if err := common.Unmarshal(body, &callback); err != nil {
+		return nil, errors.Wrap(err, "unmarshal callback body failed")
+	}
+	if callback.Challenge != "" {
+		reply, err := common.Marshal(map[string]string{"challenge": callback.Challenge})
+		if err != nil {
+			return nil, err
+		}
+		return &relaycommon.TaskCallback{Reply: reply}, nil
+	}
+	if status, ok := callbackStatuses[strings.ToLower(callback.Status)]; ok {
+		callback.Status = status
+	}
+	resultBody, err := common.Marshal(callback.QueryTaskResponse)
+	if err != nil {
+		return nil, err
+	}
+	return &relaycommon.TaskCallback{UpstreamTaskID: callback.TaskID, ResultBody: resultBody}, nil
+}
+
 func (a *TaskAdaptor) ConvertToOpenAIVideo(originTask *model.Task) ([]byte, error) {
 	var hailuoResp QueryTaskResponse
 	if err := common.Unmarshal(originTask.Data, &hailuoResp); err != nil {
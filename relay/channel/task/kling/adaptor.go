@@ -2,6 +2,7 @@ package kling
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math"
@@ -287,6 +288,9 @@ func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq, in
 	if err := taskcommon.UnmarshalMetadata(req.Metadata, &r); err != nil {
 		return nil, errors.Wrap(err, "unmarshal metadata failed")
 	}
+	if info.UpstreamCallbackURL != "" {
+		r.CallbackUrl = info.UpstreamCallbackURL
+	}
 	return &r, nil
 }
 
@@ -374,6 +378,25 @@ func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, e
 	return taskInfo, nil
 }
 
+// ParseTaskCallback handles Kling's push notification, whose body is the bare task object
+// that the query API wraps in "data".
+func (a *TaskAdaptor) ParseTaskCallback(body []byte) (*relaycommon.TaskCallback, error) {
+	var callback struct {
+		TaskId string `json:"task_id"`
+	}
+	if err := common.Unmarshal(body, &callback); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal callback body")
+	}
+	resultBody, err := common.Marshal(map[string]any{
+		"code": 0,
+		"data": json.RawMessage(body),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &relaycommon.TaskCallback{UpstreamTaskID: callback.TaskId, ResultBody: resultBody}, nil
+}
+
 func isNewAPIRelay(apiKey string) bool {
 	return strings.HasPrefix(apiKey, "sk-")
 }
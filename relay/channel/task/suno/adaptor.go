@@ -2,6 +2,7 @@ package suno
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -81,6 +82,10 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayIn
 	if !ok {
 		return nil, fmt.Errorf("task_request not found in context")
 	}
+	if req, ok := sunoRequest.(*dto.SunoSubmitReq); ok && req != nil {
+		// The client's notify_hook is served by the gateway itself; upstream only calls back the gateway.
+		req.NotifyHook = info.UpstreamCallbackURL
+	}
 	data, err := common.Marshal(sunoRequest)
 	if err != nil {
 		return nil, err
@@ -120,6 +125,22 @@ func (a *TaskAdaptor) DoResponse(c *gin.Context, resp *http.Response, info *rela
 	return sunoResponse.Data, nil, nil
 }
 
+// ParseTaskCallback accepts either a bare task object or one wrapped in {code, data}.
+func (a *TaskAdaptor) ParseTaskCallback(body []byte) (*relaycommon.TaskCallback, error) {
+	var wrapped dto.TaskResponse[json.RawMessage]
+	if err := common.Unmarshal(body, &wrapped); err == nil && len(wrapped.Data) > 0 && wrapped.Data[0] == '{' {
+		body = wrapped.Data
+	}
+	var item dto.SunoDataResponse
+	if err := common.Unmarshal(body, &item); err != nil {
+		return nil, err
+	}
+	if item.TaskID == "" {
+		return nil, fmt.Errorf("task_id is empty in callback")
+	}
+	return &relaycommon.TaskCallback{UpstreamTaskID: item.TaskID, ResultBody: body}, nil
+}
+
 func (a *TaskAdaptor) GetModelList() []string {
 	return ModelList
 }
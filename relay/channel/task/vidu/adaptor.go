@@ -213,6 +213,35 @@ func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any, proxy
 	return client.Do(req)
 }
 
+// CancelTask cancels a task that has not started generating yet.
+func (a *TaskAdaptor) CancelTask(baseUrl, key string, body map[string]any, proxy string) (*http.Response, error) {
+	taskID, ok := body["task_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid task_id")
+	}
+
+	url := fmt.Sprintf("%s/ent/v2/tasks/%s/cancel", baseUrl, taskID)
+	payload, err := common.Marshal(map[string]string{"id": taskID})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Token "+key)
+
+	client, err := service.GetHttpClientWithProxy(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("new proxy http client failed: %w", err)
+	}
+	return client.Do(req)
+}
+
 func (a *TaskAdaptor) GetModelList() []string {
 	return []string{"viduq2", "viduq1", "vidu2.0", "vidu1.5"}
 }
@@ -238,6 +267,9 @@ func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq, in
 	if err := taskcommon.UnmarshalMetadata(req.Metadata, &r); err != nil {
 		return nil, errors.Wrap(err, "unmarshal metadata failed")
 	}
+	if info.UpstreamCallbackURL != "" {
+		r.CallbackUrl = info.UpstreamCallbackURL
+	}
 	return &r, nil
 }
 
@@ -273,6 +305,22 @@ func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, e
 	return taskInfo, nil
 }
 
+// ParseTaskCallback handles Vidu's push notification, which carries the same fields as the
+// creations query plus the task id.
+func (a *TaskAdaptor) ParseTaskCallback(body []byte) (*relaycommon.TaskCallback, error) {
+	var callback struct {
+		Id     string `json:"id"`
+		TaskId string `json:"task_id"`
+	}
+	if err := common.Unmarshal(body, &callback); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal callback body")
+	}
+	return &relaycommon.TaskCallback{
+		UpstreamTaskID: taskcommon.DefaultString(callback.TaskId, callback.Id),
+		ResultBody:     body,
+	}, nil
+}
+
 func (a *TaskAdaptor) ConvertToOpenAIVideo(originTask *model.Task) ([]byte, error) {
 	var viduResp taskResultResponse
 	if err := common.Unmarshal(originTask.Data, &viduResp); err != nil {
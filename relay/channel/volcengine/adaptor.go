@@ -10,12 +10,14 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/QuantumNous/new-api/common"
 	channelconstant "github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/relay/channel"
 	"github.com/QuantumNous/new-api/relay/channel/claude"
 	"github.com/QuantumNous/new-api/relay/channel/openai"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/relaykit/dto"
 	"github.com/QuantumNous/new-api/relaykit/types"
 	"github.com/QuantumNous/new-api/setting/model_setting"
@@ -109,6 +111,11 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 	switch info.RelayMode {
 	case constant.RelayModeImagesGenerations:
 		return request, nil
+	case constant.RelayModeImagesEdits, constant.RelayModeImagesVariations:
+		if c.ContentType() != gin.MIMEMultipartPOSTForm {
+			return request, nil
+		}
+		return convertImageEditRequest(c, info, request)
 	// 根据官方文档,并没有发现豆包生图支持表单请求:https://www.volcengine.com/docs/82379/1824121
 	//case constant.RelayModeImagesEdits:
 	//
@@ -216,6 +223,32 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 	}
 }
 
+// convertImageEditRequest 将 multipart 图片编辑与变体请求转换为豆包生图 JSON 请求：
+// 输入图片以 data URL 作为参考图传入，蒙版作为最后一张参考图并在提示词中说明
+func convertImageEditRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
+	input, err := helper.ReadImageEditInput(c)
+	if err != nil {
+		return nil, err
+	}
+	images := make([]string, 0, len(input.Images)+1)
+	for _, image := range input.Images {
+		images = append(images, image.DataURL())
+	}
+	if input.Mask != nil {
+		images = append(images, input.Mask.DataURL())
+	}
+	var imageField any = images
+	if len(images) == 1 {
+		imageField = images[0]
+	}
+	if request.Image, err = common.Marshal(imageField); err != nil {
+		return nil, err
+	}
+	request.Mask = nil
+	request.Prompt = helper.EmulatedImageEditPrompt(info.RelayMode, request.Prompt, input.Mask != nil)
+	return request, nil
+}
+
 func detectImageMimeType(filename string) string {
 	ext := strings.ToLower(filepath.Ext(filename))
 	switch ext {
@@ -265,7 +298,7 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 		case constant.RelayModeEmbeddings:
 			return fmt.Sprintf("%s/api/v3/embeddings", baseUrl), nil
 		//豆包的图生图也走generations接口: https://www.volcengine.com/docs/82379/1824121
-		case constant.RelayModeImagesGenerations, constant.RelayModeImagesEdits:
+		case constant.RelayModeImagesGenerations, constant.RelayModeImagesEdits, constant.RelayModeImagesVariations:
 			return fmt.Sprintf("%s/api/v3/images/generations", baseUrl), nil
 		//case constant.RelayModeImagesEdits:
 		//	return fmt.Sprintf("%s/api/v3/images/edits", baseUrl), nil
@@ -294,7 +327,7 @@ func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Header, info *rel
 		}
 		req.Set("Content-Type", "application/json")
 		return nil
-	} else if info.RelayMode == constant.RelayModeImagesEdits {
+	} else if info.RelayMode == constant.RelayModeImagesEdits || info.RelayMode == constant.RelayModeImagesVariations {
 		req.Set("Content-Type", gin.MIMEJSON)
 	}
 
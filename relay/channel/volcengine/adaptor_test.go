@@ -0,0 +1,57 @@
+package volcengine
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	pngenc "image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func TestConvertMultipartVariationToGenerationsRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var png bytes.Buffer
+	require.NoError(t, pngenc.Encode(&png, image.NewRGBA(image.Rect(0, 0, 1, 1))))
+	source := png.Bytes()
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	part, err := writer.CreateFormFile("image", "source.png")
+	require.NoError(t, err)
+	_, _ = part.Write(source)
+	require.NoError(t, writer.Close())
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/variations", &form)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	info := &relaycommon.RelayInfo{
+		RelayMode:   relayconstant.RelayModeImagesVariations,
+		ChannelMeta: &relaycommon.ChannelMeta{ChannelBaseUrl: "https://ark.example.com", UpstreamModelName: "doubao-seedream-4-0-250828"},
+	}
+
+	adaptor := &Adaptor{}
+	converted, err := adaptor.ConvertImageRequest(c, info, dto.ImageRequest{Model: "doubao-seedream-4-0-250828", Size: "1024x1024"})
+	require.NoError(t, err)
+	body, err := common.Marshal(converted)
+	require.NoError(t, err)
+
+	assert.Equal(t, "data:image/png;base64,"+base64.StdEncoding.EncodeToString(source), gjson.GetBytes(body, "image").String())
+	assert.NotEmpty(t, gjson.GetBytes(body, "prompt").String())
+	assert.False(t, gjson.GetBytes(body, "mask").Exists())
+
+	requestURL, err := adaptor.GetRequestURL(info)
+	require.NoError(t, err)
+	assert.Equal(t, "https://ark.example.com/api/v3/images/generations", requestURL)
+}
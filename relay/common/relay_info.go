@@ -88,6 +88,9 @@ type RelayInfo struct {
 	UsingGroup        string // 使用的分组，当auto跨分组重试时，会变动
 	UserGroup         string // 用户所在分组
 	TokenUnlimited    bool
+	TokenBudgeted     bool // 令牌启用了周期预算，预扣必须直写数据库
+	OrganizationId    int  // 令牌所属组织，非 0 时从组织共享钱包扣费
+	ResellerId        int  // 用户所属分销商，非 0 时价格叠加分销商加价，并按批发价从分销商钱包扣费
 	StartTime         time.Time
 	FirstResponseTime time.Time
 	isFirstResponse   bool
@@ -121,6 +124,11 @@ type RelayInfo struct {
 	// 强制预扣全额。用于异步任务（视频/音乐生成等），因为请求返回后任务仍在运行，
 	// 必须在提交前锁定全额。
 	ForcePreConsume bool
+	// SettledQuota 为 SettleBilling 结算时的实际消耗额度，异步图片任务据此回写任务额度。
+	SettledQuota int
+	// DeferSettle 为 true 时 SettleBilling 只记录 SettledQuota 而不结算，
+	// 由异步图片任务在 CAS 推进到终态后再结算，避免与超时清理的退款重复。
+	DeferSettle bool
 	// Billing 是计费会话，封装了预扣费/结算/退款的统一生命周期。
 	// 初始免费组可为 nil；若 auto 重试切换到付费组，会在发送前创建。
 	Billing BillingSettler
@@ -517,7 +525,10 @@ func genBaseRelayInfo(c *gin.Context, request dto.Request) *RelayInfo {
 		TokenId:        common.GetContextKeyInt(c, constant.ContextKeyTokenId),
 		TokenKey:       common.GetContextKeyString(c, constant.ContextKeyTokenKey),
 		TokenUnlimited: common.GetContextKeyBool(c, constant.ContextKeyTokenUnlimited),
+		TokenBudgeted:  common.GetContextKeyBool(c, constant.ContextKeyTokenBudgeted),
 		TokenGroup:     tokenGroup,
+		OrganizationId: common.GetContextKeyInt(c, constant.ContextKeyTokenOrgId),
+		ResellerId:     common.GetContextKeyInt(c, constant.ContextKeyUserResellerId),
 
 		isFirstResponse: true,
 		RelayMode:       relayconstant.Path2RelayMode(c.Request.URL.Path),
@@ -856,6 +867,8 @@ type TaskRelayInfo struct {
 	// PublicTaskID 是提交时预生成的 task_xxxx 格式公开 ID，
 	// 供 DoResponse 在返回给客户端时使用（避免暴露上游真实 ID）。
 	PublicTaskID string
+	// UpstreamCallbackURL 是传给上游的网关回调地址，为空表示不使用上游回调
+	UpstreamCallbackURL string
 
 	ConsumeQuota bool
 
@@ -959,6 +972,13 @@ type TaskInfo struct {
 	TotalTokens      int    `json:"total_tokens,omitempty"`      // 用于按倍率计费
 }
 
+// TaskCallback 上游回调的解析结果
+type TaskCallback struct {
+	UpstreamTaskID string // 回调中携带的上游任务 ID，必须与任务记录的一致
+	ResultBody     []byte // 可交给 ParseTaskResult 解析的任务结果
+	Reply          []byte // 非空时原样返回给上游（如地址校验），不再处理回调
+}
+
 func FailTaskInfo(reason string) *TaskInfo {
 	return &TaskInfo{
 		Status: "FAILURE",
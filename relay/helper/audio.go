@@ -0,0 +1,189 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	SpeechEventAudioDelta = "speech.audio.delta"
+	SpeechEventAudioDone  = "speech.audio.done"
+)
+
+// SpeechUsage 按合成音频时长计费：每分钟 1000 token，记为音频输出 token。
+// 时长无法解析时按音频大小保底估算（约每 KB 1 token）
+func SpeechUsage(info *relaycommon.RelayInfo, duration float64, audioSize int) *dto.Usage {
+	usage := &dto.Usage{}
+	usage.PromptTokens = info.GetEstimatePromptTokens()
+	usage.PromptTokensDetails.TextTokens = usage.PromptTokens
+	completionTokens := common.AudioDurationTokens(duration)
+	if completionTokens == 0 && audioSize > 0 {
+		completionTokens = int(math.Ceil(float64(audioSize) / 1000.0))
+	}
+	usage.CompletionTokens = completionTokens
+	usage.CompletionTokenDetails.AudioTokens = completionTokens
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// TranscriptionUsage 按输入音频时长计费，与 EstimateRequestToken 的预扣口径一致
+func TranscriptionUsage(info *relaycommon.RelayInfo, duration float64) *dto.Usage {
+	promptTokens := common.AudioDurationTokens(duration)
+	if promptTokens == 0 {
+		promptTokens = info.GetEstimatePromptTokens()
+	}
+	return &dto.Usage{
+		PromptTokens: promptTokens,
+		TotalTokens:  promptTokens,
+	}
+}
+
+// CopyAudioStream 边读边向客户端写出音频并刷新，返回完整音频用于计算时长
+func CopyAudioStream(c *gin.Context, contentType string, body io.Reader) ([]byte, error) {
+	if contentType != "" {
+		c.Writer.Header().Set("Content-Type", contentType)
+	}
+	c.Writer.WriteHeader(http.StatusOK)
+	var audio []byte
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			audio = append(audio, buf[:n]...)
+			if _, writeErr := c.Writer.Write(buf[:n]); writeErr != nil {
+				return audio, writeErr
+			}
+			_ = FlushWriter(c)
+		}
+		if err == io.EOF {
+			return audio, nil
+		}
+		if err != nil {
+			return audio, err
+		}
+	}
+}
+
+// SpeechAudioDelta 以 OpenAI speech SSE 格式输出一段音频
+func SpeechAudioDelta(c *gin.Context, audio []byte) error {
+	return ObjectData(c, dto.AudioSpeechStreamEvent{
+		Type:  SpeechEventAudioDelta,
+		Audio: base64.StdEncoding.EncodeToString(audio),
+	})
+}
+
+// SpeechAudioDone 输出 speech SSE 结束事件
+func SpeechAudioDone(c *gin.Context, usage *dto.Usage) error {
+	eventUsage := &dto.Usage{
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+		TotalTokens:  usage.TotalTokens,
+	}
+	return ObjectData(c, dto.AudioSpeechStreamEvent{Type: SpeechEventAudioDone, Usage: eventUsage})
+}
+
+var transcriptionFormats = map[string]bool{"": true, "json": true, "text": true, "verbose_json": true, "srt": true, "vtt": true}
+
+// ValidateTranscriptionFormat 在请求上游前校验 response_format，避免上游已计费后才报错
+func ValidateTranscriptionFormat(responseFormat string) error {
+	if !transcriptionFormats[responseFormat] {
+		return fmt.Errorf("unsupported response_format: %s", responseFormat)
+	}
+	return nil
+}
+
+// WriteTranscription 按 OpenAI response_format 输出转写结果。
+// 上游不提供分段时间戳时，srt/vtt 以整段音频作为单条字幕
+func WriteTranscription(c *gin.Context, responseFormat string, task string, text string, language string, duration float64) error {
+	switch responseFormat {
+	case "", "json":
+		c.JSON(http.StatusOK, dto.AudioResponse{Text: text})
+	case "text":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(text))
+	case "verbose_json":
+		c.JSON(http.StatusOK, dto.WhisperVerboseJSONResponse{
+			Task:     task,
+			Language: language,
+			Duration: duration,
+			Text:     text,
+		})
+	case "srt":
+		cue := fmt.Sprintf("1\n%s --> %s\n%s\n", subtitleTimestamp(0, ","), subtitleTimestamp(duration, ","), text)
+		c.Data(http.StatusOK, "application/x-subrip; charset=utf-8", []byte(cue))
+	case "vtt":
+		cue := fmt.Sprintf("WEBVTT\n\n%s --> %s\n%s\n", subtitleTimestamp(0, "."), subtitleTimestamp(duration, "."), text)
+		c.Data(http.StatusOK, "text/vtt; charset=utf-8", []byte(cue))
+	default:
+		return fmt.Errorf("unsupported response_format: %s", responseFormat)
+	}
+	return nil
+}
+
+func subtitleTimestamp(seconds float64, fractionSeparator string) string {
+	millis := int64(math.Round(max(seconds, 0) * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d",
+		millis/3_600_000, millis/60_000%60, millis/1000%60, fractionSeparator, millis%1000)
+}
+
+// TranscriptionInput 转写请求上传的音频及常用表单字段
+type TranscriptionInput struct {
+	Audio       []byte
+	Filename    string
+	ContentType string
+	Language    string
+	Prompt      string
+	// Duration 为音频时长（秒），无法解析时为 0
+	Duration float64
+}
+
+// ReadTranscriptionInput 从 multipart 表单读取 /v1/audio/transcriptions 与 translations 的输入
+func ReadTranscriptionInput(c *gin.Context) (*TranscriptionInput, error) {
+	form, err := common.ParseMultipartFormReusable(c)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing multipart form: %w", err)
+	}
+	fileHeaders := form.File["file"]
+	if len(fileHeaders) == 0 {
+		return nil, errors.New("file is required")
+	}
+	fileHeader := fileHeaders[0]
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, fmt.Errorf("error opening audio file: %w", err)
+	}
+	defer file.Close()
+	audio, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading audio file: %w", err)
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	input := &TranscriptionInput{
+		Audio:       audio,
+		Filename:    fileHeader.Filename,
+		ContentType: fileHeader.Header.Get("Content-Type"),
+		Language:    strings.TrimSpace(strings.Join(form.Value["language"], "")),
+		Prompt:      strings.TrimSpace(strings.Join(form.Value["prompt"], "")),
+	}
+	if input.ContentType == "" || input.ContentType == "application/octet-stream" {
+		input.ContentType = mime.TypeByExtension(ext)
+	}
+	if duration, err := common.GetAudioDuration(c.Request.Context(), bytes.NewReader(audio), ext); err == nil {
+		input.Duration = duration
+	}
+	return input, nil
+}
@@ -0,0 +1,154 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	imageVariationPrompt = "Create a variation of the provided image that keeps its subject, composition and style."
+	imageMaskInstruction = "The last image is an edit mask: only change the regions that are transparent in the mask and keep everything else unchanged."
+)
+
+// ImageFile 图片编辑与变体请求上传的单张图片
+type ImageFile struct {
+	Filename string
+	MimeType string
+	Data     []byte
+}
+
+func (f *ImageFile) Base64() string {
+	return base64.StdEncoding.EncodeToString(f.Data)
+}
+
+func (f *ImageFile) DataURL() string {
+	return "data:" + f.MimeType + ";base64," + f.Base64()
+}
+
+// ImageEditInput multipart 图片编辑与变体请求中的输入图片和蒙版
+type ImageEditInput struct {
+	Images []*ImageFile
+	// Mask 为 OpenAI 格式蒙版：透明区域为待编辑区域，未上传时为 nil
+	Mask *ImageFile
+}
+
+// ReadImageEditInput 从 multipart 表单读取 image、image[]、image[N] 与 mask 文件
+func ReadImageEditInput(c *gin.Context) (*ImageEditInput, error) {
+	form, err := common.ParseMultipartFormReusable(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse image edit form request: %w", err)
+	}
+	headers := form.File["image"]
+	if len(headers) == 0 {
+		headers = form.File["image[]"]
+	}
+	if len(headers) == 0 {
+		var fieldNames []string
+		for fieldName := range form.File {
+			if strings.HasPrefix(fieldName, "image[") {
+				fieldNames = append(fieldNames, fieldName)
+			}
+		}
+		sort.Strings(fieldNames)
+		for _, fieldName := range fieldNames {
+			headers = append(headers, form.File[fieldName]...)
+		}
+	}
+	if len(headers) == 0 {
+		return nil, errors.New("image is required")
+	}
+
+	input := &ImageEditInput{}
+	for _, header := range headers {
+		file, err := readImageFile(header)
+		if err != nil {
+			return nil, err
+		}
+		input.Images = append(input.Images, file)
+	}
+	if masks := form.File["mask"]; len(masks) > 0 {
+		if input.Mask, err = readImageFile(masks[0]); err != nil {
+			return nil, err
+		}
+	}
+	return input, nil
+}
+
+func readImageFile(header *multipart.FileHeader) (*ImageFile, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image file %s: %w", header.Filename, err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image file %s: %w", header.Filename, err)
+	}
+	mimeType := header.Header.Get("Content-Type")
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = http.DetectContentType(data)
+	}
+	return &ImageFile{Filename: header.Filename, MimeType: mimeType, Data: data}, nil
+}
+
+// InpaintMask 将 OpenAI 蒙版转换为黑白蒙版：透明区域（待编辑）为白色，其余为黑色。
+// Flux Fill 等局部重绘模型使用这种格式
+func InpaintMask(mask *ImageFile) (*ImageFile, error) {
+	src, _, err := image.Decode(bytes.NewReader(mask.Data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode mask: %w", err)
+	}
+	bounds := src.Bounds()
+	dst := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, alpha := src.At(x, y).RGBA(); alpha == 0 {
+				dst.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, fmt.Errorf("failed to encode mask: %w", err)
+	}
+	return &ImageFile{Filename: "mask.png", MimeType: "image/png", Data: buf.Bytes()}, nil
+}
+
+// EmulatedImageEditPrompt 为不支持原生变体或蒙版的上游构造提示词：
+// 变体缺省提示词时要求生成相似图片；带蒙版时蒙版作为最后一张参考图，并说明其含义
+func EmulatedImageEditPrompt(relayMode int, prompt string, hasMask bool) string {
+	prompt = strings.TrimSpace(prompt)
+	if relayMode == relayconstant.RelayModeImagesVariations && prompt == "" {
+		prompt = imageVariationPrompt
+	}
+	if hasMask {
+		prompt += "\n" + imageMaskInstruction
+	}
+	return prompt
+}
+
+// UpdateImageCount 按上游实际返回的图片数量修正按次计费的 n 倍率
+func UpdateImageCount(info *relaycommon.RelayInfo, count int64) {
+	if info == nil || !info.PriceData.UsePrice || count <= 0 || count > int64(dto.MaxImageN) {
+		return
+	}
+	info.PriceData.AddOtherRatio("n", float64(count))
+}
@@ -0,0 +1,91 @@
+package helper
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func newImageEditContext(t *testing.T, files map[string][]byte) *gin.Context {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	require.NoError(t, writer.WriteField("prompt", "add a hat"))
+	for field, data := range files {
+		part, err := writer.CreateFormFile(field, field+".png")
+		require.NoError(t, err)
+		_, err = part.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/edits", &body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	return c
+}
+
+func TestReadImageEditInputOrdersIndexedImagesAndReadsMask(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	pixel := encodePNG(t, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	c := newImageEditContext(t, map[string][]byte{
+		"image[1]": []byte("second"),
+		"image[0]": []byte("first"),
+		"mask":     pixel,
+	})
+
+	input, err := ReadImageEditInput(c)
+	require.NoError(t, err)
+	require.Len(t, input.Images, 2)
+	assert.Equal(t, []byte("first"), input.Images[0].Data)
+	assert.Equal(t, []byte("second"), input.Images[1].Data)
+	require.NotNil(t, input.Mask)
+	assert.Equal(t, "image/png", input.Mask.MimeType)
+	assert.Equal(t, "data:image/png;base64,"+input.Mask.Base64(), input.Mask.DataURL())
+}
+
+func TestReadImageEditInputRequiresImage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c := newImageEditContext(t, nil)
+
+	_, err := ReadImageEditInput(c)
+	require.EqualError(t, err, "image is required")
+}
+
+func TestInpaintMaskMarksTransparentRegionsWhite(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, color.NRGBA{A: 0})
+	src.Set(1, 0, color.NRGBA{R: 10, G: 20, B: 30, A: 255})
+
+	mask, err := InpaintMask(&ImageFile{Filename: "mask.png", MimeType: "image/png", Data: encodePNG(t, src)})
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", mask.MimeType)
+
+	decoded, err := png.Decode(bytes.NewReader(mask.Data))
+	require.NoError(t, err)
+	assert.Equal(t, color.Gray{Y: 255}, color.GrayModel.Convert(decoded.At(0, 0)))
+	assert.Equal(t, color.Gray{Y: 0}, color.GrayModel.Convert(decoded.At(1, 0)))
+}
+
+func TestEmulatedImageEditPrompt(t *testing.T) {
+	assert.Equal(t, imageVariationPrompt, EmulatedImageEditPrompt(relayconstant.RelayModeImagesVariations, " ", false))
+	assert.Equal(t, "add a hat", EmulatedImageEditPrompt(relayconstant.RelayModeImagesEdits, "add a hat ", false))
+	assert.Equal(t, "add a hat\n"+imageMaskInstruction, EmulatedImageEditPrompt(relayconstant.RelayModeImagesEdits, "add a hat", true))
+}
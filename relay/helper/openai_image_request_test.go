@@ -158,3 +158,49 @@ func TestGetAndValidOpenAIImageRequestNBounds(t *testing.T) {
 		require.Contains(t, err.Error(), boundErr)
 	})
 }
+
+// TestGetAndValidOpenAIImageRequestVariations verifies that variations are
+// multipart only and default to dall-e-2 at 1024x1024 for size-based pricing.
+func TestGetAndValidOpenAIImageRequestVariations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newContext := func(t *testing.T, fields map[string]string) *gin.Context {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		for key, value := range fields {
+			require.NoError(t, writer.WriteField(key, value))
+		}
+		part, err := writer.CreateFormFile("image", "input.png")
+		require.NoError(t, err)
+		_, err = part.Write([]byte("fake image"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/variations", &body)
+		c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+		return c
+	}
+
+	t.Run("defaults model, size and n", func(t *testing.T) {
+		req, err := GetAndValidOpenAIImageRequest(newContext(t, map[string]string{"response_format": "b64_json"}), relayconstant.RelayModeImagesVariations)
+		require.NoError(t, err)
+		require.Equal(t, "dall-e-2", req.Model)
+		require.Equal(t, "1024x1024", req.Size)
+		require.Equal(t, "b64_json", req.ResponseFormat)
+		require.Equal(t, uint(1), *req.N)
+	})
+
+	t.Run("dall-e-2 size is validated", func(t *testing.T) {
+		_, err := GetAndValidOpenAIImageRequest(newContext(t, map[string]string{"size": "1792x1024"}), relayconstant.RelayModeImagesVariations)
+		require.Error(t, err)
+	})
+
+	t.Run("json body is rejected", func(t *testing.T) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/variations", bytes.NewBufferString(`{"model":"dall-e-2"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		_, err := GetAndValidOpenAIImageRequest(c, relayconstant.RelayModeImagesVariations)
+		require.Error(t, err)
+	})
+}
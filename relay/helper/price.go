@@ -67,6 +67,17 @@ func HandleGroupRatio(ctx *gin.Context, relayInfo *relaycommon.RelayInfo) hostty
 		groupRatioInfo.GroupRatio = ratio_setting.GetGroupRatio(relayInfo.UsingGroup)
 	}
 
+	// 分销商的下游用户在分组倍率之上叠加分销商加价；分销商被禁用时计费会话会拒绝请求
+	if relayInfo.ResellerId > 0 {
+		markup, err := model.GetResellerMarkup(relayInfo.ResellerId)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("failed to get reseller markup for reseller %d: %s", relayInfo.ResellerId, err.Error()))
+		} else if markup != 1 {
+			groupRatioInfo.ResellerMarkup = markup
+			groupRatioInfo.GroupRatio *= markup
+		}
+	}
+
 	return groupRatioInfo
 }
 
@@ -183,6 +183,11 @@ func GetAndValidOpenAIImageRequest(c *gin.Context, relayMode int) (*dto.ImageReq
 	imageRequest := &dto.ImageRequest{}
 
 	switch relayMode {
+	case relayconstant.RelayModeImagesVariations:
+		if !strings.Contains(c.Request.Header.Get("Content-Type"), "multipart/form-data") {
+			return nil, errors.New("image variations require a multipart/form-data request")
+		}
+		fallthrough
 	case relayconstant.RelayModeImagesEdits:
 		if strings.Contains(c.Request.Header.Get("Content-Type"), "multipart/form-data") {
 			form, err := common.ParseMultipartFormReusable(c)
@@ -194,6 +199,7 @@ func GetAndValidOpenAIImageRequest(c *gin.Context, relayMode int) (*dto.ImageReq
 			c.Request.PostForm = formData
 			imageRequest.Prompt = formData.Get("prompt")
 			imageRequest.Model = formData.Get("model")
+			imageRequest.ResponseFormat = formData.Get("response_format")
 			if nValue := strings.TrimSpace(formData.Get("n")); nValue != "" {
 				n, err := strconv.Atoi(nValue)
 				if err != nil || n < 0 || n > dto.MaxImageN {
@@ -219,6 +225,16 @@ func GetAndValidOpenAIImageRequest(c *gin.Context, relayMode int) (*dto.ImageReq
 					imageRequest.Quality = "standard"
 				}
 			}
+			if relayMode == relayconstant.RelayModeImagesVariations {
+				// 变体没有提示词，model 缺省时与 OpenAI 一致使用 dall-e-2，尺寸参与按图计费
+				imageRequest.Model = common.GetStringIfEmpty(imageRequest.Model, "dall-e-2")
+				if imageRequest.Model == "dall-e-2" {
+					if imageRequest.Size != "" && imageRequest.Size != "256x256" && imageRequest.Size != "512x512" && imageRequest.Size != "1024x1024" {
+						return nil, errors.New("size must be one of 256x256, 512x512, or 1024x1024 for dall-e-2")
+					}
+					imageRequest.Size = common.GetStringIfEmpty(imageRequest.Size, "1024x1024")
+				}
+			}
 			if imageRequest.N == nil || *imageRequest.N == 0 {
 				imageRequest.N = common.GetPointer(uint(1))
 			}
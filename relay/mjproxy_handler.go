@@ -14,7 +14,6 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
-	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
@@ -211,7 +210,7 @@ func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyR
 		}
 	}
 
-	userQuota, err := model.GetUserQuota(info.UserId, false)
+	userQuota, err := model.GetUserAvailableQuota(info.UserId)
 	if err != nil {
 		return &dto.MidjourneyResponse{
 			Code:        4,
@@ -250,6 +249,8 @@ func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyR
 		Progress:    "0%",
 		FailReason:  "",
 		ChannelId:   c.GetInt("channel_id"),
+		// 记录上游实例标识，渠道重建或更换密钥后后续操作仍能找到该任务
+		ChannelIdentity: model.MidjourneyChannelIdentity(baseURL),
 	}
 	billingPrepared, billingErr := service.PrepareMidjourneyTaskBilling(
 		info,
@@ -273,15 +274,18 @@ func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyR
 		tokenName := c.GetString("token_name")
 		logContent := fmt.Sprintf("模型固定价格 %.2f，分组倍率 %.2f，操作 %s", priceData.ModelPrice, priceData.GroupRatioInfo.GroupRatio, constant.MjActionSwapFace)
 		other := service.GenerateMjOtherInfo(info, priceData)
+		upstreamCost, costTracked := service.ComputeChannelUpstreamCost(billingChannelId, modelName, midjourneyTask.Quota, priceData.GroupRatioInfo.GroupRatio)
 		model.RecordConsumeLog(c, info.UserId, model.RecordConsumeLogParams{
-			ChannelId: billingChannelId,
-			ModelName: modelName,
-			TokenName: tokenName,
-			Quota:     midjourneyTask.Quota,
-			Content:   logContent,
-			TokenId:   midjourneyTask.TokenId,
-			Group:     info.UsingGroup,
-			Other:     other,
+			ChannelId:    billingChannelId,
+			ModelName:    modelName,
+			TokenName:    tokenName,
+			Quota:        midjourneyTask.Quota,
+			Content:      logContent,
+			TokenId:      midjourneyTask.TokenId,
+			Group:        info.UsingGroup,
+			Other:        other,
+			UpstreamCost: upstreamCost,
+			CostTracked:  costTracked,
 		})
 		model.UpdateUserUsedQuotaAndRequestCount(info.UserId, midjourneyTask.Quota)
 		model.UpdateChannelUsedQuota(billingChannelId, midjourneyTask.Quota)
@@ -305,23 +309,21 @@ func RelayMidjourneyTaskImageSeed(c *gin.Context) *dto.MidjourneyResponse {
 	if originTask == nil {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "task_no_found")
 	}
-	channel, err := model.GetChannelById(originTask.ChannelId, true)
-	if err != nil {
-		return service.MidjourneyErrorWrapper(constant.MjRequestError, "get_channel_info_failed")
-	}
-	if channel.Status != common.ChannelStatusEnabled {
-		return service.MidjourneyErrorWrapper(constant.MjRequestError, "该任务所属渠道已被禁用")
+	rebindChannel, mjErr := service.UseMidjourneyTaskChannel(c, originTask)
+	if mjErr != nil {
+		return mjErr
 	}
-	c.Set("channel_id", originTask.ChannelId)
-	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", channel.Key))
 
 	requestURL := getMjRequestPath(c.Request.URL.String())
-	fullRequestURL := fmt.Sprintf("%s%s", channel.GetBaseURL(), requestURL)
+	fullRequestURL := fmt.Sprintf("%s%s", c.GetString("base_url"), requestURL)
 	midjResponseWithStatus, _, err := service.DoMidjourneyHttpRequest(c, time.Second*30, fullRequestURL)
 	if err != nil {
 		return &midjResponseWithStatus.Response
 	}
 	midjResponse := &midjResponseWithStatus.Response
+	if midjResponseWithStatus.StatusCode == http.StatusOK && midjResponse.Code == 1 {
+		service.RebindMidjourneyTask(c, originTask, rebindChannel)
+	}
 	c.Writer.WriteHeader(midjResponseWithStatus.StatusCode)
 	respBody, err := json.Marshal(midjResponse)
 	if err != nil {
@@ -355,7 +357,13 @@ func RelayMidjourneyTask(c *gin.Context, relayMode int) *dto.MidjourneyResponse
 		}
 	case relayconstant.RelayModeMidjourneyTaskFetchByCondition:
 		var condition = struct {
-			IDs []string `json:"ids"`
+			IDs       []string `json:"ids"`
+			Status    string   `json:"status"`
+			Action    string   `json:"action"`
+			Prompt    string   `json:"prompt"`
+			StartTime int64    `json:"start_time"`
+			EndTime   int64    `json:"end_time"`
+			Limit     int      `json:"limit"`
 		}{}
 		err = c.BindJSON(&condition)
 		if err != nil {
@@ -371,6 +379,28 @@ func RelayMidjourneyTask(c *gin.Context, relayMode int) *dto.MidjourneyResponse
 				midjourneyTask := coverMidjourneyTaskDto(c, originTask)
 				tasks = append(tasks, midjourneyTask)
 			}
+		} else if condition.Status != "" || condition.Action != "" || condition.Prompt != "" || condition.StartTime > 0 || condition.EndTime > 0 {
+			// 未指定 ids 时按条件检索用户的历史任务，最多返回 100 条
+			limit := condition.Limit
+			if limit <= 0 {
+				limit = 50
+			} else if limit > 100 {
+				limit = 100
+			}
+			queryParams := model.TaskQueryParams{
+				Status: strings.ToUpper(condition.Status),
+				Action: strings.ToUpper(condition.Action),
+				Prompt: condition.Prompt,
+			}
+			if condition.StartTime > 0 {
+				queryParams.StartTimestamp = strconv.FormatInt(condition.StartTime, 10)
+			}
+			if condition.EndTime > 0 {
+				queryParams.EndTimestamp = strconv.FormatInt(condition.EndTime, 10)
+			}
+			for _, originTask := range model.GetAllUserTask(userId, 0, limit, queryParams) {
+				tasks = append(tasks, coverMidjourneyTaskDto(c, originTask))
+			}
 		}
 		if tasks == nil {
 			tasks = make([]dto.MidjourneyDto, 0)
@@ -403,9 +433,17 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 	if err != nil {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "bind_request_body_failed")
 	}
+	callbackUrl, err := service.ResolveTaskCallbackURL(c, midjRequest.NotifyHook)
+	if err != nil {
+		return service.MidjourneyErrorWrapper(constant.MjRequestError, err.Error())
+	}
 
 	relayInfo.InitChannelMeta(c)
 
+	// 基于已有任务的操作：上游接受后才把原任务迁移到 rebindChannel
+	var rebindTask *model.Midjourney
+	var rebindChannel *model.Channel
+
 	if relayInfo.RelayMode == relayconstant.RelayModeMidjourneyAction { // midjourney plus，需要从customId中获取任务信息
 		mjErr := service.CoverPlusActionToNormalAction(&midjRequest)
 		if mjErr != nil {
@@ -479,17 +517,12 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 					return service.MidjourneyErrorWrapper(constant.MjRequestError, "task_status_not_success")
 				}
 			}
-			channel, err := model.GetChannelById(originTask.ChannelId, true)
-			if err != nil {
-				return service.MidjourneyErrorWrapper(constant.MjRequestError, "get_channel_info_failed")
-			}
-			if channel.Status != common.ChannelStatusEnabled {
-				return service.MidjourneyErrorWrapper(constant.MjRequestError, "该任务所属渠道已被禁用")
+			var mjErr *dto.MidjourneyResponse
+			rebindChannel, mjErr = service.UseMidjourneyTaskChannel(c, originTask)
+			if mjErr != nil {
+				return mjErr
 			}
-			c.Set("base_url", channel.GetBaseURL())
-			c.Set("channel_id", originTask.ChannelId)
-			c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", channel.Key))
-			logger.LogDebug(c, "Midjourney action uses origin channel: id=%s, base_url=%s", strconv.Itoa(originTask.ChannelId), channel.GetBaseURL())
+			rebindTask = originTask
 		}
 		midjRequest.Prompt = originTask.Prompt
 
@@ -524,7 +557,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 		}
 	}
 
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	userQuota, err := model.GetUserAvailableQuota(relayInfo.UserId)
 	if err != nil {
 		return &dto.MidjourneyResponse{
 			Code:        4,
@@ -553,22 +586,24 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 	// 24-prompt包含敏感词 {"code":24,"description":"可能包含敏感词","properties":{"promptEn":"nude body","bannedWord":"nude"}}
 	// other: 提交错误，description为错误描述
 	midjourneyTask := &model.Midjourney{
-		UserId:      relayInfo.UserId,
-		Code:        midjResponse.Code,
-		Action:      midjRequest.Action,
-		MjId:        midjResponse.Result,
-		Prompt:      midjRequest.Prompt,
-		PromptEn:    "",
-		Description: midjResponse.Description,
-		State:       "",
-		SubmitTime:  time.Now().UnixNano() / int64(time.Millisecond),
-		StartTime:   0,
-		FinishTime:  0,
-		ImageUrl:    "",
-		Status:      "",
-		Progress:    "0%",
-		FailReason:  "",
-		ChannelId:   c.GetInt("channel_id"),
+		UserId:          relayInfo.UserId,
+		Code:            midjResponse.Code,
+		Action:          midjRequest.Action,
+		MjId:            midjResponse.Result,
+		Prompt:          midjRequest.Prompt,
+		PromptEn:        "",
+		Description:     midjResponse.Description,
+		State:           "",
+		SubmitTime:      time.Now().UnixNano() / int64(time.Millisecond),
+		StartTime:       0,
+		FinishTime:      0,
+		ImageUrl:        "",
+		Status:          "",
+		Progress:        "0%",
+		FailReason:      "",
+		ChannelId:       c.GetInt("channel_id"),
+		CallbackUrl:     callbackUrl,
+		ChannelIdentity: model.MidjourneyChannelIdentity(baseURL),
 	}
 	if midjResponse.Code == 3 {
 		//无实例账号自动禁用渠道（No available account instance）
@@ -584,6 +619,9 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 		//非1-提交成功,21-任务已存在和22-排队中，则记录错误原因
 		midjourneyTask.FailReason = midjResponse.Description
 		consumeQuota = false
+	} else if rebindTask != nil {
+		// 上游已接受基于原任务的操作，原任务迁移到该渠道
+		service.RebindMidjourneyTask(c, rebindTask, rebindChannel)
 	}
 
 	if midjResponse.Code == 21 { //21-任务已存在（处理中或者有结果了）
@@ -629,6 +667,8 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 			Description: "insert_midjourney_task_failed",
 		}
 	}
+	// UPLOAD 或已存在结果的任务提交即完成，不会再经过轮询
+	service.EnqueueMidjourneyWebhook(c, midjourneyTask)
 	billingApplied, billingErr := service.SettleMidjourneyTaskBilling(relayInfo, midjourneyTask, billingPrepared)
 	if billingErr != nil {
 		common.SysLog("error settling Midjourney quota: " + billingErr.Error())
@@ -638,15 +678,18 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 		tokenName := c.GetString("token_name")
 		logContent := fmt.Sprintf("模型固定价格 %.2f，分组倍率 %.2f，操作 %s，ID %s", priceData.ModelPrice, priceData.GroupRatioInfo.GroupRatio, midjRequest.Action, midjResponse.Result)
 		other := service.GenerateMjOtherInfo(relayInfo, priceData)
+		upstreamCost, costTracked := service.ComputeChannelUpstreamCost(billingChannelId, modelName, midjourneyTask.Quota, priceData.GroupRatioInfo.GroupRatio)
 		model.RecordConsumeLog(c, relayInfo.UserId, model.RecordConsumeLogParams{
-			ChannelId: billingChannelId,
-			ModelName: modelName,
-			TokenName: tokenName,
-			Quota:     midjourneyTask.Quota,
-			Content:   logContent,
-			TokenId:   midjourneyTask.TokenId,
-			Group:     relayInfo.UsingGroup,
-			Other:     other,
+			ChannelId:    billingChannelId,
+			ModelName:    modelName,
+			TokenName:    tokenName,
+			Quota:        midjourneyTask.Quota,
+			Content:      logContent,
+			TokenId:      midjourneyTask.TokenId,
+			Group:        relayInfo.UsingGroup,
+			Other:        other,
+			UpstreamCost: upstreamCost,
+			CostTracked:  costTracked,
 		})
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, midjourneyTask.Quota)
 		model.UpdateChannelUsedQuota(billingChannelId, midjourneyTask.Quota)
@@ -74,3 +74,10 @@ type Segment struct {
 	CompressionRatio float64 `json:"compression_ratio"`
 	NoSpeechProb     float64 `json:"no_speech_prob"`
 }
+
+// AudioSpeechStreamEvent /v1/audio/speech 在 stream_format=sse 时输出的事件
+type AudioSpeechStreamEvent struct {
+	Type  string `json:"type"`
+	Audio string `json:"audio,omitempty"`
+	Usage *Usage `json:"usage,omitempty"`
+}
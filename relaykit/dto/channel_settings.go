@@ -85,6 +85,29 @@ type ChannelOtherSettings struct {
 	UpstreamModelUpdateLastRemovedModels  []string              `json:"upstream_model_update_last_removed_models,omitempty"`  // 上次检测到的可删除模型
 	UpstreamModelUpdateIgnoredModels      []string              `json:"upstream_model_update_ignored_models,omitempty"`       // 手动忽略的模型
 	AdvancedCustom                        *AdvancedCustomConfig `json:"advanced_custom,omitempty"`
+	UpstreamCostRatio                     float64               `json:"upstream_cost_ratio,omitempty"` // 上游成本倍率：相对官方价格（未计分组倍率的扣费）的折扣，0 表示不统计成本
+	UpstreamCostExprs                     map[string]string     `json:"upstream_cost_exprs,omitempty"` // 按模型配置的上游成本表达式（billingexpr，$/1M tokens），"*" 为默认，优先于成本倍率
+}
+
+// UpstreamCostExpr 返回模型对应的上游成本表达式，依次匹配给定模型名，最后回落到 "*"。
+func (s *ChannelOtherSettings) UpstreamCostExpr(modelNames ...string) string {
+	if s == nil || len(s.UpstreamCostExprs) == 0 {
+		return ""
+	}
+	for _, name := range modelNames {
+		if name == "" {
+			continue
+		}
+		if expr := strings.TrimSpace(s.UpstreamCostExprs[name]); expr != "" {
+			return expr
+		}
+	}
+	return strings.TrimSpace(s.UpstreamCostExprs["*"])
+}
+
+// HasUpstreamCost 渠道是否配置了上游成本
+func (s *ChannelOtherSettings) HasUpstreamCost() bool {
+	return s != nil && (s.UpstreamCostRatio > 0 || len(s.UpstreamCostExprs) > 0)
 }
 
 func (s *ChannelOtherSettings) IsOpenRouterEnterprise() bool {
@@ -0,0 +1,238 @@
+package dto
+
+import (
+	kitutil "github.com/QuantumNous/new-api/relaykit/relayconvert/kitutil"
+)
+
+// Cohere v2 API (/v2/chat, /v2/embed, /v2/rerank) DTOs. The v1 chat shapes used
+// by the outbound Cohere channel live in relay/channel/cohere.
+
+const (
+	CohereContentTypeText     = "text"
+	CohereContentTypeImageURL = "image_url"
+	CohereContentTypeDocument = "document"
+	CohereContentTypeThinking = "thinking"
+
+	CohereFinishReasonComplete     = "COMPLETE"
+	CohereFinishReasonStopSequence = "STOP_SEQUENCE"
+	CohereFinishReasonMaxTokens    = "MAX_TOKENS"
+	CohereFinishReasonToolCall     = "TOOL_CALL"
+	CohereFinishReasonError        = "ERROR"
+
+	CohereStreamEventMessageStart  = "message-start"
+	CohereStreamEventContentStart  = "content-start"
+	CohereStreamEventContentDelta  = "content-delta"
+	CohereStreamEventContentEnd    = "content-end"
+	CohereStreamEventToolPlanDelta = "tool-plan-delta"
+	CohereStreamEventToolCallStart = "tool-call-start"
+	CohereStreamEventToolCallDelta = "tool-call-delta"
+	CohereStreamEventToolCallEnd   = "tool-call-end"
+	CohereStreamEventMessageEnd    = "message-end"
+)
+
+// CohereContent is one content block. Requests accept either a plain string
+// or a list of blocks for message content; responses always use blocks.
+type CohereContent struct {
+	Type     string          `json:"type,omitempty"`
+	Text     string          `json:"text,omitempty"`
+	Thinking string          `json:"thinking,omitempty"`
+	ImageURL *CohereImageURL `json:"image_url,omitempty"`
+	Document *CohereDocument `json:"document,omitempty"`
+}
+
+type CohereImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// CohereDocument carries grounding data; Data is a string or a flat object.
+type CohereDocument struct {
+	ID   string `json:"id,omitempty"`
+	Data any    `json:"data"`
+}
+
+type CohereToolCall struct {
+	ID       string             `json:"id,omitempty"`
+	Type     string             `json:"type,omitempty"`
+	Function CohereToolFunction `json:"function"`
+}
+
+type CohereToolFunction struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+type CohereTool struct {
+	Type     string          `json:"type"`
+	Function FunctionRequest `json:"function"`
+}
+
+type CohereChatMessage struct {
+	Role       string           `json:"role"`
+	Content    any              `json:"content,omitempty"`
+	ToolCalls  []CohereToolCall `json:"tool_calls,omitempty"`
+	ToolPlan   string           `json:"tool_plan,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// ParseContent normalises string or block content into blocks.
+func (m *CohereChatMessage) ParseContent() []CohereContent {
+	switch content := m.Content.(type) {
+	case nil:
+		return nil
+	case string:
+		if content == "" {
+			return nil
+		}
+		return []CohereContent{{Type: CohereContentTypeText, Text: content}}
+	case []CohereContent:
+		return content
+	default:
+		blocks, err := kitutil.Any2Type[[]CohereContent](content)
+		if err != nil {
+			return nil
+		}
+		return blocks
+	}
+}
+
+type CohereResponseFormat struct {
+	Type       string `json:"type"`
+	JsonSchema any    `json:"json_schema,omitempty"`
+}
+
+type CohereThinking struct {
+	Type        string `json:"type"`
+	TokenBudget *int   `json:"token_budget,omitempty"`
+}
+
+type CohereChatRequest struct {
+	Model            string                `json:"model"`
+	Messages         []CohereChatMessage   `json:"messages"`
+	Tools            []CohereTool          `json:"tools,omitempty"`
+	Documents        []any                 `json:"documents,omitempty"`
+	ResponseFormat   *CohereResponseFormat `json:"response_format,omitempty"`
+	SafetyMode       string                `json:"safety_mode,omitempty"`
+	MaxTokens        *int                  `json:"max_tokens,omitempty"`
+	StopSequences    []string              `json:"stop_sequences,omitempty"`
+	Temperature      *float64              `json:"temperature,omitempty"`
+	Seed             *int                  `json:"seed,omitempty"`
+	FrequencyPenalty *float64              `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float64              `json:"presence_penalty,omitempty"`
+	K                *int                  `json:"k,omitempty"`
+	P                *float64              `json:"p,omitempty"`
+	ToolChoice       string                `json:"tool_choice,omitempty"`
+	StrictTools      *bool                 `json:"strict_tools,omitempty"`
+	Thinking         *CohereThinking       `json:"thinking,omitempty"`
+	Stream           bool                  `json:"stream,omitempty"`
+}
+
+type CohereTokenUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type CohereUsage struct {
+	BilledUnits *CohereTokenUsage `json:"billed_units,omitempty"`
+	Tokens      *CohereTokenUsage `json:"tokens,omitempty"`
+}
+
+type CohereResponseMessage struct {
+	Role      string           `json:"role"`
+	Content   []CohereContent  `json:"content,omitempty"`
+	ToolPlan  string           `json:"tool_plan,omitempty"`
+	ToolCalls []CohereToolCall `json:"tool_calls,omitempty"`
+}
+
+type CohereChatResponse struct {
+	ID           string                `json:"id"`
+	FinishReason string                `json:"finish_reason"`
+	Message      CohereResponseMessage `json:"message"`
+	Usage        *CohereUsage          `json:"usage,omitempty"`
+}
+
+// CohereStreamMessage is the message fragment inside a stream event delta;
+// unlike the final response, content and tool_calls are single objects here.
+type CohereStreamMessage struct {
+	Role      string          `json:"role,omitempty"`
+	Content   *CohereContent  `json:"content,omitempty"`
+	ToolPlan  string          `json:"tool_plan,omitempty"`
+	ToolCalls *CohereToolCall `json:"tool_calls,omitempty"`
+}
+
+type CohereStreamDelta struct {
+	Message      *CohereStreamMessage `json:"message,omitempty"`
+	FinishReason string               `json:"finish_reason,omitempty"`
+	Usage        *CohereUsage         `json:"usage,omitempty"`
+	Error        string               `json:"error,omitempty"`
+}
+
+// CohereStreamEvent is one /v2/chat server-sent event; Type doubles as the
+// SSE event name.
+type CohereStreamEvent struct {
+	Type  string             `json:"type"`
+	ID    string             `json:"id,omitempty"`
+	Index *int               `json:"index,omitempty"`
+	Delta *CohereStreamDelta `json:"delta,omitempty"`
+}
+
+type CohereEmbedRequest struct {
+	Model           string   `json:"model"`
+	Texts           []string `json:"texts,omitempty"`
+	Images          []string `json:"images,omitempty"`
+	InputType       string   `json:"input_type,omitempty"`
+	EmbeddingTypes  []string `json:"embedding_types,omitempty"`
+	OutputDimension *int     `json:"output_dimension,omitempty"`
+	Truncate        string   `json:"truncate,omitempty"`
+}
+
+type CohereEmbeddings struct {
+	Float [][]float64 `json:"float,omitempty"`
+}
+
+type CohereMeta struct {
+	APIVersion  *CohereAPIVersion  `json:"api_version,omitempty"`
+	BilledUnits *CohereBilledUnits `json:"billed_units,omitempty"`
+}
+
+type CohereAPIVersion struct {
+	Version string `json:"version"`
+}
+
+type CohereBilledUnits struct {
+	InputTokens  int `json:"input_tokens,omitempty"`
+	OutputTokens int `json:"output_tokens,omitempty"`
+	SearchUnits  int `json:"search_units,omitempty"`
+}
+
+type CohereEmbedResponse struct {
+	ID           string           `json:"id"`
+	ResponseType string           `json:"response_type"`
+	Embeddings   CohereEmbeddings `json:"embeddings"`
+	Texts        []string         `json:"texts,omitempty"`
+	Meta         CohereMeta       `json:"meta"`
+}
+
+type CohereRerankRequest struct {
+	Model           string `json:"model"`
+	Query           string `json:"query"`
+	Documents       []any  `json:"documents"`
+	TopN            *int   `json:"top_n,omitempty"`
+	MaxTokensPerDoc *int   `json:"max_tokens_per_doc,omitempty"`
+}
+
+type CohereRerankResult struct {
+	Index          int     `json:"index"`
+	RelevanceScore float64 `json:"relevance_score"`
+}
+
+type CohereRerankResponse struct {
+	ID      string               `json:"id"`
+	Results []CohereRerankResult `json:"results"`
+	Meta    CohereMeta           `json:"meta"`
+}
+
+// CohereErrorResponse is the error body Cohere SDKs surface to callers.
+type CohereErrorResponse struct {
+	Message string `json:"message"`
+}
@@ -0,0 +1,187 @@
+package dto
+
+import (
+	kitutil "github.com/QuantumNous/new-api/relaykit/relayconvert/kitutil"
+)
+
+// Mistral chat API DTOs. Mistral is close to OpenAI chat completions
+// but differs in content chunk types, tool_choice "any", random_seed and
+// argument objects, so it is modelled as its own relay format.
+
+const (
+	MistralContentTypeText        = "text"
+	MistralContentTypeImageURL    = "image_url"
+	MistralContentTypeDocumentURL = "document_url"
+	MistralContentTypeThinking    = "thinking"
+)
+
+// MistralContentChunk is one content chunk. ImageURL is either a URL string or
+// an object with url/detail; Thinking holds nested text chunks.
+type MistralContentChunk struct {
+	Type         string                `json:"type"`
+	Text         string                `json:"text,omitempty"`
+	ImageURL     any                   `json:"image_url,omitempty"`
+	DocumentURL  string                `json:"document_url,omitempty"`
+	DocumentName string                `json:"document_name,omitempty"`
+	Thinking     []MistralContentChunk `json:"thinking,omitempty"`
+}
+
+// ImageURLString returns the image URL regardless of the string/object form.
+func (c *MistralContentChunk) ImageURLString() string {
+	switch imageURL := c.ImageURL.(type) {
+	case string:
+		return imageURL
+	case map[string]any:
+		url, _ := imageURL["url"].(string)
+		return url
+	}
+	return ""
+}
+
+type MistralToolCall struct {
+	ID       string              `json:"id,omitempty"`
+	Type     string              `json:"type,omitempty"`
+	Index    *int                `json:"index,omitempty"`
+	Function MistralFunctionCall `json:"function"`
+}
+
+// MistralFunctionCall arguments are a JSON string or an already-decoded object.
+type MistralFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments any    `json:"arguments"`
+}
+
+// ArgumentsString returns the arguments as a JSON string.
+func (f *MistralFunctionCall) ArgumentsString() string {
+	switch arguments := f.Arguments.(type) {
+	case nil:
+		return ""
+	case string:
+		return arguments
+	default:
+		data, err := kitutil.Marshal(arguments)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+}
+
+type MistralMessage struct {
+	Role       string            `json:"role"`
+	Content    any               `json:"content"`
+	ToolCalls  []MistralToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string            `json:"tool_call_id,omitempty"`
+	Name       string            `json:"name,omitempty"`
+	Prefix     bool              `json:"prefix,omitempty"`
+}
+
+// ParseContent normalises string or chunk content into chunks.
+func (m *MistralMessage) ParseContent() []MistralContentChunk {
+	return parseMistralContent(m.Content)
+}
+
+func parseMistralContent(content any) []MistralContentChunk {
+	switch value := content.(type) {
+	case nil:
+		return nil
+	case string:
+		if value == "" {
+			return nil
+		}
+		return []MistralContentChunk{{Type: MistralContentTypeText, Text: value}}
+	case []MistralContentChunk:
+		return value
+	default:
+		chunks, err := kitutil.Any2Type[[]MistralContentChunk](value)
+		if err != nil {
+			return nil
+		}
+		return chunks
+	}
+}
+
+type MistralChatRequest struct {
+	Model             string            `json:"model"`
+	Messages          []MistralMessage  `json:"messages"`
+	Temperature       *float64          `json:"temperature,omitempty"`
+	TopP              *float64          `json:"top_p,omitempty"`
+	MaxTokens         *int              `json:"max_tokens,omitempty"`
+	Stream            bool              `json:"stream,omitempty"`
+	Stop              any               `json:"stop,omitempty"`
+	RandomSeed        *int              `json:"random_seed,omitempty"`
+	ResponseFormat    *ResponseFormat   `json:"response_format,omitempty"`
+	Tools             []ToolCallRequest `json:"tools,omitempty"`
+	ToolChoice        any               `json:"tool_choice,omitempty"`
+	PresencePenalty   *float64          `json:"presence_penalty,omitempty"`
+	FrequencyPenalty  *float64          `json:"frequency_penalty,omitempty"`
+	N                 *int              `json:"n,omitempty"`
+	ParallelToolCalls *bool             `json:"parallel_tool_calls,omitempty"`
+	PromptMode        string            `json:"prompt_mode,omitempty"`
+	SafePrompt        bool              `json:"safe_prompt,omitempty"`
+}
+
+type MistralUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type MistralResponseMessage struct {
+	Role      string            `json:"role,omitempty"`
+	Content   any               `json:"content,omitempty"`
+	ToolCalls []MistralToolCall `json:"tool_calls,omitempty"`
+}
+
+// ParseContent normalises string or chunk content into chunks.
+func (m *MistralResponseMessage) ParseContent() []MistralContentChunk {
+	return parseMistralContent(m.Content)
+}
+
+type MistralChatChoice struct {
+	Index        int                    `json:"index"`
+	Message      MistralResponseMessage `json:"message"`
+	FinishReason string                 `json:"finish_reason"`
+}
+
+type MistralChatResponse struct {
+	ID      string              `json:"id"`
+	Object  string              `json:"object"`
+	Model   string              `json:"model"`
+	Created int64               `json:"created"`
+	Choices []MistralChatChoice `json:"choices"`
+	Usage   MistralUsage        `json:"usage"`
+}
+
+type MistralStreamChoice struct {
+	Index        int                    `json:"index"`
+	Delta        MistralResponseMessage `json:"delta"`
+	FinishReason *string                `json:"finish_reason"`
+}
+
+type MistralStreamResponse struct {
+	ID      string                `json:"id"`
+	Object  string                `json:"object"`
+	Model   string                `json:"model"`
+	Created int64                 `json:"created"`
+	Choices []MistralStreamChoice `json:"choices"`
+	Usage   *MistralUsage         `json:"usage,omitempty"`
+}
+
+// MistralEmbeddingRequest is the /v1/embeddings body; it follows OpenAI
+// except for output_dimension and output_dtype.
+type MistralEmbeddingRequest struct {
+	Model           string `json:"model"`
+	Input           any    `json:"input"`
+	OutputDimension *int   `json:"output_dimension,omitempty"`
+	OutputDtype     string `json:"output_dtype,omitempty"`
+	EncodingFormat  string `json:"encoding_format,omitempty"`
+}
+
+// MistralErrorResponse is the error body Mistral SDKs surface to callers.
+type MistralErrorResponse struct {
+	Object  string `json:"object"`
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    any    `json:"code"`
+}
@@ -0,0 +1,162 @@
+package dto
+
+import (
+	"encoding/json"
+)
+
+type OllamaChatMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content,omitempty"`
+	Images     []string         `json:"images,omitempty"`
+	ToolCalls  []OllamaToolCall `json:"tool_calls,omitempty"`
+	ToolName   string           `json:"tool_name,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+	Thinking   json.RawMessage  `json:"thinking,omitempty"`
+}
+
+type OllamaToolFunction struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Parameters  interface{} `json:"parameters,omitempty"`
+}
+
+type OllamaTool struct {
+	Type     string             `json:"type"`
+	Function OllamaToolFunction `json:"function"`
+}
+
+type OllamaToolCall struct {
+	ID       string `json:"id,omitempty"`
+	Function struct {
+		Name      string      `json:"name"`
+		Arguments interface{} `json:"arguments"`
+	} `json:"function"`
+}
+
+// OllamaChatRequest is the /api/chat request body. Stream is a pointer because
+// Ollama streams by default when the field is omitted.
+type OllamaChatRequest struct {
+	Model     string              `json:"model"`
+	Messages  []OllamaChatMessage `json:"messages"`
+	Tools     interface{}         `json:"tools,omitempty"`
+	Format    interface{}         `json:"format,omitempty"`
+	Stream    *bool               `json:"stream,omitempty"`
+	Options   map[string]any      `json:"options,omitempty"`
+	KeepAlive interface{}         `json:"keep_alive,omitempty"`
+	Think     json.RawMessage     `json:"think,omitempty"`
+}
+
+type OllamaGenerateRequest struct {
+	Model     string          `json:"model"`
+	Prompt    string          `json:"prompt,omitempty"`
+	Suffix    string          `json:"suffix,omitempty"`
+	System    string          `json:"system,omitempty"`
+	Images    []string        `json:"images,omitempty"`
+	Format    interface{}     `json:"format,omitempty"`
+	Stream    *bool           `json:"stream,omitempty"`
+	Raw       bool            `json:"raw,omitempty"`
+	Options   map[string]any  `json:"options,omitempty"`
+	KeepAlive interface{}     `json:"keep_alive,omitempty"`
+	Think     json.RawMessage `json:"think,omitempty"`
+}
+
+// OllamaMetrics carries the token counts and timings Ollama appends to the
+// final (done) chunk of chat and generate responses.
+type OllamaMetrics struct {
+	TotalDuration      int64 `json:"total_duration,omitempty"`
+	LoadDuration       int64 `json:"load_duration,omitempty"`
+	PromptEvalCount    int   `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64 `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int   `json:"eval_count,omitempty"`
+	EvalDuration       int64 `json:"eval_duration,omitempty"`
+}
+
+// OllamaChatResponse is both the non-stream /api/chat body and one NDJSON line
+// of a streamed /api/chat response.
+type OllamaChatResponse struct {
+	Model      string            `json:"model"`
+	CreatedAt  string            `json:"created_at"`
+	Message    OllamaChatMessage `json:"message"`
+	Done       bool              `json:"done"`
+	DoneReason string            `json:"done_reason,omitempty"`
+	OllamaMetrics
+}
+
+// OllamaGenerateResponse is both the non-stream /api/generate body and one
+// NDJSON line of a streamed /api/generate response.
+type OllamaGenerateResponse struct {
+	Model      string `json:"model"`
+	CreatedAt  string `json:"created_at"`
+	Response   string `json:"response"`
+	Thinking   string `json:"thinking,omitempty"`
+	Done       bool   `json:"done"`
+	DoneReason string `json:"done_reason,omitempty"`
+	OllamaMetrics
+}
+
+// ToGenerateResponse reshapes a chat response into the /api/generate layout,
+// which carries the assistant text in "response" instead of "message".
+func (r *OllamaChatResponse) ToGenerateResponse() *OllamaGenerateResponse {
+	if r == nil {
+		return nil
+	}
+	response := &OllamaGenerateResponse{
+		Model:         r.Model,
+		CreatedAt:     r.CreatedAt,
+		Response:      r.Message.Content,
+		Done:          r.Done,
+		DoneReason:    r.DoneReason,
+		OllamaMetrics: r.OllamaMetrics,
+	}
+	if len(r.Message.Thinking) > 0 {
+		var thinking string
+		if err := json.Unmarshal(r.Message.Thinking, &thinking); err == nil {
+			response.Thinking = thinking
+		}
+	}
+	return response
+}
+
+type OllamaEmbeddingRequest struct {
+	Model      string         `json:"model"`
+	Input      interface{}    `json:"input"`
+	Truncate   *bool          `json:"truncate,omitempty"`
+	Options    map[string]any `json:"options,omitempty"`
+	KeepAlive  interface{}    `json:"keep_alive,omitempty"`
+	Dimensions int            `json:"dimensions,omitempty"`
+}
+
+type OllamaEmbeddingResponse struct {
+	Error           string      `json:"error,omitempty"`
+	Model           string      `json:"model"`
+	Embeddings      [][]float64 `json:"embeddings"`
+	PromptEvalCount int         `json:"prompt_eval_count,omitempty"`
+}
+
+type OllamaTagsResponse struct {
+	Models []OllamaModel `json:"models"`
+}
+
+type OllamaModel struct {
+	Name       string            `json:"name"`
+	Model      string            `json:"model,omitempty"`
+	Size       int64             `json:"size"`
+	Digest     string            `json:"digest,omitempty"`
+	ModifiedAt string            `json:"modified_at"`
+	Details    OllamaModelDetail `json:"details,omitempty"`
+}
+
+type OllamaModelDetail struct {
+	ParentModel       string   `json:"parent_model,omitempty"`
+	Format            string   `json:"format,omitempty"`
+	Family            string   `json:"family,omitempty"`
+	Families          []string `json:"families,omitempty"`
+	ParameterSize     string   `json:"parameter_size,omitempty"`
+	QuantizationLevel string   `json:"quantization_level,omitempty"`
+}
+
+// OllamaErrorResponse is the error body shape Ollama clients expect: a bare
+// message string under "error".
+type OllamaErrorResponse struct {
+	Error string `json:"error"`
+}
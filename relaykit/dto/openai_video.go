@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"encoding/json"
 	"strconv"
 	"strings"
 )
@@ -51,3 +52,20 @@ type OpenAIVideoError struct {
 	Message string `json:"message"`
 	Code    string `json:"code"`
 }
+
+// OpenAIVideoList is the response of GET /v1/videos. Data holds the raw
+// per-platform video objects produced by each adaptor's converter.
+type OpenAIVideoList struct {
+	Object  string            `json:"object"`
+	Data    []json.RawMessage `json:"data"`
+	FirstID string            `json:"first_id,omitempty"`
+	LastID  string            `json:"last_id,omitempty"`
+	HasMore bool              `json:"has_more"`
+}
+
+// OpenAIVideoDeleted is the response of DELETE /v1/videos/{video_id}.
+type OpenAIVideoDeleted struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Deleted bool   `json:"deleted"`
+}
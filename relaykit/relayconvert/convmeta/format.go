@@ -17,6 +17,12 @@ func GuessRelayFormatFromRequest(req any) (types.RelayFormat, bool) {
 		return types.RelayFormatClaude, true
 	case *dto.GeminiChatRequest, dto.GeminiChatRequest:
 		return types.RelayFormatGemini, true
+	case *dto.OllamaChatRequest, dto.OllamaChatRequest, *dto.OllamaGenerateRequest, dto.OllamaGenerateRequest:
+		return types.RelayFormatOllama, true
+	case *dto.CohereChatRequest, dto.CohereChatRequest:
+		return types.RelayFormatCohere, true
+	case *dto.MistralChatRequest, dto.MistralChatRequest:
+		return types.RelayFormatMistral, true
 	case *dto.EmbeddingRequest, dto.EmbeddingRequest:
 		return types.RelayFormatEmbedding, true
 	case *dto.RerankRequest, dto.RerankRequest:
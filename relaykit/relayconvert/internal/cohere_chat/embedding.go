@@ -0,0 +1,49 @@
+package coherechat
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/relaykit/dto"
+)
+
+const cohereAPIVersion = "2"
+
+// CohereEmbedRequestToOpenAI converts a /v2/embed request. Only float
+// embeddings of texts have an OpenAI counterpart.
+func CohereEmbedRequestToOpenAI(cohereRequest *dto.CohereEmbedRequest) (*dto.EmbeddingRequest, error) {
+	if len(cohereRequest.Images) > 0 {
+		return nil, fmt.Errorf("cohere image embeddings are not supported")
+	}
+	if len(cohereRequest.Texts) == 0 {
+		return nil, fmt.Errorf("texts is required")
+	}
+	for _, embeddingType := range cohereRequest.EmbeddingTypes {
+		if embeddingType != "float" {
+			return nil, fmt.Errorf("unsupported cohere embedding type %q", embeddingType)
+		}
+	}
+	return &dto.EmbeddingRequest{
+		Model:      cohereRequest.Model,
+		Input:      cohereRequest.Texts,
+		Dimensions: cohereRequest.OutputDimension,
+	}, nil
+}
+
+func OpenAIEmbeddingResponseToCohere(response *dto.OpenAIEmbeddingResponse) *dto.CohereEmbedResponse {
+	embeddings := make([][]float64, len(response.Data))
+	for i, item := range response.Data {
+		if item.Index >= 0 && item.Index < len(embeddings) {
+			embeddings[item.Index] = item.Embedding
+		} else {
+			embeddings[i] = item.Embedding
+		}
+	}
+	return &dto.CohereEmbedResponse{
+		ResponseType: "embeddings_by_type",
+		Embeddings:   dto.CohereEmbeddings{Float: embeddings},
+		Meta: dto.CohereMeta{
+			APIVersion:  &dto.CohereAPIVersion{Version: cohereAPIVersion},
+			BilledUnits: &dto.CohereBilledUnits{InputTokens: response.PromptTokens},
+		},
+	}
+}
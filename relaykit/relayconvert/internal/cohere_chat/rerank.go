@@ -0,0 +1,32 @@
+package coherechat
+
+import (
+	"github.com/QuantumNous/new-api/relaykit/dto"
+)
+
+// CohereRerankRequestToRerank converts a /v2/rerank request to the gateway's
+// rerank request, which already follows the Cohere shape minus v2 extras.
+func CohereRerankRequestToRerank(cohereRequest *dto.CohereRerankRequest) *dto.RerankRequest {
+	return &dto.RerankRequest{
+		Model:     cohereRequest.Model,
+		Query:     cohereRequest.Query,
+		Documents: cohereRequest.Documents,
+		TopN:      cohereRequest.TopN,
+	}
+}
+
+// RerankResponseToCohere bills one search unit per request, as Cohere does
+// for queries of up to 100 documents.
+func RerankResponseToCohere(response *dto.RerankResponse) *dto.CohereRerankResponse {
+	results := make([]dto.CohereRerankResult, 0, len(response.Results))
+	for _, result := range response.Results {
+		results = append(results, dto.CohereRerankResult{Index: result.Index, RelevanceScore: result.RelevanceScore})
+	}
+	return &dto.CohereRerankResponse{
+		Results: results,
+		Meta: dto.CohereMeta{
+			APIVersion:  &dto.CohereAPIVersion{Version: cohereAPIVersion},
+			BilledUnits: &dto.CohereBilledUnits{SearchUnits: 1},
+		},
+	}
+}
@@ -0,0 +1,182 @@
+package coherechat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/relaykit/relayconvert/internal/jsonutil"
+	kitutil "github.com/QuantumNous/new-api/relaykit/relayconvert/kitutil"
+)
+
+// CohereChatRequestToOpenAIChat converts a /v2/chat request to an OpenAI chat
+// completions request. Grounding documents have no OpenAI counterpart and are
+// passed to the model as a leading system message.
+func CohereChatRequestToOpenAIChat(cohereRequest *dto.CohereChatRequest) (*dto.GeneralOpenAIRequest, error) {
+	openaiRequest := &dto.GeneralOpenAIRequest{
+		Model:            cohereRequest.Model,
+		Stream:           kitutil.GetPointer(cohereRequest.Stream),
+		Temperature:      cohereRequest.Temperature,
+		TopP:             cohereRequest.P,
+		TopK:             cohereRequest.K,
+		FrequencyPenalty: cohereRequest.FrequencyPenalty,
+		PresencePenalty:  cohereRequest.PresencePenalty,
+	}
+	if cohereRequest.Stream {
+		openaiRequest.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+	}
+	if cohereRequest.MaxTokens != nil && *cohereRequest.MaxTokens > 0 {
+		openaiRequest.MaxTokens = kitutil.GetPointer(uint(*cohereRequest.MaxTokens))
+	}
+	if cohereRequest.Seed != nil {
+		openaiRequest.Seed = kitutil.GetPointer(float64(*cohereRequest.Seed))
+	}
+	if len(cohereRequest.StopSequences) > 0 {
+		openaiRequest.Stop = cohereRequest.StopSequences
+	}
+	if format := cohereRequest.ResponseFormat; format != nil && format.Type == "json_object" {
+		if format.JsonSchema == nil {
+			openaiRequest.ResponseFormat = &dto.ResponseFormat{Type: "json_object"}
+		} else {
+			schema, err := kitutil.Marshal(dto.FormatJsonSchema{Name: "response", Schema: format.JsonSchema})
+			if err != nil {
+				return nil, fmt.Errorf("invalid cohere response_format: %w", err)
+			}
+			openaiRequest.ResponseFormat = &dto.ResponseFormat{Type: "json_schema", JsonSchema: schema}
+		}
+	}
+
+	for _, tool := range cohereRequest.Tools {
+		openaiRequest.Tools = append(openaiRequest.Tools, dto.ToolCallRequest{Type: "function", Function: tool.Function})
+	}
+	switch cohereRequest.ToolChoice {
+	case "REQUIRED":
+		openaiRequest.ToolChoice = "required"
+	case "NONE":
+		openaiRequest.ToolChoice = "none"
+	}
+
+	messages := make([]dto.Message, 0, len(cohereRequest.Messages)+1)
+	if len(cohereRequest.Documents) > 0 {
+		system := dto.Message{Role: "system"}
+		system.SetStringContent(cohereDocumentsPrompt(cohereRequest.Documents))
+		messages = append(messages, system)
+	}
+	for _, m := range cohereRequest.Messages {
+		message, err := cohereMessageToOpenAI(m)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	openaiRequest.Messages = messages
+	return openaiRequest, nil
+}
+
+func cohereMessageToOpenAI(m dto.CohereChatMessage) (dto.Message, error) {
+	message := dto.Message{Role: m.Role}
+	blocks := m.ParseContent()
+	switch m.Role {
+	case "assistant":
+		var text, thinking strings.Builder
+		for _, block := range blocks {
+			switch block.Type {
+			case dto.CohereContentTypeThinking:
+				thinking.WriteString(block.Thinking)
+			default:
+				text.WriteString(block.Text)
+			}
+		}
+		content := text.String()
+		// tool_plan is the assistant's text preceding its tool calls.
+		if content == "" && len(m.ToolCalls) > 0 {
+			content = m.ToolPlan
+		}
+		message.SetStringContent(content)
+		if reasoning := thinking.String(); reasoning != "" {
+			message.ReasoningContent = &reasoning
+		}
+		if len(m.ToolCalls) > 0 {
+			toolCalls := make([]dto.ToolCallRequest, 0, len(m.ToolCalls))
+			for _, toolCall := range m.ToolCalls {
+				toolCalls = append(toolCalls, dto.ToolCallRequest{
+					ID:   toolCall.ID,
+					Type: "function",
+					Function: dto.FunctionRequest{
+						Name:      toolCall.Function.Name,
+						Arguments: toolCall.Function.Arguments,
+					},
+				})
+			}
+			message.SetToolCalls(toolCalls)
+		}
+		return message, nil
+	case "tool":
+		message.ToolCallId = m.ToolCallID
+		parts := make([]string, 0, len(blocks))
+		for _, block := range blocks {
+			if block.Type == dto.CohereContentTypeDocument && block.Document != nil {
+				parts = append(parts, cohereDocumentText(block.Document.Data))
+			} else {
+				parts = append(parts, block.Text)
+			}
+		}
+		message.SetStringContent(strings.Join(parts, "\n"))
+		return message, nil
+	}
+
+	contents := make([]dto.MediaContent, 0, len(blocks))
+	hasMedia := false
+	for _, block := range blocks {
+		switch block.Type {
+		case dto.CohereContentTypeText, "":
+			contents = append(contents, dto.MediaContent{Type: dto.ContentTypeText, Text: block.Text})
+		case dto.CohereContentTypeImageURL:
+			if block.ImageURL == nil {
+				return message, fmt.Errorf("cohere image_url content requires an image_url")
+			}
+			hasMedia = true
+			contents = append(contents, dto.MediaContent{
+				Type:     dto.ContentTypeImageURL,
+				ImageUrl: &dto.MessageImageUrl{Url: block.ImageURL.URL, Detail: block.ImageURL.Detail},
+			})
+		case dto.CohereContentTypeDocument:
+			if block.Document != nil {
+				contents = append(contents, dto.MediaContent{Type: dto.ContentTypeText, Text: cohereDocumentText(block.Document.Data)})
+			}
+		default:
+			return message, fmt.Errorf("unsupported cohere content type %q", block.Type)
+		}
+	}
+	if hasMedia {
+		message.SetMediaContent(contents)
+		return message, nil
+	}
+	texts := make([]string, 0, len(contents))
+	for _, content := range contents {
+		texts = append(texts, content.Text)
+	}
+	message.SetStringContent(strings.Join(texts, "\n"))
+	return message, nil
+}
+
+func cohereDocumentsPrompt(documents []any) string {
+	var builder strings.Builder
+	builder.WriteString("Use the following documents to answer:")
+	for i, document := range documents {
+		if value, ok := document.(map[string]any); ok {
+			if data, ok := value["data"]; ok {
+				document = data
+			}
+		}
+		builder.WriteString(fmt.Sprintf("\n\n[%d] %s", i, cohereDocumentText(document)))
+	}
+	return builder.String()
+}
+
+func cohereDocumentText(data any) string {
+	if text, ok := data.(string); ok {
+		return text
+	}
+	return jsonutil.ToJSONString(data)
+}
@@ -0,0 +1,201 @@
+package coherechat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	kitutil "github.com/QuantumNous/new-api/relaykit/relayconvert/kitutil"
+	"github.com/QuantumNous/new-api/relaykit/types"
+)
+
+func cohereFinishReason(finishReason string) string {
+	switch finishReason {
+	case dto.CohereFinishReasonMaxTokens:
+		return types.FinishReasonLength
+	case dto.CohereFinishReasonToolCall:
+		return types.FinishReasonToolCalls
+	case dto.CohereFinishReasonError:
+		return types.FinishReasonContentFilter
+	default:
+		return types.FinishReasonStop
+	}
+}
+
+// UsageFromCohereUsage prefers the token counts the model saw and falls back
+// to billed units.
+func UsageFromCohereUsage(usage *dto.CohereUsage) *dto.Usage {
+	if usage == nil {
+		return nil
+	}
+	tokens := usage.Tokens
+	if tokens == nil {
+		tokens = usage.BilledUnits
+	}
+	if tokens == nil {
+		return nil
+	}
+	return &dto.Usage{
+		PromptTokens:     tokens.InputTokens,
+		CompletionTokens: tokens.OutputTokens,
+		TotalTokens:      tokens.InputTokens + tokens.OutputTokens,
+	}
+}
+
+func ResponseCohere2OpenAI(response *dto.CohereChatResponse) *dto.OpenAITextResponse {
+	var text, thinking strings.Builder
+	for _, block := range response.Message.Content {
+		if block.Type == dto.CohereContentTypeThinking {
+			thinking.WriteString(block.Thinking)
+		} else {
+			text.WriteString(block.Text)
+		}
+	}
+	content := text.String()
+	if content == "" && len(response.Message.ToolCalls) > 0 {
+		content = response.Message.ToolPlan
+	}
+	message := dto.Message{Role: "assistant"}
+	message.SetStringContent(content)
+	if reasoning := thinking.String(); reasoning != "" {
+		message.ReasoningContent = &reasoning
+	}
+	if len(response.Message.ToolCalls) > 0 {
+		toolCalls := make([]dto.ToolCallResponse, 0, len(response.Message.ToolCalls))
+		for _, toolCall := range response.Message.ToolCalls {
+			toolCalls = append(toolCalls, dto.ToolCallResponse{
+				ID:   toolCall.ID,
+				Type: "function",
+				Function: dto.FunctionResponse{
+					Name:      toolCall.Function.Name,
+					Arguments: toolCall.Function.Arguments,
+				},
+			})
+		}
+		message.SetToolCalls(toolCalls)
+	}
+
+	openAIResponse := &dto.OpenAITextResponse{
+		Id:      response.ID,
+		Object:  "chat.completion",
+		Created: kitutil.GetTimestamp(),
+		Choices: []dto.OpenAITextResponseChoice{{
+			Index:        0,
+			Message:      message,
+			FinishReason: cohereFinishReason(response.FinishReason),
+		}},
+	}
+	if usage := UsageFromCohereUsage(response.Usage); usage != nil {
+		openAIResponse.Usage = *usage
+	}
+	return openAIResponse
+}
+
+// CohereToChatStreamState turns /v2/chat stream events into OpenAI chat
+// completion chunks. Tool call indexes are carried by the events themselves;
+// message-end yields the finish and usage chunks.
+type CohereToChatStreamState struct {
+	id       string
+	created  int64
+	usage    *dto.Usage
+	finished bool
+}
+
+func NewCohereToChatStreamState(id string, created int64) *CohereToChatStreamState {
+	if id == "" {
+		id = fmt.Sprintf("chatcmpl-%s", kitutil.GetUUID())
+	}
+	if created == 0 {
+		created = kitutil.GetTimestamp()
+	}
+	return &CohereToChatStreamState{id: id, created: created}
+}
+
+func (s *CohereToChatStreamState) ConvertChunk(event *dto.CohereStreamEvent) []*dto.ChatCompletionsStreamResponse {
+	if s == nil || event == nil || s.finished {
+		return nil
+	}
+	var message *dto.CohereStreamMessage
+	if event.Delta != nil {
+		message = event.Delta.Message
+	}
+	delta := dto.ChatCompletionsStreamResponseChoiceDelta{Role: "assistant"}
+	switch event.Type {
+	case dto.CohereStreamEventMessageStart:
+		return []*dto.ChatCompletionsStreamResponse{s.chunk(delta, nil)}
+	case dto.CohereStreamEventContentStart, dto.CohereStreamEventContentDelta:
+		if message == nil || message.Content == nil {
+			return nil
+		}
+		if message.Content.Thinking != "" {
+			delta.SetReasoningContent(message.Content.Thinking)
+		}
+		if message.Content.Text != "" {
+			delta.SetContentString(message.Content.Text)
+		}
+		if message.Content.Thinking == "" && message.Content.Text == "" {
+			return nil
+		}
+	case dto.CohereStreamEventToolPlanDelta:
+		if message == nil || message.ToolPlan == "" {
+			return nil
+		}
+		delta.SetContentString(message.ToolPlan)
+	case dto.CohereStreamEventToolCallStart, dto.CohereStreamEventToolCallDelta:
+		if message == nil || message.ToolCalls == nil {
+			return nil
+		}
+		toolCall := dto.ToolCallResponse{
+			ID: message.ToolCalls.ID,
+			Function: dto.FunctionResponse{
+				Name:      message.ToolCalls.Function.Name,
+				Arguments: message.ToolCalls.Function.Arguments,
+			},
+		}
+		if event.Type == dto.CohereStreamEventToolCallStart {
+			toolCall.Type = "function"
+		}
+		if event.Index != nil {
+			toolCall.SetIndex(*event.Index)
+		}
+		delta.ToolCalls = []dto.ToolCallResponse{toolCall}
+	case dto.CohereStreamEventMessageEnd:
+		s.finished = true
+		finishReason := types.FinishReasonStop
+		if event.Delta != nil {
+			finishReason = cohereFinishReason(event.Delta.FinishReason)
+			s.usage = UsageFromCohereUsage(event.Delta.Usage)
+		}
+		responses := []*dto.ChatCompletionsStreamResponse{s.chunk(dto.ChatCompletionsStreamResponseChoiceDelta{}, &finishReason)}
+		if s.usage != nil {
+			usageChunk := s.chunk(dto.ChatCompletionsStreamResponseChoiceDelta{}, nil)
+			usageChunk.Choices = []dto.ChatCompletionsStreamResponseChoice{}
+			usageChunk.Usage = s.usage
+			responses = append(responses, usageChunk)
+		}
+		return responses
+	default:
+		return nil
+	}
+	return []*dto.ChatCompletionsStreamResponse{s.chunk(delta, nil)}
+}
+
+func (s *CohereToChatStreamState) chunk(delta dto.ChatCompletionsStreamResponseChoiceDelta, finishReason *string) *dto.ChatCompletionsStreamResponse {
+	return &dto.ChatCompletionsStreamResponse{
+		Id:      s.id,
+		Object:  "chat.completion.chunk",
+		Created: s.created,
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{
+			Index:        0,
+			Delta:        delta,
+			FinishReason: finishReason,
+		}},
+	}
+}
+
+func (s *CohereToChatStreamState) Usage() *dto.Usage {
+	if s == nil {
+		return nil
+	}
+	return s.usage
+}
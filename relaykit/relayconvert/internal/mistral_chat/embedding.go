@@ -0,0 +1,21 @@
+package mistralchat
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/relaykit/dto"
+)
+
+// MistralEmbeddingRequestToOpenAI converts a Mistral /v1/embeddings request;
+// only float output has an OpenAI counterpart.
+func MistralEmbeddingRequestToOpenAI(mistralRequest *dto.MistralEmbeddingRequest) (*dto.EmbeddingRequest, error) {
+	if mistralRequest.OutputDtype != "" && mistralRequest.OutputDtype != "float" {
+		return nil, fmt.Errorf("unsupported mistral output_dtype %q", mistralRequest.OutputDtype)
+	}
+	return &dto.EmbeddingRequest{
+		Model:          mistralRequest.Model,
+		Input:          mistralRequest.Input,
+		EncodingFormat: mistralRequest.EncodingFormat,
+		Dimensions:     mistralRequest.OutputDimension,
+	}, nil
+}
@@ -0,0 +1,124 @@
+package mistralchat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	kitutil "github.com/QuantumNous/new-api/relaykit/relayconvert/kitutil"
+)
+
+// MistralChatRequestToOpenAIChat converts a Mistral /v1/chat/completions
+// request to an OpenAI chat completions request. safe_prompt and prompt_mode
+// only affect Mistral's own system prompt and are dropped.
+func MistralChatRequestToOpenAIChat(mistralRequest *dto.MistralChatRequest) (*dto.GeneralOpenAIRequest, error) {
+	openaiRequest := &dto.GeneralOpenAIRequest{
+		Model:            mistralRequest.Model,
+		Stream:           kitutil.GetPointer(mistralRequest.Stream),
+		Temperature:      mistralRequest.Temperature,
+		TopP:             mistralRequest.TopP,
+		Stop:             mistralRequest.Stop,
+		ResponseFormat:   mistralRequest.ResponseFormat,
+		Tools:            mistralRequest.Tools,
+		FrequencyPenalty: mistralRequest.FrequencyPenalty,
+		PresencePenalty:  mistralRequest.PresencePenalty,
+		N:                mistralRequest.N,
+		ParallelTooCalls: mistralRequest.ParallelToolCalls,
+	}
+	if mistralRequest.Stream {
+		openaiRequest.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+	}
+	if mistralRequest.MaxTokens != nil && *mistralRequest.MaxTokens > 0 {
+		openaiRequest.MaxTokens = kitutil.GetPointer(uint(*mistralRequest.MaxTokens))
+	}
+	if mistralRequest.RandomSeed != nil {
+		openaiRequest.Seed = kitutil.GetPointer(float64(*mistralRequest.RandomSeed))
+	}
+	// "any" is Mistral's spelling of OpenAI's "required".
+	if toolChoice, ok := mistralRequest.ToolChoice.(string); ok && toolChoice == "any" {
+		openaiRequest.ToolChoice = "required"
+	} else {
+		openaiRequest.ToolChoice = mistralRequest.ToolChoice
+	}
+
+	messages := make([]dto.Message, 0, len(mistralRequest.Messages))
+	for _, m := range mistralRequest.Messages {
+		message := dto.Message{Role: m.Role, ToolCallId: m.ToolCallID}
+		if m.Name != "" {
+			message.Name = kitutil.GetPointer(m.Name)
+		}
+		contents, reasoning, err := mistralContentToOpenAI(m.ParseContent())
+		if err != nil {
+			return nil, err
+		}
+		if isTextOnly(contents) {
+			texts := make([]string, 0, len(contents))
+			for _, content := range contents {
+				texts = append(texts, content.Text)
+			}
+			message.SetStringContent(strings.Join(texts, ""))
+		} else {
+			message.SetMediaContent(contents)
+		}
+		if reasoning != "" && m.Role == "assistant" {
+			message.ReasoningContent = &reasoning
+		}
+		if len(m.ToolCalls) > 0 {
+			toolCalls := make([]dto.ToolCallRequest, 0, len(m.ToolCalls))
+			for _, toolCall := range m.ToolCalls {
+				toolCalls = append(toolCalls, dto.ToolCallRequest{
+					ID:   toolCall.ID,
+					Type: "function",
+					Function: dto.FunctionRequest{
+						Name:      toolCall.Function.Name,
+						Arguments: toolCall.Function.ArgumentsString(),
+					},
+				})
+			}
+			message.SetToolCalls(toolCalls)
+		}
+		messages = append(messages, message)
+	}
+	openaiRequest.Messages = messages
+	return openaiRequest, nil
+}
+
+// mistralContentToOpenAI splits Mistral chunks into OpenAI content parts and
+// the concatenated text of any thinking chunks.
+func mistralContentToOpenAI(chunks []dto.MistralContentChunk) ([]dto.MediaContent, string, error) {
+	contents := make([]dto.MediaContent, 0, len(chunks))
+	var reasoning strings.Builder
+	for _, chunk := range chunks {
+		switch chunk.Type {
+		case dto.MistralContentTypeText, "":
+			contents = append(contents, dto.MediaContent{Type: dto.ContentTypeText, Text: chunk.Text})
+		case dto.MistralContentTypeImageURL:
+			contents = append(contents, dto.MediaContent{
+				Type:     dto.ContentTypeImageURL,
+				ImageUrl: &dto.MessageImageUrl{Url: chunk.ImageURLString(), Detail: "auto"},
+			})
+		case dto.MistralContentTypeThinking:
+			reasoning.WriteString(mistralThinkingText(chunk.Thinking))
+		default:
+			return nil, "", fmt.Errorf("unsupported mistral content type %q", chunk.Type)
+		}
+	}
+	return contents, reasoning.String(), nil
+}
+
+func mistralThinkingText(chunks []dto.MistralContentChunk) string {
+	var builder strings.Builder
+	for _, chunk := range chunks {
+		builder.WriteString(chunk.Text)
+	}
+	return builder.String()
+}
+
+func isTextOnly(contents []dto.MediaContent) bool {
+	for _, content := range contents {
+		if content.Type != dto.ContentTypeText {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+package mistralchat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	kitutil "github.com/QuantumNous/new-api/relaykit/relayconvert/kitutil"
+)
+
+func UsageFromMistralUsage(usage *dto.MistralUsage) *dto.Usage {
+	if usage == nil {
+		return nil
+	}
+	return &dto.Usage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+}
+
+// mistralMessageText splits response content into text and thinking; Mistral
+// returns a plain string unless the model emitted thinking chunks.
+func mistralMessageText(message *dto.MistralResponseMessage) (string, string) {
+	var text, reasoning strings.Builder
+	for _, chunk := range message.ParseContent() {
+		if chunk.Type == dto.MistralContentTypeThinking {
+			reasoning.WriteString(mistralThinkingText(chunk.Thinking))
+		} else {
+			text.WriteString(chunk.Text)
+		}
+	}
+	return text.String(), reasoning.String()
+}
+
+func mistralToolCallsToOpenAI(toolCalls []dto.MistralToolCall, withIndex bool) []dto.ToolCallResponse {
+	if len(toolCalls) == 0 {
+		return nil
+	}
+	result := make([]dto.ToolCallResponse, 0, len(toolCalls))
+	for i, toolCall := range toolCalls {
+		response := dto.ToolCallResponse{
+			ID:   toolCall.ID,
+			Type: "function",
+			Function: dto.FunctionResponse{
+				Name:      toolCall.Function.Name,
+				Arguments: toolCall.Function.ArgumentsString(),
+			},
+		}
+		if withIndex {
+			response.SetIndex(i)
+			if toolCall.Index != nil {
+				response.SetIndex(*toolCall.Index)
+			}
+		}
+		result = append(result, response)
+	}
+	return result
+}
+
+func ResponseMistral2OpenAI(response *dto.MistralChatResponse) *dto.OpenAITextResponse {
+	openAIResponse := &dto.OpenAITextResponse{
+		Id:      response.ID,
+		Model:   response.Model,
+		Object:  "chat.completion",
+		Created: response.Created,
+		Choices: make([]dto.OpenAITextResponseChoice, 0, len(response.Choices)),
+		Usage:   *UsageFromMistralUsage(&response.Usage),
+	}
+	for _, choice := range response.Choices {
+		text, reasoning := mistralMessageText(&choice.Message)
+		message := dto.Message{Role: "assistant"}
+		message.SetStringContent(text)
+		if reasoning != "" {
+			message.ReasoningContent = &reasoning
+		}
+		if toolCalls := mistralToolCallsToOpenAI(choice.Message.ToolCalls, false); len(toolCalls) > 0 {
+			message.SetToolCalls(toolCalls)
+		}
+		openAIResponse.Choices = append(openAIResponse.Choices, dto.OpenAITextResponseChoice{
+			Index:        choice.Index,
+			Message:      message,
+			FinishReason: choice.FinishReason,
+		})
+	}
+	return openAIResponse
+}
+
+// MistralToChatStreamState maps Mistral stream chunks one-to-one onto OpenAI
+// chat completion chunks and remembers the usage of the final chunk.
+type MistralToChatStreamState struct {
+	id      string
+	created int64
+	usage   *dto.Usage
+}
+
+func NewMistralToChatStreamState(id string, created int64) *MistralToChatStreamState {
+	if id == "" {
+		id = fmt.Sprintf("chatcmpl-%s", kitutil.GetUUID())
+	}
+	if created == 0 {
+		created = kitutil.GetTimestamp()
+	}
+	return &MistralToChatStreamState{id: id, created: created}
+}
+
+func (s *MistralToChatStreamState) ConvertChunk(chunk *dto.MistralStreamResponse) []*dto.ChatCompletionsStreamResponse {
+	if s == nil || chunk == nil {
+		return nil
+	}
+	response := &dto.ChatCompletionsStreamResponse{
+		Id:      s.id,
+		Object:  "chat.completion.chunk",
+		Created: s.created,
+		Model:   chunk.Model,
+		Choices: make([]dto.ChatCompletionsStreamResponseChoice, 0, len(chunk.Choices)),
+	}
+	if chunk.Created != 0 {
+		response.Created = chunk.Created
+	}
+	for _, choice := range chunk.Choices {
+		delta := dto.ChatCompletionsStreamResponseChoiceDelta{Role: choice.Delta.Role}
+		text, reasoning := mistralMessageText(&choice.Delta)
+		if text != "" {
+			delta.SetContentString(text)
+		}
+		if reasoning != "" {
+			delta.SetReasoningContent(reasoning)
+		}
+		delta.ToolCalls = mistralToolCallsToOpenAI(choice.Delta.ToolCalls, true)
+		response.Choices = append(response.Choices, dto.ChatCompletionsStreamResponseChoice{
+			Index:        choice.Index,
+			Delta:        delta,
+			FinishReason: choice.FinishReason,
+		})
+	}
+	if chunk.Usage != nil {
+		s.usage = UsageFromMistralUsage(chunk.Usage)
+		response.Usage = s.usage
+	}
+	return []*dto.ChatCompletionsStreamResponse{response}
+}
+
+func (s *MistralToChatStreamState) Usage() *dto.Usage {
+	if s == nil {
+		return nil
+	}
+	return s.usage
+}
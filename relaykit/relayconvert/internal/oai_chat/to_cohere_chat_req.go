@@ -0,0 +1,155 @@
+package oaichat
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	kitutil "github.com/QuantumNous/new-api/relaykit/relayconvert/kitutil"
+
+	"github.com/samber/lo"
+)
+
+// stopSequences normalises OpenAI's string-or-array stop into a list.
+func stopSequences(stop any) []string {
+	switch v := stop.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		return lo.FilterMap(v, func(item any, _ int) (string, bool) {
+			value, ok := item.(string)
+			return value, ok
+		})
+	}
+	return nil
+}
+
+func toCohereResponseFormat(responseFormat *dto.ResponseFormat) (*dto.CohereResponseFormat, error) {
+	if responseFormat == nil {
+		return nil, nil
+	}
+	switch responseFormat.Type {
+	case "json_object":
+		return &dto.CohereResponseFormat{Type: "json_object"}, nil
+	case "json_schema":
+		format := &dto.CohereResponseFormat{Type: "json_object"}
+		if len(responseFormat.JsonSchema) > 0 {
+			var jsonSchema dto.FormatJsonSchema
+			if err := kitutil.Unmarshal(responseFormat.JsonSchema, &jsonSchema); err != nil {
+				return nil, fmt.Errorf("invalid cohere response format: %w", err)
+			}
+			format.JsonSchema = jsonSchema.Schema
+		}
+		return format, nil
+	default:
+		return nil, nil
+	}
+}
+
+func OpenAIChatRequestToCohereChat(r *dto.GeneralOpenAIRequest) (*dto.CohereChatRequest, error) {
+	cohereRequest := &dto.CohereChatRequest{
+		Model:            r.Model,
+		Stream:           lo.FromPtr(r.Stream),
+		Temperature:      r.Temperature,
+		P:                r.TopP,
+		K:                r.TopK,
+		FrequencyPenalty: r.FrequencyPenalty,
+		PresencePenalty:  r.PresencePenalty,
+		StopSequences:    stopSequences(r.Stop),
+	}
+	if maxTokens := r.GetMaxTokens(); maxTokens != 0 {
+		cohereRequest.MaxTokens = kitutil.GetPointer(int(maxTokens))
+	}
+	if r.Seed != nil {
+		cohereRequest.Seed = kitutil.GetPointer(int(*r.Seed))
+	}
+	responseFormat, err := toCohereResponseFormat(r.ResponseFormat)
+	if err != nil {
+		return nil, err
+	}
+	cohereRequest.ResponseFormat = responseFormat
+
+	for _, tool := range r.Tools {
+		if tool.Type != "" && tool.Type != "function" {
+			continue
+		}
+		cohereRequest.Tools = append(cohereRequest.Tools, dto.CohereTool{Type: "function", Function: tool.Function})
+	}
+	if toolChoice, ok := r.ToolChoice.(string); ok {
+		switch toolChoice {
+		case "required":
+			cohereRequest.ToolChoice = "REQUIRED"
+		case "none":
+			cohereRequest.ToolChoice = "NONE"
+		}
+	}
+
+	cohereRequest.Messages = make([]dto.CohereChatMessage, 0, len(r.Messages))
+	for _, m := range r.Messages {
+		message := dto.CohereChatMessage{Role: m.Role}
+		switch m.Role {
+		case "developer":
+			message.Role = "system"
+			message.Content = m.StringContent()
+		case "assistant":
+			blocks := make([]dto.CohereContent, 0, 2)
+			if reasoning, ok := lo.Coalesce(m.ReasoningContent, m.Reasoning); ok && *reasoning != "" {
+				blocks = append(blocks, dto.CohereContent{Type: dto.CohereContentTypeThinking, Thinking: *reasoning})
+			}
+			text := m.StringContent()
+			toolCalls := m.ParseToolCalls()
+			if len(toolCalls) > 0 {
+				message.ToolPlan = text
+				for _, toolCall := range toolCalls {
+					message.ToolCalls = append(message.ToolCalls, dto.CohereToolCall{
+						ID:   toolCall.ID,
+						Type: "function",
+						Function: dto.CohereToolFunction{
+							Name:      toolCall.Function.Name,
+							Arguments: toolCall.Function.Arguments,
+						},
+					})
+				}
+			} else if text != "" {
+				blocks = append(blocks, dto.CohereContent{Type: dto.CohereContentTypeText, Text: text})
+			}
+			if len(blocks) > 0 {
+				message.Content = blocks
+			}
+		case "tool":
+			message.ToolCallID = m.ToolCallId
+			message.Content = m.StringContent()
+		default:
+			if m.IsStringContent() {
+				message.Content = m.StringContent()
+				break
+			}
+			var blocks []dto.CohereContent
+			for _, part := range m.ParseContent() {
+				switch part.Type {
+				case dto.ContentTypeText:
+					blocks = append(blocks, dto.CohereContent{Type: dto.CohereContentTypeText, Text: part.Text})
+				case dto.ContentTypeImageURL:
+					if image := part.GetImageMedia(); image != nil {
+						blocks = append(blocks, dto.CohereContent{
+							Type:     dto.CohereContentTypeImageURL,
+							ImageURL: &dto.CohereImageURL{URL: image.Url, Detail: image.Detail},
+						})
+					}
+				default:
+					return nil, fmt.Errorf("unsupported cohere content type %q", part.Type)
+				}
+			}
+			message.Content = blocks
+		}
+		cohereRequest.Messages = append(cohereRequest.Messages, message)
+	}
+	if len(cohereRequest.Messages) == 0 {
+		return nil, fmt.Errorf("cohere chat requires at least one message")
+	}
+	return cohereRequest, nil
+}
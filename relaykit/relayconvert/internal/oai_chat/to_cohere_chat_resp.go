@@ -0,0 +1,217 @@
+package oaichat
+
+import (
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	kitutil "github.com/QuantumNous/new-api/relaykit/relayconvert/kitutil"
+	"github.com/QuantumNous/new-api/relaykit/types"
+
+	"github.com/samber/lo"
+)
+
+func cohereFinishReason(finishReason string) string {
+	switch finishReason {
+	case types.FinishReasonLength:
+		return dto.CohereFinishReasonMaxTokens
+	case types.FinishReasonToolCalls, types.FinishReasonFunctionCall:
+		return dto.CohereFinishReasonToolCall
+	case types.FinishReasonContentFilter:
+		return dto.CohereFinishReasonError
+	default:
+		return dto.CohereFinishReasonComplete
+	}
+}
+
+func cohereUsage(usage *dto.Usage) *dto.CohereUsage {
+	if usage == nil {
+		return nil
+	}
+	tokens := &dto.CohereTokenUsage{InputTokens: usage.PromptTokens, OutputTokens: usage.CompletionTokens}
+	return &dto.CohereUsage{BilledUnits: tokens, Tokens: tokens}
+}
+
+func ResponseOpenAI2Cohere(response *dto.OpenAITextResponse) *dto.CohereChatResponse {
+	cohereResponse := &dto.CohereChatResponse{
+		ID:           response.Id,
+		FinishReason: dto.CohereFinishReasonComplete,
+		Message:      dto.CohereResponseMessage{Role: "assistant"},
+		Usage:        cohereUsage(&response.Usage),
+	}
+	if len(response.Choices) == 0 {
+		return cohereResponse
+	}
+	choice := response.Choices[0]
+	cohereResponse.FinishReason = cohereFinishReason(choice.FinishReason)
+	if reasoning, ok := lo.Coalesce(choice.Message.ReasoningContent, choice.Message.Reasoning); ok && *reasoning != "" {
+		cohereResponse.Message.Content = append(cohereResponse.Message.Content, dto.CohereContent{Type: dto.CohereContentTypeThinking, Thinking: *reasoning})
+	}
+	text := choice.Message.StringContent()
+	toolCalls := choice.Message.ParseToolCalls()
+	if len(toolCalls) > 0 {
+		cohereResponse.Message.ToolPlan = text
+		for _, toolCall := range toolCalls {
+			cohereResponse.Message.ToolCalls = append(cohereResponse.Message.ToolCalls, dto.CohereToolCall{
+				ID:   toolCall.ID,
+				Type: "function",
+				Function: dto.CohereToolFunction{
+					Name:      toolCall.Function.Name,
+					Arguments: toolCall.Function.Arguments,
+				},
+			})
+		}
+	} else if text != "" {
+		cohereResponse.Message.Content = append(cohereResponse.Message.Content, dto.CohereContent{Type: dto.CohereContentTypeText, Text: text})
+	}
+	return cohereResponse
+}
+
+// ChatToCohereStreamState turns OpenAI chat completion chunks into /v2/chat
+// stream events. Cohere brackets every content block and tool call with start
+// and end events, so the state tracks which one is open; message-end carries
+// the usage and is only emitted by Finalize because the usage chunk arrives
+// after finish_reason.
+type ChatToCohereStreamState struct {
+	id           string
+	started      bool
+	blockType    string
+	blockIndex   int
+	toolIndex    int
+	toolOpen     bool
+	finishReason string
+	usage        *dto.Usage
+	finished     bool
+}
+
+func NewChatToCohereStreamState(id string) *ChatToCohereStreamState {
+	if id == "" {
+		id = kitutil.GetUUID()
+	}
+	return &ChatToCohereStreamState{id: id, blockIndex: -1, toolIndex: -1}
+}
+
+func (s *ChatToCohereStreamState) ConvertChunk(chunk *dto.ChatCompletionsStreamResponse) []*dto.CohereStreamEvent {
+	if s == nil || chunk == nil || s.finished {
+		return nil
+	}
+	if chunk.Usage != nil {
+		s.usage = chunk.Usage
+	}
+	events := s.start()
+	if len(chunk.Choices) == 0 {
+		return events
+	}
+	choice := chunk.Choices[0]
+	if choice.FinishReason != nil && *choice.FinishReason != "" {
+		s.finishReason = *choice.FinishReason
+	}
+	if reasoning := choice.Delta.GetReasoningContent(); reasoning != "" {
+		events = append(events, s.contentDelta(dto.CohereContent{Type: dto.CohereContentTypeThinking, Thinking: reasoning})...)
+	}
+	if content := choice.Delta.GetContentString(); content != "" {
+		events = append(events, s.contentDelta(dto.CohereContent{Type: dto.CohereContentTypeText, Text: content})...)
+	}
+	for i, toolCall := range choice.Delta.ToolCalls {
+		index := i
+		if toolCall.Index != nil {
+			index = *toolCall.Index
+		}
+		events = append(events, s.closeBlock()...)
+		if !s.toolOpen || index != s.toolIndex {
+			events = append(events, s.closeToolCall()...)
+			s.toolIndex, s.toolOpen = index, true
+			events = append(events, s.event(dto.CohereStreamEventToolCallStart, s.toolIndex, &dto.CohereStreamMessage{
+				ToolCalls: &dto.CohereToolCall{
+					ID:   toolCall.ID,
+					Type: "function",
+					Function: dto.CohereToolFunction{
+						Name:      toolCall.Function.Name,
+						Arguments: toolCall.Function.Arguments,
+					},
+				},
+			}))
+			continue
+		}
+		if toolCall.Function.Arguments != "" {
+			events = append(events, s.event(dto.CohereStreamEventToolCallDelta, s.toolIndex, &dto.CohereStreamMessage{
+				ToolCalls: &dto.CohereToolCall{Function: dto.CohereToolFunction{Arguments: toolCall.Function.Arguments}},
+			}))
+		}
+	}
+	return events
+}
+
+func (s *ChatToCohereStreamState) Finalize() []*dto.CohereStreamEvent {
+	if s == nil || s.finished {
+		return nil
+	}
+	events := s.start()
+	events = append(events, s.closeBlock()...)
+	events = append(events, s.closeToolCall()...)
+	s.finished = true
+	return append(events, &dto.CohereStreamEvent{
+		Type: dto.CohereStreamEventMessageEnd,
+		ID:   s.id,
+		Delta: &dto.CohereStreamDelta{
+			FinishReason: cohereFinishReason(s.finishReason),
+			Usage:        cohereUsage(s.usage),
+		},
+	})
+}
+
+func (s *ChatToCohereStreamState) Usage() *dto.Usage {
+	if s == nil {
+		return nil
+	}
+	return s.usage
+}
+
+func (s *ChatToCohereStreamState) start() []*dto.CohereStreamEvent {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	return []*dto.CohereStreamEvent{{
+		Type:  dto.CohereStreamEventMessageStart,
+		ID:    s.id,
+		Delta: &dto.CohereStreamDelta{Message: &dto.CohereStreamMessage{Role: "assistant"}},
+	}}
+}
+
+// contentDelta opens a block of the content's type when needed; switching
+// between thinking and text closes the previous block first.
+func (s *ChatToCohereStreamState) contentDelta(content dto.CohereContent) []*dto.CohereStreamEvent {
+	var events []*dto.CohereStreamEvent
+	if s.blockType != content.Type {
+		events = append(events, s.closeBlock()...)
+		events = append(events, s.closeToolCall()...)
+		s.blockIndex++
+		s.blockType = content.Type
+		events = append(events, s.event(dto.CohereStreamEventContentStart, s.blockIndex, &dto.CohereStreamMessage{
+			Content: &dto.CohereContent{Type: content.Type},
+		}))
+	}
+	return append(events, s.event(dto.CohereStreamEventContentDelta, s.blockIndex, &dto.CohereStreamMessage{Content: &content}))
+}
+
+func (s *ChatToCohereStreamState) closeBlock() []*dto.CohereStreamEvent {
+	if s.blockType == "" {
+		return nil
+	}
+	s.blockType = ""
+	return []*dto.CohereStreamEvent{s.event(dto.CohereStreamEventContentEnd, s.blockIndex, nil)}
+}
+
+func (s *ChatToCohereStreamState) closeToolCall() []*dto.CohereStreamEvent {
+	if !s.toolOpen {
+		return nil
+	}
+	s.toolOpen = false
+	return []*dto.CohereStreamEvent{s.event(dto.CohereStreamEventToolCallEnd, s.toolIndex, nil)}
+}
+
+func (s *ChatToCohereStreamState) event(eventType string, index int, message *dto.CohereStreamMessage) *dto.CohereStreamEvent {
+	event := &dto.CohereStreamEvent{Type: eventType, Index: kitutil.GetPointer(index)}
+	if message != nil {
+		event.Delta = &dto.CohereStreamDelta{Message: message}
+	}
+	return event
+}
@@ -0,0 +1,74 @@
+package oaichat
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func cohereEventTypes(events []*dto.CohereStreamEvent) []string {
+	types := make([]string, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestChatToCohereStreamStateBracketsBlocksAndToolCalls(t *testing.T) {
+	state := NewChatToCohereStreamState("msg_1")
+	finishReason := "tool_calls"
+
+	reasoning := dto.ChatCompletionsStreamResponseChoiceDelta{}
+	reasoning.SetReasoningContent("thinking")
+	events := state.ConvertChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: reasoning}},
+	})
+	assert.Equal(t, []string{"message-start", "content-start", "content-delta"}, cohereEventTypes(events))
+	assert.Equal(t, "msg_1", events[0].ID)
+	assert.Equal(t, "thinking", events[2].Delta.Message.Content.Thinking)
+
+	content := dto.ChatCompletionsStreamResponseChoiceDelta{}
+	content.SetContentString("checking")
+	events = state.ConvertChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: content}},
+	})
+	assert.Equal(t, []string{"content-end", "content-start", "content-delta"}, cohereEventTypes(events))
+	assert.Equal(t, 1, *events[2].Index)
+	assert.Equal(t, "checking", events[2].Delta.Message.Content.Text)
+
+	first := dto.ToolCallResponse{ID: "call_1", Type: "function", Function: dto.FunctionResponse{Name: "lookup", Arguments: `{"q":`}}
+	first.SetIndex(0)
+	second := dto.ToolCallResponse{Function: dto.FunctionResponse{Arguments: `"x"}`}}
+	second.SetIndex(0)
+	events = state.ConvertChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{first}}}},
+	})
+	require.Equal(t, []string{"content-end", "tool-call-start"}, cohereEventTypes(events))
+	assert.Equal(t, "call_1", events[1].Delta.Message.ToolCalls.ID)
+	assert.Equal(t, "lookup", events[1].Delta.Message.ToolCalls.Function.Name)
+
+	events = state.ConvertChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{
+			Delta:        dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{second}},
+			FinishReason: &finishReason,
+		}},
+	})
+	require.Equal(t, []string{"tool-call-delta"}, cohereEventTypes(events))
+	assert.Equal(t, `"x"}`, events[0].Delta.Message.ToolCalls.Function.Arguments)
+
+	assert.Empty(t, state.ConvertChunk(&dto.ChatCompletionsStreamResponse{
+		Usage: &dto.Usage{PromptTokens: 9, CompletionTokens: 4, TotalTokens: 13},
+	}))
+
+	events = state.Finalize()
+	require.Equal(t, []string{"tool-call-end", "message-end"}, cohereEventTypes(events))
+	end := events[1].Delta
+	assert.Equal(t, dto.CohereFinishReasonToolCall, end.FinishReason)
+	require.NotNil(t, end.Usage)
+	assert.Equal(t, 9, end.Usage.Tokens.InputTokens)
+	assert.Equal(t, 4, end.Usage.BilledUnits.OutputTokens)
+	assert.Equal(t, 13, state.Usage().TotalTokens)
+	assert.Nil(t, state.Finalize())
+}
@@ -0,0 +1,86 @@
+package oaichat
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	kitutil "github.com/QuantumNous/new-api/relaykit/relayconvert/kitutil"
+
+	"github.com/samber/lo"
+)
+
+func OpenAIChatRequestToMistralChat(r *dto.GeneralOpenAIRequest) (*dto.MistralChatRequest, error) {
+	mistralRequest := &dto.MistralChatRequest{
+		Model:             r.Model,
+		Stream:            lo.FromPtr(r.Stream),
+		Temperature:       r.Temperature,
+		TopP:              r.TopP,
+		Stop:              r.Stop,
+		ResponseFormat:    r.ResponseFormat,
+		Tools:             r.Tools,
+		ToolChoice:        r.ToolChoice,
+		FrequencyPenalty:  r.FrequencyPenalty,
+		PresencePenalty:   r.PresencePenalty,
+		N:                 r.N,
+		ParallelToolCalls: r.ParallelTooCalls,
+	}
+	if maxTokens := r.GetMaxTokens(); maxTokens != 0 {
+		mistralRequest.MaxTokens = kitutil.GetPointer(int(maxTokens))
+	}
+	if r.Seed != nil {
+		mistralRequest.RandomSeed = kitutil.GetPointer(int(*r.Seed))
+	}
+	if toolChoice, ok := r.ToolChoice.(string); ok && toolChoice == "required" {
+		mistralRequest.ToolChoice = "any"
+	}
+
+	mistralRequest.Messages = make([]dto.MistralMessage, 0, len(r.Messages))
+	for _, m := range r.Messages {
+		message := dto.MistralMessage{Role: m.Role, ToolCallID: m.ToolCallId, Name: lo.FromPtr(m.Name)}
+		if m.Role == "developer" {
+			message.Role = "system"
+		}
+		reasoning := ""
+		if m.Role == "assistant" {
+			if value, ok := lo.Coalesce(m.ReasoningContent, m.Reasoning); ok {
+				reasoning = *value
+			}
+		}
+		if m.IsStringContent() && reasoning == "" {
+			message.Content = m.StringContent()
+		} else {
+			var chunks []dto.MistralContentChunk
+			if reasoning != "" {
+				chunks = append(chunks, dto.MistralContentChunk{
+					Type:     dto.MistralContentTypeThinking,
+					Thinking: []dto.MistralContentChunk{{Type: dto.MistralContentTypeText, Text: reasoning}},
+				})
+			}
+			for _, part := range m.ParseContent() {
+				switch part.Type {
+				case dto.ContentTypeText:
+					chunks = append(chunks, dto.MistralContentChunk{Type: dto.MistralContentTypeText, Text: part.Text})
+				case dto.ContentTypeImageURL:
+					if image := part.GetImageMedia(); image != nil {
+						chunks = append(chunks, dto.MistralContentChunk{Type: dto.MistralContentTypeImageURL, ImageURL: image.Url})
+					}
+				default:
+					return nil, fmt.Errorf("unsupported mistral content type %q", part.Type)
+				}
+			}
+			message.Content = chunks
+		}
+		for _, toolCall := range m.ParseToolCalls() {
+			message.ToolCalls = append(message.ToolCalls, dto.MistralToolCall{
+				ID:   toolCall.ID,
+				Type: "function",
+				Function: dto.MistralFunctionCall{
+					Name:      toolCall.Function.Name,
+					Arguments: toolCall.Function.Arguments,
+				},
+			})
+		}
+		mistralRequest.Messages = append(mistralRequest.Messages, message)
+	}
+	return mistralRequest, nil
+}
@@ -0,0 +1,148 @@
+package oaichat
+
+import (
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	kitutil "github.com/QuantumNous/new-api/relaykit/relayconvert/kitutil"
+
+	"github.com/samber/lo"
+)
+
+// mistralContent renders text and reasoning the way Mistral does: a plain
+// string, or a chunk list led by a thinking chunk when reasoning is present.
+func mistralContent(text string, reasoning string) any {
+	if reasoning == "" {
+		return text
+	}
+	chunks := []dto.MistralContentChunk{{
+		Type:     dto.MistralContentTypeThinking,
+		Thinking: []dto.MistralContentChunk{{Type: dto.MistralContentTypeText, Text: reasoning}},
+	}}
+	if text != "" {
+		chunks = append(chunks, dto.MistralContentChunk{Type: dto.MistralContentTypeText, Text: text})
+	}
+	return chunks
+}
+
+func mistralUsage(usage *dto.Usage) *dto.MistralUsage {
+	if usage == nil {
+		return nil
+	}
+	return &dto.MistralUsage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+}
+
+func mistralToolCalls(toolCalls []dto.ToolCallResponse) []dto.MistralToolCall {
+	if len(toolCalls) == 0 {
+		return nil
+	}
+	result := make([]dto.MistralToolCall, 0, len(toolCalls))
+	for _, toolCall := range toolCalls {
+		result = append(result, dto.MistralToolCall{
+			ID:    toolCall.ID,
+			Type:  "function",
+			Index: toolCall.Index,
+			Function: dto.MistralFunctionCall{
+				Name:      toolCall.Function.Name,
+				Arguments: toolCall.Function.Arguments,
+			},
+		})
+	}
+	return result
+}
+
+func mistralCreated(created any) int64 {
+	switch v := created.(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	}
+	return kitutil.GetTimestamp()
+}
+
+func ResponseOpenAI2Mistral(response *dto.OpenAITextResponse) *dto.MistralChatResponse {
+	mistralResponse := &dto.MistralChatResponse{
+		ID:      response.Id,
+		Object:  "chat.completion",
+		Model:   response.Model,
+		Created: mistralCreated(response.Created),
+		Choices: make([]dto.MistralChatChoice, 0, len(response.Choices)),
+		Usage:   *mistralUsage(&response.Usage),
+	}
+	for _, choice := range response.Choices {
+		reasoning := ""
+		if value, ok := lo.Coalesce(choice.Message.ReasoningContent, choice.Message.Reasoning); ok {
+			reasoning = *value
+		}
+		var toolCalls []dto.MistralToolCall
+		for _, toolCall := range choice.Message.ParseToolCalls() {
+			toolCalls = append(toolCalls, dto.MistralToolCall{
+				ID:       toolCall.ID,
+				Type:     "function",
+				Function: dto.MistralFunctionCall{Name: toolCall.Function.Name, Arguments: toolCall.Function.Arguments},
+			})
+		}
+		mistralResponse.Choices = append(mistralResponse.Choices, dto.MistralChatChoice{
+			Index: choice.Index,
+			Message: dto.MistralResponseMessage{
+				Role:      "assistant",
+				Content:   mistralContent(choice.Message.StringContent(), reasoning),
+				ToolCalls: toolCalls,
+			},
+			FinishReason: choice.FinishReason,
+		})
+	}
+	return mistralResponse
+}
+
+// ChatToMistralStreamState maps OpenAI chat completion chunks one-to-one onto
+// Mistral stream chunks; Mistral reports usage on the final chunk, which the
+// include_usage chunk already is.
+type ChatToMistralStreamState struct {
+	usage *dto.Usage
+}
+
+func NewChatToMistralStreamState() *ChatToMistralStreamState {
+	return &ChatToMistralStreamState{}
+}
+
+func (s *ChatToMistralStreamState) ConvertChunk(chunk *dto.ChatCompletionsStreamResponse) []*dto.MistralStreamResponse {
+	if s == nil || chunk == nil {
+		return nil
+	}
+	response := &dto.MistralStreamResponse{
+		ID:      chunk.Id,
+		Object:  "chat.completion.chunk",
+		Model:   chunk.Model,
+		Created: chunk.Created,
+		Choices: make([]dto.MistralStreamChoice, 0, len(chunk.Choices)),
+	}
+	for _, choice := range chunk.Choices {
+		response.Choices = append(response.Choices, dto.MistralStreamChoice{
+			Index: choice.Index,
+			Delta: dto.MistralResponseMessage{
+				Role:      choice.Delta.Role,
+				Content:   mistralContent(choice.Delta.GetContentString(), choice.Delta.GetReasoningContent()),
+				ToolCalls: mistralToolCalls(choice.Delta.ToolCalls),
+			},
+			FinishReason: choice.FinishReason,
+		})
+	}
+	if chunk.Usage != nil {
+		s.usage = chunk.Usage
+		response.Usage = mistralUsage(chunk.Usage)
+	}
+	return []*dto.MistralStreamResponse{response}
+}
+
+func (s *ChatToMistralStreamState) Usage() *dto.Usage {
+	if s == nil {
+		return nil
+	}
+	return s.usage
+}
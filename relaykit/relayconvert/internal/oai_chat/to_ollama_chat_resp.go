@@ -0,0 +1,215 @@
+package oaichat
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	kitutil "github.com/QuantumNous/new-api/relaykit/relayconvert/kitutil"
+	"github.com/QuantumNous/new-api/relaykit/types"
+)
+
+// ollamaCreatedAt renders an OpenAI unix "created" value the way Ollama
+// reports created_at. Missing or malformed values fall back to now.
+func ollamaCreatedAt(created any) string {
+	var seconds int64
+	switch v := created.(type) {
+	case int64:
+		seconds = v
+	case int:
+		seconds = int64(v)
+	case float64:
+		seconds = int64(v)
+	case json.Number:
+		seconds, _ = v.Int64()
+	}
+	if seconds <= 0 {
+		seconds = kitutil.GetTimestamp()
+	}
+	return time.Unix(seconds, 0).UTC().Format(time.RFC3339Nano)
+}
+
+// ollamaDoneReason maps an OpenAI finish_reason onto Ollama's done_reason,
+// which only distinguishes a natural stop from hitting num_predict.
+func ollamaDoneReason(finishReason string) string {
+	if finishReason == types.FinishReasonLength {
+		return types.FinishReasonLength
+	}
+	return types.FinishReasonStop
+}
+
+func ollamaMetricsFromUsage(usage *dto.Usage) dto.OllamaMetrics {
+	if usage == nil {
+		return dto.OllamaMetrics{}
+	}
+	return dto.OllamaMetrics{
+		PromptEvalCount: usage.PromptTokens,
+		EvalCount:       usage.CompletionTokens,
+	}
+}
+
+func ollamaThinking(reasoning string) json.RawMessage {
+	if reasoning == "" {
+		return nil
+	}
+	thinking, err := kitutil.Marshal(reasoning)
+	if err != nil {
+		return nil
+	}
+	return thinking
+}
+
+func ollamaToolCall(id string, name string, arguments string) dto.OllamaToolCall {
+	var args any
+	if arguments != "" {
+		_ = kitutil.Unmarshal([]byte(arguments), &args)
+	}
+	if args == nil {
+		args = map[string]any{}
+	}
+	call := dto.OllamaToolCall{ID: id}
+	call.Function.Name = name
+	call.Function.Arguments = args
+	return call
+}
+
+func ResponseOpenAI2Ollama(response *dto.OpenAITextResponse) *dto.OllamaChatResponse {
+	ollamaResponse := &dto.OllamaChatResponse{
+		Model:         response.Model,
+		CreatedAt:     ollamaCreatedAt(response.Created),
+		Message:       dto.OllamaChatMessage{Role: "assistant"},
+		Done:          true,
+		DoneReason:    types.FinishReasonStop,
+		OllamaMetrics: ollamaMetricsFromUsage(&response.Usage),
+	}
+	if len(response.Choices) == 0 {
+		return ollamaResponse
+	}
+	choice := response.Choices[0]
+	ollamaResponse.DoneReason = ollamaDoneReason(choice.FinishReason)
+	ollamaResponse.Message.Content = choice.Message.StringContent()
+	if choice.Message.ReasoningContent != nil {
+		ollamaResponse.Message.Thinking = ollamaThinking(*choice.Message.ReasoningContent)
+	} else if choice.Message.Reasoning != nil {
+		ollamaResponse.Message.Thinking = ollamaThinking(*choice.Message.Reasoning)
+	}
+	for _, toolCall := range choice.Message.ParseToolCalls() {
+		ollamaResponse.Message.ToolCalls = append(ollamaResponse.Message.ToolCalls, ollamaToolCall(toolCall.ID, toolCall.Function.Name, toolCall.Function.Arguments))
+	}
+	return ollamaResponse
+}
+
+// ChatToOllamaStreamState turns OpenAI chat completion chunks into Ollama
+// NDJSON chunks. Ollama sends each tool call whole, so argument fragments are
+// buffered until the stream ends; the done chunk carrying token counts is only
+// emitted by Finalize because the usage chunk arrives after finish_reason.
+type ChatToOllamaStreamState struct {
+	model        string
+	createdAt    string
+	toolCalls    map[int]*dto.ToolCallResponse
+	finishReason string
+	usage        *dto.Usage
+	finished     bool
+}
+
+func NewChatToOllamaStreamState(model string, created int64) *ChatToOllamaStreamState {
+	return &ChatToOllamaStreamState{
+		model:     model,
+		createdAt: ollamaCreatedAt(created),
+		toolCalls: make(map[int]*dto.ToolCallResponse),
+	}
+}
+
+func (s *ChatToOllamaStreamState) ConvertChunk(chunk *dto.ChatCompletionsStreamResponse) []*dto.OllamaChatResponse {
+	if s == nil || chunk == nil || s.finished {
+		return nil
+	}
+	if chunk.Model != "" {
+		s.model = chunk.Model
+	}
+	if chunk.Usage != nil {
+		s.usage = chunk.Usage
+	}
+	if len(chunk.Choices) == 0 {
+		return nil
+	}
+	choice := chunk.Choices[0]
+	if choice.FinishReason != nil && *choice.FinishReason != "" {
+		s.finishReason = *choice.FinishReason
+	}
+	for i, toolCall := range choice.Delta.ToolCalls {
+		index := i
+		if toolCall.Index != nil {
+			index = *toolCall.Index
+		}
+		pending, ok := s.toolCalls[index]
+		if !ok {
+			pending = &dto.ToolCallResponse{}
+			s.toolCalls[index] = pending
+		}
+		if toolCall.ID != "" {
+			pending.ID = toolCall.ID
+		}
+		if toolCall.Function.Name != "" {
+			pending.Function.Name = toolCall.Function.Name
+		}
+		pending.Function.Arguments += toolCall.Function.Arguments
+	}
+
+	content := choice.Delta.GetContentString()
+	reasoning := choice.Delta.GetReasoningContent()
+	if content == "" && reasoning == "" {
+		return nil
+	}
+	return []*dto.OllamaChatResponse{{
+		Model:     s.model,
+		CreatedAt: s.createdAt,
+		Message: dto.OllamaChatMessage{
+			Role:     "assistant",
+			Content:  content,
+			Thinking: ollamaThinking(reasoning),
+		},
+	}}
+}
+
+func (s *ChatToOllamaStreamState) Finalize() []*dto.OllamaChatResponse {
+	if s == nil || s.finished {
+		return nil
+	}
+	s.finished = true
+	responses := make([]*dto.OllamaChatResponse, 0, 2)
+	if len(s.toolCalls) > 0 {
+		indexes := make([]int, 0, len(s.toolCalls))
+		for index := range s.toolCalls {
+			indexes = append(indexes, index)
+		}
+		sort.Ints(indexes)
+		message := dto.OllamaChatMessage{Role: "assistant"}
+		for _, index := range indexes {
+			toolCall := s.toolCalls[index]
+			message.ToolCalls = append(message.ToolCalls, ollamaToolCall(toolCall.ID, toolCall.Function.Name, toolCall.Function.Arguments))
+		}
+		responses = append(responses, &dto.OllamaChatResponse{
+			Model:     s.model,
+			CreatedAt: s.createdAt,
+			Message:   message,
+		})
+	}
+	responses = append(responses, &dto.OllamaChatResponse{
+		Model:         s.model,
+		CreatedAt:     s.createdAt,
+		Message:       dto.OllamaChatMessage{Role: "assistant"},
+		Done:          true,
+		DoneReason:    ollamaDoneReason(s.finishReason),
+		OllamaMetrics: ollamaMetricsFromUsage(s.usage),
+	})
+	return responses
+}
+
+func (s *ChatToOllamaStreamState) Usage() *dto.Usage {
+	if s == nil {
+		return nil
+	}
+	return s.usage
+}
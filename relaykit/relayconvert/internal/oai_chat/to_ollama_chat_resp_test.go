@@ -0,0 +1,57 @@
+package oaichat
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatToOllamaStreamStateBuffersToolCallsUntilFinalize(t *testing.T) {
+	state := NewChatToOllamaStreamState("llama3", 1700000000)
+	finishReason := "tool_calls"
+
+	first := dto.ToolCallResponse{ID: "call_1", Type: "function", Function: dto.FunctionResponse{Name: "lookup", Arguments: `{"q":`}}
+	first.SetIndex(0)
+	second := dto.ToolCallResponse{Function: dto.FunctionResponse{Arguments: `"x"}`}}
+	second.SetIndex(0)
+
+	content := dto.ChatCompletionsStreamResponseChoiceDelta{}
+	content.SetContentString("checking")
+	chunks := state.ConvertChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: content}},
+	})
+	require.Len(t, chunks, 1)
+	assert.Equal(t, "checking", chunks[0].Message.Content)
+	assert.Equal(t, "2023-11-14T22:13:20Z", chunks[0].CreatedAt)
+	assert.False(t, chunks[0].Done)
+
+	assert.Empty(t, state.ConvertChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{first}}}},
+	}))
+	assert.Empty(t, state.ConvertChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{
+			Delta:        dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{second}},
+			FinishReason: &finishReason,
+		}},
+	}))
+	assert.Empty(t, state.ConvertChunk(&dto.ChatCompletionsStreamResponse{
+		Usage: &dto.Usage{PromptTokens: 9, CompletionTokens: 4, TotalTokens: 13},
+	}))
+
+	final := state.Finalize()
+	require.Len(t, final, 2)
+	require.Len(t, final[0].Message.ToolCalls, 1)
+	assert.Equal(t, "call_1", final[0].Message.ToolCalls[0].ID)
+	assert.Equal(t, "lookup", final[0].Message.ToolCalls[0].Function.Name)
+	assert.Equal(t, map[string]any{"q": "x"}, final[0].Message.ToolCalls[0].Function.Arguments)
+	assert.False(t, final[0].Done)
+
+	assert.True(t, final[1].Done)
+	assert.Equal(t, "stop", final[1].DoneReason)
+	assert.Equal(t, 9, final[1].PromptEvalCount)
+	assert.Equal(t, 4, final[1].EvalCount)
+	assert.Equal(t, 13, state.Usage().TotalTokens)
+	assert.Nil(t, state.Finalize())
+}
@@ -0,0 +1,35 @@
+package ollamachat
+
+import (
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	kitutil "github.com/QuantumNous/new-api/relaykit/relayconvert/kitutil"
+)
+
+// OllamaEmbeddingRequestToOpenAI converts an /api/embed request; Ollama's
+// input already uses the OpenAI string-or-array shape.
+func OllamaEmbeddingRequestToOpenAI(ollamaRequest *dto.OllamaEmbeddingRequest) *dto.EmbeddingRequest {
+	embeddingRequest := &dto.EmbeddingRequest{
+		Model: ollamaRequest.Model,
+		Input: ollamaRequest.Input,
+	}
+	if ollamaRequest.Dimensions > 0 {
+		embeddingRequest.Dimensions = kitutil.GetPointer(ollamaRequest.Dimensions)
+	}
+	return embeddingRequest
+}
+
+func OpenAIEmbeddingResponseToOllama(response *dto.OpenAIEmbeddingResponse) *dto.OllamaEmbeddingResponse {
+	embeddings := make([][]float64, len(response.Data))
+	for i, item := range response.Data {
+		if item.Index >= 0 && item.Index < len(embeddings) {
+			embeddings[item.Index] = item.Embedding
+		} else {
+			embeddings[i] = item.Embedding
+		}
+	}
+	return &dto.OllamaEmbeddingResponse{
+		Model:           response.Model,
+		Embeddings:      embeddings,
+		PromptEvalCount: response.PromptTokens,
+	}
+}
@@ -0,0 +1,234 @@
+package ollamachat
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/relaykit/relayconvert/internal/jsonutil"
+	kitutil "github.com/QuantumNous/new-api/relaykit/relayconvert/kitutil"
+
+	"github.com/samber/lo"
+)
+
+// OllamaChatRequestToOpenAIChat converts an /api/chat request to an OpenAI
+// chat completions request. Ollama streams unless "stream" is explicitly false.
+func OllamaChatRequestToOpenAIChat(ollamaRequest *dto.OllamaChatRequest) (*dto.GeneralOpenAIRequest, error) {
+	openaiRequest := &dto.GeneralOpenAIRequest{
+		Model:  ollamaRequest.Model,
+		Stream: kitutil.GetPointer(lo.FromPtrOr(ollamaRequest.Stream, true)),
+	}
+	if err := applyOllamaOptions(openaiRequest, ollamaRequest.Options, ollamaRequest.Format, ollamaRequest.Think); err != nil {
+		return nil, err
+	}
+
+	if ollamaRequest.Tools != nil {
+		tools, err := kitutil.Any2Type[[]dto.ToolCallRequest](ollamaRequest.Tools)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ollama tools: %w", err)
+		}
+		for i := range tools {
+			if tools[i].Type == "" {
+				tools[i].Type = "function"
+			}
+		}
+		if len(tools) > 0 {
+			openaiRequest.Tools = tools
+		}
+	}
+
+	messages := make([]dto.Message, 0, len(ollamaRequest.Messages))
+	// Ollama tool results reference the call by tool_name only, so remember the
+	// IDs handed out for each function name.
+	pendingCallIDs := make(map[string][]string)
+	callCount := 0
+	for _, m := range ollamaRequest.Messages {
+		message := dto.Message{Role: m.Role}
+		if len(m.Images) > 0 {
+			message.SetMediaContent(ollamaMediaContents(m.Content, m.Images))
+		} else {
+			message.SetStringContent(m.Content)
+		}
+		if reasoning := ollamaThinkingText(m.Thinking); reasoning != "" && m.Role == "assistant" {
+			message.ReasoningContent = &reasoning
+		}
+		if len(m.ToolCalls) > 0 {
+			toolCalls := make([]dto.ToolCallRequest, 0, len(m.ToolCalls))
+			for _, toolCall := range m.ToolCalls {
+				callCount++
+				id := toolCall.ID
+				if id == "" {
+					id = fmt.Sprintf("call_%d", callCount)
+				}
+				pendingCallIDs[toolCall.Function.Name] = append(pendingCallIDs[toolCall.Function.Name], id)
+				toolCalls = append(toolCalls, dto.ToolCallRequest{
+					ID:   id,
+					Type: "function",
+					Function: dto.FunctionRequest{
+						Name:      toolCall.Function.Name,
+						Arguments: ollamaToolArguments(toolCall.Function.Arguments),
+					},
+				})
+			}
+			message.SetToolCalls(toolCalls)
+		}
+		if m.Role == "tool" {
+			message.ToolCallId = m.ToolCallID
+			if message.ToolCallId == "" {
+				if ids := pendingCallIDs[m.ToolName]; len(ids) > 0 {
+					message.ToolCallId = ids[0]
+					pendingCallIDs[m.ToolName] = ids[1:]
+				}
+			}
+			if m.ToolName != "" {
+				message.Name = kitutil.GetPointer(m.ToolName)
+			}
+		}
+		messages = append(messages, message)
+	}
+	openaiRequest.Messages = messages
+	return openaiRequest, nil
+}
+
+// OllamaGenerateRequestToOpenAIChat converts an /api/generate request to a
+// single-turn OpenAI chat completions request; system becomes a system message.
+func OllamaGenerateRequestToOpenAIChat(ollamaRequest *dto.OllamaGenerateRequest) (*dto.GeneralOpenAIRequest, error) {
+	openaiRequest := &dto.GeneralOpenAIRequest{
+		Model:  ollamaRequest.Model,
+		Stream: kitutil.GetPointer(lo.FromPtrOr(ollamaRequest.Stream, true)),
+	}
+	if err := applyOllamaOptions(openaiRequest, ollamaRequest.Options, ollamaRequest.Format, ollamaRequest.Think); err != nil {
+		return nil, err
+	}
+
+	messages := make([]dto.Message, 0, 2)
+	if ollamaRequest.System != "" {
+		system := dto.Message{Role: "system"}
+		system.SetStringContent(ollamaRequest.System)
+		messages = append(messages, system)
+	}
+	user := dto.Message{Role: "user"}
+	if len(ollamaRequest.Images) > 0 {
+		user.SetMediaContent(ollamaMediaContents(ollamaRequest.Prompt, ollamaRequest.Images))
+	} else {
+		user.SetStringContent(ollamaRequest.Prompt)
+	}
+	openaiRequest.Messages = append(messages, user)
+	return openaiRequest, nil
+}
+
+func applyOllamaOptions(openaiRequest *dto.GeneralOpenAIRequest, options map[string]any, format any, think json.RawMessage) error {
+	if lo.FromPtr(openaiRequest.Stream) {
+		openaiRequest.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+	}
+	openaiRequest.Temperature = ollamaFloatOption(options, "temperature")
+	openaiRequest.TopP = ollamaFloatOption(options, "top_p")
+	openaiRequest.FrequencyPenalty = ollamaFloatOption(options, "frequency_penalty")
+	openaiRequest.PresencePenalty = ollamaFloatOption(options, "presence_penalty")
+	openaiRequest.Seed = ollamaFloatOption(options, "seed")
+	if topK := ollamaFloatOption(options, "top_k"); topK != nil {
+		openaiRequest.TopK = kitutil.GetPointer(int(*topK))
+	}
+	if numPredict := ollamaFloatOption(options, "num_predict"); numPredict != nil && *numPredict > 0 {
+		openaiRequest.MaxTokens = kitutil.GetPointer(uint(*numPredict))
+	}
+	if stop, ok := options["stop"]; ok && stop != nil {
+		openaiRequest.Stop = stop
+	}
+
+	switch value := format.(type) {
+	case nil:
+	case string:
+		if value == "json" {
+			openaiRequest.ResponseFormat = &dto.ResponseFormat{Type: "json_object"}
+		}
+	default:
+		schema, err := kitutil.Marshal(dto.FormatJsonSchema{Name: "response", Schema: value})
+		if err != nil {
+			return fmt.Errorf("invalid ollama format: %w", err)
+		}
+		openaiRequest.ResponseFormat = &dto.ResponseFormat{Type: "json_schema", JsonSchema: schema}
+	}
+
+	// think accepts a boolean or an effort level; only levels have an OpenAI
+	// counterpart, a bare boolean leaves the model default in place.
+	if len(think) > 0 {
+		var effort string
+		if err := kitutil.Unmarshal(think, &effort); err == nil {
+			openaiRequest.ReasoningEffort = effort
+		}
+	}
+	return nil
+}
+
+func ollamaFloatOption(options map[string]any, key string) *float64 {
+	switch value := options[key].(type) {
+	case float64:
+		return kitutil.GetPointer(value)
+	case int:
+		return kitutil.GetPointer(float64(value))
+	case json.Number:
+		if f, err := value.Float64(); err == nil {
+			return kitutil.GetPointer(f)
+		}
+	}
+	return nil
+}
+
+func ollamaMediaContents(text string, images []string) []dto.MediaContent {
+	contents := make([]dto.MediaContent, 0, len(images)+1)
+	if text != "" {
+		contents = append(contents, dto.MediaContent{Type: dto.ContentTypeText, Text: text})
+	}
+	for _, image := range images {
+		mimeType := ollamaImageMimeType(image)
+		contents = append(contents, dto.MediaContent{
+			Type: dto.ContentTypeImageURL,
+			ImageUrl: &dto.MessageImageUrl{
+				Url:      fmt.Sprintf("data:%s;base64,%s", mimeType, image),
+				Detail:   "auto",
+				MimeType: mimeType,
+			},
+		})
+	}
+	return contents
+}
+
+// ollamaImageMimeType sniffs the format of a bare base64 image, since Ollama
+// sends images without a data URL prefix.
+func ollamaImageMimeType(image string) string {
+	switch {
+	case strings.HasPrefix(image, "/9j/"):
+		return "image/jpeg"
+	case strings.HasPrefix(image, "R0lGOD"):
+		return "image/gif"
+	case strings.HasPrefix(image, "UklGR"):
+		return "image/webp"
+	default:
+		return "image/png"
+	}
+}
+
+func ollamaToolArguments(arguments any) string {
+	switch value := arguments.(type) {
+	case nil:
+		return "{}"
+	case string:
+		return value
+	default:
+		return jsonutil.ToJSONString(value)
+	}
+}
+
+func ollamaThinkingText(thinking json.RawMessage) string {
+	raw := strings.TrimSpace(string(thinking))
+	if raw == "" || raw == "null" {
+		return ""
+	}
+	var text string
+	if err := kitutil.Unmarshal(thinking, &text); err == nil {
+		return text
+	}
+	return raw
+}
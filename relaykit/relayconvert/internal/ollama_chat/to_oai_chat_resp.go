@@ -0,0 +1,193 @@
+package ollamachat
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	kitutil "github.com/QuantumNous/new-api/relaykit/relayconvert/kitutil"
+	"github.com/QuantumNous/new-api/relaykit/types"
+)
+
+func OllamaToolCallsToOpenAI(toolCalls []dto.OllamaToolCall, startIndex int, includeIndex bool) ([]dto.ToolCallResponse, int) {
+	if len(toolCalls) == 0 {
+		return nil, startIndex
+	}
+	result := make([]dto.ToolCallResponse, 0, len(toolCalls))
+	for _, tc := range toolCalls {
+		var argBytes []byte
+		var err error
+		if tc.Function.Arguments == nil {
+			argBytes = []byte("{}")
+		} else {
+			argBytes, err = kitutil.Marshal(tc.Function.Arguments)
+			if err != nil || len(argBytes) == 0 {
+				argBytes = []byte("{}")
+			}
+		}
+		toolCallID := tc.ID
+		if toolCallID == "" {
+			toolCallID = fmt.Sprintf("call_%d", startIndex)
+		}
+		tr := dto.ToolCallResponse{
+			ID:   toolCallID,
+			Type: "function",
+			Function: dto.FunctionResponse{
+				Name:      tc.Function.Name,
+				Arguments: string(argBytes),
+			},
+		}
+		if includeIndex {
+			tr.SetIndex(startIndex)
+		}
+		startIndex++
+		result = append(result, tr)
+	}
+	return result, startIndex
+}
+
+// OllamaCreatedAtUnix parses Ollama's RFC 3339 created_at, falling back to now.
+func OllamaCreatedAtUnix(ts string) int64 {
+	if ts == "" {
+		return time.Now().Unix()
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		t2, err2 := time.Parse(time.RFC3339, ts)
+		if err2 == nil {
+			return t2.Unix()
+		}
+		return time.Now().Unix()
+	}
+	return t.Unix()
+}
+
+// OllamaGenerateResponseAsChat lifts a generate response into the chat shape
+// so both endpoints share one conversion path.
+func OllamaGenerateResponseAsChat(response *dto.OllamaGenerateResponse) *dto.OllamaChatResponse {
+	chat := &dto.OllamaChatResponse{
+		Model:         response.Model,
+		CreatedAt:     response.CreatedAt,
+		Message:       dto.OllamaChatMessage{Role: "assistant", Content: response.Response},
+		Done:          response.Done,
+		DoneReason:    response.DoneReason,
+		OllamaMetrics: response.OllamaMetrics,
+	}
+	if response.Thinking != "" {
+		chat.Message.Thinking, _ = kitutil.Marshal(response.Thinking)
+	}
+	return chat
+}
+
+func UsageFromOllamaMetrics(metrics dto.OllamaMetrics) *dto.Usage {
+	return &dto.Usage{
+		PromptTokens:     metrics.PromptEvalCount,
+		CompletionTokens: metrics.EvalCount,
+		TotalTokens:      metrics.PromptEvalCount + metrics.EvalCount,
+	}
+}
+
+func ollamaFinishReason(doneReason string, toolCall bool) string {
+	if toolCall {
+		return types.FinishReasonToolCalls
+	}
+	if doneReason == types.FinishReasonLength {
+		return types.FinishReasonLength
+	}
+	return types.FinishReasonStop
+}
+
+func ResponseOllama2OpenAI(id string, response *dto.OllamaChatResponse) *dto.OpenAITextResponse {
+	toolCalls, _ := OllamaToolCallsToOpenAI(response.Message.ToolCalls, 0, false)
+	message := dto.Message{Role: "assistant"}
+	message.SetStringContent(response.Message.Content)
+	if reasoning := ollamaThinkingText(response.Message.Thinking); reasoning != "" {
+		message.ReasoningContent = &reasoning
+	}
+	if len(toolCalls) > 0 {
+		message.SetToolCalls(toolCalls)
+	}
+	return &dto.OpenAITextResponse{
+		Id:      id,
+		Model:   response.Model,
+		Object:  "chat.completion",
+		Created: OllamaCreatedAtUnix(response.CreatedAt),
+		Choices: []dto.OpenAITextResponseChoice{{
+			Index:        0,
+			Message:      message,
+			FinishReason: ollamaFinishReason(response.DoneReason, len(toolCalls) > 0),
+		}},
+		Usage: *UsageFromOllamaMetrics(response.OllamaMetrics),
+	}
+}
+
+// OllamaToChatStreamState turns Ollama NDJSON chunks into OpenAI chat
+// completion chunks; the done chunk yields the finish and usage chunks.
+type OllamaToChatStreamState struct {
+	id            string
+	created       int64
+	toolCallIndex int
+	usage         *dto.Usage
+	finished      bool
+}
+
+func NewOllamaToChatStreamState(id string, created int64) *OllamaToChatStreamState {
+	if id == "" {
+		id = fmt.Sprintf("chatcmpl-%s", kitutil.GetUUID())
+	}
+	if created == 0 {
+		created = kitutil.GetTimestamp()
+	}
+	return &OllamaToChatStreamState{id: id, created: created}
+}
+
+func (s *OllamaToChatStreamState) ConvertChunk(chunk *dto.OllamaChatResponse) []*dto.ChatCompletionsStreamResponse {
+	if s == nil || chunk == nil || s.finished {
+		return nil
+	}
+	if !chunk.Done {
+		delta := dto.ChatCompletionsStreamResponseChoiceDelta{Role: "assistant"}
+		if chunk.Message.Content != "" {
+			delta.SetContentString(chunk.Message.Content)
+		}
+		if reasoning := ollamaThinkingText(chunk.Message.Thinking); reasoning != "" {
+			delta.SetReasoningContent(reasoning)
+		}
+		delta.ToolCalls, s.toolCallIndex = OllamaToolCallsToOpenAI(chunk.Message.ToolCalls, s.toolCallIndex, true)
+		return []*dto.ChatCompletionsStreamResponse{s.chunk(chunk.Model, delta, nil)}
+	}
+
+	s.finished = true
+	s.usage = UsageFromOllamaMetrics(chunk.OllamaMetrics)
+	finishReason := ollamaFinishReason(chunk.DoneReason, s.toolCallIndex > 0)
+	responses := make([]*dto.ChatCompletionsStreamResponse, 0, 2)
+	if chunk.Message.Content != "" {
+		delta := dto.ChatCompletionsStreamResponseChoiceDelta{Role: "assistant"}
+		delta.SetContentString(chunk.Message.Content)
+		responses = append(responses, s.chunk(chunk.Model, delta, nil))
+	}
+	stop := s.chunk(chunk.Model, dto.ChatCompletionsStreamResponseChoiceDelta{}, &finishReason)
+	stop.Usage = s.usage
+	return append(responses, stop)
+}
+
+func (s *OllamaToChatStreamState) Usage() *dto.Usage {
+	if s == nil {
+		return nil
+	}
+	return s.usage
+}
+
+func (s *OllamaToChatStreamState) chunk(model string, delta dto.ChatCompletionsStreamResponseChoiceDelta, finishReason *string) *dto.ChatCompletionsStreamResponse {
+	return &dto.ChatCompletionsStreamResponse{
+		Id:      s.id,
+		Object:  "chat.completion.chunk",
+		Created: s.created,
+		Model:   model,
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{
+			Index:        0,
+			Delta:        delta,
+			FinishReason: finishReason,
+		}},
+	}
+}
@@ -5,9 +5,12 @@ import (
 	"github.com/QuantumNous/new-api/relaykit/dto"
 	"github.com/QuantumNous/new-api/relaykit/relayconvert/convmeta"
 	claudemessages "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/claude_messages"
+	coherechat "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/cohere_chat"
 	geminichat "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/gemini_chat"
+	mistralchat "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/mistral_chat"
 	oaichat "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/oai_chat"
 	oairesponses "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/oai_responses"
+	ollamachat "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/ollama_chat"
 	sharedgemini "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/shared/gemini"
 )
 
@@ -46,3 +49,27 @@ func OpenAIResponsesRequestToClaudeMessages(c context.Context, info convmeta.Met
 func OpenAIResponsesRequestToGeminiChat(c context.Context, req *dto.OpenAIResponsesRequest, info convmeta.Meta) (*dto.GeminiChatRequest, error) {
 	return oairesponses.OpenAIResponsesRequestToGeminiChat(c, req, info)
 }
+
+func OpenAIChatRequestToOllamaChat(c context.Context, textRequest *dto.GeneralOpenAIRequest) (*dto.OllamaChatRequest, error) {
+	return oaichat.OpenAIChatRequestToOllamaChat(c, textRequest)
+}
+
+func OpenAICompletionsRequestToOllamaGenerate(textRequest *dto.GeneralOpenAIRequest) (*dto.OllamaGenerateRequest, error) {
+	return oaichat.OpenAICompletionsRequestToOllamaGenerate(textRequest)
+}
+
+func OllamaEmbeddingRequestToOpenAI(req *dto.OllamaEmbeddingRequest) *dto.EmbeddingRequest {
+	return ollamachat.OllamaEmbeddingRequestToOpenAI(req)
+}
+
+func CohereEmbedRequestToOpenAI(req *dto.CohereEmbedRequest) (*dto.EmbeddingRequest, error) {
+	return coherechat.CohereEmbedRequestToOpenAI(req)
+}
+
+func CohereRerankRequestToRerank(req *dto.CohereRerankRequest) *dto.RerankRequest {
+	return coherechat.CohereRerankRequestToRerank(req)
+}
+
+func MistralEmbeddingRequestToOpenAI(req *dto.MistralEmbeddingRequest) (*dto.EmbeddingRequest, error) {
+	return mistralchat.MistralEmbeddingRequestToOpenAI(req)
+}
@@ -11,9 +11,12 @@ import (
 	"github.com/QuantumNous/new-api/relaykit/dto"
 	"github.com/QuantumNous/new-api/relaykit/relayconvert/convmeta"
 	claudemessages "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/claude_messages"
+	coherechat "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/cohere_chat"
 	geminichat "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/gemini_chat"
+	mistralchat "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/mistral_chat"
 	oaichat "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/oai_chat"
 	oairesponses "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/oai_responses"
+	ollamachat "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/ollama_chat"
 	"github.com/QuantumNous/new-api/relaykit/types"
 )
 
@@ -80,6 +83,12 @@ const (
 	ConverterOpenAIResponsesToGemini     = "openai_responses_to_gemini_generate_content"
 	ConverterGeminiContentToOpenAIChat   = "gemini_generate_content_to_openai_chat_completions"
 	ConverterOpenAIChatToGeminiContent   = "openai_chat_completions_to_gemini_generate_content"
+	ConverterOllamaChatToOpenAIChat      = "ollama_chat_to_openai_chat_completions"
+	ConverterOpenAIChatToOllamaChat      = "openai_chat_completions_to_ollama_chat"
+	ConverterCohereChatToOpenAIChat      = "cohere_chat_to_openai_chat_completions"
+	ConverterOpenAIChatToCohereChat      = "openai_chat_completions_to_cohere_chat"
+	ConverterMistralChatToOpenAIChat     = "mistral_chat_to_openai_chat_completions"
+	ConverterOpenAIChatToMistralChat     = "openai_chat_completions_to_mistral_chat"
 )
 
 func registerBuiltinRequestConverter(spec RequestConverterSpec) {
@@ -497,3 +506,77 @@ func convertResponsesRequestToChat(_ context.Context, _ convmeta.Meta, request a
 	}
 	return oairesponses.ResponsesRequestToChatCompletionsRequest(responsesRequest)
 }
+
+func convertOllamaRequestToOpenAI(_ context.Context, _ convmeta.Meta, request any) (any, error) {
+	switch ollamaRequest := request.(type) {
+	case *dto.OllamaChatRequest:
+		return ollamachat.OllamaChatRequestToOpenAIChat(ollamaRequest)
+	case dto.OllamaChatRequest:
+		return ollamachat.OllamaChatRequestToOpenAIChat(&ollamaRequest)
+	case *dto.OllamaGenerateRequest:
+		return ollamachat.OllamaGenerateRequestToOpenAIChat(ollamaRequest)
+	case dto.OllamaGenerateRequest:
+		return ollamachat.OllamaGenerateRequestToOpenAIChat(&ollamaRequest)
+	default:
+		return nil, fmt.Errorf("expected Ollama chat or generate request, got %T", request)
+	}
+}
+
+// openAIChatRequestFromAny accepts an OpenAI chat completions request by value or pointer.
+func openAIChatRequestFromAny(request any) (*dto.GeneralOpenAIRequest, error) {
+	switch openAIRequest := request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		if openAIRequest != nil {
+			return openAIRequest, nil
+		}
+	case dto.GeneralOpenAIRequest:
+		return &openAIRequest, nil
+	}
+	return nil, fmt.Errorf("expected OpenAI chat completions request, got %T", request)
+}
+
+func convertOpenAIRequestToOllama(c context.Context, _ convmeta.Meta, request any) (any, error) {
+	openAIRequest, err := openAIChatRequestFromAny(request)
+	if err != nil {
+		return nil, err
+	}
+	return oaichat.OpenAIChatRequestToOllamaChat(c, openAIRequest)
+}
+
+func convertCohereRequestToOpenAI(_ context.Context, _ convmeta.Meta, request any) (any, error) {
+	switch cohereRequest := request.(type) {
+	case *dto.CohereChatRequest:
+		return coherechat.CohereChatRequestToOpenAIChat(cohereRequest)
+	case dto.CohereChatRequest:
+		return coherechat.CohereChatRequestToOpenAIChat(&cohereRequest)
+	default:
+		return nil, fmt.Errorf("expected Cohere chat request, got %T", request)
+	}
+}
+
+func convertOpenAIRequestToCohere(_ context.Context, _ convmeta.Meta, request any) (any, error) {
+	openAIRequest, err := openAIChatRequestFromAny(request)
+	if err != nil {
+		return nil, err
+	}
+	return oaichat.OpenAIChatRequestToCohereChat(openAIRequest)
+}
+
+func convertMistralRequestToOpenAI(_ context.Context, _ convmeta.Meta, request any) (any, error) {
+	switch mistralRequest := request.(type) {
+	case *dto.MistralChatRequest:
+		return mistralchat.MistralChatRequestToOpenAIChat(mistralRequest)
+	case dto.MistralChatRequest:
+		return mistralchat.MistralChatRequestToOpenAIChat(&mistralRequest)
+	default:
+		return nil, fmt.Errorf("expected Mistral chat request, got %T", request)
+	}
+}
+
+func convertOpenAIRequestToMistral(_ context.Context, _ convmeta.Meta, request any) (any, error) {
+	openAIRequest, err := openAIChatRequestFromAny(request)
+	if err != nil {
+		return nil, err
+	}
+	return oaichat.OpenAIChatRequestToMistralChat(openAIRequest)
+}
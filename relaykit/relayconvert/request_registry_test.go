@@ -80,6 +80,42 @@ func TestRequestConverterRegistryListsSupportedTextConverters(t *testing.T) {
 			quality:        RequestConverterQualityFair,
 			advancedCustom: true,
 		},
+		{
+			converter: ConverterOllamaChatToOpenAIChat,
+			from:      types.RelayFormatOllama,
+			to:        types.RelayFormatOpenAI,
+			quality:   RequestConverterQualityFair,
+		},
+		{
+			converter: ConverterOpenAIChatToOllamaChat,
+			from:      types.RelayFormatOpenAI,
+			to:        types.RelayFormatOllama,
+			quality:   RequestConverterQualityFair,
+		},
+		{
+			converter: ConverterCohereChatToOpenAIChat,
+			from:      types.RelayFormatCohere,
+			to:        types.RelayFormatOpenAI,
+			quality:   RequestConverterQualityFair,
+		},
+		{
+			converter: ConverterOpenAIChatToCohereChat,
+			from:      types.RelayFormatOpenAI,
+			to:        types.RelayFormatCohere,
+			quality:   RequestConverterQualityFair,
+		},
+		{
+			converter: ConverterMistralChatToOpenAIChat,
+			from:      types.RelayFormatMistral,
+			to:        types.RelayFormatOpenAI,
+			quality:   RequestConverterQualityGood,
+		},
+		{
+			converter: ConverterOpenAIChatToMistralChat,
+			from:      types.RelayFormatOpenAI,
+			to:        types.RelayFormatMistral,
+			quality:   RequestConverterQualityGood,
+		},
 	}
 
 	require.Len(t, requestConverters, len(tests))
@@ -4,9 +4,11 @@ import (
 	"github.com/QuantumNous/new-api/relaykit/dto"
 	"github.com/QuantumNous/new-api/relaykit/relayconvert/convmeta"
 	claudemessages "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/claude_messages"
+	coherechat "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/cohere_chat"
 	geminichat "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/gemini_chat"
 	oaichat "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/oai_chat"
 	oairesponses "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/oai_responses"
+	ollamachat "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/ollama_chat"
 )
 
 type ClaudeResponseInfo = claudemessages.ClaudeResponseInfo
@@ -139,3 +141,23 @@ func FinalizeResponsesToChatStream(state *ResponsesToChatStreamState) []dto.Chat
 func NewResponsesBufferedAccumulator() *ResponsesBufferedAccumulator {
 	return oairesponses.NewResponsesBufferedAccumulator()
 }
+
+func OllamaToolCallsToOpenAI(toolCalls []dto.OllamaToolCall, startIndex int, includeIndex bool) ([]dto.ToolCallResponse, int) {
+	return ollamachat.OllamaToolCallsToOpenAI(toolCalls, startIndex, includeIndex)
+}
+
+func OllamaCreatedAtUnix(ts string) int64 {
+	return ollamachat.OllamaCreatedAtUnix(ts)
+}
+
+func OpenAIEmbeddingResponseToOllama(resp *dto.OpenAIEmbeddingResponse) *dto.OllamaEmbeddingResponse {
+	return ollamachat.OpenAIEmbeddingResponseToOllama(resp)
+}
+
+func OpenAIEmbeddingResponseToCohere(resp *dto.OpenAIEmbeddingResponse) *dto.CohereEmbedResponse {
+	return coherechat.OpenAIEmbeddingResponseToCohere(resp)
+}
+
+func RerankResponseToCohere(resp *dto.RerankResponse) *dto.CohereRerankResponse {
+	return coherechat.RerankResponseToCohere(resp)
+}
@@ -10,8 +10,11 @@ import (
 
 	"github.com/QuantumNous/new-api/relaykit/dto"
 	"github.com/QuantumNous/new-api/relaykit/relayconvert/convmeta"
+	coherechat "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/cohere_chat"
 	geminichat "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/gemini_chat"
+	mistralchat "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/mistral_chat"
 	oaichat "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/oai_chat"
+	ollamachat "github.com/QuantumNous/new-api/relaykit/relayconvert/internal/ollama_chat"
 	kitutil "github.com/QuantumNous/new-api/relaykit/relayconvert/kitutil"
 	"github.com/QuantumNous/new-api/relaykit/types"
 )
@@ -95,6 +98,12 @@ const (
 	ResponseConverterOAIChatToGeminiChat     = "oai_chat_to_gemini_chat_resp"
 	ResponseConverterClaudeMessagesToOAIChat = "claude_messages_to_oai_chat_resp"
 	ResponseConverterGeminiChatToOAIChat     = "gemini_chat_to_oai_chat_resp"
+	ResponseConverterOllamaChatToOAIChat     = "ollama_chat_to_oai_chat_resp"
+	ResponseConverterOAIChatToOllamaChat     = "oai_chat_to_ollama_chat_resp"
+	ResponseConverterCohereChatToOAIChat     = "cohere_chat_to_oai_chat_resp"
+	ResponseConverterOAIChatToCohereChat     = "oai_chat_to_cohere_chat_resp"
+	ResponseConverterMistralChatToOAIChat    = "mistral_chat_to_oai_chat_resp"
+	ResponseConverterOAIChatToMistralChat    = "oai_chat_to_mistral_chat_resp"
 
 	responseConverterClaudeToGemini    = "claude_messages_to_gemini_chat_resp"
 	responseConverterClaudeToResponses = "claude_messages_to_oai_responses_resp"
@@ -718,6 +727,12 @@ func inferResponseRelayFormat(response any) (types.RelayFormat, error) {
 		return types.RelayFormatClaude, nil
 	case *dto.GeminiChatResponse, dto.GeminiChatResponse:
 		return types.RelayFormatGemini, nil
+	case *dto.OllamaChatResponse, dto.OllamaChatResponse, *dto.OllamaGenerateResponse, dto.OllamaGenerateResponse:
+		return types.RelayFormatOllama, nil
+	case *dto.CohereChatResponse, dto.CohereChatResponse, *dto.CohereStreamEvent, dto.CohereStreamEvent:
+		return types.RelayFormatCohere, nil
+	case *dto.MistralChatResponse, dto.MistralChatResponse, *dto.MistralStreamResponse, dto.MistralStreamResponse:
+		return types.RelayFormatMistral, nil
 	default:
 		return "", fmt.Errorf("unsupported response type %T", response)
 	}
@@ -774,6 +789,34 @@ func canonicalUsageFromResponse(response any) *dto.Usage {
 		return UsageFromGeminiMetadata(resp.GetUsageMetadata(), 0)
 	case dto.GeminiChatResponse:
 		return UsageFromGeminiMetadata(resp.GetUsageMetadata(), 0)
+	case *dto.OllamaChatResponse, dto.OllamaChatResponse, *dto.OllamaGenerateResponse, dto.OllamaGenerateResponse:
+		ollamaResponse, err := asOllamaChatResponse(resp)
+		if err != nil || !ollamaResponse.Done {
+			return nil
+		}
+		return ollamachat.UsageFromOllamaMetrics(ollamaResponse.OllamaMetrics)
+	case *dto.CohereChatResponse:
+		return coherechat.UsageFromCohereUsage(resp.Usage)
+	case dto.CohereChatResponse:
+		return coherechat.UsageFromCohereUsage(resp.Usage)
+	case *dto.CohereStreamEvent:
+		if resp.Delta == nil {
+			return nil
+		}
+		return coherechat.UsageFromCohereUsage(resp.Delta.Usage)
+	case dto.CohereStreamEvent:
+		if resp.Delta == nil {
+			return nil
+		}
+		return coherechat.UsageFromCohereUsage(resp.Delta.Usage)
+	case *dto.MistralChatResponse:
+		return mistralchat.UsageFromMistralUsage(&resp.Usage)
+	case dto.MistralChatResponse:
+		return mistralchat.UsageFromMistralUsage(&resp.Usage)
+	case *dto.MistralStreamResponse:
+		return mistralchat.UsageFromMistralUsage(resp.Usage)
+	case dto.MistralStreamResponse:
+		return mistralchat.UsageFromMistralUsage(resp.Usage)
 	default:
 		return nil
 	}
@@ -1023,6 +1066,221 @@ func convertGeminiChatStreamResponseToOAIChat(_ context.Context, info convmeta.M
 	return openAIResponse, usage, nil
 }
 
+func convertOllamaChatResponseToOAIChat(_ context.Context, info convmeta.Meta, response any) (any, *dto.Usage, error) {
+	ollamaResponse, err := asOllamaChatResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	openAIResponse := ollamachat.ResponseOllama2OpenAI(fmt.Sprintf("chatcmpl-%s", kitutil.GetUUID()), ollamaResponse)
+	if info != nil && info.HasChannelMeta() {
+		openAIResponse.Model = info.GetUpstreamModelName()
+	}
+	return openAIResponse, UsageFromChatUsage(&openAIResponse.Usage), nil
+}
+
+func newOllamaChatToOAIChatStreamState(options ResponseStreamOptions) any {
+	return ollamachat.NewOllamaToChatStreamState(strings.TrimSpace(options.ID), options.Created)
+}
+
+func convertOllamaChatStreamResponseChunkToOAIChat(_ context.Context, info convmeta.Meta, response any, state any) ([]any, *dto.Usage, error) {
+	ollamaResponse, err := asOllamaChatResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	streamState, ok := state.(*ollamachat.OllamaToChatStreamState)
+	if !ok || streamState == nil {
+		return nil, nil, errors.New("Ollama chat to OAI chat stream state is required")
+	}
+	chunks := streamState.ConvertChunk(ollamaResponse)
+	if info != nil && info.HasChannelMeta() {
+		for _, chunk := range chunks {
+			chunk.Model = info.GetUpstreamModelName()
+		}
+	}
+	return streamValuesFromAny(chunks), streamState.Usage(), nil
+}
+
+func convertOAIChatResponseToOllamaChat(_ context.Context, _ convmeta.Meta, response any) (any, *dto.Usage, error) {
+	chatResponse, err := asOAIChatResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	return oaichat.ResponseOpenAI2Ollama(chatResponse), UsageFromChatUsage(&chatResponse.Usage), nil
+}
+
+func newOAIChatToOllamaChatStreamState(options ResponseStreamOptions) any {
+	return oaichat.NewChatToOllamaStreamState(strings.TrimSpace(options.Model), options.Created)
+}
+
+func convertOAIChatStreamResponseChunkToOllamaChat(_ context.Context, _ convmeta.Meta, response any, state any) ([]any, *dto.Usage, error) {
+	chatResponse, err := asOAIChatStreamResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	streamState, ok := state.(*oaichat.ChatToOllamaStreamState)
+	if !ok || streamState == nil {
+		return nil, nil, errors.New("OAI chat to Ollama chat stream state is required")
+	}
+	chunks := streamState.ConvertChunk(chatResponse)
+	return streamValuesFromAny(chunks), streamState.Usage(), nil
+}
+
+func finalizeOAIChatStreamResponseToOllamaChat(_ context.Context, _ convmeta.Meta, state any) ([]any, *dto.Usage, error) {
+	streamState, ok := state.(*oaichat.ChatToOllamaStreamState)
+	if !ok || streamState == nil {
+		return nil, nil, errors.New("OAI chat to Ollama chat stream state is required")
+	}
+	chunks := streamState.Finalize()
+	return streamValuesFromAny(chunks), streamState.Usage(), nil
+}
+
+func convertCohereChatResponseToOAIChat(_ context.Context, info convmeta.Meta, response any) (any, *dto.Usage, error) {
+	cohereResponse, ok := response.(*dto.CohereChatResponse)
+	if !ok {
+		if value, ok := response.(dto.CohereChatResponse); ok {
+			cohereResponse = &value
+		}
+	}
+	if cohereResponse == nil {
+		return nil, nil, fmt.Errorf("expected Cohere chat response, got %T", response)
+	}
+	openAIResponse := coherechat.ResponseCohere2OpenAI(cohereResponse)
+	if info != nil && info.HasChannelMeta() {
+		openAIResponse.Model = info.GetUpstreamModelName()
+	}
+	return openAIResponse, UsageFromChatUsage(&openAIResponse.Usage), nil
+}
+
+func newCohereChatToOAIChatStreamState(options ResponseStreamOptions) any {
+	return coherechat.NewCohereToChatStreamState(strings.TrimSpace(options.ID), options.Created)
+}
+
+func convertCohereChatStreamResponseChunkToOAIChat(_ context.Context, info convmeta.Meta, response any, state any) ([]any, *dto.Usage, error) {
+	event, ok := response.(*dto.CohereStreamEvent)
+	if !ok {
+		if value, ok := response.(dto.CohereStreamEvent); ok {
+			event = &value
+		}
+	}
+	if event == nil {
+		return nil, nil, fmt.Errorf("expected Cohere chat stream event, got %T", response)
+	}
+	streamState, ok := state.(*coherechat.CohereToChatStreamState)
+	if !ok || streamState == nil {
+		return nil, nil, errors.New("Cohere chat to OAI chat stream state is required")
+	}
+	chunks := streamState.ConvertChunk(event)
+	if info != nil && info.HasChannelMeta() {
+		for _, chunk := range chunks {
+			chunk.Model = info.GetUpstreamModelName()
+		}
+	}
+	return streamValuesFromAny(chunks), streamState.Usage(), nil
+}
+
+func convertOAIChatResponseToCohereChat(_ context.Context, _ convmeta.Meta, response any) (any, *dto.Usage, error) {
+	chatResponse, err := asOAIChatResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	return oaichat.ResponseOpenAI2Cohere(chatResponse), UsageFromChatUsage(&chatResponse.Usage), nil
+}
+
+func newOAIChatToCohereChatStreamState(options ResponseStreamOptions) any {
+	return oaichat.NewChatToCohereStreamState(strings.TrimSpace(options.ID))
+}
+
+func convertOAIChatStreamResponseChunkToCohereChat(_ context.Context, _ convmeta.Meta, response any, state any) ([]any, *dto.Usage, error) {
+	chatResponse, err := asOAIChatStreamResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	streamState, ok := state.(*oaichat.ChatToCohereStreamState)
+	if !ok || streamState == nil {
+		return nil, nil, errors.New("OAI chat to Cohere chat stream state is required")
+	}
+	chunks := streamState.ConvertChunk(chatResponse)
+	return streamValuesFromAny(chunks), streamState.Usage(), nil
+}
+
+func finalizeOAIChatStreamResponseToCohereChat(_ context.Context, _ convmeta.Meta, state any) ([]any, *dto.Usage, error) {
+	streamState, ok := state.(*oaichat.ChatToCohereStreamState)
+	if !ok || streamState == nil {
+		return nil, nil, errors.New("OAI chat to Cohere chat stream state is required")
+	}
+	chunks := streamState.Finalize()
+	return streamValuesFromAny(chunks), streamState.Usage(), nil
+}
+
+func convertMistralChatResponseToOAIChat(_ context.Context, info convmeta.Meta, response any) (any, *dto.Usage, error) {
+	mistralResponse, ok := response.(*dto.MistralChatResponse)
+	if !ok {
+		if value, ok := response.(dto.MistralChatResponse); ok {
+			mistralResponse = &value
+		}
+	}
+	if mistralResponse == nil {
+		return nil, nil, fmt.Errorf("expected Mistral chat response, got %T", response)
+	}
+	openAIResponse := mistralchat.ResponseMistral2OpenAI(mistralResponse)
+	if info != nil && info.HasChannelMeta() {
+		openAIResponse.Model = info.GetUpstreamModelName()
+	}
+	return openAIResponse, UsageFromChatUsage(&openAIResponse.Usage), nil
+}
+
+func newMistralChatToOAIChatStreamState(options ResponseStreamOptions) any {
+	return mistralchat.NewMistralToChatStreamState(strings.TrimSpace(options.ID), options.Created)
+}
+
+func convertMistralChatStreamResponseChunkToOAIChat(_ context.Context, info convmeta.Meta, response any, state any) ([]any, *dto.Usage, error) {
+	mistralResponse, ok := response.(*dto.MistralStreamResponse)
+	if !ok {
+		if value, ok := response.(dto.MistralStreamResponse); ok {
+			mistralResponse = &value
+		}
+	}
+	if mistralResponse == nil {
+		return nil, nil, fmt.Errorf("expected Mistral chat stream response, got %T", response)
+	}
+	streamState, ok := state.(*mistralchat.MistralToChatStreamState)
+	if !ok || streamState == nil {
+		return nil, nil, errors.New("Mistral chat to OAI chat stream state is required")
+	}
+	chunks := streamState.ConvertChunk(mistralResponse)
+	if info != nil && info.HasChannelMeta() {
+		for _, chunk := range chunks {
+			chunk.Model = info.GetUpstreamModelName()
+		}
+	}
+	return streamValuesFromAny(chunks), streamState.Usage(), nil
+}
+
+func convertOAIChatResponseToMistralChat(_ context.Context, _ convmeta.Meta, response any) (any, *dto.Usage, error) {
+	chatResponse, err := asOAIChatResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	return oaichat.ResponseOpenAI2Mistral(chatResponse), UsageFromChatUsage(&chatResponse.Usage), nil
+}
+
+func newOAIChatToMistralChatStreamState(_ ResponseStreamOptions) any {
+	return oaichat.NewChatToMistralStreamState()
+}
+
+func convertOAIChatStreamResponseChunkToMistralChat(_ context.Context, _ convmeta.Meta, response any, state any) ([]any, *dto.Usage, error) {
+	chatResponse, err := asOAIChatStreamResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+	streamState, ok := state.(*oaichat.ChatToMistralStreamState)
+	if !ok || streamState == nil {
+		return nil, nil, errors.New("OAI chat to Mistral chat stream state is required")
+	}
+	chunks := streamState.ConvertChunk(chatResponse)
+	return streamValuesFromAny(chunks), streamState.Usage(), nil
+}
+
 func fallbackPromptTokens(info convmeta.Meta) int {
 	if info == nil {
 		return 0
@@ -1095,3 +1353,20 @@ func asGeminiChatResponse(response any) (*dto.GeminiChatResponse, error) {
 		return nil, fmt.Errorf("expected Gemini chat response, got %T", response)
 	}
 }
+
+// asOllamaChatResponse accepts both chat and generate responses; generate is
+// lifted into the chat shape.
+func asOllamaChatResponse(response any) (*dto.OllamaChatResponse, error) {
+	switch resp := response.(type) {
+	case *dto.OllamaChatResponse:
+		return resp, nil
+	case dto.OllamaChatResponse:
+		return &resp, nil
+	case *dto.OllamaGenerateResponse:
+		return ollamachat.OllamaGenerateResponseAsChat(resp), nil
+	case dto.OllamaGenerateResponse:
+		return ollamachat.OllamaGenerateResponseAsChat(&resp), nil
+	default:
+		return nil, fmt.Errorf("expected Ollama chat response, got %T", response)
+	}
+}
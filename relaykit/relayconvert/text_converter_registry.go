@@ -139,6 +139,98 @@ var builtinTextConverters = []TextConverterSpec{
 			Aliases:            []string{ResponseConverterOAIResponsesToOAIChat},
 		},
 	},
+	{
+		ID:      ConverterOllamaChatToOpenAIChat,
+		From:    types.RelayFormatOllama,
+		To:      types.RelayFormatOpenAI,
+		Quality: TextConverterQualityFair,
+		Req: TextRequestSide{
+			Convert: convertOllamaRequestToOpenAI,
+		},
+		Resp: TextResponseSide{
+			Convert:            convertOllamaChatResponseToOAIChat,
+			NewStreamState:     newOllamaChatToOAIChatStreamState,
+			ConvertStreamChunk: convertOllamaChatStreamResponseChunkToOAIChat,
+			Aliases:            []string{ResponseConverterOllamaChatToOAIChat},
+		},
+	},
+	{
+		ID:      ConverterOpenAIChatToOllamaChat,
+		From:    types.RelayFormatOpenAI,
+		To:      types.RelayFormatOllama,
+		Quality: TextConverterQualityFair,
+		Req: TextRequestSide{
+			Convert: convertOpenAIRequestToOllama,
+		},
+		Resp: TextResponseSide{
+			Convert:            convertOAIChatResponseToOllamaChat,
+			NewStreamState:     newOAIChatToOllamaChatStreamState,
+			ConvertStreamChunk: convertOAIChatStreamResponseChunkToOllamaChat,
+			FinalizeStream:     finalizeOAIChatStreamResponseToOllamaChat,
+			Aliases:            []string{ResponseConverterOAIChatToOllamaChat},
+		},
+	},
+	{
+		ID:      ConverterCohereChatToOpenAIChat,
+		From:    types.RelayFormatCohere,
+		To:      types.RelayFormatOpenAI,
+		Quality: TextConverterQualityFair,
+		Req: TextRequestSide{
+			Convert: convertCohereRequestToOpenAI,
+		},
+		Resp: TextResponseSide{
+			Convert:            convertCohereChatResponseToOAIChat,
+			NewStreamState:     newCohereChatToOAIChatStreamState,
+			ConvertStreamChunk: convertCohereChatStreamResponseChunkToOAIChat,
+			Aliases:            []string{ResponseConverterCohereChatToOAIChat},
+		},
+	},
+	{
+		ID:      ConverterOpenAIChatToCohereChat,
+		From:    types.RelayFormatOpenAI,
+		To:      types.RelayFormatCohere,
+		Quality: TextConverterQualityFair,
+		Req: TextRequestSide{
+			Convert: convertOpenAIRequestToCohere,
+		},
+		Resp: TextResponseSide{
+			Convert:            convertOAIChatResponseToCohereChat,
+			NewStreamState:     newOAIChatToCohereChatStreamState,
+			ConvertStreamChunk: convertOAIChatStreamResponseChunkToCohereChat,
+			FinalizeStream:     finalizeOAIChatStreamResponseToCohereChat,
+			Aliases:            []string{ResponseConverterOAIChatToCohereChat},
+		},
+	},
+	{
+		ID:      ConverterMistralChatToOpenAIChat,
+		From:    types.RelayFormatMistral,
+		To:      types.RelayFormatOpenAI,
+		Quality: TextConverterQualityGood,
+		Req: TextRequestSide{
+			Convert: convertMistralRequestToOpenAI,
+		},
+		Resp: TextResponseSide{
+			Convert:            convertMistralChatResponseToOAIChat,
+			NewStreamState:     newMistralChatToOAIChatStreamState,
+			ConvertStreamChunk: convertMistralChatStreamResponseChunkToOAIChat,
+			Aliases:            []string{ResponseConverterMistralChatToOAIChat},
+		},
+	},
+	{
+		ID:      ConverterOpenAIChatToMistralChat,
+		From:    types.RelayFormatOpenAI,
+		To:      types.RelayFormatMistral,
+		Quality: TextConverterQualityGood,
+		Req: TextRequestSide{
+			Convert: convertOpenAIRequestToMistral,
+		},
+		Resp: TextResponseSide{
+			Convert:            convertOAIChatResponseToMistralChat,
+			NewStreamState:     newOAIChatToMistralChatStreamState,
+			ConvertStreamChunk: convertOAIChatStreamResponseChunkToMistralChat,
+			Aliases:            []string{ResponseConverterOAIChatToMistralChat},
+		},
+	},
 	{
 		ID:      requestConverterClaudeToGemini,
 		From:    types.RelayFormatClaude,
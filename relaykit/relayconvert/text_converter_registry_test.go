@@ -111,6 +111,62 @@ func TestLookupBuiltinTextConverters(t *testing.T) {
 			},
 			respAlias: responseConverterResponsesToGemini,
 		},
+		{
+			id:         ConverterOllamaChatToOpenAIChat,
+			from:       types.RelayFormatOllama,
+			to:         types.RelayFormatOpenAI,
+			quality:    TextConverterQualityFair,
+			reqDirect:  true,
+			respDirect: true,
+			respAlias:  ResponseConverterOllamaChatToOAIChat,
+		},
+		{
+			id:           ConverterOpenAIChatToOllamaChat,
+			from:         types.RelayFormatOpenAI,
+			to:           types.RelayFormatOllama,
+			quality:      TextConverterQualityFair,
+			reqDirect:    true,
+			respDirect:   true,
+			streamDirect: true,
+			respAlias:    ResponseConverterOAIChatToOllamaChat,
+		},
+		{
+			id:         ConverterCohereChatToOpenAIChat,
+			from:       types.RelayFormatCohere,
+			to:         types.RelayFormatOpenAI,
+			quality:    TextConverterQualityFair,
+			reqDirect:  true,
+			respDirect: true,
+			respAlias:  ResponseConverterCohereChatToOAIChat,
+		},
+		{
+			id:           ConverterOpenAIChatToCohereChat,
+			from:         types.RelayFormatOpenAI,
+			to:           types.RelayFormatCohere,
+			quality:      TextConverterQualityFair,
+			reqDirect:    true,
+			respDirect:   true,
+			streamDirect: true,
+			respAlias:    ResponseConverterOAIChatToCohereChat,
+		},
+		{
+			id:         ConverterMistralChatToOpenAIChat,
+			from:       types.RelayFormatMistral,
+			to:         types.RelayFormatOpenAI,
+			quality:    TextConverterQualityGood,
+			reqDirect:  true,
+			respDirect: true,
+			respAlias:  ResponseConverterMistralChatToOAIChat,
+		},
+		{
+			id:         ConverterOpenAIChatToMistralChat,
+			from:       types.RelayFormatOpenAI,
+			to:         types.RelayFormatMistral,
+			quality:    TextConverterQualityGood,
+			reqDirect:  true,
+			respDirect: true,
+			respAlias:  ResponseConverterOAIChatToMistralChat,
+		},
 	}
 
 	require.Len(t, textConverters, len(tests))
@@ -14,6 +14,9 @@ const (
 	RelayFormatOpenAIRealtime                        = "openai_realtime"
 	RelayFormatRerank                                = "rerank"
 	RelayFormatEmbedding                             = "embedding"
+	RelayFormatOllama                                = "ollama"
+	RelayFormatCohere                                = "cohere"
+	RelayFormatMistral                               = "mistral"
 
 	RelayFormatTask    = "task"
 	RelayFormatMjProxy = "mj_proxy"
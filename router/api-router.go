@@ -3,6 +3,7 @@ package router
 import (
 	"github.com/QuantumNous/new-api/controller"
 	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/service/authz"
 
 	// Import oauth package to register providers via init()
 	_ "github.com/QuantumNous/new-api/oauth"
@@ -24,7 +25,7 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/status", controller.GetStatus)
 		apiRouter.GET("/uptime/status", controller.GetUptimeKumaStatus)
 		apiRouter.GET("/models", middleware.UserAuth(), controller.DashboardListModels)
-		apiRouter.GET("/status/test", middleware.AdminAuth(), controller.TestStatus)
+		apiRouter.GET("/status/test", middleware.AdminAuth(), middleware.RequirePermission(authz.SystemRead), controller.TestStatus)
 		apiRouter.GET("/notice", controller.GetNotice)
 		apiRouter.GET("/user-agreement", controller.GetUserAgreement)
 		apiRouter.GET("/privacy-policy", controller.GetPrivacyPolicy)
@@ -51,6 +52,10 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/oauth/telegram/login", middleware.CriticalRateLimit(), middleware.DisableCache(), controller.TelegramLogin)
 		apiRouter.POST("/oauth/telegram/bind/start", middleware.UserAuth(), middleware.CriticalRateLimit(), middleware.DisableCache(), controller.TelegramBindStart)
 		apiRouter.GET("/oauth/telegram/bind/:flow_token", middleware.CriticalRateLimit(), middleware.DisableCache(), controller.TelegramBind)
+		// SAML SSO: the ACS hands off to the unified OAuth callback as provider "saml"
+		apiRouter.GET("/saml/metadata", middleware.CriticalRateLimit(), controller.SAMLMetadata)
+		apiRouter.GET("/saml/login", middleware.CriticalRateLimit(), middleware.DisableCache(), controller.SAMLLogin)
+		apiRouter.POST("/saml/acs", middleware.CriticalRateLimit(), middleware.DisableCache(), anonymousRequestBodyLimit, controller.SAMLACS)
 		// Standard OAuth providers (GitHub, Discord, OIDC, LinuxDO) - unified route
 		apiRouter.GET("/oauth/:provider", middleware.CriticalRateLimit(), middleware.DisableCache(), middleware.TryUserAuth(), controller.HandleOAuth)
 		apiRouter.GET("/ratio_config", middleware.CriticalRateLimit(), controller.GetRatioConfig)
@@ -61,6 +66,10 @@ func SetApiRouter(router *gin.Engine) {
 		// :env separates test vs prod URLs so the operator can register each
 		// in Pancake's matching webhook slot; handler enforces env match.
 		apiRouter.POST("/waffo-pancake/webhook/:env", anonymousRequestBodyLimit, controller.WaffoPancakeWebhook)
+		// Upstream async task callbacks; the URL carries an HMAC over channel and task id.
+		apiRouter.POST("/task/callback/:channel_id/:task_id/:sig", anonymousRequestBodyLimit, controller.TaskUpstreamCallback)
+		// Archived media; the URL carries an HMAC over asset id and expiry.
+		apiRouter.GET("/media/file/:id", middleware.CriticalRateLimit(), controller.ServeMediaFile)
 
 		// Universal secure verification routes
 		apiRouter.POST("/verify", middleware.UserAuth(), middleware.CriticalRateLimit(), middleware.DisableCache(), controller.UniversalVerify)
@@ -100,17 +109,21 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.GET("/aff", controller.GetAffCode)
 				selfRoute.GET("/topup/info", controller.GetTopUpInfo)
 				selfRoute.GET("/topup/self", controller.GetUserTopUps)
-				selfRoute.POST("/topup", middleware.CriticalRateLimit(), controller.TopUp)
-				selfRoute.POST("/pay", middleware.CriticalRateLimit(), controller.RequestEpay)
+				selfRoute.POST("/topup", middleware.CriticalRateLimit(), middleware.DenyResellerUsers(), controller.TopUp)
+				selfRoute.POST("/pay", middleware.CriticalRateLimit(), middleware.DenyResellerUsers(), controller.RequestEpay)
 				selfRoute.POST("/amount", controller.RequestAmount)
-				selfRoute.POST("/stripe/pay", middleware.CriticalRateLimit(), controller.RequestStripePay)
+				selfRoute.POST("/stripe/pay", middleware.CriticalRateLimit(), middleware.DenyResellerUsers(), controller.RequestStripePay)
 				selfRoute.POST("/stripe/amount", controller.RequestStripeAmount)
-				selfRoute.POST("/creem/pay", middleware.CriticalRateLimit(), controller.RequestCreemPay)
+				selfRoute.POST("/creem/pay", middleware.CriticalRateLimit(), middleware.DenyResellerUsers(), controller.RequestCreemPay)
 				selfRoute.POST("/waffo/amount", controller.RequestWaffoAmount)
-				selfRoute.POST("/waffo/pay", middleware.CriticalRateLimit(), controller.RequestWaffoPay)
+				selfRoute.POST("/waffo/pay", middleware.CriticalRateLimit(), middleware.DenyResellerUsers(), controller.RequestWaffoPay)
 				selfRoute.POST("/waffo-pancake/amount", controller.RequestWaffoPancakeAmount)
-				selfRoute.POST("/waffo-pancake/pay", middleware.CriticalRateLimit(), controller.RequestWaffoPancakePay)
+				selfRoute.POST("/waffo-pancake/pay", middleware.CriticalRateLimit(), middleware.DenyResellerUsers(), controller.RequestWaffoPancakePay)
 				selfRoute.POST("/aff_transfer", middleware.UserCriticalRateLimit("aff-transfer"), controller.TransferAffQuota)
+				selfRoute.GET("/credit_grants", controller.GetSelfCreditGrants)
+				selfRoute.GET("/statements", controller.GetSelfStatements)
+				selfRoute.GET("/statements/:id/download", controller.DownloadSelfStatement)
+				selfRoute.GET("/postpaid", controller.GetSelfPostpaidAccount)
 				selfRoute.PUT("/setting", controller.UpdateUserSetting)
 
 				// 2FA routes
@@ -132,23 +145,23 @@ func SetApiRouter(router *gin.Engine) {
 			adminRoute := userRoute.Group("/")
 			adminRoute.Use(middleware.AdminAuth())
 			{
-				adminRoute.GET("/", controller.GetAllUsers)
-				adminRoute.GET("/topup", controller.GetAllTopUps)
-				adminRoute.POST("/topup/complete", controller.AdminCompleteTopUp)
-				adminRoute.GET("/search", controller.SearchUsers)
-				adminRoute.GET("/:id/oauth/bindings", controller.GetUserOAuthBindingsByAdmin)
-				adminRoute.DELETE("/:id/oauth/bindings/:provider_id", controller.UnbindCustomOAuthByAdmin)
-				adminRoute.DELETE("/:id/bindings/:binding_type", controller.AdminClearUserBinding)
-				adminRoute.GET("/:id", controller.GetUser)
-				adminRoute.POST("/", controller.CreateUser)
-				adminRoute.POST("/manage", controller.ManageUser)
-				adminRoute.PUT("/", controller.UpdateUser)
-				adminRoute.DELETE("/:id", controller.DeleteUser)
-				adminRoute.DELETE("/:id/reset_passkey", controller.AdminResetPasskey)
+				adminRoute.GET("/", middleware.RequirePermission(authz.UserRead), controller.GetAllUsers)
+				adminRoute.GET("/topup", middleware.RequirePermission(authz.PaymentRead), controller.GetAllTopUps)
+				adminRoute.POST("/topup/complete", middleware.RequirePermission(authz.PaymentOperate), controller.AdminCompleteTopUp)
+				adminRoute.GET("/search", middleware.RequirePermission(authz.UserRead), controller.SearchUsers)
+				adminRoute.GET("/:id/oauth/bindings", middleware.RequirePermission(authz.UserRead), controller.GetUserOAuthBindingsByAdmin)
+				adminRoute.DELETE("/:id/oauth/bindings/:provider_id", middleware.RequirePermission(authz.UserSensitiveWrite), controller.UnbindCustomOAuthByAdmin)
+				adminRoute.DELETE("/:id/bindings/:binding_type", middleware.RequirePermission(authz.UserSensitiveWrite), controller.AdminClearUserBinding)
+				adminRoute.GET("/:id", middleware.RequirePermission(authz.UserRead), controller.GetUser)
+				adminRoute.POST("/", middleware.RequirePermission(authz.UserWrite), controller.CreateUser)
+				adminRoute.POST("/manage", middleware.RequirePermission(authz.UserWrite), controller.ManageUser)
+				adminRoute.PUT("/", middleware.RequirePermission(authz.UserWrite), controller.UpdateUser)
+				adminRoute.DELETE("/:id", middleware.RequirePermission(authz.UserWrite), controller.DeleteUser)
+				adminRoute.DELETE("/:id/reset_passkey", middleware.RequirePermission(authz.UserSensitiveWrite), controller.AdminResetPasskey)
 
 				// Admin 2FA routes
-				adminRoute.GET("/2fa/stats", controller.Admin2FAStats)
-				adminRoute.DELETE("/:id/2fa", controller.AdminDisable2FA)
+				adminRoute.GET("/2fa/stats", middleware.RequirePermission(authz.UserRead), controller.Admin2FAStats)
+				adminRoute.DELETE("/:id/2fa", middleware.RequirePermission(authz.UserSensitiveWrite), controller.AdminDisable2FA)
 			}
 		}
 
@@ -159,28 +172,80 @@ func SetApiRouter(router *gin.Engine) {
 			subscriptionRoute.GET("/plans", controller.GetSubscriptionPlans)
 			subscriptionRoute.GET("/self", controller.GetSubscriptionSelf)
 			subscriptionRoute.PUT("/self/preference", controller.UpdateSubscriptionPreference)
-			subscriptionRoute.POST("/balance/pay", middleware.CriticalRateLimit(), controller.SubscriptionRequestBalancePay)
-			subscriptionRoute.POST("/epay/pay", middleware.CriticalRateLimit(), controller.SubscriptionRequestEpay)
-			subscriptionRoute.POST("/stripe/pay", middleware.CriticalRateLimit(), controller.SubscriptionRequestStripePay)
-			subscriptionRoute.POST("/creem/pay", middleware.CriticalRateLimit(), controller.SubscriptionRequestCreemPay)
-			subscriptionRoute.POST("/waffo-pancake/pay", middleware.CriticalRateLimit(), controller.SubscriptionRequestWaffoPancakePay)
+			subscriptionRoute.POST("/balance/pay", middleware.CriticalRateLimit(), middleware.DenyResellerUsers(), controller.SubscriptionRequestBalancePay)
+			subscriptionRoute.POST("/epay/pay", middleware.CriticalRateLimit(), middleware.DenyResellerUsers(), controller.SubscriptionRequestEpay)
+			subscriptionRoute.POST("/stripe/pay", middleware.CriticalRateLimit(), middleware.DenyResellerUsers(), controller.SubscriptionRequestStripePay)
+			subscriptionRoute.POST("/creem/pay", middleware.CriticalRateLimit(), middleware.DenyResellerUsers(), controller.SubscriptionRequestCreemPay)
+			subscriptionRoute.POST("/waffo-pancake/pay", middleware.CriticalRateLimit(), middleware.DenyResellerUsers(), controller.SubscriptionRequestWaffoPancakePay)
 		}
+		organizationRoute := apiRouter.Group("/organization")
+		organizationRoute.Use(middleware.UserAuth())
+		{
+			organizationRoute.GET("/self", controller.GetSelfOrganizations)
+			organizationRoute.GET("/invites", controller.GetSelfOrganizationInvites)
+			organizationRoute.POST("/invites/:invite_id/accept", controller.AcceptOrganizationInvite)
+			organizationRoute.POST("/invites/:invite_id/decline", controller.DeclineOrganizationInvite)
+			organizationRoute.POST("/", controller.CreateOrganization)
+			organizationRoute.GET("/:id", controller.GetOrganization)
+			organizationRoute.PUT("/:id", controller.UpdateOrganization)
+			organizationRoute.DELETE("/:id", controller.DeleteOrganization)
+			organizationRoute.GET("/:id/members", controller.GetOrganizationMembers)
+			organizationRoute.POST("/:id/invites", controller.InviteOrganizationMember)
+			organizationRoute.PUT("/:id/members/:user_id", controller.UpdateOrganizationMember)
+			organizationRoute.DELETE("/:id/members/:user_id", controller.RemoveOrganizationMember)
+			organizationRoute.POST("/:id/fund", middleware.CriticalRateLimit(), controller.FundOrganization)
+			organizationRoute.GET("/:id/logs", controller.GetOrganizationLogs)
+		}
+		resellerRoute := apiRouter.Group("/reseller")
+		resellerRoute.Use(middleware.UserAuth())
+		{
+			resellerRoute.GET("/self", controller.GetSelfReseller)
+			resellerRoute.GET("/users", controller.GetResellerUsers)
+			resellerRoute.POST("/users", middleware.CriticalRateLimit(), controller.CreateResellerUser)
+			resellerRoute.PUT("/users/:id", controller.UpdateResellerUser)
+			resellerRoute.POST("/users/:id/quota", middleware.CriticalRateLimit(), controller.AdjustResellerUserQuota)
+			resellerRoute.POST("/users/:id/reseller", controller.CreateSubReseller)
+			resellerRoute.PUT("/users/:id/reseller", controller.UpdateSubReseller)
+			resellerRoute.GET("/report", controller.GetResellerReport)
+			resellerRoute.GET("/logs", controller.GetResellerLogs)
+		}
+		resellerAdminRoute := apiRouter.Group("/reseller/admin")
+		resellerAdminRoute.Use(middleware.AdminAuth())
+		{
+			resellerAdminRoute.GET("/list", middleware.RequirePermission(authz.ResellerRead), controller.AdminListResellers)
+			resellerAdminRoute.POST("/", middleware.RequirePermission(authz.ResellerWrite), controller.AdminCreateReseller)
+			resellerAdminRoute.PUT("/:id", middleware.RequirePermission(authz.ResellerWrite), controller.AdminUpdateReseller)
+			resellerAdminRoute.DELETE("/:id", middleware.RequirePermission(authz.ResellerWrite), controller.AdminDeleteReseller)
+			resellerAdminRoute.GET("/:id/users", middleware.RequirePermission(authz.ResellerRead), controller.AdminGetResellerUsers)
+			resellerAdminRoute.GET("/:id/report", middleware.RequirePermission(authz.ResellerRead), controller.AdminGetResellerReport)
+		}
+
+		organizationAdminRoute := apiRouter.Group("/organization/admin")
+		organizationAdminRoute.Use(middleware.AdminAuth())
+		{
+			organizationAdminRoute.GET("/list", middleware.RequirePermission(authz.OrganizationRead), controller.AdminListOrganizations)
+			organizationAdminRoute.GET("/:id/members", middleware.RequirePermission(authz.OrganizationRead), controller.AdminGetOrganizationMembers)
+			organizationAdminRoute.PUT("/:id", middleware.RequirePermission(authz.OrganizationWrite), controller.AdminUpdateOrganization)
+			organizationAdminRoute.POST("/:id/quota", middleware.RequirePermission(authz.OrganizationWrite), controller.AdminAdjustOrganizationQuota)
+			organizationAdminRoute.DELETE("/:id", middleware.RequirePermission(authz.OrganizationWrite), controller.AdminDeleteOrganization)
+		}
+
 		subscriptionAdminRoute := apiRouter.Group("/subscription/admin")
 		subscriptionAdminRoute.Use(middleware.AdminAuth())
 		{
-			subscriptionAdminRoute.GET("/plans", controller.AdminListSubscriptionPlans)
-			subscriptionAdminRoute.POST("/plans", controller.AdminCreateSubscriptionPlan)
-			subscriptionAdminRoute.PUT("/plans/:id", controller.AdminUpdateSubscriptionPlan)
-			subscriptionAdminRoute.PATCH("/plans/:id", controller.AdminUpdateSubscriptionPlanStatus)
-			subscriptionAdminRoute.POST("/bind", controller.AdminBindSubscription)
-			subscriptionAdminRoute.POST("/plans/:id/subscriptions/reset", controller.AdminResetPlanSubscriptions)
+			subscriptionAdminRoute.GET("/plans", middleware.RequirePermission(authz.SubscriptionRead), controller.AdminListSubscriptionPlans)
+			subscriptionAdminRoute.POST("/plans", middleware.RequirePermission(authz.SubscriptionWrite), controller.AdminCreateSubscriptionPlan)
+			subscriptionAdminRoute.PUT("/plans/:id", middleware.RequirePermission(authz.SubscriptionWrite), controller.AdminUpdateSubscriptionPlan)
+			subscriptionAdminRoute.PATCH("/plans/:id", middleware.RequirePermission(authz.SubscriptionWrite), controller.AdminUpdateSubscriptionPlanStatus)
+			subscriptionAdminRoute.POST("/bind", middleware.RequirePermission(authz.SubscriptionWrite), controller.AdminBindSubscription)
+			subscriptionAdminRoute.POST("/plans/:id/subscriptions/reset", middleware.RequirePermission(authz.SubscriptionWrite), controller.AdminResetPlanSubscriptions)
 
 			// User subscription management (admin)
-			subscriptionAdminRoute.GET("/users/:id/subscriptions", controller.AdminListUserSubscriptions)
-			subscriptionAdminRoute.POST("/users/:id/subscriptions", controller.AdminCreateUserSubscription)
-			subscriptionAdminRoute.POST("/users/:id/subscriptions/reset", controller.AdminResetUserSubscriptionsByPlan)
-			subscriptionAdminRoute.POST("/user_subscriptions/:id/invalidate", controller.AdminInvalidateUserSubscription)
-			subscriptionAdminRoute.DELETE("/user_subscriptions/:id", controller.AdminDeleteUserSubscription)
+			subscriptionAdminRoute.GET("/users/:id/subscriptions", middleware.RequirePermission(authz.SubscriptionRead), controller.AdminListUserSubscriptions)
+			subscriptionAdminRoute.POST("/users/:id/subscriptions", middleware.RequirePermission(authz.SubscriptionWrite), controller.AdminCreateUserSubscription)
+			subscriptionAdminRoute.POST("/users/:id/subscriptions/reset", middleware.RequirePermission(authz.SubscriptionWrite), controller.AdminResetUserSubscriptionsByPlan)
+			subscriptionAdminRoute.POST("/user_subscriptions/:id/invalidate", middleware.RequirePermission(authz.SubscriptionWrite), controller.AdminInvalidateUserSubscription)
+			subscriptionAdminRoute.DELETE("/user_subscriptions/:id", middleware.RequirePermission(authz.SubscriptionWrite), controller.AdminDeleteUserSubscription)
 		}
 
 		// Subscription payment callbacks (no auth)
@@ -189,47 +254,47 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/subscription/epay/return", controller.SubscriptionEpayReturn)
 		apiRouter.POST("/subscription/epay/return", anonymousRequestBodyLimit, controller.SubscriptionEpayReturn)
 		optionRoute := apiRouter.Group("/option")
-		optionRoute.Use(middleware.RootAuth())
+		optionRoute.Use(middleware.AdminAuth())
 		{
-			optionRoute.GET("/", controller.GetOptions)
-			optionRoute.PUT("/", controller.UpdateOption)
-			optionRoute.POST("/payment_compliance", controller.ConfirmPaymentCompliance)
-			optionRoute.GET("/channel_affinity_cache", controller.GetChannelAffinityCacheStats)
-			optionRoute.DELETE("/channel_affinity_cache", controller.ClearChannelAffinityCache)
-			optionRoute.POST("/rest_model_ratio", controller.ResetModelRatio)
-			optionRoute.GET("/waffo-pancake/catalog", controller.ListWaffoPancakeCatalog)
-			optionRoute.POST("/waffo-pancake/pair", controller.CreateWaffoPancakePair)
-			optionRoute.POST("/waffo-pancake/save", controller.SaveWaffoPancake)
-			optionRoute.POST("/waffo-pancake/subscription-product", controller.CreateWaffoPancakeSubscriptionProduct)
-			optionRoute.GET("/waffo-pancake/subscription-product-options", controller.ListWaffoPancakeSubscriptionProductOptions)
+			optionRoute.GET("/", middleware.RequirePermission(authz.OptionRead), controller.GetOptions)
+			optionRoute.PUT("/", middleware.RequirePermission(authz.OptionWrite), controller.UpdateOption)
+			optionRoute.POST("/payment_compliance", middleware.RequirePermission(authz.PaymentWrite), controller.ConfirmPaymentCompliance)
+			optionRoute.GET("/channel_affinity_cache", middleware.RequirePermission(authz.OptionRead), controller.GetChannelAffinityCacheStats)
+			optionRoute.DELETE("/channel_affinity_cache", middleware.RequirePermission(authz.OptionWrite), controller.ClearChannelAffinityCache)
+			optionRoute.POST("/rest_model_ratio", middleware.RequirePermission(authz.ModelSensitiveWrite), controller.ResetModelRatio)
+			optionRoute.GET("/waffo-pancake/catalog", middleware.RequirePermission(authz.PaymentWrite), controller.ListWaffoPancakeCatalog)
+			optionRoute.POST("/waffo-pancake/pair", middleware.RequirePermission(authz.PaymentWrite), controller.CreateWaffoPancakePair)
+			optionRoute.POST("/waffo-pancake/save", middleware.RequirePermission(authz.PaymentWrite), controller.SaveWaffoPancake)
+			optionRoute.POST("/waffo-pancake/subscription-product", middleware.RequirePermission(authz.PaymentWrite), controller.CreateWaffoPancakeSubscriptionProduct)
+			optionRoute.GET("/waffo-pancake/subscription-product-options", middleware.RequirePermission(authz.PaymentWrite), controller.ListWaffoPancakeSubscriptionProductOptions)
 		}
 
-		// Custom OAuth provider management (root only)
+		// Custom OAuth provider management
 		customOAuthRoute := apiRouter.Group("/custom-oauth-provider")
-		customOAuthRoute.Use(middleware.RootAuth())
+		customOAuthRoute.Use(middleware.AdminAuth())
 		{
-			customOAuthRoute.POST("/discovery", controller.FetchCustomOAuthDiscovery)
-			customOAuthRoute.GET("/", controller.GetCustomOAuthProviders)
-			customOAuthRoute.GET("/:id", controller.GetCustomOAuthProvider)
-			customOAuthRoute.POST("/", controller.CreateCustomOAuthProvider)
-			customOAuthRoute.PUT("/:id", controller.UpdateCustomOAuthProvider)
-			customOAuthRoute.DELETE("/:id", controller.DeleteCustomOAuthProvider)
+			customOAuthRoute.POST("/discovery", middleware.RequirePermission(authz.OptionWrite), controller.FetchCustomOAuthDiscovery)
+			customOAuthRoute.GET("/", middleware.RequirePermission(authz.OptionRead), controller.GetCustomOAuthProviders)
+			customOAuthRoute.GET("/:id", middleware.RequirePermission(authz.OptionRead), controller.GetCustomOAuthProvider)
+			customOAuthRoute.POST("/", middleware.RequirePermission(authz.OptionWrite), controller.CreateCustomOAuthProvider)
+			customOAuthRoute.PUT("/:id", middleware.RequirePermission(authz.OptionWrite), controller.UpdateCustomOAuthProvider)
+			customOAuthRoute.DELETE("/:id", middleware.RequirePermission(authz.OptionWrite), controller.DeleteCustomOAuthProvider)
 		}
 		performanceRoute := apiRouter.Group("/performance")
-		performanceRoute.Use(middleware.RootAuth())
+		performanceRoute.Use(middleware.AdminAuth())
 		{
-			performanceRoute.GET("/stats", controller.GetPerformanceStats)
-			performanceRoute.DELETE("/disk_cache", controller.ClearDiskCache)
-			performanceRoute.POST("/reset_stats", controller.ResetPerformanceStats)
-			performanceRoute.POST("/gc", controller.ForceGC)
-			performanceRoute.GET("/logs", controller.GetLogFiles)
-			performanceRoute.DELETE("/logs", controller.CleanupLogFiles)
+			performanceRoute.GET("/stats", middleware.RequirePermission(authz.SystemRead), controller.GetPerformanceStats)
+			performanceRoute.DELETE("/disk_cache", middleware.RequirePermission(authz.SystemOperate), controller.ClearDiskCache)
+			performanceRoute.POST("/reset_stats", middleware.RequirePermission(authz.SystemOperate), controller.ResetPerformanceStats)
+			performanceRoute.POST("/gc", middleware.RequirePermission(authz.SystemOperate), controller.ForceGC)
+			performanceRoute.GET("/logs", middleware.RequirePermission(authz.SystemRead), controller.GetLogFiles)
+			performanceRoute.DELETE("/logs", middleware.RequirePermission(authz.SystemOperate), controller.CleanupLogFiles)
 		}
 		ratioSyncRoute := apiRouter.Group("/ratio_sync")
-		ratioSyncRoute.Use(middleware.RootAuth())
+		ratioSyncRoute.Use(middleware.AdminAuth())
 		{
-			ratioSyncRoute.GET("/channels", controller.GetSyncableChannels)
-			ratioSyncRoute.POST("/fetch", controller.FetchUpstreamRatios)
+			ratioSyncRoute.GET("/channels", middleware.RequirePermission(authz.ModelSensitiveWrite), controller.GetSyncableChannels)
+			ratioSyncRoute.POST("/fetch", middleware.RequirePermission(authz.ModelSensitiveWrite), controller.FetchUpstreamRatios)
 		}
 		registerChannelRoutes(apiRouter)
 		registerAuthzRoutes(apiRouter)
@@ -261,45 +326,101 @@ func SetApiRouter(router *gin.Engine) {
 		redemptionRoute := apiRouter.Group("/redemption")
 		redemptionRoute.Use(middleware.AdminAuth())
 		{
-			redemptionRoute.GET("/", controller.GetAllRedemptions)
-			redemptionRoute.GET("/search", controller.SearchRedemptions)
-			redemptionRoute.GET("/:id", controller.GetRedemption)
-			redemptionRoute.POST("/", controller.AddRedemption)
-			redemptionRoute.PUT("/", controller.UpdateRedemption)
-			redemptionRoute.DELETE("/invalid", controller.DeleteInvalidRedemption)
-			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
+			redemptionRoute.GET("/", middleware.RequirePermission(authz.RedemptionRead), controller.GetAllRedemptions)
+			redemptionRoute.GET("/search", middleware.RequirePermission(authz.RedemptionRead), controller.SearchRedemptions)
+			redemptionRoute.GET("/:id", middleware.RequirePermission(authz.RedemptionRead), controller.GetRedemption)
+			redemptionRoute.POST("/", middleware.RequirePermission(authz.RedemptionWrite), controller.AddRedemption)
+			redemptionRoute.PUT("/", middleware.RequirePermission(authz.RedemptionWrite), controller.UpdateRedemption)
+			redemptionRoute.DELETE("/invalid", middleware.RequirePermission(authz.RedemptionWrite), controller.DeleteInvalidRedemption)
+			redemptionRoute.DELETE("/:id", middleware.RequirePermission(authz.RedemptionWrite), controller.DeleteRedemption)
 		}
 		logRoute := apiRouter.Group("/log")
-		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
-		logRoute.GET("/stat", middleware.AdminAuth(), controller.GetLogsStat)
+		logRoute.GET("/", middleware.AdminAuth(), middleware.RequirePermission(authz.LogRead), controller.GetAllLogs)
+		logRoute.GET("/stat", middleware.AdminAuth(), middleware.RequirePermission(authz.LogRead), controller.GetLogsStat)
 		logRoute.GET("/self/stat", middleware.UserAuth(), controller.GetLogsSelfStat)
-		logRoute.GET("/channel_affinity_usage_cache", middleware.AdminAuth(), controller.GetChannelAffinityUsageCacheStats)
-		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
+		logRoute.GET("/channel_affinity_usage_cache", middleware.AdminAuth(), middleware.RequirePermission(authz.LogRead), controller.GetChannelAffinityUsageCacheStats)
+		logRoute.GET("/search", middleware.AdminAuth(), middleware.RequirePermission(authz.LogRead), controller.SearchAllLogs)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), middleware.SearchRateLimit(), controller.SearchUserLogs)
 
+		creditLedgerRoute := apiRouter.Group("/credit_ledger")
+		creditLedgerRoute.Use(middleware.AdminAuth())
+		{
+			creditLedgerRoute.GET("/reconcile", middleware.RequirePermission(authz.UserRead), controller.ReconcileCreditLedger)
+			creditLedgerRoute.POST("/reconcile/:id", middleware.RequirePermission(authz.UserSensitiveWrite), controller.RepairCreditLedger)
+			creditLedgerRoute.GET("/:id/grants", middleware.RequirePermission(authz.UserRead), controller.GetUserCreditGrants)
+			creditLedgerRoute.GET("/:id/entries", middleware.RequirePermission(authz.UserRead), controller.GetUserCreditLedgerEntries)
+			creditLedgerRoute.GET("/:id/balance", middleware.RequirePermission(authz.UserRead), controller.GetUserCreditLedgerBalance)
+		}
+
+		postpaidRoute := apiRouter.Group("/postpaid")
+		postpaidRoute.Use(middleware.AdminAuth())
+		{
+			postpaidRoute.GET("/", middleware.RequirePermission(authz.UserRead), controller.GetPostpaidAccounts)
+			postpaidRoute.PUT("/:id", middleware.RequirePermission(authz.UserSensitiveWrite), controller.UpdatePostpaidAccount)
+			postpaidRoute.DELETE("/:id", middleware.RequirePermission(authz.UserSensitiveWrite), controller.DeletePostpaidAccount)
+			postpaidRoute.POST("/:id/settle", middleware.RequirePermission(authz.UserSensitiveWrite), controller.SettlePostpaidAccount)
+		}
+
+		priceBookRoute := apiRouter.Group("/price_book")
+		priceBookRoute.Use(middleware.AdminAuth())
+		{
+			priceBookRoute.GET("/", middleware.RequirePermission(authz.ModelRead), controller.GetPriceBooks)
+			priceBookRoute.GET("/history", middleware.RequirePermission(authz.ModelRead), controller.GetModelPriceHistory)
+			priceBookRoute.POST("/preview", middleware.RequirePermission(authz.ModelRead), controller.PreviewPriceBook)
+			priceBookRoute.POST("/", middleware.RequirePermission(authz.ModelSensitiveWrite), controller.CreatePriceBook)
+			priceBookRoute.GET("/:id", middleware.RequirePermission(authz.ModelRead), controller.GetPriceBook)
+			priceBookRoute.GET("/:id/diff", middleware.RequirePermission(authz.ModelRead), controller.GetPriceBookDiff)
+			priceBookRoute.DELETE("/:id", middleware.RequirePermission(authz.ModelSensitiveWrite), controller.CancelPriceBook)
+		}
+
+		billingSimulationRoute := apiRouter.Group("/billing_simulation")
+		billingSimulationRoute.Use(middleware.AdminAuth())
+		{
+			billingSimulationRoute.POST("/", middleware.RequirePermission(authz.LogRead), controller.SimulateBilling)
+		}
+
+		statementRoute := apiRouter.Group("/statement")
+		statementRoute.Use(middleware.AdminAuth())
+		{
+			statementRoute.GET("/", middleware.RequirePermission(authz.UserRead), controller.GetAllStatements)
+			statementRoute.GET("/:id/download", middleware.RequirePermission(authz.UserRead), controller.DownloadStatement)
+			statementRoute.POST("/generate", middleware.RequirePermission(authz.UserSensitiveWrite), controller.GenerateStatements)
+		}
+
+		auditLogRoute := apiRouter.Group("/audit_log")
+		auditLogRoute.Use(middleware.AdminAuth(), middleware.RequirePermission(authz.AuditLogRead))
+		{
+			auditLogRoute.GET("/", controller.GetAuditLogs)
+			auditLogRoute.GET("/export", controller.ExportAuditLogs)
+			auditLogRoute.GET("/verify", controller.VerifyAuditLogChain)
+		}
+
 		systemTaskRoute := apiRouter.Group("/system-task")
-		systemTaskRoute.Use(middleware.RootAuth())
+		systemTaskRoute.Use(middleware.AdminAuth())
 		{
-			systemTaskRoute.POST("/log-cleanup", controller.CreateLogCleanupSystemTask)
-			systemTaskRoute.GET("/list", controller.ListSystemTasks)
-			systemTaskRoute.GET("/current", controller.GetCurrentSystemTask)
-			systemTaskRoute.GET("/:task_id", controller.GetSystemTask)
+			systemTaskRoute.POST("/log-cleanup", middleware.RequirePermission(authz.SystemOperate), controller.CreateLogCleanupSystemTask)
+			systemTaskRoute.GET("/list", middleware.RequirePermission(authz.SystemRead), controller.ListSystemTasks)
+			systemTaskRoute.GET("/current", middleware.RequirePermission(authz.SystemRead), controller.GetCurrentSystemTask)
+			systemTaskRoute.GET("/:task_id", middleware.RequirePermission(authz.SystemRead), controller.GetSystemTask)
 		}
 		systemInfoRoute := apiRouter.Group("/system-info")
-		systemInfoRoute.Use(middleware.RootAuth())
+		systemInfoRoute.Use(middleware.AdminAuth())
 		{
-			systemInfoRoute.GET("/instances", controller.ListSystemInstances)
-			systemInfoRoute.DELETE("/stale-instances", controller.DeleteStaleSystemInstances)
-			systemInfoRoute.DELETE("/instances/:node_name", controller.DeleteStaleSystemInstance)
+			systemInfoRoute.GET("/instances", middleware.RequirePermission(authz.SystemRead), controller.ListSystemInstances)
+			systemInfoRoute.DELETE("/stale-instances", middleware.RequirePermission(authz.SystemOperate), controller.DeleteStaleSystemInstances)
+			systemInfoRoute.DELETE("/instances/:node_name", middleware.RequirePermission(authz.SystemOperate), controller.DeleteStaleSystemInstance)
 		}
 
 		dataRoute := apiRouter.Group("/data")
-		dataRoute.GET("/", middleware.AdminAuth(), controller.GetAllQuotaDates)
-		dataRoute.GET("/users", middleware.AdminAuth(), controller.GetQuotaDatesByUser)
+		dataRoute.GET("/", middleware.AdminAuth(), middleware.RequirePermission(authz.LogRead), controller.GetAllQuotaDates)
+		dataRoute.GET("/users", middleware.AdminAuth(), middleware.RequirePermission(authz.LogRead), controller.GetQuotaDatesByUser)
 		dataRoute.GET("/self", middleware.UserAuth(), controller.GetUserQuotaDates)
-		dataRoute.GET("/flow", middleware.AdminAuth(), controller.GetAllFlowQuotaDates)
+		dataRoute.GET("/flow", middleware.AdminAuth(), middleware.RequirePermission(authz.LogRead), controller.GetAllFlowQuotaDates)
 		dataRoute.GET("/flow/self", middleware.UserAuth(), controller.GetUserFlowQuotaDates)
+		dataRoute.GET("/projects", middleware.AdminAuth(), middleware.RequirePermission(authz.LogRead), controller.GetProjectUsageReport)
+		dataRoute.GET("/projects/self", middleware.UserAuth(), controller.GetSelfProjectUsageReport)
+		dataRoute.GET("/margin", middleware.AdminAuth(), middleware.RequirePermission(authz.ChannelRead), controller.GetChannelMarginReport)
 
 		logRoute.Use(middleware.CORS(), middleware.CriticalRateLimit())
 		{
@@ -308,77 +429,89 @@ func SetApiRouter(router *gin.Engine) {
 		groupRoute := apiRouter.Group("/group")
 		groupRoute.Use(middleware.AdminAuth())
 		{
-			groupRoute.GET("/", controller.GetGroups)
+			groupRoute.GET("/", middleware.RequirePermission(authz.ModelRead), controller.GetGroups)
 		}
 
 		prefillGroupRoute := apiRouter.Group("/prefill_group")
 		prefillGroupRoute.Use(middleware.AdminAuth())
 		{
-			prefillGroupRoute.GET("/", controller.GetPrefillGroups)
-			prefillGroupRoute.POST("/", controller.CreatePrefillGroup)
-			prefillGroupRoute.PUT("/", controller.UpdatePrefillGroup)
-			prefillGroupRoute.DELETE("/:id", controller.DeletePrefillGroup)
+			prefillGroupRoute.GET("/", middleware.RequirePermission(authz.ModelRead), controller.GetPrefillGroups)
+			prefillGroupRoute.POST("/", middleware.RequirePermission(authz.ModelWrite), controller.CreatePrefillGroup)
+			prefillGroupRoute.PUT("/", middleware.RequirePermission(authz.ModelWrite), controller.UpdatePrefillGroup)
+			prefillGroupRoute.DELETE("/:id", middleware.RequirePermission(authz.ModelWrite), controller.DeletePrefillGroup)
 		}
 
 		mjRoute := apiRouter.Group("/mj")
 		mjRoute.GET("/self", middleware.UserAuth(), controller.GetUserMidjourney)
-		mjRoute.GET("/", middleware.AdminAuth(), controller.GetAllMidjourney)
+		mjRoute.GET("/", middleware.AdminAuth(), middleware.RequirePermission(authz.LogRead), controller.GetAllMidjourney)
+		mjRoute.POST("/refetch", middleware.AdminAuth(), middleware.RequirePermission(authz.ChannelOperate), controller.RefetchMidjourneyTasks)
 
 		taskRoute := apiRouter.Group("/task")
 		{
 			taskRoute.GET("/self", middleware.UserAuth(), controller.GetUserTask)
-			taskRoute.GET("/", middleware.AdminAuth(), controller.GetAllTask)
+			taskRoute.GET("/", middleware.AdminAuth(), middleware.RequirePermission(authz.LogRead), controller.GetAllTask)
+			taskRoute.GET("/webhook/self", middleware.UserAuth(), controller.GetUserTaskWebhookDeliveries)
+			taskRoute.POST("/webhook/self/:id/retry", middleware.UserAuth(), controller.RetryUserTaskWebhookDelivery)
+			taskRoute.GET("/webhook/", middleware.AdminAuth(), middleware.RequirePermission(authz.LogRead), controller.GetAllTaskWebhookDeliveries)
+		}
+
+		mediaRoute := apiRouter.Group("/media")
+		{
+			mediaRoute.GET("/self", middleware.UserAuth(), controller.GetUserMediaAssets)
+			mediaRoute.DELETE("/self/:id", middleware.UserAuth(), controller.DeleteUserMediaAsset)
+			mediaRoute.GET("/", middleware.AdminAuth(), middleware.RequirePermission(authz.LogRead), controller.GetAllMediaAssets)
+			mediaRoute.DELETE("/:id", middleware.AdminAuth(), middleware.RequirePermission(authz.SystemOperate), controller.DeleteMediaAsset)
 		}
 
 		vendorRoute := apiRouter.Group("/vendors")
 		vendorRoute.Use(middleware.AdminAuth())
 		{
-			vendorRoute.GET("/", controller.GetAllVendors)
-			vendorRoute.GET("/search", controller.SearchVendors)
-			vendorRoute.GET("/:id", controller.GetVendorMeta)
-			vendorRoute.POST("/", controller.CreateVendorMeta)
-			vendorRoute.PUT("/", controller.UpdateVendorMeta)
-			vendorRoute.DELETE("/:id", controller.DeleteVendorMeta)
+			vendorRoute.GET("/", middleware.RequirePermission(authz.ModelRead), controller.GetAllVendors)
+			vendorRoute.GET("/search", middleware.RequirePermission(authz.ModelRead), controller.SearchVendors)
+			vendorRoute.GET("/:id", middleware.RequirePermission(authz.ModelRead), controller.GetVendorMeta)
+			vendorRoute.POST("/", middleware.RequirePermission(authz.ModelWrite), controller.CreateVendorMeta)
+			vendorRoute.PUT("/", middleware.RequirePermission(authz.ModelWrite), controller.UpdateVendorMeta)
+			vendorRoute.DELETE("/:id", middleware.RequirePermission(authz.ModelWrite), controller.DeleteVendorMeta)
 		}
 
 		modelsRoute := apiRouter.Group("/models")
 		modelsRoute.Use(middleware.AdminAuth())
 		{
-			modelsRoute.GET("/sync_upstream/preview", controller.SyncUpstreamPreview)
-			modelsRoute.POST("/sync_upstream", controller.SyncUpstreamModels)
-			modelsRoute.GET("/missing", controller.GetMissingModels)
-			modelsRoute.GET("/", controller.GetAllModelsMeta)
-			modelsRoute.GET("/search", controller.SearchModelsMeta)
-			modelsRoute.GET("/:id", controller.GetModelMeta)
-			modelsRoute.POST("/", controller.CreateModelMeta)
-			modelsRoute.PUT("/", controller.UpdateModelMeta)
-			modelsRoute.DELETE("/:id", controller.DeleteModelMeta)
+			modelsRoute.GET("/sync_upstream/preview", middleware.RequirePermission(authz.ModelRead), controller.SyncUpstreamPreview)
+			modelsRoute.POST("/sync_upstream", middleware.RequirePermission(authz.ModelWrite), controller.SyncUpstreamModels)
+			modelsRoute.GET("/missing", middleware.RequirePermission(authz.ModelRead), controller.GetMissingModels)
+			modelsRoute.GET("/", middleware.RequirePermission(authz.ModelRead), controller.GetAllModelsMeta)
+			modelsRoute.GET("/search", middleware.RequirePermission(authz.ModelRead), controller.SearchModelsMeta)
+			modelsRoute.GET("/:id", middleware.RequirePermission(authz.ModelRead), controller.GetModelMeta)
+			modelsRoute.POST("/", middleware.RequirePermission(authz.ModelWrite), controller.CreateModelMeta)
+			modelsRoute.PUT("/", middleware.RequirePermission(authz.ModelWrite), controller.UpdateModelMeta)
+			modelsRoute.DELETE("/:id", middleware.RequirePermission(authz.ModelWrite), controller.DeleteModelMeta)
 		}
 
 		// Deployments (model deployment management)
 		deploymentsRoute := apiRouter.Group("/deployments")
 		deploymentsRoute.Use(middleware.AdminAuth())
 		{
-			deploymentsRoute.GET("/settings", controller.GetModelDeploymentSettings)
-			deploymentsRoute.POST("/settings/test-connection", controller.TestIoNetConnection)
-			deploymentsRoute.GET("/", controller.GetAllDeployments)
-			deploymentsRoute.GET("/search", controller.SearchDeployments)
-			deploymentsRoute.POST("/test-connection", controller.TestIoNetConnection)
-			deploymentsRoute.GET("/hardware-types", controller.GetHardwareTypes)
-			deploymentsRoute.GET("/locations", controller.GetLocations)
-			deploymentsRoute.GET("/available-replicas", controller.GetAvailableReplicas)
-			deploymentsRoute.POST("/price-estimation", controller.GetPriceEstimation)
-			deploymentsRoute.GET("/check-name", controller.CheckClusterNameAvailability)
-			deploymentsRoute.POST("/", controller.CreateDeployment)
-
-			deploymentsRoute.GET("/:id", controller.GetDeployment)
-			deploymentsRoute.GET("/:id/logs", controller.GetDeploymentLogs)
-			deploymentsRoute.GET("/:id/containers", controller.ListDeploymentContainers)
-			deploymentsRoute.GET("/:id/containers/:container_id", controller.GetContainerDetails)
-			deploymentsRoute.PUT("/:id", controller.UpdateDeployment)
-			deploymentsRoute.PUT("/:id/name", controller.UpdateDeploymentName)
-			deploymentsRoute.POST("/:id/extend", controller.ExtendDeployment)
-			deploymentsRoute.DELETE("/:id", controller.DeleteDeployment)
+			deploymentsRoute.GET("/settings", middleware.RequirePermission(authz.DeploymentRead), controller.GetModelDeploymentSettings)
+			deploymentsRoute.POST("/settings/test-connection", middleware.RequirePermission(authz.DeploymentRead), controller.TestIoNetConnection)
+			deploymentsRoute.GET("/", middleware.RequirePermission(authz.DeploymentRead), controller.GetAllDeployments)
+			deploymentsRoute.GET("/search", middleware.RequirePermission(authz.DeploymentRead), controller.SearchDeployments)
+			deploymentsRoute.POST("/test-connection", middleware.RequirePermission(authz.DeploymentRead), controller.TestIoNetConnection)
+			deploymentsRoute.GET("/hardware-types", middleware.RequirePermission(authz.DeploymentRead), controller.GetHardwareTypes)
+			deploymentsRoute.GET("/locations", middleware.RequirePermission(authz.DeploymentRead), controller.GetLocations)
+			deploymentsRoute.GET("/available-replicas", middleware.RequirePermission(authz.DeploymentRead), controller.GetAvailableReplicas)
+			deploymentsRoute.POST("/price-estimation", middleware.RequirePermission(authz.DeploymentRead), controller.GetPriceEstimation)
+			deploymentsRoute.GET("/check-name", middleware.RequirePermission(authz.DeploymentRead), controller.CheckClusterNameAvailability)
+			deploymentsRoute.POST("/", middleware.RequirePermission(authz.DeploymentWrite), controller.CreateDeployment)
+
+			deploymentsRoute.GET("/:id", middleware.RequirePermission(authz.DeploymentRead), controller.GetDeployment)
+			deploymentsRoute.GET("/:id/logs", middleware.RequirePermission(authz.DeploymentRead), controller.GetDeploymentLogs)
+			deploymentsRoute.GET("/:id/containers", middleware.RequirePermission(authz.DeploymentRead), controller.ListDeploymentContainers)
+			deploymentsRoute.GET("/:id/containers/:container_id", middleware.RequirePermission(authz.DeploymentRead), controller.GetContainerDetails)
+			deploymentsRoute.PUT("/:id", middleware.RequirePermission(authz.DeploymentWrite), controller.UpdateDeployment)
+			deploymentsRoute.PUT("/:id/name", middleware.RequirePermission(authz.DeploymentWrite), controller.UpdateDeploymentName)
+			deploymentsRoute.POST("/:id/extend", middleware.RequirePermission(authz.DeploymentWrite), controller.ExtendDeployment)
+			deploymentsRoute.DELETE("/:id", middleware.RequirePermission(authz.DeploymentWrite), controller.DeleteDeployment)
 		}
 	}
 }
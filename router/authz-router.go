@@ -3,17 +3,25 @@ package router
 import (
 	"github.com/QuantumNous/new-api/controller"
 	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/service/authz"
 
 	"github.com/gin-gonic/gin"
 )
 
 // registerAuthzRoutes mounts the authorization API under its own /authz
 // namespace. GET /authz/catalog returns the permission schema (resources,
-// actions, and role baselines) used by the client permission editor.
+// actions, and role baselines) used by the client permission editor; the
+// remaining routes manage custom roles and their assignment to admins.
 func registerAuthzRoutes(apiRouter *gin.RouterGroup) {
 	authzRoute := apiRouter.Group("/authz")
 	authzRoute.Use(middleware.AdminAuth())
 	{
 		authzRoute.GET("/catalog", controller.GetPermissionCatalog)
+		authzRoute.GET("/roles", middleware.RequirePermission(authz.RoleRead), controller.GetAuthzRoles)
+		authzRoute.POST("/roles", middleware.RequirePermission(authz.RoleWrite), controller.CreateAuthzRole)
+		authzRoute.PUT("/roles/:key", middleware.RequirePermission(authz.RoleWrite), controller.UpdateAuthzRole)
+		authzRoute.DELETE("/roles/:key", middleware.RequirePermission(authz.RoleWrite), controller.DeleteAuthzRole)
+		authzRoute.GET("/users/:id/roles", middleware.RequirePermission(authz.RoleRead), controller.GetUserAuthzRoles)
+		authzRoute.PUT("/users/:id/roles", middleware.RequirePermission(authz.RoleWrite), controller.UpdateUserAuthzRoles)
 	}
 }
@@ -120,6 +120,9 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.POST("/images/edits", func(c *gin.Context) {
 			controller.Relay(c, types.RelayFormatOpenAIImage)
 		})
+		httpRouter.POST("/images/variations", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatOpenAIImage)
+		})
 
 		// embedding related routes
 		httpRouter.POST("/embeddings", func(c *gin.Context) {
@@ -156,7 +159,6 @@ func SetRelayRouter(router *gin.Engine) {
 		})
 
 		// not implemented
-		httpRouter.POST("/images/variations", controller.RelayNotImplemented)
 		httpRouter.GET("/files", controller.RelayNotImplemented)
 		httpRouter.POST("/files", controller.RelayNotImplemented)
 		httpRouter.DELETE("/files/:id", controller.RelayNotImplemented)
@@ -170,6 +172,73 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.DELETE("/models/:model", controller.RelayNotImplemented)
 	}
 
+	// Ollama 原生 API：请求先转换为 OpenAI 格式，再走与 /v1 一致的鉴权、分发与计费链路
+	ollamaRouter := router.Group("/api")
+	ollamaRouter.Use(middleware.RouteTag("relay"))
+	{
+		ollamaRouter.GET("/tags", middleware.TokenAuth(), func(c *gin.Context) {
+			controller.ListModels(c, constant.ChannelTypeOllama)
+		})
+
+		ollamaRelayRouter := ollamaRouter.Group("")
+		ollamaRelayRouter.Use(middleware.OllamaRequestConvert())
+		ollamaRelayRouter.Use(middleware.SystemPerformanceCheck())
+		ollamaRelayRouter.Use(middleware.TokenAuth())
+		ollamaRelayRouter.Use(middleware.ModelRequestRateLimit())
+		ollamaRelayRouter.Use(middleware.Distribute())
+		ollamaRelayRouter.POST("/chat", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatOpenAI)
+		})
+		ollamaRelayRouter.POST("/generate", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatOpenAI)
+		})
+		ollamaRelayRouter.POST("/embed", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatEmbedding)
+		})
+	}
+
+	// Cohere v2 原生 API：与 Ollama 相同，先转换为 OpenAI 格式再走统一链路
+	cohereRouter := router.Group("/v2")
+	cohereRouter.Use(middleware.RouteTag("relay"))
+	cohereRouter.Use(middleware.CohereRequestConvert())
+	cohereRouter.Use(middleware.SystemPerformanceCheck())
+	cohereRouter.Use(middleware.TokenAuth())
+	cohereRouter.Use(middleware.ModelRequestRateLimit())
+	cohereRouter.Use(middleware.Distribute())
+	{
+		cohereRouter.POST("/chat", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatOpenAI)
+		})
+		cohereRouter.POST("/embed", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatEmbedding)
+		})
+		cohereRouter.POST("/rerank", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatRerank)
+		})
+	}
+
+	// Mistral 原生 API，挂载在 /mistral 前缀下以免与 OpenAI 兼容的 /v1 冲突
+	mistralRouter := router.Group("/mistral/v1")
+	mistralRouter.Use(middleware.RouteTag("relay"))
+	{
+		mistralRouter.GET("/models", middleware.TokenAuth(), func(c *gin.Context) {
+			controller.ListModels(c, constant.ChannelTypeOpenAI)
+		})
+
+		mistralRelayRouter := mistralRouter.Group("")
+		mistralRelayRouter.Use(middleware.MistralRequestConvert())
+		mistralRelayRouter.Use(middleware.SystemPerformanceCheck())
+		mistralRelayRouter.Use(middleware.TokenAuth())
+		mistralRelayRouter.Use(middleware.ModelRequestRateLimit())
+		mistralRelayRouter.Use(middleware.Distribute())
+		mistralRelayRouter.POST("/chat/completions", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatOpenAI)
+		})
+		mistralRelayRouter.POST("/embeddings", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatEmbedding)
+		})
+	}
+
 	relayMjRouter := router.Group("/mj")
 	relayMjRouter.Use(middleware.RouteTag("relay"))
 	relayMjRouter.Use(middleware.SystemPerformanceCheck())
@@ -61,6 +61,19 @@ func TestListModelsSupportsOpenAIAndGeminiAuthentication(t *testing.T) {
 			path:          "/v1/models?key=modelstestkey",
 			expectedField: "models",
 		},
+		{
+			name:          "Ollama tags",
+			path:          "/api/tags",
+			headerName:    "Authorization",
+			expectedField: "models",
+		},
+		{
+			name:           "Mistral models",
+			path:           "/mistral/v1/models",
+			headerName:     "Authorization",
+			expectedObject: "list",
+			expectedField:  "data",
+		},
 	}
 
 	for _, test := range tests {
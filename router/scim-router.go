@@ -0,0 +1,36 @@
+package router
+
+import (
+	"github.com/QuantumNous/new-api/controller"
+	"github.com/QuantumNous/new-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetSCIMRouter mounts the SCIM 2.0 provisioning API used by identity
+// providers. It lives outside /api because SCIM clients expect the standard
+// /scim/v2 base path and authenticate with the dedicated SCIM bearer token.
+func SetSCIMRouter(router *gin.Engine) {
+	scimRouter := router.Group("/scim/v2")
+	scimRouter.Use(middleware.RouteTag("api"))
+	scimRouter.Use(middleware.GlobalAPIRateLimit())
+	scimRouter.Use(middleware.SCIMAuth())
+	{
+		scimRouter.GET("/ServiceProviderConfig", controller.SCIMServiceProviderConfig)
+		scimRouter.GET("/ResourceTypes", controller.SCIMResourceTypes)
+
+		scimRouter.GET("/Users", controller.SCIMListUsers)
+		scimRouter.POST("/Users", controller.SCIMCreateUser)
+		scimRouter.GET("/Users/:id", controller.SCIMGetUser)
+		scimRouter.PUT("/Users/:id", controller.SCIMReplaceUser)
+		scimRouter.PATCH("/Users/:id", controller.SCIMPatchUser)
+		scimRouter.DELETE("/Users/:id", controller.SCIMDeleteUser)
+
+		scimRouter.GET("/Groups", controller.SCIMListGroups)
+		scimRouter.POST("/Groups", controller.SCIMCreateGroup)
+		scimRouter.GET("/Groups/:id", controller.SCIMGetGroup)
+		scimRouter.PUT("/Groups/:id", controller.SCIMReplaceGroup)
+		scimRouter.PATCH("/Groups/:id", controller.SCIMPatchGroup)
+		scimRouter.DELETE("/Groups/:id", controller.SCIMDeleteGroup)
+	}
+}
@@ -14,6 +14,9 @@ func SetVideoRouter(router *gin.Engine) {
 	videoProxyRouter.Use(middleware.TokenOrUserAuth())
 	{
 		videoProxyRouter.GET("/videos/:task_id/content", controller.VideoProxy)
+		videoProxyRouter.GET("/videos", controller.VideoList)
+		videoProxyRouter.DELETE("/videos/:task_id", controller.VideoDelete)
+		videoProxyRouter.POST("/videos/:video_id/cancel", controller.VideoCancel)
 	}
 
 	videoV1Router := router.Group("/v1")
@@ -0,0 +1,198 @@
+package saml
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type spMetadata struct {
+	XMLName    xml.Name `xml:"md:EntityDescriptor"`
+	XmlnsMD    string   `xml:"xmlns:md,attr"`
+	XmlnsDS    string   `xml:"xmlns:ds,attr"`
+	EntityID   string   `xml:"entityID,attr"`
+	Descriptor struct {
+		AuthnRequestsSigned        bool              `xml:"AuthnRequestsSigned,attr"`
+		WantAssertionsSigned       bool              `xml:"WantAssertionsSigned,attr"`
+		ProtocolSupportEnumeration string            `xml:"protocolSupportEnumeration,attr"`
+		KeyDescriptors             []spKeyDescriptor `xml:"md:KeyDescriptor"`
+		NameIDFormat               string            `xml:"md:NameIDFormat,omitempty"`
+		AssertionConsumerServices  []spEndpoint      `xml:"md:AssertionConsumerService"`
+	} `xml:"md:SPSSODescriptor"`
+}
+
+type spKeyDescriptor struct {
+	Use         string `xml:"use,attr"`
+	Certificate string `xml:"ds:KeyInfo>ds:X509Data>ds:X509Certificate"`
+}
+
+type spEndpoint struct {
+	Binding   string `xml:"Binding,attr"`
+	Location  string `xml:"Location,attr"`
+	Index     int    `xml:"index,attr"`
+	IsDefault bool   `xml:"isDefault,attr"`
+}
+
+// Metadata renders the SP metadata document that is registered with the IdP.
+func (sp *ServiceProvider) Metadata() ([]byte, error) {
+	if sp.Certificate == nil {
+		return nil, errors.New("saml: service provider certificate is not configured")
+	}
+	var metadata spMetadata
+	metadata.XmlnsMD = NamespaceMetadata
+	metadata.XmlnsDS = NamespaceDSig
+	metadata.EntityID = sp.EntityID
+	metadata.Descriptor.AuthnRequestsSigned = true
+	metadata.Descriptor.WantAssertionsSigned = true
+	metadata.Descriptor.ProtocolSupportEnumeration = NamespaceProtocol
+	certificate := base64.StdEncoding.EncodeToString(sp.Certificate.Raw)
+	metadata.Descriptor.KeyDescriptors = []spKeyDescriptor{{Use: "signing", Certificate: certificate}}
+	metadata.Descriptor.NameIDFormat = sp.NameIDFormat
+	metadata.Descriptor.AssertionConsumerServices = []spEndpoint{{Binding: BindingHTTPPost, Location: sp.ACSURL, Index: 0, IsDefault: true}}
+	out, err := xml.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+type idpEntityDescriptor struct {
+	EntityID         string `xml:"entityID,attr"`
+	IDPSSODescriptor *struct {
+		KeyDescriptors []struct {
+			Use          string   `xml:"use,attr"`
+			Certificates []string `xml:"http://www.w3.org/2000/09/xmldsig# KeyInfo>X509Data>X509Certificate"`
+		} `xml:"urn:oasis:names:tc:SAML:2.0:metadata KeyDescriptor"`
+		SingleSignOnServices []struct {
+			Binding  string `xml:"Binding,attr"`
+			Location string `xml:"Location,attr"`
+		} `xml:"urn:oasis:names:tc:SAML:2.0:metadata SingleSignOnService"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:metadata IDPSSODescriptor"`
+}
+
+type idpEntitiesDescriptor struct {
+	Entities []idpEntityDescriptor `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+}
+
+// ParseIdPMetadata extracts the entity ID, HTTP-Redirect SSO endpoint and
+// signing certificates from IdP metadata. Both a single EntityDescriptor and
+// an EntitiesDescriptor (the first entity with an IdP role is used) are
+// accepted.
+func ParseIdPMetadata(data []byte) (*IdentityProvider, error) {
+	root, err := parseXML(data)
+	if err != nil {
+		return nil, err
+	}
+	var entities []idpEntityDescriptor
+	switch {
+	case root.is(NamespaceMetadata, "EntityDescriptor"):
+		var entity idpEntityDescriptor
+		if err := xml.Unmarshal(data, &entity); err != nil {
+			return nil, err
+		}
+		entities = append(entities, entity)
+	case root.is(NamespaceMetadata, "EntitiesDescriptor"):
+		var list idpEntitiesDescriptor
+		if err := xml.Unmarshal(data, &list); err != nil {
+			return nil, err
+		}
+		entities = list.Entities
+	default:
+		return nil, errors.New("saml: metadata is not an EntityDescriptor")
+	}
+	for _, entity := range entities {
+		if entity.IDPSSODescriptor == nil {
+			continue
+		}
+		idp := &IdentityProvider{EntityID: entity.EntityID}
+		for _, service := range entity.IDPSSODescriptor.SingleSignOnServices {
+			if service.Binding == BindingHTTPRedirect {
+				idp.SSOURL = service.Location
+				break
+			}
+		}
+		for _, descriptor := range entity.IDPSSODescriptor.KeyDescriptors {
+			if descriptor.Use != "" && descriptor.Use != "signing" {
+				continue
+			}
+			for _, encoded := range descriptor.Certificates {
+				certificates, err := ParseCertificates(encoded)
+				if err != nil {
+					return nil, err
+				}
+				idp.Certificates = append(idp.Certificates, certificates...)
+			}
+		}
+		if idp.SSOURL == "" {
+			return nil, errors.New("saml: IdP metadata has no HTTP-Redirect SingleSignOnService")
+		}
+		return idp, nil
+	}
+	return nil, errors.New("saml: metadata has no IDPSSODescriptor")
+}
+
+// ParseCertificates accepts one or more PEM certificates, or the bare base64
+// DER form used inside metadata documents.
+func ParseCertificates(data string) ([]*x509.Certificate, error) {
+	data = strings.TrimSpace(data)
+	if data == "" {
+		return nil, nil
+	}
+	if !strings.Contains(data, "-----BEGIN") {
+		der, err := decodeBase64(data)
+		if err != nil {
+			return nil, fmt.Errorf("saml: invalid certificate: %w", err)
+		}
+		certificate, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		return []*x509.Certificate{certificate}, nil
+	}
+	var certificates []*x509.Certificate
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return nil, errors.New("saml: no certificate found in PEM data")
+	}
+	return certificates, nil
+}
+
+// ParsePrivateKey reads a PEM-encoded RSA key in PKCS#1 or PKCS#8 form.
+func ParsePrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(data)))
+	if block == nil {
+		return nil, errors.New("saml: private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("saml: invalid private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("saml: only RSA private keys are supported")
+	}
+	return key, nil
+}
@@ -0,0 +1,356 @@
+// Package saml implements the service provider side of SAML 2.0 Web Browser
+// SSO: SP metadata, AuthnRequests signed for the HTTP-Redirect binding and
+// validation of signed responses received on the HTTP-POST binding. It uses
+// only the standard library; XML signatures are verified with an exclusive
+// canonicalization implementation limited to the profile SAML IdPs emit.
+// Session and user handling live in the oauth and controller packages.
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	NamespaceAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+	NamespaceProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	NamespaceMetadata  = "urn:oasis:names:tc:SAML:2.0:metadata"
+
+	BindingHTTPRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	BindingHTTPPost     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+
+	NameIDFormatUnspecified  = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+	NameIDFormatEmailAddress = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+	NameIDFormatPersistent   = "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent"
+
+	StatusSuccess = "urn:oasis:names:tc:SAML:2.0:status:Success"
+
+	subjectConfirmationBearer = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+
+	// DefaultClockSkew is tolerated between the IdP's clock and ours.
+	DefaultClockSkew = 3 * time.Minute
+	// maxResponseSize bounds the decoded SAMLResponse.
+	maxResponseSize = 1 << 20
+)
+
+var ErrEncryptedAssertion = errors.New("saml: encrypted assertions are not supported")
+
+// IdentityProvider is the trusted IdP.
+type IdentityProvider struct {
+	EntityID     string
+	SSOURL       string
+	Certificates []*x509.Certificate
+}
+
+// ServiceProvider is this application as a SAML SP.
+type ServiceProvider struct {
+	EntityID     string
+	ACSURL       string
+	NameIDFormat string
+	Certificate  *x509.Certificate
+	PrivateKey   *rsa.PrivateKey
+	IdP          IdentityProvider
+	ClockSkew    time.Duration
+	// Now overrides the clock in tests.
+	Now func() time.Time
+}
+
+// Assertion is the validated content of a SAML response.
+type Assertion struct {
+	ID           string
+	InResponseTo string
+	Issuer       string
+	NameID       string
+	NameIDFormat string
+	SessionIndex string
+	// ExpiresAt is when the assertion stops being acceptable; replay caches
+	// must remember its ID at least until then.
+	ExpiresAt  time.Time
+	Attributes map[string][]string
+}
+
+// Attribute returns the first value of a SAML attribute, matched by Name or
+// FriendlyName.
+func (a *Assertion) Attribute(name string) string {
+	if values := a.Attributes[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (sp *ServiceProvider) now() time.Time {
+	if sp.Now != nil {
+		return sp.Now()
+	}
+	return time.Now()
+}
+
+func (sp *ServiceProvider) clockSkew() time.Duration {
+	if sp.ClockSkew > 0 {
+		return sp.ClockSkew
+	}
+	return DefaultClockSkew
+}
+
+// AuthnRequestURL returns the IdP URL the browser is redirected to, carrying
+// a deflated AuthnRequest signed with the SP key as the HTTP-Redirect binding
+// specifies (the signature covers the query string, not the XML).
+func (sp *ServiceProvider) AuthnRequestURL(requestID string, relayState string) (string, error) {
+	if sp.IdP.SSOURL == "" {
+		return "", errors.New("saml: IdP SSO URL is not configured")
+	}
+	if sp.PrivateKey == nil {
+		return "", errors.New("saml: service provider private key is not configured")
+	}
+	nameIDPolicy := ""
+	if sp.NameIDFormat != "" {
+		nameIDPolicy = `<samlp:NameIDPolicy Format="` + escapeAttr(sp.NameIDFormat) + `" AllowCreate="true"/>`
+	}
+	request := `<samlp:AuthnRequest xmlns:samlp="` + NamespaceProtocol + `" xmlns:saml="` + NamespaceAssertion + `"` +
+		` ID="` + escapeAttr(requestID) + `" Version="2.0"` +
+		` IssueInstant="` + sp.now().UTC().Format(time.RFC3339) + `"` +
+		` Destination="` + escapeAttr(sp.IdP.SSOURL) + `"` +
+		` AssertionConsumerServiceURL="` + escapeAttr(sp.ACSURL) + `"` +
+		` ProtocolBinding="` + BindingHTTPPost + `">` +
+		`<saml:Issuer>` + escapeText(sp.EntityID) + `</saml:Issuer>` +
+		nameIDPolicy +
+		`</samlp:AuthnRequest>`
+
+	var deflated bytes.Buffer
+	writer, err := flate.NewWriter(&deflated, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := writer.Write([]byte(request)); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	// The signed octets are the query parameters in this fixed order.
+	query := "SAMLRequest=" + url.QueryEscape(base64.StdEncoding.EncodeToString(deflated.Bytes()))
+	if relayState != "" {
+		query += "&RelayState=" + url.QueryEscape(relayState)
+	}
+	query += "&SigAlg=" + url.QueryEscape(AlgorithmRSASHA256)
+	hashed := crypto.SHA256.New()
+	hashed.Write([]byte(query))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, sp.PrivateKey, crypto.SHA256, hashed.Sum(nil))
+	if err != nil {
+		return "", err
+	}
+	query += "&Signature=" + url.QueryEscape(base64.StdEncoding.EncodeToString(signature))
+
+	separator := "?"
+	if strings.Contains(sp.IdP.SSOURL, "?") {
+		separator = "&"
+	}
+	return sp.IdP.SSOURL + separator + query, nil
+}
+
+// ParseResponse decodes and validates a SAMLResponse posted to the ACS. The
+// response or its single assertion must carry a valid signature from the
+// IdP; issuer, destination, audience, recipient and validity windows are
+// checked. Matching InResponseTo against an outstanding request is left to
+// the caller, which owns request state.
+func (sp *ServiceProvider) ParseResponse(encoded string) (*Assertion, error) {
+	raw, err := decodeBase64(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("saml: invalid SAMLResponse encoding: %w", err)
+	}
+	if len(raw) > maxResponseSize {
+		return nil, errors.New("saml: SAMLResponse is too large")
+	}
+	response, err := parseXML(raw)
+	if err != nil {
+		return nil, err
+	}
+	if !response.is(NamespaceProtocol, "Response") {
+		return nil, errors.New("saml: document is not a SAML Response")
+	}
+	if err := checkUniqueIDs(response); err != nil {
+		return nil, err
+	}
+	if response.attr("Version") != "2.0" {
+		return nil, errors.New("saml: unsupported SAML version")
+	}
+	if destination := response.attr("Destination"); destination != "" && destination != sp.ACSURL {
+		return nil, fmt.Errorf("saml: response destination %q does not match the ACS URL", destination)
+	}
+	inResponseTo := response.attr("InResponseTo")
+	if inResponseTo == "" {
+		return nil, errors.New("saml: IdP-initiated responses are not supported")
+	}
+	statusCode := response.child(NamespaceProtocol, "Status").child(NamespaceProtocol, "StatusCode")
+	if status := statusCode.attr("Value"); status != StatusSuccess {
+		if nested := statusCode.child(NamespaceProtocol, "StatusCode").attr("Value"); nested != "" {
+			status += " / " + nested
+		}
+		return nil, fmt.Errorf("saml: IdP returned status %q", status)
+	}
+	if issuer := response.child(NamespaceAssertion, "Issuer").text(); issuer != "" && sp.IdP.EntityID != "" && issuer != sp.IdP.EntityID {
+		return nil, fmt.Errorf("saml: unexpected response issuer %q", issuer)
+	}
+	if response.child(NamespaceAssertion, "EncryptedAssertion") != nil {
+		return nil, ErrEncryptedAssertion
+	}
+	assertions := response.childrenNamed(NamespaceAssertion, "Assertion")
+	if len(assertions) != 1 {
+		return nil, errors.New("saml: response must contain exactly one assertion")
+	}
+	assertion := assertions[0]
+
+	// Everything below is read from the same tree the signature covers, so
+	// wrapped or duplicated elements elsewhere in the document are ignored.
+	responseSigned := response.child(NamespaceDSig, "Signature") != nil
+	assertionSigned := assertion.child(NamespaceDSig, "Signature") != nil
+	if !responseSigned && !assertionSigned {
+		return nil, ErrNotSigned
+	}
+	if len(sp.IdP.Certificates) == 0 {
+		return nil, errors.New("saml: IdP certificate is not configured")
+	}
+	if responseSigned {
+		if err := verifyEnvelopedSignature(response, sp.IdP.Certificates); err != nil {
+			return nil, err
+		}
+	}
+	if assertionSigned {
+		if err := verifyEnvelopedSignature(assertion, sp.IdP.Certificates); err != nil {
+			return nil, err
+		}
+	}
+	return sp.validateAssertion(assertion, inResponseTo)
+}
+
+func (sp *ServiceProvider) validateAssertion(assertion *element, inResponseTo string) (*Assertion, error) {
+	now := sp.now()
+	skew := sp.clockSkew()
+	result := &Assertion{
+		ID:           assertion.attr("ID"),
+		InResponseTo: inResponseTo,
+		Issuer:       assertion.child(NamespaceAssertion, "Issuer").text(),
+		Attributes:   make(map[string][]string),
+	}
+	if result.ID == "" {
+		return nil, errors.New("saml: assertion has no ID")
+	}
+	if sp.IdP.EntityID != "" && result.Issuer != sp.IdP.EntityID {
+		return nil, fmt.Errorf("saml: unexpected assertion issuer %q", result.Issuer)
+	}
+
+	subject := assertion.child(NamespaceAssertion, "Subject")
+	nameID := subject.child(NamespaceAssertion, "NameID")
+	result.NameID = nameID.text()
+	result.NameIDFormat = nameID.attr("Format")
+	if result.NameID == "" {
+		return nil, errors.New("saml: assertion has no NameID")
+	}
+	confirmed := false
+	for _, confirmation := range subject.childrenNamed(NamespaceAssertion, "SubjectConfirmation") {
+		if confirmation.attr("Method") != subjectConfirmationBearer {
+			continue
+		}
+		data := confirmation.child(NamespaceAssertion, "SubjectConfirmationData")
+		if data.attr("Recipient") != sp.ACSURL {
+			continue
+		}
+		if irt := data.attr("InResponseTo"); irt != "" && irt != inResponseTo {
+			continue
+		}
+		notOnOrAfter, err := parseTime(data.attr("NotOnOrAfter"))
+		if err != nil || !now.Before(notOnOrAfter.Add(skew)) {
+			continue
+		}
+		confirmed = true
+		result.ExpiresAt = notOnOrAfter.Add(skew)
+		break
+	}
+	if !confirmed {
+		return nil, errors.New("saml: no valid bearer subject confirmation for this service provider")
+	}
+
+	if conditions := assertion.child(NamespaceAssertion, "Conditions"); conditions != nil {
+		if value := conditions.attr("NotBefore"); value != "" {
+			notBefore, err := parseTime(value)
+			if err != nil || now.Add(skew).Before(notBefore) {
+				return nil, errors.New("saml: assertion is not yet valid")
+			}
+		}
+		if value := conditions.attr("NotOnOrAfter"); value != "" {
+			notOnOrAfter, err := parseTime(value)
+			if err != nil || !now.Before(notOnOrAfter.Add(skew)) {
+				return nil, errors.New("saml: assertion has expired")
+			}
+			if notOnOrAfter.Add(skew).After(result.ExpiresAt) {
+				result.ExpiresAt = notOnOrAfter.Add(skew)
+			}
+		}
+		for _, restriction := range conditions.childrenNamed(NamespaceAssertion, "AudienceRestriction") {
+			matched := false
+			for _, audience := range restriction.childrenNamed(NamespaceAssertion, "Audience") {
+				if audience.text() == sp.EntityID {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, errors.New("saml: assertion audience does not include this service provider")
+			}
+		}
+	}
+
+	if statement := assertion.child(NamespaceAssertion, "AuthnStatement"); statement != nil {
+		result.SessionIndex = statement.attr("SessionIndex")
+	}
+	for _, statement := range assertion.childrenNamed(NamespaceAssertion, "AttributeStatement") {
+		for _, attribute := range statement.childrenNamed(NamespaceAssertion, "Attribute") {
+			var values []string
+			for _, value := range attribute.childrenNamed(NamespaceAssertion, "AttributeValue") {
+				if text := value.text(); text != "" {
+					values = append(values, text)
+				}
+			}
+			name, friendlyName := attribute.attr("Name"), attribute.attr("FriendlyName")
+			if name != "" {
+				result.Attributes[name] = append(result.Attributes[name], values...)
+			}
+			if friendlyName != "" && friendlyName != name {
+				result.Attributes[friendlyName] = append(result.Attributes[friendlyName], values...)
+			}
+		}
+	}
+	return result, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	return time.Parse(time.RFC3339, strings.TrimSpace(value))
+}
+
+// checkUniqueIDs rejects documents in which an ID value appears twice, which
+// would make signature references ambiguous.
+func checkUniqueIDs(root *element) error {
+	seen := make(map[string]bool)
+	for _, el := range root.descendants() {
+		id := el.attr("ID")
+		if id == "" {
+			continue
+		}
+		if seen[id] {
+			return fmt.Errorf("saml: duplicate ID %q", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
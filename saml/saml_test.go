@@ -0,0 +1,197 @@
+package saml_test
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/saml"
+	"github.com/QuantumNous/new-api/saml/samltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSPEntityID = "https://sp.example.com/api/saml/metadata"
+	testACSURL     = "https://sp.example.com/api/saml/acs"
+)
+
+func newTestPair(t *testing.T) (*saml.ServiceProvider, *samltest.IdP) {
+	t.Helper()
+	idp, err := samltest.New("https://idp.example.com", "https://idp.example.com/sso")
+	require.NoError(t, err)
+	key, certificate, err := samltest.NewKeyPair("sp")
+	require.NoError(t, err)
+	sp := &saml.ServiceProvider{
+		EntityID:     testSPEntityID,
+		ACSURL:       testACSURL,
+		NameIDFormat: saml.NameIDFormatPersistent,
+		Certificate:  certificate,
+		PrivateKey:   key,
+		IdP: saml.IdentityProvider{
+			EntityID:     idp.EntityID,
+			SSOURL:       idp.SSOURL,
+			Certificates: []*x509.Certificate{idp.Certificate},
+		},
+	}
+	return sp, idp
+}
+
+func validResponse() samltest.Response {
+	return samltest.Response{
+		InResponseTo: "_request-1",
+		ACSURL:       testACSURL,
+		Audience:     testSPEntityID,
+		NameID:       "user-123",
+		Attributes: map[string][]string{
+			"email":  {"alice@example.com"},
+			"groups": {"engineering", "admins"},
+		},
+	}
+}
+
+func TestParseResponseSignedAssertion(t *testing.T) {
+	sp, idp := newTestPair(t)
+	encoded, err := idp.Respond(validResponse())
+	require.NoError(t, err)
+
+	assertion, err := sp.ParseResponse(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, "user-123", assertion.NameID)
+	assert.Equal(t, "_request-1", assertion.InResponseTo)
+	assert.Equal(t, idp.EntityID, assertion.Issuer)
+	assert.Equal(t, "alice@example.com", assertion.Attribute("email"))
+	assert.Equal(t, []string{"engineering", "admins"}, assertion.Attributes["groups"])
+	assert.True(t, assertion.ExpiresAt.After(time.Now()))
+}
+
+func TestParseResponseSignedResponse(t *testing.T) {
+	sp, idp := newTestPair(t)
+	response := validResponse()
+	response.SignResponse = true
+	encoded, err := idp.Respond(response)
+	require.NoError(t, err)
+
+	assertion, err := sp.ParseResponse(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, "user-123", assertion.NameID)
+}
+
+func TestParseResponseRejectsTampering(t *testing.T) {
+	sp, idp := newTestPair(t)
+	document, err := idp.ResponseXML(validResponse())
+	require.NoError(t, err)
+
+	tampered := bytes.Replace(document, []byte("user-123"), []byte("admin-001"), 1)
+	_, err = sp.ParseResponse(base64.StdEncoding.EncodeToString(tampered))
+	assert.ErrorIs(t, err, saml.ErrInvalidSignature)
+}
+
+func TestParseResponseRejectsUntrustedSigner(t *testing.T) {
+	sp, _ := newTestPair(t)
+	other, err := samltest.New("https://idp.example.com", "https://idp.example.com/sso")
+	require.NoError(t, err)
+	encoded, err := other.Respond(validResponse())
+	require.NoError(t, err)
+
+	_, err = sp.ParseResponse(encoded)
+	assert.ErrorIs(t, err, saml.ErrInvalidSignature)
+}
+
+func TestParseResponseRejectsUnsigned(t *testing.T) {
+	sp, idp := newTestPair(t)
+	document, err := idp.ResponseXML(validResponse())
+	require.NoError(t, err)
+	start := bytes.Index(document, []byte("<ds:Signature"))
+	end := bytes.Index(document, []byte("</ds:Signature>")) + len("</ds:Signature>")
+	require.True(t, start > 0 && end > start)
+	unsigned := append(append([]byte{}, document[:start]...), document[end:]...)
+
+	_, err = sp.ParseResponse(base64.StdEncoding.EncodeToString(unsigned))
+	assert.ErrorIs(t, err, saml.ErrNotSigned)
+}
+
+func TestParseResponseRejectsWrappedAssertion(t *testing.T) {
+	sp, idp := newTestPair(t)
+	document, err := idp.ResponseXML(validResponse())
+	require.NoError(t, err)
+	// Smuggle an unsigned assertion next to the signed one.
+	forged := strings.Replace(string(document), "<saml:Assertion ",
+		`<saml:Assertion ID="_forged" Version="2.0"><saml:Issuer>https://idp.example.com</saml:Issuer></saml:Assertion><saml:Assertion `, 1)
+
+	_, err = sp.ParseResponse(base64.StdEncoding.EncodeToString([]byte(forged)))
+	assert.Error(t, err)
+}
+
+func TestParseResponseValidatesConditions(t *testing.T) {
+	sp, idp := newTestPair(t)
+
+	expired := validResponse()
+	expired.IssueInstant = time.Now().Add(-time.Hour)
+	encoded, err := idp.Respond(expired)
+	require.NoError(t, err)
+	_, err = sp.ParseResponse(encoded)
+	assert.Error(t, err)
+
+	wrongAudience := validResponse()
+	wrongAudience.Audience = "https://other-sp.example.com"
+	encoded, err = idp.Respond(wrongAudience)
+	require.NoError(t, err)
+	_, err = sp.ParseResponse(encoded)
+	assert.ErrorContains(t, err, "audience")
+
+	wrongRecipient := validResponse()
+	wrongRecipient.ACSURL = "https://other-sp.example.com/acs"
+	encoded, err = idp.Respond(wrongRecipient)
+	require.NoError(t, err)
+	_, err = sp.ParseResponse(encoded)
+	assert.Error(t, err)
+
+	failed := validResponse()
+	failed.Status = "urn:oasis:names:tc:SAML:2.0:status:Responder"
+	encoded, err = idp.Respond(failed)
+	require.NoError(t, err)
+	_, err = sp.ParseResponse(encoded)
+	assert.ErrorContains(t, err, "Responder")
+}
+
+func TestAuthnRequestURLIsSigned(t *testing.T) {
+	sp, _ := newTestPair(t)
+	redirect, err := sp.AuthnRequestURL("_request-1", "relay-state")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(redirect, "https://idp.example.com/sso?SAMLRequest="))
+
+	request, err := samltest.ParseAuthnRequest(redirect, sp.Certificate)
+	require.NoError(t, err)
+	assert.Equal(t, "_request-1", request.ID)
+	assert.Equal(t, testACSURL, request.AssertionConsumerServiceURL)
+	assert.Equal(t, testSPEntityID, request.Issuer)
+	assert.Equal(t, "relay-state", request.RelayState)
+
+	other, _, err := samltest.NewKeyPair("other")
+	require.NoError(t, err)
+	sp.PrivateKey = other
+	redirect, err = sp.AuthnRequestURL("_request-2", "")
+	require.NoError(t, err)
+	_, err = samltest.ParseAuthnRequest(redirect, sp.Certificate)
+	assert.Error(t, err)
+}
+
+func TestMetadataRoundTrip(t *testing.T) {
+	sp, idp := newTestPair(t)
+	metadata, err := sp.Metadata()
+	require.NoError(t, err)
+	assert.Contains(t, string(metadata), `entityID="`+testSPEntityID+`"`)
+	assert.Contains(t, string(metadata), `Location="`+testACSURL+`"`)
+	assert.Contains(t, string(metadata), `AuthnRequestsSigned="true"`)
+
+	parsed, err := saml.ParseIdPMetadata(idp.Metadata())
+	require.NoError(t, err)
+	assert.Equal(t, idp.EntityID, parsed.EntityID)
+	assert.Equal(t, idp.SSOURL, parsed.SSOURL)
+	require.Len(t, parsed.Certificates, 1)
+	assert.True(t, parsed.Certificates[0].Equal(idp.Certificate))
+}
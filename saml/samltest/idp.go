@@ -0,0 +1,258 @@
+// Package samltest provides a local SAML identity provider for tests: it
+// publishes metadata, checks the SP's signed AuthnRequests and issues signed
+// responses, so the SP can be exercised end to end without a real IdP.
+package samltest
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/saml"
+)
+
+// IdP is an in-process identity provider with its own signing key.
+type IdP struct {
+	EntityID    string
+	SSOURL      string
+	Key         *rsa.PrivateKey
+	Certificate *x509.Certificate
+}
+
+// New creates an IdP with a fresh RSA key and self-signed certificate.
+func New(entityID string, ssoURL string) (*IdP, error) {
+	key, certificate, err := NewKeyPair(entityID)
+	if err != nil {
+		return nil, err
+	}
+	return &IdP{EntityID: entityID, SSOURL: ssoURL, Key: key, Certificate: certificate}, nil
+}
+
+// NewKeyPair generates an RSA key and a matching self-signed certificate,
+// which is also what a test SP needs for signing AuthnRequests.
+func NewKeyPair(commonName string) (*rsa.PrivateKey, *x509.Certificate, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, certificate, nil
+}
+
+// CertificatePEM returns the signing certificate in PEM form.
+func CertificatePEM(certificate *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw}))
+}
+
+// PrivateKeyPEM returns key in PKCS#1 PEM form.
+func PrivateKeyPEM(key *rsa.PrivateKey) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+}
+
+// Metadata returns IdP metadata advertising the HTTP-Redirect SSO endpoint.
+func (idp *IdP) Metadata() []byte {
+	return []byte(`<md:EntityDescriptor xmlns:md="` + saml.NamespaceMetadata + `" entityID="` + xmlEscape(idp.EntityID) + `">` +
+		`<md:IDPSSODescriptor protocolSupportEnumeration="` + saml.NamespaceProtocol + `">` +
+		`<md:KeyDescriptor use="signing"><ds:KeyInfo xmlns:ds="` + saml.NamespaceDSig + `"><ds:X509Data><ds:X509Certificate>` +
+		base64.StdEncoding.EncodeToString(idp.Certificate.Raw) +
+		`</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>` +
+		`<md:SingleSignOnService Binding="` + saml.BindingHTTPRedirect + `" Location="` + xmlEscape(idp.SSOURL) + `"/>` +
+		`</md:IDPSSODescriptor></md:EntityDescriptor>`)
+}
+
+// AuthnRequest is what the IdP reads from an SP redirect.
+type AuthnRequest struct {
+	ID                          string `xml:"ID,attr"`
+	Destination                 string `xml:"Destination,attr"`
+	AssertionConsumerServiceURL string `xml:"AssertionConsumerServiceURL,attr"`
+	Issuer                      string `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+	RelayState                  string `xml:"-"`
+}
+
+// ParseAuthnRequest decodes an HTTP-Redirect AuthnRequest URL and verifies its
+// query-string signature against the SP certificate.
+func ParseAuthnRequest(redirectURL string, spCertificate *x509.Certificate) (*AuthnRequest, error) {
+	parsed, err := url.Parse(redirectURL)
+	if err != nil {
+		return nil, err
+	}
+	// Rebuild the signed octets from the raw (still encoded) parameters.
+	raw := make(map[string]string)
+	for _, part := range strings.Split(parsed.RawQuery, "&") {
+		if key, value, ok := strings.Cut(part, "="); ok {
+			raw[key] = value
+		}
+	}
+	signed := "SAMLRequest=" + raw["SAMLRequest"]
+	if raw["RelayState"] != "" {
+		signed += "&RelayState=" + raw["RelayState"]
+	}
+	signed += "&SigAlg=" + raw["SigAlg"]
+	query := parsed.Query()
+	if query.Get("SigAlg") != saml.AlgorithmRSASHA256 {
+		return nil, fmt.Errorf("unexpected SigAlg %q", query.Get("SigAlg"))
+	}
+	signature, err := base64.StdEncoding.DecodeString(query.Get("Signature"))
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := spCertificate.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("SP certificate is not RSA")
+	}
+	hashed := crypto.SHA256.New()
+	hashed.Write([]byte(signed))
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashed.Sum(nil), signature); err != nil {
+		return nil, fmt.Errorf("AuthnRequest signature: %w", err)
+	}
+	deflated, err := base64.StdEncoding.DecodeString(query.Get("SAMLRequest"))
+	if err != nil {
+		return nil, err
+	}
+	inflated, err := io.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	if err != nil {
+		return nil, err
+	}
+	var request AuthnRequest
+	if err := xml.Unmarshal(inflated, &request); err != nil {
+		return nil, err
+	}
+	request.RelayState = query.Get("RelayState")
+	return &request, nil
+}
+
+// Response describes the assertion the IdP should issue.
+type Response struct {
+	InResponseTo string
+	// ACSURL is used as Destination and subject confirmation Recipient.
+	ACSURL       string
+	Audience     string
+	NameID       string
+	NameIDFormat string
+	Attributes   map[string][]string
+	// IssueInstant defaults to now; the assertion is valid for Validity
+	// (five minutes by default) from then.
+	IssueInstant time.Time
+	Validity     time.Duration
+	// SignResponse signs the Response element instead of the Assertion.
+	SignResponse bool
+	// Status defaults to success.
+	Status string
+}
+
+// Respond returns a base64-encoded, signed SAMLResponse as it would be
+// posted to the ACS.
+func (idp *IdP) Respond(r Response) (string, error) {
+	document, err := idp.ResponseXML(r)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(document), nil
+}
+
+// ResponseXML returns the signed SAMLResponse document before encoding.
+func (idp *IdP) ResponseXML(r Response) ([]byte, error) {
+	if r.IssueInstant.IsZero() {
+		r.IssueInstant = time.Now()
+	}
+	if r.Validity == 0 {
+		r.Validity = 5 * time.Minute
+	}
+	if r.Status == "" {
+		r.Status = saml.StatusSuccess
+	}
+	if r.NameIDFormat == "" {
+		r.NameIDFormat = saml.NameIDFormatPersistent
+	}
+	issueInstant := r.IssueInstant.UTC().Format(time.RFC3339)
+	notBefore := r.IssueInstant.Add(-30 * time.Second).UTC().Format(time.RFC3339)
+	notOnOrAfter := r.IssueInstant.Add(r.Validity).UTC().Format(time.RFC3339)
+	responseID := newID()
+	assertionID := newID()
+
+	var attributes strings.Builder
+	if len(r.Attributes) > 0 {
+		names := make([]string, 0, len(r.Attributes))
+		for name := range r.Attributes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		attributes.WriteString(`<saml:AttributeStatement>`)
+		for _, name := range names {
+			attributes.WriteString(`<saml:Attribute Name="` + xmlEscape(name) + `">`)
+			for _, value := range r.Attributes[name] {
+				attributes.WriteString(`<saml:AttributeValue>` + xmlEscape(value) + `</saml:AttributeValue>`)
+			}
+			attributes.WriteString(`</saml:Attribute>`)
+		}
+		attributes.WriteString(`</saml:AttributeStatement>`)
+	}
+
+	document := `<samlp:Response xmlns:samlp="` + saml.NamespaceProtocol + `" xmlns:saml="` + saml.NamespaceAssertion + `"` +
+		` ID="` + responseID + `" Version="2.0" IssueInstant="` + issueInstant + `"` +
+		` Destination="` + xmlEscape(r.ACSURL) + `" InResponseTo="` + xmlEscape(r.InResponseTo) + `">` +
+		`<saml:Issuer>` + xmlEscape(idp.EntityID) + `</saml:Issuer>` +
+		`<samlp:Status><samlp:StatusCode Value="` + xmlEscape(r.Status) + `"/></samlp:Status>` +
+		`<saml:Assertion ID="` + assertionID + `" Version="2.0" IssueInstant="` + issueInstant + `">` +
+		`<saml:Issuer>` + xmlEscape(idp.EntityID) + `</saml:Issuer>` +
+		`<saml:Subject><saml:NameID Format="` + xmlEscape(r.NameIDFormat) + `">` + xmlEscape(r.NameID) + `</saml:NameID>` +
+		`<saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">` +
+		`<saml:SubjectConfirmationData InResponseTo="` + xmlEscape(r.InResponseTo) + `" NotOnOrAfter="` + notOnOrAfter + `" Recipient="` + xmlEscape(r.ACSURL) + `"/>` +
+		`</saml:SubjectConfirmation></saml:Subject>` +
+		`<saml:Conditions NotBefore="` + notBefore + `" NotOnOrAfter="` + notOnOrAfter + `">` +
+		`<saml:AudienceRestriction><saml:Audience>` + xmlEscape(r.Audience) + `</saml:Audience></saml:AudienceRestriction>` +
+		`</saml:Conditions>` +
+		`<saml:AuthnStatement AuthnInstant="` + issueInstant + `" SessionIndex="` + assertionID + `">` +
+		`<saml:AuthnContext><saml:AuthnContextClassRef>urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport</saml:AuthnContextClassRef></saml:AuthnContext>` +
+		`</saml:AuthnStatement>` +
+		attributes.String() +
+		`</saml:Assertion></samlp:Response>`
+
+	signedID := assertionID
+	if r.SignResponse {
+		signedID = responseID
+	}
+	return saml.SignEnveloped([]byte(document), signedID, idp.Key, idp.Certificate)
+}
+
+func newID() string {
+	random := make([]byte, 16)
+	_, _ = rand.Read(random)
+	return "_" + hex.EncodeToString(random)
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,233 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	// Register the digests referenced by the XML-DSig algorithm URIs.
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+const (
+	NamespaceDSig = "http://www.w3.org/2000/09/xmldsig#"
+
+	AlgorithmRSASHA1   = "http://www.w3.org/2000/09/xmldsig#rsa-sha1"
+	AlgorithmRSASHA256 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	AlgorithmRSASHA512 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"
+
+	algorithmSHA1                = "http://www.w3.org/2000/09/xmldsig#sha1"
+	algorithmSHA256              = "http://www.w3.org/2001/04/xmlenc#sha256"
+	algorithmSHA512              = "http://www.w3.org/2001/04/xmlenc#sha512"
+	algorithmEnveloped           = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	algorithmExcC14N             = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	algorithmExcC14NWithComments = "http://www.w3.org/2001/10/xml-exc-c14n#WithComments"
+)
+
+var (
+	ErrNotSigned        = errors.New("saml: element is not signed")
+	ErrInvalidSignature = errors.New("saml: signature verification failed")
+)
+
+var signatureHashes = map[string]crypto.Hash{
+	AlgorithmRSASHA1:   crypto.SHA1,
+	AlgorithmRSASHA256: crypto.SHA256,
+	AlgorithmRSASHA512: crypto.SHA512,
+}
+
+var digestHashes = map[string]crypto.Hash{
+	algorithmSHA1:   crypto.SHA1,
+	algorithmSHA256: crypto.SHA256,
+	algorithmSHA512: crypto.SHA512,
+}
+
+// decodeBase64 decodes base64 content that may be wrapped across lines.
+func decodeBase64(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+}
+
+// inclusivePrefixes reads the PrefixList of an InclusiveNamespaces child.
+func inclusivePrefixes(method *element) []string {
+	inclusive := method.child(namespaceExcC14N, "InclusiveNamespaces")
+	if inclusive == nil {
+		return nil
+	}
+	var prefixes []string
+	for _, prefix := range strings.Fields(inclusive.attr("PrefixList")) {
+		if prefix == "#default" {
+			prefix = ""
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes
+}
+
+// verifyEnvelopedSignature checks the enveloped XML signature that is a
+// direct child of el against the trusted certificates. Only the profile SAML
+// IdPs use is accepted: one Reference to el's ID, the enveloped-signature
+// transform and exclusive canonicalization. Keys embedded in KeyInfo are
+// never trusted.
+func verifyEnvelopedSignature(el *element, certificates []*x509.Certificate) error {
+	signature := el.child(NamespaceDSig, "Signature")
+	if signature == nil {
+		return ErrNotSigned
+	}
+	signedInfo := signature.child(NamespaceDSig, "SignedInfo")
+	if signedInfo == nil {
+		return errors.New("saml: signature has no SignedInfo")
+	}
+	c14nMethod := signedInfo.child(NamespaceDSig, "CanonicalizationMethod")
+	if algorithm := c14nMethod.attr("Algorithm"); algorithm != algorithmExcC14N && algorithm != algorithmExcC14NWithComments {
+		return fmt.Errorf("saml: unsupported canonicalization method %q", algorithm)
+	}
+	signatureAlgorithm := signedInfo.child(NamespaceDSig, "SignatureMethod").attr("Algorithm")
+	signatureHash, ok := signatureHashes[signatureAlgorithm]
+	if !ok {
+		return fmt.Errorf("saml: unsupported signature method %q", signatureAlgorithm)
+	}
+
+	references := signedInfo.childrenNamed(NamespaceDSig, "Reference")
+	if len(references) != 1 {
+		return errors.New("saml: signature must have exactly one reference")
+	}
+	reference := references[0]
+	id := el.attr("ID")
+	if id == "" || reference.attr("URI") != "#"+id {
+		return errors.New("saml: signature does not reference the signed element")
+	}
+	enveloped := false
+	var prefixes []string
+	hasC14N := false
+	for _, transform := range reference.child(NamespaceDSig, "Transforms").childrenNamed(NamespaceDSig, "Transform") {
+		switch transform.attr("Algorithm") {
+		case algorithmEnveloped:
+			enveloped = true
+		case algorithmExcC14N, algorithmExcC14NWithComments:
+			hasC14N = true
+			prefixes = inclusivePrefixes(transform)
+		default:
+			return fmt.Errorf("saml: unsupported transform %q", transform.attr("Algorithm"))
+		}
+	}
+	if !enveloped || !hasC14N {
+		return errors.New("saml: signature must use the enveloped-signature and exclusive c14n transforms")
+	}
+	digestAlgorithm := reference.child(NamespaceDSig, "DigestMethod").attr("Algorithm")
+	digestHash, ok := digestHashes[digestAlgorithm]
+	if !ok {
+		return fmt.Errorf("saml: unsupported digest method %q", digestAlgorithm)
+	}
+	expectedDigest, err := decodeBase64(reference.child(NamespaceDSig, "DigestValue").text())
+	if err != nil {
+		return fmt.Errorf("saml: invalid digest value: %w", err)
+	}
+	canonical, err := canonicalize(el, signature, prefixes)
+	if err != nil {
+		return err
+	}
+	digest := digestHash.New()
+	digest.Write(canonical)
+	if subtle.ConstantTimeCompare(digest.Sum(nil), expectedDigest) != 1 {
+		return ErrInvalidSignature
+	}
+
+	canonicalSignedInfo, err := canonicalize(signedInfo, nil, inclusivePrefixes(c14nMethod))
+	if err != nil {
+		return err
+	}
+	signatureValue, err := decodeBase64(signature.child(NamespaceDSig, "SignatureValue").text())
+	if err != nil {
+		return fmt.Errorf("saml: invalid signature value: %w", err)
+	}
+	hashed := signatureHash.New()
+	hashed.Write(canonicalSignedInfo)
+	sum := hashed.Sum(nil)
+	for _, certificate := range certificates {
+		publicKey, ok := certificate.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			continue
+		}
+		if rsa.VerifyPKCS1v15(publicKey, signatureHash, sum, signatureValue) == nil {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// SignEnveloped signs the element of document whose ID attribute is id with
+// an enveloped RSA-SHA256 signature and returns the document in canonical
+// form. It is the IdP-side counterpart of the verification above and backs
+// the samltest identity provider.
+func SignEnveloped(document []byte, id string, key *rsa.PrivateKey, certificate *x509.Certificate) ([]byte, error) {
+	root, err := parseXML(document)
+	if err != nil {
+		return nil, err
+	}
+	var target *element
+	for _, el := range root.descendants() {
+		if el.attr("ID") == id {
+			target = el
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("saml: no element with ID %q", id)
+	}
+	if err := signEnveloped(target, key, certificate); err != nil {
+		return nil, err
+	}
+	return canonicalize(root, nil, nil)
+}
+
+// signEnveloped adds an enveloped RSA-SHA256 signature over el, placed after
+// its Issuer child as SAML requires.
+func signEnveloped(el *element, key *rsa.PrivateKey, certificate *x509.Certificate) error {
+	id := el.attr("ID")
+	if id == "" {
+		return errors.New("saml: element to sign has no ID")
+	}
+	canonical, err := canonicalize(el, nil, nil)
+	if err != nil {
+		return err
+	}
+	digest := crypto.SHA256.New()
+	digest.Write(canonical)
+
+	signatureXML := `<ds:Signature xmlns:ds="` + NamespaceDSig + `"><ds:SignedInfo>` +
+		`<ds:CanonicalizationMethod Algorithm="` + algorithmExcC14N + `"/>` +
+		`<ds:SignatureMethod Algorithm="` + AlgorithmRSASHA256 + `"/>` +
+		`<ds:Reference URI="#` + escapeAttr(id) + `"><ds:Transforms>` +
+		`<ds:Transform Algorithm="` + algorithmEnveloped + `"/>` +
+		`<ds:Transform Algorithm="` + algorithmExcC14N + `"/>` +
+		`</ds:Transforms><ds:DigestMethod Algorithm="` + algorithmSHA256 + `"/>` +
+		`<ds:DigestValue>` + base64.StdEncoding.EncodeToString(digest.Sum(nil)) + `</ds:DigestValue>` +
+		`</ds:Reference></ds:SignedInfo><ds:SignatureValue></ds:SignatureValue>` +
+		`<ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + base64.StdEncoding.EncodeToString(certificate.Raw) +
+		`</ds:X509Certificate></ds:X509Data></ds:KeyInfo></ds:Signature>`
+	signature, err := parseXML([]byte(signatureXML))
+	if err != nil {
+		return err
+	}
+	el.insertChildAfter(signature, NamespaceAssertion, "Issuer")
+
+	canonicalSignedInfo, err := canonicalize(signature.child(NamespaceDSig, "SignedInfo"), nil, nil)
+	if err != nil {
+		return err
+	}
+	hashed := crypto.SHA256.New()
+	hashed.Write(canonicalSignedInfo)
+	value, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed.Sum(nil))
+	if err != nil {
+		return err
+	}
+	signature.child(NamespaceDSig, "SignatureValue").children = []any{base64.StdEncoding.EncodeToString(value)}
+	return nil
+}
@@ -0,0 +1,341 @@
+package saml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	namespaceXML     = "http://www.w3.org/XML/1998/namespace"
+	namespaceExcC14N = "http://www.w3.org/2001/10/xml-exc-c14n#"
+)
+
+// element is a minimal XML tree that keeps namespace prefixes and
+// declarations exactly as they appear in the document, which encoding/xml's
+// namespace-resolving decoder does not. Signature verification needs the
+// original prefixes to reproduce the canonical form the IdP signed.
+type element struct {
+	prefix   string
+	local    string
+	nsDecls  []xml.Attr // Name.Local is the declared prefix ("" for the default namespace)
+	attrs    []xml.Attr // Name.Space is the raw attribute prefix
+	children []any      // *element or string
+	parent   *element
+}
+
+// parseXML builds an element tree. Comments and processing instructions are
+// dropped and DTDs are rejected outright.
+func parseXML(data []byte) (*element, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var root, current *element
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			el := &element{prefix: t.Name.Space, local: t.Name.Local, parent: current}
+			for _, attr := range t.Attr {
+				switch {
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					el.nsDecls = append(el.nsDecls, xml.Attr{Value: attr.Value})
+				case attr.Name.Space == "xmlns":
+					el.nsDecls = append(el.nsDecls, xml.Attr{Name: xml.Name{Local: attr.Name.Local}, Value: attr.Value})
+				default:
+					el.attrs = append(el.attrs, attr)
+				}
+			}
+			if current == nil {
+				if root != nil {
+					return nil, errors.New("saml: document has more than one root element")
+				}
+				root = el
+			} else {
+				current.children = append(current.children, el)
+			}
+			current = el
+		case xml.EndElement:
+			if current == nil || t.Name.Space != current.prefix || t.Name.Local != current.local {
+				return nil, errors.New("saml: mismatched end element")
+			}
+			current = current.parent
+		case xml.CharData:
+			if current != nil {
+				current.children = append(current.children, string(t))
+			} else if len(bytes.TrimSpace(t)) > 0 {
+				return nil, errors.New("saml: text outside the root element")
+			}
+		case xml.Directive:
+			return nil, errors.New("saml: DTDs are not allowed")
+		}
+	}
+	if root == nil || current != nil {
+		return nil, errors.New("saml: incomplete XML document")
+	}
+	for _, el := range root.descendants() {
+		if _, ok := el.lookupNamespace(el.prefix); !ok {
+			return nil, fmt.Errorf("saml: undeclared namespace prefix %q", el.prefix)
+		}
+	}
+	return root, nil
+}
+
+func (e *element) lookupNamespace(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return namespaceXML, true
+	}
+	for n := e; n != nil; n = n.parent {
+		for _, decl := range n.nsDecls {
+			if decl.Name.Local == prefix {
+				return decl.Value, true
+			}
+		}
+	}
+	// The default namespace is always in scope, possibly as "no namespace".
+	return "", prefix == ""
+}
+
+func (e *element) namespace() string {
+	ns, _ := e.lookupNamespace(e.prefix)
+	return ns
+}
+
+func (e *element) is(namespace string, local string) bool {
+	return e != nil && e.local == local && e.namespace() == namespace
+}
+
+func (e *element) childElements() []*element {
+	var result []*element
+	for _, child := range e.children {
+		if el, ok := child.(*element); ok {
+			result = append(result, el)
+		}
+	}
+	return result
+}
+
+// child returns the first direct child with the given name, or nil.
+func (e *element) child(namespace string, local string) *element {
+	if e == nil {
+		return nil
+	}
+	for _, el := range e.childElements() {
+		if el.is(namespace, local) {
+			return el
+		}
+	}
+	return nil
+}
+
+func (e *element) childrenNamed(namespace string, local string) []*element {
+	if e == nil {
+		return nil
+	}
+	var result []*element
+	for _, el := range e.childElements() {
+		if el.is(namespace, local) {
+			result = append(result, el)
+		}
+	}
+	return result
+}
+
+// attr returns the value of an unprefixed attribute.
+func (e *element) attr(name string) string {
+	if e == nil {
+		return ""
+	}
+	for _, attr := range e.attrs {
+		if attr.Name.Space == "" && attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// text returns the trimmed character data directly inside the element.
+func (e *element) text() string {
+	if e == nil {
+		return ""
+	}
+	var sb strings.Builder
+	for _, child := range e.children {
+		if s, ok := child.(string); ok {
+			sb.WriteString(s)
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// descendants returns the element and every element below it in document order.
+func (e *element) descendants() []*element {
+	result := []*element{e}
+	for _, el := range e.childElements() {
+		result = append(result, el.descendants()...)
+	}
+	return result
+}
+
+// insertChildAfter inserts child right after the first direct child named
+// namespace/local, or at the front when there is no such child.
+func (e *element) insertChildAfter(child *element, namespace string, local string) {
+	child.parent = e
+	position := 0
+	for i, c := range e.children {
+		if el, ok := c.(*element); ok && el.is(namespace, local) {
+			position = i + 1
+			break
+		}
+	}
+	e.children = append(e.children[:position], append([]any{child}, e.children[position:]...)...)
+}
+
+func qualifiedName(prefix string, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
+
+// canonicalize serializes the subtree rooted at e using Exclusive XML
+// Canonicalization 1.0 without comments. exclude (typically an enveloped
+// Signature) is left out of the output; inclusivePrefixes is the
+// InclusiveNamespaces PrefixList of the transform.
+func canonicalize(e *element, exclude *element, inclusivePrefixes []string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, e, exclude, map[string]string{}, inclusivePrefixes); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type namespaceDecl struct {
+	prefix string
+	uri    string
+}
+
+func writeCanonical(buf *bytes.Buffer, e *element, exclude *element, rendered map[string]string, inclusivePrefixes []string) error {
+	// Namespaces are rendered where they are visibly utilized: by the element
+	// itself or one of its prefixed attributes, plus the inclusive prefixes.
+	required := []string{e.prefix}
+	for _, attr := range e.attrs {
+		if attr.Name.Space != "" {
+			required = append(required, attr.Name.Space)
+		}
+	}
+	candidates := make([]string, 0, len(required)+len(inclusivePrefixes))
+	candidates = append(candidates, required...)
+	candidates = append(candidates, inclusivePrefixes...)
+
+	var decls []namespaceDecl
+	seen := make(map[string]bool)
+	for i, prefix := range candidates {
+		if seen[prefix] || prefix == "xml" {
+			continue
+		}
+		seen[prefix] = true
+		uri, ok := e.lookupNamespace(prefix)
+		if !ok {
+			if i < len(required) {
+				return fmt.Errorf("saml: undeclared namespace prefix %q", prefix)
+			}
+			continue
+		}
+		previous, hadPrevious := rendered[prefix]
+		if prefix == "" && uri == "" && !hadPrevious {
+			continue
+		}
+		if hadPrevious && previous == uri {
+			continue
+		}
+		decls = append(decls, namespaceDecl{prefix: prefix, uri: uri})
+	}
+	sort.Slice(decls, func(i, j int) bool { return decls[i].prefix < decls[j].prefix })
+
+	type canonicalAttr struct {
+		namespace string
+		name      xml.Attr
+	}
+	attrs := make([]canonicalAttr, 0, len(e.attrs))
+	for _, attr := range e.attrs {
+		ns := ""
+		if attr.Name.Space != "" {
+			ns, _ = e.lookupNamespace(attr.Name.Space)
+		}
+		attrs = append(attrs, canonicalAttr{namespace: ns, name: attr})
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].namespace != attrs[j].namespace {
+			return attrs[i].namespace < attrs[j].namespace
+		}
+		return attrs[i].name.Name.Local < attrs[j].name.Name.Local
+	})
+
+	name := qualifiedName(e.prefix, e.local)
+	buf.WriteByte('<')
+	buf.WriteString(name)
+	childRendered := rendered
+	if len(decls) > 0 {
+		childRendered = make(map[string]string, len(rendered)+len(decls))
+		for k, v := range rendered {
+			childRendered[k] = v
+		}
+	}
+	for _, decl := range decls {
+		if decl.prefix == "" {
+			buf.WriteString(` xmlns="`)
+		} else {
+			buf.WriteString(` xmlns:` + decl.prefix + `="`)
+		}
+		buf.WriteString(escapeAttr(decl.uri))
+		buf.WriteByte('"')
+		childRendered[decl.prefix] = decl.uri
+	}
+	for _, attr := range attrs {
+		buf.WriteByte(' ')
+		buf.WriteString(qualifiedName(attr.name.Name.Space, attr.name.Name.Local))
+		buf.WriteString(`="`)
+		buf.WriteString(escapeAttr(attr.name.Value))
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('>')
+	for _, child := range e.children {
+		switch c := child.(type) {
+		case string:
+			buf.WriteString(escapeText(c))
+		case *element:
+			if c == exclude {
+				continue
+			}
+			if err := writeCanonical(buf, c, exclude, childRendered, inclusivePrefixes); err != nil {
+				return err
+			}
+		}
+	}
+	buf.WriteString("</")
+	buf.WriteString(name)
+	buf.WriteByte('>')
+	return nil
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func escapeAttr(s string) string {
+	return attrEscaper.Replace(s)
+}
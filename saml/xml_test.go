@@ -0,0 +1,36 @@
+package saml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalizeExclusive(t *testing.T) {
+	root, err := parseXML([]byte(`<?xml version="1.0"?>
+<root xmlns="urn:default" xmlns:a="urn:a" xmlns:unused="urn:unused"><a:child z="1" a:attr='x"y' b="2">text &amp; &gt; more<!-- dropped --></a:child><plain/></root>`))
+	require.NoError(t, err)
+
+	out, err := canonicalize(root, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, `<root xmlns="urn:default"><a:child xmlns:a="urn:a" b="2" z="1" a:attr="x&quot;y">text &amp; &gt; more</a:child><plain></plain></root>`, string(out))
+
+	child := root.childElements()[0]
+	out, err = canonicalize(child, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, `<a:child xmlns:a="urn:a" b="2" z="1" a:attr="x&quot;y">text &amp; &gt; more</a:child>`, string(out))
+
+	out, err = canonicalize(child, nil, []string{"unused"})
+	require.NoError(t, err)
+	assert.Equal(t, `<a:child xmlns:a="urn:a" xmlns:unused="urn:unused" b="2" z="1" a:attr="x&quot;y">text &amp; &gt; more</a:child>`, string(out))
+
+	out, err = canonicalize(root, root.childElements()[1], nil)
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "plain")
+}
+
+func TestParseXMLRejectsDTD(t *testing.T) {
+	_, err := parseXML([]byte(`<!DOCTYPE r [<!ENTITY x "y">]><r>&x;</r>`))
+	assert.Error(t, err)
+}
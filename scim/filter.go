@@ -0,0 +1,37 @@
+package scim
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrUnsupportedFilter = errors.New("only filters of the form `attribute eq \"value\"` are supported")
+
+// Filter is a single equality filter such as `userName eq "alice"`, which is
+// what identity providers send when they look up an existing resource.
+type Filter struct {
+	Attribute string
+	Value     string
+}
+
+// ParseFilter parses an equality filter. An empty expression yields a nil
+// filter. Attribute names are case-insensitive and returned lowercased.
+func ParseFilter(expr string) (*Filter, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, nil
+	}
+	parts := strings.SplitN(expr, " ", 3)
+	if len(parts) != 3 || !strings.EqualFold(parts[1], "eq") {
+		return nil, ErrUnsupportedFilter
+	}
+	value := strings.TrimSpace(parts[2])
+	if len(value) < 2 || !strings.HasPrefix(value, `"`) || !strings.HasSuffix(value, `"`) {
+		return nil, ErrUnsupportedFilter
+	}
+	value = strings.ReplaceAll(value[1:len(value)-1], `\"`, `"`)
+	return &Filter{
+		Attribute: strings.ToLower(strings.TrimSpace(parts[0])),
+		Value:     value,
+	}, nil
+}
@@ -0,0 +1,233 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// memberPathPattern matches `members[value eq "42"]`, the path form used to
+// remove a single group member.
+var memberPathPattern = regexp.MustCompile(`^members\[\s*value\s+eq\s+"([^"]*)"\s*\]$`)
+
+// ApplyUserPatch applies PATCH operations to a user representation. Both the
+// path form (`{"op":"replace","path":"active","value":false}`) and the
+// path-less form (`{"op":"replace","value":{"active":false}}`) are accepted.
+func ApplyUserPatch(user *User, ops []PatchOperation) error {
+	for _, op := range ops {
+		kind := strings.ToLower(op.Op)
+		if kind != "add" && kind != "replace" && kind != "remove" {
+			return fmt.Errorf("unsupported patch op %q", op.Op)
+		}
+		if op.Path == "" {
+			if kind == "remove" {
+				return errors.New("remove requires a path")
+			}
+			var attrs map[string]json.RawMessage
+			if err := common.Unmarshal(op.Value, &attrs); err != nil {
+				return fmt.Errorf("invalid patch value: %w", err)
+			}
+			for attr, value := range attrs {
+				if err := setUserAttribute(user, attr, value); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if kind == "remove" {
+			if err := setUserAttribute(user, op.Path, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := setUserAttribute(user, op.Path, op.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setUserAttribute sets one attribute; a nil value clears it. Attributes this
+// server does not store are ignored, as RFC 7644 allows.
+func setUserAttribute(user *User, path string, value json.RawMessage) error {
+	decode := func(v any) error {
+		if value == nil {
+			return nil
+		}
+		return common.Unmarshal(value, v)
+	}
+	switch strings.ToLower(path) {
+	case "username":
+		user.UserName = ""
+		return decode(&user.UserName)
+	case "externalid":
+		user.ExternalId = ""
+		return decode(&user.ExternalId)
+	case "displayname":
+		user.DisplayName = ""
+		return decode(&user.DisplayName)
+	case "active":
+		if value == nil {
+			user.Active = nil
+			return nil
+		}
+		active, err := decodeBool(value)
+		if err != nil {
+			return err
+		}
+		user.Active = &active
+	case "name":
+		user.Name = nil
+		if value != nil {
+			user.Name = &Name{}
+			return decode(user.Name)
+		}
+	case "name.givenname":
+		if user.Name == nil {
+			user.Name = &Name{}
+		}
+		user.Name.GivenName = ""
+		return decode(&user.Name.GivenName)
+	case "name.familyname":
+		if user.Name == nil {
+			user.Name = &Name{}
+		}
+		user.Name.FamilyName = ""
+		return decode(&user.Name.FamilyName)
+	case "name.formatted":
+		if user.Name == nil {
+			user.Name = &Name{}
+		}
+		user.Name.Formatted = ""
+		return decode(&user.Name.Formatted)
+	case "emails":
+		user.Emails = nil
+		return decode(&user.Emails)
+	case `emails[type eq "work"].value`, `emails[primary eq true].value`:
+		var email string
+		if err := decode(&email); err != nil {
+			return err
+		}
+		user.Emails = nil
+		if email != "" {
+			user.Emails = []MultiValue{{Value: email, Type: "work", Primary: true}}
+		}
+	}
+	return nil
+}
+
+// decodeBool accepts JSON booleans and the string forms some IdPs send.
+func decodeBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := common.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := common.Unmarshal(value, &s); err != nil {
+		return false, fmt.Errorf("invalid boolean value: %s", string(value))
+	}
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean value: %s", s)
+}
+
+// GroupPatch is the effect of a group PATCH request on membership.
+type GroupPatch struct {
+	DisplayName    string
+	ReplaceMembers bool
+	Members        []string // replacement set when ReplaceMembers is true
+	Add            []string
+	Remove         []string
+}
+
+// ParseGroupPatch interprets group PATCH operations as membership changes.
+func ParseGroupPatch(ops []PatchOperation) (GroupPatch, error) {
+	var patch GroupPatch
+	for _, op := range ops {
+		kind := strings.ToLower(op.Op)
+		path := strings.TrimSpace(op.Path)
+		lowerPath := strings.ToLower(path)
+		switch {
+		case lowerPath == "" && (kind == "add" || kind == "replace"):
+			var attrs struct {
+				DisplayName string       `json:"displayName"`
+				Members     []MultiValue `json:"members"`
+			}
+			if err := common.Unmarshal(op.Value, &attrs); err != nil {
+				return patch, fmt.Errorf("invalid patch value: %w", err)
+			}
+			if attrs.DisplayName != "" {
+				patch.DisplayName = attrs.DisplayName
+			}
+			if attrs.Members != nil {
+				if kind == "replace" {
+					patch.ReplaceMembers = true
+					patch.Members = memberValues(attrs.Members)
+				} else {
+					patch.Add = append(patch.Add, memberValues(attrs.Members)...)
+				}
+			}
+		case lowerPath == "displayname" && (kind == "add" || kind == "replace"):
+			if err := common.Unmarshal(op.Value, &patch.DisplayName); err != nil {
+				return patch, fmt.Errorf("invalid displayName: %w", err)
+			}
+		case lowerPath == "members":
+			var members []MultiValue
+			if len(op.Value) > 0 {
+				if err := common.Unmarshal(op.Value, &members); err != nil {
+					return patch, fmt.Errorf("invalid members: %w", err)
+				}
+			}
+			switch kind {
+			case "add":
+				patch.Add = append(patch.Add, memberValues(members)...)
+			case "replace":
+				patch.ReplaceMembers = true
+				patch.Members = memberValues(members)
+			case "remove":
+				if len(members) == 0 {
+					patch.ReplaceMembers = true
+					patch.Members = nil
+				} else {
+					patch.Remove = append(patch.Remove, memberValues(members)...)
+				}
+			default:
+				return patch, fmt.Errorf("unsupported patch op %q", op.Op)
+			}
+		case kind == "remove" && memberPathPattern.MatchString(path):
+			patch.Remove = append(patch.Remove, memberPathPattern.FindStringSubmatch(path)[1])
+		default:
+			return patch, fmt.Errorf("unsupported patch operation %q on %q", op.Op, op.Path)
+		}
+	}
+	return patch, nil
+}
+
+func memberValues(members []MultiValue) []string {
+	values := make([]string, 0, len(members))
+	for _, member := range members {
+		if member.Value != "" {
+			values = append(values, member.Value)
+		}
+	}
+	return values
+}
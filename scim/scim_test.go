@@ -0,0 +1,98 @@
+package scim
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	filter, err := ParseFilter(`userName eq "alice@example.com"`)
+	require.NoError(t, err)
+	assert.Equal(t, "username", filter.Attribute)
+	assert.Equal(t, "alice@example.com", filter.Value)
+
+	filter, err = ParseFilter("")
+	require.NoError(t, err)
+	assert.Nil(t, filter)
+
+	_, err = ParseFilter(`userName co "alice"`)
+	assert.ErrorIs(t, err, ErrUnsupportedFilter)
+	_, err = ParseFilter(`userName eq alice`)
+	assert.ErrorIs(t, err, ErrUnsupportedFilter)
+}
+
+func TestParsePagination(t *testing.T) {
+	startIndex, offset, count := ParsePagination("", "")
+	assert.Equal(t, 1, startIndex)
+	assert.Equal(t, 0, offset)
+	assert.Equal(t, 100, count)
+
+	startIndex, offset, count = ParsePagination("11", "1000")
+	assert.Equal(t, 11, startIndex)
+	assert.Equal(t, 10, offset)
+	assert.Equal(t, MaxPageSize, count)
+}
+
+func decodePatch(t *testing.T, body string) []PatchOperation {
+	t.Helper()
+	var req PatchRequest
+	require.NoError(t, common.UnmarshalJsonStr(body, &req))
+	return req.Operations
+}
+
+func TestApplyUserPatch_PathForm(t *testing.T) {
+	user := User{UserName: "alice"}
+	ops := decodePatch(t, `{"Operations":[
+		{"op":"replace","path":"active","value":false},
+		{"op":"replace","path":"name.givenName","value":"Alice"},
+		{"op":"replace","path":"emails[type eq \"work\"].value","value":"alice@example.com"}
+	]}`)
+
+	require.NoError(t, ApplyUserPatch(&user, ops))
+	assert.False(t, user.IsActive())
+	assert.Equal(t, "Alice", user.Name.GivenName)
+	assert.Equal(t, "alice@example.com", user.PrimaryEmail())
+}
+
+func TestApplyUserPatch_ValueFormWithStringBoolean(t *testing.T) {
+	user := User{UserName: "alice"}
+	ops := decodePatch(t, `{"Operations":[{"op":"Replace","value":{"active":"False","displayName":"Alice A"}}]}`)
+
+	require.NoError(t, ApplyUserPatch(&user, ops))
+	assert.False(t, user.IsActive())
+	assert.Equal(t, "Alice A", user.DisplayName)
+}
+
+func TestApplyUserPatch_RejectsUnknownOp(t *testing.T) {
+	user := User{}
+	ops := decodePatch(t, `{"Operations":[{"op":"move","path":"active","value":true}]}`)
+	assert.Error(t, ApplyUserPatch(&user, ops))
+}
+
+func TestParseGroupPatch(t *testing.T) {
+	ops := decodePatch(t, `{"Operations":[
+		{"op":"add","path":"members","value":[{"value":"1"},{"value":"2"}]},
+		{"op":"remove","path":"members[value eq \"3\"]"}
+	]}`)
+
+	patch, err := ParseGroupPatch(ops)
+	require.NoError(t, err)
+	assert.False(t, patch.ReplaceMembers)
+	assert.Equal(t, []string{"1", "2"}, patch.Add)
+	assert.Equal(t, []string{"3"}, patch.Remove)
+}
+
+func TestParseGroupPatch_ReplaceAndClear(t *testing.T) {
+	patch, err := ParseGroupPatch(decodePatch(t, `{"Operations":[{"op":"replace","value":{"members":[{"value":"7"}]}}]}`))
+	require.NoError(t, err)
+	assert.True(t, patch.ReplaceMembers)
+	assert.Equal(t, []string{"7"}, patch.Members)
+
+	patch, err = ParseGroupPatch(decodePatch(t, `{"Operations":[{"op":"remove","path":"members"}]}`))
+	require.NoError(t, err)
+	assert.True(t, patch.ReplaceMembers)
+	assert.Empty(t, patch.Members)
+}
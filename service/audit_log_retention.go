@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	auditLogRetentionInterval  = time.Hour
+	auditLogRetentionBatchSize = 1000
+)
+
+var auditLogRetentionOnce sync.Once
+
+// StartAuditLogRetentionTask 按 audit_log_setting.retention_days 定期清理过期的
+// 审计日志。审计日志不参与 log_cleanup 系统任务，只由本任务按自身保留期删除。
+func StartAuditLogRetentionTask() {
+	auditLogRetentionOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			ticker := time.NewTicker(auditLogRetentionInterval)
+			defer ticker.Stop()
+
+			runAuditLogRetentionOnce()
+			for range ticker.C {
+				runAuditLogRetentionOnce()
+			}
+		})
+	})
+}
+
+func runAuditLogRetentionOnce() {
+	retentionDays := operation_setting.GetAuditLogSetting().RetentionDays
+	if retentionDays <= 0 {
+		return
+	}
+	cutoff := time.Now().AddDate(0, 0, -retentionDays).Unix()
+	deleted, err := model.PruneAuditLogs(cutoff, auditLogRetentionBatchSize)
+	if err != nil {
+		logger.LogWarn(context.Background(), fmt.Sprintf("audit log retention task failed: %v", err))
+		return
+	}
+	if deleted > 0 {
+		logger.LogInfo(context.Background(), fmt.Sprintf("audit log retention task pruned %d entries older than %d days", deleted, retentionDays))
+	}
+}
@@ -1,14 +1,24 @@
 package authz
 
-import "github.com/QuantumNous/new-api/common"
+import (
+	"sort"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+)
 
 // resolveSubjectRoles returns the role keys assigned to a subject. The mapping
-// is derived from the caller's system role.
+// is derived from the caller's system role. An admin with custom role
+// assignments is governed by those roles instead of the built-in admin
+// baseline, which lets a custom role narrow what an administrator may do.
 var resolveSubjectRoles = func(userID int, systemRole int) []string {
 	switch {
 	case systemRole >= common.RoleRootUser:
 		return []string{BuiltInRoleRoot}
 	case systemRole >= common.RoleAdminUser:
+		if roles := UserRoles(userID); len(roles) > 0 {
+			return roles
+		}
 		return []string{BuiltInRoleAdmin}
 	default:
 		return nil
@@ -16,5 +26,35 @@ var resolveSubjectRoles = func(userID int, systemRole int) []string {
 }
 
 // managedRoleKey is the role whose baseline per-user overrides are expressed
-// relative to.
+// relative to when the user has no custom role assignment.
 const managedRoleKey = BuiltInRoleAdmin
+
+// UserRoles returns the custom role keys explicitly assigned to a user.
+func UserRoles(userID int) []string {
+	e := currentEnforcer()
+	if e == nil {
+		return nil
+	}
+	policies, err := e.GetFilteredGroupingPolicy(0, UserSubject(userID))
+	if err != nil {
+		return nil
+	}
+	roles := make([]string, 0, len(policies))
+	for _, policy := range policies {
+		if len(policy) < 2 || !strings.HasPrefix(policy[1], rolePrefix) {
+			continue
+		}
+		roles = append(roles, strings.TrimPrefix(policy[1], rolePrefix))
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// managedRoles returns the roles a managed (admin) user's overrides are
+// compared against.
+func managedRoles(userID int) []string {
+	if roles := UserRoles(userID); len(roles) > 0 {
+		return roles
+	}
+	return []string{managedRoleKey}
+}
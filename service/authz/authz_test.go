@@ -97,7 +97,7 @@ func TestSetUserPermissionsStoresOnlyOverrides(t *testing.T) {
 
 	assert.True(t, Can(42, common.RoleAdminUser, ChannelSensitiveWrite))
 	assert.False(t, Can(42, common.RoleAdminUser, ChannelWrite))
-	assert.Equal(t, PermissionsMap{
+	assert.Equal(t, adminBaselineWith(PermissionsMap{
 		ResourceChannel: {
 			ActionRead:           true,
 			ActionOperate:        true,
@@ -105,7 +105,7 @@ func TestSetUserPermissionsStoresOnlyOverrides(t *testing.T) {
 			ActionSensitiveWrite: true,
 			ActionSecretView:     false,
 		},
-	}, ExplicitUserPermissions(42))
+	}), ExplicitUserPermissions(42))
 	assert.Equal(t, PermissionsMap{
 		ResourceChannel: {
 			ActionSensitiveWrite: true,
@@ -125,7 +125,7 @@ func TestSetUserPermissionsStoresOnlyOverrides(t *testing.T) {
 		ActionSecretView:     false,
 	}}))
 	assert.False(t, Can(42, common.RoleAdminUser, ChannelSensitiveWrite))
-	assert.Equal(t, PermissionsMap{
+	assert.Equal(t, adminBaselineWith(PermissionsMap{
 		ResourceChannel: {
 			ActionRead:           true,
 			ActionOperate:        true,
@@ -133,7 +133,7 @@ func TestSetUserPermissionsStoresOnlyOverrides(t *testing.T) {
 			ActionSensitiveWrite: false,
 			ActionSecretView:     false,
 		},
-	}, ExplicitUserPermissions(42))
+	}), ExplicitUserPermissions(42))
 	assert.Empty(t, ExplicitUserOverrides(42))
 }
 
@@ -227,3 +227,136 @@ func TestCapabilitiesUseCatalogShape(t *testing.T) {
 	assert.False(t, capabilities[ResourceChannel][ActionSensitiveWrite])
 	assert.False(t, capabilities[ResourceChannel][ActionSecretView])
 }
+
+func TestCustomRoleNarrowsAssignedAdmin(t *testing.T) {
+	db := newAuthzTestDB(t)
+	require.NoError(t, Init(db))
+
+	require.NoError(t, CreateRole(RoleInput{
+		Key:  "support",
+		Name: "Support (read-only)",
+		Grants: PermissionsMap{
+			ResourceUser: {ActionRead: true},
+			ResourceLog:  {ActionRead: true},
+			"unknown":    {ActionRead: true},
+		},
+	}))
+	require.NoError(t, SetUserRoles(21, []string{"support"}))
+
+	assert.Equal(t, []string{"support"}, UserRoles(21))
+	assert.True(t, Can(21, common.RoleAdminUser, UserRead))
+	assert.True(t, Can(21, common.RoleAdminUser, LogRead))
+	assert.False(t, Can(21, common.RoleAdminUser, UserWrite))
+	assert.False(t, Can(21, common.RoleAdminUser, ChannelRead))
+	// Unassigned admins keep the built-in baseline; role assignments never
+	// grant anything to common users.
+	assert.True(t, Can(22, common.RoleAdminUser, ChannelRead))
+	assert.False(t, Can(21, common.RoleCommonUser, UserRead))
+
+	// Overrides are relative to the assigned role rather than the admin baseline.
+	require.NoError(t, SetUserPermissions(21, PermissionsMap{ResourceChannel: {ActionRead: true}}))
+	assert.True(t, Can(21, common.RoleAdminUser, ChannelRead))
+
+	var roles []RoleDescriptor
+	for _, role := range Roles() {
+		if role.Key == "support" {
+			roles = append(roles, role)
+		}
+	}
+	require.Len(t, roles, 1)
+	assert.False(t, roles[0].BuiltIn)
+	assert.True(t, roles[0].Grants[ResourceUser][ActionRead])
+	assert.False(t, roles[0].Grants[ResourceUser][ActionWrite])
+}
+
+func TestUpdateAndDeleteCustomRole(t *testing.T) {
+	db := newAuthzTestDB(t)
+	require.NoError(t, Init(db))
+
+	require.NoError(t, CreateRole(RoleInput{
+		Key:    "billing",
+		Name:   "Billing operator",
+		Grants: PermissionsMap{ResourcePayment: {ActionRead: true}},
+	}))
+	assert.ErrorIs(t, CreateRole(RoleInput{Key: "billing", Name: "Duplicate"}), ErrRoleExists)
+	assert.ErrorIs(t, CreateRole(RoleInput{Key: BuiltInRoleAdmin, Name: "Admin"}), ErrRoleExists)
+	assert.ErrorIs(t, CreateRole(RoleInput{Key: "Bad Key", Name: "Bad"}), ErrInvalidRoleKey)
+	require.NoError(t, SetUserRoles(31, []string{"billing"}))
+	assert.False(t, Can(31, common.RoleAdminUser, PaymentOperate))
+
+	require.NoError(t, UpdateRole("billing", RoleInput{
+		Name:   "Billing operator",
+		Grants: PermissionsMap{ResourcePayment: {ActionRead: true, ActionOperate: true}},
+	}))
+	assert.True(t, Can(31, common.RoleAdminUser, PaymentOperate))
+	assert.ErrorIs(t, UpdateRole(BuiltInRoleAdmin, RoleInput{Name: "Admin"}), ErrRoleBuiltIn)
+
+	assert.ErrorIs(t, DeleteRole("billing"), ErrRoleInUse)
+	assert.True(t, Can(31, common.RoleAdminUser, PaymentOperate))
+	assert.False(t, Can(31, common.RoleAdminUser, ChannelRead))
+	require.NoError(t, SetUserRoles(31, nil))
+	require.NoError(t, DeleteRole("billing"))
+	assert.ErrorIs(t, DeleteRole("billing"), ErrRoleNotFound)
+	assert.Empty(t, UserRoles(31))
+	assert.True(t, Can(31, common.RoleAdminUser, ChannelRead))
+
+	var count int64
+	require.NoError(t, db.Model(&model.CasbinRule{}).Where("v0 = ? OR v1 = ?", RoleSubject("billing"), RoleSubject("billing")).Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestClearUserAuthorizationRemovesRoleAssignments(t *testing.T) {
+	db := newAuthzTestDB(t)
+	require.NoError(t, Init(db))
+
+	require.NoError(t, CreateRole(RoleInput{Key: "viewer", Name: "Viewer"}))
+	assert.ErrorIs(t, SetUserRoles(41, []string{"missing"}), ErrRoleNotFound)
+	require.NoError(t, SetUserRoles(41, []string{"viewer"}))
+	assert.False(t, Can(41, common.RoleAdminUser, ChannelRead))
+
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		return ClearUserAuthorizationInTx(tx, 41)
+	}))
+	require.NoError(t, ReloadPolicy())
+	assert.Empty(t, UserRoles(41))
+	assert.True(t, Can(41, common.RoleAdminUser, ChannelRead))
+}
+
+func TestCheckGrantableRejectsPermissionsBeyondCaller(t *testing.T) {
+	db := newAuthzTestDB(t)
+	require.NoError(t, Init(db))
+
+	require.NoError(t, CreateRole(RoleInput{
+		Key:    "role-editor",
+		Name:   "Role editor",
+		Grants: PermissionsMap{ResourceRole: {ActionRead: true, ActionWrite: true}},
+	}))
+	require.NoError(t, CreateRole(RoleInput{
+		Key:    "operator",
+		Name:   "Operator",
+		Grants: PermissionsMap{ResourceUser: {ActionWrite: true}},
+	}))
+	require.NoError(t, SetUserRoles(51, []string{"role-editor"}))
+
+	assert.NoError(t, CheckGrantable(51, common.RoleAdminUser, PermissionsMap{ResourceRole: {ActionRead: true}}))
+	assert.ErrorIs(t, CheckGrantable(51, common.RoleAdminUser, PermissionsMap{ResourceUser: {ActionWrite: true}}), ErrGrantExceeds)
+	assert.NoError(t, CheckRolesGrantable(51, common.RoleAdminUser, []string{"role-editor"}))
+	assert.ErrorIs(t, CheckRolesGrantable(51, common.RoleAdminUser, []string{"operator"}), ErrGrantExceeds)
+	assert.NoError(t, CheckRolesGrantable(1, common.RoleRootUser, []string{"operator"}))
+	// Clearing every role restores the admin baseline, which the role editor
+	// does not hold.
+	assert.ErrorIs(t, CheckRolesGrantable(51, common.RoleAdminUser, nil), ErrGrantExceeds)
+	assert.NoError(t, CheckRolesGrantable(52, common.RoleAdminUser, nil))
+	assert.NoError(t, CheckRolesGrantable(1, common.RoleRootUser, []string{}))
+}
+
+// adminBaselineWith returns the built-in admin grant matrix with the given
+// resources replaced.
+func adminBaselineWith(resources PermissionsMap) PermissionsMap {
+	spec, _ := roleSpec(BuiltInRoleAdmin)
+	expected := roleGrants(spec)
+	for resource, actions := range resources {
+		expected[resource] = actions
+	}
+	return expected
+}
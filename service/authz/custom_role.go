@@ -0,0 +1,232 @@
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/QuantumNous/new-api/model"
+	"gorm.io/gorm"
+)
+
+const customRoleSort = 100
+
+var (
+	ErrRoleNotFound   = errors.New("authz role not found")
+	ErrRoleExists     = errors.New("authz role already exists")
+	ErrRoleBuiltIn    = errors.New("built-in authz roles cannot be modified")
+	ErrInvalidRoleKey = errors.New("role key must be 1-64 characters of lowercase letters, digits, '_' or '-'")
+	ErrGrantExceeds   = errors.New("cannot grant a permission the caller does not hold")
+	ErrRoleInUse      = errors.New("authz role is still assigned to users")
+)
+
+var roleKeyPattern = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+// RoleInput is the editable definition of a custom role. Grants use the same
+// resource -> action -> allowed shape as the catalog; unknown entries and
+// denied actions are ignored.
+type RoleInput struct {
+	Key         string         `json:"key"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Grants      PermissionsMap `json:"grants"`
+}
+
+// CreateRole stores a new custom role and its grants.
+func CreateRole(input RoleInput) error {
+	input.Key = strings.TrimSpace(input.Key)
+	if err := validateRoleInput(input); err != nil {
+		return err
+	}
+	if _, ok := roleSpec(input.Key); ok {
+		return ErrRoleExists
+	}
+	return updateRolesAndReload(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.AuthzRole{}).Where(map[string]interface{}{"key": input.Key}).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrRoleExists
+		}
+		role := model.AuthzRole{
+			Key:         input.Key,
+			Name:        strings.TrimSpace(input.Name),
+			Description: input.Description,
+			Enabled:     true,
+			Sort:        customRoleSort,
+		}
+		if err := tx.Create(&role).Error; err != nil {
+			return err
+		}
+		return replaceRoleGrantsInTx(tx, input.Key, input.Grants)
+	})
+}
+
+// UpdateRole replaces the name, description and grants of a custom role.
+func UpdateRole(roleKey string, input RoleInput) error {
+	input.Key = roleKey
+	if err := validateRoleInput(input); err != nil {
+		return err
+	}
+	if _, ok := roleSpec(roleKey); ok {
+		return ErrRoleBuiltIn
+	}
+	return updateRolesAndReload(func(tx *gorm.DB) error {
+		result := tx.Model(&model.AuthzRole{}).
+			Where(customRoleCondition(roleKey)).
+			Updates(map[string]interface{}{
+				"name":        strings.TrimSpace(input.Name),
+				"description": input.Description,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRoleNotFound
+		}
+		return replaceRoleGrantsInTx(tx, roleKey, input.Grants)
+	})
+}
+
+// DeleteRole removes a custom role and its grants. A role that is still
+// assigned cannot be deleted: its holders would otherwise fall back to the
+// broader built-in admin baseline.
+func DeleteRole(roleKey string) error {
+	if _, ok := roleSpec(roleKey); ok {
+		return ErrRoleBuiltIn
+	}
+	return updateRolesAndReload(func(tx *gorm.DB) error {
+		var holders int64
+		if err := tx.Model(&model.CasbinRule{}).Where("ptype = ? AND v1 = ?", "g", RoleSubject(roleKey)).Count(&holders).Error; err != nil {
+			return err
+		}
+		if holders > 0 {
+			return ErrRoleInUse
+		}
+		result := tx.Where(customRoleCondition(roleKey)).Delete(&model.AuthzRole{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRoleNotFound
+		}
+		return tx.Where("ptype = ? AND v0 = ?", "p", RoleSubject(roleKey)).Delete(&model.CasbinRule{}).Error
+	})
+}
+
+// SetUserRoles replaces the custom roles assigned to a user. An empty list
+// returns the user to the built-in role derived from their system role.
+func SetUserRoles(userID int, roleKeys []string) error {
+	return updateRolesAndReload(func(tx *gorm.DB) error {
+		return SetUserRolesInTx(tx, userID, roleKeys)
+	})
+}
+
+// SetUserRolesInTx is SetUserRoles inside a caller-owned transaction. Like
+// SetUserPermissionsInTx it only writes the database; callers must call
+// ReloadPolicy after commit.
+func SetUserRolesInTx(tx *gorm.DB, userID int, roleKeys []string) error {
+	seen := make(map[string]struct{}, len(roleKeys))
+	rules := make([]model.CasbinRule, 0, len(roleKeys))
+	for _, roleKey := range roleKeys {
+		if _, ok := seen[roleKey]; ok {
+			continue
+		}
+		seen[roleKey] = struct{}{}
+		if _, ok := roleSpec(roleKey); ok {
+			return fmt.Errorf("built-in role %s cannot be assigned explicitly", roleKey)
+		}
+		var count int64
+		if err := tx.Model(&model.AuthzRole{}).Where(customRoleCondition(roleKey)).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: %s", ErrRoleNotFound, roleKey)
+		}
+		rules = append(rules, newRule("g", []string{UserSubject(userID), RoleSubject(roleKey)}))
+	}
+	if err := tx.Where("ptype = ? AND v0 = ?", "g", UserSubject(userID)).Delete(&model.CasbinRule{}).Error; err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	return tx.Create(&rules).Error
+}
+
+// CheckGrantable reports whether every permission in grants is one the caller
+// holds, so a role editor cannot mint permissions beyond their own.
+func CheckGrantable(callerID int, callerRole int, grants PermissionsMap) error {
+	for _, permission := range AllPermissions() {
+		if !grants[permission.Resource][permission.Action] {
+			continue
+		}
+		if !Can(callerID, callerRole, permission) {
+			return fmt.Errorf("%w: %s.%s", ErrGrantExceeds, permission.Resource, permission.Action)
+		}
+	}
+	return nil
+}
+
+// CheckRolesGrantable reports whether the union of the given custom roles'
+// grants is within the caller's own effective permissions. An empty list puts
+// the user back on the built-in admin baseline, so that baseline is checked
+// instead.
+func CheckRolesGrantable(callerID int, callerRole int, roleKeys []string) error {
+	if len(roleKeys) == 0 {
+		spec, _ := roleSpec(managedRoleKey)
+		return CheckGrantable(callerID, callerRole, roleGrants(spec))
+	}
+	for _, roleKey := range roleKeys {
+		if err := CheckGrantable(callerID, callerRole, customRoleGrants(roleKey)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// customRoleCondition uses a map condition so gorm quotes the reserved "key"
+// column for every supported database.
+func customRoleCondition(roleKey string) map[string]interface{} {
+	return map[string]interface{}{"key": roleKey, "built_in": false}
+}
+
+func validateRoleInput(input RoleInput) error {
+	if !roleKeyPattern.MatchString(input.Key) {
+		return ErrInvalidRoleKey
+	}
+	if strings.TrimSpace(input.Name) == "" {
+		return errors.New("role name is required")
+	}
+	return nil
+}
+
+func replaceRoleGrantsInTx(tx *gorm.DB, roleKey string, grants PermissionsMap) error {
+	if err := tx.Where("ptype = ? AND v0 = ?", "p", RoleSubject(roleKey)).Delete(&model.CasbinRule{}).Error; err != nil {
+		return err
+	}
+	rules := make([]model.CasbinRule, 0)
+	for _, permission := range AllPermissions() {
+		if !grants[permission.Resource][permission.Action] {
+			continue
+		}
+		rules = append(rules, newRule("p", []string{RoleSubject(roleKey), permission.Resource, permission.Action, EffectAllow}))
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	return tx.Create(&rules).Error
+}
+
+func updateRolesAndReload(fn func(tx *gorm.DB) error) error {
+	db := currentDB()
+	if db == nil {
+		return fmt.Errorf("authz enforcer is not initialized")
+	}
+	if err := db.Transaction(fn); err != nil {
+		return err
+	}
+	return ReloadPolicy()
+}
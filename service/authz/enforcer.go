@@ -14,6 +14,9 @@ import (
 var (
 	enforcerMu sync.RWMutex
 	enforcer   *casbin.SyncedEnforcer
+	// authzDB is the database Init was called with. Custom role metadata lives
+	// in model.AuthzRole and is read through it.
+	authzDB *gorm.DB
 )
 
 const modelText = `
@@ -23,6 +26,9 @@ r = sub, obj, act
 [policy_definition]
 p = sub, obj, act, eft
 
+[role_definition]
+g = _, _
+
 [policy_effect]
 e = some(where (p.eft == allow))
 
@@ -52,6 +58,7 @@ func Init(db *gorm.DB) error {
 
 	enforcerMu.Lock()
 	enforcer = e
+	authzDB = db
 	enforcerMu.Unlock()
 
 	if !common.IsMasterNode {
@@ -66,6 +73,12 @@ func currentEnforcer() *casbin.SyncedEnforcer {
 	return enforcer
 }
 
+func currentDB() *gorm.DB {
+	enforcerMu.RLock()
+	defer enforcerMu.RUnlock()
+	return authzDB
+}
+
 func ReloadPolicy() error {
 	enforcerMu.Lock()
 	defer enforcerMu.Unlock()
@@ -30,7 +30,7 @@ func SetUserPermissions(userID int, permissions PermissionsMap) error {
 		if _, err := e.RemoveFilteredPolicy(0, UserSubject(userID), resource); err != nil {
 			return err
 		}
-		for _, policy := range userOverridePolicies(e, resource, actions) {
+		for _, policy := range userOverridePolicies(e, managedRoles(userID), resource, actions) {
 			if _, err := e.AddPolicy(UserSubject(userID), policy.Resource, policy.Action, policy.Effect); err != nil {
 				return err
 			}
@@ -52,7 +52,7 @@ func SetUserPermissionsInTx(tx *gorm.DB, userID int, permissions PermissionsMap)
 		if err := tx.Where("ptype = ? AND v0 = ? AND v1 = ?", "p", UserSubject(userID), resource).Delete(&model.CasbinRule{}).Error; err != nil {
 			return err
 		}
-		policies := userOverridePolicies(e, resource, actions)
+		policies := userOverridePolicies(e, managedRoles(userID), resource, actions)
 		if len(policies) == 0 {
 			continue
 		}
@@ -90,12 +90,27 @@ func ClearUserPermissionsInTx(tx *gorm.DB, userID int) error {
 	return nil
 }
 
+// ClearUserAuthorization removes both the per-user overrides and the custom
+// role assignments of a user.
 func ClearUserAuthorization(userID int) error {
-	return ClearUserPermissions(userID)
+	if err := ClearUserPermissions(userID); err != nil {
+		return err
+	}
+	e := currentEnforcer()
+	if e == nil {
+		return fmt.Errorf("authz enforcer is not initialized")
+	}
+	if _, err := e.RemoveFilteredGroupingPolicy(0, UserSubject(userID)); err != nil {
+		return err
+	}
+	return nil
 }
 
 func ClearUserAuthorizationInTx(tx *gorm.DB, userID int) error {
-	return ClearUserPermissionsInTx(tx, userID)
+	if err := ClearUserPermissionsInTx(tx, userID); err != nil {
+		return err
+	}
+	return tx.Where("ptype = ? AND v0 = ?", "g", UserSubject(userID)).Delete(&model.CasbinRule{}).Error
 }
 
 // ExplicitUserPermissions returns the effective permission matrix for the
@@ -133,9 +148,9 @@ func ExplicitUserOverrides(userID int) PermissionsMap {
 	return result
 }
 
-// userOverridePolicies returns the override entries that differ from the managed
-// role baseline; entries matching the baseline are omitted.
-func userOverridePolicies(e *casbin.SyncedEnforcer, resource string, actions map[string]bool) []overridePolicy {
+// userOverridePolicies returns the override entries that differ from the
+// baseline of the given roles; entries matching the baseline are omitted.
+func userOverridePolicies(e *casbin.SyncedEnforcer, roles []string, resource string, actions map[string]bool) []overridePolicy {
 	overrides := make([]overridePolicy, 0, len(actions))
 	for _, action := range catalogActions(resource) {
 		desired, ok := actions[action.Action]
@@ -143,7 +158,7 @@ func userOverridePolicies(e *casbin.SyncedEnforcer, resource string, actions map
 			continue
 		}
 		permission := Permission{Resource: resource, Action: action.Action}
-		if desired == roleBaselineAllows(e, managedRoleKey, permission) {
+		if desired == rolesBaselineAllows(e, roles, permission) {
 			continue
 		}
 		effect := EffectDeny
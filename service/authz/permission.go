@@ -21,7 +21,9 @@ func UserSubject(userID int) string {
 	return "user:" + strconv.Itoa(userID)
 }
 
+const rolePrefix = "role:"
+
 // RoleSubject is the casbin subject string for a role.
 func RoleSubject(roleKey string) string {
-	return "role:" + roleKey
+	return rolePrefix + roleKey
 }
@@ -26,12 +26,7 @@ func Can(userID int, systemRole int, permission Permission) bool {
 	if effect, ok := explicitSubjectEffect(e, UserSubject(userID), permission); ok {
 		return effect == EffectAllow
 	}
-	for _, role := range roles {
-		if roleBaselineAllows(e, role, permission) {
-			return true
-		}
-	}
-	return false
+	return rolesBaselineAllows(e, roles, permission)
 }
 
 // Capabilities returns the full resource/action matrix the subject is allowed.
@@ -50,6 +45,15 @@ func Capabilities(userID int, systemRole int) PermissionsMap {
 	return result
 }
 
+func rolesBaselineAllows(e *casbin.SyncedEnforcer, roles []string, permission Permission) bool {
+	for _, role := range roles {
+		if roleBaselineAllows(e, role, permission) {
+			return true
+		}
+	}
+	return false
+}
+
 func roleBaselineAllows(e *casbin.SyncedEnforcer, roleKey string, permission Permission) bool {
 	effect, ok := explicitSubjectEffect(e, RoleSubject(roleKey), permission)
 	return ok && effect == EffectAllow
@@ -0,0 +1,19 @@
+package authz
+
+const ResourceAuditLog = "audit_log"
+
+var AuditLogRead = Permission{Resource: ResourceAuditLog, Action: ActionRead}
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceAuditLog,
+		LabelKey: "Audit Log",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read audit log",
+				DescriptionKey: "View, export, and verify the tamper-evident audit log of administrative actions.",
+			},
+		},
+	})
+}
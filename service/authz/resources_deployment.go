@@ -0,0 +1,29 @@
+package authz
+
+const ResourceDeployment = "deployment"
+
+var (
+	DeploymentRead  = Permission{Resource: ResourceDeployment, Action: ActionRead}
+	DeploymentWrite = Permission{Resource: ResourceDeployment, Action: ActionWrite}
+)
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceDeployment,
+		LabelKey: "Model Deployments",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read deployments",
+				DescriptionKey: "View deployments, containers, logs, hardware, and price estimates.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit deployments",
+				DescriptionKey: "Create, update, extend, rename, and delete deployments.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+}
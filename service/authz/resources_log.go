@@ -0,0 +1,20 @@
+package authz
+
+const ResourceLog = "log"
+
+var LogRead = Permission{Resource: ResourceLog, Action: ActionRead}
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceLog,
+		LabelKey: "Logs & Usage Data",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read logs",
+				DescriptionKey: "View usage logs, statistics, quota data, and task records of all users.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+}
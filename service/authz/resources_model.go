@@ -0,0 +1,35 @@
+package authz
+
+const ResourceModel = "model"
+
+var (
+	ModelRead           = Permission{Resource: ResourceModel, Action: ActionRead}
+	ModelWrite          = Permission{Resource: ResourceModel, Action: ActionWrite}
+	ModelSensitiveWrite = Permission{Resource: ResourceModel, Action: ActionSensitiveWrite}
+)
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceModel,
+		LabelKey: "Models & Pricing",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read models",
+				DescriptionKey: "View model metadata, vendors, groups, and prefill groups.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit models",
+				DescriptionKey: "Edit model metadata, vendors, and prefill groups, and sync models from upstream.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionSensitiveWrite,
+				LabelKey:       "Edit pricing",
+				DescriptionKey: "Reset model ratios and fetch ratios from upstream channels.",
+			},
+		},
+	})
+}
@@ -0,0 +1,29 @@
+package authz
+
+const ResourceOrganization = "organization"
+
+var (
+	OrganizationRead  = Permission{Resource: ResourceOrganization, Action: ActionRead}
+	OrganizationWrite = Permission{Resource: ResourceOrganization, Action: ActionWrite}
+)
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceOrganization,
+		LabelKey: "Organizations",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read organizations",
+				DescriptionKey: "View every organization, its members, and its shared wallet.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit organizations",
+				DescriptionKey: "Adjust organization wallets, enable or disable, and delete organizations.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+}
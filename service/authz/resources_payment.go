@@ -0,0 +1,35 @@
+package authz
+
+const ResourcePayment = "payment"
+
+var (
+	PaymentRead    = Permission{Resource: ResourcePayment, Action: ActionRead}
+	PaymentOperate = Permission{Resource: ResourcePayment, Action: ActionOperate}
+	PaymentWrite   = Permission{Resource: ResourcePayment, Action: ActionWrite}
+)
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourcePayment,
+		LabelKey: "Payments",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read payments",
+				DescriptionKey: "View top-up orders of all users.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionOperate,
+				LabelKey:       "Operate payments",
+				DescriptionKey: "Manually complete pending top-up orders.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit payment settings",
+				DescriptionKey: "Confirm payment compliance and configure payment provider products.",
+			},
+		},
+	})
+}
@@ -0,0 +1,29 @@
+package authz
+
+const ResourceReseller = "reseller"
+
+var (
+	ResellerRead  = Permission{Resource: ResourceReseller, Action: ActionRead}
+	ResellerWrite = Permission{Resource: ResourceReseller, Action: ActionWrite}
+)
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceReseller,
+		LabelKey: "Resellers",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read resellers",
+				DescriptionKey: "View every reseller, its downstream users, and its usage report.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit resellers",
+				DescriptionKey: "Grant or revoke reseller status and change reseller markups and user limits.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+}
@@ -0,0 +1,28 @@
+package authz
+
+const ResourceRole = "role"
+
+var (
+	RoleRead  = Permission{Resource: ResourceRole, Action: ActionRead}
+	RoleWrite = Permission{Resource: ResourceRole, Action: ActionWrite}
+)
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceRole,
+		LabelKey: "Authorization Roles",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read roles",
+				DescriptionKey: "View authorization roles and the roles assigned to administrators.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit roles",
+				DescriptionKey: "Create, edit, and delete custom roles and assign them to administrators.",
+			},
+		},
+	})
+}
@@ -0,0 +1,29 @@
+package authz
+
+const ResourceSubscription = "subscription"
+
+var (
+	SubscriptionRead  = Permission{Resource: ResourceSubscription, Action: ActionRead}
+	SubscriptionWrite = Permission{Resource: ResourceSubscription, Action: ActionWrite}
+)
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceSubscription,
+		LabelKey: "Subscriptions",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read subscriptions",
+				DescriptionKey: "View subscription plans and the subscriptions of any user.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit subscriptions",
+				DescriptionKey: "Create and edit plans, bind, reset, invalidate, and delete user subscriptions.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+}
@@ -0,0 +1,27 @@
+package authz
+
+const ResourceSystem = "system"
+
+var (
+	SystemRead    = Permission{Resource: ResourceSystem, Action: ActionRead}
+	SystemOperate = Permission{Resource: ResourceSystem, Action: ActionOperate}
+)
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceSystem,
+		LabelKey: "System Tasks",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read system status",
+				DescriptionKey: "View system tasks, instances, performance statistics, and log files.",
+			},
+			{
+				Action:         ActionOperate,
+				LabelKey:       "Run system tasks",
+				DescriptionKey: "Start system tasks, clean up instances, caches, and log files, and trigger GC.",
+			},
+		},
+	})
+}
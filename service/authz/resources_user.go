@@ -0,0 +1,36 @@
+package authz
+
+const ResourceUser = "user"
+
+var (
+	UserRead           = Permission{Resource: ResourceUser, Action: ActionRead}
+	UserWrite          = Permission{Resource: ResourceUser, Action: ActionWrite}
+	UserSensitiveWrite = Permission{Resource: ResourceUser, Action: ActionSensitiveWrite}
+)
+
+func init() {
+	RegisterResource(ResourceDefinition{
+		Resource: ResourceUser,
+		LabelKey: "User Management",
+		Actions: []ActionDefinition{
+			{
+				Action:         ActionRead,
+				LabelKey:       "Read users",
+				DescriptionKey: "View user lists, details, OAuth bindings, and 2FA statistics.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionWrite,
+				LabelKey:       "Edit users",
+				DescriptionKey: "Create, update, enable/disable, promote, and delete users.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+			{
+				Action:         ActionSensitiveWrite,
+				LabelKey:       "Reset user security settings",
+				DescriptionKey: "Clear account bindings, force-disable 2FA, and reset passkeys.",
+				DefaultRoles:   []string{BuiltInRoleAdmin},
+			},
+		},
+	})
+}
@@ -1,5 +1,10 @@
 package authz
 
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+)
+
 const (
 	BuiltInRoleRoot  = "root"
 	BuiltInRoleAdmin = "admin"
@@ -37,23 +42,34 @@ var builtInRoles = []RoleSpec{
 
 // RoleDescriptor exposes a role together with its baseline grant matrix.
 type RoleDescriptor struct {
-	Key       string         `json:"key"`
-	Name      string         `json:"name"`
-	BuiltIn   bool           `json:"built_in"`
-	Superuser bool           `json:"superuser"`
-	Grants    PermissionsMap `json:"grants"`
+	Key         string         `json:"key"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	BuiltIn     bool           `json:"built_in"`
+	Superuser   bool           `json:"superuser"`
+	Grants      PermissionsMap `json:"grants"`
 }
 
-// Roles returns the role descriptors with their baseline grants.
+// Roles returns the built-in and custom role descriptors with their baseline
+// grants.
 func Roles() []RoleDescriptor {
 	result := make([]RoleDescriptor, 0, len(builtInRoles))
 	for _, spec := range builtInRoles {
 		result = append(result, RoleDescriptor{
-			Key:       spec.Key,
-			Name:      spec.Name,
-			BuiltIn:   spec.BuiltIn,
-			Superuser: spec.Superuser,
-			Grants:    roleGrants(spec),
+			Key:         spec.Key,
+			Name:        spec.Name,
+			Description: spec.Description,
+			BuiltIn:     spec.BuiltIn,
+			Superuser:   spec.Superuser,
+			Grants:      roleGrants(spec),
+		})
+	}
+	for _, role := range customRoles() {
+		result = append(result, RoleDescriptor{
+			Key:         role.Key,
+			Name:        role.Name,
+			Description: role.Description,
+			Grants:      customRoleGrants(role.Key),
 		})
 	}
 	return result
@@ -71,6 +87,37 @@ func roleGrants(spec RoleSpec) PermissionsMap {
 	return grants
 }
 
+// customRoleGrants reads a custom role's grant matrix from the loaded policy,
+// since custom roles have no DefaultRoles entries in the registry.
+func customRoleGrants(roleKey string) PermissionsMap {
+	grants := make(PermissionsMap, len(registry))
+	e := currentEnforcer()
+	for _, resource := range registry {
+		actions := make(map[string]bool, len(resource.Actions))
+		for _, action := range resource.Actions {
+			actions[action.Action] = e != nil && roleBaselineAllows(e, roleKey, Permission{
+				Resource: resource.Resource,
+				Action:   action.Action,
+			})
+		}
+		grants[resource.Resource] = actions
+	}
+	return grants
+}
+
+func customRoles() []model.AuthzRole {
+	db := currentDB()
+	if db == nil {
+		return nil
+	}
+	var roles []model.AuthzRole
+	if err := db.Where("built_in = ?", false).Order("sort asc, id asc").Find(&roles).Error; err != nil {
+		common.SysError("failed to load custom authz roles: " + err.Error())
+		return nil
+	}
+	return roles
+}
+
 func roleSpec(roleKey string) (RoleSpec, bool) {
 	for _, spec := range builtInRoles {
 		if spec.Key == roleKey {
@@ -0,0 +1,498 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/billingexpr"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/setting/billing_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	hosttypes "github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// 计费模拟：把时间窗口内的消费日志按候选价格重新结算，估算价格调整对收入的影响。
+// 只读取日志，不修改任何余额或配置。
+//
+// 每条日志先用日志中记录的价格参数重算一次（基准），再用候选价格重算一次，
+// 模拟额度 = 实际扣费 + (候选 - 基准)。这样工具调用附加费、按秒计费等没有写入
+// 日志的部分保持不变，价格未变化的日志差额恰好为 0。两次都按次计费时改为按比例
+// 缩放实际扣费，以保留视频时长等其他倍率。
+const (
+	billingSimulationBatchSize  = 1000
+	billingSimulationDefaultTop = 100
+	// BillingSimulationSyncWindow 不超过该时长的模拟直接在请求中完成，更长的窗口转为系统任务
+	BillingSimulationSyncWindow = 7 * 24 * time.Hour
+)
+
+// BillingSimulationRequest 候选价格只需列出要调整的项，未列出的模型与分组沿用日志中记录的价格
+type BillingSimulationRequest struct {
+	StartTimestamp int64  `json:"start_timestamp"`
+	EndTimestamp   int64  `json:"end_timestamp"`
+	ModelName      string `json:"model_name,omitempty"`
+	UserId         int    `json:"user_id,omitempty"`
+	Group          string `json:"group,omitempty"`
+	TopN           int    `json:"top_n,omitempty"`
+
+	GroupRatio      map[string]float64 `json:"group_ratio,omitempty"`
+	ModelRatio      map[string]float64 `json:"model_ratio,omitempty"`
+	CompletionRatio map[string]float64 `json:"completion_ratio,omitempty"`
+	CacheRatio      map[string]float64 `json:"cache_ratio,omitempty"`
+	ModelPrice      map[string]float64 `json:"model_price,omitempty"`
+	BillingMode     map[string]string  `json:"billing_mode,omitempty"`
+	BillingExpr     map[string]string  `json:"billing_expr,omitempty"`
+}
+
+func (r *BillingSimulationRequest) Validate() error {
+	if r.StartTimestamp <= 0 || r.EndTimestamp <= r.StartTimestamp {
+		return errors.New("invalid simulation window")
+	}
+	for group, ratio := range r.GroupRatio {
+		if ratio < 0 || math.IsNaN(ratio) || math.IsInf(ratio, 0) {
+			return fmt.Errorf("invalid ratio for group %s", group)
+		}
+	}
+	for name, ratio := range r.CacheRatio {
+		if ratio < 0 || math.IsNaN(ratio) || math.IsInf(ratio, 0) {
+			return fmt.Errorf("invalid cache ratio for model %s", name)
+		}
+	}
+	for name, mode := range r.BillingMode {
+		if mode != billing_setting.BillingModeRatio && mode != billing_setting.BillingModeTieredExpr {
+			return fmt.Errorf("unknown billing mode %q for model %s", mode, name)
+		}
+	}
+	content := &model.PriceBookContent{
+		ModelRatio:      r.ModelRatio,
+		CompletionRatio: r.CompletionRatio,
+		ModelPrice:      r.ModelPrice,
+		BillingExpr:     r.BillingExpr,
+	}
+	return content.Validate()
+}
+
+func (r *BillingSimulationRequest) filter() model.ConsumeLogFilter {
+	return model.ConsumeLogFilter{
+		StartTimestamp: r.StartTimestamp,
+		EndTimestamp:   r.EndTimestamp,
+		ModelName:      r.ModelName,
+		UserId:         r.UserId,
+		Group:          r.Group,
+	}
+}
+
+type BillingSimulationTotals struct {
+	Requests       int64 `json:"requests"`
+	ActualQuota    int64 `json:"actual_quota"`
+	SimulatedQuota int64 `json:"simulated_quota"`
+	DeltaQuota     int64 `json:"delta_quota"`
+}
+
+func (t *BillingSimulationTotals) add(actual, simulated int) {
+	t.Requests++
+	t.ActualQuota += int64(actual)
+	t.SimulatedQuota += int64(simulated)
+	t.DeltaQuota += int64(simulated - actual)
+}
+
+type BillingSimulationUserRow struct {
+	UserId   int    `json:"user_id"`
+	Username string `json:"username"`
+	BillingSimulationTotals
+}
+
+type BillingSimulationModelRow struct {
+	ModelName string `json:"model_name"`
+	BillingSimulationTotals
+}
+
+// BillingSimulationResult 用户与模型按差额绝对值降序，只保留前 TopN 项
+type BillingSimulationResult struct {
+	StartTimestamp int64                       `json:"start_timestamp"`
+	EndTimestamp   int64                       `json:"end_timestamp"`
+	Total          BillingSimulationTotals     `json:"total"`
+	Skipped        int64                       `json:"skipped"`
+	UserCount      int                         `json:"user_count"`
+	ModelCount     int                         `json:"model_count"`
+	Users          []BillingSimulationUserRow  `json:"users"`
+	Models         []BillingSimulationModelRow `json:"models"`
+}
+
+// loggedPricing 日志 other 字段中记录的计费参数
+type loggedPricing struct {
+	ModelRatio      float64
+	GroupRatio      float64
+	CompletionRatio float64
+	CacheRatio      float64
+	ModelPrice      float64
+	SpecialGroup    bool
+	ResellerMarkup  float64
+	TieredExpr      string
+
+	CacheCreationRatio   float64
+	CacheCreationRatio5m float64
+	CacheCreationRatio1h float64
+	ImageRatio           float64
+}
+
+type loggedUsage struct {
+	usage       *dto.Usage
+	audio       bool
+	claudeUsage bool
+}
+
+func otherFloat(other map[string]interface{}, key string) (float64, bool) {
+	switch v := other[key].(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func otherInt(other map[string]interface{}, key string) int {
+	v, _ := otherFloat(other, key)
+	return int(v)
+}
+
+// parseLoggedBilling 从消费日志还原计费参数与用量，缺少分组倍率的日志无法重放
+func parseLoggedBilling(log *model.Log) (*loggedPricing, *loggedUsage, bool) {
+	if log.Other == "" {
+		return nil, nil, false
+	}
+	other := map[string]interface{}{}
+	if err := common.UnmarshalJsonStr(log.Other, &other); err != nil {
+		return nil, nil, false
+	}
+	groupRatio, ok := otherFloat(other, "group_ratio")
+	if !ok {
+		return nil, nil, false
+	}
+	pricing := &loggedPricing{GroupRatio: groupRatio, ModelPrice: -1}
+	pricing.ModelRatio, _ = otherFloat(other, "model_ratio")
+	pricing.CompletionRatio, _ = otherFloat(other, "completion_ratio")
+	pricing.CacheRatio, _ = otherFloat(other, "cache_ratio")
+	if price, ok := otherFloat(other, "model_price"); ok {
+		pricing.ModelPrice = price
+	}
+	if special, ok := otherFloat(other, "user_group_ratio"); ok && special >= 0 {
+		pricing.SpecialGroup = true
+	}
+	pricing.ResellerMarkup, _ = otherFloat(other, "reseller_markup")
+	pricing.CacheCreationRatio, _ = otherFloat(other, "cache_creation_ratio")
+	pricing.CacheCreationRatio5m, _ = otherFloat(other, "cache_creation_ratio_5m")
+	pricing.CacheCreationRatio1h, _ = otherFloat(other, "cache_creation_ratio_1h")
+	pricing.ImageRatio, _ = otherFloat(other, "image_ratio")
+	if mode, _ := other["billing_mode"].(string); mode == billing_setting.BillingModeTieredExpr {
+		encoded, _ := other["expr_b64"].(string)
+		expr, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(expr) == 0 {
+			return nil, nil, false
+		}
+		pricing.TieredExpr = string(expr)
+	}
+
+	usage := &dto.Usage{
+		PromptTokens:     log.PromptTokens,
+		CompletionTokens: log.CompletionTokens,
+		TotalTokens:      log.PromptTokens + log.CompletionTokens,
+	}
+	usage.PromptTokensDetails.CachedTokens = otherInt(other, "cache_tokens")
+	usage.PromptTokensDetails.CachedCreationTokens = otherInt(other, "cache_creation_tokens")
+	usage.ClaudeCacheCreation5mTokens = otherInt(other, "cache_creation_tokens_5m")
+	usage.ClaudeCacheCreation1hTokens = otherInt(other, "cache_creation_tokens_1h")
+	if image, _ := other["image"].(bool); image {
+		usage.PromptTokensDetails.ImageTokens = otherInt(other, "image_output")
+	}
+	usage.PromptTokensDetails.AudioTokens = otherInt(other, "audio_input_token_count")
+	semantic, _ := other["usage_semantic"].(string)
+	usage.UsageSemantic = semantic
+
+	logged := &loggedUsage{usage: usage, claudeUsage: semantic == "anthropic"}
+	audio, _ := other["audio"].(bool)
+	ws, _ := other["ws"].(bool)
+	if audio || ws {
+		logged.audio = true
+		usage.PromptTokensDetails.TextTokens = otherInt(other, "text_input")
+		usage.PromptTokensDetails.AudioTokens = otherInt(other, "audio_input")
+		usage.CompletionTokenDetails.TextTokens = otherInt(other, "text_output")
+		usage.CompletionTokenDetails.AudioTokens = otherInt(other, "audio_output")
+	}
+	return pricing, logged, true
+}
+
+// candidatePricing 在日志价格上叠加候选价格
+func (r *BillingSimulationRequest) candidatePricing(log *model.Log, logged *loggedPricing) *loggedPricing {
+	candidate := *logged
+	name := log.ModelName
+	if ratio, ok := r.GroupRatio[log.Group]; ok && !logged.SpecialGroup {
+		// 日志中的 group_ratio 包含分销商加价，候选分组倍率同样需要叠加
+		if logged.ResellerMarkup > 0 {
+			ratio *= logged.ResellerMarkup
+		}
+		candidate.GroupRatio = ratio
+	}
+
+	mode, modeSet := r.BillingMode[name]
+	switch {
+	case modeSet && mode == billing_setting.BillingModeTieredExpr:
+		if expr, ok := r.BillingExpr[name]; ok {
+			candidate.TieredExpr = expr
+		}
+		if candidate.TieredExpr == "" {
+			if expr, ok := billing_setting.GetBillingExpr(name); ok {
+				candidate.TieredExpr = expr
+			}
+		}
+	case modeSet && mode == billing_setting.BillingModeRatio && logged.TieredExpr != "":
+		// 从分段计费切回倍率计费时，日志里没有倍率，未指定的项取当前设置
+		candidate.TieredExpr = ""
+		candidate.ModelPrice, _ = ratio_setting.GetModelPrice(name, false)
+		candidate.ModelRatio, _, _ = ratio_setting.GetModelRatio(name)
+		candidate.CompletionRatio = ratio_setting.GetCompletionRatio(name)
+		candidate.CacheRatio, _ = ratio_setting.GetCacheRatio(name)
+	case !modeSet && logged.TieredExpr != "":
+		if expr, ok := r.BillingExpr[name]; ok {
+			candidate.TieredExpr = expr
+		}
+	}
+	if candidate.TieredExpr != "" {
+		return &candidate
+	}
+
+	if price, ok := r.ModelPrice[name]; ok {
+		candidate.ModelPrice = price
+	}
+	if ratio, ok := r.ModelRatio[name]; ok {
+		candidate.ModelRatio = ratio
+	}
+	if ratio, ok := r.CompletionRatio[name]; ok {
+		candidate.CompletionRatio = ratio
+	}
+	if ratio, ok := r.CacheRatio[name]; ok {
+		candidate.CacheRatio = ratio
+	}
+	return &candidate
+}
+
+func (p *loggedPricing) usePrice() bool {
+	return p.TieredExpr == "" && p.ModelPrice >= 0
+}
+
+// replayQuota 用给定价格重新结算一条日志，复用线上的倍率结算与分段表达式结算逻辑
+func replayQuota(modelName string, pricing *loggedPricing, logged *loggedUsage) (int, error) {
+	if pricing.TieredExpr != "" {
+		snap := &billingexpr.BillingSnapshot{
+			BillingMode:  billing_setting.BillingModeTieredExpr,
+			ModelName:    modelName,
+			ExprString:   pricing.TieredExpr,
+			ExprHash:     billingexpr.ExprHashString(pricing.TieredExpr),
+			GroupRatio:   pricing.GroupRatio,
+			QuotaPerUnit: common.QuotaPerUnit,
+			ExprVersion:  billingexpr.ExprVersion(pricing.TieredExpr),
+		}
+		params := BuildTieredTokenParams(logged.usage, logged.claudeUsage, billingexpr.UsedVars(pricing.TieredExpr))
+		result, err := billingexpr.ComputeTieredQuota(snap, params)
+		if err != nil {
+			return 0, err
+		}
+		return result.ActualQuotaAfterGroup, nil
+	}
+	if logged.audio || pricing.usePrice() {
+		quota, _ := calculateAudioQuota(QuotaInfo{
+			InputDetails: TokenDetails{
+				TextTokens:  logged.usage.PromptTokensDetails.TextTokens,
+				AudioTokens: logged.usage.PromptTokensDetails.AudioTokens,
+			},
+			OutputDetails: TokenDetails{
+				TextTokens:  logged.usage.CompletionTokenDetails.TextTokens,
+				AudioTokens: logged.usage.CompletionTokenDetails.AudioTokens,
+			},
+			ModelName:  modelName,
+			UsePrice:   pricing.usePrice(),
+			ModelPrice: pricing.ModelPrice,
+			ModelRatio: pricing.ModelRatio,
+			GroupRatio: pricing.GroupRatio,
+		})
+		return quota, nil
+	}
+	relayInfo := &relaycommon.RelayInfo{
+		OriginModelName: modelName,
+		StartTime:       time.Now(),
+		PriceData: hosttypes.PriceData{
+			ModelRatio:           pricing.ModelRatio,
+			CompletionRatio:      pricing.CompletionRatio,
+			CacheRatio:           pricing.CacheRatio,
+			ImageRatio:           pricing.ImageRatio,
+			ModelPrice:           pricing.ModelPrice,
+			CacheCreationRatio:   pricing.CacheCreationRatio,
+			CacheCreation5mRatio: pricing.CacheCreationRatio5m,
+			CacheCreation1hRatio: pricing.CacheCreationRatio1h,
+			GroupRatioInfo:       hosttypes.GroupRatioInfo{GroupRatio: pricing.GroupRatio},
+		},
+	}
+	summary := calculateTextQuotaSummary(&gin.Context{}, relayInfo, logged.usage)
+	return summary.Quota, nil
+}
+
+// SimulateLogQuota 返回一条消费日志在候选价格下应扣的额度；日志缺少计费参数时 ok 为 false
+func (r *BillingSimulationRequest) SimulateLogQuota(log *model.Log) (int, bool) {
+	logged, usage, ok := parseLoggedBilling(log)
+	if !ok {
+		return 0, false
+	}
+	candidate := r.candidatePricing(log, logged)
+	base, err := replayQuota(log.ModelName, logged, usage)
+	if err != nil {
+		return 0, false
+	}
+	simulated, err := replayQuota(log.ModelName, candidate, usage)
+	if err != nil {
+		return 0, false
+	}
+	if base == simulated {
+		return log.Quota, true
+	}
+	var quota decimal.Decimal
+	if logged.usePrice() && candidate.usePrice() && base > 0 {
+		quota = decimal.NewFromInt(int64(log.Quota)).Mul(decimal.NewFromInt(int64(simulated))).Div(decimal.NewFromInt(int64(base)))
+	} else {
+		quota = decimal.NewFromInt(int64(log.Quota + simulated - base))
+	}
+	if quota.IsNegative() {
+		return 0, true
+	}
+	return common.QuotaFromDecimal(quota), true
+}
+
+// RunBillingSimulation 重放窗口内的消费日志，progress 可为 nil
+func RunBillingSimulation(ctx context.Context, req *BillingSimulationRequest, progress func(processed, total int)) (*BillingSimulationResult, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	filter := req.filter()
+	total, err := model.CountConsumeLogs(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	result := &BillingSimulationResult{StartTimestamp: req.StartTimestamp, EndTimestamp: req.EndTimestamp}
+	users := make(map[int]*BillingSimulationUserRow)
+	models := make(map[string]*BillingSimulationModelRow)
+	processed := 0
+	err = model.ScanConsumeLogs(ctx, filter, billingSimulationBatchSize, func(logs []*model.Log) error {
+		for _, log := range logs {
+			simulated, ok := req.SimulateLogQuota(log)
+			if !ok {
+				result.Skipped++
+				continue
+			}
+			result.Total.add(log.Quota, simulated)
+			user := users[log.UserId]
+			if user == nil {
+				user = &BillingSimulationUserRow{UserId: log.UserId, Username: log.Username}
+				users[log.UserId] = user
+			}
+			user.add(log.Quota, simulated)
+			row := models[log.ModelName]
+			if row == nil {
+				row = &BillingSimulationModelRow{ModelName: log.ModelName}
+				models[log.ModelName] = row
+			}
+			row.add(log.Quota, simulated)
+		}
+		processed += len(logs)
+		if progress != nil {
+			progress(processed, int(total))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	topN := req.TopN
+	if topN <= 0 {
+		topN = billingSimulationDefaultTop
+	}
+	result.UserCount = len(users)
+	result.ModelCount = len(models)
+	result.Users = make([]BillingSimulationUserRow, 0, len(users))
+	for _, row := range users {
+		result.Users = append(result.Users, *row)
+	}
+	sort.Slice(result.Users, func(i, j int) bool {
+		di, dj := absInt64(result.Users[i].DeltaQuota), absInt64(result.Users[j].DeltaQuota)
+		if di != dj {
+			return di > dj
+		}
+		return result.Users[i].UserId < result.Users[j].UserId
+	})
+	if len(result.Users) > topN {
+		result.Users = result.Users[:topN]
+	}
+	result.Models = make([]BillingSimulationModelRow, 0, len(models))
+	for _, row := range models {
+		result.Models = append(result.Models, *row)
+	}
+	sort.Slice(result.Models, func(i, j int) bool {
+		di, dj := absInt64(result.Models[i].DeltaQuota), absInt64(result.Models[j].DeltaQuota)
+		if di != dj {
+			return di > dj
+		}
+		return result.Models[i].ModelName < result.Models[j].ModelName
+	})
+	if len(result.Models) > topN {
+		result.Models = result.Models[:topN]
+	}
+	if progress != nil && total == 0 {
+		progress(0, 0)
+	}
+	return result, nil
+}
+
+func absInt64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// billingSimulationHandler 在系统任务中运行大窗口的计费模拟，由 EnqueueSystemTask 创建
+type billingSimulationHandler struct{}
+
+func (billingSimulationHandler) Type() string { return model.SystemTaskTypeBillingSim }
+
+func (billingSimulationHandler) Run(ctx context.Context, task *model.SystemTask, runnerID string) {
+	req := &BillingSimulationRequest{}
+	if err := task.DecodePayload(req); err != nil {
+		failSystemTask(task, runnerID, err)
+		return
+	}
+	result, err := RunBillingSimulation(ctx, req, NewSystemTaskProgressReporter(task, runnerID))
+	if err != nil {
+		failSystemTask(task, runnerID, err)
+		return
+	}
+	if err := model.FinishSystemTask(task.TaskID, runnerID, model.SystemTaskStatusSucceeded, result, ""); err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("system task %s failed to persist result: %v", task.TaskID, err))
+	}
+}
+
+func init() {
+	RegisterSystemTaskHandler(billingSimulationHandler{})
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seedSimulationLog(t *testing.T, userId int, modelName, group string, createdAt int64, prompt, completion, quota int, other string) {
+	t.Helper()
+	log := &model.Log{
+		UserId:           userId,
+		Username:         "test_user",
+		CreatedAt:        createdAt,
+		Type:             model.LogTypeConsume,
+		ModelName:        modelName,
+		Group:            group,
+		PromptTokens:     prompt,
+		CompletionTokens: completion,
+		Quota:            quota,
+		Other:            other,
+	}
+	require.NoError(t, model.LOG_DB.Create(log).Error)
+}
+
+func TestSimulateLogQuotaKeepsUnchangedPrices(t *testing.T) {
+	req := &BillingSimulationRequest{ModelRatio: map[string]float64{"other-model": 9}}
+	log := &model.Log{
+		ModelName:        "sim-model",
+		PromptTokens:     1000,
+		CompletionTokens: 500,
+		// 附加费使实际扣费高于按倍率计算的额度，价格不变时应原样保留
+		Quota: 2100,
+		Other: `{"model_ratio":1,"completion_ratio":2,"group_ratio":1,"model_price":-1}`,
+	}
+	quota, ok := req.SimulateLogQuota(log)
+	require.True(t, ok)
+	assert.Equal(t, 2100, quota)
+
+	_, ok = req.SimulateLogQuota(&model.Log{ModelName: "sim-model", Other: `{"model_ratio":1}`})
+	assert.False(t, ok, "logs without a group ratio cannot be replayed")
+}
+
+func TestRunBillingSimulationReportsDeltasWithoutChargingUsers(t *testing.T) {
+	truncate(t)
+	seedUser(t, 1, 10000)
+
+	seedSimulationLog(t, 1, "sim-model", "default", 1000, 1000, 500, 2000,
+		`{"model_ratio":1,"completion_ratio":2,"group_ratio":1,"model_price":-1}`)
+	seedSimulationLog(t, 2, "sim-model", "vip", 1001, 1000, 500, 1000,
+		`{"model_ratio":1,"completion_ratio":2,"group_ratio":0.5,"model_price":-1}`)
+	seedSimulationLog(t, 2, "sim-image", "vip", 1002, 0, 0, 2500,
+		`{"group_ratio":0.5,"model_price":0.01}`)
+	seedSimulationLog(t, 1, "sim-model", "default", 2000, 1000, 500, 2000,
+		`{"model_ratio":1,"completion_ratio":2,"group_ratio":1,"model_price":-1}`)
+
+	req := &BillingSimulationRequest{
+		StartTimestamp: 1000,
+		EndTimestamp:   2000,
+		GroupRatio:     map[string]float64{"default": 1.5},
+		ModelPrice:     map[string]float64{"sim-image": 0.02},
+	}
+	result, err := RunBillingSimulation(context.Background(), req, nil)
+	require.NoError(t, err)
+
+	assert.EqualValues(t, 3, result.Total.Requests, "the window end is exclusive")
+	assert.EqualValues(t, 5500, result.Total.ActualQuota)
+	assert.EqualValues(t, 9000, result.Total.SimulatedQuota)
+	assert.EqualValues(t, 3500, result.Total.DeltaQuota)
+	assert.Zero(t, result.Skipped)
+
+	require.Len(t, result.Users, 2)
+	assert.Equal(t, 2, result.Users[0].UserId)
+	assert.EqualValues(t, 2500, result.Users[0].DeltaQuota)
+	assert.EqualValues(t, 1000, result.Users[1].DeltaQuota)
+
+	require.Len(t, result.Models, 2)
+	assert.Equal(t, "sim-image", result.Models[0].ModelName)
+	assert.EqualValues(t, 5000, result.Models[0].SimulatedQuota)
+	assert.EqualValues(t, 1000, result.Models[1].DeltaQuota)
+
+	assert.Equal(t, 10000, getUserQuota(t, 1))
+}
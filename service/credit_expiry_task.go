@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	creditExpiryTickInterval = 5 * time.Minute
+	creditExpiryBatchSize    = 300
+)
+
+var (
+	creditExpiryOnce    sync.Once
+	creditExpiryRunning atomic.Bool
+)
+
+// StartCreditGrantExpiryTask 定期注销额度账本中已到期授予的剩余额度。
+func StartCreditGrantExpiryTask() {
+	creditExpiryOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("credit grant expiry task started: tick=%s", creditExpiryTickInterval))
+			ticker := time.NewTicker(creditExpiryTickInterval)
+			defer ticker.Stop()
+
+			runCreditGrantExpiryOnce()
+			for range ticker.C {
+				runCreditGrantExpiryOnce()
+			}
+		})
+	})
+}
+
+func runCreditGrantExpiryOnce() {
+	if !operation_setting.IsCreditLedgerEnabled() {
+		return
+	}
+	if !creditExpiryRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer creditExpiryRunning.Store(false)
+
+	ctx := context.Background()
+	total := 0
+	for {
+		n, err := model.ExpireDueCreditGrants(creditExpiryBatchSize)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("credit grant expiry task failed: %v", err))
+			return
+		}
+		total += n
+		if n < creditExpiryBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		logger.LogInfo(ctx, fmt.Sprintf("credit grant expiry task expired %d grants", total))
+	}
+}
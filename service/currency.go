@@ -0,0 +1,295 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/shopspring/decimal"
+)
+
+// 汇率文件按修改时间缓存，每个节点独立检查，无需跨节点同步
+const exchangeRateFileCheckInterval = 30 * time.Second
+
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+type exchangeRateFileCache struct {
+	mu        sync.RWMutex
+	path      string
+	modTime   time.Time
+	checkedAt time.Time
+	rates     map[string]float64
+}
+
+var rateFileCache exchangeRateFileCache
+
+// exchangeRateFile 汇率文件格式：可以是 {"EUR":0.92,"CNY":7.1}，
+// 也可以是 {"base":"EUR","rates":{"USD":1.08,"CNY":7.7}}，后者会换算为以 USD 为基准
+type exchangeRateFile struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// ParseExchangeRateFile 解析汇率文件内容，返回 1 USD 可兑换的各币种数量
+func ParseExchangeRateFile(data []byte) (map[string]float64, error) {
+	var file exchangeRateFile
+	if err := common.Unmarshal(data, &file); err != nil || file.Rates == nil {
+		flat := map[string]float64{}
+		if flatErr := common.Unmarshal(data, &flat); flatErr != nil {
+			if err != nil {
+				return nil, err
+			}
+			return nil, flatErr
+		}
+		file = exchangeRateFile{Base: "USD", Rates: flat}
+	}
+	base := operation_setting.NormalizeCurrency(file.Base)
+	if base == "" {
+		base = "USD"
+	}
+	usdRate := 1.0
+	if base != "USD" {
+		rate, ok := file.Rates[base]
+		if ok && rate != 1 {
+			return nil, fmt.Errorf("rate of base currency %s must be 1", base)
+		}
+		usdRate, ok = file.Rates["USD"]
+		if !ok || usdRate <= 0 {
+			return nil, fmt.Errorf("rate file based on %s must include USD", base)
+		}
+	}
+	rates := make(map[string]float64, len(file.Rates)+1)
+	for code, rate := range file.Rates {
+		if rate <= 0 {
+			return nil, fmt.Errorf("invalid rate for %s", code)
+		}
+		rates[operation_setting.NormalizeCurrency(code)] = rate / usdRate
+	}
+	rates[base] = 1 / usdRate
+	rates["USD"] = 1
+	return rates, nil
+}
+
+// fileExchangeRates 返回汇率文件中的汇率，文件变化后自动重新加载；读取失败时沿用上次的结果
+func fileExchangeRates() map[string]float64 {
+	path := strings.TrimSpace(operation_setting.GetCurrencySetting().RateFile)
+	now := time.Now()
+	rateFileCache.mu.RLock()
+	fresh := rateFileCache.path == path && now.Sub(rateFileCache.checkedAt) < exchangeRateFileCheckInterval
+	rates := rateFileCache.rates
+	rateFileCache.mu.RUnlock()
+	if fresh {
+		return rates
+	}
+
+	rateFileCache.mu.Lock()
+	defer rateFileCache.mu.Unlock()
+	if path != rateFileCache.path {
+		rateFileCache.path = path
+		rateFileCache.modTime = time.Time{}
+		rateFileCache.rates = nil
+	}
+	rateFileCache.checkedAt = now
+	if path == "" {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		common.SysError("failed to stat exchange rate file: " + err.Error())
+		return rateFileCache.rates
+	}
+	if info.ModTime().Equal(rateFileCache.modTime) {
+		return rateFileCache.rates
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		common.SysError("failed to read exchange rate file: " + err.Error())
+		return rateFileCache.rates
+	}
+	parsed, err := ParseExchangeRateFile(data)
+	if err != nil {
+		common.SysError("failed to parse exchange rate file: " + err.Error())
+		return rateFileCache.rates
+	}
+	rateFileCache.modTime = info.ModTime()
+	rateFileCache.rates = parsed
+	return parsed
+}
+
+// GetExchangeRate 返回 1 USD 可兑换的目标币种数量，汇率文件优先于手动配置
+func GetExchangeRate(currency string) (float64, error) {
+	currency = operation_setting.NormalizeCurrency(currency)
+	if currency == "" || currency == "USD" {
+		return 1, nil
+	}
+	if rate, ok := fileExchangeRates()[currency]; ok {
+		return rate, nil
+	}
+	cfg, ok := operation_setting.GetCurrencySetting().GetCurrencyConfig(currency)
+	if !ok || cfg.Rate <= 0 {
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, currency)
+	}
+	return cfg.Rate, nil
+}
+
+// IsSupportedCurrency 判断币种是否配置了可用汇率
+func IsSupportedCurrency(currency string) bool {
+	_, err := GetExchangeRate(currency)
+	return err == nil
+}
+
+// ConvertMoney 在两个币种之间换算金额
+func ConvertMoney(amount float64, from string, to string) (float64, error) {
+	fromRate, err := GetExchangeRate(from)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := GetExchangeRate(to)
+	if err != nil {
+		return 0, err
+	}
+	return decimal.NewFromFloat(amount).
+		Div(decimal.NewFromFloat(fromRate)).
+		Mul(decimal.NewFromFloat(toRate)).
+		InexactFloat64(), nil
+}
+
+// CurrencyAmount 以指定币种表示的金额
+type CurrencyAmount struct {
+	Currency string  `json:"currency"`
+	Symbol   string  `json:"symbol"`
+	Rate     float64 `json:"rate"`
+	Amount   float64 `json:"amount"`
+}
+
+func newCurrencyAmount(currency string, usd decimal.Decimal) (*CurrencyAmount, error) {
+	currency = operation_setting.NormalizeCurrency(currency)
+	if currency == "" {
+		currency = "USD"
+	}
+	rate, err := GetExchangeRate(currency)
+	if err != nil {
+		return nil, err
+	}
+	cfg, _ := operation_setting.GetCurrencySetting().GetCurrencyConfig(currency)
+	decimals := cfg.Decimals
+	if decimals <= 0 {
+		decimals = 2
+	}
+	return &CurrencyAmount{
+		Currency: currency,
+		Symbol:   cfg.Symbol,
+		Rate:     rate,
+		Amount:   usd.Mul(decimal.NewFromFloat(rate)).Round(int32(decimals)).InexactFloat64(),
+	}, nil
+}
+
+// QuotaInCurrency 把额度换算为指定币种的金额
+func QuotaInCurrency(quota int, currency string) (*CurrencyAmount, error) {
+	usd := decimal.NewFromInt(int64(quota)).Div(decimal.NewFromFloat(common.QuotaPerUnit))
+	return newCurrencyAmount(currency, usd)
+}
+
+// MoneyInCurrency 把某币种的金额换算为另一币种的展示金额
+func MoneyInCurrency(amount float64, from string, to string) (*CurrencyAmount, error) {
+	fromRate, err := GetExchangeRate(from)
+	if err != nil {
+		return nil, err
+	}
+	return newCurrencyAmount(to, decimal.NewFromFloat(amount).Div(decimal.NewFromFloat(fromRate)))
+}
+
+// ResolveUserCurrency 返回用户的展示币种：用户设置优先，其次是站点的额度展示类型
+func ResolveUserCurrency(preferred string) string {
+	if preferred = operation_setting.NormalizeCurrency(preferred); preferred != "" && IsSupportedCurrency(preferred) {
+		return preferred
+	}
+	if operation_setting.IsCNYDisplay() {
+		return "CNY"
+	}
+	return "USD"
+}
+
+// CurrencyOption 充值页展示的币种与价格
+type CurrencyOption struct {
+	Currency   string  `json:"currency"`
+	Symbol     string  `json:"symbol"`
+	Rate       float64 `json:"rate"`
+	TopUpPrice float64 `json:"topup_price"`
+}
+
+// ListCurrencyOptions 列出配置的币种，按代码排序；汇率不可用的币种会被跳过
+func ListCurrencyOptions() []CurrencyOption {
+	setting := operation_setting.GetCurrencySetting()
+	codes := make([]string, 0, len(setting.Currencies)+1)
+	seen := map[string]bool{}
+	for code := range setting.Currencies {
+		code = operation_setting.NormalizeCurrency(code)
+		if code != "" && !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	if !seen["USD"] {
+		codes = append(codes, "USD")
+	}
+	sort.Strings(codes)
+	options := make([]CurrencyOption, 0, len(codes))
+	for _, code := range codes {
+		rate, err := GetExchangeRate(code)
+		if err != nil {
+			continue
+		}
+		cfg, _ := setting.GetCurrencyConfig(code)
+		options = append(options, CurrencyOption{
+			Currency:   code,
+			Symbol:     cfg.Symbol,
+			Rate:       rate,
+			TopUpPrice: GetTopUpUnitPrice(code, rate),
+		})
+	}
+	return options
+}
+
+// GetTopUpUnitPrice 返回币种每单位充值额度的售价，价格表未配置时使用 fallback
+func GetTopUpUnitPrice(currency string, fallback float64) float64 {
+	if price, ok := operation_setting.GetCurrencySetting().GetTopUpPrice(currency); ok {
+		return price
+	}
+	return fallback
+}
+
+// OrderCurrency 返回写入订单的币种与下单时的汇率；汇率未知时记录为 0
+func OrderCurrency(currency string) (string, float64) {
+	currency = operation_setting.NormalizeCurrency(currency)
+	if currency == "" {
+		currency = "USD"
+	}
+	rate, err := GetExchangeRate(currency)
+	if err != nil {
+		return currency, 0
+	}
+	return currency, rate
+}
+
+// SubscriptionPlanPrice 返回套餐在指定币种下的售价：优先使用套餐的币种价格表，
+// 否则按汇率从套餐基础价格换算
+func SubscriptionPlanPrice(plan *model.SubscriptionPlan, currency string) (*CurrencyAmount, error) {
+	currency = operation_setting.NormalizeCurrency(currency)
+	if price, ok := plan.GetCurrencyPrices()[currency]; ok {
+		return MoneyInCurrency(price, currency, currency)
+	}
+	base := plan.Currency
+	if base == "" {
+		base = "USD"
+	}
+	return MoneyInCurrency(plan.PriceAmount, base, currency)
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useCurrencySetting(t *testing.T, currencies map[string]operation_setting.CurrencyConfig, rateFile string) {
+	t.Helper()
+	setting := operation_setting.GetCurrencySetting()
+	previous := *setting
+	setting.Currencies = currencies
+	setting.RateFile = rateFile
+	rateFileCache.mu.Lock()
+	rateFileCache.path = "-"
+	rateFileCache.mu.Unlock()
+	t.Cleanup(func() {
+		*setting = previous
+		rateFileCache.mu.Lock()
+		rateFileCache.path = "-"
+		rateFileCache.mu.Unlock()
+	})
+}
+
+func TestParseExchangeRateFileConvertsBase(t *testing.T) {
+	rates, err := ParseExchangeRateFile([]byte(`{"EUR":0.9,"cny":7.2}`))
+	require.NoError(t, err)
+	assert.Equal(t, 0.9, rates["EUR"])
+	assert.Equal(t, 7.2, rates["CNY"])
+	assert.Equal(t, 1.0, rates["USD"])
+
+	rates, err = ParseExchangeRateFile([]byte(`{"base":"EUR","rates":{"USD":1.25,"CNY":9}}`))
+	require.NoError(t, err)
+	assert.InDelta(t, 0.8, rates["EUR"], 1e-9)
+	assert.InDelta(t, 7.2, rates["CNY"], 1e-9)
+
+	_, err = ParseExchangeRateFile([]byte(`{"base":"EUR","rates":{"CNY":9}}`))
+	assert.Error(t, err, "a non-USD base needs a USD rate")
+	_, err = ParseExchangeRateFile([]byte(`{"EUR":-1}`))
+	assert.Error(t, err)
+}
+
+func TestExchangeRateFileOverridesManualRates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"EUR":0.8}`), 0o600))
+	useCurrencySetting(t, map[string]operation_setting.CurrencyConfig{
+		"EUR": {Symbol: "€", Rate: 0.9, Decimals: 2},
+		"JPY": {Symbol: "¥", Rate: 150, Decimals: 0},
+	}, path)
+
+	rate, err := GetExchangeRate("eur")
+	require.NoError(t, err)
+	assert.Equal(t, 0.8, rate)
+	rate, err = GetExchangeRate("JPY")
+	require.NoError(t, err)
+	assert.Equal(t, 150.0, rate)
+	_, err = GetExchangeRate("GBP")
+	assert.ErrorIs(t, err, ErrUnsupportedCurrency)
+
+	balance, err := QuotaInCurrency(int(common.QuotaPerUnit*10)+1, "JPY")
+	require.NoError(t, err)
+	assert.Equal(t, 1500.0, balance.Amount, "JPY has no minor unit")
+	assert.Equal(t, "¥", balance.Symbol)
+
+	currency, orderRate := OrderCurrency("eur")
+	assert.Equal(t, "EUR", currency)
+	assert.Equal(t, 0.8, orderRate)
+}
+
+func TestSubscriptionPlanPricePrefersCurrencyPriceList(t *testing.T) {
+	useCurrencySetting(t, map[string]operation_setting.CurrencyConfig{
+		"EUR": {Symbol: "€", Rate: 0.9, Decimals: 2},
+	}, "")
+	plan := &model.SubscriptionPlan{PriceAmount: 10, Currency: "USD", CurrencyPrices: `{"eur":8.5}`}
+
+	price, err := SubscriptionPlanPrice(plan, "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, 8.5, price.Amount)
+
+	price, err = SubscriptionPlanPrice(plan, "USD")
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, price.Amount)
+
+	plan.CurrencyPrices = ""
+	price, err = SubscriptionPlanPrice(plan, "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, 9.0, price.Amount)
+}
@@ -2,18 +2,21 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"time"
 
+	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
 )
 
 // ---------------------------------------------------------------------------
-// FundingSource — 资金来源接口（钱包 or 订阅）
+// FundingSource — 资金来源接口（钱包 / 订阅 / 组织）
 // ---------------------------------------------------------------------------
 
 // FundingSource 抽象了预扣费的资金来源。
 type FundingSource interface {
-	// Source 返回资金来源标识："wallet" 或 "subscription"
+	// Source 返回资金来源标识："wallet"、"subscription" 或 "organization"
 	Source() string
 	// PreConsume 从该资金来源预扣 amount 额度
 	PreConsume(amount int) error
@@ -32,45 +35,255 @@ type FundingSource interface {
 // 使 wallet_first 等计费偏好可以回退到订阅。
 var ErrInsufficientWalletQuota = errors.New("wallet quota insufficient")
 
+// ErrInsufficientResellerQuota 分销商钱包不足以支付下游用户的批发价，或分销商已被禁用，未发生任何扣减。
+var ErrInsufficientResellerQuota = errors.New("reseller quota insufficient")
+
 type WalletFunding struct {
-	userId   int
-	consumed int // 实际预扣的用户额度
+	userId    int
+	requestId string // 额度账本分录的 reference，退款据此冲回原扣减
+	consumed  int    // 实际预扣的用户额度
+
+	// 分销商下游用户之上的各级分销商（由近到远）。用户按零售价扣费，
+	// 各级分销商按 零售额度 / Divisor 的批发价从自己的钱包扣费。
+	resellers []model.ResellerLevel
+	retail    int   // 本资金来源累计向用户收取的零售额度
+	wholesale []int // 各级分销商累计被收取的批发额度
+}
+
+// newWalletFunding 创建钱包资金来源，resellerId 非 0 时解析其上各级分销商
+func newWalletFunding(userId int, resellerId int, requestId string) (*WalletFunding, error) {
+	w := &WalletFunding{userId: userId, requestId: requestId}
+	if resellerId <= 0 {
+		return w, nil
+	}
+	levels, _, err := model.GetResellerChain(resellerId)
+	if err != nil {
+		return nil, err
+	}
+	w.resellers = levels
+	w.wholesale = make([]int, len(levels))
+	return w, nil
+}
+
+// walletFundingForUser 为异步结算、退款等路径创建钱包资金来源，分销商从用户缓存解析；
+// 解析失败时只调整用户钱包并记录日志，不中断结算。
+func walletFundingForUser(userId int, requestId string) *WalletFunding {
+	resellerId := 0
+	if user, err := model.GetUserCache(userId); err == nil {
+		resellerId = user.ResellerId
+	}
+	w, err := newWalletFunding(userId, resellerId, requestId)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to resolve resellers of user %d: %s", userId, err.Error()))
+		return &WalletFunding{userId: userId, requestId: requestId}
+	}
+	return w
 }
 
 func (w *WalletFunding) Source() string { return BillingSourceWallet }
 
 func (w *WalletFunding) PreConsume(amount int) error {
+	for _, level := range w.resellers {
+		if level.Disabled {
+			return fmt.Errorf("%w: reseller %d is disabled", ErrInsufficientResellerQuota, level.ResellerId)
+		}
+	}
 	if amount <= 0 {
-		return nil
+		return w.checkResellerQuota()
 	}
-	reserved, err := model.TryReserveUserQuota(w.userId, amount)
+	reserved, err := model.TryReserveUserQuota(w.userId, amount, w.ledgerRef())
 	if err != nil {
 		return err
 	}
 	if !reserved {
 		return ErrInsufficientWalletQuota
 	}
+	if err := w.reserveResellers(amount); err != nil {
+		if refundErr := model.IncreaseUserQuota(w.userId, amount, false, w.ledgerRef()); refundErr != nil {
+			common.SysError("failed to roll back wallet reserve after reseller reserve failure: " + refundErr.Error())
+		}
+		return err
+	}
 	w.consumed = amount
 	return nil
 }
 
 func (w *WalletFunding) Settle(delta int) error {
+	return w.charge(delta)
+}
+
+func (w *WalletFunding) Refund() error {
+	if w.consumed <= 0 {
+		return nil
+	}
+	// IncreaseUserQuota 是 quota += N 的非幂等操作，不能重试，否则会多退额度。
+	// 订阅的 RefundSubscriptionPreConsume 有 requestId 幂等保护所以可以重试。
+	return w.charge(-w.consumed)
+}
+
+// charge 无条件调整钱包：delta > 0 扣费（余额不足的部分记为欠费），delta < 0 退还；
+// 各级分销商的批发额度随之调整。
+func (w *WalletFunding) charge(delta int) error {
 	if delta == 0 {
 		return nil
 	}
+	var err error
 	if delta > 0 {
-		return model.DecreaseUserQuota(w.userId, delta, false)
+		err = model.DecreaseUserQuota(w.userId, delta, false, w.ledgerRef())
+	} else {
+		err = model.IncreaseUserQuota(w.userId, -delta, false, w.ledgerRef())
+	}
+	if err != nil {
+		return err
 	}
-	return model.IncreaseUserQuota(w.userId, -delta, false)
+	w.adjustResellers(delta)
+	return nil
 }
 
-func (w *WalletFunding) Refund() error {
-	if w.consumed <= 0 {
+// resellerDeltas 返回用户零售额度变化 delta 后各级分销商批发额度的变化。
+// 按累计额度取整，保证多次结算后批发额度与一次性计算的结果一致。
+func (w *WalletFunding) resellerDeltas(delta int) []int {
+	retail := w.retail + delta
+	deltas := make([]int, len(w.resellers))
+	for i, level := range w.resellers {
+		deltas[i] = int(math.Round(float64(retail)/level.Divisor)) - w.wholesale[i]
+	}
+	return deltas
+}
+
+// checkResellerQuota 在无需预扣时确认各级分销商钱包仍有余额
+func (w *WalletFunding) checkResellerQuota() error {
+	for _, level := range w.resellers {
+		quota, err := model.GetUserAvailableQuota(level.ResellerId)
+		if err != nil {
+			return err
+		}
+		if quota <= 0 {
+			return fmt.Errorf("%w: reseller %d", ErrInsufficientResellerQuota, level.ResellerId)
+		}
+	}
+	return nil
+}
+
+// reserveResellers 从各级分销商钱包原子预扣批发额度，任一级不足时回滚已预扣的层级
+func (w *WalletFunding) reserveResellers(delta int) error {
+	if len(w.resellers) == 0 {
 		return nil
 	}
-	// IncreaseUserQuota 是 quota += N 的非幂等操作，不能重试，否则会多退额度。
-	// 订阅的 RefundSubscriptionPreConsume 有 requestId 幂等保护所以可以重试。
-	return model.IncreaseUserQuota(w.userId, w.consumed, false)
+	deltas := w.resellerDeltas(delta)
+	for i, level := range w.resellers {
+		amount := deltas[i]
+		if amount <= 0 {
+			continue
+		}
+		reserved, err := model.TryReserveUserQuota(level.ResellerId, amount, w.resellerRef())
+		if err == nil && reserved {
+			continue
+		}
+		for j := 0; j < i; j++ {
+			w.adjustResellerWallet(w.resellers[j].ResellerId, -deltas[j])
+		}
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: reseller %d", ErrInsufficientResellerQuota, level.ResellerId)
+	}
+	w.applyResellerDeltas(delta, deltas)
+	return nil
+}
+
+// adjustResellers 无条件调整各级分销商的批发额度，失败只记录日志：
+// 此时用户侧已提交，中断结算会造成用户重复扣费。
+func (w *WalletFunding) adjustResellers(delta int) {
+	if len(w.resellers) == 0 {
+		return
+	}
+	deltas := w.resellerDeltas(delta)
+	for i, level := range w.resellers {
+		w.adjustResellerWallet(level.ResellerId, deltas[i])
+	}
+	w.applyResellerDeltas(delta, deltas)
+}
+
+func (w *WalletFunding) adjustResellerWallet(resellerId int, amount int) {
+	var err error
+	if amount > 0 {
+		err = model.DecreaseUserQuota(resellerId, amount, false, w.resellerRef())
+	} else if amount < 0 {
+		err = model.IncreaseUserQuota(resellerId, -amount, false, w.resellerRef())
+	}
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to adjust reseller %d wallet by %d for user %d: %s", resellerId, amount, w.userId, err.Error()))
+	}
+}
+
+// applyResellerDeltas 累计已收取的零售与批发额度，并写入分销商用量报表
+func (w *WalletFunding) applyResellerDeltas(delta int, deltas []int) {
+	retail := delta
+	for i, level := range w.resellers {
+		w.wholesale[i] += deltas[i]
+		if err := model.RecordResellerUsage(level.ResellerId, w.userId, retail, deltas[i]); err != nil {
+			common.SysLog("failed to record reseller usage: " + err.Error())
+		}
+		// 上一级分销商的零售额度就是本级分销商支付的批发额度
+		retail = deltas[i]
+	}
+	w.retail += delta
+}
+
+// ledgerRef / resellerRef 标识额度账本的对方科目，与余额变动在同一事务内记账。
+func (w *WalletFunding) ledgerRef() model.CreditRef {
+	return model.CreditRef{Source: model.CreditSourceUsage, Reference: w.requestId}
+}
+
+func (w *WalletFunding) resellerRef() model.CreditRef {
+	return model.CreditRef{Source: model.CreditSourceReseller, Reference: fmt.Sprintf("%s:user:%d", w.requestId, w.userId)}
+}
+
+// ---------------------------------------------------------------------------
+// OrganizationFunding — 组织共享钱包资金来源实现
+// ---------------------------------------------------------------------------
+
+// ErrInsufficientOrganizationQuota 组织钱包余额不足或成员超出消费上限，未发生任何扣减。
+var ErrInsufficientOrganizationQuota = errors.New("organization quota insufficient")
+
+// OrganizationFunding 从组织共享钱包扣费，同时累计成员在该组织内的消费，
+// 用于成员消费上限检查。用于 Token.OrgId 非 0 的令牌。
+type OrganizationFunding struct {
+	orgId    int
+	userId   int
+	consumed int
+}
+
+func (o *OrganizationFunding) Source() string { return BillingSourceOrganization }
+
+func (o *OrganizationFunding) PreConsume(amount int) error {
+	if amount <= 0 {
+		return nil
+	}
+	err := model.TryReserveOrganizationQuota(o.orgId, o.userId, amount)
+	if errors.Is(err, model.ErrOrganizationQuotaInsufficient) || errors.Is(err, model.ErrOrganizationMemberLimitReached) {
+		return fmt.Errorf("%w: %s", ErrInsufficientOrganizationQuota, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+	o.consumed = amount
+	return nil
+}
+
+func (o *OrganizationFunding) Settle(delta int) error {
+	return model.AdjustOrganizationQuota(o.orgId, o.userId, delta)
+}
+
+func (o *OrganizationFunding) Refund() error {
+	if o.consumed <= 0 {
+		return nil
+	}
+	// AdjustOrganizationQuota 基于事务，失败时不会部分生效，可以重试。
+	return refundWithRetry(func() error {
+		return model.AdjustOrganizationQuota(o.orgId, o.userId, -o.consumed)
+	})
 }
 
 // ---------------------------------------------------------------------------
@@ -156,6 +156,7 @@ func appendBillingInfo(relayInfo *relaycommon.RelayInfo, other map[string]interf
 	if relayInfo == nil || other == nil {
 		return
 	}
+	appendResellerInfo(relayInfo, other)
 	// billing_source: "wallet" or "subscription"
 	if relayInfo.BillingSource != "" {
 		other["billing_source"] = relayInfo.BillingSource
@@ -298,9 +299,21 @@ func GenerateMjOtherInfo(relayInfo *relaycommon.RelayInfo, priceData hosttypes.P
 		other["user_group_ratio"] = priceData.GroupRatioInfo.GroupSpecialRatio
 	}
 	appendRequestPath(nil, relayInfo, other)
+	appendResellerInfo(relayInfo, other)
 	return other
 }
 
+// appendResellerInfo 记录分销商加价；group_ratio 已包含该加价，账单模拟据此还原基础分组倍率
+func appendResellerInfo(relayInfo *relaycommon.RelayInfo, other map[string]interface{}) {
+	if relayInfo == nil || other == nil || relayInfo.ResellerId == 0 {
+		return
+	}
+	other["reseller_id"] = relayInfo.ResellerId
+	if markup := relayInfo.PriceData.GroupRatioInfo.ResellerMarkup; markup > 0 {
+		other["reseller_markup"] = markup
+	}
+}
+
 // InjectTieredBillingInfo overlays tiered billing fields onto an existing
 // module-specific other map. Call this after GenerateTextOtherInfo /
 // GenerateClaudeOtherInfo / etc. when the request used tiered_expr billing.
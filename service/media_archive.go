@@ -0,0 +1,551 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	mediaArchiveFetchTimeout     = 5 * time.Minute
+	mediaRetentionInterval       = time.Hour
+	mediaRetentionBatchSize      = 100
+	mediaArchiveBillingModelName = "media-archive"
+)
+
+var (
+	ErrMediaTooLarge         = errors.New("media exceeds the archive size limit")
+	ErrMediaQuotaExceeded    = errors.New("media storage quota exceeded")
+	ErrMediaURLNotArchivable = errors.New("media url is not archivable")
+	ErrMediaSignatureInvalid = errors.New("invalid or expired media signature")
+)
+
+var (
+	mediaRetentionOnce sync.Once
+	// mediaArchiveReservations userId -> *mediaUserReservation
+	mediaArchiveReservations sync.Map
+)
+
+// MediaArchiveEnabled 判断指定类型的结果是否需要转存
+func MediaArchiveEnabled(kind string) bool {
+	setting := operation_setting.GetMediaArchiveSetting()
+	if !setting.Enabled {
+		return false
+	}
+	switch kind {
+	case model.MediaKindVideo:
+		return setting.ArchiveVideo
+	case model.MediaKindImage:
+		return setting.ArchiveImage
+	case model.MediaKindAudio:
+		return setting.ArchiveAudio
+	}
+	return false
+}
+
+// SignedMediaURL 返回文件的网关签名地址，在签名有效期或文件保留期结束时失效。
+// 保存到任务结果中的地址由 model.RefreshMediaURLs 在读取时重新签发
+func SignedMediaURL(asset *model.MediaAsset) string {
+	return model.SignMediaURL(asset.Id, asset.ExpiresAt)
+}
+
+// VerifyMediaSignature 校验签名地址，retain 为签发时的文件保留截止时间
+func VerifyMediaSignature(id int, expires int64, retain int64, signature string) error {
+	if expires <= 0 || common.GetTimestamp() > expires {
+		return ErrMediaSignatureInvalid
+	}
+	if !hmac.Equal([]byte(model.MediaSignature(id, expires, retain)), []byte(signature)) {
+		return ErrMediaSignatureInvalid
+	}
+	return nil
+}
+
+// isArchivableMediaURL 仅转存外部 http(s) 地址；data URL 不会过期，网关自身的地址无需转存
+func isArchivableMediaURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return false
+	}
+	serverAddress := strings.TrimRight(system_setting.ServerAddress, "/")
+	return serverAddress == "" || !strings.HasPrefix(rawURL, serverAddress+"/")
+}
+
+// ArchiveMediaURL 下载上游结果并写入存储，按用户分组检查存储上限、设置保留期并按配置计费。
+// 失败时不保留文件记录
+func ArchiveMediaURL(ctx context.Context, userId int, source string, sourceId string, kind string, rawURL string) (*model.MediaAsset, error) {
+	asset, err := newPendingMediaAsset(userId, source, sourceId, kind, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := archiveMediaAsset(ctx, asset); err != nil {
+		if deleteErr := model.DeleteMediaAsset(asset.Id); deleteErr != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("delete unarchived media asset #%d failed: %v", asset.Id, deleteErr))
+		}
+		return nil, err
+	}
+	return asset, nil
+}
+
+// newPendingMediaAsset 创建转存中的文件记录，签名地址在转存完成前即可下发
+func newPendingMediaAsset(userId int, source string, sourceId string, kind string, rawURL string) (*model.MediaAsset, error) {
+	setting := operation_setting.GetMediaArchiveSetting()
+	if !isArchivableMediaURL(rawURL) {
+		return nil, ErrMediaURLNotArchivable
+	}
+	if _, err := GetMediaStorage(setting.Backend); err != nil {
+		return nil, err
+	}
+	group, _ := model.GetUserGroup(userId, false)
+	asset := &model.MediaAsset{
+		UserId:      userId,
+		Source:      source,
+		SourceId:    sourceId,
+		Kind:        kind,
+		Backend:     setting.Backend,
+		OriginalUrl: rawURL,
+		Status:      model.MediaAssetStatusPending,
+		CreatedAt:   common.GetTimestamp(),
+	}
+	if days := setting.RetentionDaysForGroup(group); days > 0 {
+		asset.ExpiresAt = asset.CreatedAt + int64(days)*86400
+	}
+	if err := model.CreateMediaAsset(asset); err != nil {
+		return nil, err
+	}
+	return asset, nil
+}
+
+// archiveMediaAsset 下载转存中的文件并写入存储。存储空间按用户预占，上传不持有锁；
+// 计费失败时删除已上传的文件，用户不会保留未付费的文件
+func archiveMediaAsset(ctx context.Context, asset *model.MediaAsset) error {
+	setting := operation_setting.GetMediaArchiveSetting()
+	if err := ValidateSSRFProtectedFetchURL(asset.OriginalUrl); err != nil {
+		return fmt.Errorf("%w: %v", ErrMediaURLNotArchivable, err)
+	}
+	storage, err := GetMediaStorage(setting.Backend)
+	if err != nil {
+		return err
+	}
+
+	download, err := fetchMediaForArchive(ctx, asset.OriginalUrl, setting.MaxAssetBytes())
+	if err != nil {
+		return err
+	}
+	defer download.Close()
+
+	group, _ := model.GetUserGroup(asset.UserId, false)
+	release, err := reserveMediaStorage(asset.UserId, download.size, setting.QuotaBytesForGroup(group))
+	if err != nil {
+		return err
+	}
+	// 记录落库后再释放预占，期间并发转存仍能看到这部分占用
+	defer release()
+
+	key, err := mediaStorageKey(asset.UserId, asset.Kind, asset.OriginalUrl, download.contentType)
+	if err != nil {
+		return err
+	}
+	if err := storage.Put(ctx, key, download.contentType, download.file, download.size); err != nil {
+		return err
+	}
+	asset.Backend = storage.Name()
+	asset.StorageKey = key
+	asset.ContentType = download.contentType
+	asset.Size = download.size
+	asset.Quota = mediaArchiveQuota(download.size, setting.PricePerGB, group)
+
+	funding, err := chargeMediaAsset(asset)
+	if err != nil {
+		_ = storage.Delete(ctx, key)
+		return err
+	}
+	if err := model.FinishMediaAsset(asset); err != nil {
+		_ = storage.Delete(ctx, key)
+		if funding != nil {
+			if refundErr := funding.Refund(); refundErr != nil {
+				logger.LogError(ctx, fmt.Sprintf("refund media asset #%d failed: %v", asset.Id, refundErr))
+			}
+		}
+		return err
+	}
+	if funding != nil {
+		recordMediaAssetCharge(asset, group)
+	}
+	return nil
+}
+
+// mediaUserReservation 用户正在转存、尚未落库的文件大小，检查存储上限时一并计入
+type mediaUserReservation struct {
+	mu      sync.Mutex
+	pending int64
+}
+
+// reserveMediaStorage 按用户加锁检查存储上限并预占空间，返回释放预占的函数
+func reserveMediaStorage(userId int, size int64, limit int64) (func(), error) {
+	value, _ := mediaArchiveReservations.LoadOrStore(userId, &mediaUserReservation{})
+	reservation := value.(*mediaUserReservation)
+	reservation.mu.Lock()
+	defer reservation.mu.Unlock()
+	if limit > 0 {
+		used, err := model.SumUserMediaAssetBytes(userId)
+		if err != nil {
+			return nil, err
+		}
+		if used+reservation.pending+size > limit {
+			return nil, fmt.Errorf("%w: used %d + %d bytes > %d bytes", ErrMediaQuotaExceeded, used+reservation.pending, size, limit)
+		}
+	}
+	reservation.pending += size
+	return func() {
+		reservation.mu.Lock()
+		reservation.pending -= size
+		reservation.mu.Unlock()
+	}, nil
+}
+
+// mediaDownload 下载到临时文件的上游结果，大文件不整体驻留内存
+type mediaDownload struct {
+	file        *os.File
+	size        int64
+	contentType string
+}
+
+func (d *mediaDownload) Close() {
+	_ = d.file.Close()
+	_ = os.Remove(d.file.Name())
+}
+
+func fetchMediaForArchive(ctx context.Context, rawURL string, maxBytes int64) (*mediaDownload, error) {
+	ctx, cancel := context.WithTimeout(ctx, mediaArchiveFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := GetSSRFProtectedHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch media returned status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxBytes {
+		return nil, ErrMediaTooLarge
+	}
+	file, err := os.CreateTemp("", "media-archive-*")
+	if err != nil {
+		return nil, err
+	}
+	download := &mediaDownload{file: file}
+	download.size, err = io.Copy(file, io.LimitReader(resp.Body, maxBytes+1))
+	if err == nil && download.size > maxBytes {
+		err = ErrMediaTooLarge
+	}
+	if err == nil {
+		download.contentType, err = detectDownloadContentType(file, resp.Header.Get("Content-Type"))
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		download.Close()
+		return nil, err
+	}
+	return download, nil
+}
+
+// detectDownloadContentType 上游未声明类型时按内容识别；只转存图片、视频、音频
+func detectDownloadContentType(file *os.File, contentType string) (string, error) {
+	if contentType == "" || strings.HasPrefix(contentType, "application/octet-stream") {
+		head := make([]byte, 512)
+		n, err := file.ReadAt(head, 0)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		contentType = http.DetectContentType(head[:n])
+	}
+	if !IsServableMediaType(contentType) {
+		return "", fmt.Errorf("%w: content type %q", ErrMediaURLNotArchivable, contentType)
+	}
+	return contentType, nil
+}
+
+// IsServableMediaType 判断能否按原类型下发：仅允许图片、视频、音频，
+// SVG 可内嵌脚本，不在允许之列
+func IsServableMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "image/svg+xml" {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "image/") ||
+		strings.HasPrefix(mediaType, "video/") ||
+		strings.HasPrefix(mediaType, "audio/")
+}
+
+// mediaStorageKey 生成 {user}/{kind}/{yyyymmdd}/{random}{ext} 形式的存储 key
+func mediaStorageKey(userId int, kind string, rawURL string, contentType string) (string, error) {
+	random, err := common.GenerateRandomCharsKey(24)
+	if err != nil {
+		return "", err
+	}
+	ext := ""
+	if parsed, err := url.Parse(rawURL); err == nil {
+		ext = strings.ToLower(path.Ext(parsed.Path))
+	}
+	if len(ext) > 8 || ext == "" {
+		ext = ""
+		if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+			if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+				ext = exts[0]
+			}
+		}
+	}
+	return fmt.Sprintf("%d/%s/%s/%s%s", userId, kind, time.Now().Format("20060102"), random, ext), nil
+}
+
+// mediaArchiveQuota 按文件大小和每 GB 价格计算额度，并乘以用户分组倍率
+func mediaArchiveQuota(size int64, pricePerGB float64, group string) int {
+	if pricePerGB <= 0 || size <= 0 {
+		return 0
+	}
+	gigabytes := float64(size) / float64(1<<30)
+	return common.QuotaFromFloat(math.Ceil(gigabytes * pricePerGB * common.QuotaPerUnit * ratio_setting.GetGroupRatio(group)))
+}
+
+// chargeMediaAsset 按转存额度从用户钱包预扣，余额不足时返回错误；无需计费时返回 nil
+func chargeMediaAsset(asset *model.MediaAsset) (*WalletFunding, error) {
+	if asset.Quota <= 0 {
+		return nil, nil
+	}
+	funding := walletFundingForUser(asset.UserId, "media:"+strconv.Itoa(asset.Id))
+	if err := funding.PreConsume(asset.Quota); err != nil {
+		return nil, err
+	}
+	return funding, nil
+}
+
+func recordMediaAssetCharge(asset *model.MediaAsset, group string) {
+	model.UpdateUserUsedQuota(asset.UserId, asset.Quota)
+	model.RecordTaskBillingLog(model.RecordTaskBillingLogParams{
+		UserId:    asset.UserId,
+		LogType:   model.LogTypeConsume,
+		Content:   fmt.Sprintf("媒体转存 %.2f MB", float64(asset.Size)/float64(1<<20)),
+		ModelName: mediaArchiveBillingModelName,
+		Quota:     asset.Quota,
+		Group:     group,
+		Other: map[string]interface{}{
+			"media_asset_id": asset.Id,
+			"media_kind":     asset.Kind,
+			"source_id":      asset.SourceId,
+			"size":           asset.Size,
+		},
+	})
+}
+
+// DeleteMediaAssetFile 删除存储中的文件及其记录；未写入存储的记录直接删除
+func DeleteMediaAssetFile(ctx context.Context, asset *model.MediaAsset) error {
+	if asset.StorageKey != "" {
+		storage, err := GetMediaStorage(asset.Backend)
+		if err != nil {
+			return err
+		}
+		if err := storage.Delete(ctx, asset.StorageKey); err != nil {
+			return err
+		}
+	}
+	return model.DeleteMediaAsset(asset.Id)
+}
+
+// OpenMediaAsset 打开文件内容供下载
+func OpenMediaAsset(ctx context.Context, asset *model.MediaAsset) (io.ReadCloser, error) {
+	storage, err := GetMediaStorage(asset.Backend)
+	if err != nil {
+		return nil, err
+	}
+	return storage.Open(ctx, asset.StorageKey)
+}
+
+// OnTaskFinished 任务进入终态后的后续处理。成功且需要转存时在后台转存结果，
+// 完成后再通知客户端，使回调中的结果地址是转存后的地址
+func OnTaskFinished(ctx context.Context, task *model.Task) {
+	if task == nil {
+		return
+	}
+	if task.Status != model.TaskStatusSuccess || !taskHasArchivableMedia(task) {
+		EnqueueTaskWebhook(ctx, task)
+		return
+	}
+	archived := *task
+	gopool.Go(func() {
+		bgCtx := context.WithoutCancel(ctx)
+		if archiveTaskMedia(bgCtx, &archived) {
+			if err := model.UpdateTaskArchivedResult(&archived); err != nil {
+				logger.LogError(bgCtx, fmt.Sprintf("save archived result of task %s failed: %v", archived.TaskID, err))
+			}
+		}
+		EnqueueTaskWebhook(bgCtx, &archived)
+	})
+}
+
+func taskHasArchivableMedia(task *model.Task) bool {
+	if task.Platform == constant.TaskPlatformSuno {
+		return MediaArchiveEnabled(model.MediaKindAudio) && len(task.Data) > 0
+	}
+	return MediaArchiveEnabled(model.MediaKindVideo) && task.PrivateData.MediaAssetId == 0 &&
+		isArchivableMediaURL(task.PrivateData.ResultURL)
+}
+
+// archiveTaskMedia 转存任务结果并改写结果地址，返回任务是否被修改。
+// 视频任务转存 ResultURL；Suno 任务转存每首歌曲的 audio_url
+func archiveTaskMedia(ctx context.Context, task *model.Task) bool {
+	if task.Platform == constant.TaskPlatformSuno {
+		return archiveSunoTaskMedia(ctx, task)
+	}
+	asset, err := ArchiveMediaURL(ctx, task.UserId, model.MediaSourceTask, task.TaskID, model.MediaKindVideo, task.PrivateData.ResultURL)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("archive result of task %s failed, keep upstream url: %v", task.TaskID, err))
+		return false
+	}
+	task.PrivateData.ResultURL = SignedMediaURL(asset)
+	task.PrivateData.MediaAssetId = asset.Id
+	return true
+}
+
+func archiveSunoTaskMedia(ctx context.Context, task *model.Task) bool {
+	data := []byte(task.Data)
+	changed := false
+	for i, song := range gjson.ParseBytes(data).Array() {
+		audioURL := song.Get("audio_url").String()
+		if !isArchivableMediaURL(audioURL) {
+			continue
+		}
+		asset, err := ArchiveMediaURL(ctx, task.UserId, model.MediaSourceTask, task.TaskID, model.MediaKindAudio, audioURL)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("archive audio %d of task %s failed, keep upstream url: %v", i, task.TaskID, err))
+			continue
+		}
+		updated, err := sjson.SetBytes(data, fmt.Sprintf("%d.audio_url", i), SignedMediaURL(asset))
+		if err != nil {
+			continue
+		}
+		data = updated
+		changed = true
+	}
+	if changed {
+		task.Data = data
+	}
+	return changed
+}
+
+// ArchiveImageResponse 把图片接口响应中 data[].url 改写为网关签名地址，并在后台转存图片，
+// 不阻塞响应。转存完成前或转存失败时，签名地址跳转回上游地址
+func ArchiveImageResponse(ctx context.Context, userId int, requestId string, body []byte) []byte {
+	if !MediaArchiveEnabled(model.MediaKindImage) {
+		return body
+	}
+	var pending []*model.MediaAsset
+	for i, item := range gjson.GetBytes(body, "data").Array() {
+		imageURL := item.Get("url").String()
+		if !isArchivableMediaURL(imageURL) {
+			continue
+		}
+		asset, err := newPendingMediaAsset(userId, model.MediaSourceImage, requestId, model.MediaKindImage, imageURL)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("archive image %d of request %s failed, keep upstream url: %v", i, requestId, err))
+			continue
+		}
+		updated, err := sjson.SetBytes(body, fmt.Sprintf("data.%d.url", i), SignedMediaURL(asset))
+		if err != nil {
+			_ = model.DeleteMediaAsset(asset.Id)
+			continue
+		}
+		body = updated
+		pending = append(pending, asset)
+	}
+	if len(pending) == 0 {
+		return body
+	}
+	bgCtx := context.WithoutCancel(ctx)
+	gopool.Go(func() {
+		for _, asset := range pending {
+			if err := archiveMediaAsset(bgCtx, asset); err != nil {
+				logger.LogWarn(bgCtx, fmt.Sprintf("archive image #%d of request %s failed, keep upstream url: %v", asset.Id, requestId, err))
+				if failErr := model.FailMediaAsset(asset.Id); failErr != nil {
+					logger.LogError(bgCtx, fmt.Sprintf("mark media asset #%d failed: %v", asset.Id, failErr))
+				}
+			}
+		}
+	})
+	return body
+}
+
+// StartMediaRetentionTask 定期删除保留期已结束的转存文件，仅在主节点运行
+func StartMediaRetentionTask() {
+	mediaRetentionOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			ticker := time.NewTicker(mediaRetentionInterval)
+			defer ticker.Stop()
+
+			runMediaRetentionOnce()
+			for range ticker.C {
+				runMediaRetentionOnce()
+			}
+		})
+	})
+}
+
+func runMediaRetentionOnce() {
+	ctx := context.Background()
+	deleted := 0
+	for {
+		assets, err := model.GetExpiredMediaAssets(common.GetTimestamp(), mediaRetentionBatchSize)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("media retention task failed: %v", err))
+			return
+		}
+		failed := 0
+		for _, asset := range assets {
+			if err := DeleteMediaAssetFile(ctx, asset); err != nil {
+				failed++
+				logger.LogWarn(ctx, fmt.Sprintf("delete expired media asset #%d failed: %v", asset.Id, err))
+				continue
+			}
+			deleted++
+		}
+		// 整批失败时停止，避免存储不可用时空转
+		if len(assets) < mediaRetentionBatchSize || failed == len(assets) {
+			break
+		}
+	}
+	if deleted > 0 {
+		logger.LogInfo(ctx, fmt.Sprintf("media retention task deleted %d expired assets", deleted))
+	}
+}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+// enableMediaArchive 启用媒体转存并允许从本地测试服务器下载
+func enableMediaArchive(t *testing.T, configure func(setting *operation_setting.MediaArchiveSetting)) {
+	t.Helper()
+	allowLocalTaskWebhooks(t)
+	setting := operation_setting.GetMediaArchiveSetting()
+	original := *setting
+	t.Cleanup(func() { *setting = original })
+	setting.Enabled = true
+	setting.Backend = operation_setting.MediaArchiveBackendLocal
+	setting.LocalPath = t.TempDir()
+	setting.GroupRetentionDays = map[string]int{}
+	setting.GroupQuotaMB = map[string]int{}
+	if configure != nil {
+		configure(setting)
+	}
+}
+
+func newMediaSourceServer(t *testing.T, content string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte(content))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func readMediaAsset(t *testing.T, asset *model.MediaAsset) string {
+	t.Helper()
+	reader, err := OpenMediaAsset(context.Background(), asset)
+	require.NoError(t, err)
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(data)
+}
+
+// waitMediaAssetStatus 等待后台转存结束，返回最终的文件记录
+func waitMediaAssetStatus(t *testing.T, id int, status string) *model.MediaAsset {
+	t.Helper()
+	var asset *model.MediaAsset
+	require.Eventually(t, func() bool {
+		var err error
+		asset, err = model.GetMediaAssetById(id, 0)
+		return err == nil && asset.Status == status
+	}, 5*time.Second, 10*time.Millisecond)
+	return asset
+}
+
+func TestArchiveImageResponseRewritesURLsToSignedURLs(t *testing.T) {
+	truncate(t)
+	enableMediaArchive(t, func(setting *operation_setting.MediaArchiveSetting) {
+		setting.RetentionDays = 7
+	})
+	source := newMediaSourceServer(t, "png-bytes")
+
+	body := []byte(`{"created":1,"data":[{"url":"` + source.URL + `/a.png"},{"b64_json":"aGk="}]}`)
+	archived := ArchiveImageResponse(context.Background(), 4401, "req_media", body)
+
+	signedURL := gjson.GetBytes(archived, "data.0.url").String()
+	assert.Contains(t, signedURL, "/api/media/file/")
+	assert.Equal(t, "aGk=", gjson.GetBytes(archived, "data.1.b64_json").String())
+
+	assets, total, err := model.GetMediaAssets(4401, model.MediaKindImage, "req_media", 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	asset := waitMediaAssetStatus(t, assets[0].Id, model.MediaAssetStatusReady)
+	assert.Equal(t, int64(len("png-bytes")), asset.Size)
+	assert.Equal(t, "image/png", asset.ContentType)
+	assert.True(t, strings.HasSuffix(asset.StorageKey, ".png"))
+	assert.Equal(t, asset.CreatedAt+7*86400, asset.ExpiresAt)
+	assert.Equal(t, "png-bytes", readMediaAsset(t, asset))
+
+	assert.NoError(t, VerifyMediaSignature(asset.Id, asset.ExpiresAt, asset.ExpiresAt, model.MediaSignature(asset.Id, asset.ExpiresAt, asset.ExpiresAt)))
+	assert.ErrorIs(t, VerifyMediaSignature(asset.Id, asset.ExpiresAt+1, asset.ExpiresAt, model.MediaSignature(asset.Id, asset.ExpiresAt, asset.ExpiresAt)), ErrMediaSignatureInvalid)
+	assert.ErrorIs(t, VerifyMediaSignature(asset.Id, 1, 0, model.MediaSignature(asset.Id, 1, 0)), ErrMediaSignatureInvalid)
+	assert.ErrorIs(t, VerifyMediaSignature(asset.Id, 0, 0, model.MediaSignature(asset.Id, 0, 0)), ErrMediaSignatureInvalid)
+}
+
+func TestSignedMediaURLExpiresBeforeRetention(t *testing.T) {
+	enableMediaArchive(t, func(setting *operation_setting.MediaArchiveSetting) {
+		setting.SignedURLHours = 2
+	})
+	now := common.GetTimestamp()
+
+	// 永久保留的文件也只签发有限期的地址
+	query := mediaURLQuery(t, SignedMediaURL(&model.MediaAsset{Id: 7}))
+	expires, _ := strconv.ParseInt(query.Get("expires"), 10, 64)
+	assert.InDelta(t, now+2*3600, expires, 5)
+	assert.Equal(t, "0", query.Get("retain"))
+	assert.NoError(t, VerifyMediaSignature(7, expires, 0, query.Get("signature")))
+
+	// 有效期不超过保留期
+	query = mediaURLQuery(t, SignedMediaURL(&model.MediaAsset{Id: 7, ExpiresAt: now + 60}))
+	assert.Equal(t, strconv.FormatInt(now+60, 10), query.Get("expires"))
+}
+
+func TestRefreshMediaURLsResignsStoredURLs(t *testing.T) {
+	enableMediaArchive(t, nil)
+	now := common.GetTimestamp()
+
+	// 已过期的签名地址在读取时重新签发
+	stale := fmt.Sprintf("/api/media/file/9?expires=%d&retain=0&signature=%s", now-10, model.MediaSignature(9, now-10, 0))
+	query := mediaURLQuery(t, model.RefreshMediaURLs(stale))
+	expires, _ := strconv.ParseInt(query.Get("expires"), 10, 64)
+	assert.Greater(t, expires, now)
+	assert.NoError(t, VerifyMediaSignature(9, expires, 0, query.Get("signature")))
+
+	// JSON 中转义的旧版地址按其 expires 作为保留期升级
+	legacy := fmt.Sprintf(`[{"audio_url":"/api/media/file/9?expires=%d\u0026signature=%s"}]`, now+60, common.GenerateHMAC(fmt.Sprintf("media:9:%d", now+60)))
+	refreshed := gjson.Get(model.RefreshMediaURLs(legacy), "0.audio_url").String()
+	query = mediaURLQuery(t, refreshed)
+	assert.Equal(t, strconv.FormatInt(now+60, 10), query.Get("retain"))
+	assert.Equal(t, strconv.FormatInt(now+60, 10), query.Get("expires"))
+
+	// 签名无效的地址保持不变
+	forged := fmt.Sprintf("/api/media/file/9?expires=%d&retain=0&signature=%s", now, strings.Repeat("0", 64))
+	assert.Equal(t, forged, model.RefreshMediaURLs(forged))
+}
+
+func mediaURLQuery(t *testing.T, rawURL string) url.Values {
+	t.Helper()
+	parsed, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	return parsed.Query()
+}
+
+func TestArchiveMediaURLEnforcesUserStorageQuota(t *testing.T) {
+	truncate(t)
+	enableMediaArchive(t, func(setting *operation_setting.MediaArchiveSetting) {
+		setting.UserQuotaMB = 1
+	})
+	source := newMediaSourceServer(t, strings.Repeat("x", 600<<10))
+
+	_, err := ArchiveMediaURL(context.Background(), 4402, model.MediaSourceImage, "req_1", model.MediaKindImage, source.URL+"/1.png")
+	require.NoError(t, err)
+	_, err = ArchiveMediaURL(context.Background(), 4402, model.MediaSourceImage, "req_2", model.MediaKindImage, source.URL+"/2.png")
+	assert.ErrorIs(t, err, ErrMediaQuotaExceeded)
+
+	// 超出上限的图片转存失败，签名地址跳转回上游地址
+	body := []byte(`{"data":[{"url":"` + source.URL + `/3.png"}]}`)
+	assert.Contains(t, gjson.GetBytes(ArchiveImageResponse(context.Background(), 4402, "req_3", body), "data.0.url").String(), "/api/media/file/")
+	assets, _, err := model.GetMediaAssets(4402, model.MediaKindImage, "req_3", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, assets, 1)
+	failed := waitMediaAssetStatus(t, assets[0].Id, model.MediaAssetStatusFailed)
+	assert.Zero(t, failed.Size)
+}
+
+func TestReserveMediaStorageCountsInFlightUploads(t *testing.T) {
+	truncate(t)
+	release, err := reserveMediaStorage(4407, 600, 1000)
+	require.NoError(t, err)
+	_, err = reserveMediaStorage(4407, 600, 1000)
+	assert.ErrorIs(t, err, ErrMediaQuotaExceeded)
+	release()
+	release, err = reserveMediaStorage(4407, 600, 1000)
+	require.NoError(t, err)
+	release()
+}
+
+func TestArchiveMediaURLRejectsUnpaidAndNonMediaContent(t *testing.T) {
+	truncate(t)
+	enableMediaArchive(t, func(setting *operation_setting.MediaArchiveSetting) {
+		setting.PricePerGB = 1000
+	})
+	source := newMediaSourceServer(t, strings.Repeat("x", 1<<20))
+	user := &model.User{Id: 4408, Username: "media_broke", AffCode: "media-broke", Group: "default", Quota: 0, Status: common.UserStatusEnabled}
+	require.NoError(t, model.DB.Create(user).Error)
+
+	_, err := ArchiveMediaURL(context.Background(), 4408, model.MediaSourceImage, "req_unpaid", model.MediaKindImage, source.URL+"/unpaid.png")
+	assert.ErrorIs(t, err, ErrInsufficientWalletQuota)
+
+	html := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<script>alert(1)</script>"))
+	}))
+	t.Cleanup(html.Close)
+	_, err = ArchiveMediaURL(context.Background(), 4408, model.MediaSourceImage, "req_html", model.MediaKindImage, html.URL+"/x.png")
+	assert.ErrorIs(t, err, ErrMediaURLNotArchivable)
+
+	_, total, err := model.GetMediaAssets(4408, "", "", 0, 10)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+}
+
+func TestArchiveMediaURLChargesPerGigabyte(t *testing.T) {
+	truncate(t)
+	enableMediaArchive(t, func(setting *operation_setting.MediaArchiveSetting) {
+		setting.PricePerGB = 1000
+	})
+	source := newMediaSourceServer(t, strings.Repeat("x", 1<<20))
+	user := &model.User{Id: 4403, Username: "media_user", AffCode: "media-aff", Group: "default", Quota: 10_000_000, Status: common.UserStatusEnabled}
+	require.NoError(t, model.DB.Create(user).Error)
+
+	asset, err := ArchiveMediaURL(context.Background(), 4403, model.MediaSourceImage, "req_bill", model.MediaKindImage, source.URL+"/bill.png")
+	require.NoError(t, err)
+	assert.Greater(t, asset.Quota, 0)
+
+	remaining, err := model.GetUserQuota(4403, true)
+	require.NoError(t, err)
+	assert.Equal(t, 10_000_000-asset.Quota, remaining)
+}
+
+func TestRunMediaRetentionOnceDeletesExpiredAssets(t *testing.T) {
+	truncate(t)
+	enableMediaArchive(t, nil)
+	source := newMediaSourceServer(t, "old")
+
+	asset, err := ArchiveMediaURL(context.Background(), 4404, model.MediaSourceImage, "req_old", model.MediaKindImage, source.URL+"/old.png")
+	require.NoError(t, err)
+	require.NoError(t, model.DB.Model(asset).Update("expires_at", common.GetTimestamp()-1).Error)
+
+	runMediaRetentionOnce()
+
+	_, err = model.GetMediaAssetById(asset.Id, 0)
+	assert.ErrorIs(t, err, model.ErrMediaAssetNotFound)
+	_, err = OpenMediaAsset(context.Background(), asset)
+	assert.ErrorIs(t, err, ErrMediaObjectNotFound)
+}
+
+func TestS3MediaStorageAgainstCompatibleStub(t *testing.T) {
+	truncate(t)
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			objects[r.URL.Path], _ = io.ReadAll(r.Body)
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(data)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer stub.Close()
+	enableMediaArchive(t, func(setting *operation_setting.MediaArchiveSetting) {
+		setting.Backend = operation_setting.MediaArchiveBackendS3
+		setting.S3Endpoint = stub.URL
+		setting.S3Bucket = "media"
+		setting.S3AccessKey = "minio"
+		setting.S3SecretKey = "minio-secret"
+		setting.S3UsePathStyle = true
+	})
+	source := newMediaSourceServer(t, "s3-bytes")
+
+	asset, err := ArchiveMediaURL(context.Background(), 4405, model.MediaSourceImage, "req_s3", model.MediaKindImage, source.URL+"/s3.png")
+	require.NoError(t, err)
+	assert.Equal(t, operation_setting.MediaArchiveBackendS3, asset.Backend)
+	assert.Contains(t, objects, "/media/"+asset.StorageKey)
+	assert.Equal(t, "s3-bytes", readMediaAsset(t, asset))
+
+	require.NoError(t, DeleteMediaAssetFile(context.Background(), asset))
+	assert.Empty(t, objects)
+}
+
+func TestArchiveTaskMediaRewritesVideoResultURL(t *testing.T) {
+	truncate(t)
+	enableMediaArchive(t, nil)
+	source := newMediaSourceServer(t, "video-bytes")
+
+	task := &model.Task{TaskID: "task_media_video", UserId: 4406, Status: model.TaskStatusSuccess, Progress: "100%"}
+	task.PrivateData.ResultURL = source.URL + "/video.mp4"
+	require.NoError(t, model.DB.Create(task).Error)
+
+	archived := *task
+	require.True(t, archiveTaskMedia(context.Background(), &archived))
+	require.NoError(t, model.UpdateTaskArchivedResult(&archived))
+
+	saved, exists, err := model.GetByTaskId(4406, "task_media_video")
+	require.NoError(t, err)
+	require.True(t, exists)
+	assert.Greater(t, saved.PrivateData.MediaAssetId, 0)
+	assert.Contains(t, saved.PrivateData.ResultURL, "/api/media/file/")
+	expires, _ := strconv.ParseInt(mediaURLQuery(t, saved.GetResultURL()).Get("expires"), 10, 64)
+	assert.LessOrEqual(t, expires, common.GetTimestamp()+24*3600)
+	assert.False(t, taskHasArchivableMedia(saved))
+}
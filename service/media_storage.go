@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+var ErrMediaObjectNotFound = errors.New("media object not found")
+
+// MediaStorage 转存文件的存储后端
+type MediaStorage interface {
+	Name() string
+	// Put 流式写入 size 字节的内容
+	Put(ctx context.Context, key string, contentType string, body io.Reader, size int64) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// GetMediaStorage 按当前配置返回存储后端；每次调用重新读取配置，修改后立即生效
+func GetMediaStorage(backend string) (MediaStorage, error) {
+	setting := operation_setting.GetMediaArchiveSetting()
+	switch backend {
+	case operation_setting.MediaArchiveBackendLocal, "":
+		if strings.TrimSpace(setting.LocalPath) == "" {
+			return nil, errors.New("media archive local path is empty")
+		}
+		return &localMediaStorage{root: setting.LocalPath}, nil
+	case operation_setting.MediaArchiveBackendS3:
+		if setting.S3Endpoint == "" || setting.S3Bucket == "" {
+			return nil, errors.New("media archive s3 endpoint or bucket is empty")
+		}
+		return &s3MediaStorage{
+			endpoint:  strings.TrimRight(setting.S3Endpoint, "/"),
+			region:    setting.S3Region,
+			bucket:    setting.S3Bucket,
+			accessKey: setting.S3AccessKey,
+			secretKey: setting.S3SecretKey,
+			pathStyle: setting.S3UsePathStyle,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown media archive backend: %s", backend)
+	}
+}
+
+type localMediaStorage struct {
+	root string
+}
+
+func (s *localMediaStorage) Name() string { return operation_setting.MediaArchiveBackendLocal }
+
+// path 将存储 key 映射到根目录下的文件，拒绝越出根目录的 key
+func (s *localMediaStorage) path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if cleaned == "/" {
+		return "", fmt.Errorf("invalid media key: %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(cleaned)), nil
+}
+
+func (s *localMediaStorage) Put(_ context.Context, key string, _ string, body io.Reader, _ int64) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// 先写临时文件再改名，避免读到写了一半的文件
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *localMediaStorage) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrMediaObjectNotFound
+	}
+	return file, err
+}
+
+func (s *localMediaStorage) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// s3MediaStorage 使用 SigV4 签名直接调用 S3 对象接口，兼容 MinIO 等 S3 兼容存储
+type s3MediaStorage struct {
+	endpoint  string
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+}
+
+func (s *s3MediaStorage) Name() string { return operation_setting.MediaArchiveBackendS3 }
+
+func (s *s3MediaStorage) objectURL(key string) (string, error) {
+	base, err := url.Parse(s.endpoint)
+	if err != nil || base.Host == "" {
+		return "", fmt.Errorf("invalid s3 endpoint: %s", s.endpoint)
+	}
+	escapedKey := strings.TrimLeft(key, "/")
+	if s.pathStyle {
+		base.Path = "/" + s.bucket + "/" + escapedKey
+	} else {
+		base.Host = s.bucket + "." + base.Host
+		base.Path = "/" + escapedKey
+	}
+	return base.String(), nil
+}
+
+// emptyPayloadHash 无请求体时的 SHA-256；上传使用 UNSIGNED-PAYLOAD，避免为计算签名缓存整个文件
+var emptyPayloadHash = func() string {
+	sum := sha256.Sum256(nil)
+	return hex.EncodeToString(sum[:])
+}()
+
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+func (s *s3MediaStorage) do(ctx context.Context, method string, key string, contentType string, body io.Reader, size int64) (*http.Response, error) {
+	objectURL, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		payloadHash = unsignedPayload
+		// 由调用方关闭文件，请求结束时不关闭 body
+		body = io.NopCloser(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, objectURL, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	region := s.region
+	if region == "" {
+		region = "us-east-1"
+	}
+	credentials := aws.Credentials{AccessKeyID: s.accessKey, SecretAccessKey: s.secretKey}
+	if err := v4.NewSigner().SignHTTP(ctx, credentials, req, payloadHash, "s3", region, time.Now()); err != nil {
+		return nil, err
+	}
+	// 存储地址由管理员配置，不经过 SSRF 防护（常见部署为内网 MinIO）
+	return GetHttpClient().Do(req)
+}
+
+func (s *s3MediaStorage) Put(ctx context.Context, key string, contentType string, body io.Reader, size int64) error {
+	resp, err := s.do(ctx, http.MethodPut, key, contentType, body, size)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("s3 put object returned status %d: %s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+func (s *s3MediaStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, "", nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrMediaObjectNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3 get object returned status %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+func (s *s3MediaStorage) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, "", nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("s3 delete object returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -51,6 +51,9 @@ func PrepareMidjourneyTaskBilling(relayInfo *relaycommon.RelayInfo, task *model.
 	if relayInfo.BillingSource == BillingSourceSubscription {
 		return false, errors.New("legacy Midjourney billing does not support subscriptions")
 	}
+	if relayInfo.OrganizationId > 0 {
+		return false, errors.New("legacy Midjourney billing does not support organization tokens")
+	}
 
 	task.Quota = quota
 	task.BillingChannelId = task.ChannelId
@@ -100,7 +103,7 @@ func RefundMidjourneyQuota(ctx context.Context, task *model.Midjourney, reason s
 		return true
 	}
 
-	if err := model.IncreaseUserQuota(task.UserId, quota, false); err != nil {
+	if err := walletFundingForUser(task.UserId, "mj:"+task.MjId).charge(-quota); err != nil {
 		logger.LogWarn(ctx, fmt.Sprintf("退还 Midjourney 用户额度失败 task %s: %s", task.MjId, err.Error()))
 		return false
 	}
@@ -110,6 +113,9 @@ func RefundMidjourneyQuota(ctx context.Context, task *model.Midjourney, reason s
 		if tokenKey != "" {
 			if err := model.IncreaseTokenQuota(task.TokenId, tokenKey, quota); err != nil {
 				logger.LogWarn(ctx, fmt.Sprintf("退还 Midjourney 令牌额度失败 task %s: %s", task.MjId, err.Error()))
+			} else {
+				// SubmitTime 为毫秒时间戳
+				adjustTokenBudgetUsed(task.TokenId, -quota, task.SubmitTime/1000)
 			}
 		}
 	}
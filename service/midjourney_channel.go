@@ -0,0 +1,102 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FindMidjourneyChannelByIdentity 查找指向同一上游 MJ 实例的启用渠道，用于渠道被删除重建或更换密钥后找回任务
+func FindMidjourneyChannelByIdentity(identity string, excludedChannelId int) *model.Channel {
+	if identity == "" {
+		return nil
+	}
+	channels, err := model.GetEnabledMidjourneyChannels()
+	if err != nil {
+		common.SysLog("get midjourney channels failed: " + err.Error())
+		return nil
+	}
+	for _, channel := range channels {
+		if channel.Id != excludedChannelId && model.MidjourneyChannelIdentity(channel.GetBaseURL()) == identity {
+			return channel
+		}
+	}
+	return nil
+}
+
+// FindMidjourneyTaskChannel 查找仍能访问任务所在上游实例的渠道：原渠道存在且地址未变时使用原渠道，
+// 否则按任务记录的实例标识查找其它启用渠道。enabledOnly 为 true 时跳过已禁用的原渠道
+func FindMidjourneyTaskChannel(task *model.Midjourney, enabledOnly bool) *model.Channel {
+	identity := task.ChannelIdentity
+	origin, err := model.GetChannelById(task.ChannelId, true)
+	if err == nil {
+		originIdentity := model.MidjourneyChannelIdentity(origin.GetBaseURL())
+		if identity == "" || identity == originIdentity {
+			if !enabledOnly || origin.Status == common.ChannelStatusEnabled {
+				return origin
+			}
+			identity = originIdentity
+		}
+	}
+	return FindMidjourneyChannelByIdentity(identity, task.ChannelId)
+}
+
+// RebindMidjourneyTask 将任务迁移到新渠道，后续查询、轮询与操作都走新渠道。
+// 只应在上游已接受基于该任务的操作后调用，channel 为 nil 时不做任何事
+func RebindMidjourneyTask(c *gin.Context, task *model.Midjourney, channel *model.Channel) {
+	if channel == nil {
+		return
+	}
+	identity := model.MidjourneyChannelIdentity(channel.GetBaseURL())
+	if task.ChannelId == channel.Id && task.ChannelIdentity == identity {
+		return
+	}
+	if err := model.RebindMidjourneyTasks([]int{task.Id}, channel.Id, identity); err != nil {
+		logger.LogError(c, fmt.Sprintf("rebind midjourney task %s to channel #%d failed: %s", task.MjId, channel.Id, err.Error()))
+		return
+	}
+	logger.LogInfo(c, fmt.Sprintf("midjourney task %s moved from channel #%d to channel #%d", task.MjId, task.ChannelId, channel.Id))
+	task.ChannelId = channel.Id
+	task.ChannelIdentity = identity
+}
+
+// UseMidjourneyTaskChannel 为基于已有任务的操作（action、modal、image-seed 等）选择上游渠道并写入上下文。
+// 任务 ID 只在提交它的上游实例上有效，因此优先使用原渠道或指向同一实例的渠道；
+// 都不可用且开启 MjActionAnyChannelEnabled 时，沿用分发器在同一分组中选出的 MJ 渠道。
+// 返回指向同一上游实例的渠道，调用方在上游接受操作后用它调用 RebindMidjourneyTask；
+// 使用分组渠道兜底时返回 nil，任务不迁移
+func UseMidjourneyTaskChannel(c *gin.Context, task *model.Midjourney) (*model.Channel, *dto.MidjourneyResponse) {
+	channel := FindMidjourneyTaskChannel(task, true)
+	rebindTarget := channel
+	if channel == nil {
+		if !setting.MjActionAnyChannelEnabled {
+			if _, err := model.GetChannelById(task.ChannelId, true); err == nil {
+				return nil, MidjourneyErrorWrapper(constant.MjRequestError, "该任务所属渠道已被禁用")
+			}
+			return nil, MidjourneyErrorWrapper(constant.MjRequestError, "get_channel_info_failed")
+		}
+		poolChannel, err := model.GetChannelById(c.GetInt("channel_id"), true)
+		if err != nil {
+			return nil, MidjourneyErrorWrapper(constant.MjRequestError, "get_channel_info_failed")
+		}
+		logger.LogInfo(c, fmt.Sprintf("midjourney task %s origin channel #%d unavailable, using pool channel #%d", task.MjId, task.ChannelId, poolChannel.Id))
+		channel = poolChannel
+	}
+	key, _, apiErr := channel.GetNextEnabledKey()
+	if apiErr != nil {
+		return nil, MidjourneyErrorWrapper(constant.MjRequestError, "get_channel_info_failed")
+	}
+	c.Set("base_url", channel.GetBaseURL())
+	c.Set("channel_id", channel.Id)
+	common.SetContextKey(c, constant.ContextKeyChannelKey, key)
+	c.Request.Header.Set("Authorization", "Bearer "+key)
+	logger.LogDebug(c, "Midjourney action uses channel: id=%d, base_url=%s", channel.Id, channel.GetBaseURL())
+	return rebindTarget, nil
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// PostpaidDunningSummary 一次账期结算与催缴任务的结果
+type PostpaidDunningSummary struct {
+	Processed int `json:"processed"`
+	Closed    int `json:"closed"`
+	Settled   int `json:"settled"`
+	Escalated int `json:"escalated"`
+	Failed    int `json:"failed"`
+}
+
+func postpaidDunningPolicy() model.PostpaidDunningPolicy {
+	setting := operation_setting.GetPostpaidSetting()
+	return model.PostpaidDunningPolicy{
+		WarnDays:     setting.WarnDays,
+		ThrottleDays: setting.ThrottleDays,
+		SuspendDays:  setting.SuspendDays,
+	}
+}
+
+// RunPostpaidDunningOnce 处理全部后付费账户：核销还款、结算到期账期并按逾期天数升级催缴
+func RunPostpaidDunningOnce(ctx context.Context, progress func(processed, total int)) (*PostpaidDunningSummary, error) {
+	userIds, err := model.GetPostpaidAccountUserIds()
+	if err != nil {
+		return nil, err
+	}
+	summary := &PostpaidDunningSummary{}
+	policy := postpaidDunningPolicy()
+	for i, userId := range userIds {
+		if ctx.Err() != nil {
+			return summary, ctx.Err()
+		}
+		transition, err := model.ProcessPostpaidAccount(userId, common.GetTimestamp(), policy)
+		if err != nil {
+			summary.Failed++
+			common.SysError(fmt.Sprintf("failed to process postpaid account of user %d: %v", userId, err))
+		} else {
+			summary.Processed++
+			if transition.CycleClosed {
+				summary.Closed++
+			}
+			if transition.Settled {
+				summary.Settled++
+			}
+			if transition.Stage > transition.PreviousStage {
+				summary.Escalated++
+			}
+			notifyPostpaidTransition(transition)
+		}
+		if progress != nil {
+			progress(i+1, len(userIds))
+		}
+	}
+	if progress != nil && len(userIds) == 0 {
+		progress(0, 0)
+	}
+	return summary, nil
+}
+
+// RefreshPostpaidAccount 立即核销指定用户的后付费账户（例如用户充值后查看账户时）
+func RefreshPostpaidAccount(userId int) (*model.PostpaidAccount, error) {
+	transition, err := model.ProcessPostpaidAccount(userId, common.GetTimestamp(), postpaidDunningPolicy())
+	if err != nil {
+		return nil, err
+	}
+	notifyPostpaidTransition(transition)
+	return model.GetPostpaidAccount(userId)
+}
+
+// SettlePostpaidAccount 管理员登记线下收款：按 amount（为 0 时取当前应付金额）增加用户余额并立即核销
+func SettlePostpaidAccount(userId int, amount int) (*model.PostpaidAccount, int, error) {
+	account, err := model.GetPostpaidAccount(userId)
+	if err != nil {
+		return nil, 0, err
+	}
+	if amount <= 0 {
+		amount = int(account.OutstandingQuota)
+	}
+	if amount <= 0 {
+		return nil, 0, errors.New("no outstanding balance to settle")
+	}
+	if err := model.GrantUserQuota(userId, amount, model.CreditSourcePostpaid, "postpaid:manual"); err != nil {
+		return nil, 0, err
+	}
+	account, err = RefreshPostpaidAccount(userId)
+	return account, amount, err
+}
+
+func notifyPostpaidTransition(transition *model.PostpaidTransition) {
+	var subject, content string
+	dueDate := time.Unix(transition.DueAt, 0).Format("2006-01-02")
+	outstanding := logger.FormatQuota(int(transition.Outstanding))
+	switch {
+	case transition.Settled && transition.PreviousStage > model.PostpaidStageCurrent:
+		subject = "账单已结清"
+		content = "您的后付费账单已结清，账户已恢复正常使用。"
+	case transition.Stage > transition.PreviousStage:
+		switch transition.Stage {
+		case model.PostpaidStageWarned:
+			subject = "账单逾期提醒"
+			content = fmt.Sprintf("您的后付费账单已于 %s 到期，应付金额 %s，请尽快充值结清。", dueDate, outstanding)
+		case model.PostpaidStageThrottled:
+			subject = "账单逾期：请求已限速"
+			content = fmt.Sprintf("您的后付费账单（应付 %s，%s 到期）仍未结清，API 请求已被限速，继续逾期将停用信用额度。", outstanding, dueDate)
+		case model.PostpaidStageSuspended:
+			subject = "账单逾期：信用额度已停用"
+			content = fmt.Sprintf("您的后付费账单（应付 %s，%s 到期）仍未结清，信用额度已停用，结清后自动恢复。", outstanding, dueDate)
+		}
+	case transition.CycleClosed && transition.Outstanding > 0 && transition.Stage == model.PostpaidStageCurrent:
+		subject = "后付费账单已出账"
+		content = fmt.Sprintf("本期后付费账单已出账，应付金额 %s，请于 %s 前充值结清。", outstanding, dueDate)
+	}
+	if subject == "" {
+		return
+	}
+	user, err := model.GetUserById(transition.UserId, false)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to load user %d for postpaid notification: %v", transition.UserId, err))
+		return
+	}
+	if err := NotifyUser(user.Id, user.Email, user.GetSetting(), dto.NewNotify(dto.NotifyTypeBilling, subject, content, nil)); err != nil {
+		common.SysLog(fmt.Sprintf("failed to send postpaid notification to user %d: %s", user.Id, err.Error()))
+	}
+}
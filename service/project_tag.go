@@ -0,0 +1,131 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProjectHeader 成本归属项目请求头。令牌配置了允许列表时，也可通过请求体
+// metadata.project 或 "project:<name>" 形式的 user 字段指定，请求头优先。
+const ProjectHeader = "X-NewAPI-Project"
+
+const projectUserPrefix = "project:"
+
+var ErrProjectNotAllowed = errors.New("project is not allowed for this token")
+
+// ApplyProjectTag 校验请求头中的项目标签并写入上下文，消费日志据此归属成本。
+// 令牌未配置允许列表时拒绝携带项目标签的请求，避免随意填写导致报表失真。
+func ApplyProjectTag(c *gin.Context, project string) error {
+	project = strings.TrimSpace(project)
+	if project == "" {
+		return nil
+	}
+	if err := model.ValidateProjectTag(project); err != nil {
+		return err
+	}
+	allowed := common.GetContextKeyString(c, constant.ContextKeyTokenAllowedProjects)
+	if !model.IsProjectAllowed(allowed, project) {
+		return fmt.Errorf("%w: %s", ErrProjectNotAllowed, project)
+	}
+	common.SetContextKey(c, constant.ContextKeyProject, project)
+	return nil
+}
+
+// ApplyRequestProjectTag 应用从请求体中读取的项目标签。metadata 与 user 是客户端
+// 常用的自由字段，仅在令牌配置了允许列表且标签在列表内时才归属成本，其余情况忽略，
+// 不拒绝请求
+func ApplyRequestProjectTag(c *gin.Context, project string) {
+	project = strings.TrimSpace(project)
+	allowed := common.GetContextKeyString(c, constant.ContextKeyTokenAllowedProjects)
+	if project == "" || allowed == "" {
+		return
+	}
+	if model.ValidateProjectTag(project) != nil || !model.IsProjectAllowed(allowed, project) {
+		return
+	}
+	common.SetContextKey(c, constant.ContextKeyProject, project)
+}
+
+// ProjectFromRequest 从已解析的请求体中提取项目标签。Anthropic 的 metadata 只接受
+// user_id，Claude 请求中的 metadata.project 会在提取后从请求中移除，不转发给上游
+func ProjectFromRequest(request dto.Request) string {
+	switch r := request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		if project := projectFromMetadata(r.Metadata); project != "" {
+			return project
+		}
+		return projectFromUser(r.User)
+	case *dto.OpenAIResponsesRequest:
+		if project := projectFromMetadata(r.Metadata); project != "" {
+			return project
+		}
+		return projectFromUser(r.User)
+	case *dto.ClaudeRequest:
+		project := projectFromMetadata(r.Metadata)
+		if project != "" {
+			r.Metadata = removeMetadataProject(r.Metadata)
+		}
+		return project
+	case *dto.ImageRequest:
+		return projectFromUser(r.User)
+	case *dto.EmbeddingRequest:
+		return projectFromUserString(r.User)
+	}
+	return ""
+}
+
+func projectFromMetadata(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var metadata map[string]any
+	if err := common.Unmarshal(raw, &metadata); err != nil {
+		return ""
+	}
+	project, _ := metadata["project"].(string)
+	return project
+}
+
+// removeMetadataProject 删除 metadata 中的 project 键，删除后为空时去掉整个 metadata
+func removeMetadataProject(raw json.RawMessage) json.RawMessage {
+	var metadata map[string]json.RawMessage
+	if err := common.Unmarshal(raw, &metadata); err != nil {
+		return raw
+	}
+	delete(metadata, "project")
+	if len(metadata) == 0 {
+		return nil
+	}
+	stripped, err := common.Marshal(metadata)
+	if err != nil {
+		return raw
+	}
+	return stripped
+}
+
+func projectFromUser(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var user string
+	if err := common.Unmarshal(raw, &user); err != nil {
+		return ""
+	}
+	return projectFromUserString(user)
+}
+
+func projectFromUserString(user string) string {
+	if !strings.HasPrefix(user, projectUserPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(user, projectUserPrefix)
+}
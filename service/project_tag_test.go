@@ -0,0 +1,65 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectFromRequest(t *testing.T) {
+	assert.Equal(t, "search", ProjectFromRequest(&dto.GeneralOpenAIRequest{
+		Metadata: json.RawMessage(`{"project":"search"}`),
+		User:     json.RawMessage(`"project:ads"`),
+	}))
+	assert.Equal(t, "ads", ProjectFromRequest(&dto.GeneralOpenAIRequest{User: json.RawMessage(`"project:ads"`)}))
+	assert.Empty(t, ProjectFromRequest(&dto.GeneralOpenAIRequest{User: json.RawMessage(`"end-user-42"`)}))
+
+	// Anthropic 拒绝未知的 metadata 键，提取后不再随请求转发
+	claude := &dto.ClaudeRequest{Metadata: json.RawMessage(`{"user_id":"u","project":"search"}`)}
+	assert.Equal(t, "search", ProjectFromRequest(claude))
+	assert.JSONEq(t, `{"user_id":"u"}`, string(claude.Metadata))
+	claude = &dto.ClaudeRequest{Metadata: json.RawMessage(`{"project":"search"}`)}
+	assert.Equal(t, "search", ProjectFromRequest(claude))
+	assert.Empty(t, claude.Metadata)
+	assert.Equal(t, "ads", ProjectFromRequest(&dto.EmbeddingRequest{User: "project:ads"}))
+}
+
+func TestApplyProjectTagChecksTokenAllowList(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	common.SetContextKey(c, constant.ContextKeyTokenAllowedProjects, "search\nads")
+
+	require.NoError(t, ApplyProjectTag(c, ""))
+	assert.Empty(t, common.GetContextKeyString(c, constant.ContextKeyProject))
+
+	assert.ErrorIs(t, ApplyProjectTag(c, "billing"), ErrProjectNotAllowed)
+	assert.Error(t, ApplyProjectTag(c, "bad project"))
+
+	require.NoError(t, ApplyProjectTag(c, "ads"))
+	assert.Equal(t, "ads", common.GetContextKeyString(c, constant.ContextKeyProject))
+}
+
+func TestApplyRequestProjectTagIgnoresUnlistedProjects(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	// 令牌未配置允许列表时忽略请求体中的项目标签
+	ApplyRequestProjectTag(c, "search")
+	assert.Empty(t, common.GetContextKeyString(c, constant.ContextKeyProject))
+
+	common.SetContextKey(c, constant.ContextKeyTokenAllowedProjects, "search\nads")
+	ApplyRequestProjectTag(c, "billing")
+	ApplyRequestProjectTag(c, "bad project")
+	assert.Empty(t, common.GetContextKeyString(c, constant.ContextKeyProject))
+
+	ApplyRequestProjectTag(c, "ads")
+	assert.Equal(t, "ads", common.GetContextKeyString(c, constant.ContextKeyProject))
+}
@@ -90,7 +90,7 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 	if relayInfo.UsePrice {
 		return nil
 	}
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	userQuota, err := model.GetUserAvailableQuota(relayInfo.UserId)
 	if err != nil {
 		return err
 	}
@@ -120,6 +120,9 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 	if ok {
 		actualGroupRatio = userGroupRatio
 	}
+	if markup, err := model.GetResellerMarkup(relayInfo.ResellerId); err == nil {
+		actualGroupRatio *= markup
+	}
 
 	quotaInfo := QuotaInfo{
 		InputDetails: TokenDetails{
@@ -242,6 +245,13 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 		InjectTieredBillingInfo(other, relayInfo, tieredResult)
 	}
 	attachQuotaSaturation(ctx, relayInfo, other)
+	upstreamCost, costTracked := ComputeUpstreamCost(relayInfo, quota, billingexpr.TokenParams{
+		P:   float64(usage.InputTokens),
+		C:   float64(usage.OutputTokens),
+		Len: float64(usage.InputTokens),
+		AI:  float64(audioInputTokens),
+		AO:  float64(audioOutTokens),
+	})
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     usage.InputTokens,
@@ -255,6 +265,8 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 		IsStream:         relayInfo.IsStream,
 		Group:            relayInfo.UsingGroup,
 		Other:            other,
+		UpstreamCost:     upstreamCost,
+		CostTracked:      costTracked,
 	})
 }
 
@@ -365,6 +377,13 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 		InjectTieredBillingInfo(other, relayInfo, tieredResult)
 	}
 	attachQuotaSaturation(ctx, relayInfo, other)
+	upstreamCost, costTracked := ComputeUpstreamCost(relayInfo, quota, billingexpr.TokenParams{
+		P:   float64(usage.PromptTokens),
+		C:   float64(usage.CompletionTokens),
+		Len: float64(usage.PromptTokens),
+		AI:  float64(usage.PromptTokensDetails.AudioTokens),
+		AO:  float64(usage.CompletionTokenDetails.AudioTokens),
+	})
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     usage.PromptTokens,
@@ -378,12 +397,22 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 		IsStream:         relayInfo.IsStream,
 		Group:            relayInfo.UsingGroup,
 		Other:            other,
+		UpstreamCost:     upstreamCost,
+		CostTracked:      costTracked,
 	})
 	gopool.Go(func() {
 		perfmetrics.RecordRelaySample(relayInfo, true, int64(usage.CompletionTokens))
 	})
 }
 
+// adjustTokenBudgetUsed 预算令牌随结算差额调整本周期已用预算（delta < 0 为退还），
+// reservedAt 为预扣时间，预扣属于上一周期时不调整。
+func adjustTokenBudgetUsed(tokenId int, delta int, reservedAt int64) {
+	if err := model.AdjustTokenBudgetUsed(tokenId, delta, reservedAt); err != nil {
+		common.SysLog(fmt.Sprintf("error adjusting token budget (tokenId=%d, delta=%d): %s", tokenId, delta, err.Error()))
+	}
+}
+
 func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
@@ -392,10 +421,21 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 		return nil
 	}
 	// 原子预扣：检查与扣减在同一操作中完成，并发请求不可能同时通过检查后超扣。
-	reserved, err := model.TryReserveTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, quota, relayInfo.TokenUnlimited)
+	reserved, err := model.TryReserveTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, quota, relayInfo.TokenUnlimited, relayInfo.TokenBudgeted)
 	if err != nil {
 		return err
 	}
+	if !reserved && relayInfo.TokenBudgeted {
+		if token, tokenErr := model.GetTokenById(relayInfo.TokenId); tokenErr == nil && token.HasBudget() &&
+			token.BudgetUsed+quota > token.BudgetLimit() {
+			return fmt.Errorf("token budget exceeded for the current %s period, used: %s, limit: %s, resets at %s",
+				token.BudgetPeriod, logger.FormatQuota(token.BudgetUsed), logger.FormatQuota(token.BudgetLimit()),
+				time.Unix(token.BudgetResetTime, 0).UTC().Format(time.RFC3339))
+		}
+	}
+	if reserved && relayInfo.TokenBudgeted {
+		checkAndSendTokenBudgetNotify(relayInfo)
+	}
 	if !reserved {
 		remainQuota := 0
 		if token, tokenErr := model.GetTokenByKey(relayInfo.TokenKey, false); tokenErr == nil && token != nil {
@@ -430,14 +470,18 @@ func postConsumeQuotaWithResult(relayInfo *relaycommon.RelayInfo, quota int, pre
 			}
 			relayInfo.SubscriptionPostDelta += delta
 		}
+	} else if relayInfo != nil && relayInfo.BillingSource == BillingSourceOrganization {
+		if relayInfo.OrganizationId == 0 {
+			return result, errors.New("organization id is missing")
+		}
+		if quota != 0 {
+			if err := model.AdjustOrganizationQuota(relayInfo.OrganizationId, relayInfo.UserId, quota); err != nil {
+				return result, err
+			}
+		}
 	} else {
 		// Wallet
-		if quota > 0 {
-			err = model.DecreaseUserQuota(relayInfo.UserId, quota, false)
-		} else {
-			err = model.IncreaseUserQuota(relayInfo.UserId, -quota, false)
-		}
-		if err != nil {
+		if err = walletFundingForUser(relayInfo.UserId, relayInfo.RequestId).charge(quota); err != nil {
 			return result, err
 		}
 	}
@@ -452,6 +496,9 @@ func postConsumeQuotaWithResult(relayInfo *relaycommon.RelayInfo, quota int, pre
 		if err != nil {
 			return result, err
 		}
+		if relayInfo.TokenBudgeted {
+			adjustTokenBudgetUsed(relayInfo.TokenId, quota, relayInfo.StartTime.Unix())
+		}
 		result.TokenApplied = true
 	}
 
@@ -559,3 +606,40 @@ func checkAndSendSubscriptionQuotaNotify(relayInfo *relaycommon.RelayInfo) {
 		}
 	})
 }
+
+// checkAndSendTokenBudgetNotify 令牌本周期预算首次达到软阈值时提醒用户，每个周期只提醒一次。
+func checkAndSendTokenBudgetNotify(relayInfo *relaycommon.RelayInfo) {
+	gopool.Go(func() {
+		token, err := model.MarkTokenBudgetSoftThreshold(relayInfo.TokenId)
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to check token budget threshold for token %d: %s", relayInfo.TokenId, err.Error()))
+			return
+		}
+		if token == nil {
+			return
+		}
+
+		prompt := fmt.Sprintf("令牌 %s 的周期预算即将用尽", token.Name)
+		resetAt := time.Unix(token.BudgetResetTime, 0).Format("2006-01-02 15:04:05")
+		used := logger.FormatQuota(token.BudgetUsed)
+		budget := logger.FormatQuota(token.BudgetAmount)
+
+		var content string
+		var values []interface{}
+		notifyType := relayInfo.UserSetting.NotifyType
+		if notifyType == "" {
+			notifyType = dto.NotifyTypeEmail
+		}
+		if notifyType == dto.NotifyTypeBark || notifyType == dto.NotifyTypeGotify {
+			content = "{{value}}，本周期已用 {{value}} / {{value}}，将于 {{value}} 重置。"
+			values = []interface{}{prompt, used, budget, resetAt}
+		} else {
+			content = "{{value}}，本周期已用 {{value}}，预算为 {{value}}，将于 {{value}} 重置。<br/>如需继续使用，请调整令牌预算。"
+			values = []interface{}{prompt, used, budget, resetAt}
+		}
+
+		if err := NotifyUser(relayInfo.UserId, relayInfo.UserEmail, relayInfo.UserSetting, dto.NewNotify(dto.NotifyTypeQuotaExceed, prompt, content, values)); err != nil {
+			common.SysError(fmt.Sprintf("failed to send token budget notify to user %d: %s", relayInfo.UserId, err.Error()))
+		}
+	})
+}
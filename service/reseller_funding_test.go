@@ -0,0 +1,63 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seedResellerPair 创建一个分销商及其下游用户，分销商加价为 markup
+func seedResellerPair(t *testing.T, resellerId int, userId int, resellerQuota int, userQuota int, markup float64) {
+	t.Helper()
+	require.NoError(t, model.DB.Create(&model.User{
+		Id: resellerId, Username: "reseller", AffCode: "reseller-aff", Quota: resellerQuota, Status: common.UserStatusEnabled,
+	}).Error)
+	require.NoError(t, model.DB.Create(&model.User{
+		Id: userId, Username: "downstream", AffCode: "downstream-aff", Quota: userQuota, Status: common.UserStatusEnabled, ResellerId: resellerId,
+	}).Error)
+	require.NoError(t, model.DB.Create(&model.Reseller{
+		UserId: resellerId, Markup: markup, Status: model.ResellerStatusEnabled,
+	}).Error)
+}
+
+func TestWalletFundingChargesResellerAtWholesale(t *testing.T) {
+	truncate(t)
+	seedResellerPair(t, 4101, 4102, 1000, 1000, 2)
+
+	funding, err := newWalletFunding(4102, 4101, "req-reseller-1")
+	require.NoError(t, err)
+	require.NoError(t, funding.PreConsume(400))
+	assert.Equal(t, 600, getUserQuota(t, 4102))
+	assert.Equal(t, 800, getUserQuota(t, 4101))
+
+	require.NoError(t, funding.Settle(101))
+	assert.Equal(t, 499, getUserQuota(t, 4102))
+	// 批发额度按累计零售额度取整：round(501 / 2) = 251
+	assert.Equal(t, 749, getUserQuota(t, 4101))
+
+	now := time.Now().Unix()
+	report, err := model.GetResellerUsageReport(4101, now-60, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(501), report.RetailQuota)
+	assert.Equal(t, int64(251), report.WholesaleQuota)
+	assert.Equal(t, int64(250), report.Margin)
+}
+
+func TestWalletFundingRejectsWhenResellerWalletShort(t *testing.T) {
+	truncate(t)
+	seedResellerPair(t, 4201, 4202, 100, 1000, 2)
+
+	funding, err := newWalletFunding(4202, 4201, "req-reseller-2")
+	require.NoError(t, err)
+	err = funding.PreConsume(400)
+	assert.ErrorIs(t, err, ErrInsufficientResellerQuota)
+
+	assert.Equal(t, 1000, getUserQuota(t, 4202))
+	assert.Equal(t, 100, getUserQuota(t, 4201))
+	assert.NoError(t, funding.Refund())
+	assert.Equal(t, 1000, getUserQuota(t, 4202))
+}
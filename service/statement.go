@@ -0,0 +1,399 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/pdfdoc"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+// StatementRunSummary 一次账单生成任务的结果
+type StatementRunSummary struct {
+	PeriodStart int64 `json:"period_start"`
+	PeriodEnd   int64 `json:"period_end"`
+	Created     int   `json:"created"`
+	Skipped     int   `json:"skipped"`
+	Failed      int   `json:"failed"`
+}
+
+// GenerateStatementsForPeriod 为 [start, end) 内有账务活动的用户和组织生成账单，
+// 已生成过的账单跳过，因此可以安全重跑。
+func GenerateStatementsForPeriod(ctx context.Context, start int64, end int64, progress func(processed, total int)) (*StatementRunSummary, error) {
+	if start >= end {
+		return nil, errors.New("invalid statement period")
+	}
+	owners, err := model.GetStatementOwners(start, end)
+	if err != nil {
+		return nil, err
+	}
+	legal := system_setting.GetLegalSettings()
+	seller := model.StatementParty{
+		Name:    legal.CompanyName,
+		Address: legal.CompanyAddress,
+		TaxId:   legal.CompanyTaxId,
+		Email:   legal.CompanyEmail,
+	}
+	prefix := operation_setting.GetStatementSetting().GetInvoicePrefix()
+
+	summary := &StatementRunSummary{PeriodStart: start, PeriodEnd: end}
+	total := len(owners.UserIds) + len(owners.OrgIds)
+	processed := 0
+	generate := func(build func() (*model.Statement, error), owner string) {
+		defer func() {
+			processed++
+			if progress != nil {
+				progress(processed, total)
+			}
+		}()
+		statement, err := build()
+		if err == nil {
+			err = model.CreateStatement(statement, prefix)
+		}
+		switch {
+		case err == nil:
+			summary.Created++
+		case errors.Is(err, model.ErrStatementExists):
+			summary.Skipped++
+		default:
+			summary.Failed++
+			common.SysError(fmt.Sprintf("failed to generate statement for %s: %v", owner, err))
+		}
+	}
+	for _, userId := range owners.UserIds {
+		if ctx.Err() != nil {
+			return summary, ctx.Err()
+		}
+		generate(func() (*model.Statement, error) {
+			return model.BuildUserStatement(userId, start, end, seller, legal.InvoiceFooter)
+		}, fmt.Sprintf("user %d", userId))
+	}
+	for _, orgId := range owners.OrgIds {
+		if ctx.Err() != nil {
+			return summary, ctx.Err()
+		}
+		generate(func() (*model.Statement, error) {
+			return model.BuildOrganizationStatement(orgId, start, end, seller, legal.InvoiceFooter)
+		}, fmt.Sprintf("organization %d", orgId))
+	}
+	if progress != nil && total == 0 {
+		progress(0, 0)
+	}
+	return summary, nil
+}
+
+// PreviousStatementPeriod 返回按账单时区计算的上一个自然月
+func PreviousStatementPeriod(now time.Time) (int64, int64) {
+	return model.StatementPeriod(now, operation_setting.GetStatementSetting().Location())
+}
+
+// statementView 渲染 HTML/PDF 时共用的展示数据，金额均已格式化
+type statementView struct {
+	InvoiceNo      string
+	Title          string
+	Period         string
+	IssuedAt       string
+	Seller         model.StatementParty
+	Customer       model.StatementParty
+	Footer         string
+	Summary        []statementRow
+	TopUps         []statementRow
+	Subscriptions  []statementRow
+	Usage          []statementUsageRow
+	UsageTotal     string
+	UsageTotalCall int64
+}
+
+type statementRow struct {
+	Label  string
+	Note   string
+	Amount string
+}
+
+type statementUsageRow struct {
+	ModelName        string
+	Count            int64
+	PromptTokens     int64
+	CompletionTokens int64
+	Amount           string
+}
+
+func formatStatementQuota(quota int64) string {
+	return fmt.Sprintf("$%.4f", float64(quota)/common.QuotaPerUnit)
+}
+
+func buildStatementView(statement *model.Statement) (*statementView, error) {
+	detail, err := statement.GetDetail()
+	if err != nil {
+		return nil, err
+	}
+	loc := operation_setting.GetStatementSetting().Location()
+	periodStart := time.Unix(statement.PeriodStart, 0).In(loc)
+	periodEnd := time.Unix(statement.PeriodEnd-1, 0).In(loc)
+	view := &statementView{
+		InvoiceNo: statement.InvoiceNo,
+		Title:     "Statement",
+		Period:    fmt.Sprintf("%s - %s", periodStart.Format("2006-01-02"), periodEnd.Format("2006-01-02")),
+		IssuedAt:  time.Unix(statement.CreatedAt, 0).In(loc).Format("2006-01-02"),
+		Seller:    detail.Seller,
+		Customer:  detail.Customer,
+		Footer:    detail.Footer,
+		Summary: []statementRow{
+			{Label: "Opening balance", Amount: formatStatementQuota(statement.OpeningBalance)},
+			{Label: "Usage", Amount: formatStatementQuota(-statement.UsageQuota)},
+			{Label: "Refunds", Amount: formatStatementQuota(statement.RefundQuota)},
+			{Label: "Top-ups and other adjustments", Amount: formatStatementQuota(statement.AdjustmentQuota)},
+			{Label: "Closing balance", Amount: formatStatementQuota(statement.ClosingBalance)},
+		},
+	}
+	// 无法还原历史余额时只列出本期收支，最后一行为净额
+	if !statement.HasBalances() {
+		view.Summary = []statementRow{
+			{Label: "Usage", Amount: formatStatementQuota(-statement.UsageQuota)},
+			{Label: "Refunds", Amount: formatStatementQuota(statement.RefundQuota)},
+			{Label: "Net usage", Amount: formatStatementQuota(statement.RefundQuota - statement.UsageQuota)},
+		}
+	}
+	if statement.IsOrganization() {
+		view.Title = "Organization Statement"
+	}
+	if view.Seller.Name == "" {
+		view.Seller.Name = common.SystemName
+	}
+	for _, topUp := range detail.TopUps {
+		view.TopUps = append(view.TopUps, statementRow{
+			Label:  time.Unix(topUp.CompleteTime, 0).In(loc).Format("2006-01-02 15:04"),
+			Note:   fmt.Sprintf("%s %s", topUp.PaymentMethod, topUp.TradeNo),
+			Amount: fmt.Sprintf("%.2f", topUp.Money),
+		})
+	}
+	for _, order := range detail.Subscriptions {
+		view.Subscriptions = append(view.Subscriptions, statementRow{
+			Label:  time.Unix(order.CompleteTime, 0).In(loc).Format("2006-01-02 15:04"),
+			Note:   fmt.Sprintf("%s %s", order.Title, order.TradeNo),
+			Amount: fmt.Sprintf("%.2f", order.Money),
+		})
+	}
+	for _, usage := range detail.Usage {
+		view.Usage = append(view.Usage, statementUsageRow{
+			ModelName:        usage.ModelName,
+			Count:            usage.Count,
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			Amount:           formatStatementQuota(usage.Quota),
+		})
+		view.UsageTotalCall += usage.Count
+	}
+	view.UsageTotal = formatStatementQuota(statement.UsageQuota)
+	return view, nil
+}
+
+var statementHTMLTemplate = template.Must(template.New("statement").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} {{.InvoiceNo}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; color: #222; margin: 40px; font-size: 13px; }
+h1 { font-size: 22px; margin-bottom: 4px; }
+h2 { font-size: 15px; margin-top: 28px; border-bottom: 1px solid #ccc; padding-bottom: 4px; }
+table { width: 100%; border-collapse: collapse; }
+th, td { text-align: left; padding: 4px 6px; border-bottom: 1px solid #eee; }
+td.num, th.num { text-align: right; }
+.parties { display: flex; justify-content: space-between; margin-top: 16px; }
+.muted { color: #777; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="muted">Invoice No. {{.InvoiceNo}} &middot; Period {{.Period}} &middot; Issued {{.IssuedAt}}</div>
+<div class="parties">
+<div><strong>{{.Seller.Name}}</strong>{{if .Seller.Address}}<br>{{.Seller.Address}}{{end}}{{if .Seller.TaxId}}<br>Tax ID: {{.Seller.TaxId}}{{end}}{{if .Seller.Email}}<br>{{.Seller.Email}}{{end}}</div>
+<div><strong>Bill to: {{.Customer.Name}}</strong>{{if .Customer.Email}}<br>{{.Customer.Email}}{{end}}</div>
+</div>
+<h2>Summary</h2>
+<table>
+{{range .Summary}}<tr><td>{{.Label}}</td><td class="num">{{.Amount}}</td></tr>
+{{end}}</table>
+{{if .TopUps}}<h2>Top-ups</h2>
+<table>
+<tr><th>Date</th><th>Reference</th><th class="num">Amount paid</th></tr>
+{{range .TopUps}}<tr><td>{{.Label}}</td><td>{{.Note}}</td><td class="num">{{.Amount}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Subscriptions}}<h2>Subscriptions</h2>
+<table>
+<tr><th>Date</th><th>Plan / Reference</th><th class="num">Amount paid</th></tr>
+{{range .Subscriptions}}<tr><td>{{.Label}}</td><td>{{.Note}}</td><td class="num">{{.Amount}}</td></tr>
+{{end}}</table>
+{{end}}<h2>Usage by model</h2>
+<table>
+<tr><th>Model</th><th class="num">Requests</th><th class="num">Prompt tokens</th><th class="num">Completion tokens</th><th class="num">Amount</th></tr>
+{{range .Usage}}<tr><td>{{.ModelName}}</td><td class="num">{{.Count}}</td><td class="num">{{.PromptTokens}}</td><td class="num">{{.CompletionTokens}}</td><td class="num">{{.Amount}}</td></tr>
+{{end}}<tr><th>Total</th><th class="num">{{.UsageTotalCall}}</th><th></th><th></th><th class="num">{{.UsageTotal}}</th></tr>
+</table>
+{{if .Footer}}<p class="muted">{{.Footer}}</p>{{end}}
+</body>
+</html>
+`))
+
+// RenderStatementHTML 将账单渲染为 HTML
+func RenderStatementHTML(statement *model.Statement) ([]byte, error) {
+	view, err := buildStatementView(statement)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := statementHTMLTemplate.Execute(&buf, view); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// statementPDFWriter 负责 PDF 版面的逐行排版与自动分页
+type statementPDFWriter struct {
+	doc *pdfdoc.Document
+	y   float64
+}
+
+const (
+	statementPDFMargin     = 50.0
+	statementPDFLineHeight = 15.0
+)
+
+func (w *statementPDFWriter) right() float64 {
+	return w.doc.Width - statementPDFMargin
+}
+
+func (w *statementPDFWriter) ensure(height float64) {
+	if w.y+height > w.doc.Height-statementPDFMargin {
+		w.doc.AddPage()
+		w.y = statementPDFMargin
+	}
+}
+
+func (w *statementPDFWriter) line(size float64, bold bool, text string) {
+	w.ensure(statementPDFLineHeight)
+	w.doc.Text(statementPDFMargin, w.y, size, bold, text)
+	w.y += statementPDFLineHeight
+}
+
+func (w *statementPDFWriter) heading(text string) {
+	w.y += 10
+	w.ensure(statementPDFLineHeight * 2)
+	w.doc.Text(statementPDFMargin, w.y, 12, true, text)
+	w.y += 5
+	w.doc.Line(statementPDFMargin, w.y, w.right(), w.y, 0.5)
+	w.y += statementPDFLineHeight
+}
+
+// columns 按列绘制一行；第一列左对齐，其余列右对齐到给定的右边界
+func (w *statementPDFWriter) columns(bold bool, first string, firstWidth float64, rest []string, rights []float64) {
+	w.ensure(statementPDFLineHeight)
+	w.doc.Text(statementPDFMargin, w.y, 9, bold, w.doc.Truncate(first, firstWidth, 9, bold))
+	for i, text := range rest {
+		w.doc.TextRight(rights[i], w.y, 9, bold, text)
+	}
+	w.y += statementPDFLineHeight
+}
+
+// ErrStatementPDFFontMissing 账单含中文等非 Latin-1 文字，但未配置可嵌入的字体
+var ErrStatementPDFFontMissing = errors.New("statement contains characters outside Latin-1; configure statement_setting.pdf_font_path with a TrueType font or export HTML")
+
+// RenderStatementPDF 将账单渲染为 PDF。内置字体不含中文字形，非 Latin-1 文字使用
+// 配置的 TrueType 字体；未配置时返回 ErrStatementPDFFontMissing，不会输出乱码
+func RenderStatementPDF(statement *model.Statement) ([]byte, error) {
+	view, err := buildStatementView(statement)
+	if err != nil {
+		return nil, err
+	}
+	doc := pdfdoc.New()
+	if fontPath := strings.TrimSpace(operation_setting.GetStatementSetting().PDFFontPath); fontPath != "" {
+		fontData, err := os.ReadFile(fontPath)
+		if err != nil {
+			return nil, fmt.Errorf("read statement pdf font: %w", err)
+		}
+		if err := doc.SetUnicodeFont(fontData); err != nil {
+			return nil, err
+		}
+	}
+	w := &statementPDFWriter{doc: doc, y: statementPDFMargin + 10}
+	w.doc.AddPage()
+	right := w.right()
+
+	w.doc.Text(statementPDFMargin, w.y, 20, true, view.Title)
+	w.doc.TextRight(right, w.y, 10, true, "Invoice No. "+view.InvoiceNo)
+	w.y += 18
+	w.doc.TextRight(right, w.y, 9, false, "Period "+view.Period)
+	w.y += 12
+	w.doc.TextRight(right, w.y, 9, false, "Issued "+view.IssuedAt)
+	w.y += 20
+
+	w.line(10, true, view.Seller.Name)
+	for _, text := range []string{view.Seller.Address, taxIdLine(view.Seller.TaxId), view.Seller.Email} {
+		if text != "" {
+			w.line(9, false, text)
+		}
+	}
+	w.y += 8
+	w.line(10, true, "Bill to: "+view.Customer.Name)
+	if view.Customer.Email != "" {
+		w.line(9, false, view.Customer.Email)
+	}
+
+	amountRight := []float64{right}
+	w.heading("Summary")
+	for i, row := range view.Summary {
+		w.columns(i == len(view.Summary)-1, row.Label, 300, []string{row.Amount}, amountRight)
+	}
+
+	paymentSection := func(title string, rows []statementRow) {
+		if len(rows) == 0 {
+			return
+		}
+		w.heading(title)
+		for _, row := range rows {
+			w.columns(false, row.Label+"  "+row.Note, 380, []string{row.Amount}, amountRight)
+		}
+	}
+	paymentSection("Top-ups", view.TopUps)
+	paymentSection("Subscriptions", view.Subscriptions)
+
+	w.heading("Usage by model")
+	usageRights := []float64{right - 270, right - 180, right - 90, right}
+	w.columns(true, "Model", 180, []string{"Requests", "Prompt", "Completion", "Amount"}, usageRights)
+	for _, usage := range view.Usage {
+		w.columns(false, usage.ModelName, 180, []string{
+			fmt.Sprintf("%d", usage.Count),
+			fmt.Sprintf("%d", usage.PromptTokens),
+			fmt.Sprintf("%d", usage.CompletionTokens),
+			usage.Amount,
+		}, usageRights)
+	}
+	w.columns(true, "Total", 180, []string{fmt.Sprintf("%d", view.UsageTotalCall), "", "", view.UsageTotal}, usageRights)
+
+	if view.Footer != "" {
+		w.y += 12
+		w.line(8, false, view.Footer)
+	}
+	data, err := w.doc.Bytes()
+	if errors.Is(err, pdfdoc.ErrUnsupportedText) {
+		return nil, fmt.Errorf("%w: %v", ErrStatementPDFFontMissing, err)
+	}
+	return data, err
+}
+
+func taxIdLine(taxId string) string {
+	if taxId == "" {
+		return ""
+	}
+	return "Tax ID: " + taxId
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func newRenderTestStatement(t *testing.T) *model.Statement {
+	t.Helper()
+	detail := model.StatementDetail{
+		Seller:   model.StatementParty{Name: "Seller Ltd", TaxId: "TX-1"},
+		Customer: model.StatementParty{Name: "<alice>"},
+		TopUps:   []model.StatementPayment{{TradeNo: "top-1", PaymentMethod: "stripe", Money: 12.5, CompleteTime: 1_700_000_100}},
+		Usage:    []model.StatementModelUsage{{ModelName: "gpt-a", Count: 3, PromptTokens: 30, CompletionTokens: 12, Quota: 1500}},
+	}
+	data, err := json.Marshal(detail)
+	require.NoError(t, err)
+	return &model.Statement{
+		InvoiceNo:      "INV-000007",
+		UserId:         1,
+		PeriodStart:    1_700_000_000,
+		PeriodEnd:      1_702_592_000,
+		OpeningBalance: 5000,
+		UsageQuota:     1500,
+		ClosingBalance: 3500,
+		Detail:         string(data),
+		CreatedAt:      1_702_600_000,
+	}
+}
+
+func TestRenderStatementHTMLEscapesCustomerFields(t *testing.T) {
+	out, err := RenderStatementHTML(newRenderTestStatement(t))
+	require.NoError(t, err)
+	html := string(out)
+	assert.Contains(t, html, "INV-000007")
+	assert.Contains(t, html, "&lt;alice&gt;")
+	assert.Contains(t, html, "gpt-a")
+	assert.Contains(t, html, "Tax ID: TX-1")
+}
+
+func TestRenderStatementPDFPaginatesLongUsage(t *testing.T) {
+	statement := newRenderTestStatement(t)
+	detail, err := statement.GetDetail()
+	require.NoError(t, err)
+	for i := 0; i < 120; i++ {
+		detail.Usage = append(detail.Usage, model.StatementModelUsage{ModelName: "model", Count: 1, Quota: 1})
+	}
+	data, err := json.Marshal(detail)
+	require.NoError(t, err)
+	statement.Detail = string(data)
+
+	out, err := RenderStatementPDF(statement)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(out, []byte("%PDF-1.4")))
+	assert.Contains(t, string(out), "(Invoice No. INV-000007) Tj")
+	assert.Contains(t, string(out), "/Count 3")
+}
+
+func TestRenderStatementPDFRequiresFontForNonLatinText(t *testing.T) {
+	statement := newRenderTestStatement(t)
+	detail, err := statement.GetDetail()
+	require.NoError(t, err)
+	detail.Customer.Name = "Алиса"
+	data, err := json.Marshal(detail)
+	require.NoError(t, err)
+	statement.Detail = string(data)
+
+	setting := operation_setting.GetStatementSetting()
+	original := setting.PDFFontPath
+	t.Cleanup(func() { setting.PDFFontPath = original })
+
+	setting.PDFFontPath = ""
+	_, err = RenderStatementPDF(statement)
+	assert.ErrorIs(t, err, ErrStatementPDFFontMissing)
+
+	fontPath := filepath.Join(t.TempDir(), "font.ttf")
+	require.NoError(t, os.WriteFile(fontPath, goregular.TTF, 0o644))
+	setting.PDFFontPath = fontPath
+	out, err := RenderStatementPDF(statement)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "/FontFile2")
+}
+
+func TestRenderStatementOmitsUnavailableBalances(t *testing.T) {
+	statement := newRenderTestStatement(t)
+	statement.BalanceSource = model.StatementBalanceSourceUnavailable
+	out, err := RenderStatementHTML(statement)
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "Closing balance")
+	assert.Contains(t, string(out), "Net usage")
+}
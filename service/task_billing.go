@@ -9,6 +9,7 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/billingexpr"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/QuantumNous/new-api/types"
@@ -47,20 +48,29 @@ func LogTaskConsumption(c *gin.Context, info *relaycommon.RelayInfo) {
 	if info.PriceData.GroupRatioInfo.HasSpecialRatio {
 		other["user_group_ratio"] = info.PriceData.GroupRatioInfo.GroupSpecialRatio
 	}
+	appendResellerInfo(info, other)
 	if info.IsModelMapped {
 		other["is_model_mapped"] = true
 		other["upstream_model_name"] = info.UpstreamModelName
 	}
 	attachQuotaSaturation(c, info, other)
+	// 提交时上游尚未返回用量，成本表达式按请求的输入 token 估算
+	promptTokens := float64(info.GetEstimatePromptTokens())
+	upstreamCost, costTracked := ComputeUpstreamCost(info, info.PriceData.Quota, billingexpr.TokenParams{
+		P:   promptTokens,
+		Len: promptTokens,
+	})
 	model.RecordConsumeLog(c, info.UserId, model.RecordConsumeLogParams{
-		ChannelId: info.ChannelId,
-		ModelName: info.OriginModelName,
-		TokenName: tokenName,
-		Quota:     info.PriceData.Quota,
-		Content:   logContent,
-		TokenId:   info.TokenId,
-		Group:     info.UsingGroup,
-		Other:     other,
+		ChannelId:    info.ChannelId,
+		ModelName:    info.OriginModelName,
+		TokenName:    tokenName,
+		Quota:        info.PriceData.Quota,
+		Content:      logContent,
+		TokenId:      info.TokenId,
+		Group:        info.UsingGroup,
+		Other:        other,
+		UpstreamCost: upstreamCost,
+		CostTracked:  costTracked,
 	})
 	model.UpdateUserUsedQuotaAndRequestCount(info.UserId, info.PriceData.Quota)
 	model.UpdateChannelUsedQuota(info.ChannelId, info.PriceData.Quota)
@@ -86,15 +96,20 @@ func taskIsSubscription(task *model.Task) bool {
 	return task.PrivateData.BillingSource == BillingSourceSubscription && task.PrivateData.SubscriptionId > 0
 }
 
-// taskAdjustFunding 调整任务的资金来源（钱包或订阅），delta > 0 表示扣费，delta < 0 表示退还。
+// taskIsOrganization 判断任务是否通过组织共享钱包计费。
+func taskIsOrganization(task *model.Task) bool {
+	return task.PrivateData.BillingSource == BillingSourceOrganization && task.PrivateData.OrganizationId > 0
+}
+
+// taskAdjustFunding 调整任务的资金来源（钱包、订阅或组织），delta > 0 表示扣费，delta < 0 表示退还。
 func taskAdjustFunding(task *model.Task, delta int) error {
 	if taskIsSubscription(task) {
 		return model.PostConsumeUserSubscriptionDelta(task.PrivateData.SubscriptionId, int64(delta))
 	}
-	if delta > 0 {
-		return model.DecreaseUserQuota(task.UserId, delta, false)
+	if taskIsOrganization(task) {
+		return model.AdjustOrganizationQuota(task.PrivateData.OrganizationId, task.UserId, delta)
 	}
-	return model.IncreaseUserQuota(task.UserId, -delta, false)
+	return walletFundingForUser(task.UserId, "task:"+task.TaskID).charge(delta)
 }
 
 // taskAdjustTokenQuota 调整任务的令牌额度，delta > 0 表示扣费，delta < 0 表示退还。
@@ -115,7 +130,9 @@ func taskAdjustTokenQuota(ctx context.Context, task *model.Task, delta int) {
 	}
 	if err != nil {
 		logger.LogWarn(ctx, fmt.Sprintf("调整令牌额度失败 (delta=%d, task=%s): %s", delta, task.TaskID, err.Error()))
+		return
 	}
+	adjustTokenBudgetUsed(task.PrivateData.TokenId, delta, task.CreatedAt)
 }
 
 // taskBillingOther 从 task 的 BillingContext 构建日志 Other 字段。
@@ -196,6 +213,7 @@ func RefundTaskQuota(ctx context.Context, task *model.Task, reason string) bool
 		TokenId:   task.PrivateData.TokenId,
 		Group:     task.Group,
 		Other:     other,
+		Project:   task.PrivateData.Project,
 	})
 
 	// 5. 资金退款完成后再清除持久化标记。
@@ -266,17 +284,25 @@ func RecalculateTaskQuota(ctx context.Context, task *model.Task, actualQuota int
 	for _, clamp := range clamps {
 		attachQuotaSaturationToOther(other, clamp)
 	}
+	var upstreamCost int
+	var costTracked bool
+	if logType == model.LogTypeConsume {
+		upstreamCost, costTracked = taskUpstreamCost(task, logQuota)
+	}
 	model.RecordTaskBillingLog(model.RecordTaskBillingLogParams{
-		UserId:    task.UserId,
-		LogType:   logType,
-		Content:   reason,
-		ChannelId: task.ChannelId,
-		ModelName: taskModelName(task),
-		Quota:     logQuota,
-		TokenId:   task.PrivateData.TokenId,
-		Group:     task.Group,
-		Other:     other,
-		NodeName:  task.PrivateData.NodeName,
+		UserId:       task.UserId,
+		LogType:      logType,
+		Content:      reason,
+		ChannelId:    task.ChannelId,
+		ModelName:    taskModelName(task),
+		Quota:        logQuota,
+		TokenId:      task.PrivateData.TokenId,
+		Group:        task.Group,
+		Other:        other,
+		NodeName:     task.PrivateData.NodeName,
+		Project:      task.PrivateData.Project,
+		UpstreamCost: upstreamCost,
+		CostTracked:  costTracked,
 	})
 }
 
@@ -318,6 +344,12 @@ func RecalculateTaskQuotaByTokens(ctx context.Context, task *model.Task, totalTo
 	} else {
 		finalGroupRatio = groupRatio
 	}
+	// 分销商下游用户的任务同样叠加分销商加价
+	if userCache, err := model.GetUserCache(task.UserId); err == nil && userCache.ResellerId > 0 {
+		if markup, err := model.GetResellerMarkup(userCache.ResellerId); err == nil {
+			finalGroupRatio *= markup
+		}
+	}
 
 	// 计算 OtherRatios 乘积（视频折扣、时长等）
 	otherMultiplier := 1.0
@@ -50,6 +50,10 @@ func TestMain(m *testing.M) {
 		&model.UserSubscription{},
 		&model.SystemTask{},
 		&model.SystemTaskLock{},
+		&model.Reseller{},
+		&model.ResellerUsage{},
+		&model.TaskWebhookDelivery{},
+		&model.MediaAsset{},
 	); err != nil {
 		panic("failed to migrate: " + err.Error())
 	}
@@ -74,6 +78,10 @@ func truncate(t *testing.T) {
 		model.DB.Exec("DELETE FROM user_subscriptions")
 		model.DB.Exec("DELETE FROM system_task_locks")
 		model.DB.Exec("DELETE FROM system_tasks")
+		model.DB.Exec("DELETE FROM resellers")
+		model.DB.Exec("DELETE FROM reseller_usages")
+		model.DB.Exec("DELETE FROM task_webhook_deliveries")
+		model.DB.Exec("DELETE FROM media_assets")
 	})
 }
 
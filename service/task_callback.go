@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	taskdto "github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+var (
+	ErrTaskCallbackNotFound    = errors.New("task not found for callback")
+	ErrTaskCallbackUnsupported = errors.New("task platform does not support callbacks")
+	ErrTaskCallbackMismatch    = errors.New("callback does not match task")
+)
+
+// TaskCallbackParser 与 channel.TaskCallbackAdaptor 对应，避免 service -> relay 的循环依赖
+type TaskCallbackParser interface {
+	ParseTaskCallback(body []byte) (*relaycommon.TaskCallback, error)
+}
+
+// taskCallbackSignature 回调地址签名，绑定渠道与公开任务 ID，防止伪造其他任务的回调
+func taskCallbackSignature(channelId int, taskId string) string {
+	return common.GenerateHMAC(fmt.Sprintf("task_callback:%d:%s", channelId, taskId))
+}
+
+// BuildTaskUpstreamCallbackURL 生成传给上游的回调地址；未启用或未配置服务器地址时返回空
+func BuildTaskUpstreamCallbackURL(channelId int, taskId string) string {
+	if !operation_setting.GetTaskCallbackSetting().Enabled || taskId == "" {
+		return ""
+	}
+	serverAddress := strings.TrimRight(system_setting.ServerAddress, "/")
+	if serverAddress == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/api/task/callback/%d/%s/%s", serverAddress, channelId, taskId, taskCallbackSignature(channelId, taskId))
+}
+
+// VerifyTaskUpstreamCallback 校验回调地址中的签名
+func VerifyTaskUpstreamCallback(channelId int, taskId string, signature string) bool {
+	expected := taskCallbackSignature(channelId, taskId)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// HandleTaskUpstreamCallback 处理上游推送的任务状态，与轮询共用结果解析和结算流程。
+// 返回的 reply 非空时应原样响应给上游
+func HandleTaskUpstreamCallback(ctx context.Context, channelId int, taskId string, body []byte) ([]byte, error) {
+	task, exist, err := model.GetTaskByChannelAndTaskId(channelId, taskId)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, ErrTaskCallbackNotFound
+	}
+	if GetTaskAdaptorFunc == nil {
+		return nil, ErrTaskCallbackUnsupported
+	}
+	adaptor := GetTaskAdaptorFunc(task.Platform)
+	parser, ok := adaptor.(TaskCallbackParser)
+	if adaptor == nil || !ok {
+		return nil, ErrTaskCallbackUnsupported
+	}
+	callback, err := parser.ParseTaskCallback(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(callback.Reply) > 0 {
+		return callback.Reply, nil
+	}
+	if callback.UpstreamTaskID == "" || callback.UpstreamTaskID != task.GetUpstreamTaskID() {
+		return nil, ErrTaskCallbackMismatch
+	}
+	if task.Status == model.TaskStatusSuccess || task.Status == model.TaskStatusFailure {
+		// 重复回调或轮询已先完成，直接确认
+		return nil, nil
+	}
+	if err := task.MarkUpstreamCallback(time.Now().Unix()); err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("record upstream callback for task %s failed: %v", task.TaskID, err))
+	}
+
+	if task.Platform == constant.TaskPlatformSuno {
+		var item taskdto.SunoDataResponse
+		if err := common.Unmarshal(callback.ResultBody, &item); err != nil {
+			return nil, err
+		}
+		applySunoTaskResponse(ctx, task, item)
+		return nil, nil
+	}
+
+	ch, err := model.CacheGetChannel(channelId)
+	if err != nil {
+		return nil, err
+	}
+	info := &relaycommon.RelayInfo{}
+	info.ChannelMeta = &relaycommon.ChannelMeta{
+		ChannelBaseUrl: ch.GetBaseURL(),
+	}
+	info.ApiKey = ch.Key
+	adaptor.Init(info)
+	logger.LogDebug(ctx, "task %s upstream callback: %s", task.TaskID, callback.ResultBody)
+	return nil, applyVideoTaskResponse(ctx, adaptor, task, callback.ResultBody)
+}
+
+var (
+	callbackTaskPollMu sync.Mutex
+	// callbackTaskLastPolled 已登记上游回调的任务最近一次兜底轮询的时间，仅记录在本进程；
+	// 收到回调的时间持久化在任务上（PrivateData.CallbackAt）
+	callbackTaskLastPolled = map[int64]int64{}
+)
+
+// filterCallbackTasksForPolling 已登记上游回调的任务按兜底间隔轮询，其余任务照常轮询；
+// 兜底间隔从提交、最近一次回调或最近一次兜底轮询中较晚的时刻起算
+func filterCallbackTasksForPolling(tasks []*model.Task, now int64) []*model.Task {
+	interval := operation_setting.GetTaskCallbackSetting().SafetyNetPollInterval()
+	callbackTaskPollMu.Lock()
+	defer callbackTaskPollMu.Unlock()
+
+	lastPolled := make(map[int64]int64, len(callbackTaskLastPolled))
+	filtered := make([]*model.Task, 0, len(tasks))
+	for _, task := range tasks {
+		if !task.PrivateData.UpstreamCallback {
+			filtered = append(filtered, task)
+			continue
+		}
+		last, ok := callbackTaskLastPolled[task.ID]
+		if !ok {
+			// 首次见到时以提交时间为起点，回调正常时整个任务期间都不会轮询
+			last = task.SubmitTime
+		}
+		if task.PrivateData.CallbackAt > last {
+			last = task.PrivateData.CallbackAt
+		}
+		if now-last >= interval {
+			filtered = append(filtered, task)
+			last = now
+		}
+		lastPolled[task.ID] = last
+	}
+	callbackTaskLastPolled = lastPolled
+	return filtered
+}
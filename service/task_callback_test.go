@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type taskCallbackTestAdaptor struct {
+	taskPollingFetchAdaptor
+}
+
+func (a *taskCallbackTestAdaptor) ParseTaskCallback(body []byte) (*relaycommon.TaskCallback, error) {
+	var callback struct {
+		TaskId string `json:"task_id"`
+	}
+	if err := common.Unmarshal(body, &callback); err != nil {
+		return nil, err
+	}
+	return &relaycommon.TaskCallback{UpstreamTaskID: callback.TaskId, ResultBody: body}, nil
+}
+
+func (a *taskCallbackTestAdaptor) ParseTaskResult(body []byte) (*relaycommon.TaskInfo, error) {
+	var result struct {
+		Status string `json:"status"`
+		Url    string `json:"url"`
+	}
+	if err := common.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	return &relaycommon.TaskInfo{Status: result.Status, Url: result.Url, Progress: "100%"}, nil
+}
+
+func useTaskCallbackTestAdaptor(t *testing.T) {
+	t.Helper()
+	previousFactory := GetTaskAdaptorFunc
+	GetTaskAdaptorFunc = func(constant.TaskPlatform) TaskPollingAdaptor { return &taskCallbackTestAdaptor{} }
+	t.Cleanup(func() { GetTaskAdaptorFunc = previousFactory })
+}
+
+func TestTaskUpstreamCallbackSignatureBindsChannelAndTask(t *testing.T) {
+	sig := taskCallbackSignature(7, "task_abc")
+	assert.True(t, VerifyTaskUpstreamCallback(7, "task_abc", sig))
+	assert.False(t, VerifyTaskUpstreamCallback(8, "task_abc", sig))
+	assert.False(t, VerifyTaskUpstreamCallback(7, "task_other", sig))
+}
+
+func TestTaskUpstreamCallbackCompletesTask(t *testing.T) {
+	truncate(t)
+	useTaskCallbackTestAdaptor(t)
+
+	const channelID = 121
+	seedTaskPollingChannel(t, channelID, true)
+	seedPollingTask(t, channelID, "task_cb_ok", "upstream_cb_ok")
+
+	_, err := HandleTaskUpstreamCallback(context.Background(), channelID, "task_cb_ok",
+		[]byte(`{"task_id":"upstream_other","status":"SUCCESS"}`))
+	require.ErrorIs(t, err, ErrTaskCallbackMismatch)
+	// 回调必须携带上游任务 ID
+	_, err = HandleTaskUpstreamCallback(context.Background(), channelID, "task_cb_ok",
+		[]byte(`{"status":"SUCCESS","url":"https://cdn.example.com/forged.mp4"}`))
+	require.ErrorIs(t, err, ErrTaskCallbackMismatch)
+
+	_, err = HandleTaskUpstreamCallback(context.Background(), channelID, "task_cb_ok",
+		[]byte(`{"task_id":"upstream_cb_ok","status":"SUCCESS","url":"https://cdn.example.com/v.mp4"}`))
+	require.NoError(t, err)
+
+	task, exist, err := model.GetByTaskId(1, "task_cb_ok")
+	require.NoError(t, err)
+	require.True(t, exist)
+	assert.EqualValues(t, model.TaskStatusSuccess, task.Status)
+	assert.Equal(t, "https://cdn.example.com/v.mp4", task.GetResultURL())
+
+	_, err = HandleTaskUpstreamCallback(context.Background(), channelID+1, "task_cb_ok", []byte(`{}`))
+	assert.ErrorIs(t, err, ErrTaskCallbackNotFound)
+}
+
+func TestCallbackTasksArePolledOnlyAsSafetyNet(t *testing.T) {
+	setting := operation_setting.GetTaskCallbackSetting()
+	original := *setting
+	t.Cleanup(func() { *setting = original })
+	setting.SafetyNetPollSeconds = 600
+
+	now := time.Now().Unix()
+	plain := &model.Task{ID: 1, SubmitTime: now}
+	fresh := &model.Task{ID: 2, SubmitTime: now - 60}
+	fresh.PrivateData.UpstreamCallback = true
+	stale := &model.Task{ID: 3, SubmitTime: now - 900}
+	stale.PrivateData.UpstreamCallback = true
+
+	polled := filterCallbackTasksForPolling([]*model.Task{plain, fresh, stale}, now)
+	assert.Equal(t, []*model.Task{plain, stale}, polled)
+
+	// 兜底轮询后重新计时
+	polled = filterCallbackTasksForPolling([]*model.Task{plain, fresh, stale}, now+60)
+	assert.Equal(t, []*model.Task{plain}, polled)
+}
+
+func TestTaskUpstreamCallbackPersistsReceiptForPolling(t *testing.T) {
+	truncate(t)
+	useTaskCallbackTestAdaptor(t)
+	setting := operation_setting.GetTaskCallbackSetting()
+	original := *setting
+	t.Cleanup(func() { *setting = original })
+	setting.SafetyNetPollSeconds = 600
+
+	const channelID = 122
+	seedTaskPollingChannel(t, channelID, true)
+	seedPollingTask(t, channelID, "task_cb_progress", "upstream_cb_progress")
+	require.NoError(t, model.DB.Model(&model.Task{}).Where("task_id = ?", "task_cb_progress").
+		Update("submit_time", time.Now().Unix()-900).Error)
+
+	_, err := HandleTaskUpstreamCallback(context.Background(), channelID, "task_cb_progress",
+		[]byte(`{"task_id":"upstream_cb_progress","status":"IN_PROGRESS"}`))
+	require.NoError(t, err)
+
+	task, exist, err := model.GetByTaskId(1, "task_cb_progress")
+	require.NoError(t, err)
+	require.True(t, exist)
+	assert.NotZero(t, task.PrivateData.CallbackAt)
+
+	// 提交已超过兜底间隔，但刚收到回调，本进程未记录过轮询时间时同样不轮询
+	task.PrivateData.UpstreamCallback = true
+	callbackTaskPollMu.Lock()
+	callbackTaskLastPolled = map[int64]int64{}
+	callbackTaskPollMu.Unlock()
+	assert.Empty(t, filterCallbackTasksForPolling([]*model.Task{task}, time.Now().Unix()))
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+)
+
+const taskCancelReason = "任务已被用户取消"
+
+var (
+	ErrTaskCancelUnsupported = errors.New("task platform does not support cancellation")
+	ErrTaskCancelRejected    = errors.New("upstream rejected the cancellation")
+	ErrTaskAlreadyFinished   = errors.New("task has already finished")
+	ErrTaskNotFinished       = errors.New("task is still in progress")
+)
+
+// TaskCanceler 与 channel.TaskCancelAdaptor 对应，避免 service -> relay 的循环依赖
+type TaskCanceler interface {
+	CancelTask(baseURL string, key string, body map[string]any, proxy string) (*http.Response, error)
+}
+
+// IsTaskFinished 任务是否已到达终态
+func IsTaskFinished(task *model.Task) bool {
+	return task.Status == model.TaskStatusSuccess || task.Status == model.TaskStatusFailure
+}
+
+// CancelTask 取消未结束的任务：上游确认取消后将任务置为失败，并通过任务退款流程退还预扣额度。
+// 上游不支持取消的平台直接拒绝，避免上游继续生成而网关已经退款
+func CancelTask(ctx context.Context, task *model.Task) error {
+	if IsTaskFinished(task) {
+		return ErrTaskAlreadyFinished
+	}
+	if GetTaskAdaptorFunc == nil {
+		return ErrTaskCancelUnsupported
+	}
+	adaptor := GetTaskAdaptorFunc(task.Platform)
+	canceler, ok := adaptor.(TaskCanceler)
+	if adaptor == nil || !ok {
+		return ErrTaskCancelUnsupported
+	}
+
+	ch, err := model.CacheGetChannel(task.ChannelId)
+	if err != nil {
+		return err
+	}
+	baseURL := constant.ChannelBaseURLs[ch.Type]
+	if ch.GetBaseURL() != "" {
+		baseURL = ch.GetBaseURL()
+	}
+	key := ch.Key
+	if task.PrivateData.Key != "" {
+		key = task.PrivateData.Key
+	}
+	resp, err := canceler.CancelTask(baseURL, key, map[string]any{
+		"task_id": task.GetUpstreamTaskID(),
+		"action":  task.Action,
+	}, ch.GetSetting().Proxy)
+	if err != nil {
+		return fmt.Errorf("cancel upstream task failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		// 上游响应可能含渠道信息，只记录在服务端日志
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		logger.LogWarn(ctx, fmt.Sprintf("upstream rejected cancellation of task %s: status %d: %s", task.TaskID, resp.StatusCode, body))
+		return ErrTaskCancelRejected
+	}
+
+	oldStatus := task.Status
+	task.Status = model.TaskStatusFailure
+	task.Progress = "100%"
+	task.FinishTime = time.Now().Unix()
+	task.FailReason = taskCancelReason
+	won, err := task.UpdateWithStatus(oldStatus)
+	if err != nil {
+		return err
+	}
+	if !won {
+		// 轮询或回调已先一步推进任务，结算由对方负责
+		return ErrTaskAlreadyFinished
+	}
+	logger.LogInfo(ctx, fmt.Sprintf("task %s cancelled by user %d", task.TaskID, task.UserId))
+	if task.Quota != 0 {
+		RefundTaskQuota(ctx, task, taskCancelReason)
+	}
+	OnTaskFinished(ctx, task)
+	return nil
+}
+
+// DeleteTask 删除已结束的任务及其转存文件。未结束的任务需先取消，避免留下未结算的预扣费
+func DeleteTask(ctx context.Context, task *model.Task) error {
+	if !IsTaskFinished(task) {
+		return ErrTaskNotFinished
+	}
+	assets, err := model.GetMediaAssetsBySourceId(task.UserId, task.TaskID)
+	if err != nil {
+		return err
+	}
+	for _, asset := range assets {
+		if err := DeleteMediaAssetFile(ctx, asset); err != nil {
+			return err
+		}
+	}
+	return model.DeleteTask(task.ID)
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type taskCancelTestAdaptor struct {
+	taskPollingFetchAdaptor
+	status    int
+	cancelled []string
+}
+
+func (a *taskCancelTestAdaptor) CancelTask(_ string, _ string, body map[string]any, _ string) (*http.Response, error) {
+	a.cancelled = append(a.cancelled, body["task_id"].(string))
+	recorder := httptest.NewRecorder()
+	recorder.WriteHeader(a.status)
+	if a.status/100 != 2 {
+		_, _ = recorder.WriteString(`{"error":"channel sk-upstream quota exhausted"}`)
+	}
+	return recorder.Result(), nil
+}
+
+func useTaskCancelTestAdaptor(t *testing.T, status int) *taskCancelTestAdaptor {
+	t.Helper()
+	adaptor := &taskCancelTestAdaptor{status: status}
+	previousFactory := GetTaskAdaptorFunc
+	GetTaskAdaptorFunc = func(constant.TaskPlatform) TaskPollingAdaptor { return adaptor }
+	t.Cleanup(func() { GetTaskAdaptorFunc = previousFactory })
+	return adaptor
+}
+
+func TestCancelTaskRefundsAfterUpstreamCancel(t *testing.T) {
+	truncate(t)
+	adaptor := useTaskCancelTestAdaptor(t, http.StatusOK)
+
+	const userID, tokenID, channelID = 441, 441, 441
+	seedUser(t, userID, 7000)
+	seedToken(t, tokenID, userID, "sk-cancel-key", 2000)
+	seedChannel(t, channelID)
+	task := makeTask(userID, channelID, 3000, tokenID, BillingSourceWallet, 0)
+	task.PrivateData.UpstreamTaskID = "upstream_cancel"
+	require.NoError(t, model.DB.Create(task).Error)
+
+	require.NoError(t, CancelTask(context.Background(), task))
+
+	assert.Equal(t, []string{"upstream_cancel"}, adaptor.cancelled)
+	saved, exists, err := model.GetByTaskId(userID, task.TaskID)
+	require.NoError(t, err)
+	require.True(t, exists)
+	assert.EqualValues(t, model.TaskStatusFailure, saved.Status)
+	assert.Equal(t, taskCancelReason, saved.FailReason)
+	assert.Zero(t, saved.Quota)
+	assert.Equal(t, 10000, getUserQuota(t, userID))
+	assert.Equal(t, 5000, getTokenRemainQuota(t, tokenID))
+
+	// 已结束的任务不能再次取消，避免重复退款
+	assert.ErrorIs(t, CancelTask(context.Background(), saved), ErrTaskAlreadyFinished)
+}
+
+func TestCancelTaskKeepsChargeWhenUpstreamRejects(t *testing.T) {
+	truncate(t)
+	useTaskCancelTestAdaptor(t, http.StatusConflict)
+
+	seedUser(t, 442, 7000)
+	seedChannel(t, 442)
+	task := makeTask(442, 442, 3000, 0, BillingSourceWallet, 0)
+	require.NoError(t, model.DB.Create(task).Error)
+
+	err := CancelTask(context.Background(), task)
+	assert.ErrorIs(t, err, ErrTaskCancelRejected)
+	// 上游响应体只记录在服务端，不返回给调用方
+	assert.NotContains(t, err.Error(), "sk-upstream")
+
+	saved, _, err := model.GetByTaskId(442, task.TaskID)
+	require.NoError(t, err)
+	assert.EqualValues(t, model.TaskStatusInProgress, saved.Status)
+	assert.Equal(t, 3000, saved.Quota)
+	assert.Equal(t, 7000, getUserQuota(t, 442))
+}
+
+func TestCancelTaskRejectsPlatformsWithoutCancel(t *testing.T) {
+	truncate(t)
+	useTaskCallbackTestAdaptor(t)
+
+	task := makeTask(443, 443, 3000, 0, BillingSourceWallet, 0)
+	assert.ErrorIs(t, CancelTask(context.Background(), task), ErrTaskCancelUnsupported)
+}
+
+func TestDeleteTaskPurgesArchivedMedia(t *testing.T) {
+	truncate(t)
+	enableMediaArchive(t, nil)
+	source := newMediaSourceServer(t, "video-bytes")
+
+	task := makeTask(444, 444, 0, 0, BillingSourceWallet, 0)
+	require.NoError(t, model.DB.Create(task).Error)
+	assert.ErrorIs(t, DeleteTask(context.Background(), task), ErrTaskNotFinished)
+
+	task.Status = model.TaskStatusSuccess
+	require.NoError(t, model.DB.Model(task).Update("status", task.Status).Error)
+	asset, err := ArchiveMediaURL(context.Background(), 444, model.MediaSourceTask, task.TaskID, model.MediaKindVideo, source.URL+"/v.mp4")
+	require.NoError(t, err)
+
+	require.NoError(t, DeleteTask(context.Background(), task))
+
+	_, exists, err := model.GetByTaskId(444, task.TaskID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	_, err = model.GetMediaAssetById(asset.Id, 0)
+	assert.ErrorIs(t, err, model.ErrMediaAssetNotFound)
+	_, err = OpenMediaAsset(context.Background(), asset)
+	assert.ErrorIs(t, err, ErrMediaObjectNotFound)
+}
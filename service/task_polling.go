@@ -85,6 +85,7 @@ func sweepTimedOutTasks(ctx context.Context) {
 		if !isLegacy && task.Quota != 0 {
 			RefundTaskQuota(ctx, task, reason)
 		}
+		EnqueueTaskWebhook(ctx, task)
 	}
 
 	if timedOutCount > 0 {
@@ -118,8 +119,13 @@ func RunTaskPollingOnce(ctx context.Context, report func(processed, total int))
 	sweepTimedOutTasks(ctx)
 	allTasks := model.GetAllUnFinishSyncTasks(constant.TaskQueryLimit)
 	summary.UnfinishedTasks = len(allTasks)
+	allTasks = filterCallbackTasksForPolling(allTasks, time.Now().Unix())
 	platformTask := make(map[constant.TaskPlatform][]*model.Task)
 	for _, t := range allTasks {
+		if t.Platform == constant.TaskPlatformImage {
+			// 异步图片任务由提交节点的后台协程推进，没有可轮询的上游任务；进程中断由超时清理退款
+			continue
+		}
 		platformTask[t.Platform] = append(platformTask[t.Platform], t)
 	}
 
@@ -276,38 +282,48 @@ func updateSunoTasks(ctx context.Context, channelId int, taskIds []string, taskM
 			logger.LogWarn(ctx, fmt.Sprintf("Suno task response ignored: unknown task_id=%s", responseItem.TaskID))
 			continue
 		}
-		if !taskNeedsUpdate(task, responseItem) {
-			continue
-		}
+		applySunoTaskResponse(ctx, task, responseItem)
+	}
+	return nil
+}
 
-		prevStatus := task.Status
-		task.Status = lo.If(model.TaskStatus(responseItem.Status) != "", model.TaskStatus(responseItem.Status)).Else(task.Status)
-		task.FailReason = lo.If(responseItem.FailReason != "", responseItem.FailReason).Else(task.FailReason)
-		task.SubmitTime = lo.If(responseItem.SubmitTime != 0, responseItem.SubmitTime).Else(task.SubmitTime)
-		task.StartTime = lo.If(responseItem.StartTime != 0, responseItem.StartTime).Else(task.StartTime)
-		task.FinishTime = lo.If(responseItem.FinishTime != 0, responseItem.FinishTime).Else(task.FinishTime)
-		isFailure := responseItem.FailReason != "" || task.Status == model.TaskStatusFailure
-		if isFailure {
-			logger.LogInfo(ctx, task.TaskID+" 构建失败，"+task.FailReason)
-			task.Status = model.TaskStatusFailure
-			task.Progress = "100%"
-		}
-		if responseItem.Status == model.TaskStatusSuccess {
-			task.Progress = "100%"
-		}
-		task.Data = responseItem.Data
+// applySunoTaskResponse 将单个 Suno 任务的上游状态（轮询结果或回调）写入任务
+func applySunoTaskResponse(ctx context.Context, task *model.Task, responseItem taskdto.SunoDataResponse) {
+	if !taskNeedsUpdate(task, responseItem) {
+		return
+	}
 
-		// 持久化走 CAS，防止重叠轮询/sweep/多实例/持久化失败重试导致重复退款或覆盖终态。
-		won, err := task.UpdateWithStatus(prevStatus)
-		if err != nil {
-			logger.LogError(ctx, fmt.Sprintf("UpdateSunoTask task %s error: %v", task.TaskID, err))
-		} else if !won {
-			logger.LogWarn(ctx, fmt.Sprintf("Task %s CAS lost or no-op update, skip billing", task.TaskID))
-		} else if isFailure && prevStatus != model.TaskStatusFailure && task.Quota != 0 {
+	prevStatus := task.Status
+	task.Status = lo.If(model.TaskStatus(responseItem.Status) != "", model.TaskStatus(responseItem.Status)).Else(task.Status)
+	task.FailReason = lo.If(responseItem.FailReason != "", responseItem.FailReason).Else(task.FailReason)
+	task.SubmitTime = lo.If(responseItem.SubmitTime != 0, responseItem.SubmitTime).Else(task.SubmitTime)
+	task.StartTime = lo.If(responseItem.StartTime != 0, responseItem.StartTime).Else(task.StartTime)
+	task.FinishTime = lo.If(responseItem.FinishTime != 0, responseItem.FinishTime).Else(task.FinishTime)
+	isFailure := responseItem.FailReason != "" || task.Status == model.TaskStatusFailure
+	if isFailure {
+		logger.LogInfo(ctx, task.TaskID+" 构建失败，"+task.FailReason)
+		task.Status = model.TaskStatusFailure
+		task.Progress = "100%"
+	}
+	if responseItem.Status == model.TaskStatusSuccess {
+		task.Progress = "100%"
+	}
+	task.Data = responseItem.Data
+
+	// 持久化走 CAS，防止重叠轮询/sweep/多实例/持久化失败重试导致重复退款或覆盖终态。
+	won, err := task.UpdateWithStatus(prevStatus)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("UpdateSunoTask task %s error: %v", task.TaskID, err))
+	} else if !won {
+		logger.LogWarn(ctx, fmt.Sprintf("Task %s CAS lost or no-op update, skip billing", task.TaskID))
+	} else {
+		if isFailure && prevStatus != model.TaskStatusFailure && task.Quota != 0 {
 			RefundTaskQuota(ctx, task, task.FailReason)
 		}
+		if task.Status != prevStatus {
+			OnTaskFinished(ctx, task)
+		}
 	}
-	return nil
 }
 
 // taskNeedsUpdate 检查 Suno 任务是否需要更新
@@ -473,9 +489,15 @@ func updateVideoSingleTask(ctx context.Context, adaptor TaskPollingAdaptor, ch *
 	}
 
 	logger.LogDebug(ctx, "updateVideoSingleTask response: %s", responseBody)
+	return applyVideoTaskResponse(ctx, adaptor, task, responseBody)
+}
 
+// applyVideoTaskResponse 将上游返回的任务状态（轮询结果或回调）写入任务，并在终态时结算或退款
+func applyVideoTaskResponse(ctx context.Context, adaptor TaskPollingAdaptor, task *model.Task, responseBody []byte) error {
+	taskId := task.GetUpstreamTaskID()
 	snap := task.Snapshot()
 
+	var err error
 	taskResult := &relaycommon.TaskInfo{}
 	// try parse as New API response format
 	var responseItems taskdto.TaskResponse[model.Task]
@@ -571,6 +593,7 @@ func updateVideoSingleTask(ctx context.Context, adaptor TaskPollingAdaptor, ch *
 	}
 
 	isDone := task.Status == model.TaskStatusSuccess || task.Status == model.TaskStatusFailure
+	shouldNotify := false
 	if isDone && snap.Status != task.Status {
 		won, err := task.UpdateWithStatus(snap.Status)
 		if err != nil {
@@ -581,6 +604,8 @@ func updateVideoSingleTask(ctx context.Context, adaptor TaskPollingAdaptor, ch *
 			logger.LogWarn(ctx, fmt.Sprintf("Task %s CAS lost or no-op update, skip billing", task.TaskID))
 			shouldRefund = false
 			shouldSettle = false
+		} else {
+			shouldNotify = true
 		}
 	} else if !snap.Equal(task.Snapshot()) {
 		if _, err := task.UpdateWithStatus(snap.Status); err != nil {
@@ -597,6 +622,9 @@ func updateVideoSingleTask(ctx context.Context, adaptor TaskPollingAdaptor, ch *
 	if shouldRefund {
 		RefundTaskQuota(ctx, task, task.FailReason)
 	}
+	if shouldNotify {
+		OnTaskFinished(ctx, task)
+	}
 
 	return nil
 }
@@ -496,3 +496,38 @@ func TestSweepTimedOutTasksHonorsRefundRolloutBoundary(t *testing.T) {
 	assert.Equal(t, initialQuota+modernTaskQuota, getUserQuota(t, userID))
 	assert.Equal(t, int64(1), countLogs(t))
 }
+
+func TestRunTaskPollingOnceLeavesAsyncImageTasksToTheirWorker(t *testing.T) {
+	truncate(t)
+
+	const userID, initialQuota, taskQuota = 404, 10_000, 1_500
+	seedUser(t, userID, initialQuota)
+
+	task := makeTask(userID, 0, taskQuota, 0, BillingSourceWallet, 0)
+	task.TaskID = "async_image_in_progress"
+	task.Platform = constant.TaskPlatformImage
+	task.SubmitTime = time.Now().Unix()
+	require.NoError(t, model.DB.Create(task).Error)
+
+	previousFactory := GetTaskAdaptorFunc
+	GetTaskAdaptorFunc = func(constant.TaskPlatform) TaskPollingAdaptor {
+		return &taskPollingFetchAdaptor{}
+	}
+	previousLimit := constant.TaskQueryLimit
+	constant.TaskQueryLimit = 100
+	t.Cleanup(func() {
+		GetTaskAdaptorFunc = previousFactory
+		constant.TaskQueryLimit = previousLimit
+	})
+
+	summary := RunTaskPollingOnce(context.Background(), nil)
+
+	// 图片任务没有上游任务 ID，不能被当作异常任务直接置为失败
+	assert.Equal(t, 1, summary.UnfinishedTasks)
+	assert.Zero(t, summary.NullTasksFailed)
+	var reloaded model.Task
+	require.NoError(t, model.DB.First(&reloaded, task.ID).Error)
+	assert.EqualValues(t, model.TaskStatusInProgress, reloaded.Status)
+	assert.Equal(t, taskQuota, reloaded.Quota)
+	assert.Equal(t, initialQuota, getUserQuota(t, userID))
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel/task/taskcommon"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	taskWebhookTickInterval = 10 * time.Second
+	taskWebhookBatchSize    = 50
+	// taskWebhookClaimLease 领取后在该时长内不会被再次领取，应大于单次投递的超时
+	taskWebhookClaimLease = 120
+	taskWebhookMaxURLLen  = 512
+
+	TaskWebhookEventSucceeded = "task.succeeded"
+	TaskWebhookEventFailed    = "task.failed"
+)
+
+// taskWebhookRetryDelays 第 N 次失败后的重试间隔（秒），用尽后标记为投递失败
+var taskWebhookRetryDelays = []int64{30, 60, 300, 900, 3600, 10800}
+
+var ErrInvalidTaskCallbackURL = errors.New("invalid callback url")
+
+var (
+	taskWebhookOnce    sync.Once
+	taskWebhookRunning atomic.Bool
+)
+
+// TaskWebhookPayload 任务结束时发送给客户端的回调内容
+type TaskWebhookPayload struct {
+	Event      string `json:"event"`
+	TaskId     string `json:"task_id"`
+	Platform   string `json:"platform"`
+	Action     string `json:"action"`
+	Status     string `json:"status"`
+	Progress   string `json:"progress"`
+	FailReason string `json:"fail_reason,omitempty"`
+	ResultUrl  string `json:"result_url,omitempty"`
+	SubmitTime int64  `json:"submit_time"`
+	FinishTime int64  `json:"finish_time"`
+	Timestamp  int64  `json:"timestamp"`
+}
+
+// taskCallbackRequest 各类任务请求体中携带回调地址的字段
+type taskCallbackRequest struct {
+	CallbackURL string `json:"callback_url"`
+	NotifyHook  string `json:"notify_hook"`
+}
+
+// ValidateTaskCallbackURL 校验回调地址，仅允许 http/https，非 Worker 模式下做 SSRF 检查；空地址视为未设置
+func ValidateTaskCallbackURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	if len(raw) > taskWebhookMaxURLLen {
+		return "", fmt.Errorf("%w: longer than %d characters", ErrInvalidTaskCallbackURL, taskWebhookMaxURLLen)
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return "", fmt.Errorf("%w: must be an absolute http(s) url", ErrInvalidTaskCallbackURL)
+	}
+	if !system_setting.EnableWorker() {
+		if err := ValidateSSRFProtectedFetchURL(raw); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidTaskCallbackURL, err)
+		}
+	}
+	return raw, nil
+}
+
+// ResolveTaskCallbackURL 确定本次任务的回调地址：请求中指定的地址优先，其次是令牌的默认地址。
+// requested 为调用方已解析出的地址（如 Midjourney 的 notifyHook），为空时从请求体读取 callback_url / notify_hook
+func ResolveTaskCallbackURL(c *gin.Context, requested string) (string, error) {
+	if requested == "" {
+		requested = c.GetHeader("X-Callback-Url")
+	}
+	if requested == "" {
+		var req taskCallbackRequest
+		if err := common.UnmarshalBodyReusable(c, &req); err == nil {
+			requested = req.CallbackURL
+			if requested == "" {
+				requested = req.NotifyHook
+			}
+		}
+	}
+	if strings.TrimSpace(requested) == "" {
+		requested = common.GetContextKeyString(c, constant.ContextKeyTokenCallbackUrl)
+	}
+	return ValidateTaskCallbackURL(requested)
+}
+
+func taskWebhookEvent(success bool) string {
+	if success {
+		return TaskWebhookEventSucceeded
+	}
+	return TaskWebhookEventFailed
+}
+
+// EnqueueTaskWebhook 异步任务进入终态后写入回调队列；未设置回调地址时不做任何事
+func EnqueueTaskWebhook(ctx context.Context, task *model.Task) {
+	if task == nil || task.PrivateData.CallbackURL == "" {
+		return
+	}
+	if task.Status != model.TaskStatusSuccess && task.Status != model.TaskStatusFailure {
+		return
+	}
+	resultUrl := task.GetResultURL()
+	if strings.HasPrefix(resultUrl, "data:") {
+		resultUrl = taskcommon.BuildProxyURL(task.TaskID)
+	}
+	payload := TaskWebhookPayload{
+		Event:      taskWebhookEvent(task.Status == model.TaskStatusSuccess),
+		TaskId:     task.TaskID,
+		Platform:   string(task.Platform),
+		Action:     task.Action,
+		Status:     string(task.Status),
+		Progress:   task.Progress,
+		FailReason: task.FailReason,
+		ResultUrl:  resultUrl,
+		SubmitTime: task.SubmitTime,
+		FinishTime: task.FinishTime,
+	}
+	enqueueTaskWebhook(ctx, task.UserId, model.TaskWebhookSourceTask, task.PrivateData.CallbackURL, payload)
+}
+
+// EnqueueMidjourneyWebhook Midjourney 任务进入终态后写入回调队列
+func EnqueueMidjourneyWebhook(ctx context.Context, task *model.Midjourney) {
+	if task == nil || task.CallbackUrl == "" {
+		return
+	}
+	if task.Status != "SUCCESS" && task.Status != "FAILURE" {
+		return
+	}
+	resultUrl := task.ImageUrl
+	if resultUrl == "" {
+		resultUrl = task.VideoUrl
+	}
+	payload := TaskWebhookPayload{
+		Event:      taskWebhookEvent(task.Status == "SUCCESS"),
+		TaskId:     task.MjId,
+		Platform:   string(constant.TaskPlatformMidjourney),
+		Action:     task.Action,
+		Status:     task.Status,
+		Progress:   task.Progress,
+		FailReason: task.FailReason,
+		ResultUrl:  resultUrl,
+		SubmitTime: task.SubmitTime,
+		FinishTime: task.FinishTime,
+	}
+	enqueueTaskWebhook(ctx, task.UserId, model.TaskWebhookSourceMidjourney, task.CallbackUrl, payload)
+}
+
+func enqueueTaskWebhook(ctx context.Context, userId int, source string, callbackURL string, payload TaskWebhookPayload) {
+	payload.Timestamp = time.Now().Unix()
+	data, err := common.Marshal(payload)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("marshal task webhook payload for %s failed: %v", payload.TaskId, err))
+		return
+	}
+	delivery := &model.TaskWebhookDelivery{
+		UserId:  userId,
+		Source:  source,
+		TaskId:  payload.TaskId,
+		Event:   payload.Event,
+		Url:     callbackURL,
+		Payload: string(data),
+	}
+	if err := model.CreateTaskWebhookDelivery(delivery); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("enqueue task webhook for %s failed: %v", payload.TaskId, err))
+	}
+}
+
+// StartTaskWebhookDeliveryTask 定期投递到期的任务回调，仅在主节点运行
+func StartTaskWebhookDeliveryTask() {
+	taskWebhookOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("task webhook delivery task started: tick=%s", taskWebhookTickInterval))
+			ticker := time.NewTicker(taskWebhookTickInterval)
+			defer ticker.Stop()
+
+			runTaskWebhookDeliveryOnce()
+			for range ticker.C {
+				runTaskWebhookDeliveryOnce()
+			}
+		})
+	})
+}
+
+func runTaskWebhookDeliveryOnce() {
+	if !taskWebhookRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer taskWebhookRunning.Store(false)
+
+	ctx := context.Background()
+	for {
+		deliveries, err := model.ClaimDueTaskWebhookDeliveries(taskWebhookBatchSize, taskWebhookClaimLease)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("claim task webhook deliveries failed: %v", err))
+			return
+		}
+		for _, delivery := range deliveries {
+			deliverTaskWebhook(ctx, delivery)
+		}
+		if len(deliveries) < taskWebhookBatchSize {
+			return
+		}
+	}
+}
+
+// deliverTaskWebhook 投递一条回调并记录结果；签名使用用户通知设置中的 webhook 密钥，未设置时自动生成
+func deliverTaskWebhook(ctx context.Context, delivery *model.TaskWebhookDelivery) {
+	statusCode, err := sendTaskWebhook(delivery)
+	nextAttemptAt := int64(0)
+	if err != nil && delivery.Attempts < len(taskWebhookRetryDelays) {
+		nextAttemptAt = time.Now().Unix() + taskWebhookRetryDelays[delivery.Attempts]
+	}
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("task webhook #%d for %s attempt %d failed: %v", delivery.Id, delivery.TaskId, delivery.Attempts+1, err))
+	}
+	if recordErr := model.RecordTaskWebhookAttempt(delivery.Id, statusCode, err, nextAttemptAt); recordErr != nil {
+		logger.LogError(ctx, fmt.Sprintf("record task webhook #%d attempt failed: %v", delivery.Id, recordErr))
+	}
+}
+
+func sendTaskWebhook(delivery *model.TaskWebhookDelivery) (int, error) {
+	// 入队后站点的 SSRF 配置可能已变化，投递前重新校验
+	if _, err := ValidateTaskCallbackURL(delivery.Url); err != nil {
+		return 0, err
+	}
+	// 未配置回调密钥时先生成，生成失败则不投递，避免发出无签名的回调
+	secret, err := model.EnsureUserTaskCallbackSecret(delivery.UserId)
+	if err != nil {
+		return 0, fmt.Errorf("webhook secret unavailable: %w", err)
+	}
+	return postSignedWebhook(delivery.Url, secret, []byte(delivery.Payload), map[string]string{
+		"X-Webhook-Event":    delivery.Event,
+		"X-Webhook-Delivery": strconv.Itoa(delivery.Id),
+	})
+}
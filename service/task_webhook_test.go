@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// allowLocalTaskWebhooks 关闭 SSRF 防护，使回调可以投递到本地测试服务器
+func allowLocalTaskWebhooks(t *testing.T) {
+	t.Helper()
+	fetchSetting := system_setting.GetFetchSetting()
+	original := *fetchSetting
+	originalClient := httpClient
+	t.Cleanup(func() {
+		*fetchSetting = original
+		httpClient = originalClient
+	})
+	fetchSetting.EnableSSRFProtection = false
+	httpClient = &http.Client{}
+}
+
+func latestTaskWebhookDelivery(t *testing.T, taskId string) *model.TaskWebhookDelivery {
+	t.Helper()
+	var delivery model.TaskWebhookDelivery
+	require.NoError(t, model.DB.Where("task_id = ?", taskId).Order("id desc").First(&delivery).Error)
+	return &delivery
+}
+
+func TestValidateTaskCallbackURLRejectsPrivateTargets(t *testing.T) {
+	configureSSRFTestFetchSetting(t)
+
+	_, err := ValidateTaskCallbackURL("http://127.0.0.1/hook")
+	assert.ErrorIs(t, err, ErrInvalidTaskCallbackURL)
+	_, err = ValidateTaskCallbackURL("ftp://example.com/hook")
+	assert.ErrorIs(t, err, ErrInvalidTaskCallbackURL)
+
+	url, err := ValidateTaskCallbackURL("  ")
+	require.NoError(t, err)
+	assert.Empty(t, url)
+}
+
+func TestTaskWebhookDeliversSignedPayload(t *testing.T) {
+	truncate(t)
+	allowLocalTaskWebhooks(t)
+
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get("X-Webhook-Signature")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	user := &model.User{Id: 4301, Username: "hook_user", AffCode: "hook-aff", Status: common.UserStatusEnabled}
+	user.SetSetting(dto.UserSetting{WebhookSecret: "s3cret"})
+	require.NoError(t, model.DB.Create(user).Error)
+
+	task := &model.Task{TaskID: "task_hook_ok", UserId: 4301, Status: model.TaskStatusSuccess, Progress: "100%"}
+	task.PrivateData.CallbackURL = server.URL
+	task.PrivateData.ResultURL = "https://cdn.example.com/video.mp4"
+	EnqueueTaskWebhook(context.Background(), task)
+
+	runTaskWebhookDeliveryOnce()
+
+	delivery := latestTaskWebhookDelivery(t, "task_hook_ok")
+	assert.Equal(t, model.TaskWebhookStatusSucceeded, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, http.StatusNoContent, delivery.LastStatusCode)
+	assert.Equal(t, generateSignature("s3cret", body), signature)
+
+	var payload TaskWebhookPayload
+	require.NoError(t, common.Unmarshal(body, &payload))
+	assert.Equal(t, TaskWebhookEventSucceeded, payload.Event)
+	assert.Equal(t, "https://cdn.example.com/video.mp4", payload.ResultUrl)
+}
+
+func TestTaskWebhookSchedulesRetryOnFailure(t *testing.T) {
+	truncate(t)
+	allowLocalTaskWebhooks(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	require.NoError(t, model.DB.Create(&model.User{Id: 4302, Username: "hook_retry", AffCode: "hook-retry-aff", Status: common.UserStatusEnabled}).Error)
+
+	task := &model.Task{TaskID: "task_hook_retry", UserId: 4302, Status: model.TaskStatusFailure, FailReason: "upstream error"}
+	task.PrivateData.CallbackURL = server.URL
+	EnqueueTaskWebhook(context.Background(), task)
+
+	runTaskWebhookDeliveryOnce()
+
+	delivery := latestTaskWebhookDelivery(t, "task_hook_retry")
+	assert.Equal(t, model.TaskWebhookStatusPending, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, http.StatusBadGateway, delivery.LastStatusCode)
+	assert.Greater(t, delivery.NextAttemptAt, common.GetTimestamp()+taskWebhookRetryDelays[0]-5)
+
+	// 未到重试时间的回调不会被再次领取
+	runTaskWebhookDeliveryOnce()
+	assert.Equal(t, 1, latestTaskWebhookDelivery(t, "task_hook_retry").Attempts)
+}
+
+func TestTaskWebhookGeneratesSecretBeforeSending(t *testing.T) {
+	truncate(t)
+	allowLocalTaskWebhooks(t)
+
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get("X-Webhook-Signature")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	require.NoError(t, model.DB.Create(&model.User{Id: 4303, Username: "hook_nosecret", AffCode: "hook-nosecret-aff", Status: common.UserStatusEnabled}).Error)
+
+	task := &model.Task{TaskID: "task_hook_secret", UserId: 4303, Status: model.TaskStatusSuccess, Progress: "100%"}
+	task.PrivateData.CallbackURL = server.URL
+	EnqueueTaskWebhook(context.Background(), task)
+
+	runTaskWebhookDeliveryOnce()
+
+	setting, err := model.GetUserSetting(4303, true)
+	require.NoError(t, err)
+	require.NotEmpty(t, setting.TaskCallbackSecret)
+	assert.Empty(t, setting.WebhookSecret, "quota notification webhooks stay unsigned")
+	assert.Equal(t, generateSignature(setting.TaskCallbackSecret, body), signature)
+	assert.Equal(t, model.TaskWebhookStatusSucceeded, latestTaskWebhookDelivery(t, "task_hook_secret").Status)
+}
+
+func TestTaskWebhookRefusesToSendWithoutSecret(t *testing.T) {
+	truncate(t)
+	allowLocalTaskWebhooks(t)
+
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// 用户已不存在，无法生成密钥，回调不会以无签名的形式发出
+	task := &model.Task{TaskID: "task_hook_orphan", UserId: 4304, Status: model.TaskStatusSuccess}
+	task.PrivateData.CallbackURL = server.URL
+	EnqueueTaskWebhook(context.Background(), task)
+
+	runTaskWebhookDeliveryOnce()
+
+	assert.False(t, called)
+	delivery := latestTaskWebhookDelivery(t, "task_hook_orphan")
+	assert.Equal(t, model.TaskWebhookStatusPending, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	tokenBudgetResetTickInterval = 1 * time.Minute
+	tokenBudgetResetBatchSize    = 300
+)
+
+var (
+	tokenBudgetResetOnce    sync.Once
+	tokenBudgetResetRunning atomic.Bool
+)
+
+// StartTokenBudgetResetTask 定期重置已到期的令牌周期预算。预扣路径也会在周期到期时
+// 按需重置，此任务保证长时间没有请求的令牌在列表中显示正确的本周期用量。
+func StartTokenBudgetResetTask() {
+	tokenBudgetResetOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("token budget reset task started: tick=%s", tokenBudgetResetTickInterval))
+			ticker := time.NewTicker(tokenBudgetResetTickInterval)
+			defer ticker.Stop()
+
+			runTokenBudgetResetOnce()
+			for range ticker.C {
+				runTokenBudgetResetOnce()
+			}
+		})
+	})
+}
+
+func runTokenBudgetResetOnce() {
+	if !tokenBudgetResetRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer tokenBudgetResetRunning.Store(false)
+
+	ctx := context.Background()
+	totalReset := 0
+	for {
+		n, err := model.ResetDueTokenBudgets(tokenBudgetResetBatchSize)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("token budget reset task failed: %v", err))
+			return
+		}
+		if n == 0 {
+			break
+		}
+		totalReset += n
+		if n < tokenBudgetResetBatchSize {
+			break
+		}
+	}
+	if common.DebugEnabled && totalReset > 0 {
+		logger.LogDebug(ctx, "token budget maintenance: reset_count=%d", totalReset)
+	}
+}
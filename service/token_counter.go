@@ -208,13 +208,8 @@ func EstimateRequestToken(c *gin.Context, meta *types.TokenCountMeta, info *rela
 			if err != nil {
 				return 0, fmt.Errorf("error getting audio duration: %v", err)
 			}
-			// duration 来自用户上传文件的元数据，可被伪造成天文数字或负数。
-			// 负值会让 token 估算变成负数（低估预扣费），先钳到 0 再转换。
-			if duration < 0 {
-				duration = 0
-			}
-			// 一分钟 1000 token，与 $price / minute 对齐。
-			totalAudioToken += common.QuotaRound(math.Ceil(duration) / 60.0 * 1000)
+			// duration 来自用户上传文件的元数据，可被伪造成天文数字或负数，统一由 AudioDurationTokens 钳制。
+			totalAudioToken += common.AudioDurationTokens(duration)
 		}
 		return totalAudioToken, nil
 	}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/billingexpr"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+)
+
+// ComputeUpstreamCost 估算本次请求付给上游的成本（额度单位）。
+// 渠道为模型配置了成本表达式时按表达式（$/1M tokens）计算，否则按成本倍率乘以
+// 未计分组倍率的用户扣费；均未配置时返回 tracked=false。
+func ComputeUpstreamCost(relayInfo *relaycommon.RelayInfo, quota int, params billingexpr.TokenParams) (cost int, tracked bool) {
+	if relayInfo == nil || relayInfo.ChannelMeta == nil {
+		return 0, false
+	}
+	groupRatio := 1.0
+	if relayInfo.PriceData.GroupRatioInfo.GroupRatio > 0 {
+		groupRatio = relayInfo.PriceData.GroupRatioInfo.GroupRatio
+	}
+	return computeUpstreamCost(&relayInfo.ChannelOtherSettings, quota, groupRatio, params,
+		relayInfo.UpstreamModelName, relayInfo.OriginModelName)
+}
+
+func computeUpstreamCost(settings *dto.ChannelOtherSettings, quota int, groupRatio float64, params billingexpr.TokenParams, modelNames ...string) (int, bool) {
+	if !settings.HasUpstreamCost() {
+		return 0, false
+	}
+	if expr := settings.UpstreamCostExpr(modelNames...); expr != "" {
+		rawCost, _, err := billingexpr.RunExpr(expr, params)
+		if err != nil {
+			common.SysError("failed to run upstream cost expression: " + err.Error())
+			return 0, false
+		}
+		return billingexpr.QuotaRound(rawCost / 1_000_000 * common.QuotaPerUnit), true
+	}
+	if settings.UpstreamCostRatio <= 0 {
+		return 0, false
+	}
+	if groupRatio <= 0 {
+		groupRatio = 1
+	}
+	return billingexpr.QuotaRound(float64(quota) / groupRatio * settings.UpstreamCostRatio), true
+}
+
+// ComputeChannelUpstreamCost 按渠道 ID 计算按次计费请求的上游成本，用于计费渠道
+// 与当前请求渠道不同的场景（如 Midjourney 按原任务渠道计费）。
+func ComputeChannelUpstreamCost(channelId int, modelName string, quota int, groupRatio float64) (int, bool) {
+	channel, err := model.CacheGetChannel(channelId)
+	if err != nil {
+		return 0, false
+	}
+	settings := channel.GetOtherSettings()
+	return computeUpstreamCost(&settings, quota, groupRatio, billingexpr.TokenParams{}, modelName)
+}
+
+// textUpstreamCostParams 按成本表达式引用的变量构造 token 参数，口径与分段计费一致。
+func textUpstreamCostParams(relayInfo *relaycommon.RelayInfo, usage *dto.Usage, summary textQuotaSummary) billingexpr.TokenParams {
+	if usage == nil || relayInfo == nil || relayInfo.ChannelMeta == nil {
+		return billingexpr.TokenParams{
+			P:   float64(summary.PromptTokens),
+			C:   float64(summary.CompletionTokens),
+			Len: float64(summary.PromptTokens),
+		}
+	}
+	var usedVars map[string]bool
+	if expr := relayInfo.ChannelOtherSettings.UpstreamCostExpr(relayInfo.UpstreamModelName, relayInfo.OriginModelName); expr != "" {
+		usedVars = billingexpr.UsedVars(expr)
+	}
+	return BuildTieredTokenParams(usage, summary.IsClaudeUsageSemantic, usedVars)
+}
+
+// taskUpstreamCost 估算异步任务差额结算部分的上游成本。结算时已没有请求上下文，
+// 只按成本倍率计算；模型配置了成本表达式时提交阶段已按次计入，差额不再统计。
+func taskUpstreamCost(task *model.Task, quota int) (int, bool) {
+	channel, err := model.CacheGetChannel(task.ChannelId)
+	if err != nil {
+		return 0, false
+	}
+	settings := channel.GetOtherSettings()
+	if settings.UpstreamCostExpr(taskModelName(task)) != "" {
+		return 0, false
+	}
+	groupRatio := 1.0
+	if bc := task.PrivateData.BillingContext; bc != nil && bc.GroupRatio > 0 {
+		groupRatio = bc.GroupRatio
+	}
+	return computeUpstreamCost(&settings, quota, groupRatio, billingexpr.TokenParams{})
+}
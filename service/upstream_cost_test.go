@@ -0,0 +1,39 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/pkg/billingexpr"
+	"github.com/QuantumNous/new-api/relaykit/dto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeUpstreamCostUsesRatioWithoutGroupRatio(t *testing.T) {
+	settings := &dto.ChannelOtherSettings{UpstreamCostRatio: 0.6}
+	cost, tracked := computeUpstreamCost(settings, 2000, 2, billingexpr.TokenParams{}, "gpt-a")
+	assert.True(t, tracked)
+	assert.Equal(t, 600, cost)
+
+	_, tracked = computeUpstreamCost(&dto.ChannelOtherSettings{}, 2000, 1, billingexpr.TokenParams{}, "gpt-a")
+	assert.False(t, tracked)
+}
+
+func TestComputeUpstreamCostPrefersModelExpression(t *testing.T) {
+	settings := &dto.ChannelOtherSettings{
+		UpstreamCostRatio: 0.6,
+		UpstreamCostExprs: map[string]string{
+			"gpt-a": "p * 1 + c * 2",
+			"*":     "p * 10",
+		},
+	}
+	params := billingexpr.TokenParams{P: 1_000_000, C: 500_000}
+	cost, tracked := computeUpstreamCost(settings, 0, 1, params, "gpt-a")
+	assert.True(t, tracked)
+	assert.Equal(t, int(2*common.QuotaPerUnit), cost)
+
+	cost, tracked = computeUpstreamCost(settings, 0, 1, params, "gpt-b")
+	assert.True(t, tracked)
+	assert.Equal(t, int(10*common.QuotaPerUnit), cost)
+}
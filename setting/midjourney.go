@@ -5,3 +5,7 @@ var MjAccountFilterEnabled = false
 var MjModeClearEnabled = false
 var MjForwardUrlEnabled = true
 var MjActionCheckSuccessEnabled = true
+
+// MjActionAnyChannelEnabled 原任务所在的上游实例不可用时，后续操作改由同一分组内任一可用的 MJ 渠道处理，
+// 适用于多个实例共享任务存储的 MJ 代理集群
+var MjActionAnyChannelEnabled = false
@@ -0,0 +1,23 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// AuditLogSetting 审计日志（哈希链存储）配置，独立于使用日志的清理策略
+type AuditLogSetting struct {
+	RetentionDays int `json:"retention_days"` // 保留天数，0 表示永久保留
+}
+
+// 默认配置
+var auditLogSetting = AuditLogSetting{
+	RetentionDays: 0, // 默认永久保留
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("audit_log_setting", &auditLogSetting)
+}
+
+// GetAuditLogSetting 获取审计日志配置
+func GetAuditLogSetting() *AuditLogSetting {
+	return &auditLogSetting
+}
@@ -0,0 +1,84 @@
+package operation_setting
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// 额度授予有效期取值
+const (
+	CreditExpiryNever    = "never"     // 永不过期
+	CreditExpiryMonthEnd = "month_end" // 授予当月月底过期
+	// 其余取值形如 "30d"，表示授予后 N 天过期
+)
+
+// CreditGrantPolicy 某一来源的额度授予策略：Priority 越小越先被消费
+type CreditGrantPolicy struct {
+	Priority int    `json:"priority"`
+	Expiry   string `json:"expiry"`
+}
+
+// CreditLedgerSetting 额度账本配置
+type CreditLedgerSetting struct {
+	Enabled       bool                         `json:"enabled"`        // 是否记录额度账本
+	GrantPolicies map[string]CreditGrantPolicy `json:"grant_policies"` // 按授予来源配置优先级与有效期
+}
+
+// 默认配置：签到等赠送额度优先消费并在月底过期，付费额度永不过期
+var creditLedgerSetting = CreditLedgerSetting{
+	Enabled: false,
+	GrantPolicies: map[string]CreditGrantPolicy{
+		"topup":           {Priority: 100, Expiry: CreditExpiryNever},
+		"redemption":      {Priority: 100, Expiry: CreditExpiryNever},
+		"checkin":         {Priority: 10, Expiry: CreditExpiryMonthEnd},
+		"affiliate":       {Priority: 50, Expiry: CreditExpiryNever},
+		"adjustment":      {Priority: 100, Expiry: CreditExpiryNever},
+		"refund":          {Priority: 100, Expiry: CreditExpiryNever},
+		"opening_balance": {Priority: 100, Expiry: CreditExpiryNever},
+	},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("credit_ledger_setting", &creditLedgerSetting)
+}
+
+// GetCreditLedgerSetting 获取额度账本配置
+func GetCreditLedgerSetting() *CreditLedgerSetting {
+	return &creditLedgerSetting
+}
+
+// IsCreditLedgerEnabled 是否启用额度账本
+func IsCreditLedgerEnabled() bool {
+	return creditLedgerSetting.Enabled
+}
+
+// GetCreditGrantPolicy 返回来源对应的授予策略，未配置的来源按付费额度处理
+func GetCreditGrantPolicy(source string) CreditGrantPolicy {
+	if policy, ok := creditLedgerSetting.GrantPolicies[source]; ok {
+		return policy
+	}
+	return CreditGrantPolicy{Priority: 100, Expiry: CreditExpiryNever}
+}
+
+// ExpiresAt 计算在 now 授予的额度的过期时间戳，0 表示永不过期
+func (p CreditGrantPolicy) ExpiresAt(now time.Time) int64 {
+	expiry := strings.TrimSpace(p.Expiry)
+	switch {
+	case expiry == "" || expiry == CreditExpiryNever:
+		return 0
+	case expiry == CreditExpiryMonthEnd:
+		year, month, _ := now.Date()
+		return time.Date(year, month+1, 1, 0, 0, 0, 0, now.Location()).Unix()
+	case strings.HasSuffix(expiry, "d"):
+		days, err := strconv.Atoi(strings.TrimSuffix(expiry, "d"))
+		if err != nil || days <= 0 {
+			return 0
+		}
+		return now.AddDate(0, 0, days).Unix()
+	}
+	return 0
+}
@@ -0,0 +1,99 @@
+package operation_setting
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// CurrencyConfig 单个币种配置，Rate 为 1 USD 可兑换的该币种数量
+type CurrencyConfig struct {
+	Symbol   string  `json:"symbol"`
+	Rate     float64 `json:"rate"`
+	Decimals int     `json:"decimals"`
+}
+
+// CurrencySetting 多币种展示与结算配置，额度始终以 USD 为基准（1 USD = QuotaPerUnit）
+type CurrencySetting struct {
+	// 可供用户选择的币种，键为 ISO 4217 代码
+	Currencies map[string]CurrencyConfig `json:"currencies"`
+	// 本地汇率文件路径，文件中的汇率覆盖手动配置
+	RateFile string `json:"rate_file"`
+	// 各支付网关的结算币种，例如 {"epay":"CNY","stripe":"EUR"}
+	ProviderCurrencies map[string]string `json:"provider_currencies"`
+	// 各币种每单位充值额度的售价，未配置的币种按汇率换算
+	TopUpPrices map[string]float64 `json:"topup_prices"`
+}
+
+var currencySetting = CurrencySetting{
+	Currencies: map[string]CurrencyConfig{
+		"USD": {Symbol: "$", Rate: 1, Decimals: 2},
+	},
+	RateFile:           "",
+	ProviderCurrencies: map[string]string{},
+	TopUpPrices:        map[string]float64{},
+}
+
+// 支付网关未配置结算币种时使用的默认值，与各网关原有的计价方式一致
+var defaultProviderCurrencies = map[string]string{
+	"epay": "CNY",
+}
+
+func init() {
+	config.GlobalConfig.Register("currency_setting", &currencySetting)
+}
+
+// GetCurrencySetting 获取多币种配置
+func GetCurrencySetting() *CurrencySetting {
+	return &currencySetting
+}
+
+// NormalizeCurrency 统一币种代码格式
+func NormalizeCurrency(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// GetCurrencyConfig 返回币种配置，USD 与 CNY 未配置时使用内置值
+func (s *CurrencySetting) GetCurrencyConfig(code string) (CurrencyConfig, bool) {
+	code = NormalizeCurrency(code)
+	for key, cfg := range s.Currencies {
+		if NormalizeCurrency(key) == code {
+			if cfg.Decimals <= 0 {
+				cfg.Decimals = 2
+			}
+			return cfg, true
+		}
+	}
+	switch code {
+	case "USD":
+		return CurrencyConfig{Symbol: "$", Rate: 1, Decimals: 2}, true
+	case "CNY":
+		return CurrencyConfig{Symbol: "¥", Rate: USDExchangeRate, Decimals: 2}, true
+	}
+	return CurrencyConfig{}, false
+}
+
+// GetProviderCurrency 返回支付网关的结算币种，未配置时返回 fallback
+func (s *CurrencySetting) GetProviderCurrency(provider string, fallback string) string {
+	if code := NormalizeCurrency(s.ProviderCurrencies[provider]); code != "" {
+		return code
+	}
+	if code, ok := defaultProviderCurrencies[provider]; ok {
+		return code
+	}
+	if code := NormalizeCurrency(fallback); code != "" {
+		return code
+	}
+	return "USD"
+}
+
+// GetTopUpPrice 返回币种的充值单价（每单位额度），未配置时 ok 为 false
+func (s *CurrencySetting) GetTopUpPrice(code string) (float64, bool) {
+	code = NormalizeCurrency(code)
+	for key, price := range s.TopUpPrices {
+		if NormalizeCurrency(key) == code && price > 0 {
+			return price, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,100 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+const (
+	MediaArchiveBackendLocal = "local"
+	MediaArchiveBackendS3    = "s3"
+)
+
+// MediaArchiveSetting 生成结果（视频、图片、音频）转存配置。上游结果地址通常数小时后失效，
+// 启用后网关下载结果并保存到本地磁盘或 S3 兼容存储，结果地址改写为网关签名地址
+type MediaArchiveSetting struct {
+	Enabled      bool   `json:"enabled"`
+	Backend      string `json:"backend"`       // local 或 s3
+	LocalPath    string `json:"local_path"`    // 本地存储目录
+	ArchiveVideo bool   `json:"archive_video"` // 转存视频任务结果
+	ArchiveImage bool   `json:"archive_image"` // 转存图片接口返回的 url
+	ArchiveAudio bool   `json:"archive_audio"` // 转存 Suno 音频
+
+	S3Endpoint     string `json:"s3_endpoint"` // 如 https://s3.us-east-1.amazonaws.com 或 MinIO 地址
+	S3Region       string `json:"s3_region"`
+	S3Bucket       string `json:"s3_bucket"`
+	S3AccessKey    string `json:"s3_access_key"`
+	S3SecretKey    string `json:"s3_secret_key"`
+	S3UsePathStyle bool   `json:"s3_use_path_style"` // MinIO 等需要路径风格访问
+
+	MaxAssetMB int `json:"max_asset_mb"` // 单个文件大小上限，超过时保留上游地址
+	// RetentionDays 每个用户文件的默认保留天数，0 表示永久保留；签名地址最迟在保留期结束时失效
+	RetentionDays int `json:"retention_days"`
+	// SignedURLHours 签名地址的有效小时数，读取结果时重新签发
+	SignedURLHours int `json:"signed_url_hours"`
+	// UserQuotaMB 每个用户的默认存储空间上限，0 表示不限制；超出后新结果不再转存
+	UserQuotaMB int `json:"user_quota_mb"`
+	// GroupRetentionDays / GroupQuotaMB 按用户分组覆盖默认保留天数和存储上限
+	GroupRetentionDays map[string]int `json:"group_retention_days"`
+	GroupQuotaMB       map[string]int `json:"group_quota_mb"`
+	// PricePerGB 每 GB 转存文件的价格（美元），0 表示不单独计费
+	PricePerGB float64 `json:"price_per_gb"`
+}
+
+var mediaArchiveSetting = MediaArchiveSetting{
+	Enabled:            false,
+	Backend:            MediaArchiveBackendLocal,
+	LocalPath:          "./data/media",
+	ArchiveVideo:       true,
+	ArchiveImage:       true,
+	ArchiveAudio:       true,
+	MaxAssetMB:         512,
+	RetentionDays:      30,
+	SignedURLHours:     24,
+	UserQuotaMB:        0,
+	GroupRetentionDays: map[string]int{},
+	GroupQuotaMB:       map[string]int{},
+	PricePerGB:         0,
+}
+
+func init() {
+	config.GlobalConfig.Register("media_archive_setting", &mediaArchiveSetting)
+}
+
+// GetMediaArchiveSetting 获取媒体转存配置
+func GetMediaArchiveSetting() *MediaArchiveSetting {
+	return &mediaArchiveSetting
+}
+
+// RetentionDaysForGroup 返回指定分组的保留天数，未单独配置时使用默认值
+func (s *MediaArchiveSetting) RetentionDaysForGroup(group string) int {
+	if days, ok := s.GroupRetentionDays[group]; ok {
+		return days
+	}
+	return s.RetentionDays
+}
+
+// QuotaBytesForGroup 返回指定分组每个用户的存储上限（字节），0 表示不限制
+func (s *MediaArchiveSetting) QuotaBytesForGroup(group string) int64 {
+	quotaMB := s.UserQuotaMB
+	if mb, ok := s.GroupQuotaMB[group]; ok {
+		quotaMB = mb
+	}
+	if quotaMB <= 0 {
+		return 0
+	}
+	return int64(quotaMB) << 20
+}
+
+// MaxAssetBytes 返回单个文件大小上限（字节），配置无效时使用 512MB
+func (s *MediaArchiveSetting) MaxAssetBytes() int64 {
+	if s.MaxAssetMB <= 0 {
+		return 512 << 20
+	}
+	return int64(s.MaxAssetMB) << 20
+}
+
+// SignedURLSeconds 返回签名地址的有效期（秒），配置无效时使用 24 小时
+func (s *MediaArchiveSetting) SignedURLSeconds() int64 {
+	if s.SignedURLHours <= 0 {
+		return 24 * 3600
+	}
+	return int64(s.SignedURLHours) * 3600
+}
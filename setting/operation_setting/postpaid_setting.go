@@ -0,0 +1,34 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// PostpaidSetting 后付费账户的催缴策略，天数均从账单到期日起算
+type PostpaidSetting struct {
+	Enabled                   bool `json:"enabled"`                      // 是否启用后付费：信用额度可用，并运行账期结算与催缴任务
+	WarnDays                  int  `json:"warn_days"`                    // 逾期多少天后发送催缴提醒
+	ThrottleDays              int  `json:"throttle_days"`                // 逾期多少天后限速
+	SuspendDays               int  `json:"suspend_days"`                 // 逾期多少天后停用信用额度
+	ThrottleRequestsPerMinute int  `json:"throttle_requests_per_minute"` // 限速阶段每分钟允许的请求数
+}
+
+var postpaidSetting = PostpaidSetting{
+	Enabled:                   false,
+	WarnDays:                  0,
+	ThrottleDays:              7,
+	SuspendDays:               14,
+	ThrottleRequestsPerMinute: 10,
+}
+
+func init() {
+	config.GlobalConfig.Register("postpaid_setting", &postpaidSetting)
+}
+
+// GetPostpaidSetting 获取后付费催缴配置
+func GetPostpaidSetting() *PostpaidSetting {
+	return &postpaidSetting
+}
+
+// IsPostpaidEnabled 是否启用后付费；未启用时信用额度不参与扣费
+func IsPostpaidEnabled() bool {
+	return postpaidSetting.Enabled
+}
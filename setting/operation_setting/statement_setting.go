@@ -0,0 +1,55 @@
+package operation_setting
+
+import (
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// StatementSetting 月度账单配置
+type StatementSetting struct {
+	Enabled       bool   `json:"enabled"`        // 是否每月自动生成账单
+	InvoicePrefix string `json:"invoice_prefix"` // 发票编号前缀
+	Timezone      string `json:"timezone"`       // 账单周期所用时区，为空时使用服务器时区
+	// PDFFontPath 嵌入 PDF 的 TrueType 字体（.ttf）路径，用于中文等非 Latin-1 文字；
+	// 为空时含此类文字的账单无法导出 PDF
+	PDFFontPath string `json:"pdf_font_path"`
+}
+
+var statementSetting = StatementSetting{
+	Enabled:       false,
+	InvoicePrefix: "INV",
+	Timezone:      "",
+}
+
+func init() {
+	config.GlobalConfig.Register("statement_setting", &statementSetting)
+}
+
+// GetStatementSetting 获取月度账单配置
+func GetStatementSetting() *StatementSetting {
+	return &statementSetting
+}
+
+// Location 返回账单周期所用时区，配置无效时回退到服务器时区
+func (s *StatementSetting) Location() *time.Location {
+	timezone := strings.TrimSpace(s.Timezone)
+	if timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// GetInvoicePrefix 返回发票编号前缀
+func (s *StatementSetting) GetInvoicePrefix() string {
+	prefix := strings.TrimSpace(s.InvoicePrefix)
+	if prefix == "" {
+		return "INV"
+	}
+	return prefix
+}
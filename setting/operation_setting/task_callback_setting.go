@@ -0,0 +1,32 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// TaskCallbackSetting 上游任务回调配置
+type TaskCallbackSetting struct {
+	Enabled bool `json:"enabled"` // 是否向支持回调的上游传递网关回调地址
+	// SafetyNetPollSeconds 已登记回调的任务的兜底轮询间隔（秒），防止回调丢失
+	SafetyNetPollSeconds int `json:"safety_net_poll_seconds"`
+}
+
+var taskCallbackSetting = TaskCallbackSetting{
+	Enabled:              false,
+	SafetyNetPollSeconds: 600,
+}
+
+func init() {
+	config.GlobalConfig.Register("task_callback_setting", &taskCallbackSetting)
+}
+
+// GetTaskCallbackSetting 获取上游任务回调配置
+func GetTaskCallbackSetting() *TaskCallbackSetting {
+	return &taskCallbackSetting
+}
+
+// SafetyNetPollInterval 返回兜底轮询间隔（秒），配置无效时使用 600
+func (s *TaskCallbackSetting) SafetyNetPollInterval() int64 {
+	if s.SafetyNetPollSeconds <= 0 {
+		return 600
+	}
+	return int64(s.SafetyNetPollSeconds)
+}
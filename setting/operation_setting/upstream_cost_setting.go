@@ -0,0 +1,23 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// UpstreamCostSetting 上游成本相关配置
+type UpstreamCostSetting struct {
+	// PreferCheapestChannel 同优先级渠道中优先选择上游成本倍率最低的渠道，
+	// 未配置成本倍率的渠道仅在没有可比较渠道时参与选择
+	PreferCheapestChannel bool `json:"prefer_cheapest_channel"`
+}
+
+var upstreamCostSetting = UpstreamCostSetting{
+	PreferCheapestChannel: false,
+}
+
+func init() {
+	config.GlobalConfig.Register("upstream_cost_setting", &upstreamCostSetting)
+}
+
+// GetUpstreamCostSetting 获取上游成本配置
+func GetUpstreamCostSetting() *UpstreamCostSetting {
+	return &upstreamCostSetting
+}
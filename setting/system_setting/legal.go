@@ -5,6 +5,12 @@ import "github.com/QuantumNous/new-api/setting/config"
 type LegalSettings struct {
 	UserAgreement string `json:"user_agreement"`
 	PrivacyPolicy string `json:"privacy_policy"`
+	// 以下为账单/发票抬头中展示的公司信息
+	CompanyName    string `json:"company_name"`
+	CompanyAddress string `json:"company_address"`
+	CompanyTaxId   string `json:"company_tax_id"`
+	CompanyEmail   string `json:"company_email"`
+	InvoiceFooter  string `json:"invoice_footer"`
 }
 
 var defaultLegalSettings = LegalSettings{
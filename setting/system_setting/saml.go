@@ -0,0 +1,88 @@
+package system_setting
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// SAMLSettings configures SAML 2.0 single sign-on with one identity
+// provider. The IdP is described either by pasting its metadata XML into
+// IdPMetadata or by the explicit entity ID / SSO URL / certificate fields,
+// which take precedence when set. The SP key pair signs AuthnRequests and is
+// published in the SP metadata. Attribute names select which assertion
+// attributes fill the local username, email, display name and group; an
+// empty name falls back to the NameID where that makes sense. GroupMapping
+// is a JSON object mapping IdP group values onto new-api user groups.
+type SAMLSettings struct {
+	Enabled              bool   `json:"enabled"`
+	DisplayName          string `json:"display_name"`
+	SPEntityId           string `json:"sp_entity_id"`
+	SPCertificate        string `json:"sp_certificate"`
+	SPPrivateKey         string `json:"sp_private_key"`
+	IdPMetadata          string `json:"idp_metadata"`
+	IdPEntityId          string `json:"idp_entity_id"`
+	IdPSSOURL            string `json:"idp_sso_url"`
+	IdPCertificate       string `json:"idp_certificate"`
+	NameIDFormat         string `json:"name_id_format"`
+	UsernameAttribute    string `json:"username_attribute"`
+	EmailAttribute       string `json:"email_attribute"`
+	DisplayNameAttribute string `json:"display_name_attribute"`
+	GroupAttribute       string `json:"group_attribute"`
+	GroupMapping         string `json:"group_mapping"`
+}
+
+var defaultSAMLSettings = SAMLSettings{
+	NameIDFormat:         "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent",
+	EmailAttribute:       "email",
+	DisplayNameAttribute: "displayName",
+	GroupMapping:         "{}",
+}
+
+func init() {
+	config.GlobalConfig.Register("saml", &defaultSAMLSettings)
+}
+
+func GetSAMLSettings() *SAMLSettings {
+	return &defaultSAMLSettings
+}
+
+func (s *SAMLSettings) GetEffectiveDisplayName() string {
+	if trimmed := strings.TrimSpace(s.DisplayName); trimmed != "" {
+		return trimmed
+	}
+	return "SAML"
+}
+
+// GetEffectiveSPEntityId defaults the SP entity ID to the metadata URL, the
+// convention most IdPs expect.
+func (s *SAMLSettings) GetEffectiveSPEntityId() string {
+	if trimmed := strings.TrimSpace(s.SPEntityId); trimmed != "" {
+		return trimmed
+	}
+	return strings.TrimRight(ServerAddress, "/") + "/api/saml/metadata"
+}
+
+func (s *SAMLSettings) GetACSURL() string {
+	return strings.TrimRight(ServerAddress, "/") + "/api/saml/acs"
+}
+
+// MapGroup returns the new-api group for the first IdP group value that has
+// a mapping, or "" when none does. Unlike SCIM, unmapped SAML groups are
+// ignored so that arbitrary IdP groups cannot select a billing group.
+func (s *SAMLSettings) MapGroup(values []string) string {
+	mapping := make(map[string]string)
+	if strings.TrimSpace(s.GroupMapping) != "" {
+		if err := common.UnmarshalJsonStr(s.GroupMapping, &mapping); err != nil {
+			common.SysError("failed to parse saml.group_mapping: " + err.Error())
+			return ""
+		}
+	}
+	for _, value := range values {
+		if mapped, ok := mapping[strings.TrimSpace(value)]; ok && strings.TrimSpace(mapped) != "" {
+			return strings.TrimSpace(mapped)
+		}
+	}
+	return ""
+}
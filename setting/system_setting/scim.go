@@ -0,0 +1,56 @@
+package system_setting
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// SCIMSettings configures the SCIM 2.0 provisioning endpoint (/scim/v2).
+// BearerToken authenticates the identity provider; GroupMapping is a JSON
+// object mapping IdP group display names onto new-api user groups.
+type SCIMSettings struct {
+	Enabled      bool   `json:"enabled"`
+	BearerToken  string `json:"bearer_token"`
+	DefaultGroup string `json:"default_group"`
+	GroupMapping string `json:"group_mapping"`
+}
+
+var defaultSCIMSettings = SCIMSettings{
+	DefaultGroup: "default",
+	GroupMapping: "{}",
+}
+
+func init() {
+	config.GlobalConfig.Register("scim", &defaultSCIMSettings)
+}
+
+func GetSCIMSettings() *SCIMSettings {
+	return &defaultSCIMSettings
+}
+
+// GetEffectiveDefaultGroup returns the group assigned to users that are
+// removed from every SCIM group.
+func (s *SCIMSettings) GetEffectiveDefaultGroup() string {
+	if trimmed := strings.TrimSpace(s.DefaultGroup); trimmed != "" {
+		return trimmed
+	}
+	return "default"
+}
+
+// MapGroup translates an IdP group display name into a new-api group. Names
+// without an explicit mapping are used as-is.
+func (s *SCIMSettings) MapGroup(displayName string) string {
+	displayName = strings.TrimSpace(displayName)
+	mapping := make(map[string]string)
+	if strings.TrimSpace(s.GroupMapping) != "" {
+		if err := common.UnmarshalJsonStr(s.GroupMapping, &mapping); err != nil {
+			common.SysError("failed to parse scim.group_mapping: " + err.Error())
+		}
+	}
+	if mapped, ok := mapping[displayName]; ok && strings.TrimSpace(mapped) != "" {
+		return strings.TrimSpace(mapped)
+	}
+	return displayName
+}
@@ -11,6 +11,7 @@ type GroupRatioInfo struct {
 	GroupRatio        float64
 	GroupSpecialRatio float64
 	HasSpecialRatio   bool
+	ResellerMarkup    float64 // 分销商加价，已计入 GroupRatio；0 表示未加价
 }
 
 type PriceData struct {
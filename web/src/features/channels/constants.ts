@@ -81,10 +81,11 @@ export const CHANNEL_TYPES = {
   58: 'Advanced Custom',
   59: 'Sub2API',
   60: 'New API',
+  61: 'Azure Speech',
 } as const
 
 const CHANNEL_TYPE_DISPLAY_ORDER: number[] = [
-  1, 14, 33, 24, 43, 3, 41, 48, 60, 58, 42, 34, 20, 4, 40, 27, 25, 17, 26, 15,
+  1, 14, 33, 24, 43, 3, 61, 41, 48, 60, 58, 42, 34, 20, 4, 40, 27, 25, 17, 26, 15,
   46, 23, 18, 45, 31, 35, 49, 19, 47, 37, 38, 39, 11, 8, 57, 59, 22, 21, 44, 2,
   5, 36, 50, 51, 52, 53, 54, 55, 56,
 ]
@@ -403,6 +404,7 @@ export const TYPE_TO_KEY_PROMPT: Record<number, string> = {
   57: 'Paste Codex OAuth JSON credential (access_token / refresh_token / account_id)',
   59: 'Enter API key for this channel',
   60: 'Enter API key for this channel',
+  61: 'Azure Speech resource key; set the base URL to the resource endpoint, e.g. https://eastus.api.cognitive.microsoft.com',
 }
 
 export const CHANNEL_TYPE_WARNINGS: Record<number, string> = {
@@ -164,6 +164,16 @@ export const CHANNEL_TYPE_CONFIGS: Record<number, ChannelTypeConfig> = {
       models: 'Models',
     },
   },
+  61: {
+    id: 61,
+    name: CHANNEL_TYPES[61],
+    icon: 'Azure',
+    hints: {
+      baseUrl: 'Speech resource endpoint, e.g. https://eastus.api.cognitive.microsoft.com',
+      key: 'Speech resource key',
+      models: 'azure-tts, azure-stt',
+    },
+  },
 }
 
 /**
@@ -55,6 +55,7 @@ export function getChannelTypeIcon(type: number): string {
     59: 'Sub2API', // Sub2API
     60: 'NewAPI', // New API
     3: 'Azure', // Azure
+    61: 'Azure', // Azure Speech
 
     // Anthropic
     14: 'Claude', // Anthropic
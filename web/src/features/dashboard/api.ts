@@ -19,6 +19,7 @@ For commercial licensing, please contact support@quantumnous.com
 import { api } from '@/lib/api'
 
 import type {
+  ChannelMarginItem,
   FlowQuotaDataItem,
   QuotaDataItem,
   UptimeGroupResult,
@@ -84,6 +85,31 @@ export async function getFlowQuotaDates(
   return res.data
 }
 
+// Get per-day channel revenue, upstream cost and margin (admin only)
+export async function getChannelMarginReport(params: {
+  start_timestamp: number
+  end_timestamp: number
+}) {
+  const res = await api.get<{
+    success: boolean
+    data?: ChannelMarginItem[]
+    message?: string
+  }>('/api/data/margin', { params })
+  return res.data
+}
+
+// Download the channel margin report as CSV (admin only)
+export async function downloadChannelMarginReport(params: {
+  start_timestamp: number
+  end_timestamp: number
+}) {
+  const res = await api.get<Blob>('/api/data/margin', {
+    params: { ...params, format: 'csv' },
+    responseType: 'blob',
+  })
+  return res.data
+}
+
 // Get uptime monitoring status for all services
 export async function getUptimeStatus() {
   const res = await api.get<{ success: boolean; data: UptimeGroupResult[] }>(
@@ -0,0 +1,259 @@
+/*
+Copyright (C) 2023-2026 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+import { useQuery } from '@tanstack/react-query'
+import { Download, Loader2 } from 'lucide-react'
+import { useCallback, useMemo, useState } from 'react'
+import { useTranslation } from 'react-i18next'
+import { toast } from 'sonner'
+
+import { Button } from '@/components/ui/button'
+import { Skeleton } from '@/components/ui/skeleton'
+import {
+  Table,
+  TableBody,
+  TableCell,
+  TableHead,
+  TableHeader,
+  TableRow,
+} from '@/components/ui/table'
+import { Tabs, TabsList, TabsTrigger } from '@/components/ui/tabs'
+import {
+  downloadChannelMarginReport,
+  getChannelMarginReport,
+} from '@/features/dashboard/api'
+import { TIME_RANGE_PRESETS } from '@/features/dashboard/constants'
+import type { ChannelMarginItem } from '@/features/dashboard/types'
+import { formatQuota } from '@/lib/format'
+import { getRollingDateRange } from '@/lib/time'
+
+interface MarginSummary {
+  key: string
+  channelName: string
+  modelName: string
+  count: number
+  quota: number
+  costedQuota: number
+  upstreamCost: number
+  margin: number
+}
+
+// The report is per day; the dashboard shows the range totals per channel
+// and model, sorted by revenue.
+function summarizeMargin(rows: ChannelMarginItem[]): MarginSummary[] {
+  const byKey = new Map<string, MarginSummary>()
+  for (const row of rows) {
+    const key = `${row.channel_id}:${row.model_name}`
+    const item = byKey.get(key) ?? {
+      key,
+      channelName: row.channel_name || `#${row.channel_id}`,
+      modelName: row.model_name,
+      count: 0,
+      quota: 0,
+      costedQuota: 0,
+      upstreamCost: 0,
+      margin: 0,
+    }
+    item.count += row.count
+    item.quota += row.quota
+    item.costedQuota += row.costed_quota
+    item.upstreamCost += row.upstream_cost
+    item.margin += row.margin
+    byKey.set(key, item)
+  }
+  return [...byKey.values()].sort((a, b) => b.quota - a.quota)
+}
+
+function formatMarginRate(
+  item: Pick<MarginSummary, 'margin' | 'costedQuota'>
+) {
+  if (item.costedQuota <= 0) return '-'
+  return `${((item.margin / item.costedQuota) * 100).toFixed(1)}%`
+}
+
+export function ChannelMargin() {
+  const { t } = useTranslation()
+  const [selectedRange, setSelectedRange] = useState<number>(
+    TIME_RANGE_PRESETS[1].days
+  )
+  const [downloading, setDownloading] = useState(false)
+
+  const timeRange = useMemo(() => {
+    const { start, end } = getRollingDateRange(selectedRange)
+    return {
+      start_timestamp: Math.floor(start.getTime() / 1000),
+      end_timestamp: Math.floor(end.getTime() / 1000),
+    }
+  }, [selectedRange])
+
+  const { data, isLoading } = useQuery({
+    queryKey: ['dashboard', 'channel-margin', timeRange],
+    queryFn: () => getChannelMarginReport(timeRange),
+    select: (res) => (res.success ? (res.data ?? []) : []),
+    staleTime: 60_000,
+  })
+
+  const summary = useMemo(() => summarizeMargin(data ?? []), [data])
+  const totals = useMemo(
+    () =>
+      summary.reduce(
+        (acc, item) => ({
+          quota: acc.quota + item.quota,
+          costedQuota: acc.costedQuota + item.costedQuota,
+          upstreamCost: acc.upstreamCost + item.upstreamCost,
+          margin: acc.margin + item.margin,
+        }),
+        { quota: 0, costedQuota: 0, upstreamCost: 0, margin: 0 }
+      ),
+    [summary]
+  )
+
+  const handleDownload = useCallback(async () => {
+    setDownloading(true)
+    try {
+      const blob = await downloadChannelMarginReport(timeRange)
+      const url = URL.createObjectURL(blob)
+      const a = document.createElement('a')
+      a.href = url
+      a.download = `channel-margin-${timeRange.start_timestamp}-${timeRange.end_timestamp}.csv`
+      a.click()
+      URL.revokeObjectURL(url)
+    } catch {
+      toast.error(t('Export failed'))
+    } finally {
+      setDownloading(false)
+    }
+  }, [timeRange, t])
+
+  const cards = [
+    { key: 'revenue', label: t('Revenue'), value: formatQuota(totals.quota) },
+    {
+      key: 'cost',
+      label: t('Upstream Cost'),
+      value: formatQuota(totals.upstreamCost),
+    },
+    { key: 'margin', label: t('Margin'), value: formatQuota(totals.margin) },
+    { key: 'rate', label: t('Margin Rate'), value: formatMarginRate(totals) },
+  ]
+
+  return (
+    <div className='space-y-3'>
+      <div className='flex items-center gap-1.5 overflow-x-auto pb-1 sm:gap-2'>
+        <Tabs
+          value={String(selectedRange)}
+          onValueChange={(value) => setSelectedRange(Number(value))}
+          className='shrink-0'
+        >
+          <TabsList>
+            {TIME_RANGE_PRESETS.map((preset) => (
+              <TabsTrigger
+                key={preset.days}
+                value={String(preset.days)}
+                className='px-2.5 text-xs'
+              >
+                {t(preset.label)}
+              </TabsTrigger>
+            ))}
+          </TabsList>
+        </Tabs>
+        {isLoading && (
+          <Loader2 className='text-muted-foreground size-4 animate-spin' />
+        )}
+        <Button
+          variant='outline'
+          size='sm'
+          className='ml-auto shrink-0'
+          onClick={handleDownload}
+          disabled={downloading}
+        >
+          {downloading ? <Loader2 className='animate-spin' /> : <Download />}
+          {t('Export CSV')}
+        </Button>
+      </div>
+
+      <div className='overflow-hidden rounded-lg border'>
+        <div className='divide-border/60 grid grid-cols-2 divide-x sm:grid-cols-4'>
+          {cards.map((card) => (
+            <div key={card.key} className='px-2.5 py-1.5 sm:px-5 sm:py-4'>
+              <div className='text-muted-foreground text-xs sm:text-sm'>
+                {card.label}
+              </div>
+              {isLoading ? (
+                <Skeleton className='mt-1 h-5 w-16 sm:mt-2 sm:h-7 sm:w-20' />
+              ) : (
+                <div className='mt-1 text-base font-semibold sm:mt-2 sm:text-xl'>
+                  {card.value}
+                </div>
+              )}
+            </div>
+          ))}
+        </div>
+      </div>
+
+      <div className='overflow-hidden rounded-lg border'>
+        <Table>
+          <TableHeader>
+            <TableRow>
+              <TableHead>{t('Channel')}</TableHead>
+              <TableHead>{t('Model')}</TableHead>
+              <TableHead className='text-right'>{t('Requests')}</TableHead>
+              <TableHead className='text-right'>{t('Revenue')}</TableHead>
+              <TableHead className='text-right'>
+                {t('Upstream Cost')}
+              </TableHead>
+              <TableHead className='text-right'>{t('Margin')}</TableHead>
+              <TableHead className='text-right'>{t('Margin Rate')}</TableHead>
+            </TableRow>
+          </TableHeader>
+          <TableBody>
+            {!isLoading && summary.length === 0 && (
+              <TableRow>
+                <TableCell
+                  colSpan={7}
+                  className='text-muted-foreground h-24 text-center'
+                >
+                  {t('No data')}
+                </TableCell>
+              </TableRow>
+            )}
+            {summary.map((item) => (
+              <TableRow key={item.key}>
+                <TableCell>{item.channelName}</TableCell>
+                <TableCell className='font-mono text-xs'>
+                  {item.modelName}
+                </TableCell>
+                <TableCell className='text-right'>{item.count}</TableCell>
+                <TableCell className='text-right'>
+                  {formatQuota(item.quota)}
+                </TableCell>
+                <TableCell className='text-right'>
+                  {formatQuota(item.upstreamCost)}
+                </TableCell>
+                <TableCell className='text-right'>
+                  {formatQuota(item.margin)}
+                </TableCell>
+                <TableCell className='text-right'>
+                  {formatMarginRate(item)}
+                </TableCell>
+              </TableRow>
+            ))}
+          </TableBody>
+        </Table>
+      </div>
+    </div>
+  )
+}
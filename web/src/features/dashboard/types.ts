@@ -48,6 +48,18 @@ export interface FlowQuotaDataItem {
   quota?: number
 }
 
+export interface ChannelMarginItem {
+  day: number
+  channel_id: number
+  channel_name: string
+  model_name: string
+  count: number
+  quota: number
+  costed_quota: number
+  upstream_cost: number
+  margin: number
+}
+
 export type FlowMetric = 'quota' | 'tokens' | 'requests'
 
 export type FlowOverflowMode = 'aggregate' | 'hide'
@@ -83,6 +83,11 @@ export function NotificationTab({ profile, onUpdate }: NotificationTabProps) {
     upstream_model_update_notify_enabled: false,
   })
 
+  // Task callbacks are signed with a separate, server-generated secret
+  const taskCallbackSecret = profile?.setting
+    ? (parseUserSettings(profile.setting).task_callback_secret ?? '')
+    : ''
+
   // Update form field helper
   const updateField = useCallback(
     <K extends keyof UserSettings>(field: K, value: UserSettings[K]) => {
@@ -320,6 +325,25 @@ export function NotificationTab({ profile, onUpdate }: NotificationTabProps) {
         </>
       )}
 
+      {/* Task Callback Secret */}
+      {taskCallbackSecret && (
+        <div className='space-y-1.5'>
+          <Label htmlFor='taskCallbackSecret'>
+            {t('Task Callback Secret')}
+          </Label>
+          <PasswordInput
+            id='taskCallbackSecret'
+            value={taskCallbackSecret}
+            readOnly
+          />
+          <p className='text-muted-foreground text-xs'>
+            {t(
+              'Task completion callbacks are signed with this secret in the X-Webhook-Signature header.'
+            )}
+          </p>
+        </div>
+      )}
+
       {/* Divider */}
       <div className='border-t' />
 
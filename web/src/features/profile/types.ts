@@ -100,6 +100,8 @@ export interface UserSettings {
   webhook_url?: string
   /** Webhook secret */
   webhook_secret?: string
+  /** Task callback signing secret (read-only) */
+  task_callback_secret?: string
   /** Notification email */
   notification_email?: string
   /** Bark URL */
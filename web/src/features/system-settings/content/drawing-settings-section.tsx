@@ -48,6 +48,7 @@ const drawingSchema = z.object({
   MjForwardUrlEnabled: z.boolean(),
   MjModeClearEnabled: z.boolean(),
   MjActionCheckSuccessEnabled: z.boolean(),
+  MjActionAnyChannelEnabled: z.boolean(),
 })
 
 type DrawingFormValues = z.infer<typeof drawingSchema>
@@ -127,6 +128,13 @@ export function DrawingSettingsSection({
         'Users must wait for a successful drawing before upscales or variations.'
       ),
     },
+    {
+      name: 'MjActionAnyChannelEnabled',
+      label: t('Allow follow-up actions on any pool channel'),
+      description: t(
+        'When the original channel is gone or disabled, follow-up actions fall back to another Midjourney channel in the same group.'
+      ),
+    },
   ]
 
   return (
@@ -93,6 +93,7 @@ export function TaskLogsFilterBar<TData>(props: TaskLogsFilterBarProps<TData>) {
         ? {
             ...baseFilters,
             ...(searchParams.filter ? { mjId: searchParams.filter } : {}),
+            ...(searchParams.prompt ? { prompt: searchParams.prompt } : {}),
           }
         : {
             ...baseFilters,
@@ -106,6 +107,7 @@ export function TaskLogsFilterBar<TData>(props: TaskLogsFilterBarProps<TData>) {
     searchParams.endTime,
     searchParams.channel,
     searchParams.filter,
+    searchParams.prompt,
   ])
 
   const handleChange = useCallback(
@@ -164,7 +166,12 @@ export function TaskLogsFilterBar<TData>(props: TaskLogsFilterBarProps<TData>) {
     props.logCategory === 'drawing'
       ? t('Filter by MjProxy task ID')
       : t('Filter by task ID')
-  const hasAdditionalFilters = !!filterValue || !!filters.channel
+  const promptValue =
+    props.logCategory === 'drawing'
+      ? (filters as DrawingLogFilters).prompt || ''
+      : ''
+  const hasAdditionalFilters =
+    !!filterValue || !!promptValue || !!filters.channel
   const dateRangeFilter = (
     <LogsFilterField wide>
       <CompactDateTimeRangePicker
@@ -188,6 +195,20 @@ export function TaskLogsFilterBar<TData>(props: TaskLogsFilterBarProps<TData>) {
       />
     </LogsFilterField>
   )
+  const promptFilter =
+    props.logCategory === 'drawing' ? (
+      <LogsFilterField>
+        <LogsFilterInput
+          aria-label={t('Prompt')}
+          placeholder={t('Search prompts')}
+          value={promptValue}
+          onChange={(e) =>
+            setFilters((prev) => ({ ...prev, prompt: e.target.value }))
+          }
+          onKeyDown={handleKeyDown}
+        />
+      </LogsFilterField>
+    ) : null
   const channelFilter = isAdmin ? (
     <LogsFilterField>
       <LogsFilterInput
@@ -206,6 +227,7 @@ export function TaskLogsFilterBar<TData>(props: TaskLogsFilterBarProps<TData>) {
         <>
           {dateRangeFilter}
           {taskIdFilter}
+          {promptFilter}
           {channelFilter}
         </>
       }
@@ -213,10 +235,13 @@ export function TaskLogsFilterBar<TData>(props: TaskLogsFilterBarProps<TData>) {
       mobileFilters={
         <>
           {taskIdFilter}
+          {promptFilter}
           {channelFilter}
         </>
       }
-      mobileFilterCount={[filterValue, filters.channel].filter(Boolean).length}
+      mobileFilterCount={
+        [filterValue, promptValue, filters.channel].filter(Boolean).length
+      }
       hasActiveFilters={hasAdditionalFilters}
       onSearch={handleApply}
       searchLoading={fetchingLogs > 0}
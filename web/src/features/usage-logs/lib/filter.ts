@@ -65,6 +65,7 @@ export function buildSearchParams(
       return {
         ...baseParams,
         ...(drawingFilters.mjId && { filter: drawingFilters.mjId }),
+        ...(drawingFilters.prompt && { prompt: drawingFilters.prompt }),
       }
     }
     case 'task': {
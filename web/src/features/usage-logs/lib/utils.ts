@@ -284,7 +284,10 @@ export async function fetchLogsByCategory(
   const paramsWithFilter = {
     ...baseParams,
     ...(logCategory === 'drawing'
-      ? { mj_id: searchParams.filter as string | undefined }
+      ? {
+          mj_id: searchParams.filter as string | undefined,
+          prompt: searchParams.prompt as string | undefined,
+        }
       : {}),
     ...(logCategory === 'task'
       ? { task_id: searchParams.filter as string | undefined }
@@ -61,6 +61,7 @@ export interface CommonLogFilters extends CommonFilters {
  */
 export interface DrawingLogFilters extends CommonFilters {
   mjId?: string
+  prompt?: string
 }
 
 /**
@@ -365,6 +366,7 @@ export interface GetMidjourneyLogsParams {
   page_size?: number
   channel_id?: string
   mj_id?: string
+  prompt?: string
   start_timestamp?: number
   end_timestamp?: number
 }